@@ -0,0 +1,315 @@
+// Package accountwatch polls the account and calls typed callbacks for its
+// changes, such as a move of the equity, a drop of the buying power or a
+// block of the trading, e.g.
+//
+//	watcher := accountwatch.New(client,
+//		accountwatch.WithEquityThreshold(decimal.New(500, 0)),
+//		accountwatch.OnFlagChange(func(c accountwatch.FlagChange) {
+//			if c.Flag == accountwatch.TradingBlocked && c.Value {
+//				alert("trading blocked")
+//			}
+//		}),
+//	)
+//	go watcher.Run(ctx)
+package accountwatch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/shopspring/decimal"
+)
+
+// Flag is a boolean field of the account
+type Flag string
+
+// The flags watched
+const (
+	TradingBlocked   Flag = "trading_blocked"
+	TransfersBlocked Flag = "transfers_blocked"
+	AccountBlocked   Flag = "account_blocked"
+	ShortingEnabled  Flag = "shorting_enabled"
+	PatternDayTrader Flag = "pattern_day_trader"
+)
+
+var flags = []struct {
+	flag  Flag
+	value func(a *alpaca.Account) bool
+}{
+	{TradingBlocked, func(a *alpaca.Account) bool { return a.TradingBlocked }},
+	{TransfersBlocked, func(a *alpaca.Account) bool { return a.TransfersBlocked }},
+	{AccountBlocked, func(a *alpaca.Account) bool { return a.AccountBlocked }},
+	{ShortingEnabled, func(a *alpaca.Account) bool { return a.ShortingEnabled }},
+	{PatternDayTrader, func(a *alpaca.Account) bool { return a.PatternDayTrader }},
+}
+
+// Event is a change of the account. It is one of EquityChange,
+// BuyingPowerDrop, FlagChange and StatusChange.
+type Event interface {
+	// Snapshot returns the account the change was found in
+	Snapshot() *alpaca.Account
+	event()
+}
+
+// EquityChange is a move of the equity beyond the threshold of the watcher
+type EquityChange struct {
+	Account *alpaca.Account
+	// Previous is the equity of the last equity change, or of the first
+	// poll
+	Previous decimal.Decimal
+	Current  decimal.Decimal
+}
+
+// Change returns the change of the equity, negative for a loss.
+func (c EquityChange) Change() decimal.Decimal {
+	return c.Current.Sub(c.Previous)
+}
+
+// BuyingPowerDrop is a fall of the buying power beyond the threshold of the
+// watcher
+type BuyingPowerDrop struct {
+	Account *alpaca.Account
+	// Previous is the buying power of the last drop, or the highest since
+	Previous decimal.Decimal
+	Current  decimal.Decimal
+}
+
+// FlagChange is a flag of the account turned on or off
+type FlagChange struct {
+	Account *alpaca.Account
+	Flag    Flag
+	Value   bool
+}
+
+// StatusChange is a change of the status of the account, e.g. from ACTIVE
+// to ACCOUNT_CLOSED
+type StatusChange struct {
+	Account *alpaca.Account
+	From    string
+	To      string
+}
+
+func (c EquityChange) Snapshot() *alpaca.Account    { return c.Account }
+func (c BuyingPowerDrop) Snapshot() *alpaca.Account { return c.Account }
+func (c FlagChange) Snapshot() *alpaca.Account      { return c.Account }
+func (c StatusChange) Snapshot() *alpaca.Account    { return c.Account }
+
+func (EquityChange) event()    {}
+func (BuyingPowerDrop) event() {}
+func (FlagChange) event()      {}
+func (StatusChange) event()    {}
+
+// Option configures a Watcher
+type Option func(w *Watcher)
+
+// WithInterval sets how often Run polls, every minute by default.
+func WithInterval(interval time.Duration) Option {
+	return func(w *Watcher) {
+		w.interval = interval
+	}
+}
+
+// WithClock sets the clock Run waits on, clock.Real by default.
+func WithClock(c clock.Clock) Option {
+	return func(w *Watcher) {
+		w.clock = c
+	}
+}
+
+// WithEquityThreshold reports the equity once it moved by more than amount
+// since it was last reported, every change by default.
+func WithEquityThreshold(amount decimal.Decimal) Option {
+	return func(w *Watcher) {
+		w.equityThreshold = amount
+	}
+}
+
+// WithBuyingPowerThreshold reports the buying power once it fell by more
+// than amount since it was last reported, every drop by default.
+func WithBuyingPowerThreshold(amount decimal.Decimal) Option {
+	return func(w *Watcher) {
+		w.buyingPowerThreshold = amount
+	}
+}
+
+// OnEquityChange sets the callback called for the moves of the equity.
+func OnEquityChange(callback func(c EquityChange)) Option {
+	return func(w *Watcher) {
+		w.onEquity = callback
+	}
+}
+
+// OnBuyingPowerDrop sets the callback called for the drops of the buying
+// power.
+func OnBuyingPowerDrop(callback func(d BuyingPowerDrop)) Option {
+	return func(w *Watcher) {
+		w.onBuyingPower = callback
+	}
+}
+
+// OnFlagChange sets the callback called for the flags turned on or off.
+func OnFlagChange(callback func(c FlagChange)) Option {
+	return func(w *Watcher) {
+		w.onFlag = callback
+	}
+}
+
+// OnStatusChange sets the callback called for the changes of status.
+func OnStatusChange(callback func(c StatusChange)) Option {
+	return func(w *Watcher) {
+		w.onStatus = callback
+	}
+}
+
+// OnEvent sets the callback called for every change, after the callback of
+// its type.
+func OnEvent(callback func(e Event)) Option {
+	return func(w *Watcher) {
+		w.onEvent = callback
+	}
+}
+
+// OnError sets the callback called with the errors of the polls of Run.
+func OnError(callback func(err error)) Option {
+	return func(w *Watcher) {
+		w.onError = callback
+	}
+}
+
+// Watcher polls an account and reports its changes. The first poll only
+// sets the state the next ones are compared with.
+type Watcher struct {
+	client               alpaca.TradingClient
+	interval             time.Duration
+	clock                clock.Clock
+	equityThreshold      decimal.Decimal
+	buyingPowerThreshold decimal.Decimal
+
+	onEquity      func(c EquityChange)
+	onBuyingPower func(d BuyingPowerDrop)
+	onFlag        func(c FlagChange)
+	onStatus      func(c StatusChange)
+	onEvent       func(e Event)
+	onError       func(err error)
+
+	// mu guards the state of the polls
+	mu   sync.Mutex
+	last *alpaca.Account
+	// equity and buyingPower are the values the next ones are compared with
+	equity      decimal.Decimal
+	buyingPower decimal.Decimal
+}
+
+// New creates a watcher polling the account with client.
+func New(client alpaca.TradingClient, opts ...Option) *Watcher {
+	w := &Watcher{
+		client:   client,
+		interval: time.Minute,
+		clock:    clock.Real,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// Account returns the account of the last poll, nil before the first one.
+func (w *Watcher) Account() *alpaca.Account {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.last
+}
+
+// Run polls at the interval of the watcher until ctx is done. The errors of
+// the polls are passed to the OnError callback and retried at the next one.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := w.clock.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Poll(); err != nil && w.onError != nil {
+			w.onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
+
+// Poll fetches the account and calls the callbacks for its changes since
+// the previous poll. The callbacks are called outside of the lock of the
+// watcher, so they may call Account.
+func (w *Watcher) Poll() error {
+	account, err := w.client.GetAccount()
+	if err != nil {
+		return err
+	}
+	for _, event := range w.update(account) {
+		w.dispatch(event)
+	}
+	return nil
+}
+
+// update makes account the last one polled and returns its changes
+func (w *Watcher) update(account *alpaca.Account) []Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	prev := w.last
+	w.last = account
+	if prev == nil {
+		w.equity, w.buyingPower = account.Equity, account.BuyingPower
+		return nil
+	}
+
+	var events []Event
+	if account.Status != prev.Status {
+		events = append(events, StatusChange{Account: account, From: prev.Status, To: account.Status})
+	}
+	for _, f := range flags {
+		if value := f.value(account); value != f.value(prev) {
+			events = append(events, FlagChange{Account: account, Flag: f.flag, Value: value})
+		}
+	}
+	if change := account.Equity.Sub(w.equity); !change.IsZero() && change.Abs().GreaterThan(w.equityThreshold) {
+		events = append(events, EquityChange{Account: account, Previous: w.equity, Current: account.Equity})
+		w.equity = account.Equity
+	}
+	switch drop := w.buyingPower.Sub(account.BuyingPower); {
+	case drop.IsPositive() && drop.GreaterThan(w.buyingPowerThreshold):
+		events = append(events, BuyingPowerDrop{Account: account, Previous: w.buyingPower, Current: account.BuyingPower})
+		w.buyingPower = account.BuyingPower
+	case drop.IsNegative():
+		// the drops are measured from the highest buying power since
+		w.buyingPower = account.BuyingPower
+	}
+	return events
+}
+
+// dispatch calls the callbacks of an event
+func (w *Watcher) dispatch(event Event) {
+	switch e := event.(type) {
+	case EquityChange:
+		if w.onEquity != nil {
+			w.onEquity(e)
+		}
+	case BuyingPowerDrop:
+		if w.onBuyingPower != nil {
+			w.onBuyingPower(e)
+		}
+	case FlagChange:
+		if w.onFlag != nil {
+			w.onFlag(e)
+		}
+	case StatusChange:
+		if w.onStatus != nil {
+			w.onStatus(e)
+		}
+	}
+	if w.onEvent != nil {
+		w.onEvent(event)
+	}
+}
@@ -0,0 +1,108 @@
+package accountwatch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	account := alpaca.Account{Status: "ACTIVE", Equity: decimal.New(10000, 0), BuyingPower: decimal.New(20000, 0)}
+	mock := &alpacatest.MockClient{
+		GetAccountFunc: func() (*alpaca.Account, error) {
+			a := account
+			return &a, nil
+		},
+	}
+	var equity []EquityChange
+	var drops []BuyingPowerDrop
+	var events []Event
+	w := New(mock,
+		WithEquityThreshold(decimal.New(500, 0)),
+		WithBuyingPowerThreshold(decimal.New(1000, 0)),
+		OnEquityChange(func(c EquityChange) { equity = append(equity, c) }),
+		OnBuyingPowerDrop(func(d BuyingPowerDrop) { drops = append(drops, d) }),
+		OnEvent(func(e Event) { events = append(events, e) }),
+	)
+
+	// the first poll only sets the state
+	require.NoError(t, w.Poll())
+	assert.Empty(t, events)
+	assert.Equal(t, "ACTIVE", w.Account().Status)
+
+	// the moves under the threshold add up
+	account.Equity = decimal.New(9700, 0)
+	require.NoError(t, w.Poll())
+	assert.Empty(t, equity)
+	account.Equity = decimal.New(9400, 0)
+	require.NoError(t, w.Poll())
+	require.Len(t, equity, 1)
+	assert.Equal(t, "-600", equity[0].Change().String())
+
+	// the drops are measured from the highest buying power
+	account.BuyingPower = decimal.New(25000, 0)
+	require.NoError(t, w.Poll())
+	account.BuyingPower = decimal.New(23500, 0)
+	require.NoError(t, w.Poll())
+	require.Len(t, drops, 1)
+	assert.Equal(t, "25000", drops[0].Previous.String())
+
+	events = nil
+	account.TradingBlocked = true
+	account.Status = "ACCOUNT_UPDATED"
+	require.NoError(t, w.Poll())
+	require.Len(t, events, 2)
+	assert.Equal(t, StatusChange{Account: events[0].Snapshot(), From: "ACTIVE", To: "ACCOUNT_UPDATED"}, events[0])
+	assert.Equal(t, FlagChange{Account: events[1].Snapshot(), Flag: TradingBlocked, Value: true}, events[1])
+
+	// nothing changed
+	require.NoError(t, w.Poll())
+	assert.Len(t, events, 2)
+}
+
+func TestWatcherRun(t *testing.T) {
+	account := alpaca.Account{Status: "ACTIVE"}
+	polls := 0
+	mock := &alpacatest.MockClient{
+		GetAccountFunc: func() (*alpaca.Account, error) {
+			polls++
+			if polls == 2 {
+				return nil, errors.New("unavailable")
+			}
+			a := account
+			return &a, nil
+		},
+	}
+	fake := clock.NewFake(time.Date(2021, 10, 14, 13, 30, 0, 0, time.UTC))
+	errs := make(chan error, 1)
+	statuses := make(chan string, 1)
+	var w *Watcher
+	w = New(mock, WithClock(fake), WithInterval(time.Minute),
+		OnError(func(err error) { errs <- err }),
+		// the callbacks may query the watcher
+		OnStatusChange(func(c StatusChange) { statuses <- w.Account().Status }),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- w.Run(ctx) }()
+
+	// the error of the second poll is reported and retried at the third
+	fake.BlockUntil(1)
+	fake.Advance(time.Minute)
+	assert.EqualError(t, <-errs, "unavailable")
+	account.Status = "ACCOUNT_UPDATED"
+	fake.Advance(time.Minute)
+	assert.Equal(t, "ACCOUNT_UPDATED", <-statuses)
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
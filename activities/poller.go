@@ -0,0 +1,285 @@
+// Package activities polls the non-trade account activities, such as the
+// dividends, fees and transfers the trade updates stream does not report,
+// and calls typed callbacks for the new ones, e.g.
+//
+//	poller := activities.New(client,
+//		activities.OnDividend(func(d *alpaca.Dividend) {
+//			log.Printf("%s dividend of %s", d.Symbol, d.NetAmount)
+//		}),
+//		activities.WithCursor(lastCursor),
+//	)
+//	go poller.Run(ctx)
+//	...
+//	lastCursor = poller.Cursor()
+package activities
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+)
+
+// pageSize is the number of activities fetched per request
+const pageSize = 100
+
+var newYork = loadNewYork()
+
+func loadNewYork() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Option configures a Poller
+type Option func(p *Poller)
+
+// WithInterval sets how often Run polls, every minute by default.
+func WithInterval(interval time.Duration) Option {
+	return func(p *Poller) {
+		p.interval = interval
+	}
+}
+
+// WithTypes restricts the polled activities to types, e.g.
+// alpaca.ActivityDividend and alpaca.ActivityFee, all the types by default.
+func WithTypes(types ...string) Option {
+	return func(p *Poller) {
+		p.types = types
+	}
+}
+
+// WithCursor resumes polling after the activity of ID cursor, as returned
+// by Cursor. Without a cursor, the first poll only marks the activities of
+// the last day as seen.
+func WithCursor(cursor string) Option {
+	return func(p *Poller) {
+		p.cursor = cursor
+		p.primed = cursor != ""
+	}
+}
+
+// WithClock sets the clock Run waits on, clock.Real by default.
+func WithClock(c clock.Clock) Option {
+	return func(p *Poller) {
+		p.clock = c
+	}
+}
+
+// OnDividend sets the callback called for the new dividends.
+func OnDividend(callback func(d *alpaca.Dividend)) Option {
+	return func(p *Poller) {
+		p.onDividend = callback
+	}
+}
+
+// OnInterest sets the callback called for the new interests.
+func OnInterest(callback func(i *alpaca.Interest)) Option {
+	return func(p *Poller) {
+		p.onInterest = callback
+	}
+}
+
+// OnFee sets the callback called for the new fees.
+func OnFee(callback func(f *alpaca.Fee)) Option {
+	return func(p *Poller) {
+		p.onFee = callback
+	}
+}
+
+// OnTransfer sets the callback called for the new transfers.
+func OnTransfer(callback func(t *alpaca.Transfer)) Option {
+	return func(p *Poller) {
+		p.onTransfer = callback
+	}
+}
+
+// OnActivity sets the callback called for every new activity but the
+// fills, including the ones of the types without a callback of their own.
+func OnActivity(callback func(a alpaca.TypedActivity)) Option {
+	return func(p *Poller) {
+		p.onActivity = callback
+	}
+}
+
+// Poller polls the account activities. The activity IDs start with their
+// time, so the poller keeps the greatest ID seen as its cursor and only
+// calls the callbacks for the activities after it, once each and in order.
+// The fills are skipped, they are reported by the trade updates stream.
+type Poller struct {
+	client   alpaca.TradingClient
+	interval time.Duration
+	types    []string
+	clock    clock.Clock
+
+	onDividend func(d *alpaca.Dividend)
+	onInterest func(i *alpaca.Interest)
+	onFee      func(f *alpaca.Fee)
+	onTransfer func(t *alpaca.Transfer)
+	onActivity func(a alpaca.TypedActivity)
+
+	// mu serializes the polls
+	mu     sync.Mutex
+	cursor string
+	// primed is false until the activities already there were seen
+	primed bool
+	// since is when the activities are fetched from without a cursor
+	since time.Time
+}
+
+// New creates a poller fetching the activities with client.
+func New(client alpaca.TradingClient, opts ...Option) *Poller {
+	p := &Poller{
+		client:   client,
+		interval: time.Minute,
+		clock:    clock.Real,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Cursor returns the ID of the last activity seen, to resume polling with
+// WithCursor.
+func (p *Poller) Cursor() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cursor
+}
+
+// Run polls at the interval of the poller until ctx is done. The errors of
+// the polls are retried at the next one.
+func (p *Poller) Run(ctx context.Context) error {
+	ticker := p.clock.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.Poll()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
+
+// Poll fetches the activities after the cursor and calls the callbacks for
+// them.
+func (p *Poller) Poll() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.primed && p.since.IsZero() {
+		p.since = p.clock.Now().AddDate(0, 0, -1)
+	}
+	activities, err := p.fetch()
+	if err != nil {
+		return err
+	}
+	primed := p.primed
+	p.primed = true
+	for _, a := range activities {
+		p.cursor = a.ID
+		if primed && a.ActivityType != alpaca.ActivityFill {
+			p.dispatch(a.Typed())
+		}
+	}
+	return nil
+}
+
+// fetch returns the activities after the cursor sorted by ID, page by page
+func (p *Poller) fetch() ([]alpaca.AccountActivity, error) {
+	req := &alpaca.AccountActivitiesRequest{
+		Direction: stringPtr("asc"),
+		PageSize:  intPtr(pageSize),
+	}
+	if len(p.types) > 0 {
+		req.ActivityTypes = &p.types
+	}
+	if after, ok := cursorDate(p.cursor); ok {
+		req.After = &after
+	} else if !p.since.IsZero() {
+		req.After = &p.since
+	}
+
+	seen := map[string]bool{}
+	var activities []alpaca.AccountActivity
+	for {
+		page, err := p.client.GetAccountActivities(nil, req)
+		if err != nil {
+			return nil, err
+		}
+		added := 0
+		for _, a := range page {
+			if a.ID <= p.cursor || seen[a.ID] {
+				continue
+			}
+			seen[a.ID] = true
+			activities = append(activities, a)
+			added++
+			if t := a.Typed().Time(); req.After == nil || t.After(*req.After) {
+				req.After = &t
+			}
+		}
+		// the dates of the non-trade activities have a resolution of a day,
+		// so the activities of the last day of a page come again with the
+		// next one
+		if len(page) < pageSize || added == 0 {
+			break
+		}
+	}
+	sort.Slice(activities, func(i, j int) bool { return activities[i].ID < activities[j].ID })
+	return activities, nil
+}
+
+// dispatch calls the callbacks of an activity
+func (p *Poller) dispatch(activity alpaca.TypedActivity) {
+	switch a := activity.(type) {
+	case *alpaca.Dividend:
+		if p.onDividend != nil {
+			p.onDividend(a)
+		}
+	case *alpaca.Interest:
+		if p.onInterest != nil {
+			p.onInterest(a)
+		}
+	case *alpaca.Fee:
+		if p.onFee != nil {
+			p.onFee(a)
+		}
+	case *alpaca.Transfer:
+		if p.onTransfer != nil {
+			p.onTransfer(a)
+		}
+	}
+	if p.onActivity != nil {
+		p.onActivity(activity)
+	}
+}
+
+// cursorDate returns the day before the date an activity ID starts with,
+// e.g. 20211014 for 20211015093000000::1f2e..., so that the activities of
+// the same day are fetched whatever the time zone of the ID
+func cursorDate(cursor string) (time.Time, bool) {
+	if len(cursor) < 8 {
+		return time.Time{}, false
+	}
+	date, err := time.ParseInLocation("20060102", cursor[:8], newYork)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date.AddDate(0, 0, -1), true
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func intPtr(i int) *int {
+	return &i
+}
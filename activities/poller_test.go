@@ -0,0 +1,70 @@
+package activities
+
+import (
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoller(t *testing.T) {
+	remote := []alpaca.AccountActivity{
+		{ID: "20211014000000000::a1", ActivityType: alpaca.ActivityJournalCash, NetAmount: decimal.New(100, 0)},
+	}
+	var requests []alpaca.AccountActivitiesRequest
+	mock := &alpacatest.MockClient{
+		GetAccountActivitiesFunc: func(
+			activityType *string, opts *alpaca.AccountActivitiesRequest,
+		) ([]alpaca.AccountActivity, error) {
+			requests = append(requests, *opts)
+			return remote, nil
+		},
+	}
+
+	var dividends []*alpaca.Dividend
+	var fees []*alpaca.Fee
+	var all []string
+	p := New(mock,
+		OnDividend(func(d *alpaca.Dividend) { dividends = append(dividends, d) }),
+		OnFee(func(f *alpaca.Fee) { fees = append(fees, f) }),
+		OnActivity(func(a alpaca.TypedActivity) { all = append(all, a.ActivityID()) }),
+	)
+
+	// the activities already there are only marked as seen
+	require.NoError(t, p.Poll())
+	assert.Empty(t, all)
+	assert.Equal(t, "20211014000000000::a1", p.Cursor())
+	require.NotNil(t, requests[0].After)
+
+	remote = []alpaca.AccountActivity{
+		{ID: "20211015000000000::a3", ActivityType: alpaca.ActivityFee, NetAmount: decimal.New(-1, 0)},
+		remote[0],
+		{ID: "20211015000000000::a2", ActivityType: "DIVNRA", Symbol: "AAPL", NetAmount: decimal.New(-2, 0)},
+		{ID: "20211015000000000::a4", ActivityType: alpaca.ActivityFill, Symbol: "AAPL"},
+	}
+	require.NoError(t, p.Poll())
+	require.Len(t, dividends, 1)
+	assert.Equal(t, "AAPL", dividends[0].Symbol)
+	require.Len(t, fees, 1)
+	// in order and without the fill
+	assert.Equal(t, []string{"20211015000000000::a2", "20211015000000000::a3"}, all)
+	assert.Equal(t, "20211015000000000::a4", p.Cursor())
+	assert.Equal(t, "asc", *requests[1].Direction)
+	assert.Equal(t, "2021-10-13", requests[1].After.Format("2006-01-02"))
+
+	// polling again calls nothing
+	require.NoError(t, p.Poll())
+	assert.Len(t, all, 2)
+
+	// a poller resumed from the cursor calls nothing either
+	all = nil
+	resumed := New(mock, WithCursor(p.Cursor()),
+		OnActivity(func(a alpaca.TypedActivity) { all = append(all, a.ActivityID()) }))
+	require.NoError(t, resumed.Poll())
+	assert.Empty(t, all)
+	assert.True(t, requests[len(requests)-1].After.Equal(time.Date(2021, 10, 14, 0, 0, 0, 0, newYork)))
+}
@@ -0,0 +1,276 @@
+package alpaca
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Account activity types
+const (
+	ActivityFill = "FILL"
+	// ActivityDividend and the types starting with DIV are dividends, e.g.
+	// DIVNRA for the withholding of a non-resident alien
+	ActivityDividend = "DIV"
+	// ActivityInterest and the types starting with INT are interests
+	ActivityInterest = "INT"
+	// ActivityFee is for regulatory and other fees
+	ActivityFee = "FEE"
+	// ActivityPassThruCharge is for pass-through charges
+	ActivityPassThruCharge = "PTC"
+	// ActivityCryptoFee is for crypto trading fees
+	ActivityCryptoFee      = "CFEE"
+	ActivityCashDeposit    = "CSD"
+	ActivityCashWithdrawal = "CSW"
+	ActivityJournalCash    = "JNLC"
+	ActivityJournalStock   = "JNLS"
+)
+
+// TypedActivity is an account activity modeled by its type: a *Fill, a
+// *Dividend, an *Interest, a *Fee, a *Transfer or an *OtherActivity for the
+// types not modeled. Use a type switch on the value returned by
+// AccountActivity.Typed, e.g.
+//
+//	switch a := activity.Typed().(type) {
+//	case *alpaca.Fill:
+//		lots.Add(a.Symbol, a.SignedQty(), a.Price)
+//	case *alpaca.Dividend:
+//		income = income.Add(a.NetAmount)
+//	}
+type TypedActivity interface {
+	// ActivityID returns the ID of the activity
+	ActivityID() string
+	// Time returns the transaction time of a fill and the date of the other
+	// activities
+	Time() time.Time
+	// CashAmount returns the change of the cash balance of the account due to
+	// the activity
+	CashAmount() decimal.Decimal
+
+	typedActivity()
+}
+
+// Fill is the execution of an order, in full or in part
+type Fill struct {
+	ID              string
+	OrderID         string
+	TransactionTime time.Time
+	// Type is "fill" or "partial_fill"
+	Type   string
+	Symbol string
+	Side   Side
+	Qty    decimal.Decimal
+	Price  decimal.Decimal
+	// LeavesQty is the quantity of the order still to fill
+	LeavesQty decimal.Decimal
+	// CumQty is the quantity of the order filled so far
+	CumQty decimal.Decimal
+}
+
+// SignedQty returns the quantity of the fill, negative for a sale
+func (f *Fill) SignedQty() decimal.Decimal {
+	if f.Side == Buy {
+		return f.Qty
+	}
+	return f.Qty.Neg()
+}
+
+// IsPartial returns true if the order is not filled yet
+func (f *Fill) IsPartial() bool {
+	return f.Type == "partial_fill"
+}
+
+func (f *Fill) ActivityID() string { return f.ID }
+func (f *Fill) Time() time.Time    { return f.TransactionTime }
+
+// CashAmount returns the notional of the fill, negative for a purchase
+func (f *Fill) CashAmount() decimal.Decimal {
+	return f.SignedQty().Mul(f.Price).Neg()
+}
+
+func (f *Fill) typedActivity() {}
+
+// Dividend is a dividend paid on a position, or an adjustment of one
+type Dividend struct {
+	ID string
+	// ActivityType is DIV or another type starting with DIV, e.g. DIVNRA
+	ActivityType string
+	Date         time.Time
+	Symbol       string
+	// Qty is the number of shares the dividend was paid on
+	Qty            decimal.Decimal
+	PerShareAmount decimal.Decimal
+	NetAmount      decimal.Decimal
+	Description    string
+}
+
+func (d *Dividend) ActivityID() string          { return d.ID }
+func (d *Dividend) Time() time.Time             { return d.Date }
+func (d *Dividend) CashAmount() decimal.Decimal { return d.NetAmount }
+func (d *Dividend) typedActivity()              {}
+
+// Interest is an interest paid or charged, or an adjustment of one
+type Interest struct {
+	ID string
+	// ActivityType is INT or another type starting with INT
+	ActivityType string
+	Date         time.Time
+	NetAmount    decimal.Decimal
+	Description  string
+}
+
+func (i *Interest) ActivityID() string          { return i.ID }
+func (i *Interest) Time() time.Time             { return i.Date }
+func (i *Interest) CashAmount() decimal.Decimal { return i.NetAmount }
+func (i *Interest) typedActivity()              {}
+
+// Fee is a fee charged to the account: FEE, PTC or CFEE
+type Fee struct {
+	ID           string
+	ActivityType string
+	Date         time.Time
+	Symbol       string
+	// OrderID is the order the fee was charged for, if any
+	OrderID string
+	// NetAmount is the fee in USD, negative, and zero for a crypto fee
+	// charged in coins
+	NetAmount decimal.Decimal
+	// Qty and Price are the amount and USD price of the coins of a crypto
+	// fee
+	Qty         decimal.Decimal
+	Price       decimal.Decimal
+	Description string
+}
+
+// Amount returns the fee as a positive USD amount
+func (f *Fee) Amount() decimal.Decimal {
+	if f.ActivityType == ActivityCryptoFee && f.NetAmount.IsZero() {
+		// fees charged in coins have no USD net amount
+		return CryptoFee{Qty: f.Qty.Abs(), Price: f.Price}.Value()
+	}
+	return f.NetAmount.Abs()
+}
+
+func (f *Fee) ActivityID() string { return f.ID }
+func (f *Fee) Time() time.Time    { return f.Date }
+
+// CashAmount returns the USD net amount of the fee, zero for a crypto fee
+// charged in coins
+func (f *Fee) CashAmount() decimal.Decimal { return f.NetAmount }
+
+func (f *Fee) typedActivity() {}
+
+// Transfer is a movement of cash or securities in or out of the account:
+// CSD, CSW, JNLC or JNLS
+type Transfer struct {
+	ID           string
+	ActivityType string
+	Date         time.Time
+	// NetAmount is the cash transferred, negative out of the account
+	NetAmount decimal.Decimal
+	// Symbol and Qty are the securities of a JNLS journal
+	Symbol      string
+	Qty         decimal.Decimal
+	Status      string
+	Description string
+}
+
+// IsDeposit returns true if the transfer is into the account
+func (t *Transfer) IsDeposit() bool {
+	if t.ActivityType == ActivityJournalStock {
+		return t.Qty.IsPositive()
+	}
+	return t.NetAmount.IsPositive()
+}
+
+func (t *Transfer) ActivityID() string          { return t.ID }
+func (t *Transfer) Time() time.Time             { return t.Date }
+func (t *Transfer) CashAmount() decimal.Decimal { return t.NetAmount }
+func (t *Transfer) typedActivity()              {}
+
+// OtherActivity is an activity of a type not modeled by TypedActivity
+type OtherActivity struct {
+	AccountActivity
+}
+
+func (o *OtherActivity) ActivityID() string { return o.ID }
+func (o *OtherActivity) Time() time.Time    { return activityTime(o.AccountActivity) }
+
+// CashAmount returns the net amount of the activity
+func (o *OtherActivity) CashAmount() decimal.Decimal { return o.NetAmount }
+
+func (o *OtherActivity) typedActivity() {}
+
+// Typed returns the activity modeled by its type
+func (a AccountActivity) Typed() TypedActivity {
+	switch {
+	case a.ActivityType == ActivityFill:
+		return &Fill{
+			ID:              a.ID,
+			OrderID:         a.OrderID,
+			TransactionTime: a.TransactionTime,
+			Type:            a.Type,
+			Symbol:          a.Symbol,
+			Side:            Side(a.Side),
+			Qty:             a.Qty,
+			Price:           a.Price,
+			LeavesQty:       a.LeavesQty,
+			CumQty:          a.CumQty,
+		}
+	case strings.HasPrefix(a.ActivityType, ActivityDividend):
+		return &Dividend{
+			ID:             a.ID,
+			ActivityType:   a.ActivityType,
+			Date:           a.Date,
+			Symbol:         a.Symbol,
+			Qty:            a.Qty,
+			PerShareAmount: a.PerShareAmount,
+			NetAmount:      a.NetAmount,
+			Description:    a.Description,
+		}
+	case strings.HasPrefix(a.ActivityType, ActivityInterest):
+		return &Interest{
+			ID:           a.ID,
+			ActivityType: a.ActivityType,
+			Date:         a.Date,
+			NetAmount:    a.NetAmount,
+			Description:  a.Description,
+		}
+	case a.ActivityType == ActivityFee || a.ActivityType == ActivityPassThruCharge ||
+		a.ActivityType == ActivityCryptoFee:
+		return &Fee{
+			ID:           a.ID,
+			ActivityType: a.ActivityType,
+			Date:         a.Date,
+			Symbol:       a.Symbol,
+			OrderID:      a.OrderID,
+			NetAmount:    a.NetAmount,
+			Qty:          a.Qty,
+			Price:        a.Price,
+			Description:  a.Description,
+		}
+	case a.ActivityType == ActivityCashDeposit || a.ActivityType == ActivityCashWithdrawal ||
+		a.ActivityType == ActivityJournalCash || a.ActivityType == ActivityJournalStock:
+		return &Transfer{
+			ID:           a.ID,
+			ActivityType: a.ActivityType,
+			Date:         a.Date,
+			NetAmount:    a.NetAmount,
+			Symbol:       a.Symbol,
+			Qty:          a.Qty,
+			Status:       a.Status,
+			Description:  a.Description,
+		}
+	}
+	return &OtherActivity{AccountActivity: a}
+}
+
+// activityTime returns the transaction time of a fill, or the date of the
+// other activities
+func activityTime(a AccountActivity) time.Time {
+	if !a.TransactionTime.IsZero() {
+		return a.TransactionTime
+	}
+	return a.Date
+}
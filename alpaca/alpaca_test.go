@@ -2,15 +2,24 @@ package alpaca
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
 	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
@@ -471,6 +480,8 @@ func (s *AlpacaTestSuite) TestAlpaca() {
 		got, err := GetSnapshot("AAPL")
 		require.NoError(s.T(), err)
 		require.NotNil(s.T(), got)
+		assert.JSONEq(s.T(), snapshotJSON, string(got.Raw))
+		got.Raw = nil
 		assert.Equal(s.T(), expected, *got)
 
 		// api failure
@@ -1016,6 +1027,1671 @@ func (s *AlpacaTestSuite) TestAlpaca() {
 	}
 }
 
+func (s *AlpacaTestSuite) TestWithCredentials() {
+	var keys []string
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		keys = append(keys, c.credentials.ID)
+		return &http.Response{
+			Body: genBody(Account{ID: c.credentials.ID}),
+		}, nil
+	}
+
+	base := NewClient(&common.APIKey{ID: "base"})
+	first := base.WithCredentials(&common.APIKey{ID: "first"})
+	second := base.WithCredentials(&common.APIKey{ID: "second"})
+
+	acct, err := first.GetAccount()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "first", acct.ID)
+	_, err = second.GetAccount()
+	require.NoError(s.T(), err)
+	_, err = base.GetAccount()
+	require.NoError(s.T(), err)
+
+	assert.Equal(s.T(), []string{"first", "second", "base"}, keys)
+	assert.Same(s.T(), base.httpClient, first.httpClient)
+	assert.Same(s.T(), base.httpClient, second.httpClient)
+}
+
+func (s *AlpacaTestSuite) TestEnvironment() {
+	paper, err := NewClientForEnv(Paper, &common.APIKey{ID: "PKTEST", Secret: "secret"})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), Paper, paper.Environment())
+
+	live, err := NewClientForEnv(Live, &common.APIKey{ID: "AKTEST", Secret: "secret"})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), Live, live.Environment())
+
+	// mismatched keys
+	_, err = NewClientForEnv(Live, &common.APIKey{ID: "PKTEST", Secret: "secret"})
+	assert.Error(s.T(), err)
+	_, err = NewClientForEnv(Paper, &common.APIKey{ID: "AKTEST", Secret: "secret"})
+	assert.Error(s.T(), err)
+	_, err = NewClientForEnv("staging", &common.APIKey{ID: "PKTEST", Secret: "secret"})
+	assert.Error(s.T(), err)
+
+	// without credentials, the keys of the environment are checked
+	defer os.Setenv(common.EnvApiKeyID, os.Getenv(common.EnvApiKeyID))
+	os.Setenv(common.EnvApiKeyID, "PKENV")
+	fromEnv, err := NewClientForEnv(Paper, nil)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), Paper, fromEnv.Environment())
+	_, err = NewClientForEnv(Live, nil)
+	assert.Error(s.T(), err)
+
+	// requests go to the environment's URL
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		assert.Equal(s.T(), "paper-api.alpaca.markets", req.URL.Host)
+		return &http.Response{
+			Body: genBody(Account{}),
+		}, nil
+	}
+	_, err = paper.GetAccount()
+	assert.NoError(s.T(), err)
+
+	// the copies keep pointing at the same environment
+	assert.Equal(s.T(), Paper, paper.WithCredentials(&common.APIKey{ID: "PKOTHER"}).Environment())
+}
+
+func (s *AlpacaTestSuite) TestShortSellValidation() {
+	assetCalls := 0
+	placed := 0
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		switch {
+		case strings.HasSuffix(req.URL.Path, "/positions/HTB"):
+			return nil, &APIError{Code: positionNotFoundCode, Message: "position does not exist"}
+		case strings.HasSuffix(req.URL.Path, "/positions/ETB"):
+			return &http.Response{
+				Body: genBody(Position{Symbol: "ETB", Qty: decimal.New(10, 0)}),
+			}, nil
+		case strings.HasSuffix(req.URL.Path, "/assets/HTB"):
+			assetCalls++
+			return &http.Response{
+				Body: genBody(Asset{Symbol: "HTB", Shortable: true, EasyToBorrow: false}),
+			}, nil
+		case strings.HasSuffix(req.URL.Path, "/assets/ETB"):
+			assetCalls++
+			return &http.Response{
+				Body: genBody(Asset{Symbol: "ETB", Shortable: true, EasyToBorrow: true}),
+			}, nil
+		case strings.HasSuffix(req.URL.Path, "/orders"):
+			placed++
+			return &http.Response{
+				Body: genBody(Order{}),
+			}, nil
+		}
+		return nil, fmt.Errorf("unexpected request %s", req.URL)
+	}
+
+	c := NewClient(&common.APIKey{}, WithShortSellValidation())
+
+	shortable, err := c.IsShortable("HTB")
+	require.NoError(s.T(), err)
+	assert.False(s.T(), shortable)
+	shortable, err = c.IsShortable("ETB")
+	require.NoError(s.T(), err)
+	assert.True(s.T(), shortable)
+
+	// opening a short in a hard to borrow asset
+	htb := "HTB"
+	_, err = c.PlaceOrder(PlaceOrderRequest{AssetKey: &htb, Qty: decimal.New(1, 0), Side: Sell, Type: Market})
+	var notShortable *NotShortableError
+	require.True(s.T(), errors.As(err, &notShortable))
+	assert.Equal(s.T(), "HTB", notShortable.Symbol)
+	assert.Equal(s.T(), 0, placed)
+
+	// buying is not checked
+	_, err = c.PlaceOrder(PlaceOrderRequest{AssetKey: &htb, Qty: decimal.New(1, 0), Side: Buy, Type: Market})
+	assert.NoError(s.T(), err)
+
+	// selling a long position and going short in an easy to borrow asset are allowed
+	etb := "ETB"
+	_, err = c.PlaceOrder(PlaceOrderRequest{AssetKey: &etb, Qty: decimal.New(5, 0), Side: Sell, Type: Market})
+	assert.NoError(s.T(), err)
+	_, err = c.PlaceOrder(PlaceOrderRequest{AssetKey: &etb, Qty: decimal.New(20, 0), Side: Sell, Type: Market})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), 3, placed)
+
+	// the assets were only fetched once
+	assert.Equal(s.T(), 2, assetCalls)
+
+	// without the option orders are not validated
+	_, err = NewClient(&common.APIKey{}).PlaceOrder(PlaceOrderRequest{AssetKey: &htb, Qty: decimal.New(1, 0), Side: Sell})
+	assert.NoError(s.T(), err)
+}
+
+func (s *AlpacaTestSuite) TestRoundToTick() {
+	for _, tc := range []struct {
+		price, buy, sell string
+	}{
+		{"12.345", "12.34", "12.35"},
+		{"12.34", "12.34", "12.34"},
+		{"0.12345", "0.1234", "0.1235"},
+		{"0.99999", "0.9999", "1"},
+		{"1.001", "1", "1.01"},
+	} {
+		price := decimal.RequireFromString(tc.price)
+		assert.True(s.T(), RoundToTick(price, Buy).Equal(decimal.RequireFromString(tc.buy)), tc.price)
+		assert.True(s.T(), RoundToTick(price, Sell).Equal(decimal.RequireFromString(tc.sell)), tc.price)
+	}
+
+	assert.True(s.T(), IsValidTick(decimal.RequireFromString("12.34")))
+	assert.False(s.T(), IsValidTick(decimal.RequireFromString("12.345")))
+	assert.True(s.T(), IsValidTick(decimal.RequireFromString("0.1234")))
+
+	// auto rounding
+	var sent PlaceOrderRequest
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		require.NoError(s.T(), json.NewDecoder(req.Body).Decode(&sent))
+		return &http.Response{
+			Body: genBody(Order{}),
+		}, nil
+	}
+	limit := decimal.RequireFromString("100.005")
+	takeProfit := decimal.RequireFromString("110.001")
+	stopLoss := decimal.RequireFromString("95.009")
+	_, err := NewClient(&common.APIKey{}, WithPriceRounding()).PlaceOrder(PlaceOrderRequest{
+		Qty:        decimal.New(1, 0),
+		Side:       Buy,
+		Type:       Limit,
+		LimitPrice: &limit,
+		OrderClass: Bracket,
+		TakeProfit: &TakeProfit{LimitPrice: &takeProfit},
+		StopLoss:   &StopLoss{StopPrice: &stopLoss},
+	})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "100", sent.LimitPrice.String())
+	assert.Equal(s.T(), "110.01", sent.TakeProfit.LimitPrice.String())
+	assert.Equal(s.T(), "95.01", sent.StopLoss.StopPrice.String())
+	// the caller's prices are unchanged
+	assert.Equal(s.T(), "100.005", limit.String())
+}
+
+func (s *AlpacaTestSuite) TestOrderStatusAndTradeEvent() {
+	assert.True(s.T(), OrderFilled.IsTerminal())
+	assert.True(s.T(), OrderCanceled.IsTerminal())
+	assert.False(s.T(), OrderPartiallyFilled.IsTerminal())
+	assert.True(s.T(), OrderNew.IsOpen())
+	assert.False(s.T(), OrderDoneForDay.IsOpen())
+
+	assert.True(s.T(), EventFill.IsFill())
+	assert.True(s.T(), EventPartialFill.IsFill())
+	assert.False(s.T(), EventCanceled.IsFill())
+	assert.True(s.T(), EventCanceled.IsTerminal())
+	assert.False(s.T(), EventPendingNew.IsTerminal())
+
+	var update TradeUpdate
+	err := json.Unmarshal([]byte(`{"event":"partial_fill","order":{"status":"partially_filled"}}`), &update)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), EventPartialFill, update.Event)
+	assert.Equal(s.T(), OrderPartiallyFilled, update.Order.Status)
+}
+
+func (s *AlpacaTestSuite) TestStreamTradeUpdateEvents() {
+	eventsReconnectDelay = 0
+	defer func() {
+		eventsReconnectDelay = time.Second
+		doStream = defaultDoStream
+	}()
+
+	event := func(id, at, eventType string) string {
+		return fmt.Sprintf(`data: {"event_id":"%s","at":"%s","event":"%s","order":{"id":"o1"}}`+"\n\n", id, at, eventType)
+	}
+
+	calls := 0
+	doStream = func(c *Client, req *http.Request) (*http.Response, error) {
+		calls++
+		switch calls {
+		case 1:
+			assert.Equal(s.T(), "/v2/events/trades", req.URL.Path)
+			assert.Equal(s.T(), "", req.URL.Query().Get("since"))
+			return &http.Response{
+				Body: ioutil.NopCloser(strings.NewReader(
+					": heartbeat\n\n" +
+						event("1", "2021-03-04T15:00:00Z", "new") +
+						event("2", "2021-03-04T15:01:00Z", "partial_fill"),
+				)),
+			}, nil
+		case 2:
+			return nil, fmt.Errorf("connection reset")
+		default:
+			// resumed from the last event, which is sent again
+			assert.Equal(s.T(), "2021-03-04T15:01:00Z", req.URL.Query().Get("since"))
+			return &http.Response{
+				Body: ioutil.NopCloser(strings.NewReader(
+					event("2", "2021-03-04T15:01:00Z", "partial_fill") +
+						event("3", "2021-03-04T15:02:00Z", "fill"),
+				)),
+			}, nil
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var events []TradeUpdateEvent
+	err := StreamTradeUpdateEvents(ctx, StreamEventsRequest{}, func(event TradeUpdateEvent) {
+		events = append(events, event)
+		if len(events) == 3 {
+			cancel()
+		}
+	})
+	assert.True(s.T(), errors.Is(err, context.Canceled))
+	require.Len(s.T(), events, 3)
+	assert.Equal(s.T(), EventNew, events[0].Event)
+	assert.Equal(s.T(), EventPartialFill, events[1].Event)
+	assert.Equal(s.T(), EventFill, events[2].Event)
+	assert.Equal(s.T(), "o1", events[2].Order.ID)
+
+	// the stream ends when the until time is reached
+	doStream = func(c *Client, req *http.Request) (*http.Response, error) {
+		assert.Equal(s.T(), "/v2/events/nta", req.URL.Path)
+		assert.Equal(s.T(), "2021-03-05T00:00:00Z", req.URL.Query().Get("until"))
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(
+				`data: {"event_id":"1","at":"2021-03-04T15:00:00Z","entry_type":"DIV","net_amount":"1.5"}` + "\n\n",
+			)),
+		}, nil
+	}
+	until := time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC)
+	var activities []NonTradeActivityEvent
+	err = StreamNonTradeActivityEvents(context.Background(), StreamEventsRequest{Until: &until}, func(event NonTradeActivityEvent) {
+		activities = append(activities, event)
+	})
+	assert.NoError(s.T(), err)
+	require.Len(s.T(), activities, 1)
+	assert.Equal(s.T(), "DIV", activities[0].EntryType)
+	assert.True(s.T(), activities[0].NetAmount.Equal(decimal.NewFromFloat(1.5)))
+
+	// the errors of authentication and validation are not retried
+	calls = 0
+	doStream = func(c *Client, req *http.Request) (*http.Response, error) {
+		calls++
+		return nil, &APIError{Code: 40110000, Message: "request is not authorized", StatusCode: http.StatusUnauthorized}
+	}
+	err = StreamTradeUpdateEvents(context.Background(), StreamEventsRequest{}, func(event TradeUpdateEvent) {})
+	assert.EqualError(s.T(), err, "request is not authorized")
+	assert.Equal(s.T(), 1, calls)
+}
+
+func (s *AlpacaTestSuite) TestStreamEventsRetriesServerErrors() {
+	defer func() { doStream = defaultDoStream }()
+
+	// the server errors and rate limits are retried with a growing delay
+	fake := clock.NewFake(time.Date(2021, 3, 4, 15, 0, 0, 0, time.UTC))
+	c := NewClient(&common.APIKey{ID: "key", Secret: "secret"}, WithClock(fake))
+	failures := []error{
+		&APIError{Code: 50010000, Message: "internal server error", StatusCode: http.StatusInternalServerError},
+		&APIError{Code: 42910000, Message: "rate limit exceeded", StatusCode: http.StatusTooManyRequests},
+		&APIError{Code: 50310000, Message: "service unavailable", StatusCode: http.StatusServiceUnavailable},
+	}
+	var calls []time.Time
+	doStream = func(c *Client, req *http.Request) (*http.Response, error) {
+		calls = append(calls, fake.Now())
+		if len(calls) <= len(failures) {
+			return nil, failures[len(calls)-1]
+		}
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(
+				`data: {"event_id":"1","at":"2021-03-04T15:00:00Z","event":"fill","order":{"id":"o1"}}` + "\n\n",
+			)),
+		}, nil
+	}
+
+	until := time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC)
+	done := make(chan error)
+	var events []TradeUpdateEvent
+	go func() {
+		done <- c.StreamTradeUpdateEvents(context.Background(), StreamEventsRequest{Until: &until}, func(event TradeUpdateEvent) {
+			events = append(events, event)
+		})
+	}()
+	for _, delay := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		fake.BlockUntil(1)
+		fake.Advance(delay)
+	}
+	require.NoError(s.T(), <-done)
+	require.Len(s.T(), events, 1)
+	require.Len(s.T(), calls, 4)
+	assert.Equal(s.T(), 7*time.Second, calls[3].Sub(calls[0]))
+}
+
+func (s *AlpacaTestSuite) TestEventCheckpoint() {
+	defer func() { doStream = defaultDoStream }()
+
+	dir, err := ioutil.TempDir("", "checkpoint")
+	require.NoError(s.T(), err)
+	defer os.RemoveAll(dir)
+	checkpointer := NewFileCheckpointer(filepath.Join(dir, "trades.json"))
+
+	checkpoint, err := checkpointer.Load()
+	require.NoError(s.T(), err)
+	assert.Nil(s.T(), checkpoint)
+
+	var sinceIDs []string
+	doStream = func(c *Client, req *http.Request) (*http.Response, error) {
+		sinceIDs = append(sinceIDs, req.URL.Query().Get("since_id"))
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(
+				`data: {"event_id":"1","at":"2021-03-04T15:00:00Z","event":"new","order":{"id":"o1"}}` + "\n\n" +
+					`data: {"event_id":"2","at":"2021-03-04T15:01:00Z","event":"fill","order":{"id":"o1"}}` + "\n\n",
+			)),
+		}, nil
+	}
+	until := time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC)
+	req := StreamEventsRequest{Until: &until, Checkpointer: checkpointer}
+
+	var events []TradeUpdateEvent
+	handler := func(event TradeUpdateEvent) {
+		events = append(events, event)
+	}
+	require.NoError(s.T(), StreamTradeUpdateEvents(context.Background(), req, handler))
+	require.Len(s.T(), events, 2)
+
+	checkpoint, err = checkpointer.Load()
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), checkpoint)
+	assert.Equal(s.T(), "2", checkpoint.EventID)
+	assert.True(s.T(), checkpoint.At.Equal(time.Date(2021, 3, 4, 15, 1, 0, 0, time.UTC)))
+
+	// a restarted stream resumes after the checkpoint
+	require.NoError(s.T(), StreamTradeUpdateEvents(context.Background(), req, handler))
+	assert.Equal(s.T(), []string{"", "2"}, sinceIDs)
+
+	// an explicit start position takes precedence
+	since := time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)
+	req.Since = &since
+	require.NoError(s.T(), StreamTradeUpdateEvents(context.Background(), req, handler))
+	assert.Equal(s.T(), "", sinceIDs[2])
+}
+
+func (s *AlpacaTestSuite) TestCredentialsProvider() {
+	defer func(d func(c *Client, req *http.Request) (*http.Response, error)) { do = d }(do)
+	do = defaultDo
+
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("APCA-API-KEY-ID"))
+		fmt.Fprint(w, `{"id":"acct"}`)
+	}))
+	defer server.Close()
+
+	rotation := 0
+	c := NewClientWithOptions(
+		WithBaseURL(server.URL),
+		WithCredentialsProvider(common.CredentialsProviderFunc(func(ctx context.Context) (common.APIKey, error) {
+			rotation++
+			if rotation > 2 {
+				return common.APIKey{}, errors.New("vault unavailable")
+			}
+			return common.APIKey{ID: fmt.Sprintf("key%d", rotation), Secret: "secret"}, nil
+		})),
+	)
+	for i := 0; i < 2; i++ {
+		_, err := c.GetAccount()
+		require.NoError(s.T(), err)
+	}
+	assert.Equal(s.T(), []string{"key1", "key2"}, keys)
+
+	_, err := c.GetAccount()
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "vault unavailable")
+
+	// a copy with fixed credentials does not use the provider
+	_, err = c.WithCredentials(&common.APIKey{ID: "fixed"}).GetAccount()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "fixed", keys[2])
+}
+
+func (s *AlpacaTestSuite) TestCredentialsFallback() {
+	defer func(d func(c *Client, req *http.Request) (*http.Response, error)) { do = d }(do)
+	do = defaultDo
+
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("APCA-API-KEY-ID"))
+		fmt.Fprint(w, `{"id":"acct"}`)
+	}))
+	defer server.Close()
+
+	defer os.Setenv(common.EnvApiKeyID, os.Getenv(common.EnvApiKeyID))
+	c := NewClientWithOptions(WithBaseURL(server.URL))
+	explicit := NewClientWithOptions(WithBaseURL(server.URL), WithCredentials(&common.APIKey{ID: "explicit"}))
+
+	// clients without credentials read the environment for every request
+	for _, id := range []string{"env1", "env2"} {
+		os.Setenv(common.EnvApiKeyID, id)
+		_, err := c.GetAccount()
+		require.NoError(s.T(), err)
+		_, err = explicit.GetAccount()
+		require.NoError(s.T(), err)
+	}
+	assert.Equal(s.T(), []string{"env1", "explicit", "env2", "explicit"}, keys)
+}
+
+// fakeTracer records the calls reported to a RequestTracer.
+type fakeTracer struct {
+	paths    []string
+	statuses []int
+	retries  []int
+}
+
+func (t *fakeTracer) StartRequest(req *http.Request) (*http.Request, func(statusCode, retries int, err error)) {
+	t.paths = append(t.paths, req.URL.Path)
+	req.Header.Set("traceparent", "00-trace-span-01")
+	return req, func(statusCode, retries int, err error) {
+		t.statuses = append(t.statuses, statusCode)
+		t.retries = append(t.retries, retries)
+	}
+}
+
+func (s *AlpacaTestSuite) TestRequestTracer() {
+	defer func(d func(c *Client, req *http.Request) (*http.Response, error)) { do = d }(do)
+	do = defaultDo
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(s.T(), "00-trace-span-01", r.Header.Get("traceparent"))
+		if calls == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"id":"acct"}`)
+	}))
+	defer server.Close()
+
+	tracer := &fakeTracer{}
+	c := NewClientWithOptions(
+		WithBaseURL(server.URL),
+		WithCredentials(&common.APIKey{ID: "key", Secret: "secret"}),
+		WithRequestTracer(tracer),
+	)
+	_, err := c.GetAccount()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"/v2/account"}, tracer.paths)
+	assert.Equal(s.T(), []int{http.StatusOK}, tracer.statuses)
+	assert.Equal(s.T(), []int{1}, tracer.retries)
+
+	// the calls of a client with a cancelled context fail
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = c.WithContext(ctx).GetAccount()
+	require.Error(s.T(), err)
+	assert.True(s.T(), errors.Is(err, context.Canceled))
+	assert.Equal(s.T(), []int{http.StatusOK, 0}, tracer.statuses)
+}
+
+func (s *AlpacaTestSuite) TestSentinelErrors() {
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		return nil, verify(&http.Response{
+			StatusCode: http.StatusForbidden,
+			Body:       genBody(APIError{Code: 40310000, Message: "insufficient buying power"}),
+		})
+	}
+	_, err := PlaceOrder(PlaceOrderRequest{})
+	assert.True(s.T(), errors.Is(err, ErrInsufficientBuyingPower))
+	assert.False(s.T(), errors.Is(err, ErrWashTradeBlocked))
+
+	for _, tc := range []struct {
+		apiErr *APIError
+		target error
+	}{
+		{&APIError{Code: 40310000, Message: "potential wash trade detected. use complex orders"}, ErrWashTradeBlocked},
+		{&APIError{Code: 42210000, Message: "order is not cancelable"}, ErrOrderNotCancelable},
+		{&APIError{Code: 42210000, Message: "asset \"XYZ\" is not tradable"}, ErrAssetNotTradable},
+		{&APIError{Code: 40410000, Message: "position does not exist"}, ErrPositionNotFound},
+	} {
+		err := fmt.Errorf("wrapped: %w", tc.apiErr)
+		assert.True(s.T(), errors.Is(err, tc.target), tc.apiErr.Message)
+		assert.False(s.T(), errors.Is(err, ErrInsufficientBuyingPower), tc.apiErr.Message)
+	}
+}
+
+func (s *AlpacaTestSuite) TestDebugLogging() {
+	mockDo := do
+	do = defaultDo
+	defer func() { do = mockDo }()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"code":40310000,"message":"insufficient buying power"}`))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger := common.NewStdLogger(log.New(&buf, "", 0), common.LevelDebug)
+	c := NewClient(&common.APIKey{ID: "key", Secret: "secret"}, WithDebugLogging(logger), WithDebugBodies())
+	c.base = srv.URL
+
+	_, err := c.PlaceOrder(PlaceOrderRequest{Side: Buy})
+	assert.True(s.T(), errors.Is(err, ErrInsufficientBuyingPower))
+
+	logged := buf.String()
+	assert.Contains(s.T(), logged, `msg="request sent" client=rest method=POST url=`+srv.URL+"/v2/orders")
+	assert.Contains(s.T(), logged, `\"side\":\"buy\"`)
+	assert.Contains(s.T(), logged, `msg="response received" client=rest method=POST url=`+srv.URL+"/v2/orders")
+	assert.Contains(s.T(), logged, "status=403")
+	assert.Contains(s.T(), logged, "insufficient buying power")
+	assert.Contains(s.T(), logged, "Apca-Api-Secret-Key: REDACTED")
+	assert.NotContains(s.T(), logged, "Apca-Api-Secret-Key: secret")
+
+	// without bodies only the summary is logged
+	buf.Reset()
+	c = NewClient(&common.APIKey{ID: "key", Secret: "secret"}, WithDebugLogging(logger))
+	c.base = srv.URL
+	_, err = c.GetAccount()
+	assert.Error(s.T(), err)
+	assert.Regexp(s.T(), `^level=DEBUG msg="response received" client=rest method=GET url=`+srv.URL+
+		`/v2/account request_id=[0-9a-f]{32} status=403 latency=\S+\n$`, buf.String())
+
+	// the request is not modified, its body is logged from a copy
+	buf.Reset()
+	var sent io.ReadCloser
+	transport := &debugTransport{
+		next: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			sent = req.Body
+			return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+		}),
+		logger: logger,
+		bodies: true,
+	}
+	req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"qty":"1"}`))
+	require.NoError(s.T(), err)
+	body := req.Body
+	_, err = transport.RoundTrip(req)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), body, req.Body)
+	assert.Equal(s.T(), body, sent)
+	b, err := ioutil.ReadAll(sent)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), `{"qty":"1"}`, string(b))
+	assert.Contains(s.T(), buf.String(), `body="{\"qty\":\"1\"}"`)
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func (s *AlpacaTestSuite) TestNewClientWithOptions() {
+	defer SetBaseUrl(base)
+	defer os.Setenv("APCA_API_BASE_URL", os.Getenv("APCA_API_BASE_URL"))
+	os.Setenv("APCA_API_BASE_URL", "https://api.alpaca.markets")
+
+	paper := NewClientWithOptions(
+		WithBaseURL("https://paper-api.alpaca.markets"),
+		WithDataURL("https://data.example.com"),
+		WithCredentials(&common.APIKey{ID: "PKTEST", Secret: "paper"}),
+	)
+	live := NewClientWithOptions(WithCredentials(&common.APIKey{ID: "AKTEST", Secret: "live"}))
+	// changing the global default afterwards has no effect on either client
+	SetBaseUrl("https://example.com")
+
+	var hosts, keys []string
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		c.setAuthHeaders(req)
+		hosts = append(hosts, req.URL.Host)
+		keys = append(keys, req.Header.Get("APCA-API-KEY-ID"))
+		return &http.Response{
+			Body: genBody(v2.Trade{}),
+		}, nil
+	}
+	_, err := paper.GetAccount()
+	require.NoError(s.T(), err)
+	_, err = live.GetAccount()
+	require.NoError(s.T(), err)
+	_, err = paper.GetLatestTrade("AAPL")
+	require.NoError(s.T(), err)
+
+	assert.Equal(s.T(), []string{"paper-api.alpaca.markets", "api.alpaca.markets", "data.example.com"}, hosts)
+	assert.Equal(s.T(), []string{"PKTEST", "AKTEST", "PKTEST"}, keys)
+}
+
+func (s *AlpacaTestSuite) TestAccountDecimalFields() {
+	var acct Account
+	err := json.Unmarshal([]byte(`{
+		"multiplier": "4",
+		"buying_power": "262113.632",
+		"non_marginable_buying_power": "65528.40",
+		"sma": "0",
+		"accrued_fees": "0.12",
+		"pending_transfer_in": null
+	}`), &acct)
+	require.NoError(s.T(), err)
+	assert.True(s.T(), acct.Multiplier.Equal(decimal.New(4, 0)))
+	assert.Equal(s.T(), "262113.632", acct.BuyingPower.String())
+	assert.Equal(s.T(), "65528.4", acct.NonMarginableBuyingPower.String())
+	assert.True(s.T(), acct.SMA.IsZero())
+	assert.Equal(s.T(), "0.12", acct.AccruedFees.String())
+	assert.True(s.T(), acct.PendingTransferIn.IsZero())
+
+	var position Position
+	err = json.Unmarshal([]byte(`{"qty": "10", "qty_available": "7"}`), &position)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "7", position.QtyAvailable.String())
+}
+
+func (s *AlpacaTestSuite) TestPositionPL() {
+	var position Position
+	err := json.Unmarshal([]byte(`{
+		"qty": "-10",
+		"current_price": "101.5",
+		"lastday_price": "100",
+		"change_today": "0.015",
+		"unrealized_pl": "-20",
+		"unrealized_plpc": "-0.02",
+		"unrealized_intraday_pl": "-15",
+		"unrealized_intraday_plpc": "-0.015"
+	}`), &position)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "-15", position.UnrealizedIntradayPL.String())
+	assert.Equal(s.T(), "-0.015", position.UnrealizedIntradayPLPC.String())
+	assert.Equal(s.T(), "0.015", position.ChangeToday.String())
+
+	// the short position loses when the price rises
+	assert.Equal(s.T(), "-15", position.MarketValueChangeToday().String())
+	assert.Equal(s.T(), "-5", position.MarketValueDelta(decimal.RequireFromString("102")).String())
+	assert.Equal(s.T(), "15", position.MarketValueDelta(decimal.RequireFromString("100")).String())
+}
+
+func (s *AlpacaTestSuite) TestMarginHelpers() {
+	margin := Account{
+		Multiplier:            decimal.New(4, 0),
+		ShortingEnabled:       true,
+		PatternDayTrader:      true,
+		BuyingPower:           decimal.New(10000, 0),
+		DaytradingBuyingPower: decimal.New(8000, 0),
+	}
+	cash := Account{
+		Multiplier:  decimal.New(1, 0),
+		BuyingPower: decimal.New(10000, 0),
+	}
+
+	assert.Equal(s.T(), "10000", margin.EffectiveBuyingPower(Buy, Limit).String())
+	assert.Equal(s.T(), "9700", margin.EffectiveBuyingPower(Buy, Market).String())
+	assert.Equal(s.T(), "10000", margin.EffectiveBuyingPower(Sell, Limit).String())
+	assert.True(s.T(), cash.EffectiveBuyingPower(Sell, Limit).IsZero())
+
+	assert.NoError(s.T(), margin.CheckDayTradingBuyingPower(decimal.New(8000, 0)))
+	assert.True(s.T(), errors.Is(margin.CheckDayTradingBuyingPower(decimal.New(8001, 0)), ErrInsufficientBuyingPower))
+	assert.NoError(s.T(), cash.CheckDayTradingBuyingPower(decimal.New(20000, 0)))
+
+	req := PlaceOrderRequest{Qty: decimal.New(10, 0), Side: Buy, Type: Limit}
+	price := decimal.New(150, 0)
+	assert.Equal(s.T(), "750", margin.EstimateInitialMargin(req, price, true).String())
+	assert.Equal(s.T(), "1500", margin.EstimateInitialMargin(req, price, false).String())
+	assert.Equal(s.T(), "1500", cash.EstimateInitialMargin(req, price, true).String())
+
+	req = PlaceOrderRequest{Notional: decimal.New(500, 0), Side: Buy, Type: Market}
+	assert.Equal(s.T(), "250", margin.EstimateInitialMargin(req, price, true).String())
+}
+
+func (s *AlpacaTestSuite) TestWouldTriggerPDT() {
+	acct := Account{DaytradeCount: 3, Equity: decimal.New(10000, 0)}
+	assert.Equal(s.T(), 0, acct.DayTradesRemaining())
+	assert.Equal(s.T(), 2, (&Account{DaytradeCount: 1}).DayTradesRemaining())
+	assert.Equal(s.T(), -1, (&Account{PatternDayTrader: true}).DayTradesRemaining())
+	assert.Equal(s.T(), -1, (&Account{Equity: decimal.New(30000, 0)}).DayTradesRemaining())
+
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		switch req.URL.Path {
+		case "/v2/account":
+			return &http.Response{Body: genBody(acct)}, nil
+		case "/v2/account/activities/FILL":
+			assert.Equal(s.T(), time.Now().In(marketLocation).Format("2006-01-02"), req.URL.Query().Get("date"))
+			return &http.Response{Body: genBody([]AccountActivity{
+				{Symbol: "AAPL", Side: "buy"},
+				{Symbol: "TSLA", Side: "sell_short"},
+			})}, nil
+		}
+		return nil, fmt.Errorf("unexpected request %s", req.URL)
+	}
+
+	for _, tc := range []struct {
+		symbol   string
+		side     Side
+		expected bool
+	}{
+		{"AAPL", Sell, true},
+		{"AAPL", Buy, false},
+		{"TSLA", Buy, true},
+		{"TSLA", Sell, false},
+		{"MSFT", Sell, false},
+	} {
+		symbol := tc.symbol
+		triggers, err := WouldTriggerPDT(PlaceOrderRequest{AssetKey: &symbol, Side: tc.side})
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), tc.expected, triggers, "%s %s", tc.side, tc.symbol)
+	}
+
+	// accounts with day trades left never trigger it
+	acct.DaytradeCount = 2
+	symbol := "AAPL"
+	triggers, err := WouldTriggerPDT(PlaceOrderRequest{AssetKey: &symbol, Side: Sell})
+	require.NoError(s.T(), err)
+	assert.False(s.T(), triggers)
+}
+
+func (s *AlpacaTestSuite) TestCryptoWallets() {
+	// list wallets
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		assert.Equal(s.T(), "/v2/wallets", req.URL.Path)
+		assert.Equal(s.T(), "BTC", req.URL.Query().Get("asset"))
+		return &http.Response{
+			Body: genBody([]CryptoWallet{{Asset: "BTC", Address: "some_address"}}),
+		}, nil
+	}
+	asset := "BTC"
+	wallets, err := ListCryptoWallets(&asset)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), wallets, 1)
+	assert.Equal(s.T(), "some_address", wallets[0].Address)
+
+	// whitelist an address
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		assert.Equal(s.T(), http.MethodPost, req.Method)
+		assert.Equal(s.T(), "/v2/wallets/whitelists", req.URL.Path)
+		var body CreateWhitelistedAddressRequest
+		require.NoError(s.T(), json.NewDecoder(req.Body).Decode(&body))
+		return &http.Response{
+			Body: genBody(WhitelistedAddress{ID: "w1", Address: body.Address, Status: "PENDING"}),
+		}, nil
+	}
+	address, err := CreateWhitelistedAddress(CreateWhitelistedAddressRequest{Address: "0xabc", Asset: "ETH"})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "0xabc", address.Address)
+
+	// withdraw
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		assert.Equal(s.T(), "/v2/wallets/transfers", req.URL.Path)
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(
+				`{"id":"t1","direction":"OUTGOING","status":"PROCESSING","amount":"0.5","asset":"ETH"}`)),
+		}, nil
+	}
+	transfer, err := CreateCryptoTransfer(CreateCryptoTransferRequest{
+		Amount: decimal.New(5, -1), Address: "0xabc", Asset: "ETH",
+	})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), OutgoingTransfer, transfer.Direction)
+	assert.Equal(s.T(), TransferProcessing, transfer.Status)
+	assert.Equal(s.T(), "0.5", transfer.Amount.String())
+
+	// transfer status
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		assert.Equal(s.T(), "/v2/wallets/transfers/t1", req.URL.Path)
+		return &http.Response{
+			Body: genBody(CryptoTransfer{ID: "t1", Status: TransferComplete}),
+		}, nil
+	}
+	transfer, err = GetCryptoTransfer("t1")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), TransferComplete, transfer.Status)
+
+	// api failure
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		return &http.Response{}, fmt.Errorf("fail")
+	}
+	_, err = ListCryptoTransfers()
+	assert.Error(s.T(), err)
+}
+
+func (s *AlpacaTestSuite) TestWaitForCryptoTransfer() {
+	statuses := []CryptoTransferStatus{TransferProcessing, TransferProcessing, TransferComplete}
+	polls := 0
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodPost {
+			return &http.Response{Body: genBody(CryptoTransfer{ID: "t1", Status: TransferProcessing})}, nil
+		}
+		assert.Equal(s.T(), "/v2/wallets/transfers/t1", req.URL.Path)
+		status := statuses[polls]
+		polls++
+		return &http.Response{Body: genBody(CryptoTransfer{ID: "t1", Status: status})}, nil
+	}
+
+	fake := clock.NewFake(time.Now())
+	c := NewClient(common.Credentials(), WithClock(fake))
+	go func() {
+		for i := 0; i < 2; i++ {
+			fake.BlockUntil(1)
+			fake.Advance(time.Second)
+		}
+	}()
+	transfer, err := c.CreateCryptoTransferAndWait(context.Background(), CreateCryptoTransferRequest{
+		Amount: decimal.New(5, -1), Address: "0xabc", Asset: "ETH",
+	}, time.Second)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), TransferComplete, transfer.Status)
+	assert.Equal(s.T(), 3, polls)
+
+	// failed
+	statuses, polls = []CryptoTransferStatus{TransferFailed}, 0
+	transfer, err = c.WaitForCryptoTransfer(context.Background(), "t1", 0)
+	assert.True(s.T(), errors.Is(err, ErrTransferFailed))
+	require.NotNil(s.T(), transfer)
+	assert.Equal(s.T(), TransferFailed, transfer.Status)
+
+	// timed out
+	statuses, polls = []CryptoTransferStatus{TransferProcessing}, 0
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		fake.BlockUntil(1)
+		cancel()
+	}()
+	transfer, err = c.WaitForCryptoTransfer(ctx, "t1", time.Second)
+	assert.True(s.T(), errors.Is(err, context.Canceled))
+	require.NotNil(s.T(), transfer)
+	assert.Equal(s.T(), TransferProcessing, transfer.Status)
+}
+
+func (s *AlpacaTestSuite) TestOptions() {
+	// contracts are listed across pages
+	calls := 0
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		calls++
+		q := req.URL.Query()
+		assert.Equal(s.T(), "/v2/options/contracts", req.URL.Path)
+		assert.Equal(s.T(), "AAPL,SPY", q.Get("underlying_symbols"))
+		assert.Equal(s.T(), "call", q.Get("type"))
+		assert.Equal(s.T(), "150", q.Get("strike_price_gte"))
+		if calls == 1 {
+			assert.Equal(s.T(), "", q.Get("page_token"))
+			return &http.Response{
+				Body: ioutil.NopCloser(strings.NewReader(
+					`{"option_contracts":[{"symbol":"AAPL240119C00150000","strike_price":"150","type":"call"}],"next_page_token":"next"}`)),
+			}, nil
+		}
+		assert.Equal(s.T(), "next", q.Get("page_token"))
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(
+				`{"option_contracts":[{"symbol":"SPY240119C00450000","strike_price":"450","type":"call"}],"next_page_token":null}`)),
+		}, nil
+	}
+	strike := decimal.New(150, 0)
+	contracts, err := GetOptionContracts(GetOptionContractsRequest{
+		UnderlyingSymbols: []string{"AAPL", "SPY"},
+		Type:              Call,
+		StrikePriceGTE:    &strike,
+	})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), contracts, 2)
+	assert.Equal(s.T(), "AAPL240119C00150000", contracts[0].Symbol)
+	assert.Equal(s.T(), "450", contracts[1].StrikePrice.String())
+
+	// single contract
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		assert.Equal(s.T(), "/v2/options/contracts/AAPL240119C00150000", req.URL.Path)
+		return &http.Response{
+			Body: genBody(OptionContract{Symbol: "AAPL240119C00150000", Style: American}),
+		}, nil
+	}
+	contract, err := GetOptionContract("AAPL240119C00150000")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), American, contract.Style)
+
+	// option positions
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			Body: genBody([]Position{
+				{Symbol: "AAPL", Class: USEquity},
+				{Symbol: "AAPL240119C00150000", Class: USOption},
+			}),
+		}, nil
+	}
+	positions, err := ListPositions()
+	require.NoError(s.T(), err)
+	assert.False(s.T(), positions[0].IsOption())
+	assert.True(s.T(), positions[1].IsOption())
+
+	// exercise
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		assert.Equal(s.T(), http.MethodPost, req.Method)
+		assert.Equal(s.T(), "/v2/positions/AAPL240119C00150000/exercise", req.URL.Path)
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}
+	assert.NoError(s.T(), ExerciseOptionPosition("AAPL240119C00150000"))
+}
+
+func (s *AlpacaTestSuite) TestMultiLegOrder() {
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		var body map[string]interface{}
+		require.NoError(s.T(), json.NewDecoder(req.Body).Decode(&body))
+		assert.Equal(s.T(), "mleg", body["order_class"])
+		assert.NotContains(s.T(), body, "side")
+		assert.Equal(s.T(), []interface{}{
+			map[string]interface{}{"symbol": "AAPL240119C00150000", "side": "buy", "ratio_qty": "1"},
+			map[string]interface{}{"symbol": "AAPL240119C00160000", "side": "sell", "ratio_qty": "1"},
+		}, body["legs"])
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(`{
+				"id": "parent",
+				"order_class": "mleg",
+				"status": "filled",
+				"legs": [
+					{"symbol": "AAPL240119C00150000", "side": "buy", "ratio_qty": "1", "filled_qty": "2", "filled_avg_price": "5.1"},
+					{"symbol": "AAPL240119C00160000", "side": "sell", "ratio_qty": "1", "filled_qty": "2", "filled_avg_price": "2.3"}
+				]
+			}`)),
+		}, nil
+	}
+
+	limit := decimal.RequireFromString("2.8")
+	order, err := PlaceOrder(PlaceOrderRequest{
+		Qty:         decimal.New(2, 0),
+		Type:        Limit,
+		LimitPrice:  &limit,
+		TimeInForce: Day,
+		OrderClass:  Mleg,
+		Legs: []OrderLeg{
+			{Symbol: "AAPL240119C00150000", Side: Buy, RatioQty: decimal.New(1, 0)},
+			{Symbol: "AAPL240119C00160000", Side: Sell, RatioQty: decimal.New(1, 0)},
+		},
+	})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), Mleg, order.OrderClass)
+	require.NotNil(s.T(), order.Legs)
+	legs := *order.Legs
+	require.Len(s.T(), legs, 2)
+	assert.Equal(s.T(), "1", legs[0].RatioQty.String())
+	assert.Equal(s.T(), "2", legs[1].FilledQty.String())
+	assert.Equal(s.T(), "2.3", legs[1].FilledAvgPrice.String())
+}
+
+func (s *AlpacaTestSuite) TestCryptoFees() {
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		assert.Equal(s.T(), "/v2/account/activities/CFEE", req.URL.Path)
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(`[
+				{"id": "a1", "activity_type": "CFEE", "date": "2021-06-01", "symbol": "BTCUSD", "qty": "-2.5", "price": "0", "description": "Coin Pair Transaction Fee (USD)"},
+				{"id": "a2", "activity_type": "CFEE", "date": "2021-06-02", "symbol": "ETHBTC", "qty": "-0.0001", "price": "40000", "description": "Coin Pair Transaction Fee (Non USD)"}
+			]`)),
+		}, nil
+	}
+
+	fees, err := ListCryptoFees(nil)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), fees, 2)
+	assert.Equal(s.T(), time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), fees[0].Date)
+	assert.Equal(s.T(), "2.5", fees[0].Value().String())
+	assert.Equal(s.T(), "0.0001", fees[1].Qty.String())
+	assert.Equal(s.T(), "4", fees[1].Value().String())
+	assert.Equal(s.T(), "6.5", TotalCryptoFees(fees).String())
+}
+
+func (s *AlpacaTestSuite) TestFillDeltas() {
+	d := NewFillDeltas()
+	avg := decimal.New(100, 0)
+	order := Order{ID: "o1", Side: Sell, Status: OrderPartiallyFilled, FilledQty: decimal.New(4, 0), FilledAvgPrice: &avg}
+	qty, price, ok := d.Order(order)
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), "-4", qty.String())
+	assert.Equal(s.T(), "100", price.String())
+	_, _, ok = d.Order(order)
+	assert.False(s.T(), ok)
+
+	// the filled order is dropped, its late reports ignored
+	avg = decimal.New(106, 0)
+	order.Status = OrderFilled
+	order.FilledQty = decimal.New(10, 0)
+	qty, price, ok = d.Order(order)
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), "-6", qty.String())
+	assert.Equal(s.T(), "110", price.String())
+	assert.Empty(s.T(), d.filled)
+	_, ok = d.Fill(&Fill{OrderID: "o1", Type: "fill", CumQty: decimal.New(10, 0)})
+	assert.False(s.T(), ok)
+
+	// short sales are negative too
+	qty, ok = d.Fill(&Fill{OrderID: "o2", Type: "fill", Side: "sell_short", CumQty: decimal.New(10, 0)})
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), "-10", qty.String())
+
+	for i := 0; i <= maxDoneOrders; i++ {
+		d.Forget(strconv.Itoa(i))
+	}
+	assert.Len(s.T(), d.done, maxDoneOrders)
+	assert.False(s.T(), d.done["o1"])
+}
+
+func (s *AlpacaTestSuite) TestApplyFill() {
+	dec := decimal.RequireFromString
+	for _, test := range []struct {
+		name                           string
+		qty, avg, fillQty, price       string
+		wantQty, wantAvg, wantRealized string
+	}{
+		{"opens", "0", "0", "-10", "50", "-10", "50", "0"},
+		{"adds", "-10", "50", "-10", "60", "-20", "55", "0"},
+		{"reduces", "20", "100", "-5", "110", "15", "100", "50"},
+		{"closes", "-20", "55", "20", "45", "0", "0", "200"},
+		{"reverses", "-20", "55", "30", "45", "10", "45", "200"},
+	} {
+		qty, avg, realized := ApplyFill(dec(test.qty), dec(test.avg), dec(test.fillQty), dec(test.price))
+		assert.Equal(s.T(), test.wantQty, qty.String(), test.name)
+		assert.Equal(s.T(), test.wantAvg, avg.String(), test.name)
+		assert.Equal(s.T(), test.wantRealized, realized.String(), test.name)
+	}
+}
+
+func (s *AlpacaTestSuite) TestCryptoFeeTier() {
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		assert.Equal(s.T(), "/v2/account/crypto/fee_tier", req.URL.Path)
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(
+				`{"tier": 2, "volume_30d": "150000", "maker_fee_bps": "12", "taker_fee_bps": "22"}`)),
+		}, nil
+	}
+
+	tier, err := GetCryptoFeeTier()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 2, tier.Tier)
+	assert.Equal(s.T(), "150000", tier.Volume30D.String())
+	assert.Equal(s.T(), "12", tier.MakerFeeBps.String())
+	assert.Equal(s.T(), "22", tier.TakerFeeBps.String())
+
+	var update TradeUpdate
+	require.NoError(s.T(), json.Unmarshal([]byte(`{
+		"event": "fill", "fee": "0.25", "fee_currency": "USD",
+		"order": {"id": "o1", "symbol": "BTCUSD", "filled_fee": "0.25", "fee_currency": "USD"}
+	}`), &update))
+	assert.Equal(s.T(), "0.25", update.Fee.String())
+	assert.Equal(s.T(), "USD", update.FeeCurrency)
+	assert.Equal(s.T(), "0.25", update.Order.FilledFee.String())
+	assert.Equal(s.T(), "USD", update.Order.FeeCurrency)
+}
+
+func (s *AlpacaTestSuite) TestListAnnouncements() {
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		assert.Equal(s.T(), "/v2/corporate_actions/announcements", req.URL.Path)
+		assert.Equal(s.T(), "split,dividend", req.URL.Query().Get("ca_types"))
+		assert.Equal(s.T(), "2020-08-01", req.URL.Query().Get("since"))
+		assert.Equal(s.T(), "2020-09-01", req.URL.Query().Get("until"))
+		assert.Equal(s.T(), "AAPL", req.URL.Query().Get("symbol"))
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(`[
+				{"id": "c1", "ca_type": "split", "ca_sub_type": "forward_split", "initiating_symbol": "AAPL", "target_symbol": "AAPL",
+				 "ex_date": "2020-08-31", "old_rate": "1", "new_rate": "4"},
+				{"id": "c2", "ca_type": "dividend", "ca_sub_type": "cash", "initiating_symbol": "AAPL",
+				 "ex_date": "2020-08-07", "cash": "0.82"}
+			]`)),
+		}, nil
+	}
+
+	announcements, err := ListAnnouncements(ListAnnouncementsRequest{
+		CATypes: []string{CATypeSplit, CATypeDividend},
+		Since:   time.Date(2020, 8, 1, 0, 0, 0, 0, time.UTC),
+		Until:   time.Date(2020, 9, 1, 0, 0, 0, 0, time.UTC),
+		Symbol:  "AAPL",
+	})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), announcements, 2)
+	ratio, ok := announcements[0].SplitRatio()
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), "4", ratio.String())
+	assert.True(s.T(), announcements[1].Concerns("AAPL"))
+	assert.Equal(s.T(), "0.82", announcements[1].Cash.String())
+	_, ok = announcements[1].SplitRatio()
+	assert.False(s.T(), ok)
+
+	_, err = ListAnnouncements(ListAnnouncementsRequest{})
+	assert.Error(s.T(), err)
+}
+
+func (s *AlpacaTestSuite) TestGetMarkPrice() {
+	var quote string
+	trades := 0
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		body := `{"symbol":"AAPL","trade":{"p":150.3}}`
+		switch req.URL.Path {
+		case "/v2/stocks/AAPL/quotes/latest":
+			body = `{"symbol":"AAPL","quote":` + quote + `}`
+		case "/v2/stocks/AAPL/trades/latest":
+			trades++
+		default:
+			s.T().Fatalf("unexpected path %s", req.URL.Path)
+		}
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+
+	quote = `{"bp":150.1,"ap":150.2}`
+	mark, err := GetMarkPrice("AAPL")
+	require.NoError(s.T(), err)
+	assert.InDelta(s.T(), 150.15, mark.Price, 1e-9)
+	assert.Equal(s.T(), mark.Mid, mark.Price)
+	assert.Empty(s.T(), mark.Fallback)
+	assert.Nil(s.T(), mark.Trade)
+	assert.Equal(s.T(), 0, trades)
+
+	quote = `{"bp":150.4,"ap":150.2}`
+	mark, err = GetMarkPrice("AAPL")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 150.3, mark.Price)
+	assert.Equal(s.T(), MarkFallbackCrossedBook, mark.Fallback)
+	require.NotNil(s.T(), mark.Trade)
+	assert.Equal(s.T(), 1, trades)
+
+	quote = `{"bp":0,"ap":150.2}`
+	mark, err = GetMarkPrice("AAPL")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 150.3, mark.Price)
+	assert.Equal(s.T(), MarkFallbackEmptyBook, mark.Fallback)
+	assert.Zero(s.T(), mark.Mid)
+}
+
+func (s *AlpacaTestSuite) TestGetPositionBars() {
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		var body string
+		switch req.URL.Path {
+		case "/v2/positions":
+			body = `[
+				{"symbol":"AAPL","asset_class":"us_equity","qty":"10"},
+				{"symbol":"MSFT","asset_class":"us_equity","qty":"5"},
+				{"symbol":"BTCUSD","asset_class":"crypto","qty":"1"}
+			]`
+		case "/v2/stocks/bars":
+			assert.Equal(s.T(), "AAPL,MSFT", req.URL.Query().Get("symbols"))
+			assert.Equal(s.T(), "1Day", req.URL.Query().Get("timeframe"))
+			assert.Equal(s.T(), "split", req.URL.Query().Get("adjustment"))
+			if req.URL.Query().Get("page_token") == "" {
+				body = `{"bars":{"AAPL":[{"t":"2021-10-14T04:00:00Z","c":143.76}]},"next_page_token":"p2"}`
+			} else {
+				body = `{"bars":{"AAPL":[{"t":"2021-10-15T04:00:00Z","c":144.84}],
+					"MSFT":[{"t":"2021-10-15T04:00:00Z","c":304.21}]},"next_page_token":null}`
+			}
+		case "/v2/stocks/snapshots":
+			body = `{"AAPL":{"latestTrade":{"p":144.9}},"MSFT":{"latestTrade":{"p":304.3}}}`
+		default:
+			s.T().Fatalf("unexpected path %s", req.URL.Path)
+		}
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+
+	positions, err := GetPositionBars(v2.Day, 48*time.Hour)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), positions, 2)
+	require.Len(s.T(), positions["AAPL"].Bars, 2)
+	assert.Equal(s.T(), 144.84, positions["AAPL"].Bars[1].Close)
+	assert.Equal(s.T(), "10", positions["AAPL"].Position.Qty.String())
+	require.Len(s.T(), positions["MSFT"].Bars, 1)
+	require.NotNil(s.T(), positions["MSFT"].Snapshot)
+	assert.Equal(s.T(), 304.3, positions["MSFT"].Snapshot.LatestTrade.Price)
+}
+
+func (s *AlpacaTestSuite) TestOrderLimits() {
+	var placed int
+	position := `{"symbol":"AAPL","qty":"40","side":"long"}`
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		body := `{}`
+		switch {
+		case req.URL.Path == "/v2/stocks/AAPL/trades/latest":
+			body = `{"symbol":"AAPL","trade":{"p":100}}`
+		case req.URL.Path == "/v2/stocks/SPY/trades/latest":
+			body = `{"symbol":"SPY","trade":{"p":400}}`
+		case req.URL.Path == "/v2/positions/SPY":
+			return nil, &APIError{Code: positionNotFoundCode, Message: "position does not exist"}
+		case req.URL.Path == "/v2/positions/AAPL":
+			if position == "" {
+				return nil, &APIError{Code: positionNotFoundCode, Message: "position does not exist"}
+			}
+			body = position
+		case req.URL.Path == "/v2/orders/o1" && req.Method == http.MethodGet:
+			body = `{"id":"o1","symbol":"AAPL","side":"buy","type":"limit","qty":"10","filled_qty":"0","limit_price":"100"}`
+		case req.URL.Path == "/v2/orders" || req.URL.Path == "/v2/orders/o1":
+			placed++
+		default:
+			s.T().Fatalf("unexpected path %s", req.URL.Path)
+		}
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+	aapl, msft := "AAPL", "MSFT"
+	buy := func(qty int64) PlaceOrderRequest {
+		return PlaceOrderRequest{AssetKey: &aapl, Qty: decimal.New(qty, 0), Side: Buy, Type: Market, TimeInForce: Day}
+	}
+	c := NewClient(common.Credentials(),
+		WithMaxOrderNotional(decimal.New(2000, 0)),
+		WithMaxPositionQty(decimal.New(50, 0)),
+		WithAllowedSymbols("AAPL", "SPY"),
+	)
+
+	_, err := c.PlaceOrder(buy(10))
+	require.NoError(s.T(), err)
+	// valued at the latest trade
+	_, err = c.PlaceOrder(buy(21))
+	assert.True(s.T(), errors.Is(err, ErrOrderLimit))
+	assert.Equal(s.T(), "order rejected by the client limits: buy AAPL worth 2100.00, over 2000", err.Error())
+	_, err = c.PlaceOrder(buy(11))
+	assert.True(s.T(), errors.Is(err, ErrOrderLimit))
+	// reducing the position is allowed
+	sell := buy(20)
+	sell.Side = Sell
+	_, err = c.PlaceOrder(sell)
+	require.NoError(s.T(), err)
+	position = ""
+	sell.Notional, sell.Qty = decimal.New(1000, 0), decimal.Zero
+	_, err = c.PlaceOrder(sell)
+	require.NoError(s.T(), err)
+
+	other := buy(1)
+	other.AssetKey = &msft
+	_, err = c.PlaceOrder(other)
+	var limitErr *OrderLimitError
+	require.True(s.T(), errors.As(err, &limitErr))
+	assert.Equal(s.T(), "MSFT is not an allowed symbol", limitErr.Reason)
+	_, err = c.PlaceOrder(PlaceOrderRequest{OrderClass: Mleg, Legs: []OrderLeg{{Symbol: "AAPL"}, {Symbol: "MSFT"}}})
+	assert.True(s.T(), errors.Is(err, ErrOrderLimit))
+
+	// the legs of multi-leg orders are valued and sized each
+	mleg := PlaceOrderRequest{OrderClass: Mleg, Qty: decimal.New(2, 0), Type: Market, TimeInForce: Day, Legs: []OrderLeg{
+		{Symbol: "AAPL", Side: Buy, RatioQty: decimal.New(1, 0)},
+		{Symbol: "SPY", Side: Sell, RatioQty: decimal.New(2, 0)},
+	}}
+	_, err = c.PlaceOrder(mleg)
+	require.NoError(s.T(), err)
+	mleg.Qty = decimal.New(3, 0)
+	_, err = c.PlaceOrder(mleg)
+	require.True(s.T(), errors.As(err, &limitErr))
+	assert.Equal(s.T(), "sell SPY worth 2400.00, over 2000", limitErr.Reason)
+
+	// the raw order endpoints would bypass the limits
+	_, err = c.DoRaw(http.MethodPost, "v2/orders", mleg)
+	assert.True(s.T(), errors.Is(err, ErrOrderLimit))
+	_, err = c.DoRaw(http.MethodPatch, "/v2/orders/o1", ReplaceOrderRequest{Qty: &mleg.Qty})
+	assert.True(s.T(), errors.Is(err, ErrOrderLimit))
+
+	// the limits hold for the copies of the client and the replacements
+	_, err = c.WithCredentials(&common.APIKey{}).PlaceOrder(other)
+	assert.True(s.T(), errors.Is(err, ErrOrderLimit))
+	qty := decimal.New(30, 0)
+	_, err = c.ReplaceOrder("o1", ReplaceOrderRequest{Qty: &qty})
+	assert.True(s.T(), errors.Is(err, ErrOrderLimit))
+	qty = decimal.New(15, 0)
+	_, err = c.ReplaceOrder("o1", ReplaceOrderRequest{Qty: &qty})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 5, placed)
+	_, err = c.DoRaw(http.MethodDelete, "v2/orders/o1", nil)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 6, placed)
+}
+
+func (s *AlpacaTestSuite) TestJournal() {
+	var requestIDs []string
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		id, _ := RequestIDFromContext(c.ctx)
+		requestIDs = append(requestIDs, id)
+		if req.Method == http.MethodDelete && strings.HasPrefix(req.URL.Path, "/v2/orders/") {
+			return nil, &APIError{Code: unprocessableEntityCode, Message: "order is not cancelable"}
+		}
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(`{"id":"o1","symbol":"AAPL","status":"new"}`)),
+		}, nil
+	}
+	var buf bytes.Buffer
+	journal := NewJournal(&buf)
+	c := NewClient(common.Credentials(), WithJournal(journal))
+
+	aapl := "AAPL"
+	_, err := c.PlaceOrder(PlaceOrderRequest{AssetKey: &aapl, Qty: decimal.New(1, 0), Side: Buy, Type: Market})
+	require.NoError(s.T(), err)
+	assert.True(s.T(), errors.Is(c.CancelOrder("o1"), ErrOrderNotCancelable))
+	// a request ID set by the caller is kept
+	_, err = c.WithContext(ContextWithRequestID(context.Background(), "req-1")).GetOrder("o1")
+	require.NoError(s.T(), err)
+	journal.HandleTradeUpdate(TradeUpdate{Event: EventFill, Order: Order{ID: "o1"}})
+	// the orders rejected by the client and the closed positions are journaled
+	limited := NewClient(common.Credentials(), WithJournal(journal), WithAllowedSymbols("MSFT"))
+	_, err = limited.PlaceOrder(PlaceOrderRequest{AssetKey: &aapl, Qty: decimal.New(1, 0), Side: Buy, Type: Market})
+	assert.True(s.T(), errors.Is(err, ErrOrderLimit))
+	require.NoError(s.T(), c.ClosePosition("AAPL"))
+	require.NoError(s.T(), journal.Err())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(s.T(), lines, 5)
+	var entries []map[string]interface{}
+	for _, line := range lines {
+		var entry map[string]interface{}
+		require.NoError(s.T(), json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	assert.Equal(s.T(), JournalPlace, entries[0]["action"])
+	assert.Equal(s.T(), requestIDs[0], entries[0]["request_id"])
+	assert.NotEmpty(s.T(), requestIDs[0])
+	assert.Equal(s.T(), "AAPL", entries[0]["request"].(map[string]interface{})["symbol"])
+	assert.Equal(s.T(), "o1", entries[0]["response"].(map[string]interface{})["id"])
+	assert.NotContains(s.T(), entries[0], "error")
+	assert.Equal(s.T(), JournalCancel, entries[1]["action"])
+	assert.Equal(s.T(), "o1", entries[1]["order_id"])
+	assert.Equal(s.T(), "order is not cancelable", entries[1]["error"])
+	assert.NotContains(s.T(), entries[1], "response")
+	assert.Equal(s.T(), JournalTradeUpdate, entries[2]["action"])
+	assert.Equal(s.T(), "fill", entries[2]["response"].(map[string]interface{})["event"])
+	assert.Equal(s.T(), "req-1", requestIDs[2])
+	assert.Equal(s.T(), JournalPlace, entries[3]["action"])
+	assert.Equal(s.T(), "order rejected by the client limits: AAPL is not an allowed symbol", entries[3]["error"])
+	assert.Equal(s.T(), JournalClose, entries[4]["action"])
+	assert.Equal(s.T(), "AAPL", entries[4]["symbol"])
+	assert.Equal(s.T(), requestIDs[3], entries[4]["request_id"])
+}
+
+func (s *AlpacaTestSuite) TestValidateOrder() {
+	aapl, btc := "AAPL", "BTC/USD"
+	price := func(p string) *decimal.Decimal {
+		d := decimal.RequireFromString(p)
+		return &d
+	}
+	valid := PlaceOrderRequest{
+		AssetKey:    &aapl,
+		Qty:         decimal.New(10, 0),
+		Side:        Buy,
+		Type:        Limit,
+		TimeInForce: Day,
+		LimitPrice:  price("150.25"),
+	}
+	assert.NoError(s.T(), ValidateOrder(valid))
+
+	for _, tc := range []struct {
+		name   string
+		modify func(req *PlaceOrderRequest)
+	}{
+		{"no symbol", func(req *PlaceOrderRequest) { req.AssetKey = nil }},
+		{"no side", func(req *PlaceOrderRequest) { req.Side = "" }},
+		{"qty and notional", func(req *PlaceOrderRequest) { req.Notional = decimal.New(100, 0) }},
+		{"no qty", func(req *PlaceOrderRequest) { req.Qty = decimal.Zero }},
+		{"notional limit", func(req *PlaceOrderRequest) { req.Qty, req.Notional = decimal.Zero, decimal.New(100, 0) }},
+		{"fractional gtc", func(req *PlaceOrderRequest) { req.Qty, req.TimeInForce = decimal.New(5, -1), GTC }},
+		{"no limit price", func(req *PlaceOrderRequest) { req.LimitPrice = nil }},
+		{"sub-penny", func(req *PlaceOrderRequest) { req.LimitPrice = price("150.255") }},
+		{"opg stop", func(req *PlaceOrderRequest) { req.Type, req.StopPrice, req.TimeInForce = Stop, price("1"), OPG }},
+		{"extended hours market", func(req *PlaceOrderRequest) { req.Type, req.ExtendedHours = Market, true }},
+		{"extended hours gtc", func(req *PlaceOrderRequest) { req.TimeInForce, req.ExtendedHours = GTC, true }},
+		{"crypto day", func(req *PlaceOrderRequest) { req.AssetKey = &btc }},
+		{"bracket without stop loss", func(req *PlaceOrderRequest) {
+			req.OrderClass, req.TakeProfit = Bracket, &TakeProfit{LimitPrice: price("160")}
+		}},
+		{"bracket take profit below entry", func(req *PlaceOrderRequest) {
+			req.OrderClass, req.TakeProfit, req.StopLoss = Bracket, NewTakeProfit(*price("140")), NewStopLoss(*price("145"))
+		}},
+		{"bracket stop loss above entry", func(req *PlaceOrderRequest) {
+			req.OrderClass, req.TakeProfit, req.StopLoss = Bracket, NewTakeProfit(*price("160")), NewStopLoss(*price("155"))
+		}},
+		{"bracket stop limit above stop", func(req *PlaceOrderRequest) {
+			req.OrderClass, req.TakeProfit = Bracket, NewTakeProfit(*price("160"))
+			req.StopLoss = NewStopLimitLoss(*price("145"), *price("146"))
+		}},
+		{"oto with both legs", func(req *PlaceOrderRequest) {
+			req.OrderClass, req.TakeProfit, req.StopLoss = Oto, NewTakeProfit(*price("160")), NewStopLoss(*price("145"))
+		}},
+		{"oco inverted legs", func(req *PlaceOrderRequest) {
+			req.Side, req.OrderClass = Sell, Oco
+			req.TakeProfit, req.StopLoss = NewTakeProfit(*price("145")), NewStopLoss(*price("160"))
+		}},
+		{"mleg with one leg", func(req *PlaceOrderRequest) {
+			req.OrderClass, req.Legs = Mleg, []OrderLeg{{Symbol: "AAPL240119C00150000", Side: Buy, RatioQty: decimal.New(1, 0)}}
+		}},
+	} {
+		req := valid
+		tc.modify(&req)
+		err := ValidateOrder(req)
+		var validationErr *OrderValidationError
+		assert.True(s.T(), errors.As(err, &validationErr), tc.name)
+	}
+
+	// the legs close the position on the other side of the entry
+	bracket := valid
+	bracket.OrderClass = Bracket
+	bracket.TakeProfit = NewTakeProfit(*price("160"))
+	bracket.StopLoss = NewStopLimitLoss(*price("145"), *price("144.5"))
+	assert.NoError(s.T(), ValidateOrder(bracket))
+	bracket.Side = Sell
+	err := ValidateOrder(bracket)
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), "invalid order: take profit limit price 160 must be below the entry price 150.25 of a sell order", err.Error())
+	bracket.TakeProfit, bracket.StopLoss = NewTakeProfit(*price("140")), NewStopLoss(*price("155"))
+	assert.NoError(s.T(), ValidateOrder(bracket))
+	oco := valid
+	oco.Side, oco.OrderClass = Sell, Oco
+	oco.TakeProfit, oco.StopLoss = NewTakeProfit(*price("160")), NewStopLoss(*price("145"))
+	assert.NoError(s.T(), ValidateOrder(oco))
+
+	// crypto orders allow sub-penny prices and fractional gtc orders
+	crypto := valid
+	crypto.AssetKey = &btc
+	crypto.TimeInForce = GTC
+	crypto.Qty = decimal.New(5, -3)
+	crypto.LimitPrice = price("40000.123")
+	assert.NoError(s.T(), ValidateOrder(crypto))
+	assert.True(s.T(), isCryptoSymbol("BTCUSD"))
+	assert.False(s.T(), isCryptoSymbol("AAPL"))
+
+	// the time in force matrix
+	option := valid
+	contract := "AAPL240119C00150000"
+	option.AssetKey = &contract
+	assert.NoError(s.T(), ValidateOrder(option))
+	option.TimeInForce = GTC
+	err = ValidateOrder(option)
+	require.Error(s.T(), err)
+	assert.Equal(s.T(), "invalid order: gtc time in force is not allowed for us_option limit orders, only day", err.Error())
+	assert.Equal(s.T(), []TimeInForce{Day, GTC, OPG, CLS, IOC, FOK}, AllowedTimeInForces(USEquity, Limit, false))
+	assert.Equal(s.T(), []TimeInForce{Day, GTC}, AllowedTimeInForces(USEquity, TrailingStop, false))
+	assert.Equal(s.T(), []TimeInForce{Day}, AllowedTimeInForces(USEquity, Limit, true))
+	assert.Empty(s.T(), AllowedTimeInForces(Crypto, Stop, false))
+	assert.True(s.T(), isOptionSymbol("SPY240119P00450000"))
+	assert.False(s.T(), isOptionSymbol("BRK.B"))
+
+	// dry runs never reach the API
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		s.T().Fatal("dry run sent a request")
+		return nil, nil
+	}
+	c := NewClient(common.Credentials(), WithDryRun())
+	order, err := c.PlaceOrder(valid)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "AAPL", order.Symbol)
+	assert.Equal(s.T(), "", order.ID)
+	assert.Equal(s.T(), "150.25", order.LimitPrice.String())
+
+	invalid := valid
+	invalid.LimitPrice = nil
+	_, err = c.PlaceOrder(invalid)
+	assert.Error(s.T(), err)
+}
+
+func (s *AlpacaTestSuite) TestRawJSON() {
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(`{"id":"some_id","new_field":"new_value"}`)),
+		}, nil
+	}
+	acct, err := GetAccount()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "some_id", acct.ID)
+	var extra struct {
+		NewField string `json:"new_field"`
+	}
+	require.NoError(s.T(), json.Unmarshal(acct.Raw, &extra))
+	assert.Equal(s.T(), "new_value", extra.NewField)
+
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(`[{"id":"o1","legs":[{"id":"o2","new_field":1}]}]`)),
+		}, nil
+	}
+	orders, err := ListOrders(nil, nil, nil, nil)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), orders, 1)
+	assert.Contains(s.T(), string((*orders[0].Legs)[0].Raw), `"new_field":1`)
+
+	// unwrapped endpoints
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		assert.Equal(s.T(), http.MethodPatch, req.Method)
+		assert.Equal(s.T(), DefaultClient.base+"/v2/some/endpoint", req.URL.String())
+		body, _ := ioutil.ReadAll(req.Body)
+		assert.JSONEq(s.T(), `{"key":"value"}`, string(body))
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(`{"ok":true}`)),
+		}, nil
+	}
+	raw, err := DoRaw(http.MethodPatch, "/v2/some/endpoint", map[string]string{"key": "value"})
+	require.NoError(s.T(), err)
+	assert.JSONEq(s.T(), `{"ok":true}`, string(raw))
+}
+
+func (s *AlpacaTestSuite) TestNestedOrder() {
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		assert.Equal(s.T(), "/v2/orders/parent", req.URL.Path)
+		assert.Equal(s.T(), "true", req.URL.Query().Get("nested"))
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(`{
+				"id": "parent",
+				"order_class": "bracket",
+				"order_type": "limit",
+				"status": "filled",
+				"legs": [
+					{"id": "tp", "order_type": "limit", "limit_price": "110", "status": "new"},
+					{"id": "sl", "order_type": "stop", "stop_price": "95", "status": "held"}
+				]
+			}`)),
+		}, nil
+	}
+
+	order, err := GetNestedOrder("parent")
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), order.TakeProfitLeg())
+	assert.Equal(s.T(), "tp", order.TakeProfitLeg().ID)
+	assert.Equal(s.T(), "110", order.TakeProfitLeg().LimitPrice.String())
+	require.NotNil(s.T(), order.StopLossLeg())
+	assert.Equal(s.T(), "sl", order.StopLossLeg().ID)
+
+	assert.Nil(s.T(), (&Order{}).TakeProfitLeg())
+}
+
+func (s *AlpacaTestSuite) TestOrderFees() {
+	var order Order
+	err := json.Unmarshal([]byte(`{"id":"o1","commission":"1.25","commission_type":"notional"}`), &order)
+	require.NoError(s.T(), err)
+	require.NotNil(s.T(), order.Commission)
+	assert.Equal(s.T(), "1.25", order.Commission.String())
+	assert.Equal(s.T(), NotionalCommission, order.CommissionType)
+
+	fees := FeesByOrder([]AccountActivity{
+		{ActivityType: "FILL", OrderID: "o1", Qty: decimal.New(10, 0)},
+		{ActivityType: "FEE", OrderID: "o1", NetAmount: decimal.RequireFromString("-0.02")},
+		{ActivityType: "PTC", OrderID: "o1", NetAmount: decimal.RequireFromString("-0.01")},
+		{ActivityType: "CFEE", OrderID: "o2", Qty: decimal.RequireFromString("-0.0001"), Price: decimal.New(40000, 0)},
+		{ActivityType: "FEE", NetAmount: decimal.New(-1, 0)},
+	})
+	assert.Len(s.T(), fees, 3)
+	assert.Equal(s.T(), "0.03", fees["o1"].String())
+	assert.Equal(s.T(), "4", fees["o2"].String())
+	assert.Equal(s.T(), "1", fees[""].String())
+}
+
+func (s *AlpacaTestSuite) TestTypedActivities() {
+	var activities []AccountActivity
+	require.NoError(s.T(), json.Unmarshal([]byte(`[
+		{"id":"a1","activity_type":"FILL","transaction_time":"2021-10-14T14:00:00Z","type":"partial_fill",
+		 "symbol":"AAPL","side":"sell","qty":"5","price":"143.5","leaves_qty":"5","cum_qty":"5","order_id":"o1"},
+		{"id":"a2","activity_type":"DIVNRA","date":"2021-10-15","symbol":"AAPL","qty":"10",
+		 "per_share_amount":"0.22","net_amount":"-0.66"},
+		{"id":"a3","activity_type":"INT","date":"2021-10-15","net_amount":"0.12"},
+		{"id":"a4","activity_type":"CFEE","date":"2021-10-15","symbol":"BTCUSD","qty":"-0.0001","price":"40000"},
+		{"id":"a5","activity_type":"CSW","date":"2021-10-15","net_amount":"-1000","status":"executed"},
+		{"id":"a6","activity_type":"MA","date":"2021-10-15","net_amount":"0"}
+	]`), &activities))
+
+	fill, ok := activities[0].Typed().(*Fill)
+	require.True(s.T(), ok)
+	assert.True(s.T(), fill.IsPartial())
+	assert.Equal(s.T(), "-5", fill.SignedQty().String())
+	assert.Equal(s.T(), "717.5", fill.CashAmount().String())
+	assert.Equal(s.T(), time.Date(2021, 10, 14, 14, 0, 0, 0, time.UTC), fill.Time())
+
+	dividend, ok := activities[1].Typed().(*Dividend)
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), "0.22", dividend.PerShareAmount.String())
+	assert.Equal(s.T(), "-0.66", dividend.CashAmount().String())
+
+	_, ok = activities[2].Typed().(*Interest)
+	assert.True(s.T(), ok)
+
+	fee, ok := activities[3].Typed().(*Fee)
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), "4", fee.Amount().String())
+	assert.True(s.T(), fee.CashAmount().IsZero())
+
+	transfer, ok := activities[4].Typed().(*Transfer)
+	require.True(s.T(), ok)
+	assert.False(s.T(), transfer.IsDeposit())
+
+	other, ok := activities[5].Typed().(*OtherActivity)
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), "a6", other.ActivityID())
+}
+
+func (s *AlpacaTestSuite) TestCachedClient() {
+	requests := map[string]int{}
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		requests[req.URL.Path]++
+		switch req.URL.Path {
+		case "/v2/account":
+			return &http.Response{Body: genBody(Account{ID: "some_id"})}, nil
+		case "/v2/clock":
+			now := time.Now()
+			return &http.Response{Body: genBody(Clock{
+				Timestamp: now,
+				NextOpen:  now.Add(time.Hour),
+				NextClose: now.Add(2 * time.Hour),
+			})}, nil
+		case "/v2/assets/AAPL":
+			return &http.Response{Body: genBody(Asset{Symbol: "AAPL"})}, nil
+		case "/v2/orders":
+			return &http.Response{Body: genBody(Order{ID: "o1"})}, nil
+		}
+		return nil, fmt.Errorf("unexpected request %s", req.URL)
+	}
+
+	c := NewCachedClient(NewClient(common.Credentials()), DefaultCacheTTLs)
+	for i := 0; i < 3; i++ {
+		acct, err := c.GetAccount()
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "some_id", acct.ID)
+		_, err = c.GetClock()
+		require.NoError(s.T(), err)
+		asset, err := c.GetAsset("AAPL")
+		require.NoError(s.T(), err)
+		assert.Equal(s.T(), "AAPL", asset.Symbol)
+	}
+	assert.Equal(s.T(), 1, requests["/v2/account"])
+	assert.Equal(s.T(), 1, requests["/v2/clock"])
+	assert.Equal(s.T(), 1, requests["/v2/assets/AAPL"])
+
+	// orders change the account
+	_, err := c.PlaceOrder(PlaceOrderRequest{})
+	require.NoError(s.T(), err)
+	_, err = c.GetAccount()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 2, requests["/v2/account"])
+
+	c.Invalidate()
+	_, err = c.GetAsset("AAPL")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 2, requests["/v2/assets/AAPL"])
+
+	// zero TTLs disable the cache
+	c = NewCachedClient(NewClient(common.Credentials()), CacheTTLs{})
+	_, err = c.GetAccount()
+	require.NoError(s.T(), err)
+	_, err = c.GetAccount()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 4, requests["/v2/account"])
+}
+
 type nopCloser struct {
 	io.Reader
 }
@@ -1026,3 +2702,260 @@ func genBody(data interface{}) io.ReadCloser {
 	buf, _ := json.Marshal(data)
 	return nopCloser{bytes.NewBuffer(buf)}
 }
+
+func (s *AlpacaTestSuite) TestRateLimit() {
+	defer func(d func(c *Client, req *http.Request) (*http.Response, error)) { do = d }(do)
+	do = defaultDo
+
+	remaining := 200
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remaining--
+		w.Header().Set("X-RateLimit-Limit", "200")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", "1700000000")
+		fmt.Fprint(w, `{"id":"acct"}`)
+	}))
+	defer server.Close()
+
+	c := NewClientWithOptions(
+		WithBaseURL(server.URL),
+		WithCredentials(&common.APIKey{ID: "key", Secret: "secret"}),
+	)
+	_, ok := c.RateLimit()
+	assert.False(s.T(), ok)
+
+	_, err := c.GetAccount()
+	require.NoError(s.T(), err)
+	_, err = c.WithContext(context.Background()).GetAccount()
+	require.NoError(s.T(), err)
+	rl, ok := c.RateLimit()
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), RateLimit{Limit: 200, Remaining: 198, Reset: time.Unix(1700000000, 0)}, rl)
+
+	// the copies with other credentials are limited separately
+	_, ok = c.WithCredentials(&common.APIKey{ID: "other", Secret: "secret"}).RateLimit()
+	assert.False(s.T(), ok)
+}
+
+func (s *AlpacaTestSuite) TestTimeSync() {
+	defer func(d func(c *Client, req *http.Request) (*http.Response, error)) { do = d }(do)
+	do = defaultDo
+
+	local := time.Date(2021, 10, 14, 13, 30, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", local.Add(90*time.Second).Format(http.TimeFormat))
+		if r.URL.Path == "/v2/clock" {
+			fmt.Fprint(w, `{"timestamp":"2021-10-14T09:31:30.25-04:00","is_open":true}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"acct"}`)
+	}))
+	defer server.Close()
+
+	c := NewClientWithOptions(
+		WithBaseURL(server.URL),
+		WithCredentials(&common.APIKey{ID: "key", Secret: "secret"}),
+		WithClock(clock.NewFake(local)),
+	)
+	_, ok := c.TimeOffset()
+	assert.False(s.T(), ok)
+	assert.Equal(s.T(), local, c.Now())
+
+	_, err := c.GetAccount()
+	require.NoError(s.T(), err)
+	offset, ok := c.TimeOffset()
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), 90500*time.Millisecond, offset)
+
+	offset, err = c.SyncTime()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 90250*time.Millisecond, offset)
+	// the Date header no longer overrides the offset of SyncTime
+	_, err = c.WithCredentials(&common.APIKey{ID: "other", Secret: "secret"}).GetAccount()
+	require.NoError(s.T(), err)
+	assert.True(s.T(), c.Now().Equal(local.Add(90250*time.Millisecond)))
+	assert.True(s.T(), c.ServerClock().Now().Equal(c.Now()))
+
+	assert.Equal(s.T(), 2*time.Second, ClockOffset(local, local.Add(2*time.Second), local.Add(3*time.Second)))
+}
+
+func (s *AlpacaTestSuite) TestRequestID() {
+	defer func(d func(c *Client, req *http.Request) (*http.Response, error)) { do = d }(do)
+	do = defaultDo
+
+	var ids []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ids = append(ids, r.Header.Get(RequestIDHeader))
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"code":40310000,"message":"insufficient buying power"}`)
+	}))
+	defer server.Close()
+
+	c := NewClientWithOptions(
+		WithBaseURL(server.URL),
+		WithCredentials(&common.APIKey{ID: "key", Secret: "secret"}),
+	)
+	_, err := c.GetAccount()
+	var apiErr *APIError
+	require.True(s.T(), errors.As(err, &apiErr))
+	require.Len(s.T(), ids, 1)
+	assert.Len(s.T(), ids[0], 32)
+	assert.Equal(s.T(), ids[0], apiErr.RequestID)
+
+	// every call has its own ID
+	_, err = c.GetAccount()
+	require.Error(s.T(), err)
+	assert.NotEqual(s.T(), ids[0], ids[1])
+
+	// unless the context sets one
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+	_, err = c.WithContext(ctx).GetAccount()
+	require.True(s.T(), errors.As(err, &apiErr))
+	assert.Equal(s.T(), "req-1", ids[2])
+	assert.Equal(s.T(), "req-1", apiErr.RequestID)
+}
+
+func (s *AlpacaTestSuite) TestAPIVersion() {
+	var paths []string
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		paths = append(paths, req.URL.Path)
+		return &http.Response{Body: genBody(map[string]interface{}{})}, nil
+	}
+
+	c := NewClientWithOptions(
+		WithBaseURL("https://api.example.com"),
+		WithDataURL("https://data.example.com"),
+		WithAPIVersion(StocksEndpoint, "v1beta3"),
+	)
+	_, err := c.GetClock()
+	require.NoError(s.T(), err)
+	_, err = c.GetSnapshot("AAPL")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"/v2/clock", "/v1beta3/stocks/AAPL/snapshot"}, paths)
+}
+
+func (s *AlpacaTestSuite) TestUserAgent() {
+	defer func(d func(c *Client, req *http.Request) (*http.Response, error)) { do = d }(do)
+	do = defaultDo
+
+	var agents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.Header.Get("User-Agent"))
+		fmt.Fprint(w, `{"id":"acct"}`)
+	}))
+	defer server.Close()
+
+	for _, opts := range [][]ClientOption{nil, {WithUserAgent("my-bot/2.1")}} {
+		c := NewClientWithOptions(append(opts, WithBaseURL(server.URL))...)
+		_, err := c.GetAccount()
+		require.NoError(s.T(), err)
+	}
+	assert.Equal(s.T(), []string{
+		"alpaca-trade-api-go/" + common.Version,
+		"my-bot/2.1 alpaca-trade-api-go/" + common.Version,
+	}, agents)
+}
+
+func (s *AlpacaTestSuite) TestPager() {
+	// trades are fetched page by page up to the limit
+	var limits, tokens []string
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		q := req.URL.Query()
+		assert.Equal(s.T(), "/v2/stocks/AAPL/trades", req.URL.Path)
+		limits = append(limits, q.Get("limit"))
+		tokens = append(tokens, q.Get("page_token"))
+		if q.Get("page_token") == "" {
+			return &http.Response{
+				Body: ioutil.NopCloser(strings.NewReader(
+					`{"trades":[{"p":1},{"p":2}],"next_page_token":"next"}`)),
+			}, nil
+		}
+		return &http.Response{
+			Body: ioutil.NopCloser(strings.NewReader(`{"trades":[{"p":3}],"next_page_token":"last"}`)),
+		}, nil
+	}
+	start, end := time.Now().Add(-time.Hour), time.Now()
+	trades, err := TradesPager("AAPL", start, end).Limit(3).All(context.Background())
+	require.NoError(s.T(), err)
+	require.Len(s.T(), trades, 3)
+	assert.Equal(s.T(), 3.0, trades[2].Price)
+	assert.Equal(s.T(), []string{"3", "1"}, limits)
+	assert.Equal(s.T(), []string{"", "next"}, tokens)
+
+	// the channel API is served by the pager
+	items := []v2.TradeItem{}
+	for item := range GetTrades("AAPL", start, end, 2) {
+		items = append(items, item)
+	}
+	require.Len(s.T(), items, 2)
+	assert.NoError(s.T(), items[1].Error)
+
+	// orders are paged by submission time
+	submitted := time.Date(2021, 3, 4, 15, 30, 0, 0, time.UTC)
+	var untils []string
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		q := req.URL.Query()
+		assert.Equal(s.T(), "all", q.Get("status"))
+		untils = append(untils, q.Get("until"))
+		if q.Get("until") == "" {
+			assert.Equal(s.T(), "3", q.Get("limit"))
+			return &http.Response{
+				Body: genBody([]Order{{ID: "1"}, {ID: "2"}, {ID: "3", SubmittedAt: submitted}}),
+			}, nil
+		}
+		assert.Equal(s.T(), "3", q.Get("limit"))
+		return &http.Response{Body: genBody([]Order{{ID: "4"}})}, nil
+	}
+	pager := OrdersPager("all", false)
+	pager.pageSize = 3
+	it := pager.Iterate(context.Background())
+	ids := []string{}
+	for it.Next() {
+		ids = append(ids, it.Value().ID)
+	}
+	require.NoError(s.T(), it.Err())
+	assert.Equal(s.T(), []string{"1", "2", "3", "4"}, ids)
+	assert.Equal(s.T(), []string{"", submitted.Format(time.RFC3339Nano)}, untils)
+
+	// the orders submitted at the page boundary are returned once
+	untils = nil
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		until := req.URL.Query().Get("until")
+		untils = append(untils, until)
+		if until == "" {
+			return &http.Response{
+				Body: genBody([]Order{{ID: "1"}, {ID: "2", SubmittedAt: submitted}, {ID: "3", SubmittedAt: submitted}}),
+			}, nil
+		}
+		return &http.Response{
+			Body: genBody([]Order{{ID: "2", SubmittedAt: submitted}, {ID: "3", SubmittedAt: submitted}}),
+		}, nil
+	}
+	orders, err := pager.All(context.Background())
+	require.NoError(s.T(), err)
+	ids = nil
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+	assert.Equal(s.T(), []string{"1", "2", "3"}, ids)
+
+	// a full page submitted at the token time cannot be paged past
+	untils = nil
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		untils = append(untils, req.URL.Query().Get("until"))
+		return &http.Response{
+			Body: genBody([]Order{{ID: "1", SubmittedAt: submitted}, {ID: "2", SubmittedAt: submitted}, {ID: "3", SubmittedAt: submitted}}),
+		}, nil
+	}
+	_, err = pager.All(context.Background())
+	assert.True(s.T(), errors.Is(err, ErrOrdersPageStuck))
+	assert.Len(s.T(), untils, 2)
+
+	// errors stop the iteration
+	do = func(c *Client, req *http.Request) (*http.Response, error) {
+		return &http.Response{}, fmt.Errorf("fail")
+	}
+	it2 := ActivitiesPager(nil, nil).Iterate(context.Background())
+	assert.False(s.T(), it2.Next())
+	assert.Error(s.T(), it2.Err())
+}
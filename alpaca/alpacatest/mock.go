@@ -0,0 +1,669 @@
+package alpacatest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+)
+
+// ErrNotMocked is returned by the methods of MockClient whose function is not set
+var ErrNotMocked = errors.New("method not mocked")
+
+// MockClient is a fake alpaca.TradingClient. Every method calls the
+// corresponding function field, e.g. GetAccount calls GetAccountFunc.
+// Methods whose function is not set return zero values and ErrNotMocked
+// (or a closed channel for the streaming methods). Calls records the name
+// of every called method in order; read it with CalledMethods while methods
+// may still be called from other goroutines.
+type MockClient struct {
+	GetAccountFunc                   func() (*alpaca.Account, error)
+	GetAccountConfigurationsFunc     func() (*alpaca.AccountConfigurations, error)
+	UpdateAccountConfigurationsFunc  func(newConfigs alpaca.AccountConfigurationsRequest) (*alpaca.AccountConfigurations, error)
+	GetAccountActivitiesFunc         func(activityType *string, opts *alpaca.AccountActivitiesRequest) ([]alpaca.AccountActivity, error)
+	GetPortfolioHistoryFunc          func(period *string, timeframe *alpaca.RangeFreq, dateEnd *time.Time, extendedHours bool) (*alpaca.PortfolioHistory, error)
+	ListPositionsFunc                func() ([]alpaca.Position, error)
+	GetPositionFunc                  func(symbol string) (*alpaca.Position, error)
+	CloseAllPositionsFunc            func() error
+	ClosePositionFunc                func(symbol string) error
+	GetAggregatesFunc                func(symbol string, timespan string, from string, to string) (*alpaca.Aggregates, error)
+	GetLastQuoteFunc                 func(symbol string) (*alpaca.LastQuoteResponse, error)
+	GetLastTradeFunc                 func(symbol string) (*alpaca.LastTradeResponse, error)
+	GetTradesFunc                    func(symbol string, start time.Time, end time.Time, limit int) <-chan v2.TradeItem
+	GetQuotesFunc                    func(symbol string, start time.Time, end time.Time, limit int) <-chan v2.QuoteItem
+	GetBarsFunc                      func(symbol string, timeFrame v2.TimeFrame, adjustment v2.Adjustment, start time.Time, end time.Time, limit int) <-chan v2.BarItem
+	GetMultiBarsFunc                 func(symbols []string, timeFrame v2.TimeFrame, adjustment v2.Adjustment, start time.Time, end time.Time) (map[string][]v2.Bar, error)
+	GetLatestTradeFunc               func(symbol string) (*v2.Trade, error)
+	GetLatestQuoteFunc               func(symbol string) (*v2.Quote, error)
+	GetMarkPriceFunc                 func(symbol string) (*alpaca.MarkPrice, error)
+	GetSnapshotFunc                  func(symbol string) (*v2.Snapshot, error)
+	GetSnapshotsFunc                 func(symbols []string) (map[string]*v2.Snapshot, error)
+	GetPositionBarsFunc              func(timeFrame v2.TimeFrame, lookback time.Duration) (map[string]*alpaca.PositionBars, error)
+	ListBarsFunc                     func(symbols []string, opts alpaca.ListBarParams) (map[string][]alpaca.Bar, error)
+	GetSymbolBarsFunc                func(symbol string, opts alpaca.ListBarParams) ([]alpaca.Bar, error)
+	ListCryptoPairsFunc              func(status *string) ([]alpaca.CryptoPair, error)
+	GetCryptoSnapshotFunc            func(symbol string) (*v2.CryptoSnapshot, error)
+	GetClockFunc                     func() (*alpaca.Clock, error)
+	GetCalendarFunc                  func(start *string, end *string) ([]alpaca.CalendarDay, error)
+	ListAnnouncementsFunc            func(req alpaca.ListAnnouncementsRequest) ([]alpaca.Announcement, error)
+	ListOrdersFunc                   func(status *string, until *time.Time, limit *int, nested *bool) ([]alpaca.Order, error)
+	PlaceOrderFunc                   func(req alpaca.PlaceOrderRequest) (*alpaca.Order, error)
+	ValidateOrderFunc                func(req alpaca.PlaceOrderRequest) error
+	GetOrderFunc                     func(orderID string) (*alpaca.Order, error)
+	GetNestedOrderFunc               func(orderID string) (*alpaca.Order, error)
+	GetOrderByClientOrderIDFunc      func(clientOrderID string) (*alpaca.Order, error)
+	ReplaceOrderFunc                 func(orderID string, req alpaca.ReplaceOrderRequest) (*alpaca.Order, error)
+	CancelOrderFunc                  func(orderID string) error
+	CancelAllOrdersFunc              func() error
+	ListAssetsFunc                   func(status *string) ([]alpaca.Asset, error)
+	GetAssetFunc                     func(symbol string) (*alpaca.Asset, error)
+	IsShortableFunc                  func(symbol string) (bool, error)
+	WouldTriggerPDTFunc              func(proposed alpaca.PlaceOrderRequest) (bool, error)
+	ListCryptoWalletsFunc            func(asset *string) ([]alpaca.CryptoWallet, error)
+	ListWhitelistedAddressesFunc     func() ([]alpaca.WhitelistedAddress, error)
+	CreateWhitelistedAddressFunc     func(req alpaca.CreateWhitelistedAddressRequest) (*alpaca.WhitelistedAddress, error)
+	DeleteWhitelistedAddressFunc     func(addressID string) error
+	ListCryptoTransfersFunc          func() ([]alpaca.CryptoTransfer, error)
+	GetCryptoTransferFunc            func(transferID string) (*alpaca.CryptoTransfer, error)
+	CreateCryptoTransferFunc         func(req alpaca.CreateCryptoTransferRequest) (*alpaca.CryptoTransfer, error)
+	WaitForCryptoTransferFunc        func(ctx context.Context, transferID string, interval time.Duration) (*alpaca.CryptoTransfer, error)
+	CreateCryptoTransferAndWaitFunc  func(ctx context.Context, req alpaca.CreateCryptoTransferRequest, interval time.Duration) (*alpaca.CryptoTransfer, error)
+	ListWatchlistsFunc               func() ([]alpaca.Watchlist, error)
+	GetWatchlistFunc                 func(watchlistID string) (*alpaca.Watchlist, error)
+	GetWatchlistByNameFunc           func(name string) (*alpaca.Watchlist, error)
+	GetOptionContractsFunc           func(filter alpaca.GetOptionContractsRequest) ([]alpaca.OptionContract, error)
+	GetOptionContractFunc            func(symbolOrID string) (*alpaca.OptionContract, error)
+	ExerciseOptionPositionFunc       func(symbol string) error
+	ListCryptoFeesFunc               func(opts *alpaca.AccountActivitiesRequest) ([]alpaca.CryptoFee, error)
+	GetCryptoFeeTierFunc             func() (*alpaca.CryptoFeeTier, error)
+	DoRawFunc                        func(method, path string, body interface{}) (json.RawMessage, error)
+	StreamTradeUpdateEventsFunc      func(ctx context.Context, req alpaca.StreamEventsRequest, handler func(event alpaca.TradeUpdateEvent)) error
+	StreamNonTradeActivityEventsFunc func(ctx context.Context, req alpaca.StreamEventsRequest, handler func(event alpaca.NonTradeActivityEvent)) error
+
+	Calls []string
+	// mu guards Calls
+	mu sync.Mutex
+}
+
+var _ alpaca.TradingClient = (*MockClient)(nil)
+
+func (m *MockClient) record(method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Calls = append(m.Calls, method)
+}
+
+// CalledMethods returns a copy of Calls.
+func (m *MockClient) CalledMethods() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.Calls...)
+}
+
+// GetAccount calls GetAccountFunc
+func (m *MockClient) GetAccount() (*alpaca.Account, error) {
+	m.record("GetAccount")
+	if m.GetAccountFunc != nil {
+		return m.GetAccountFunc()
+	}
+	return nil, ErrNotMocked
+}
+
+// GetAccountConfigurations calls GetAccountConfigurationsFunc
+func (m *MockClient) GetAccountConfigurations() (*alpaca.AccountConfigurations, error) {
+	m.record("GetAccountConfigurations")
+	if m.GetAccountConfigurationsFunc != nil {
+		return m.GetAccountConfigurationsFunc()
+	}
+	return nil, ErrNotMocked
+}
+
+// UpdateAccountConfigurations calls UpdateAccountConfigurationsFunc
+func (m *MockClient) UpdateAccountConfigurations(newConfigs alpaca.AccountConfigurationsRequest) (*alpaca.AccountConfigurations, error) {
+	m.record("UpdateAccountConfigurations")
+	if m.UpdateAccountConfigurationsFunc != nil {
+		return m.UpdateAccountConfigurationsFunc(newConfigs)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetAccountActivities calls GetAccountActivitiesFunc
+func (m *MockClient) GetAccountActivities(activityType *string, opts *alpaca.AccountActivitiesRequest) ([]alpaca.AccountActivity, error) {
+	m.record("GetAccountActivities")
+	if m.GetAccountActivitiesFunc != nil {
+		return m.GetAccountActivitiesFunc(activityType, opts)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetPortfolioHistory calls GetPortfolioHistoryFunc
+func (m *MockClient) GetPortfolioHistory(period *string, timeframe *alpaca.RangeFreq, dateEnd *time.Time, extendedHours bool) (*alpaca.PortfolioHistory, error) {
+	m.record("GetPortfolioHistory")
+	if m.GetPortfolioHistoryFunc != nil {
+		return m.GetPortfolioHistoryFunc(period, timeframe, dateEnd, extendedHours)
+	}
+	return nil, ErrNotMocked
+}
+
+// ListPositions calls ListPositionsFunc
+func (m *MockClient) ListPositions() ([]alpaca.Position, error) {
+	m.record("ListPositions")
+	if m.ListPositionsFunc != nil {
+		return m.ListPositionsFunc()
+	}
+	return nil, ErrNotMocked
+}
+
+// GetPosition calls GetPositionFunc
+func (m *MockClient) GetPosition(symbol string) (*alpaca.Position, error) {
+	m.record("GetPosition")
+	if m.GetPositionFunc != nil {
+		return m.GetPositionFunc(symbol)
+	}
+	return nil, ErrNotMocked
+}
+
+// CloseAllPositions calls CloseAllPositionsFunc
+func (m *MockClient) CloseAllPositions() error {
+	m.record("CloseAllPositions")
+	if m.CloseAllPositionsFunc != nil {
+		return m.CloseAllPositionsFunc()
+	}
+	return ErrNotMocked
+}
+
+// ClosePosition calls ClosePositionFunc
+func (m *MockClient) ClosePosition(symbol string) error {
+	m.record("ClosePosition")
+	if m.ClosePositionFunc != nil {
+		return m.ClosePositionFunc(symbol)
+	}
+	return ErrNotMocked
+}
+
+// GetAggregates calls GetAggregatesFunc
+func (m *MockClient) GetAggregates(symbol string, timespan string, from string, to string) (*alpaca.Aggregates, error) {
+	m.record("GetAggregates")
+	if m.GetAggregatesFunc != nil {
+		return m.GetAggregatesFunc(symbol, timespan, from, to)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetLastQuote calls GetLastQuoteFunc
+func (m *MockClient) GetLastQuote(symbol string) (*alpaca.LastQuoteResponse, error) {
+	m.record("GetLastQuote")
+	if m.GetLastQuoteFunc != nil {
+		return m.GetLastQuoteFunc(symbol)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetLastTrade calls GetLastTradeFunc
+func (m *MockClient) GetLastTrade(symbol string) (*alpaca.LastTradeResponse, error) {
+	m.record("GetLastTrade")
+	if m.GetLastTradeFunc != nil {
+		return m.GetLastTradeFunc(symbol)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetTrades calls GetTradesFunc
+func (m *MockClient) GetTrades(symbol string, start time.Time, end time.Time, limit int) <-chan v2.TradeItem {
+	m.record("GetTrades")
+	if m.GetTradesFunc != nil {
+		return m.GetTradesFunc(symbol, start, end, limit)
+	}
+	ch := make(chan v2.TradeItem)
+	close(ch)
+	return ch
+}
+
+// GetQuotes calls GetQuotesFunc
+func (m *MockClient) GetQuotes(symbol string, start time.Time, end time.Time, limit int) <-chan v2.QuoteItem {
+	m.record("GetQuotes")
+	if m.GetQuotesFunc != nil {
+		return m.GetQuotesFunc(symbol, start, end, limit)
+	}
+	ch := make(chan v2.QuoteItem)
+	close(ch)
+	return ch
+}
+
+// GetBars calls GetBarsFunc
+func (m *MockClient) GetBars(symbol string, timeFrame v2.TimeFrame, adjustment v2.Adjustment, start time.Time, end time.Time, limit int) <-chan v2.BarItem {
+	m.record("GetBars")
+	if m.GetBarsFunc != nil {
+		return m.GetBarsFunc(symbol, timeFrame, adjustment, start, end, limit)
+	}
+	ch := make(chan v2.BarItem)
+	close(ch)
+	return ch
+}
+
+// GetMultiBars calls GetMultiBarsFunc
+func (m *MockClient) GetMultiBars(symbols []string, timeFrame v2.TimeFrame, adjustment v2.Adjustment, start time.Time, end time.Time) (map[string][]v2.Bar, error) {
+	m.record("GetMultiBars")
+	if m.GetMultiBarsFunc != nil {
+		return m.GetMultiBarsFunc(symbols, timeFrame, adjustment, start, end)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetLatestTrade calls GetLatestTradeFunc
+func (m *MockClient) GetLatestTrade(symbol string) (*v2.Trade, error) {
+	m.record("GetLatestTrade")
+	if m.GetLatestTradeFunc != nil {
+		return m.GetLatestTradeFunc(symbol)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetLatestQuote calls GetLatestQuoteFunc
+func (m *MockClient) GetLatestQuote(symbol string) (*v2.Quote, error) {
+	m.record("GetLatestQuote")
+	if m.GetLatestQuoteFunc != nil {
+		return m.GetLatestQuoteFunc(symbol)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetMarkPrice calls GetMarkPriceFunc
+func (m *MockClient) GetMarkPrice(symbol string) (*alpaca.MarkPrice, error) {
+	m.record("GetMarkPrice")
+	if m.GetMarkPriceFunc != nil {
+		return m.GetMarkPriceFunc(symbol)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetSnapshot calls GetSnapshotFunc
+func (m *MockClient) GetSnapshot(symbol string) (*v2.Snapshot, error) {
+	m.record("GetSnapshot")
+	if m.GetSnapshotFunc != nil {
+		return m.GetSnapshotFunc(symbol)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetSnapshots calls GetSnapshotsFunc
+func (m *MockClient) GetSnapshots(symbols []string) (map[string]*v2.Snapshot, error) {
+	m.record("GetSnapshots")
+	if m.GetSnapshotsFunc != nil {
+		return m.GetSnapshotsFunc(symbols)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetPositionBars calls GetPositionBarsFunc
+func (m *MockClient) GetPositionBars(timeFrame v2.TimeFrame, lookback time.Duration) (map[string]*alpaca.PositionBars, error) {
+	m.record("GetPositionBars")
+	if m.GetPositionBarsFunc != nil {
+		return m.GetPositionBarsFunc(timeFrame, lookback)
+	}
+	return nil, ErrNotMocked
+}
+
+// ListBars calls ListBarsFunc
+func (m *MockClient) ListBars(symbols []string, opts alpaca.ListBarParams) (map[string][]alpaca.Bar, error) {
+	m.record("ListBars")
+	if m.ListBarsFunc != nil {
+		return m.ListBarsFunc(symbols, opts)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetSymbolBars calls GetSymbolBarsFunc
+func (m *MockClient) GetSymbolBars(symbol string, opts alpaca.ListBarParams) ([]alpaca.Bar, error) {
+	m.record("GetSymbolBars")
+	if m.GetSymbolBarsFunc != nil {
+		return m.GetSymbolBarsFunc(symbol, opts)
+	}
+	return nil, ErrNotMocked
+}
+
+// ListCryptoPairs calls ListCryptoPairsFunc
+func (m *MockClient) ListCryptoPairs(status *string) ([]alpaca.CryptoPair, error) {
+	m.record("ListCryptoPairs")
+	if m.ListCryptoPairsFunc != nil {
+		return m.ListCryptoPairsFunc(status)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetCryptoSnapshot calls GetCryptoSnapshotFunc
+func (m *MockClient) GetCryptoSnapshot(symbol string) (*v2.CryptoSnapshot, error) {
+	m.record("GetCryptoSnapshot")
+	if m.GetCryptoSnapshotFunc != nil {
+		return m.GetCryptoSnapshotFunc(symbol)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetClock calls GetClockFunc
+func (m *MockClient) GetClock() (*alpaca.Clock, error) {
+	m.record("GetClock")
+	if m.GetClockFunc != nil {
+		return m.GetClockFunc()
+	}
+	return nil, ErrNotMocked
+}
+
+// GetCalendar calls GetCalendarFunc
+func (m *MockClient) GetCalendar(start *string, end *string) ([]alpaca.CalendarDay, error) {
+	m.record("GetCalendar")
+	if m.GetCalendarFunc != nil {
+		return m.GetCalendarFunc(start, end)
+	}
+	return nil, ErrNotMocked
+}
+
+// ListAnnouncements calls ListAnnouncementsFunc
+func (m *MockClient) ListAnnouncements(req alpaca.ListAnnouncementsRequest) ([]alpaca.Announcement, error) {
+	m.record("ListAnnouncements")
+	if m.ListAnnouncementsFunc != nil {
+		return m.ListAnnouncementsFunc(req)
+	}
+	return nil, ErrNotMocked
+}
+
+// ListOrders calls ListOrdersFunc
+func (m *MockClient) ListOrders(status *string, until *time.Time, limit *int, nested *bool) ([]alpaca.Order, error) {
+	m.record("ListOrders")
+	if m.ListOrdersFunc != nil {
+		return m.ListOrdersFunc(status, until, limit, nested)
+	}
+	return nil, ErrNotMocked
+}
+
+// PlaceOrder calls PlaceOrderFunc
+func (m *MockClient) PlaceOrder(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+	m.record("PlaceOrder")
+	if m.PlaceOrderFunc != nil {
+		return m.PlaceOrderFunc(req)
+	}
+	return nil, ErrNotMocked
+}
+
+// ValidateOrder calls ValidateOrderFunc
+func (m *MockClient) ValidateOrder(req alpaca.PlaceOrderRequest) error {
+	m.record("ValidateOrder")
+	if m.ValidateOrderFunc != nil {
+		return m.ValidateOrderFunc(req)
+	}
+	return ErrNotMocked
+}
+
+// GetOrder calls GetOrderFunc
+func (m *MockClient) GetOrder(orderID string) (*alpaca.Order, error) {
+	m.record("GetOrder")
+	if m.GetOrderFunc != nil {
+		return m.GetOrderFunc(orderID)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetNestedOrder calls GetNestedOrderFunc
+func (m *MockClient) GetNestedOrder(orderID string) (*alpaca.Order, error) {
+	m.record("GetNestedOrder")
+	if m.GetNestedOrderFunc != nil {
+		return m.GetNestedOrderFunc(orderID)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetOrderByClientOrderID calls GetOrderByClientOrderIDFunc
+func (m *MockClient) GetOrderByClientOrderID(clientOrderID string) (*alpaca.Order, error) {
+	m.record("GetOrderByClientOrderID")
+	if m.GetOrderByClientOrderIDFunc != nil {
+		return m.GetOrderByClientOrderIDFunc(clientOrderID)
+	}
+	return nil, ErrNotMocked
+}
+
+// ReplaceOrder calls ReplaceOrderFunc
+func (m *MockClient) ReplaceOrder(orderID string, req alpaca.ReplaceOrderRequest) (*alpaca.Order, error) {
+	m.record("ReplaceOrder")
+	if m.ReplaceOrderFunc != nil {
+		return m.ReplaceOrderFunc(orderID, req)
+	}
+	return nil, ErrNotMocked
+}
+
+// CancelOrder calls CancelOrderFunc
+func (m *MockClient) CancelOrder(orderID string) error {
+	m.record("CancelOrder")
+	if m.CancelOrderFunc != nil {
+		return m.CancelOrderFunc(orderID)
+	}
+	return ErrNotMocked
+}
+
+// CancelAllOrders calls CancelAllOrdersFunc
+func (m *MockClient) CancelAllOrders() error {
+	m.record("CancelAllOrders")
+	if m.CancelAllOrdersFunc != nil {
+		return m.CancelAllOrdersFunc()
+	}
+	return ErrNotMocked
+}
+
+// ListAssets calls ListAssetsFunc
+func (m *MockClient) ListAssets(status *string) ([]alpaca.Asset, error) {
+	m.record("ListAssets")
+	if m.ListAssetsFunc != nil {
+		return m.ListAssetsFunc(status)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetAsset calls GetAssetFunc
+func (m *MockClient) GetAsset(symbol string) (*alpaca.Asset, error) {
+	m.record("GetAsset")
+	if m.GetAssetFunc != nil {
+		return m.GetAssetFunc(symbol)
+	}
+	return nil, ErrNotMocked
+}
+
+// IsShortable calls IsShortableFunc
+func (m *MockClient) IsShortable(symbol string) (bool, error) {
+	m.record("IsShortable")
+	if m.IsShortableFunc != nil {
+		return m.IsShortableFunc(symbol)
+	}
+	return false, ErrNotMocked
+}
+
+// WouldTriggerPDT calls WouldTriggerPDTFunc
+func (m *MockClient) WouldTriggerPDT(proposed alpaca.PlaceOrderRequest) (bool, error) {
+	m.record("WouldTriggerPDT")
+	if m.WouldTriggerPDTFunc != nil {
+		return m.WouldTriggerPDTFunc(proposed)
+	}
+	return false, ErrNotMocked
+}
+
+// ListCryptoWallets calls ListCryptoWalletsFunc
+func (m *MockClient) ListCryptoWallets(asset *string) ([]alpaca.CryptoWallet, error) {
+	m.record("ListCryptoWallets")
+	if m.ListCryptoWalletsFunc != nil {
+		return m.ListCryptoWalletsFunc(asset)
+	}
+	return nil, ErrNotMocked
+}
+
+// ListWhitelistedAddresses calls ListWhitelistedAddressesFunc
+func (m *MockClient) ListWhitelistedAddresses() ([]alpaca.WhitelistedAddress, error) {
+	m.record("ListWhitelistedAddresses")
+	if m.ListWhitelistedAddressesFunc != nil {
+		return m.ListWhitelistedAddressesFunc()
+	}
+	return nil, ErrNotMocked
+}
+
+// CreateWhitelistedAddress calls CreateWhitelistedAddressFunc
+func (m *MockClient) CreateWhitelistedAddress(req alpaca.CreateWhitelistedAddressRequest) (*alpaca.WhitelistedAddress, error) {
+	m.record("CreateWhitelistedAddress")
+	if m.CreateWhitelistedAddressFunc != nil {
+		return m.CreateWhitelistedAddressFunc(req)
+	}
+	return nil, ErrNotMocked
+}
+
+// DeleteWhitelistedAddress calls DeleteWhitelistedAddressFunc
+func (m *MockClient) DeleteWhitelistedAddress(addressID string) error {
+	m.record("DeleteWhitelistedAddress")
+	if m.DeleteWhitelistedAddressFunc != nil {
+		return m.DeleteWhitelistedAddressFunc(addressID)
+	}
+	return ErrNotMocked
+}
+
+// ListCryptoTransfers calls ListCryptoTransfersFunc
+func (m *MockClient) ListCryptoTransfers() ([]alpaca.CryptoTransfer, error) {
+	m.record("ListCryptoTransfers")
+	if m.ListCryptoTransfersFunc != nil {
+		return m.ListCryptoTransfersFunc()
+	}
+	return nil, ErrNotMocked
+}
+
+// GetCryptoTransfer calls GetCryptoTransferFunc
+func (m *MockClient) GetCryptoTransfer(transferID string) (*alpaca.CryptoTransfer, error) {
+	m.record("GetCryptoTransfer")
+	if m.GetCryptoTransferFunc != nil {
+		return m.GetCryptoTransferFunc(transferID)
+	}
+	return nil, ErrNotMocked
+}
+
+// CreateCryptoTransfer calls CreateCryptoTransferFunc
+func (m *MockClient) CreateCryptoTransfer(req alpaca.CreateCryptoTransferRequest) (*alpaca.CryptoTransfer, error) {
+	m.record("CreateCryptoTransfer")
+	if m.CreateCryptoTransferFunc != nil {
+		return m.CreateCryptoTransferFunc(req)
+	}
+	return nil, ErrNotMocked
+}
+
+// WaitForCryptoTransfer calls WaitForCryptoTransferFunc
+func (m *MockClient) WaitForCryptoTransfer(ctx context.Context, transferID string, interval time.Duration) (*alpaca.CryptoTransfer, error) {
+	m.record("WaitForCryptoTransfer")
+	if m.WaitForCryptoTransferFunc != nil {
+		return m.WaitForCryptoTransferFunc(ctx, transferID, interval)
+	}
+	return nil, ErrNotMocked
+}
+
+// CreateCryptoTransferAndWait calls CreateCryptoTransferAndWaitFunc
+func (m *MockClient) CreateCryptoTransferAndWait(ctx context.Context, req alpaca.CreateCryptoTransferRequest, interval time.Duration) (*alpaca.CryptoTransfer, error) {
+	m.record("CreateCryptoTransferAndWait")
+	if m.CreateCryptoTransferAndWaitFunc != nil {
+		return m.CreateCryptoTransferAndWaitFunc(ctx, req, interval)
+	}
+	return nil, ErrNotMocked
+}
+
+// ListWatchlists calls ListWatchlistsFunc
+func (m *MockClient) ListWatchlists() ([]alpaca.Watchlist, error) {
+	m.record("ListWatchlists")
+	if m.ListWatchlistsFunc != nil {
+		return m.ListWatchlistsFunc()
+	}
+	return nil, ErrNotMocked
+}
+
+// GetWatchlist calls GetWatchlistFunc
+func (m *MockClient) GetWatchlist(watchlistID string) (*alpaca.Watchlist, error) {
+	m.record("GetWatchlist")
+	if m.GetWatchlistFunc != nil {
+		return m.GetWatchlistFunc(watchlistID)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetWatchlistByName calls GetWatchlistByNameFunc
+func (m *MockClient) GetWatchlistByName(name string) (*alpaca.Watchlist, error) {
+	m.record("GetWatchlistByName")
+	if m.GetWatchlistByNameFunc != nil {
+		return m.GetWatchlistByNameFunc(name)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetOptionContracts calls GetOptionContractsFunc
+func (m *MockClient) GetOptionContracts(filter alpaca.GetOptionContractsRequest) ([]alpaca.OptionContract, error) {
+	m.record("GetOptionContracts")
+	if m.GetOptionContractsFunc != nil {
+		return m.GetOptionContractsFunc(filter)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetOptionContract calls GetOptionContractFunc
+func (m *MockClient) GetOptionContract(symbolOrID string) (*alpaca.OptionContract, error) {
+	m.record("GetOptionContract")
+	if m.GetOptionContractFunc != nil {
+		return m.GetOptionContractFunc(symbolOrID)
+	}
+	return nil, ErrNotMocked
+}
+
+// ExerciseOptionPosition calls ExerciseOptionPositionFunc
+func (m *MockClient) ExerciseOptionPosition(symbol string) error {
+	m.record("ExerciseOptionPosition")
+	if m.ExerciseOptionPositionFunc != nil {
+		return m.ExerciseOptionPositionFunc(symbol)
+	}
+	return ErrNotMocked
+}
+
+// ListCryptoFees calls ListCryptoFeesFunc
+func (m *MockClient) ListCryptoFees(opts *alpaca.AccountActivitiesRequest) ([]alpaca.CryptoFee, error) {
+	m.record("ListCryptoFees")
+	if m.ListCryptoFeesFunc != nil {
+		return m.ListCryptoFeesFunc(opts)
+	}
+	return nil, ErrNotMocked
+}
+
+// GetCryptoFeeTier calls GetCryptoFeeTierFunc
+func (m *MockClient) GetCryptoFeeTier() (*alpaca.CryptoFeeTier, error) {
+	m.record("GetCryptoFeeTier")
+	if m.GetCryptoFeeTierFunc != nil {
+		return m.GetCryptoFeeTierFunc()
+	}
+	return nil, ErrNotMocked
+}
+
+// DoRaw calls DoRawFunc
+func (m *MockClient) DoRaw(method, path string, body interface{}) (json.RawMessage, error) {
+	m.record("DoRaw")
+	if m.DoRawFunc != nil {
+		return m.DoRawFunc(method, path, body)
+	}
+	return json.RawMessage{}, ErrNotMocked
+}
+
+// StreamTradeUpdateEvents calls StreamTradeUpdateEventsFunc
+func (m *MockClient) StreamTradeUpdateEvents(ctx context.Context, req alpaca.StreamEventsRequest, handler func(event alpaca.TradeUpdateEvent)) error {
+	m.record("StreamTradeUpdateEvents")
+	if m.StreamTradeUpdateEventsFunc != nil {
+		return m.StreamTradeUpdateEventsFunc(ctx, req, handler)
+	}
+	return ErrNotMocked
+}
+
+// StreamNonTradeActivityEvents calls StreamNonTradeActivityEventsFunc
+func (m *MockClient) StreamNonTradeActivityEvents(ctx context.Context, req alpaca.StreamEventsRequest, handler func(event alpaca.NonTradeActivityEvent)) error {
+	m.record("StreamNonTradeActivityEvents")
+	if m.StreamNonTradeActivityEventsFunc != nil {
+		return m.StreamNonTradeActivityEventsFunc(ctx, req, handler)
+	}
+	return ErrNotMocked
+}
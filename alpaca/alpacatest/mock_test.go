@@ -0,0 +1,47 @@
+package alpacatest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockClient(t *testing.T) {
+	m := &MockClient{
+		GetAccountFunc: func() (*alpaca.Account, error) {
+			return &alpaca.Account{ID: "some_id"}, nil
+		},
+	}
+	var c alpaca.TradingClient = m
+
+	acct, err := c.GetAccount()
+	require.NoError(t, err)
+	assert.Equal(t, "some_id", acct.ID)
+
+	_, err = c.GetClock()
+	assert.Equal(t, ErrNotMocked, err)
+
+	assert.Equal(t, ErrNotMocked, c.CancelAllOrders())
+
+	_, ok := <-c.GetTrades("AAPL", acct.CreatedAt, acct.CreatedAt, 0)
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"GetAccount", "GetClock", "CancelAllOrders", "GetTrades"}, m.Calls)
+}
+
+func TestMockClientConcurrentCalls(t *testing.T) {
+	m := &MockClient{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.GetClock()
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, m.CalledMethods(), 10)
+}
@@ -0,0 +1,703 @@
+package alpacatest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	"github.com/shopspring/decimal"
+)
+
+// API error codes of the server, as the ones of the trading API
+const (
+	unauthorizedCode        = 40110000
+	forbiddenCode           = 40310000
+	notFoundCode            = 40410000
+	unprocessableEntityCode = 42210000
+)
+
+// notionalPlaces are the decimal places of the quantity of a notional order
+const notionalPlaces = 9
+
+// errNotFound is the error of the orders and positions that do not exist
+var errNotFound = errors.New("not found")
+
+// apiError is an error response of the server
+type apiError struct {
+	status int
+	alpaca.APIError
+}
+
+func newAPIError(status, code int, format string, args ...interface{}) *apiError {
+	return &apiError{status: status, APIError: alpaca.APIError{Code: code, Message: fmt.Sprintf(format, args...)}}
+}
+
+// ServerOption configures a Server
+type ServerOption func(s *Server)
+
+// WithCash sets the cash of the account of the server, 100000 by default.
+func WithCash(cash decimal.Decimal) ServerOption {
+	return func(s *Server) {
+		s.cash = cash
+	}
+}
+
+// WithManualFills keeps the orders open until Fill is called, instead of
+// filling them as soon as the price set with SetPrice crosses them.
+func WithManualFills() ServerOption {
+	return func(s *Server) {
+		s.manual = true
+	}
+}
+
+// WithNow sets the clock of the server, time.Now by default.
+func WithNow(now func() time.Time) ServerOption {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// position is a position of the account
+type position struct {
+	qty   decimal.Decimal
+	entry decimal.Decimal
+}
+
+// Server is a fake trading API serving an in-memory account, its orders and
+// its positions over HTTP, for a real alpaca.Client to run against, e.g.
+//
+//	s := alpacatest.NewServer()
+//	defer s.Close()
+//	s.SetPrice("AAPL", decimal.New(150, 0))
+//	order, err := s.Client().PlaceOrder(req)
+//
+// Orders go through the states of the trading API: they are new once
+// placed, then partially filled, filled, canceled or replaced. They fill at
+// the price of their symbol set with SetPrice, in full, once their limit and
+// stop prices are crossed, or with Fill. Market, limit, stop and stop limit
+// orders of a single leg are supported, regardless of their time in force.
+type Server struct {
+	*httptest.Server
+	credentials common.APIKey
+	manual      bool
+	now         func() time.Time
+
+	mu        sync.Mutex
+	cash      decimal.Decimal
+	seq       int
+	orders    []*alpaca.Order
+	prices    map[string]decimal.Decimal
+	positions map[string]*position
+}
+
+// NewServer starts a server. Close it once done.
+func NewServer(opts ...ServerOption) *Server {
+	s := &Server{
+		credentials: common.APIKey{ID: "alpacatest-key", Secret: "alpacatest-secret"},
+		now:         time.Now,
+		cash:        decimal.New(100000, 0),
+		prices:      make(map[string]decimal.Decimal),
+		positions:   make(map[string]*position),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/account", s.handle(s.account))
+	mux.HandleFunc("/v2/orders", s.handle(s.ordersHandler))
+	mux.HandleFunc("/v2/orders/", s.handle(s.orderHandler))
+	mux.HandleFunc("/v2/orders:by_client_order_id", s.handle(s.orderByClientID))
+	mux.HandleFunc("/v2/positions", s.handle(s.positionsHandler))
+	mux.HandleFunc("/v2/positions/", s.handle(s.positionHandler))
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Credentials returns the credentials the server accepts.
+func (s *Server) Credentials() *common.APIKey {
+	credentials := s.credentials
+	return &credentials
+}
+
+// Client returns a client of the server, configured by opts as well.
+func (s *Server) Client(opts ...alpaca.ClientOption) *alpaca.Client {
+	return alpaca.NewClientWithOptions(append([]alpaca.ClientOption{
+		alpaca.WithBaseURL(s.URL),
+		alpaca.WithCredentials(s.Credentials()),
+	}, opts...)...)
+}
+
+// SetPrice sets the market price of symbol, and fills the open orders of
+// symbol it crosses unless the server fills manually.
+func (s *Server) SetPrice(symbol string, price decimal.Decimal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prices[symbol] = price
+	s.match()
+}
+
+// Fill fills qty of an open order at price, or the rest of it for a zero qty.
+func (s *Server) Fill(orderID string, qty, price decimal.Decimal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.order(orderID)
+	if o == nil {
+		return fmt.Errorf("order %s %w", orderID, errNotFound)
+	}
+	if !o.Status.IsOpen() {
+		return fmt.Errorf("order %s is %s", orderID, o.Status)
+	}
+	remaining := s.remaining(o, price)
+	if qty.IsZero() {
+		qty = remaining
+	}
+	if qty.Sign() <= 0 || qty.GreaterThan(remaining) {
+		return fmt.Errorf("cannot fill %s of order %s, %s remaining", qty, orderID, remaining)
+	}
+	s.fill(o, qty, price)
+	return nil
+}
+
+// Orders returns the orders placed so far, oldest first.
+func (s *Server) Orders() []alpaca.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orders := make([]alpaca.Order, len(s.orders))
+	for i, o := range s.orders {
+		orders[i] = *o
+	}
+	return orders
+}
+
+// handle authenticates the requests and writes the responses of h
+func (s *Server) handle(h func(r *http.Request) (int, interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			status int
+			body   interface{}
+			err    error
+		)
+		if r.Header.Get("APCA-API-KEY-ID") != s.credentials.ID ||
+			r.Header.Get("APCA-API-SECRET-KEY") != s.credentials.Secret {
+			err = newAPIError(http.StatusUnauthorized, unauthorizedCode, "request is not authorized")
+		} else {
+			s.mu.Lock()
+			status, body, err = h(r)
+			s.mu.Unlock()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) {
+				apiErr = newAPIError(http.StatusInternalServerError, 50010000, "%v", err)
+			}
+			status, body = apiErr.status, apiErr.APIError
+		}
+		w.WriteHeader(status)
+		if body != nil {
+			json.NewEncoder(w).Encode(body)
+		}
+	}
+}
+
+func methodNotAllowed() *apiError {
+	return newAPIError(http.StatusMethodNotAllowed, 40510000, "method not allowed")
+}
+
+func (s *Server) account(r *http.Request) (int, interface{}, error) {
+	if r.Method != http.MethodGet {
+		return 0, nil, methodNotAllowed()
+	}
+	long, short := decimal.Zero, decimal.Zero
+	for symbol, p := range s.positions {
+		value := p.qty.Mul(s.price(symbol, p.entry))
+		if value.Sign() > 0 {
+			long = long.Add(value)
+		} else {
+			short = short.Add(value)
+		}
+	}
+	equity := s.cash.Add(long).Add(short)
+	return http.StatusOK, alpaca.Account{
+		ID:               "alpacatest-account",
+		AccountNumber:    "PA0000000000",
+		Status:           "ACTIVE",
+		Currency:         "USD",
+		Cash:             s.cash,
+		CashWithdrawable: s.cash,
+		ShortingEnabled:  true,
+		BuyingPower:      s.buyingPower(),
+		Equity:           equity,
+		LastEquity:       equity,
+		Multiplier:       decimal.New(1, 0),
+		LongMarketValue:  long,
+		ShortMarketValue: short,
+		PortfolioValue:   equity,
+	}, nil
+}
+
+func (s *Server) ordersHandler(r *http.Request) (int, interface{}, error) {
+	switch r.Method {
+	case http.MethodGet:
+		return s.listOrders(r)
+	case http.MethodPost:
+		var req alpaca.PlaceOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return 0, nil, newAPIError(http.StatusUnprocessableEntity, unprocessableEntityCode, "invalid request: %v", err)
+		}
+		o, err := s.place(req)
+		if err != nil {
+			return 0, nil, err
+		}
+		return http.StatusOK, o, nil
+	case http.MethodDelete:
+		var canceled []map[string]interface{}
+		for _, o := range s.orders {
+			if o.Status.IsOpen() {
+				s.cancel(o)
+				canceled = append(canceled, map[string]interface{}{"id": o.ID, "status": http.StatusOK})
+			}
+		}
+		return http.StatusMultiStatus, canceled, nil
+	}
+	return 0, nil, methodNotAllowed()
+}
+
+func (s *Server) listOrders(r *http.Request) (int, interface{}, error) {
+	q := r.URL.Query()
+	status := q.Get("status")
+	limit := 50
+	if l := q.Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil {
+			return 0, nil, newAPIError(http.StatusUnprocessableEntity, unprocessableEntityCode, "invalid limit: %v", l)
+		}
+	}
+	orders := []alpaca.Order{}
+	// newest first
+	for i := len(s.orders) - 1; i >= 0 && len(orders) < limit; i-- {
+		o := s.orders[i]
+		switch status {
+		case "", "open":
+			if !o.Status.IsOpen() {
+				continue
+			}
+		case "closed":
+			if o.Status.IsOpen() {
+				continue
+			}
+		case "all":
+		default:
+			return 0, nil, newAPIError(http.StatusUnprocessableEntity, unprocessableEntityCode, "invalid status: %s", status)
+		}
+		orders = append(orders, *o)
+	}
+	return http.StatusOK, orders, nil
+}
+
+func (s *Server) orderHandler(r *http.Request) (int, interface{}, error) {
+	o := s.order(strings.TrimPrefix(r.URL.Path, "/v2/orders/"))
+	if o == nil {
+		return 0, nil, newAPIError(http.StatusNotFound, notFoundCode, "order not found")
+	}
+	switch r.Method {
+	case http.MethodGet:
+		return http.StatusOK, o, nil
+	case http.MethodPatch:
+		var req alpaca.ReplaceOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return 0, nil, newAPIError(http.StatusUnprocessableEntity, unprocessableEntityCode, "invalid request: %v", err)
+		}
+		replacement, err := s.replace(o, req)
+		if err != nil {
+			return 0, nil, err
+		}
+		return http.StatusOK, replacement, nil
+	case http.MethodDelete:
+		if !o.Status.IsOpen() {
+			return 0, nil, newAPIError(http.StatusUnprocessableEntity, unprocessableEntityCode,
+				"order is not cancelable, it is %s", o.Status)
+		}
+		s.cancel(o)
+		return http.StatusNoContent, nil, nil
+	}
+	return 0, nil, methodNotAllowed()
+}
+
+func (s *Server) orderByClientID(r *http.Request) (int, interface{}, error) {
+	if r.Method != http.MethodGet {
+		return 0, nil, methodNotAllowed()
+	}
+	id := r.URL.Query().Get("client_order_id")
+	for _, o := range s.orders {
+		if o.ClientOrderID == id {
+			return http.StatusOK, o, nil
+		}
+	}
+	return 0, nil, newAPIError(http.StatusNotFound, notFoundCode, "order not found")
+}
+
+func (s *Server) positionsHandler(r *http.Request) (int, interface{}, error) {
+	switch r.Method {
+	case http.MethodGet:
+		symbols := make([]string, 0, len(s.positions))
+		for symbol := range s.positions {
+			symbols = append(symbols, symbol)
+		}
+		sort.Strings(symbols)
+		positions := make([]alpaca.Position, len(symbols))
+		for i, symbol := range symbols {
+			positions[i] = s.position(symbol)
+		}
+		return http.StatusOK, positions, nil
+	case http.MethodDelete:
+		var closed []map[string]interface{}
+		for symbol := range s.positions {
+			o, err := s.close(symbol)
+			if err != nil {
+				return 0, nil, err
+			}
+			closed = append(closed, map[string]interface{}{"symbol": symbol, "status": http.StatusOK, "body": o})
+		}
+		return http.StatusMultiStatus, closed, nil
+	}
+	return 0, nil, methodNotAllowed()
+}
+
+func (s *Server) positionHandler(r *http.Request) (int, interface{}, error) {
+	symbol := strings.TrimPrefix(r.URL.Path, "/v2/positions/")
+	if _, ok := s.positions[symbol]; !ok {
+		return 0, nil, newAPIError(http.StatusNotFound, notFoundCode, "position does not exist")
+	}
+	switch r.Method {
+	case http.MethodGet:
+		return http.StatusOK, s.position(symbol), nil
+	case http.MethodDelete:
+		o, err := s.close(symbol)
+		if err != nil {
+			return 0, nil, err
+		}
+		return http.StatusOK, o, nil
+	}
+	return 0, nil, methodNotAllowed()
+}
+
+// place validates and places an order
+func (s *Server) place(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return newAPIError(http.StatusUnprocessableEntity, unprocessableEntityCode, format, args...)
+	}
+	switch {
+	case req.AssetKey == nil || *req.AssetKey == "":
+		return nil, invalid("symbol is required")
+	case req.Side != alpaca.Buy && req.Side != alpaca.Sell:
+		return nil, invalid("invalid side: %s", req.Side)
+	case req.Qty.IsZero() == req.Notional.IsZero():
+		return nil, invalid("one of qty and notional is required")
+	case req.Qty.Sign() < 0 || req.Notional.Sign() < 0:
+		return nil, invalid("qty and notional must be positive")
+	case !req.Notional.IsZero() && req.Type != alpaca.Market:
+		return nil, invalid("notional orders must be market orders")
+	case req.OrderClass != "" && req.OrderClass != alpaca.Simple:
+		return nil, invalid("order class %s is not supported", req.OrderClass)
+	}
+	switch req.Type {
+	case alpaca.Market:
+	case alpaca.Limit:
+		if req.LimitPrice == nil {
+			return nil, invalid("limit_price is required")
+		}
+	case alpaca.Stop:
+		if req.StopPrice == nil {
+			return nil, invalid("stop_price is required")
+		}
+	case alpaca.StopLimit:
+		if req.LimitPrice == nil || req.StopPrice == nil {
+			return nil, invalid("limit_price and stop_price are required")
+		}
+	default:
+		return nil, invalid("order type %s is not supported", req.Type)
+	}
+	if req.ClientOrderID != "" {
+		for _, o := range s.orders {
+			if o.ClientOrderID == req.ClientOrderID {
+				return nil, invalid("client_order_id must be unique")
+			}
+		}
+	}
+
+	now := s.now()
+	s.seq++
+	o := &alpaca.Order{
+		ID:            fmt.Sprintf("order-%d", s.seq),
+		ClientOrderID: req.ClientOrderID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		SubmittedAt:   now,
+		Symbol:        *req.AssetKey,
+		Class:         alpaca.USEquity,
+		Qty:           req.Qty,
+		Notional:      req.Notional,
+		Type:          req.Type,
+		Side:          req.Side,
+		TimeInForce:   req.TimeInForce,
+		LimitPrice:    req.LimitPrice,
+		StopPrice:     req.StopPrice,
+		Status:        alpaca.OrderNew,
+		ExtendedHours: req.ExtendedHours,
+		OrderClass:    alpaca.Simple,
+	}
+	if o.ClientOrderID == "" {
+		o.ClientOrderID = fmt.Sprintf("client-%d", s.seq)
+	}
+	if o.Side == alpaca.Buy {
+		if cost := s.reserved(o); cost.GreaterThan(s.buyingPower()) {
+			s.seq--
+			return nil, newAPIError(http.StatusForbidden, forbiddenCode, "insufficient buying power")
+		}
+	}
+	s.orders = append(s.orders, o)
+	s.match()
+	copied := *o
+	return &copied, nil
+}
+
+// replace replaces an open order. The quantity of the replacement defaults
+// to the quantity left to fill.
+func (s *Server) replace(o *alpaca.Order, req alpaca.ReplaceOrderRequest) (*alpaca.Order, error) {
+	if !o.Status.IsOpen() {
+		return nil, newAPIError(http.StatusUnprocessableEntity, unprocessableEntityCode,
+			"order is not replaceable, it is %s", o.Status)
+	}
+	if !o.Notional.IsZero() {
+		return nil, newAPIError(http.StatusUnprocessableEntity, unprocessableEntityCode,
+			"notional orders are not replaceable")
+	}
+	placed := alpaca.PlaceOrderRequest{
+		AssetKey:      &o.Symbol,
+		Qty:           o.Qty.Sub(o.FilledQty),
+		Side:          o.Side,
+		Type:          o.Type,
+		TimeInForce:   o.TimeInForce,
+		LimitPrice:    o.LimitPrice,
+		StopPrice:     o.StopPrice,
+		ExtendedHours: o.ExtendedHours,
+		ClientOrderID: req.ClientOrderID,
+	}
+	if req.Qty != nil {
+		placed.Qty = *req.Qty
+	}
+	if req.LimitPrice != nil {
+		placed.LimitPrice = req.LimitPrice
+	}
+	if req.StopPrice != nil {
+		placed.StopPrice = req.StopPrice
+	}
+	if req.TimeInForce != "" {
+		placed.TimeInForce = req.TimeInForce
+	}
+
+	// the replaced order no longer reserves buying power
+	status := o.Status
+	o.Status = alpaca.OrderReplaced
+	replacement, err := s.place(placed)
+	if err != nil {
+		o.Status = status
+		return nil, err
+	}
+	stored := s.order(replacement.ID)
+	now := s.now()
+	o.ReplacedAt, o.ReplacedBy, o.UpdatedAt = &now, &stored.ID, now
+	stored.Replaces = &o.ID
+	copied := *stored
+	return &copied, nil
+}
+
+func (s *Server) cancel(o *alpaca.Order) {
+	now := s.now()
+	o.Status, o.CanceledAt, o.UpdatedAt = alpaca.OrderCanceled, &now, now
+}
+
+// close places the market order closing the position of symbol
+func (s *Server) close(symbol string) (*alpaca.Order, error) {
+	p := s.positions[symbol]
+	side := alpaca.Sell
+	if p.qty.Sign() < 0 {
+		side = alpaca.Buy
+	}
+	return s.place(alpaca.PlaceOrderRequest{
+		AssetKey:    &symbol,
+		Qty:         p.qty.Abs(),
+		Side:        side,
+		Type:        alpaca.Market,
+		TimeInForce: alpaca.Day,
+	})
+}
+
+// match fills the open orders crossed by the prices
+func (s *Server) match() {
+	if s.manual {
+		return
+	}
+	for _, o := range s.orders {
+		price, ok := s.prices[o.Symbol]
+		if !ok || !o.Status.IsOpen() || !crosses(o, price) {
+			continue
+		}
+		s.fill(o, s.remaining(o, price), price)
+	}
+}
+
+// crosses returns true if an order can fill at price
+func crosses(o *alpaca.Order, price decimal.Decimal) bool {
+	buy := o.Side == alpaca.Buy
+	if o.StopPrice != nil && (o.Type == alpaca.Stop || o.Type == alpaca.StopLimit) {
+		if buy && price.LessThan(*o.StopPrice) || !buy && price.GreaterThan(*o.StopPrice) {
+			return false
+		}
+	}
+	if o.LimitPrice != nil && (o.Type == alpaca.Limit || o.Type == alpaca.StopLimit) {
+		if buy && price.GreaterThan(*o.LimitPrice) || !buy && price.LessThan(*o.LimitPrice) {
+			return false
+		}
+	}
+	return true
+}
+
+// remaining returns the quantity of an order left to fill at price
+func (s *Server) remaining(o *alpaca.Order, price decimal.Decimal) decimal.Decimal {
+	if o.Qty.IsZero() {
+		return o.Notional.DivRound(price, notionalPlaces)
+	}
+	return o.Qty.Sub(o.FilledQty)
+}
+
+// fill fills qty of an order at price, and updates the account
+func (s *Server) fill(o *alpaca.Order, qty, price decimal.Decimal) {
+	if o.Qty.IsZero() {
+		o.Qty = o.Notional.DivRound(price, notionalPlaces)
+	}
+	filled := o.FilledQty.Add(qty)
+	avg := price
+	if o.FilledAvgPrice != nil {
+		avg = o.FilledAvgPrice.Mul(o.FilledQty).Add(price.Mul(qty)).Div(filled)
+	}
+	now := s.now()
+	o.FilledQty, o.FilledAvgPrice, o.UpdatedAt = filled, &avg, now
+	if filled.Equal(o.Qty) {
+		o.Status, o.FilledAt = alpaca.OrderFilled, &now
+	} else {
+		o.Status = alpaca.OrderPartiallyFilled
+	}
+
+	signed := qty
+	if o.Side == alpaca.Sell {
+		signed = qty.Neg()
+	}
+	s.cash = s.cash.Sub(signed.Mul(price))
+	if _, ok := s.prices[o.Symbol]; !ok {
+		s.prices[o.Symbol] = price
+	}
+	p, ok := s.positions[o.Symbol]
+	if !ok {
+		p = &position{}
+		s.positions[o.Symbol] = p
+	}
+	held := p.qty
+	p.qty = held.Add(signed)
+	switch {
+	case p.qty.IsZero():
+		delete(s.positions, o.Symbol)
+	case held.Sign() == 0 || held.Sign() == signed.Sign():
+		// increased
+		p.entry = p.entry.Mul(held.Abs()).Add(price.Mul(qty)).Div(p.qty.Abs())
+	case held.Sign() != p.qty.Sign():
+		// reversed
+		p.entry = price
+	}
+}
+
+// reserved returns the buying power an open buy order holds
+func (s *Server) reserved(o *alpaca.Order) decimal.Decimal {
+	if o.Qty.IsZero() {
+		return o.Notional
+	}
+	price := s.prices[o.Symbol]
+	if o.LimitPrice != nil {
+		price = *o.LimitPrice
+	} else if o.StopPrice != nil && o.StopPrice.GreaterThan(price) {
+		price = *o.StopPrice
+	}
+	return o.Qty.Sub(o.FilledQty).Mul(price)
+}
+
+// buyingPower returns the cash not held by open buy orders
+func (s *Server) buyingPower() decimal.Decimal {
+	bp := s.cash
+	for _, o := range s.orders {
+		if o.Side == alpaca.Buy && o.Status.IsOpen() {
+			bp = bp.Sub(s.reserved(o))
+		}
+	}
+	return bp
+}
+
+func (s *Server) order(id string) *alpaca.Order {
+	for _, o := range s.orders {
+		if o.ID == id {
+			return o
+		}
+	}
+	return nil
+}
+
+// price returns the price of symbol, or def if it has none
+func (s *Server) price(symbol string, def decimal.Decimal) decimal.Decimal {
+	if price, ok := s.prices[symbol]; ok {
+		return price
+	}
+	return def
+}
+
+func (s *Server) position(symbol string) alpaca.Position {
+	p := s.positions[symbol]
+	price := s.price(symbol, p.entry)
+	side := "long"
+	if p.qty.Sign() < 0 {
+		side = "short"
+	}
+	value := p.qty.Mul(price)
+	cost := p.qty.Mul(p.entry)
+	pl := value.Sub(cost)
+	var plpc decimal.Decimal
+	if !cost.IsZero() {
+		plpc = pl.DivRound(cost.Abs(), 8)
+	}
+	return alpaca.Position{
+		AssetID:        symbol,
+		Symbol:         symbol,
+		Class:          alpaca.USEquity,
+		AccountID:      "alpacatest-account",
+		EntryPrice:     p.entry,
+		Qty:            p.qty,
+		QtyAvailable:   p.qty,
+		Side:           side,
+		MarketValue:    value,
+		CostBasis:      cost,
+		UnrealizedPL:   pl,
+		UnrealizedPLPC: plpc,
+		CurrentPrice:   price,
+		LastdayPrice:   price,
+	}
+}
@@ -0,0 +1,210 @@
+package alpacatest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func decp(s string) *decimal.Decimal {
+	d := dec(s)
+	return &d
+}
+
+func order(symbol string, side alpaca.Side, qty string) alpaca.PlaceOrderRequest {
+	return alpaca.PlaceOrderRequest{
+		AssetKey:    &symbol,
+		Qty:         dec(qty),
+		Side:        side,
+		Type:        alpaca.Market,
+		TimeInForce: alpaca.Day,
+	}
+}
+
+func TestServerMarketOrders(t *testing.T) {
+	s := NewServer(WithCash(dec("10000")))
+	defer s.Close()
+	c := s.Client()
+	var _ alpaca.TradingClient = c
+
+	// no price yet, so the order waits
+	o, err := c.PlaceOrder(order("AAPL", alpaca.Buy, "10"))
+	require.NoError(t, err)
+	assert.Equal(t, alpaca.OrderNew, o.Status)
+	assert.Equal(t, "AAPL", o.Symbol)
+
+	s.SetPrice("AAPL", dec("100"))
+	o, err = c.GetOrder(o.ID)
+	require.NoError(t, err)
+	assert.Equal(t, alpaca.OrderFilled, o.Status)
+	assert.True(t, dec("10").Equal(o.FilledQty))
+	assert.True(t, dec("100").Equal(*o.FilledAvgPrice))
+	assert.NotNil(t, o.FilledAt)
+
+	s.SetPrice("AAPL", dec("110"))
+	p, err := c.GetPosition("AAPL")
+	require.NoError(t, err)
+	assert.Equal(t, "long", p.Side)
+	assert.True(t, dec("10").Equal(p.Qty))
+	assert.True(t, dec("100").Equal(p.EntryPrice))
+	assert.True(t, dec("1100").Equal(p.MarketValue))
+	assert.True(t, dec("100").Equal(p.UnrealizedPL))
+
+	acct, err := c.GetAccount()
+	require.NoError(t, err)
+	assert.True(t, dec("9000").Equal(acct.Cash))
+	assert.True(t, dec("10100").Equal(acct.Equity))
+	assert.True(t, dec("1100").Equal(acct.LongMarketValue))
+
+	// closing sells at the market
+	require.NoError(t, c.ClosePosition("AAPL"))
+	_, err = c.GetPosition("AAPL")
+	assert.True(t, errors.Is(err, alpaca.ErrPositionNotFound))
+	positions, err := c.ListPositions()
+	require.NoError(t, err)
+	assert.Empty(t, positions)
+	acct, err = c.GetAccount()
+	require.NoError(t, err)
+	assert.True(t, dec("10100").Equal(acct.Cash))
+}
+
+func TestServerLimitOrders(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := s.Client()
+	s.SetPrice("MSFT", dec("250"))
+
+	req := order("MSFT", alpaca.Buy, "4")
+	req.Type, req.LimitPrice, req.ClientOrderID = alpaca.Limit, decp("240"), "dip"
+	o, err := c.PlaceOrder(req)
+	require.NoError(t, err)
+	assert.Equal(t, alpaca.OrderNew, o.Status)
+
+	_, err = c.PlaceOrder(req)
+	assert.Error(t, err, "duplicate client order id")
+
+	open := "open"
+	orders, err := c.ListOrders(&open, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, o.ID, orders[0].ID)
+
+	acct, err := c.GetAccount()
+	require.NoError(t, err)
+	assert.True(t, dec("99040").Equal(acct.BuyingPower))
+
+	// replaced with a higher limit, crossed by the price
+	replacement, err := c.ReplaceOrder(o.ID, alpaca.ReplaceOrderRequest{LimitPrice: decp("255")})
+	require.NoError(t, err)
+	assert.Equal(t, alpaca.OrderFilled, replacement.Status)
+	assert.Equal(t, o.ID, *replacement.Replaces)
+	assert.True(t, dec("250").Equal(*replacement.FilledAvgPrice))
+
+	o, err = c.GetOrderByClientOrderID("dip")
+	require.NoError(t, err)
+	assert.Equal(t, alpaca.OrderReplaced, o.Status)
+	assert.Equal(t, replacement.ID, *o.ReplacedBy)
+
+	// terminal orders are neither cancelable nor replaceable
+	assert.True(t, errors.Is(c.CancelOrder(replacement.ID), alpaca.ErrOrderNotCancelable))
+	_, err = c.ReplaceOrder(o.ID, alpaca.ReplaceOrderRequest{Qty: decp("1")})
+	assert.Error(t, err)
+
+	all := "all"
+	orders, err = c.ListOrders(&all, nil, nil, nil)
+	require.NoError(t, err)
+	require.Len(t, orders, 2)
+	assert.Equal(t, replacement.ID, orders[0].ID)
+}
+
+func TestServerManualFills(t *testing.T) {
+	s := NewServer(WithManualFills())
+	defer s.Close()
+	c := s.Client()
+	s.SetPrice("TSLA", dec("700"))
+
+	o, err := c.PlaceOrder(order("TSLA", alpaca.Sell, "3"))
+	require.NoError(t, err)
+	assert.Equal(t, alpaca.OrderNew, o.Status)
+
+	require.NoError(t, s.Fill(o.ID, dec("1"), dec("700")))
+	o, err = c.GetOrder(o.ID)
+	require.NoError(t, err)
+	assert.Equal(t, alpaca.OrderPartiallyFilled, o.Status)
+	assert.Error(t, s.Fill(o.ID, dec("5"), dec("700")))
+
+	require.NoError(t, s.Fill(o.ID, decimal.Zero, dec("690")))
+	o, err = c.GetOrder(o.ID)
+	require.NoError(t, err)
+	assert.Equal(t, alpaca.OrderFilled, o.Status)
+	assert.True(t, dec("3").Equal(o.FilledQty))
+	assert.True(t, dec("693.33").Equal(o.FilledAvgPrice.Round(2)))
+	assert.Error(t, s.Fill(o.ID, decimal.Zero, dec("690")))
+
+	p, err := c.GetPosition("TSLA")
+	require.NoError(t, err)
+	assert.Equal(t, "short", p.Side)
+	assert.True(t, dec("-3").Equal(p.Qty))
+
+	// the orders wait until filled
+	o, err = c.PlaceOrder(order("TSLA", alpaca.Buy, "1"))
+	require.NoError(t, err)
+	require.NoError(t, c.CancelAllOrders())
+	o, err = c.GetOrder(o.ID)
+	require.NoError(t, err)
+	assert.Equal(t, alpaca.OrderCanceled, o.Status)
+	assert.NotNil(t, o.CanceledAt)
+	assert.Len(t, s.Orders(), 2)
+}
+
+func TestServerNotional(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c := s.Client()
+	s.SetPrice("VOO", dec("400"))
+
+	req := order("VOO", alpaca.Buy, "0")
+	req.Notional = dec("100")
+	o, err := c.PlaceOrder(req)
+	require.NoError(t, err)
+	assert.Equal(t, alpaca.OrderFilled, o.Status)
+	assert.True(t, dec("0.25").Equal(o.FilledQty))
+}
+
+func TestServerErrors(t *testing.T) {
+	s := NewServer(WithCash(dec("1000")))
+	defer s.Close()
+	c := s.Client()
+	s.SetPrice("AAPL", dec("100"))
+
+	_, err := c.PlaceOrder(order("AAPL", alpaca.Buy, "11"))
+	assert.True(t, errors.Is(err, alpaca.ErrInsufficientBuyingPower))
+
+	req := order("AAPL", alpaca.Buy, "1")
+	req.Type = alpaca.Limit
+	_, err = c.PlaceOrder(req)
+	var apiErr *alpaca.APIError
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, unprocessableEntityCode, apiErr.Code)
+
+	req.Type = alpaca.TrailingStop
+	_, err = c.PlaceOrder(req)
+	assert.Error(t, err)
+
+	_, err = c.GetOrder("missing")
+	assert.Error(t, err)
+	assert.Empty(t, s.Orders())
+
+	_, err = s.Client(alpaca.WithCredentials(&common.APIKey{ID: "key", Secret: "wrong"})).GetAccount()
+	require.True(t, errors.As(err, &apiErr))
+	assert.Equal(t, unauthorizedCode, apiErr.Code)
+}
@@ -0,0 +1,168 @@
+package alpaca
+
+import (
+	"sync"
+	"time"
+)
+
+// CacheTTLs are how long a CachedClient reuses the responses of each
+// endpoint. A zero TTL disables caching of the endpoint.
+type CacheTTLs struct {
+	Account time.Duration
+	Asset   time.Duration
+	Clock   time.Duration
+}
+
+// DefaultCacheTTLs are suitable for polling loops running every few
+// hundred milliseconds
+var DefaultCacheTTLs = CacheTTLs{
+	Account: time.Second,
+	Asset:   time.Minute,
+	Clock:   5 * time.Second,
+}
+
+// CachedClient is a Client that reuses the recent responses of GetAccount,
+// GetAsset and GetClock instead of requesting unchanged payloads again,
+// so polling loops don't burn the rate limit. The cached account is
+// discarded whenever the client changes orders or positions.
+type CachedClient struct {
+	*Client
+	ttls CacheTTLs
+
+	mu               sync.Mutex
+	account          *Account
+	accountFetchedAt time.Time
+	clock            *Clock
+	clockFetchedAt   time.Time
+	assets           *assetCache
+}
+
+var _ TradingClient = (*CachedClient)(nil)
+
+// NewCachedClient wraps the client with a cache using the given TTLs
+func NewCachedClient(client *Client, ttls CacheTTLs) *CachedClient {
+	return &CachedClient{
+		Client: client,
+		ttls:   ttls,
+		assets: newAssetCache(ttls.Asset),
+	}
+}
+
+// Invalidate discards all the cached responses
+func (c *CachedClient) Invalidate() {
+	c.invalidateAccount()
+
+	c.mu.Lock()
+	c.clock = nil
+	c.assets = newAssetCache(c.ttls.Asset)
+	c.mu.Unlock()
+}
+
+func (c *CachedClient) invalidateAccount() {
+	c.mu.Lock()
+	c.account = nil
+	c.mu.Unlock()
+}
+
+// GetAccount returns the cached account if it is recent enough
+func (c *CachedClient) GetAccount() (*Account, error) {
+	c.mu.Lock()
+	if c.account != nil && time.Since(c.accountFetchedAt) < c.ttls.Account {
+		acct := *c.account
+		c.mu.Unlock()
+		return &acct, nil
+	}
+	c.mu.Unlock()
+
+	acct, err := c.Client.GetAccount()
+	if err != nil {
+		return nil, err
+	}
+
+	cached := *acct
+	c.mu.Lock()
+	c.account, c.accountFetchedAt = &cached, time.Now()
+	c.mu.Unlock()
+	return acct, nil
+}
+
+// GetAsset returns the cached asset if it is recent enough
+func (c *CachedClient) GetAsset(symbol string) (*Asset, error) {
+	c.mu.Lock()
+	assets := c.assets
+	c.mu.Unlock()
+
+	if asset, ok := assets.get(symbol); ok {
+		return &asset, nil
+	}
+
+	asset, err := c.Client.GetAsset(symbol)
+	if err != nil {
+		return nil, err
+	}
+	assets.put(symbol, *asset)
+	return asset, nil
+}
+
+// GetClock returns the cached clock, with its timestamp moved forward,
+// if it is recent enough and the market has not opened or closed since.
+func (c *CachedClient) GetClock() (*Clock, error) {
+	c.mu.Lock()
+	if c.clock != nil {
+		age := time.Since(c.clockFetchedAt)
+		clock := *c.clock
+		clock.Timestamp = clock.Timestamp.Add(age)
+		if age < c.ttls.Clock && clock.Timestamp.Before(clock.NextOpen) && clock.Timestamp.Before(clock.NextClose) {
+			c.mu.Unlock()
+			return &clock, nil
+		}
+	}
+	c.mu.Unlock()
+
+	clock, err := c.Client.GetClock()
+	if err != nil {
+		return nil, err
+	}
+
+	cached := *clock
+	c.mu.Lock()
+	c.clock, c.clockFetchedAt = &cached, time.Now()
+	c.mu.Unlock()
+	return clock, nil
+}
+
+// PlaceOrder places the order and discards the cached account
+func (c *CachedClient) PlaceOrder(req PlaceOrderRequest) (*Order, error) {
+	defer c.invalidateAccount()
+	return c.Client.PlaceOrder(req)
+}
+
+// ReplaceOrder replaces the order and discards the cached account
+func (c *CachedClient) ReplaceOrder(orderID string, req ReplaceOrderRequest) (*Order, error) {
+	defer c.invalidateAccount()
+	return c.Client.ReplaceOrder(orderID, req)
+}
+
+// CancelOrder cancels the order and discards the cached account
+func (c *CachedClient) CancelOrder(orderID string) error {
+	defer c.invalidateAccount()
+	return c.Client.CancelOrder(orderID)
+}
+
+// CancelAllOrders cancels all the orders and discards the cached account
+func (c *CachedClient) CancelAllOrders() error {
+	defer c.invalidateAccount()
+	return c.Client.CancelAllOrders()
+}
+
+// ClosePosition closes the position and discards the cached account
+func (c *CachedClient) ClosePosition(symbol string) error {
+	defer c.invalidateAccount()
+	return c.Client.ClosePosition(symbol)
+}
+
+// CloseAllPositions closes all the positions and discards the cached account
+func (c *CachedClient) CloseAllPositions() error {
+	defer c.invalidateAccount()
+	return c.Client.CloseAllPositions()
+}
@@ -0,0 +1,75 @@
+package alpaca
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Checkpoint is the position of the last processed event of an events stream
+type Checkpoint struct {
+	EventID string    `json:"event_id"`
+	At      time.Time `json:"at"`
+}
+
+// Checkpointer persists the checkpoint of an events stream, so a restarted
+// process resumes the stream after the last processed event.
+type Checkpointer interface {
+	// Load returns the saved checkpoint, or nil if there is none
+	Load() (*Checkpoint, error)
+	// Save is called after the handler returned for each event
+	Save(checkpoint Checkpoint) error
+}
+
+// FileCheckpointer is a Checkpointer storing the checkpoint as JSON in a file
+type FileCheckpointer struct {
+	path string
+}
+
+// NewFileCheckpointer returns a Checkpointer storing the checkpoint in path
+func NewFileCheckpointer(path string) *FileCheckpointer {
+	return &FileCheckpointer{path: path}
+}
+
+// Load reads the checkpoint file. A missing file means no checkpoint.
+func (f *FileCheckpointer) Load() (*Checkpoint, error) {
+	b, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(b, &checkpoint); err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// Save writes the checkpoint to a temporary file and renames it, so the
+// checkpoint file is never left half written.
+func (f *FileCheckpointer) Save(checkpoint Checkpoint) error {
+	b, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,80 @@
+package alpaca
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// Corporate action types
+const (
+	CATypeDividend = "dividend"
+	CATypeMerger   = "merger"
+	CATypeSpinoff  = "spinoff"
+	CATypeSplit    = "split"
+)
+
+// ListAnnouncements returns the corporate action announcements of the types
+// of req whose req.DateType date, the ex date by default, is in the range of
+// req. The range is at most 90 days.
+func (c *Client) ListAnnouncements(req ListAnnouncementsRequest) ([]Announcement, error) {
+	if len(req.CATypes) == 0 {
+		return nil, errors.New("no corporate action types")
+	}
+	u, err := url.Parse(fmt.Sprintf("%s/%s/corporate_actions/announcements", c.baseURL(), c.version(TradingEndpoint)))
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Set("ca_types", strings.Join(req.CATypes, ","))
+	q.Set("since", req.Since.Format("2006-01-02"))
+	q.Set("until", req.Until.Format("2006-01-02"))
+	if req.Symbol != "" {
+		q.Set("symbol", req.Symbol)
+	}
+	if req.CUSIP != "" {
+		q.Set("cusip", req.CUSIP)
+	}
+	if req.DateType != "" {
+		q.Set("date_type", req.DateType)
+	}
+	u.RawQuery = q.Encode()
+
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	announcements := []Announcement{}
+
+	if err = unmarshal(resp, &announcements); err != nil {
+		return nil, err
+	}
+	return announcements, nil
+}
+
+// Concerns returns true if the announcement is about symbol, as its
+// initiating or target symbol
+func (a Announcement) Concerns(symbol string) bool {
+	return a.InitiatingSymbol == symbol || a.TargetSymbol == symbol
+}
+
+// SplitRatio returns the number of new shares per old share of a split or
+// a stock dividend, e.g. 4 for a 4:1 forward split, and false for the other
+// announcements.
+func (a Announcement) SplitRatio() (decimal.Decimal, bool) {
+	if a.OldRate.IsZero() || a.NewRate.IsZero() {
+		return decimal.Zero, false
+	}
+	return a.NewRate.Div(a.OldRate), true
+}
+
+// ListAnnouncements returns the corporate action announcements
+// with the default Alpaca client.
+func ListAnnouncements(req ListAnnouncementsRequest) ([]Announcement, error) {
+	return DefaultClient.ListAnnouncements(req)
+}
@@ -0,0 +1,85 @@
+package alpaca
+
+import (
+	"fmt"
+	"net/url"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+)
+
+// ListCryptoPairs returns the crypto trading pairs, optionally only those
+// with the given status, e.g. active, with their order size and price steps
+// and the exchanges they trade on.
+func (c *Client) ListCryptoPairs(status *string) ([]CryptoPair, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/crypto/pairs", c.dataBaseURL(), c.version(CryptoEndpoint)))
+	if err != nil {
+		return nil, err
+	}
+
+	if status != nil {
+		q := u.Query()
+		q.Set("status", *status)
+		u.RawQuery = q.Encode()
+	}
+
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var pairsResp cryptoPairsResponse
+
+	if err = unmarshal(resp, &pairsResp); err != nil {
+		return nil, err
+	}
+
+	if pairsResp.Pairs == nil {
+		return []CryptoPair{}, nil
+	}
+	return pairsResp.Pairs, nil
+}
+
+// GetCryptoSnapshot returns the snapshot of the crypto pair, with the latest
+// trade and quote on every exchange it trades on.
+func (c *Client) GetCryptoSnapshot(symbol string) (*v2.CryptoSnapshot, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/crypto/%s/snapshot",
+		c.dataBaseURL(), c.version(CryptoEndpoint), url.PathEscape(symbol)))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot v2.CryptoSnapshot
+
+	if err = unmarshal(resp, &snapshot); err != nil {
+		return nil, err
+	}
+
+	return &snapshot, nil
+}
+
+// TradesOn returns true if the pair trades on the exchange
+func (p CryptoPair) TradesOn(exchange string) bool {
+	for _, e := range p.Exchanges {
+		if e == exchange {
+			return true
+		}
+	}
+	return false
+}
+
+// ListCryptoPairs returns the crypto trading pairs
+// with the default Alpaca client.
+func ListCryptoPairs(status *string) ([]CryptoPair, error) {
+	return DefaultClient.ListCryptoPairs(status)
+}
+
+// GetCryptoSnapshot returns the snapshot of the crypto pair
+// with the default Alpaca client.
+func GetCryptoSnapshot(symbol string) (*v2.CryptoSnapshot, error) {
+	return DefaultClient.GetCryptoSnapshot(symbol)
+}
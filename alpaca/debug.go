@@ -0,0 +1,125 @@
+package alpaca
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+)
+
+// redactedHeaders are never written to the debug log
+var redactedHeaders = []string{
+	"Apca-Api-Key-Id",
+	"Apca-Api-Secret-Key",
+	"Authorization",
+}
+
+// WithDebugLogging logs the method, URL, status, latency and request ID of
+// every request the client sends to the logger, at the debug level.
+func WithDebugLogging(logger common.Logger) ClientOption {
+	return func(c *Client) {
+		c.debugLogger = logger
+	}
+}
+
+// WithDebugBodies additionally logs the headers and bodies of the requests
+// and responses when debug logging is enabled. The credential headers are
+// redacted, and the bodies of event streams and of the requests that can't
+// be read again, without http.Request.GetBody, are not logged.
+func WithDebugBodies() ClientOption {
+	return func(c *Client) {
+		c.debugBodies = true
+	}
+}
+
+// debugTransport logs the requests sent through the next transport
+type debugTransport struct {
+	next   http.RoundTripper
+	logger common.Logger
+	bodies bool
+}
+
+// RoundTrip sends req through the next transport. As a RoundTripper must
+// not modify the request, its body is logged from a copy of GetBody.
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	requestID := req.Header.Get(RequestIDHeader)
+	if t.bodies {
+		body, err := requestBody(req)
+		if err != nil {
+			return nil, err
+		}
+		t.logger.Debug("request sent", "client", "rest", "method", req.Method, "url", req.URL,
+			"request_id", requestID, "headers", formatHeaders(req.Header), "body", string(body))
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	latency := time.Since(start)
+	if err != nil {
+		t.logger.Debug("request failed", "client", "rest", "method", req.Method, "url", req.URL,
+			"request_id", requestID, "latency", latency, "error", err)
+		return nil, err
+	}
+
+	if !t.bodies {
+		t.logger.Debug("response received", "client", "rest", "method", req.Method, "url", req.URL,
+			"request_id", requestID, "status", resp.StatusCode, "latency", latency)
+		return resp, nil
+	}
+
+	var body []byte
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		if body, err = drainBody(&resp.Body); err != nil {
+			return nil, err
+		}
+	}
+	t.logger.Debug("response received", "client", "rest", "method", req.Method, "url", req.URL,
+		"request_id", requestID, "status", resp.StatusCode, "latency", latency,
+		"headers", formatHeaders(resp.Header), "body", string(body))
+	return resp, nil
+}
+
+// requestBody returns a copy of the body of req, nil if it has none or if it
+// can't be read again without GetBody
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody || req.GetBody == nil {
+		return nil, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// drainBody reads the body and replaces it with an identical unread one
+func drainBody(b *io.ReadCloser) ([]byte, error) {
+	if *b == nil || *b == http.NoBody {
+		return nil, nil
+	}
+	defer (*b).Close()
+
+	body, err := ioutil.ReadAll(*b)
+	if err != nil {
+		return nil, err
+	}
+	*b = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func formatHeaders(h http.Header) string {
+	h = h.Clone()
+	for _, name := range redactedHeaders {
+		if h.Get(name) != "" {
+			h.Set(name, "REDACTED")
+		}
+	}
+	var buf bytes.Buffer
+	h.Write(&buf)
+	return buf.String()
+}
@@ -1,6 +1,7 @@
 package alpaca
 
 import (
+	"encoding/json"
 	"time"
 
 	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
@@ -8,33 +9,50 @@ import (
 )
 
 type Account struct {
-	ID                    string          `json:"id"`
-	AccountNumber         string          `json:"account_number"`
-	CreatedAt             time.Time       `json:"created_at"`
-	UpdatedAt             time.Time       `json:"updated_at"`
-	DeletedAt             *time.Time      `json:"deleted_at"`
-	Status                string          `json:"status"`
-	Currency              string          `json:"currency"`
-	Cash                  decimal.Decimal `json:"cash"`
-	CashWithdrawable      decimal.Decimal `json:"cash_withdrawable"`
-	TradingBlocked        bool            `json:"trading_blocked"`
-	TransfersBlocked      bool            `json:"transfers_blocked"`
-	AccountBlocked        bool            `json:"account_blocked"`
-	ShortingEnabled       bool            `json:"shorting_enabled"`
-	BuyingPower           decimal.Decimal `json:"buying_power"`
-	PatternDayTrader      bool            `json:"pattern_day_trader"`
-	DaytradeCount         int64           `json:"daytrade_count"`
-	DaytradingBuyingPower decimal.Decimal `json:"daytrading_buying_power"`
-	RegTBuyingPower       decimal.Decimal `json:"regt_buying_power"`
-	Equity                decimal.Decimal `json:"equity"`
-	LastEquity            decimal.Decimal `json:"last_equity"`
-	Multiplier            string          `json:"multiplier"`
-	InitialMargin         decimal.Decimal `json:"initial_margin"`
-	MaintenanceMargin     decimal.Decimal `json:"maintenance_margin"`
-	LastMaintenanceMargin decimal.Decimal `json:"last_maintenance_margin"`
-	LongMarketValue       decimal.Decimal `json:"long_market_value"`
-	ShortMarketValue      decimal.Decimal `json:"short_market_value"`
-	PortfolioValue        decimal.Decimal `json:"portfolio_value"`
+	ID                       string          `json:"id"`
+	AccountNumber            string          `json:"account_number"`
+	CreatedAt                time.Time       `json:"created_at"`
+	UpdatedAt                time.Time       `json:"updated_at"`
+	DeletedAt                *time.Time      `json:"deleted_at"`
+	Status                   string          `json:"status"`
+	Currency                 string          `json:"currency"`
+	Cash                     decimal.Decimal `json:"cash"`
+	CashWithdrawable         decimal.Decimal `json:"cash_withdrawable"`
+	TradingBlocked           bool            `json:"trading_blocked"`
+	TransfersBlocked         bool            `json:"transfers_blocked"`
+	AccountBlocked           bool            `json:"account_blocked"`
+	ShortingEnabled          bool            `json:"shorting_enabled"`
+	BuyingPower              decimal.Decimal `json:"buying_power"`
+	PatternDayTrader         bool            `json:"pattern_day_trader"`
+	DaytradeCount            int64           `json:"daytrade_count"`
+	DaytradingBuyingPower    decimal.Decimal `json:"daytrading_buying_power"`
+	RegTBuyingPower          decimal.Decimal `json:"regt_buying_power"`
+	NonMarginableBuyingPower decimal.Decimal `json:"non_marginable_buying_power"`
+	Equity                   decimal.Decimal `json:"equity"`
+	LastEquity               decimal.Decimal `json:"last_equity"`
+	Multiplier               decimal.Decimal `json:"multiplier"`
+	InitialMargin            decimal.Decimal `json:"initial_margin"`
+	MaintenanceMargin        decimal.Decimal `json:"maintenance_margin"`
+	LastMaintenanceMargin    decimal.Decimal `json:"last_maintenance_margin"`
+	SMA                      decimal.Decimal `json:"sma"`
+	LongMarketValue          decimal.Decimal `json:"long_market_value"`
+	ShortMarketValue         decimal.Decimal `json:"short_market_value"`
+	PortfolioValue           decimal.Decimal `json:"portfolio_value"`
+	AccruedFees              decimal.Decimal `json:"accrued_fees"`
+	PendingTransferIn        decimal.Decimal `json:"pending_transfer_in"`
+	PendingTransferOut       decimal.Decimal `json:"pending_transfer_out"`
+	// Raw is the JSON the account was decoded from, including the fields
+	// not (yet) known by this package
+	Raw json.RawMessage `json:"-"`
+}
+
+func (a *Account) UnmarshalJSON(data []byte) error {
+	type alias Account
+	if err := json.Unmarshal(data, (*alias)(a)); err != nil {
+		return err
+	}
+	a.Raw = append(json.RawMessage(nil), data...)
+	return nil
 }
 
 type Order struct {
@@ -66,27 +84,107 @@ type Order struct {
 	TrailPrice     *decimal.Decimal `json:"trail_price"`
 	TrailPercent   *decimal.Decimal `json:"trail_percent"`
 	Hwm            *decimal.Decimal `json:"hwm"`
-	Status         string           `json:"status"`
+	Status         OrderStatus      `json:"status"`
 	ExtendedHours  bool             `json:"extended_hours"`
-	Legs           *[]Order         `json:"legs"`
+	OrderClass     OrderClass       `json:"order_class"`
+	RatioQty       *decimal.Decimal `json:"ratio_qty"`
+	Commission     *decimal.Decimal `json:"commission"`
+	CommissionType CommissionType   `json:"commission_type"`
+	// FilledFee is the fee charged for the fills of a crypto order, in
+	// FeeCurrency
+	FilledFee   *decimal.Decimal `json:"filled_fee"`
+	FeeCurrency string           `json:"fee_currency"`
+	Legs        *[]Order         `json:"legs"`
+	// Raw is the JSON the order was decoded from, including the fields
+	// not (yet) known by this package
+	Raw json.RawMessage `json:"-"`
+}
+
+// TakeProfitLeg returns the take profit leg of a bracket or OCO order
+// queried with its legs, or nil if it has none.
+func (o *Order) TakeProfitLeg() *Order {
+	return o.findLeg(Limit)
+}
+
+// StopLossLeg returns the stop loss leg of a bracket or OCO order
+// queried with its legs, or nil if it has none.
+func (o *Order) StopLossLeg() *Order {
+	return o.findLeg(Stop, StopLimit)
+}
+
+func (o *Order) findLeg(types ...OrderType) *Order {
+	if o.Legs == nil {
+		return nil
+	}
+	for i := range *o.Legs {
+		leg := &(*o.Legs)[i]
+		for _, t := range types {
+			if leg.Type == t {
+				return leg
+			}
+		}
+	}
+	return nil
+}
+
+func (o *Order) UnmarshalJSON(data []byte) error {
+	type alias Order
+	if err := json.Unmarshal(data, (*alias)(o)); err != nil {
+		return err
+	}
+	o.Raw = append(json.RawMessage(nil), data...)
+	return nil
 }
 
 type Position struct {
-	AssetID        string          `json:"asset_id"`
-	Symbol         string          `json:"symbol"`
-	Exchange       string          `json:"exchange"`
-	Class          string          `json:"asset_class"`
-	AccountID      string          `json:"account_id"`
-	EntryPrice     decimal.Decimal `json:"avg_entry_price"`
-	Qty            decimal.Decimal `json:"qty"`
-	Side           string          `json:"side"`
-	MarketValue    decimal.Decimal `json:"market_value"`
-	CostBasis      decimal.Decimal `json:"cost_basis"`
-	UnrealizedPL   decimal.Decimal `json:"unrealized_pl"`
-	UnrealizedPLPC decimal.Decimal `json:"unrealized_plpc"`
-	CurrentPrice   decimal.Decimal `json:"current_price"`
-	LastdayPrice   decimal.Decimal `json:"lastday_price"`
-	ChangeToday    decimal.Decimal `json:"change_today"`
+	AssetID                string          `json:"asset_id"`
+	Symbol                 string          `json:"symbol"`
+	Exchange               string          `json:"exchange"`
+	Class                  string          `json:"asset_class"`
+	AccountID              string          `json:"account_id"`
+	EntryPrice             decimal.Decimal `json:"avg_entry_price"`
+	Qty                    decimal.Decimal `json:"qty"`
+	QtyAvailable           decimal.Decimal `json:"qty_available"`
+	Side                   string          `json:"side"`
+	MarketValue            decimal.Decimal `json:"market_value"`
+	CostBasis              decimal.Decimal `json:"cost_basis"`
+	UnrealizedPL           decimal.Decimal `json:"unrealized_pl"`
+	UnrealizedPLPC         decimal.Decimal `json:"unrealized_plpc"`
+	UnrealizedIntradayPL   decimal.Decimal `json:"unrealized_intraday_pl"`
+	UnrealizedIntradayPLPC decimal.Decimal `json:"unrealized_intraday_plpc"`
+	CurrentPrice           decimal.Decimal `json:"current_price"`
+	LastdayPrice           decimal.Decimal `json:"lastday_price"`
+	ChangeToday            decimal.Decimal `json:"change_today"`
+	// Raw is the JSON the position was decoded from, including the fields
+	// not (yet) known by this package
+	Raw json.RawMessage `json:"-"`
+}
+
+func (p *Position) UnmarshalJSON(data []byte) error {
+	type alias Position
+	if err := json.Unmarshal(data, (*alias)(p)); err != nil {
+		return err
+	}
+	p.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// IsOption returns true if the position is in an option contract
+func (p Position) IsOption() bool {
+	return p.Class == USOption
+}
+
+// MarketValueDelta returns how much the market value of the position
+// changes if the price moves from the current price to the given one.
+// It is negative for short positions when the price rises.
+func (p Position) MarketValueDelta(price decimal.Decimal) decimal.Decimal {
+	return price.Sub(p.CurrentPrice).Mul(p.Qty)
+}
+
+// MarketValueChangeToday returns the change of the market value of the
+// position since the previous close, at the current price.
+func (p Position) MarketValueChangeToday() decimal.Decimal {
+	return p.CurrentPrice.Sub(p.LastdayPrice).Mul(p.Qty)
 }
 
 type Asset struct {
@@ -100,6 +198,7 @@ type Asset struct {
 	Marginable   bool   `json:"marginable"`
 	Shortable    bool   `json:"shortable"`
 	EasyToBorrow bool   `json:"easy_to_borrow"`
+	Fractionable bool   `json:"fractionable"`
 }
 
 type Fundamental struct {
@@ -223,6 +322,11 @@ type barResponse struct {
 	Bars          []v2.Bar `json:"bars"`
 }
 
+type multiBarResponse struct {
+	NextPageToken *string             `json:"next_page_token"`
+	Bars          map[string][]v2.Bar `json:"bars"`
+}
+
 type latestTradeResponse struct {
 	Symbol string   `json:"symbol"`
 	Trade  v2.Trade `json:"trade"`
@@ -233,6 +337,41 @@ type latestQuoteResponse struct {
 	Quote  v2.Quote `json:"quote"`
 }
 
+// ListAnnouncementsRequest filters the corporate action announcements
+type ListAnnouncementsRequest struct {
+	// CATypes are the types of the announcements, e.g. CATypeSplit
+	CATypes []string
+	// Since and Until are the dates of the range, at most 90 days apart
+	Since time.Time
+	Until time.Time
+	// Symbol and CUSIP optionally filter the announcements
+	Symbol string
+	CUSIP  string
+	// DateType is the date in the range: declaration_date, ex_date,
+	// record_date or payable_date. The ex date is the default.
+	DateType string
+}
+
+// Announcement is a corporate action announcement. Its dates are formatted
+// as 2006-01-02.
+type Announcement struct {
+	ID                      string          `json:"id"`
+	CorporateActionID       string          `json:"corporate_action_id"`
+	CAType                  string          `json:"ca_type"`
+	CASubType               string          `json:"ca_sub_type"`
+	InitiatingSymbol        string          `json:"initiating_symbol"`
+	InitiatingOriginalCUSIP string          `json:"initiating_original_cusip"`
+	TargetSymbol            string          `json:"target_symbol"`
+	TargetOriginalCUSIP     string          `json:"target_original_cusip"`
+	DeclarationDate         string          `json:"declaration_date"`
+	ExDate                  string          `json:"ex_date"`
+	RecordDate              string          `json:"record_date"`
+	PayableDate             string          `json:"payable_date"`
+	Cash                    decimal.Decimal `json:"cash"`
+	OldRate                 decimal.Decimal `json:"old_rate"`
+	NewRate                 decimal.Decimal `json:"new_rate"`
+}
+
 type CalendarDay struct {
 	Date  string `json:"date"`
 	Open  string `json:"open"`
@@ -268,6 +407,32 @@ type AccountActivity struct {
 	NetAmount       decimal.Decimal `json:"net_amount"`
 	Description     string          `json:"description"`
 	PerShareAmount  decimal.Decimal `json:"per_share_amount"`
+	Status          string          `json:"status"`
+	OrderID         string          `json:"order_id"`
+}
+
+// UnmarshalJSON accepts the date-only dates of the non-trade activities
+func (a *AccountActivity) UnmarshalJSON(data []byte) error {
+	type alias AccountActivity
+	aux := struct {
+		*alias
+		Date string `json:"date"`
+	}{alias: (*alias)(a)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.Date == "" {
+		return nil
+	}
+
+	date, err := time.Parse("2006-01-02", aux.Date)
+	if err != nil {
+		if date, err = time.Parse(time.RFC3339, aux.Date); err != nil {
+			return err
+		}
+	}
+	a.Date = date
+	return nil
 }
 
 type PortfolioHistory struct {
@@ -284,7 +449,7 @@ type PlaceOrderRequest struct {
 	AssetKey      *string          `json:"symbol"`
 	Qty           decimal.Decimal  `json:"qty"`
 	Notional      decimal.Decimal  `json:"notional"`
-	Side          Side             `json:"side"`
+	Side          Side             `json:"side,omitempty"`
 	Type          OrderType        `json:"type"`
 	TimeInForce   TimeInForce      `json:"time_in_force"`
 	LimitPrice    *decimal.Decimal `json:"limit_price"`
@@ -296,12 +461,26 @@ type PlaceOrderRequest struct {
 	StopLoss      *StopLoss        `json:"stop_loss"`
 	TrailPrice    *decimal.Decimal `json:"trail_price"`
 	TrailPercent  *decimal.Decimal `json:"trail_percent"`
+	Legs          []OrderLeg       `json:"legs,omitempty"`
+}
+
+// OrderLeg is a leg of a multi-leg (Mleg) order. The quantity of the leg
+// is its ratio multiplied by the quantity of the order.
+type OrderLeg struct {
+	Symbol   string          `json:"symbol"`
+	Side     Side            `json:"side"`
+	RatioQty decimal.Decimal `json:"ratio_qty"`
 }
 
+// TakeProfit is the limit order leg of a bracket, OCO or OTO order taking
+// the profit of the position, see NewTakeProfit
 type TakeProfit struct {
 	LimitPrice *decimal.Decimal `json:"limit_price"`
 }
 
+// StopLoss is the stop or stop limit order leg of a bracket, OCO or OTO
+// order limiting the loss of the position, see NewStopLoss and
+// NewStopLimitLoss
 type StopLoss struct {
 	LimitPrice *decimal.Decimal `json:"limit_price"`
 	StopPrice  *decimal.Decimal `json:"stop_price"`
@@ -355,6 +534,18 @@ const (
 	TrailingStop OrderType = "trailing_stop"
 )
 
+// CommissionType is how the commission of an order is charged
+type CommissionType string
+
+const (
+	// NotionalCommission is charged per order
+	NotionalCommission CommissionType = "notional"
+	// QtyCommission is charged per share or contract
+	QtyCommission CommissionType = "qty"
+	// BpsCommission is charged in basis points of the order value
+	BpsCommission CommissionType = "bps"
+)
+
 type OrderClass string
 
 const (
@@ -362,6 +553,9 @@ const (
 	Oto     OrderClass = "oto"
 	Oco     OrderClass = "oco"
 	Simple  OrderClass = "simple"
+	// Mleg is a multi-leg option order, e.g. a spread or a straddle, whose
+	// legs are submitted and filled atomically
+	Mleg OrderClass = "mleg"
 )
 
 type TimeInForce string
@@ -377,6 +571,78 @@ const (
 	CLS TimeInForce = "cls"
 )
 
+// OrderStatus is the status of an order as reported by the API
+type OrderStatus string
+
+const (
+	OrderNew                OrderStatus = "new"
+	OrderPartiallyFilled    OrderStatus = "partially_filled"
+	OrderFilled             OrderStatus = "filled"
+	OrderDoneForDay         OrderStatus = "done_for_day"
+	OrderCanceled           OrderStatus = "canceled"
+	OrderExpired            OrderStatus = "expired"
+	OrderReplaced           OrderStatus = "replaced"
+	OrderPendingCancel      OrderStatus = "pending_cancel"
+	OrderPendingReplace     OrderStatus = "pending_replace"
+	OrderAccepted           OrderStatus = "accepted"
+	OrderPendingNew         OrderStatus = "pending_new"
+	OrderAcceptedForBidding OrderStatus = "accepted_for_bidding"
+	OrderStopped            OrderStatus = "stopped"
+	OrderRejected           OrderStatus = "rejected"
+	OrderSuspended          OrderStatus = "suspended"
+	OrderCalculated         OrderStatus = "calculated"
+)
+
+// IsTerminal returns true if an order with this status can no longer change.
+func (s OrderStatus) IsTerminal() bool {
+	switch s {
+	case OrderFilled, OrderCanceled, OrderExpired, OrderReplaced, OrderRejected:
+		return true
+	}
+	return false
+}
+
+// IsOpen returns true if an order with this status may still be filled.
+func (s OrderStatus) IsOpen() bool {
+	return !s.IsTerminal() && s != OrderDoneForDay
+}
+
+// TradeEvent is the event type of a trade update
+type TradeEvent string
+
+const (
+	EventNew                  TradeEvent = "new"
+	EventFill                 TradeEvent = "fill"
+	EventPartialFill          TradeEvent = "partial_fill"
+	EventCanceled             TradeEvent = "canceled"
+	EventExpired              TradeEvent = "expired"
+	EventDoneForDay           TradeEvent = "done_for_day"
+	EventReplaced             TradeEvent = "replaced"
+	EventRejected             TradeEvent = "rejected"
+	EventPendingNew           TradeEvent = "pending_new"
+	EventStopped              TradeEvent = "stopped"
+	EventPendingCancel        TradeEvent = "pending_cancel"
+	EventPendingReplace       TradeEvent = "pending_replace"
+	EventCalculated           TradeEvent = "calculated"
+	EventSuspended            TradeEvent = "suspended"
+	EventOrderReplaceRejected TradeEvent = "order_replace_rejected"
+	EventOrderCancelRejected  TradeEvent = "order_cancel_rejected"
+)
+
+// IsFill returns true for full and partial fills.
+func (e TradeEvent) IsFill() bool {
+	return e == EventFill || e == EventPartialFill
+}
+
+// IsTerminal returns true if the event moves the order into a final state.
+func (e TradeEvent) IsTerminal() bool {
+	switch e {
+	case EventFill, EventCanceled, EventExpired, EventReplaced, EventRejected:
+		return true
+	}
+	return false
+}
+
 type DtbpCheck string
 
 const (
@@ -418,8 +684,215 @@ type ServerMsg struct {
 }
 
 type TradeUpdate struct {
-	Event string `json:"event"`
-	Order Order  `json:"order"`
+	Event TradeEvent `json:"event"`
+	Order Order      `json:"order"`
+	// Fee is the fee charged for the fill of a crypto order the update
+	// reports, in FeeCurrency
+	Fee         *decimal.Decimal `json:"fee"`
+	FeeCurrency string           `json:"fee_currency"`
+}
+
+// StreamEventsRequest contains the optional parameters of the
+// server-sent events endpoints
+type StreamEventsRequest struct {
+	// Since replays the events that happened since the given time
+	Since *time.Time
+	// Until ends the stream after the given time
+	Until *time.Time
+	// SinceID replays the events following the given event ID
+	SinceID *string
+	// Checkpointer, if set, saves the position of every handled event. When
+	// neither Since nor SinceID is set the stream resumes after the saved event.
+	Checkpointer Checkpointer
+}
+
+// TradeUpdateEvent is a trade update received from the /events/trades endpoint
+type TradeUpdateEvent struct {
+	EventID     string          `json:"event_id"`
+	At          time.Time       `json:"at"`
+	AccountID   string          `json:"account_id"`
+	Event       TradeEvent      `json:"event"`
+	ExecutionID string          `json:"execution_id"`
+	Order       Order           `json:"order"`
+	Timestamp   time.Time       `json:"timestamp"`
+	Price       decimal.Decimal `json:"price"`
+	Qty         decimal.Decimal `json:"qty"`
+	PositionQty decimal.Decimal `json:"position_qty"`
+	// Fee is the fee charged for the fill of a crypto order the event
+	// reports, in FeeCurrency
+	Fee         *decimal.Decimal `json:"fee"`
+	FeeCurrency string           `json:"fee_currency"`
+}
+
+// NonTradeActivityEvent is a non-trade activity (dividend, fee, transfer, ...)
+// received from the /events/nta endpoint
+type NonTradeActivityEvent struct {
+	EventID     string          `json:"event_id"`
+	At          time.Time       `json:"at"`
+	AccountID   string          `json:"account_id"`
+	ID          string          `json:"id"`
+	EntryType   string          `json:"entry_type"`
+	Symbol      string          `json:"symbol"`
+	NetAmount   decimal.Decimal `json:"net_amount"`
+	Qty         decimal.Decimal `json:"qty"`
+	Price       decimal.Decimal `json:"price"`
+	Description string          `json:"description"`
+	Status      string          `json:"status"`
+	SettleDate  string          `json:"settle_date"`
+	SystemDate  string          `json:"system_date"`
+}
+
+// CryptoWallet is a crypto funding wallet of the account
+type CryptoWallet struct {
+	Asset     string    `json:"asset"`
+	Chain     string    `json:"chain"`
+	Address   string    `json:"address"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WhitelistedAddress is an address crypto withdrawals can be sent to
+type WhitelistedAddress struct {
+	ID        string    `json:"id"`
+	Asset     string    `json:"asset"`
+	Chain     string    `json:"chain"`
+	Address   string    `json:"address"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateWhitelistedAddressRequest struct {
+	Address string `json:"address"`
+	Asset   string `json:"asset"`
+}
+
+type CryptoTransferDirection string
+
+const (
+	IncomingTransfer CryptoTransferDirection = "INCOMING"
+	OutgoingTransfer CryptoTransferDirection = "OUTGOING"
+)
+
+type CryptoTransferStatus string
+
+const (
+	TransferProcessing CryptoTransferStatus = "PROCESSING"
+	TransferFailed     CryptoTransferStatus = "FAILED"
+	TransferComplete   CryptoTransferStatus = "COMPLETE"
+)
+
+// IsTerminal returns true if a transfer with this status can no longer
+// change.
+func (s CryptoTransferStatus) IsTerminal() bool {
+	return s == TransferFailed || s == TransferComplete
+}
+
+// CryptoTransfer is a crypto deposit to or withdrawal from a wallet
+type CryptoTransfer struct {
+	ID          string                  `json:"id"`
+	TxHash      string                  `json:"tx_hash"`
+	Direction   CryptoTransferDirection `json:"direction"`
+	Status      CryptoTransferStatus    `json:"status"`
+	Amount      decimal.Decimal         `json:"amount"`
+	USDValue    decimal.Decimal         `json:"usd_value"`
+	NetworkFee  decimal.Decimal         `json:"network_fee"`
+	Fees        decimal.Decimal         `json:"fees"`
+	Chain       string                  `json:"chain"`
+	Asset       string                  `json:"asset"`
+	FromAddress string                  `json:"from_address"`
+	ToAddress   string                  `json:"to_address"`
+	CreatedAt   time.Time               `json:"created_at"`
+}
+
+// CreateCryptoTransferRequest withdraws the amount of the asset
+// to a whitelisted address
+type CreateCryptoTransferRequest struct {
+	Amount  decimal.Decimal `json:"amount"`
+	Address string          `json:"address"`
+	Asset   string          `json:"asset"`
+}
+
+// CryptoPair is a crypto trading pair, e.g. BTC/USD
+type CryptoPair struct {
+	Symbol        string `json:"symbol"`
+	BaseCurrency  string `json:"base_currency"`
+	QuoteCurrency string `json:"quote_currency"`
+	// Status is active for the pairs that can be traded
+	Status string `json:"status"`
+	// MinOrderSize is the smallest quantity of the base currency of an order
+	MinOrderSize decimal.Decimal `json:"min_order_size"`
+	// MinTradeIncrement is the step of the quantities of the orders
+	MinTradeIncrement decimal.Decimal `json:"min_trade_increment"`
+	// PriceIncrement is the step of the limit and stop prices of the orders
+	PriceIncrement decimal.Decimal `json:"price_increment"`
+	// Exchanges are the exchanges the pair trades on, e.g. CBSE or ERSX
+	Exchanges []string `json:"exchanges"`
+}
+
+type cryptoPairsResponse struct {
+	Pairs []CryptoPair `json:"pairs"`
+}
+
+// Asset classes of assets and positions
+const (
+	USEquity = "us_equity"
+	USOption = "us_option"
+	Crypto   = "crypto"
+)
+
+type OptionType string
+
+const (
+	Call OptionType = "call"
+	Put  OptionType = "put"
+)
+
+type OptionStyle string
+
+const (
+	American OptionStyle = "american"
+	European OptionStyle = "european"
+)
+
+// OptionContract is an option contract that can be traded
+type OptionContract struct {
+	ID                string          `json:"id"`
+	Symbol            string          `json:"symbol"`
+	Name              string          `json:"name"`
+	Status            string          `json:"status"`
+	Tradable          bool            `json:"tradable"`
+	ExpirationDate    string          `json:"expiration_date"`
+	RootSymbol        string          `json:"root_symbol"`
+	UnderlyingSymbol  string          `json:"underlying_symbol"`
+	UnderlyingAssetID string          `json:"underlying_asset_id"`
+	Type              OptionType      `json:"type"`
+	Style             OptionStyle     `json:"style"`
+	StrikePrice       decimal.Decimal `json:"strike_price"`
+	Multiplier        decimal.Decimal `json:"multiplier"`
+	Size              decimal.Decimal `json:"size"`
+	OpenInterest      decimal.Decimal `json:"open_interest"`
+	OpenInterestDate  string          `json:"open_interest_date"`
+	ClosePrice        decimal.Decimal `json:"close_price"`
+	ClosePriceDate    string          `json:"close_price_date"`
+}
+
+// GetOptionContractsRequest filters the option contracts returned by
+// GetOptionContracts. Dates are formatted as YYYY-MM-DD.
+type GetOptionContractsRequest struct {
+	UnderlyingSymbols []string
+	Status            string
+	ExpirationDate    string
+	ExpirationDateGTE string
+	ExpirationDateLTE string
+	RootSymbol        string
+	Type              OptionType
+	Style             OptionStyle
+	StrikePriceGTE    *decimal.Decimal
+	StrikePriceLTE    *decimal.Decimal
+}
+
+type optionContractsResponse struct {
+	OptionContracts []OptionContract `json:"option_contracts"`
+	NextPageToken   *string          `json:"next_page_token"`
 }
 
 type StreamAgg struct {
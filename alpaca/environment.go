@@ -0,0 +1,73 @@
+package alpaca
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+)
+
+// Environment is the trading environment a client sends its orders to
+type Environment string
+
+const (
+	// Paper is the paper trading environment, where orders are simulated
+	Paper Environment = "paper"
+	// Live is the live trading environment, where orders are executed with real money
+	Live Environment = "live"
+)
+
+const (
+	paperURL = "https://paper-api.alpaca.markets"
+	liveURL  = "https://api.alpaca.markets"
+
+	// key ID prefixes of the API keys issued for each environment
+	paperKeyPrefix = "PK"
+	liveKeyPrefix  = "AK"
+)
+
+// NewClientForEnv creates a new Alpaca client pointing at the given environment.
+// It refuses API keys that were obviously issued for the other environment,
+// those of the environment being checked if credentials is nil.
+func NewClientForEnv(env Environment, credentials *common.APIKey) (*Client, error) {
+	var u, prefix string
+	switch env {
+	case Paper:
+		u, prefix = paperURL, paperKeyPrefix
+	case Live:
+		u, prefix = liveURL, liveKeyPrefix
+	default:
+		return nil, fmt.Errorf("unknown environment: %s", env)
+	}
+
+	key := credentials
+	if key == nil {
+		key = common.Credentials()
+	}
+	// OAuth tokens can be used in both environments
+	if key.OAuth == "" && !strings.HasPrefix(key.ID, prefix) {
+		if strings.HasPrefix(key.ID, paperKeyPrefix) || strings.HasPrefix(key.ID, liveKeyPrefix) {
+			return nil, fmt.Errorf("API key %s... does not belong to the %s environment",
+				key.ID[:len(prefix)], env)
+		}
+	}
+
+	return NewClient(credentials, WithBaseURL(u)), nil
+}
+
+// Environment returns the environment the client points at, or an empty
+// string if it uses a custom URL (e.g. a proxy or a mock server).
+func (c *Client) Environment() Environment {
+	u, err := url.Parse(c.baseURL())
+	if err != nil {
+		return ""
+	}
+	switch u.Host {
+	case "paper-api.alpaca.markets":
+		return Paper
+	case "api.alpaca.markets":
+		return Live
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package alpaca
+
+import (
+	"errors"
+	"strings"
+)
+
+// Sentinel errors for common trading failures. Errors returned by the
+// API match them with errors.Is, e.g.
+//
+//	if errors.Is(err, alpaca.ErrInsufficientBuyingPower) { ... }
+var (
+	ErrInsufficientBuyingPower = errors.New("insufficient buying power")
+	ErrOrderNotCancelable      = errors.New("order is not cancelable")
+	ErrAssetNotTradable        = errors.New("asset is not tradable")
+	ErrWashTradeBlocked        = errors.New("potential wash trade detected")
+	ErrPositionNotFound        = errors.New("position not found")
+)
+
+// API error codes. The API shares codes between several failures,
+// so the sentinels are told apart by their message as well.
+const (
+	forbiddenCode           = 40310000
+	positionNotFoundCode    = 40410000
+	unprocessableEntityCode = 42210000
+)
+
+var apiErrorSentinels = []struct {
+	code    int
+	message string
+	err     error
+}{
+	{forbiddenCode, "insufficient buying power", ErrInsufficientBuyingPower},
+	{forbiddenCode, "wash trade", ErrWashTradeBlocked},
+	{unprocessableEntityCode, "not cancelable", ErrOrderNotCancelable},
+	{unprocessableEntityCode, "not tradable", ErrAssetNotTradable},
+	{forbiddenCode, "not tradable", ErrAssetNotTradable},
+	{positionNotFoundCode, "", ErrPositionNotFound},
+}
+
+// Is reports whether the error matches one of the sentinel errors
+func (e *APIError) Is(target error) bool {
+	msg := strings.ToLower(e.Message)
+	for _, s := range apiErrorSentinels {
+		if s.err == target && s.code == e.Code && strings.Contains(msg, s.message) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package alpaca
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+)
+
+var (
+	// eventsReconnectDelay is the delay before the first reconnection of an
+	// events stream, doubled after each failed one up to
+	// maxEventsReconnectDelay
+	eventsReconnectDelay    = time.Second
+	maxEventsReconnectDelay = time.Minute
+	doStream                = defaultDoStream
+)
+
+func defaultDoStream(c *Client, req *http.Request) (*http.Response, error) {
+	setRequestID(req)
+	req.Header.Set("User-Agent", common.UserAgent(c.application))
+	if err := c.setAuthHeaders(req); err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	// no client timeout: the stream is long lived and ends with the request's context
+	client := &http.Client{}
+	if c.httpClient != nil {
+		client.Transport = c.httpClient.Transport
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = verify(resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// StreamTradeUpdateEvents connects to the trade events endpoint and calls the handler
+// for every trade update until the context is canceled or, if req.Until is set,
+// the server ends the stream. The connection is automatically re-established
+// after network errors, server errors and rate limits, replaying the events
+// since the last received one. The other API errors, e.g. of authentication,
+// end the stream.
+func (c *Client) StreamTradeUpdateEvents(
+	ctx context.Context, req StreamEventsRequest, handler func(event TradeUpdateEvent),
+) error {
+	return c.streamEvents(ctx, "trades", req, func(data []byte) error {
+		var event TradeUpdateEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return err
+		}
+		handler(event)
+		return nil
+	})
+}
+
+// StreamNonTradeActivityEvents connects to the non-trade activity events endpoint
+// and calls the handler for every activity until the context is canceled or,
+// if req.Until is set, the server ends the stream. The connection is automatically
+// re-established after network errors, server errors and rate limits, replaying
+// the events since the last received one. The other API errors end the stream.
+func (c *Client) StreamNonTradeActivityEvents(
+	ctx context.Context, req StreamEventsRequest, handler func(event NonTradeActivityEvent),
+) error {
+	return c.streamEvents(ctx, "nta", req, func(data []byte) error {
+		var event NonTradeActivityEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return err
+		}
+		handler(event)
+		return nil
+	})
+}
+
+// eventMeta contains the fields common to all events that are used
+// to resume a stream after reconnecting
+type eventMeta struct {
+	EventID string    `json:"event_id"`
+	At      time.Time `json:"at"`
+}
+
+// streamEvents reads the given events endpoint and passes the data of every
+// event to handle. After a reconnect the stream resumes from the time of the
+// last received event, skipping the events that were already handled.
+func (c *Client) streamEvents(
+	ctx context.Context, endpoint string, req StreamEventsRequest, handle func(data []byte) error,
+) error {
+	since, sinceID := req.Since, req.SinceID
+	if req.Checkpointer != nil && since == nil && sinceID == nil {
+		checkpoint, err := req.Checkpointer.Load()
+		if err != nil {
+			return err
+		}
+		if checkpoint != nil {
+			sinceID = &checkpoint.EventID
+		}
+	}
+	// IDs of the already handled events at the since time
+	seen := map[string]bool{}
+	delay := eventsReconnectDelay
+
+	for {
+		u, err := url.Parse(fmt.Sprintf("%s/%s/events/%s", c.baseURL(), c.version(EventsEndpoint), endpoint))
+		if err != nil {
+			return err
+		}
+
+		q := u.Query()
+		if since != nil {
+			q.Set("since", since.Format(time.RFC3339Nano))
+		}
+		if req.Until != nil {
+			q.Set("until", req.Until.Format(time.RFC3339Nano))
+		}
+		if sinceID != nil {
+			q.Set("since_id", *sinceID)
+		}
+		u.RawQuery = q.Encode()
+
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := doStream(c, httpReq)
+		if err == nil {
+			delay = eventsReconnectDelay
+			err = readEvents(resp.Body, func(data []byte) {
+				var meta eventMeta
+				if err := json.Unmarshal(data, &meta); err != nil {
+					c.logger().Warn("invalid event", "client", "events_stream", "endpoint", endpoint, "error", err)
+					return
+				}
+				if since != nil && meta.At.Equal(*since) {
+					if seen[meta.EventID] {
+						return
+					}
+				} else {
+					since = &meta.At
+					seen = map[string]bool{}
+				}
+				seen[meta.EventID] = true
+				// from now on the time of the last event is the resume point
+				sinceID = nil
+
+				if err := handle(data); err != nil {
+					c.logger().Warn("invalid event", "client", "events_stream", "endpoint", endpoint, "error", err)
+				}
+				if req.Checkpointer != nil {
+					checkpoint := Checkpoint{EventID: meta.EventID, At: meta.At}
+					if err := req.Checkpointer.Save(checkpoint); err != nil {
+						c.logger().Error("failed to save checkpoint",
+							"client", "events_stream", "endpoint", endpoint, "event_id", meta.EventID, "error", err)
+					}
+				}
+			})
+			resp.Body.Close()
+			if err == nil && req.Until != nil {
+				// the server ends the stream after the until time
+				return nil
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && !retryable(apiErr) {
+			return err
+		}
+		if err != nil {
+			c.logger().Warn("stream error, reconnecting",
+				"client", "events_stream", "endpoint", endpoint, "delay", delay, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.clock().After(delay):
+		}
+		if delay *= 2; delay > maxEventsReconnectDelay {
+			delay = maxEventsReconnectDelay
+		}
+	}
+}
+
+// retryable returns true if the call failed with err may succeed again, on a
+// server error or a rate limit
+func retryable(err *APIError) bool {
+	return err.StatusCode >= http.StatusInternalServerError || err.StatusCode == http.StatusTooManyRequests
+}
+
+// readEvents parses a text/event-stream body and calls handler with the
+// data of every event. It returns nil when the body ends.
+func readEvents(r io.Reader, handler func(data []byte)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		switch {
+		case len(line) == 0:
+			// a blank line dispatches the event
+			if data.Len() > 0 {
+				handler(data.Bytes())
+				data.Reset()
+			}
+		case line[0] == ':':
+			// comment, used as heartbeat
+		case bytes.HasPrefix(line, []byte("data:")):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.Write(bytes.TrimPrefix(bytes.TrimPrefix(line, []byte("data:")), []byte(" ")))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if data.Len() > 0 {
+		handler(data.Bytes())
+	}
+	return nil
+}
+
+// StreamTradeUpdateEvents streams the account's trade updates
+// using the default Alpaca client.
+func StreamTradeUpdateEvents(ctx context.Context, req StreamEventsRequest, handler func(event TradeUpdateEvent)) error {
+	return DefaultClient.StreamTradeUpdateEvents(ctx, req, handler)
+}
+
+// StreamNonTradeActivityEvents streams the account's non-trade activities
+// using the default Alpaca client.
+func StreamNonTradeActivityEvents(ctx context.Context, req StreamEventsRequest, handler func(event NonTradeActivityEvent)) error {
+	return DefaultClient.StreamNonTradeActivityEvents(ctx, req, handler)
+}
@@ -0,0 +1,121 @@
+package alpaca
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// CryptoFee is a fee charged for a crypto trade. Fees of trades in coin
+// pairs not quoted in USD are charged in the quote coin.
+type CryptoFee struct {
+	ActivityID  string
+	Symbol      string
+	Date        time.Time
+	Description string
+	// Qty is the charged amount, in the currency the fee was charged in
+	Qty decimal.Decimal
+	// Price is the USD price of the currency the fee was charged in
+	Price decimal.Decimal
+}
+
+// Value returns the USD value of the fee
+func (f CryptoFee) Value() decimal.Decimal {
+	if f.Price.IsZero() {
+		return f.Qty
+	}
+	return f.Qty.Mul(f.Price)
+}
+
+// CryptoFeeTier is the crypto trading fee tier of the account, set by its
+// 30 day trading volume.
+type CryptoFeeTier struct {
+	Tier int `json:"tier"`
+	// Volume30D is the USD volume traded in the last 30 days
+	Volume30D decimal.Decimal `json:"volume_30d"`
+	// MakerFeeBps and TakerFeeBps are the fees charged for the fills of
+	// orders adding and removing liquidity, in basis points
+	MakerFeeBps decimal.Decimal `json:"maker_fee_bps"`
+	TakerFeeBps decimal.Decimal `json:"taker_fee_bps"`
+}
+
+// GetCryptoFeeTier returns the current crypto trading fee tier of the
+// account.
+func (c *Client) GetCryptoFeeTier() (*CryptoFeeTier, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/account/crypto/fee_tier", c.baseURL(), c.version(TradingEndpoint)))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	tier := &CryptoFeeTier{}
+	if err = unmarshal(resp, tier); err != nil {
+		return nil, err
+	}
+	return tier, nil
+}
+
+// ListCryptoFees returns the crypto trading fees charged to the account,
+// filtered by the optional activity request parameters.
+func (c *Client) ListCryptoFees(opts *AccountActivitiesRequest) ([]CryptoFee, error) {
+	activityType := ActivityCryptoFee
+	activities, err := c.GetAccountActivities(&activityType, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	fees := make([]CryptoFee, 0, len(activities))
+	for _, activity := range activities {
+		fees = append(fees, CryptoFee{
+			ActivityID:  activity.ID,
+			Symbol:      activity.Symbol,
+			Date:        activity.Date,
+			Description: activity.Description,
+			// fees are reported as outgoing, i.e. negative, quantities
+			Qty:   activity.Qty.Abs(),
+			Price: activity.Price,
+		})
+	}
+	return fees, nil
+}
+
+// TotalCryptoFees returns the USD value of the fees
+func TotalCryptoFees(fees []CryptoFee) decimal.Decimal {
+	total := decimal.Zero
+	for _, fee := range fees {
+		total = total.Add(fee.Value())
+	}
+	return total
+}
+
+// FeesByOrder sums the fees among the account activities per order ID,
+// e.g. to compute the realized P&L of orders net of costs. Fees that are not
+// linked to an order are summed under the empty order ID. The fees are
+// returned as positive USD amounts.
+func FeesByOrder(activities []AccountActivity) map[string]decimal.Decimal {
+	fees := make(map[string]decimal.Decimal)
+	for _, activity := range activities {
+		if fee, ok := activity.Typed().(*Fee); ok {
+			fees[fee.OrderID] = fees[fee.OrderID].Add(fee.Amount())
+		}
+	}
+	return fees
+}
+
+// ListCryptoFees returns the crypto trading fees charged to the account
+// with the default Alpaca client.
+func ListCryptoFees(opts *AccountActivitiesRequest) ([]CryptoFee, error) {
+	return DefaultClient.ListCryptoFees(opts)
+}
+
+// GetCryptoFeeTier returns the crypto trading fee tier of the account
+// with the default Alpaca client.
+func GetCryptoFeeTier() (*CryptoFeeTier, error) {
+	return DefaultClient.GetCryptoFeeTier()
+}
@@ -0,0 +1,131 @@
+package alpaca
+
+import "github.com/shopspring/decimal"
+
+// maxDoneOrders is how many terminal orders a FillDeltas remembers to ignore
+// their late reports
+const maxDoneOrders = 1000
+
+// FillDeltas turns the cumulative filled quantities of orders, reported by
+// trade updates and fill activities, into the quantities filled since the
+// last report of each order, so that repeated or late reports do not count
+// twice. The fills of an order are dropped once it reaches a terminal state,
+// only its ID being remembered among the last terminal orders. It is not
+// safe for concurrent use.
+type FillDeltas struct {
+	// filled is the quantity and notional of each order reported so far
+	filled map[string]cumulativeFill
+	// done is the last terminal orders, oldest first in doneOrder
+	done      map[string]bool
+	doneOrder []string
+}
+
+type cumulativeFill struct {
+	qty, notional decimal.Decimal
+}
+
+// NewFillDeltas creates a FillDeltas without reported orders.
+func NewFillDeltas() *FillDeltas {
+	return &FillDeltas{
+		filled: map[string]cumulativeFill{},
+		done:   map[string]bool{},
+	}
+}
+
+// Order returns the quantity of order filled since it was last reported,
+// negative for a sell, and its price derived from the average fill price of
+// the order. ok is false if nothing was filled since.
+func (d *FillDeltas) Order(order Order) (qty, price decimal.Decimal, ok bool) {
+	if d.done[order.ID] {
+		return decimal.Zero, decimal.Zero, false
+	}
+	if order.Status.IsTerminal() {
+		defer d.Forget(order.ID)
+	}
+	if order.FilledAvgPrice == nil {
+		return decimal.Zero, decimal.Zero, false
+	}
+	notional := order.FilledQty.Mul(*order.FilledAvgPrice)
+	prev := d.filled[order.ID]
+	qty = order.FilledQty.Sub(prev.qty)
+	if !qty.IsPositive() {
+		return decimal.Zero, decimal.Zero, false
+	}
+	price = notional.Sub(prev.notional).Div(qty)
+	d.filled[order.ID] = cumulativeFill{qty: order.FilledQty, notional: notional}
+	if order.Side != Buy {
+		qty = qty.Neg()
+	}
+	return qty, price, true
+}
+
+// Seed records the quantity of order filled so far as reported, e.g. for
+// an open order whose fills are already in the positions loaded from the
+// REST API.
+func (d *FillDeltas) Seed(order Order) {
+	d.Order(order)
+}
+
+// Fill returns the quantity of the order of f filled since it was last
+// reported, negative for a sale, short or not, filled at f.Price. ok is
+// false if nothing was filled since.
+func (d *FillDeltas) Fill(f *Fill) (qty decimal.Decimal, ok bool) {
+	if d.done[f.OrderID] {
+		return decimal.Zero, false
+	}
+	if f.Type == string(EventFill) {
+		defer d.Forget(f.OrderID)
+	}
+	prev := d.filled[f.OrderID]
+	qty = f.CumQty.Sub(prev.qty)
+	if !qty.IsPositive() {
+		return decimal.Zero, false
+	}
+	d.filled[f.OrderID] = cumulativeFill{qty: f.CumQty, notional: prev.notional.Add(qty.Mul(f.Price))}
+	if f.Side != Buy {
+		qty = qty.Neg()
+	}
+	return qty, true
+}
+
+// Forget drops the fills of the order orderID once it reached a terminal
+// state, ignoring its later reports.
+func (d *FillDeltas) Forget(orderID string) {
+	delete(d.filled, orderID)
+	if d.done[orderID] {
+		return
+	}
+	d.done[orderID] = true
+	d.doneOrder = append(d.doneOrder, orderID)
+	if len(d.doneOrder) > maxDoneOrders {
+		delete(d.done, d.doneOrder[0])
+		d.doneOrder = d.doneOrder[1:]
+	}
+}
+
+// ApplyFill returns the signed quantity and the average entry price of a
+// position of qty at avgEntryPrice once the signed quantity fillQty is filled
+// at price, with the profit or loss realized by the quantity it closes.
+func ApplyFill(qty, avgEntryPrice, fillQty, price decimal.Decimal) (newQty, newAvgEntryPrice, realizedPL decimal.Decimal) {
+	newQty = qty.Add(fillQty)
+	if qty.IsZero() || qty.Sign() == fillQty.Sign() {
+		newAvgEntryPrice = avgEntryPrice.Mul(qty).Add(price.Mul(fillQty)).Div(newQty)
+		return newQty, newAvgEntryPrice, decimal.Zero
+	}
+
+	closed := decimal.Min(fillQty.Abs(), qty.Abs())
+	realizedPL = closed.Mul(price.Sub(avgEntryPrice))
+	if qty.IsNegative() {
+		realizedPL = realizedPL.Neg()
+	}
+	switch {
+	case newQty.IsZero():
+		newAvgEntryPrice = decimal.Zero
+	case newQty.Sign() != qty.Sign():
+		// the position reversed, what is left was opened at price
+		newAvgEntryPrice = price
+	default:
+		newAvgEntryPrice = avgEntryPrice
+	}
+	return newQty, newAvgEntryPrice, realizedPL
+}
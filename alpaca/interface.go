@@ -0,0 +1,89 @@
+package alpaca
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+)
+
+// TradingClient is the interface of the API methods of Client.
+// Strategy code depending on it instead of *Client can be unit tested
+// with a fake implementation such as alpacatest.MockClient.
+type TradingClient interface {
+	GetAccount() (*Account, error)
+	GetAccountConfigurations() (*AccountConfigurations, error)
+	UpdateAccountConfigurations(newConfigs AccountConfigurationsRequest) (*AccountConfigurations, error)
+	GetAccountActivities(activityType *string, opts *AccountActivitiesRequest) ([]AccountActivity, error)
+	GetPortfolioHistory(period *string, timeframe *RangeFreq, dateEnd *time.Time, extendedHours bool) (*PortfolioHistory, error)
+
+	ListPositions() ([]Position, error)
+	GetPosition(symbol string) (*Position, error)
+	CloseAllPositions() error
+	ClosePosition(symbol string) error
+
+	GetAggregates(symbol, timespan, from, to string) (*Aggregates, error)
+	GetLastQuote(symbol string) (*LastQuoteResponse, error)
+	GetLastTrade(symbol string) (*LastTradeResponse, error)
+	GetTrades(symbol string, start, end time.Time, limit int) <-chan v2.TradeItem
+	GetQuotes(symbol string, start, end time.Time, limit int) <-chan v2.QuoteItem
+	GetBars(symbol string, timeFrame v2.TimeFrame, adjustment v2.Adjustment, start, end time.Time, limit int) <-chan v2.BarItem
+	GetMultiBars(symbols []string, timeFrame v2.TimeFrame, adjustment v2.Adjustment, start, end time.Time) (map[string][]v2.Bar, error)
+	GetLatestTrade(symbol string) (*v2.Trade, error)
+	GetLatestQuote(symbol string) (*v2.Quote, error)
+	GetMarkPrice(symbol string) (*MarkPrice, error)
+	GetSnapshot(symbol string) (*v2.Snapshot, error)
+	GetSnapshots(symbols []string) (map[string]*v2.Snapshot, error)
+	GetPositionBars(timeFrame v2.TimeFrame, lookback time.Duration) (map[string]*PositionBars, error)
+	ListBars(symbols []string, opts ListBarParams) (map[string][]Bar, error)
+	GetSymbolBars(symbol string, opts ListBarParams) ([]Bar, error)
+	ListCryptoPairs(status *string) ([]CryptoPair, error)
+	GetCryptoSnapshot(symbol string) (*v2.CryptoSnapshot, error)
+
+	GetClock() (*Clock, error)
+	GetCalendar(start, end *string) ([]CalendarDay, error)
+	ListAnnouncements(req ListAnnouncementsRequest) ([]Announcement, error)
+
+	ListOrders(status *string, until *time.Time, limit *int, nested *bool) ([]Order, error)
+	PlaceOrder(req PlaceOrderRequest) (*Order, error)
+	ValidateOrder(req PlaceOrderRequest) error
+	GetOrder(orderID string) (*Order, error)
+	GetNestedOrder(orderID string) (*Order, error)
+	GetOrderByClientOrderID(clientOrderID string) (*Order, error)
+	ReplaceOrder(orderID string, req ReplaceOrderRequest) (*Order, error)
+	CancelOrder(orderID string) error
+	CancelAllOrders() error
+
+	ListAssets(status *string) ([]Asset, error)
+	GetAsset(symbol string) (*Asset, error)
+	IsShortable(symbol string) (bool, error)
+	WouldTriggerPDT(proposed PlaceOrderRequest) (bool, error)
+
+	ListCryptoWallets(asset *string) ([]CryptoWallet, error)
+	ListWhitelistedAddresses() ([]WhitelistedAddress, error)
+	CreateWhitelistedAddress(req CreateWhitelistedAddressRequest) (*WhitelistedAddress, error)
+	DeleteWhitelistedAddress(addressID string) error
+	ListCryptoTransfers() ([]CryptoTransfer, error)
+	GetCryptoTransfer(transferID string) (*CryptoTransfer, error)
+	CreateCryptoTransfer(req CreateCryptoTransferRequest) (*CryptoTransfer, error)
+	WaitForCryptoTransfer(ctx context.Context, transferID string, interval time.Duration) (*CryptoTransfer, error)
+	CreateCryptoTransferAndWait(ctx context.Context, req CreateCryptoTransferRequest, interval time.Duration) (*CryptoTransfer, error)
+	ListCryptoFees(opts *AccountActivitiesRequest) ([]CryptoFee, error)
+	GetCryptoFeeTier() (*CryptoFeeTier, error)
+
+	ListWatchlists() ([]Watchlist, error)
+	GetWatchlist(watchlistID string) (*Watchlist, error)
+	GetWatchlistByName(name string) (*Watchlist, error)
+
+	GetOptionContracts(filter GetOptionContractsRequest) ([]OptionContract, error)
+	GetOptionContract(symbolOrID string) (*OptionContract, error)
+	ExerciseOptionPosition(symbol string) error
+
+	DoRaw(method, path string, body interface{}) (json.RawMessage, error)
+
+	StreamTradeUpdateEvents(ctx context.Context, req StreamEventsRequest, handler func(event TradeUpdateEvent)) error
+	StreamNonTradeActivityEvents(ctx context.Context, req StreamEventsRequest, handler func(event NonTradeActivityEvent)) error
+}
+
+var _ TradingClient = (*Client)(nil)
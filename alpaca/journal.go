@@ -0,0 +1,130 @@
+package alpaca
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// The actions of the journal entries
+const (
+	JournalPlace       = "place"
+	JournalReplace     = "replace"
+	JournalCancel      = "cancel"
+	JournalCancelAll   = "cancel_all"
+	JournalClose       = "close"
+	JournalCloseAll    = "close_all"
+	JournalTradeUpdate = "trade_update"
+)
+
+// JournalEntry is a line of an order journal
+type JournalEntry struct {
+	Time time.Time `json:"time"`
+	// Action is one of the Journal action constants
+	Action string `json:"action"`
+	// RequestID is the ID of the REST call, empty for the trade updates
+	RequestID string `json:"request_id,omitempty"`
+	// OrderID is the order replaced, canceled or updated
+	OrderID string `json:"order_id,omitempty"`
+	// Symbol is the position closed
+	Symbol string `json:"symbol,omitempty"`
+	// Request is the PlaceOrderRequest or ReplaceOrderRequest sent
+	Request interface{} `json:"request,omitempty"`
+	// Response is the order returned by the API, or the trade update
+	Response interface{} `json:"response,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// Journal writes the order activity of a client as JSON lines, one
+// JournalEntry per line, appended to its writer. The entries of concurrent
+// calls are not interleaved.
+type Journal struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewJournal creates a journal appending to w, e.g. a file opened with
+// os.O_APPEND.
+func NewJournal(w io.Writer) *Journal {
+	return &Journal{enc: json.NewEncoder(w)}
+}
+
+// WithJournal makes the client write every order placed, replaced or
+// canceled, and every position closed, to journal, with its response and
+// request ID. The orders rejected by the client itself are written too.
+func WithJournal(journal *Journal) ClientOption {
+	return func(c *Client) {
+		c.journal = journal
+	}
+}
+
+// Record appends entry to the journal, timestamped now if it has no time.
+// The journal keeps the first write error, see Err.
+func (j *Journal) Record(entry JournalEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.enc.Encode(entry); err != nil && j.err == nil {
+		j.err = err
+	}
+}
+
+// Err returns the first error writing the journal.
+func (j *Journal) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+// HandleTradeUpdate records a trade update. It can be passed to
+// stream.SubscribeTradeUpdates.
+func (j *Journal) HandleTradeUpdate(update TradeUpdate) {
+	j.Record(JournalEntry{
+		Action:   JournalTradeUpdate,
+		OrderID:  update.Order.ID,
+		Response: update,
+	})
+}
+
+// journaled returns the client making a call to record in the journal, with
+// the request ID of the call set, and the function recording its outcome. It
+// returns the client itself and a no-op without a journal.
+func (c *Client) journaled(action, orderID string, request interface{}) (*Client, func(response interface{}, err error)) {
+	return c.journaledEntry(JournalEntry{Action: action, OrderID: orderID, Request: request})
+}
+
+// journaledPosition is journaled for the calls closing the position of symbol
+func (c *Client) journaledPosition(action, symbol string) (*Client, func(response interface{}, err error)) {
+	return c.journaledEntry(JournalEntry{Action: action, Symbol: symbol})
+}
+
+// journaledEntry is journaled for the entry, completed with the time, the
+// request ID and the outcome of the call
+func (c *Client) journaledEntry(entry JournalEntry) (*Client, func(response interface{}, err error)) {
+	if c.journal == nil {
+		return c, func(interface{}, error) {}
+	}
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	id, ok := RequestIDFromContext(ctx)
+	if !ok {
+		id = newRequestID()
+		ctx = ContextWithRequestID(ctx, id)
+	}
+	return c.WithContext(ctx), func(response interface{}, err error) {
+		entry.Time = c.clock().Now()
+		entry.RequestID = id
+		entry.Response = response
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		c.journal.Record(entry)
+	}
+}
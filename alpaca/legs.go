@@ -0,0 +1,107 @@
+package alpaca
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+// NewTakeProfit returns the take profit leg of a bracket, OCO or OTO order
+// closing the position with a limit order at limitPrice.
+func NewTakeProfit(limitPrice decimal.Decimal) *TakeProfit {
+	return &TakeProfit{LimitPrice: &limitPrice}
+}
+
+// NewStopLoss returns the stop loss leg of a bracket, OCO or OTO order
+// closing the position with a stop order at stopPrice.
+func NewStopLoss(stopPrice decimal.Decimal) *StopLoss {
+	return &StopLoss{StopPrice: &stopPrice}
+}
+
+// NewStopLimitLoss returns the stop loss leg of a bracket, OCO or OTO order
+// closing the position with a stop limit order at stopPrice and limitPrice.
+func NewStopLimitLoss(stopPrice, limitPrice decimal.Decimal) *StopLoss {
+	return &StopLoss{StopPrice: &stopPrice, LimitPrice: &limitPrice}
+}
+
+// validateLegPrices checks the legs of bracket, OCO and OTO orders, and that
+// their prices are on the right side of the entry and of each other, e.g.
+// for a buy bracket the take profit above the entry and the stop loss below
+func validateLegPrices(req PlaceOrderRequest) error {
+	tp := req.TakeProfit != nil && req.TakeProfit.LimitPrice != nil
+	sl := req.StopLoss != nil && req.StopLoss.StopPrice != nil
+	if req.StopLoss != nil && req.StopLoss.LimitPrice != nil && req.StopLoss.StopPrice == nil {
+		return invalidOrder("stop loss limit price requires a stop price")
+	}
+
+	// the legs of bracket and OTO orders close the position the order opens,
+	// the legs of OCO orders are the order itself
+	exit := Sell
+	if req.Side == Sell {
+		exit = Buy
+	}
+	var entry *decimal.Decimal
+	switch req.OrderClass {
+	case Bracket:
+		if !tp {
+			return invalidOrder("%s orders require a take profit limit price", Bracket)
+		}
+		if !sl {
+			return invalidOrder("%s orders require a stop loss stop price", Bracket)
+		}
+		entry = entryPrice(req)
+	case Oco:
+		if !tp || !sl {
+			return invalidOrder("%s orders require a take profit limit price and a stop loss stop price", Oco)
+		}
+		exit = req.Side
+	case Oto:
+		if tp == sl {
+			return invalidOrder("%s orders require exactly one of a take profit and a stop loss", Oto)
+		}
+		entry = entryPrice(req)
+	default:
+		return nil
+	}
+
+	// a sell exit closes a long position: it takes profit above and stops
+	// the loss below, a buy exit closes a short one the other way around
+	above, below := "above", "below"
+	if exit == Buy {
+		above, below = below, above
+	}
+	beyond := func(a, b decimal.Decimal) bool {
+		if exit == Sell {
+			return a.GreaterThan(b)
+		}
+		return a.LessThan(b)
+	}
+
+	if tp && entry != nil && !beyond(*req.TakeProfit.LimitPrice, *entry) {
+		return invalidOrder("take profit limit price %s must be %s the entry price %s of a %s order",
+			req.TakeProfit.LimitPrice, above, entry, req.Side)
+	}
+	if sl && entry != nil && !beyond(*entry, *req.StopLoss.StopPrice) {
+		return invalidOrder("stop loss stop price %s must be %s the entry price %s of a %s order",
+			req.StopLoss.StopPrice, below, entry, req.Side)
+	}
+	if tp && sl && !beyond(*req.TakeProfit.LimitPrice, *req.StopLoss.StopPrice) {
+		return invalidOrder("take profit limit price %s must be %s the stop loss stop price %s",
+			req.TakeProfit.LimitPrice, above, req.StopLoss.StopPrice)
+	}
+	if sl && req.StopLoss.LimitPrice != nil && beyond(*req.StopLoss.LimitPrice, *req.StopLoss.StopPrice) {
+		return invalidOrder("stop loss limit price %s must not be %s the stop loss stop price %s",
+			req.StopLoss.LimitPrice, above, req.StopLoss.StopPrice)
+	}
+	return nil
+}
+
+// entryPrice returns the price the order is expected to fill at, nil for a
+// market or trailing stop order
+func entryPrice(req PlaceOrderRequest) *decimal.Decimal {
+	switch req.Type {
+	case Limit, StopLimit:
+		return req.LimitPrice
+	case Stop:
+		return req.StopPrice
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package alpaca
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrOrderLimit is matched with errors.Is by the errors of the orders
+// rejected by the hard limits of the client.
+var ErrOrderLimit = errors.New("order limit exceeded")
+
+// OrderLimitError is returned by PlaceOrder and ReplaceOrder for the orders
+// violating a hard limit of the client
+type OrderLimitError struct {
+	Reason string
+}
+
+func (e *OrderLimitError) Error() string {
+	return "order rejected by the client limits: " + e.Reason
+}
+
+// Unwrap returns ErrOrderLimit
+func (e *OrderLimitError) Unwrap() error {
+	return ErrOrderLimit
+}
+
+func limitExceeded(format string, args ...interface{}) error {
+	return &OrderLimitError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// orderLimits are the hard limits of a client. They are only set by the
+// options of NewClient, so no code holding the client can lift them.
+type orderLimits struct {
+	maxOrderNotional decimal.Decimal
+	maxPositionQty   decimal.Decimal
+	allowedSymbols   map[string]bool
+}
+
+// limits returns the limits of the client being created
+func (c *Client) limits() *orderLimits {
+	if c.orderLimits == nil {
+		c.orderLimits = &orderLimits{}
+	}
+	return c.orderLimits
+}
+
+// WithMaxOrderNotional makes PlaceOrder and ReplaceOrder reject the orders
+// worth more than max, valued at their limit or stop price, or else at the
+// latest trade of their symbol. The legs of multi-leg orders are valued
+// each at the latest trade of their symbol. It is a last line of defense
+// against the bugs of a strategy, the orders fail if they cannot be valued.
+func WithMaxOrderNotional(max decimal.Decimal) ClientOption {
+	return func(c *Client) {
+		c.limits().maxOrderNotional = max
+	}
+}
+
+// WithMaxPositionQty makes PlaceOrder and ReplaceOrder reject the orders
+// that would grow a position, long or short, over max shares or coins,
+// including the positions of the legs of multi-leg orders.
+func WithMaxPositionQty(max decimal.Decimal) ClientOption {
+	return func(c *Client) {
+		c.limits().maxPositionQty = max
+	}
+}
+
+// WithAllowedSymbols makes PlaceOrder and ReplaceOrder reject the orders of
+// the symbols not listed, including the legs of multi-leg orders.
+func WithAllowedSymbols(symbols ...string) ClientOption {
+	return func(c *Client) {
+		l := c.limits()
+		if l.allowedSymbols == nil {
+			l.allowedSymbols = map[string]bool{}
+		}
+		for _, symbol := range symbols {
+			l.allowedSymbols[symbol] = true
+		}
+	}
+}
+
+// checkLimits returns an *OrderLimitError if req violates a hard limit of the
+// client. The legs of multi-leg orders are checked as orders of their own.
+func (c *Client) checkLimits(req PlaceOrderRequest) error {
+	l := c.orderLimits
+	if l == nil {
+		return nil
+	}
+	if req.AssetKey == nil {
+		for _, leg := range req.Legs {
+			if l.allowedSymbols != nil && !l.allowedSymbols[leg.Symbol] {
+				return limitExceeded("%s is not an allowed symbol", leg.Symbol)
+			}
+		}
+		for _, leg := range req.Legs {
+			symbol := leg.Symbol
+			err := c.checkLimits(PlaceOrderRequest{
+				AssetKey: &symbol,
+				Qty:      req.Qty.Mul(leg.RatioQty),
+				Side:     leg.Side,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	symbol := *req.AssetKey
+	if l.allowedSymbols != nil && !l.allowedSymbols[symbol] {
+		return limitExceeded("%s is not an allowed symbol", symbol)
+	}
+	if l.maxOrderNotional.IsZero() && l.maxPositionQty.IsZero() {
+		return nil
+	}
+
+	qty, notional := req.Qty, req.Notional
+	if !l.maxOrderNotional.IsZero() || qty.IsZero() {
+		price, err := c.limitPrice(req)
+		if err != nil {
+			return err
+		}
+		if qty.IsZero() {
+			if !price.IsPositive() {
+				return limitExceeded("%s has no price to value the order at", symbol)
+			}
+			qty = notional.Div(price)
+		} else {
+			notional = qty.Mul(price)
+		}
+	}
+	if !l.maxOrderNotional.IsZero() && notional.GreaterThan(l.maxOrderNotional) {
+		return limitExceeded("%s %s worth %s, over %s", req.Side, symbol, notional.StringFixed(2), l.maxOrderNotional)
+	}
+	if l.maxPositionQty.IsZero() {
+		return nil
+	}
+
+	position := decimal.Zero
+	p, err := c.GetPosition(symbol)
+	switch {
+	case err == nil:
+		position = p.Qty
+		if p.Side == "short" && position.IsPositive() {
+			position = position.Neg()
+		}
+	case !errors.Is(err, ErrPositionNotFound):
+		return err
+	}
+	after := position.Add(qty)
+	if req.Side == Sell {
+		after = position.Sub(qty)
+	}
+	if after.Abs().GreaterThan(position.Abs()) && after.Abs().GreaterThan(l.maxPositionQty) {
+		return limitExceeded("position in %s would be %s, over %s", symbol, after, l.maxPositionQty)
+	}
+	return nil
+}
+
+// limitPrice returns the price req is valued at by the limits
+func (c *Client) limitPrice(req PlaceOrderRequest) (decimal.Decimal, error) {
+	if req.LimitPrice != nil {
+		return *req.LimitPrice, nil
+	}
+	if req.StopPrice != nil {
+		return *req.StopPrice, nil
+	}
+	trade, err := c.GetLatestTrade(*req.AssetKey)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("pricing %s for the order limits: %w", *req.AssetKey, err)
+	}
+	return decimal.NewFromFloat(trade.Price), nil
+}
+
+// checkReplaceLimits checks the order orderID once replaced by req against
+// the hard limits of the client
+func (c *Client) checkReplaceLimits(orderID string, req ReplaceOrderRequest) error {
+	if c.orderLimits == nil {
+		return nil
+	}
+	order, err := c.GetOrder(orderID)
+	if err != nil {
+		return err
+	}
+	replaced := PlaceOrderRequest{
+		AssetKey:   &order.Symbol,
+		Qty:        order.Qty.Sub(order.FilledQty),
+		Side:       order.Side,
+		Type:       order.Type,
+		LimitPrice: order.LimitPrice,
+		StopPrice:  order.StopPrice,
+	}
+	if req.Qty != nil {
+		replaced.Qty = *req.Qty
+	}
+	if req.LimitPrice != nil {
+		replaced.LimitPrice = req.LimitPrice
+	}
+	if req.StopPrice != nil {
+		replaced.StopPrice = req.StopPrice
+	}
+	return c.checkLimits(replaced)
+}
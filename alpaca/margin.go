@@ -0,0 +1,73 @@
+package alpaca
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// regTInitialMarginRate is the Regulation T initial margin requirement
+	// of marginable securities, for both long and short positions
+	regTInitialMarginRate = decimal.New(5, -1)
+
+	// marketOrderBuffer is the fraction of buying power held back by
+	// EffectiveBuyingPower for orders whose fill price is not known up front
+	marketOrderBuffer = decimal.New(3, -2)
+)
+
+// isMarginAccount returns true if the account can borrow, i.e. it is
+// not a cash account
+func (a *Account) isMarginAccount() bool {
+	return a.Multiplier.GreaterThan(decimal.New(1, 0))
+}
+
+// EffectiveBuyingPower returns the buying power that can be spent on an
+// order opening a position with the given side and type. Short positions
+// need a margin account with shorting enabled, and market, stop and
+// trailing stop orders keep a small buffer since their fill price is unknown.
+func (a *Account) EffectiveBuyingPower(side Side, orderType OrderType) decimal.Decimal {
+	if side == Sell && (!a.ShortingEnabled || !a.isMarginAccount()) {
+		return decimal.Zero
+	}
+
+	bp := a.BuyingPower
+	switch orderType {
+	case Market, Stop, TrailingStop:
+		bp = bp.Sub(bp.Mul(marketOrderBuffer))
+	}
+	if bp.IsNegative() {
+		return decimal.Zero
+	}
+	return bp
+}
+
+// CheckDayTradingBuyingPower returns an error wrapping
+// ErrInsufficientBuyingPower if a day trade of the given cost exceeds the
+// day trading buying power of a pattern day trader account. Other
+// accounts are not subject to it.
+func (a *Account) CheckDayTradingBuyingPower(cost decimal.Decimal) error {
+	if !a.PatternDayTrader {
+		return nil
+	}
+	if cost.GreaterThan(a.DaytradingBuyingPower) {
+		return fmt.Errorf("%w: day trade of %s exceeds day trading buying power of %s",
+			ErrInsufficientBuyingPower, cost, a.DaytradingBuyingPower)
+	}
+	return nil
+}
+
+// EstimateInitialMargin returns the Regulation T initial margin the order
+// would require when filled at the given price, e.g. its limit price or
+// the latest quote. Non-marginable securities and orders of cash accounts
+// require their full value.
+func (a *Account) EstimateInitialMargin(req PlaceOrderRequest, price decimal.Decimal, marginable bool) decimal.Decimal {
+	value := req.Notional
+	if value.IsZero() {
+		value = req.Qty.Mul(price)
+	}
+	if !marginable || !a.isMarginAccount() {
+		return value
+	}
+	return value.Mul(regTInitialMarginRate)
+}
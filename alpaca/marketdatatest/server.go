@@ -0,0 +1,437 @@
+// Package marketdatatest provides a fake market data API serving the bars,
+// trades, quotes, snapshots and crypto pairs it is given, for testing the code fetching
+// them with an alpaca.Client against the edge cases of the real API:
+// pagination, empty pages, rate limits and malformed rows, e.g.
+//
+//	s := marketdatatest.NewServer(marketdatatest.WithPageSize(2))
+//	defer s.Close()
+//	s.AddBars("AAPL", v2.Min, bars...)
+//	s.AddRawBar("AAPL", v2.Min, t, `{"t":"not a time"}`)
+//	for item := range s.Client().GetBars("AAPL", v2.Min, v2.Raw, start, end, 100) {
+//		...
+//	}
+package marketdatatest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+)
+
+const (
+	defaultLimit = 1000
+	maxLimit     = 10000
+)
+
+// Option configures a Server
+type Option func(s *Server)
+
+// WithPageSize sets the most rows of a page, whatever the limit of the
+// request, so that small data sets span several pages.
+func WithPageSize(n int) Option {
+	return func(s *Server) {
+		s.pageSize = n
+	}
+}
+
+// WithEmptyPages makes every page of rows preceded by an empty page with a
+// next page token.
+func WithEmptyPages() Option {
+	return func(s *Server) {
+		s.emptyPages = true
+	}
+}
+
+// WithRateLimit sets how many requests are served per minute, unlimited by
+// default. The responses have the X-RateLimit headers of the real API, and
+// the requests over the limit fail with 429 Too Many Requests.
+func WithRateLimit(perMinute int) Option {
+	return func(s *Server) {
+		s.rateLimit = perMinute
+	}
+}
+
+// WithNow sets the clock of the rate limit, time.Now by default.
+func WithNow(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// row is a row of a series, kept as JSON so it can be malformed
+type row struct {
+	t   time.Time
+	raw json.RawMessage
+}
+
+// Server is a fake market data API. Its trades, quotes and bars are served
+// between the start and end of the requests, both included, in pages of at
+// most the limit of the requests. The adjustment of the bars is ignored.
+type Server struct {
+	*httptest.Server
+	credentials common.APIKey
+	pageSize    int
+	emptyPages  bool
+	rateLimit   int
+	now         func() time.Time
+
+	mu        sync.Mutex
+	series    map[string][]row
+	snapshots map[string]v2.Snapshot
+	pairs     []alpaca.CryptoPair
+	crypto    map[string]v2.CryptoSnapshot
+	requests  []string
+	// throttled is the number of the next requests failing with 429
+	throttled int
+	window    time.Time
+	served    int
+}
+
+// NewServer starts a server. Close it once done.
+func NewServer(opts ...Option) *Server {
+	s := &Server{
+		credentials: common.APIKey{ID: "marketdatatest-key", Secret: "marketdatatest-secret"},
+		now:         time.Now,
+		series:      make(map[string][]row),
+		snapshots:   make(map[string]v2.Snapshot),
+		crypto:      make(map[string]v2.CryptoSnapshot),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Credentials returns the credentials the server accepts.
+func (s *Server) Credentials() *common.APIKey {
+	credentials := s.credentials
+	return &credentials
+}
+
+// Client returns a client of the server, configured by opts as well.
+func (s *Server) Client(opts ...alpaca.ClientOption) *alpaca.Client {
+	return alpaca.NewClientWithOptions(append([]alpaca.ClientOption{
+		alpaca.WithDataURL(s.URL),
+		alpaca.WithCredentials(s.Credentials()),
+	}, opts...)...)
+}
+
+func tradesKey(symbol string) string {
+	return "trades/" + symbol
+}
+
+func quotesKey(symbol string) string {
+	return "quotes/" + symbol
+}
+
+func barsKey(symbol string, tf v2.TimeFrame) string {
+	return "bars/" + string(tf) + "/" + symbol
+}
+
+func (s *Server) add(key string, t time.Time, raw string) {
+	s.addRows(key, row{t: t, raw: json.RawMessage(raw)})
+}
+
+func (s *Server) addRows(key string, rows ...row) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	series := append(s.series[key], rows...)
+	sort.SliceStable(series, func(i, j int) bool {
+		return series[i].t.Before(series[j].t)
+	})
+	s.series[key] = series
+}
+
+// marshal marshals a row, which cannot fail for the rows of the v2 package
+func marshal(v interface{}) json.RawMessage {
+	raw, _ := json.Marshal(v)
+	return raw
+}
+
+// AddTrades adds trades of symbol.
+func (s *Server) AddTrades(symbol string, trades ...v2.Trade) {
+	rows := make([]row, len(trades))
+	for i, trade := range trades {
+		rows[i] = row{t: trade.Timestamp, raw: marshal(trade)}
+	}
+	s.addRows(tradesKey(symbol), rows...)
+}
+
+// AddQuotes adds quotes of symbol.
+func (s *Server) AddQuotes(symbol string, quotes ...v2.Quote) {
+	rows := make([]row, len(quotes))
+	for i, quote := range quotes {
+		rows[i] = row{t: quote.Timestamp, raw: marshal(quote)}
+	}
+	s.addRows(quotesKey(symbol), rows...)
+}
+
+// AddBars adds bars of symbol in time frame tf.
+func (s *Server) AddBars(symbol string, tf v2.TimeFrame, bars ...v2.Bar) {
+	rows := make([]row, len(bars))
+	for i, bar := range bars {
+		rows[i] = row{t: bar.Timestamp, raw: marshal(bar)}
+	}
+	s.addRows(barsKey(symbol, tf), rows...)
+}
+
+// AddRawTrade adds a trade of symbol at t served as raw, e.g. malformed JSON.
+func (s *Server) AddRawTrade(symbol string, t time.Time, raw string) {
+	s.add(tradesKey(symbol), t, raw)
+}
+
+// AddRawQuote adds a quote of symbol at t served as raw, e.g. malformed JSON.
+func (s *Server) AddRawQuote(symbol string, t time.Time, raw string) {
+	s.add(quotesKey(symbol), t, raw)
+}
+
+// AddRawBar adds a bar of symbol in time frame tf at t served as raw, e.g.
+// malformed JSON.
+func (s *Server) AddRawBar(symbol string, tf v2.TimeFrame, t time.Time, raw string) {
+	s.add(barsKey(symbol, tf), t, raw)
+}
+
+// SetSnapshot sets the snapshot of symbol.
+func (s *Server) SetSnapshot(symbol string, snapshot v2.Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[symbol] = snapshot
+}
+
+// AddCryptoPairs adds crypto trading pairs.
+func (s *Server) AddCryptoPairs(pairs ...alpaca.CryptoPair) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pairs = append(s.pairs, pairs...)
+}
+
+// SetCryptoSnapshot sets the snapshot of the crypto pair symbol.
+func (s *Server) SetCryptoSnapshot(symbol string, snapshot v2.CryptoSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.crypto[symbol] = snapshot
+}
+
+// Throttle makes the next n requests fail with 429 Too Many Requests.
+func (s *Server) Throttle(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttled = n
+}
+
+// Requests returns the paths and queries of the requests served so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.URL.RequestURI())
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Header.Get("APCA-API-KEY-ID") != s.credentials.ID ||
+		r.Header.Get("APCA-API-SECRET-KEY") != s.credentials.Secret {
+		writeError(w, http.StatusUnauthorized, "request is not authorized")
+		return
+	}
+	if !s.allow(w) {
+		writeError(w, http.StatusTooManyRequests, "too many requests")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if r.URL.Path == "/v1beta1/crypto/pairs" {
+		s.serveCryptoPairs(w, r.URL.Query().Get("status"))
+		return
+	}
+	if symbol := strings.TrimPrefix(r.URL.Path, "/v1beta1/crypto/"); symbol != r.URL.Path &&
+		strings.HasSuffix(symbol, "/snapshot") {
+		snapshot, ok := s.crypto[strings.TrimSuffix(symbol, "/snapshot")]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		json.NewEncoder(w).Encode(snapshot)
+		return
+	}
+
+	// /v2/stocks/snapshots or /v2/stocks/{symbol}/{endpoint}[/latest]
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/v2/stocks/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "snapshots":
+		s.serveSnapshots(w, strings.Split(r.URL.Query().Get("symbols"), ","))
+	case len(parts) == 2 && parts[1] == "snapshot":
+		snapshot, ok := s.snapshots[parts[0]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		json.NewEncoder(w).Encode(snapshot)
+	case len(parts) == 3 && parts[2] == "latest" && (parts[1] == "trades" || parts[1] == "quotes"):
+		s.serveLatest(w, parts[0], parts[1])
+	case len(parts) == 2 && parts[1] == "trades":
+		s.servePage(w, r, parts[0], "trades", tradesKey(parts[0]))
+	case len(parts) == 2 && parts[1] == "quotes":
+		s.servePage(w, r, parts[0], "quotes", quotesKey(parts[0]))
+	case len(parts) == 2 && parts[1] == "bars":
+		tf := v2.TimeFrame(r.URL.Query().Get("timeframe"))
+		s.servePage(w, r, parts[0], "bars", barsKey(parts[0], tf))
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// allow counts a request against the rate limit, and returns false if it is
+// over the limit
+func (s *Server) allow(w http.ResponseWriter) bool {
+	if s.throttled > 0 {
+		s.throttled--
+		return false
+	}
+	if s.rateLimit <= 0 {
+		return true
+	}
+	now := s.now()
+	if now.Sub(s.window) >= time.Minute {
+		s.window, s.served = now.Truncate(time.Minute), 0
+	}
+	allowed := s.served < s.rateLimit
+	if allowed {
+		s.served++
+	}
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.rateLimit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.rateLimit-s.served))
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(s.window.Add(time.Minute).Unix(), 10))
+	return allowed
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// servePage writes a page of the rows of key, under field
+func (s *Server) servePage(w http.ResponseWriter, r *http.Request, symbol, field, key string) {
+	q := r.URL.Query()
+	var start, end time.Time
+	var err error
+	if v := q.Get("start"); v != "" {
+		if start, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "invalid start: "+v)
+			return
+		}
+	}
+	if v := q.Get("end"); v != "" {
+		if end, err = time.Parse(time.RFC3339, v); err != nil {
+			writeError(w, http.StatusUnprocessableEntity, "invalid end: "+v)
+			return
+		}
+	}
+	limit := defaultLimit
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 || limit > maxLimit {
+			writeError(w, http.StatusUnprocessableEntity, "invalid limit: "+v)
+			return
+		}
+	}
+	if s.pageSize > 0 && s.pageSize < limit {
+		limit = s.pageSize
+	}
+
+	// the token is the offset of the page, prefixed by "e" once the empty page
+	// before it was served
+	offset, emptyServed := 0, false
+	if token := q.Get("page_token"); token != "" {
+		emptyServed = strings.HasPrefix(token, "e")
+		if offset, err = strconv.Atoi(strings.TrimPrefix(token, "e")); err != nil || offset < 0 {
+			writeError(w, http.StatusUnprocessableEntity, "invalid page token: "+token)
+			return
+		}
+	}
+
+	var rows []json.RawMessage
+	for _, row := range s.series[key] {
+		if !start.IsZero() && row.t.Before(start) || !end.IsZero() && row.t.After(end) {
+			continue
+		}
+		rows = append(rows, row.raw)
+	}
+	if offset > len(rows) {
+		offset = len(rows)
+	}
+	rows = rows[offset:]
+
+	var next *string
+	page := []json.RawMessage{}
+	switch {
+	case s.emptyPages && !emptyServed && len(rows) > 0:
+		token := "e" + strconv.Itoa(offset)
+		next = &token
+	case len(rows) > limit:
+		page = rows[:limit]
+		token := strconv.Itoa(offset + limit)
+		next = &token
+	default:
+		page = rows
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"symbol":          symbol,
+		field:             page,
+		"next_page_token": next,
+	})
+}
+
+// serveLatest writes the last row of the trades or quotes of symbol
+func (s *Server) serveLatest(w http.ResponseWriter, symbol, field string) {
+	key := tradesKey(symbol)
+	if field == "quotes" {
+		key = quotesKey(symbol)
+	}
+	series := s.series[key]
+	if len(series) == 0 {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"symbol":                       symbol,
+		strings.TrimSuffix(field, "s"): series[len(series)-1].raw,
+	})
+}
+
+func (s *Server) serveSnapshots(w http.ResponseWriter, symbols []string) {
+	snapshots := make(map[string]*v2.Snapshot, len(symbols))
+	for _, symbol := range symbols {
+		if snapshot, ok := s.snapshots[symbol]; ok {
+			snapshots[symbol] = &snapshot
+		} else {
+			snapshots[symbol] = nil
+		}
+	}
+	json.NewEncoder(w).Encode(snapshots)
+}
+
+func (s *Server) serveCryptoPairs(w http.ResponseWriter, status string) {
+	pairs := []alpaca.CryptoPair{}
+	for _, pair := range s.pairs {
+		if status == "" || pair.Status == status {
+			pairs = append(pairs, pair)
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"pairs": pairs})
+}
@@ -0,0 +1,257 @@
+package marketdatatest
+
+import (
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	start = time.Date(2021, 3, 2, 14, 30, 0, 0, time.UTC)
+	end   = start.Add(time.Hour)
+)
+
+func minuteBars(n int) []v2.Bar {
+	bars := make([]v2.Bar, n)
+	for i := range bars {
+		price := 100 + float64(i)
+		bars[i] = v2.Bar{
+			Open: price, High: price + 1, Low: price - 1, Close: price,
+			Volume: uint64(100 * (i + 1)), Timestamp: start.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	return bars
+}
+
+func collectBars(items <-chan v2.BarItem) ([]v2.Bar, error) {
+	var bars []v2.Bar
+	for item := range items {
+		if item.Error != nil {
+			for range items {
+			}
+			return bars, item.Error
+		}
+		bars = append(bars, item.Bar)
+	}
+	return bars, nil
+}
+
+func TestPagination(t *testing.T) {
+	s := NewServer(WithPageSize(2))
+	defer s.Close()
+	bars := minuteBars(5)
+	// added out of order
+	s.AddBars("AAPL", v2.Min, bars[3:]...)
+	s.AddBars("AAPL", v2.Min, bars[:3]...)
+	s.AddBars("AAPL", v2.Day, v2.Bar{Close: 1, Timestamp: start})
+
+	got, err := collectBars(s.Client().GetBars("AAPL", v2.Min, v2.Raw, start, end, math.MaxInt32))
+	require.NoError(t, err)
+	require.Len(t, got, 5)
+	for i := range bars {
+		assert.True(t, bars[i].Timestamp.Equal(got[i].Timestamp))
+		assert.Equal(t, bars[i].Volume, got[i].Volume)
+	}
+	assert.Len(t, s.Requests(), 3)
+
+	// the limit of the request, then the range
+	got, err = collectBars(s.Client().GetBars("AAPL", v2.Min, v2.Raw, start, end, 3))
+	require.NoError(t, err)
+	assert.Len(t, got, 3)
+	got, err = collectBars(s.Client().GetBars("AAPL", v2.Min, v2.Raw, start.Add(time.Minute), start.Add(2*time.Minute), 100))
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+
+	got, err = collectBars(s.Client().GetBars("MSFT", v2.Min, v2.Raw, start, end, 100))
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestEmptyPages(t *testing.T) {
+	s := NewServer(WithPageSize(2), WithEmptyPages())
+	defer s.Close()
+	trades := []v2.Trade{
+		{ID: 1, Price: 100, Size: 10, Timestamp: start},
+		{ID: 2, Price: 101, Size: 20, Timestamp: start.Add(time.Second)},
+		{ID: 3, Price: 102, Size: 30, Timestamp: start.Add(2 * time.Second)},
+	}
+	s.AddTrades("AAPL", trades...)
+
+	var ids []int64
+	for item := range s.Client().GetTrades("AAPL", start, end, 100) {
+		require.NoError(t, item.Error)
+		ids = append(ids, item.Trade.ID)
+	}
+	assert.Equal(t, []int64{1, 2, 3}, ids)
+	// empty, 2 trades, empty, 1 trade
+	assert.Len(t, s.Requests(), 4)
+
+	latest, err := s.Client().GetLatestTrade("AAPL")
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), latest.ID)
+	_, err = s.Client().GetLatestQuote("AAPL")
+	assert.Error(t, err)
+}
+
+func TestMalformedRows(t *testing.T) {
+	s := NewServer(WithPageSize(2))
+	defer s.Close()
+	s.AddQuotes("AAPL",
+		v2.Quote{BidPrice: 99, AskPrice: 101, Timestamp: start},
+		v2.Quote{BidPrice: 99, AskPrice: 102, Timestamp: start.Add(time.Second)},
+	)
+	s.AddRawQuote("AAPL", start.Add(2*time.Second), `{"bp":"not a price"}`)
+
+	var quotes []v2.Quote
+	var err error
+	for item := range s.Client().GetQuotes("AAPL", start, end, 100) {
+		if item.Error != nil {
+			err = item.Error
+			continue
+		}
+		quotes = append(quotes, item.Quote)
+	}
+	assert.Len(t, quotes, 2)
+	assert.Error(t, err)
+}
+
+func TestThrottle(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.AddBars("AAPL", v2.Min, minuteBars(1)...)
+
+	// the client retries a second after a 429
+	s.Throttle(1)
+	c := clock.NewFake(start)
+	go func() {
+		c.BlockUntil(1)
+		c.Advance(time.Second)
+	}()
+	got, err := collectBars(s.Client(alpaca.WithClock(c)).GetBars("AAPL", v2.Min, v2.Raw, start, end, 100))
+	require.NoError(t, err)
+	assert.Len(t, got, 1)
+	assert.Len(t, s.Requests(), 2)
+}
+
+func TestRateLimit(t *testing.T) {
+	now := start
+	s := NewServer(WithRateLimit(2), WithNow(func() time.Time { return now }))
+	defer s.Close()
+
+	get := func() *http.Response {
+		req, err := http.NewRequest(http.MethodGet, s.URL+"/v2/stocks/AAPL/bars?timeframe=1Min", nil)
+		require.NoError(t, err)
+		req.Header.Set("APCA-API-KEY-ID", s.Credentials().ID)
+		req.Header.Set("APCA-API-SECRET-KEY", s.Credentials().Secret)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := get()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "2", resp.Header.Get("X-RateLimit-Limit"))
+	assert.Equal(t, "1", resp.Header.Get("X-RateLimit-Remaining"))
+	assert.Equal(t, "1614695460", resp.Header.Get("X-RateLimit-Reset"))
+	assert.Equal(t, http.StatusOK, get().StatusCode)
+	resp = get()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "0", resp.Header.Get("X-RateLimit-Remaining"))
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, http.StatusOK, get().StatusCode)
+}
+
+func TestSnapshots(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetSnapshot("AAPL", v2.Snapshot{
+		LatestTrade: &v2.Trade{Price: 120, Timestamp: start},
+		DailyBar:    &v2.Bar{Close: 119, Timestamp: start},
+	})
+	c := s.Client()
+
+	snapshot, err := c.GetSnapshot("AAPL")
+	require.NoError(t, err)
+	assert.Equal(t, 120.0, snapshot.LatestTrade.Price)
+	assert.Nil(t, snapshot.LatestQuote)
+	_, err = c.GetSnapshot("MSFT")
+	assert.Error(t, err)
+
+	snapshots, err := c.GetSnapshots([]string{"AAPL", "MSFT"})
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, 119.0, snapshots["AAPL"].DailyBar.Close)
+	assert.Nil(t, snapshots["MSFT"])
+}
+
+func TestCryptoPairs(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.AddCryptoPairs(
+		alpaca.CryptoPair{
+			Symbol: "BTC/USD", Status: "active", MinOrderSize: decimal.New(1, -4),
+			Exchanges: []string{"CBSE", "ERSX"},
+		},
+		alpaca.CryptoPair{Symbol: "DOGE/USD", Status: "inactive"},
+	)
+	c := s.Client()
+
+	pairs, err := c.ListCryptoPairs(nil)
+	require.NoError(t, err)
+	require.Len(t, pairs, 2)
+
+	active := "active"
+	pairs, err = c.ListCryptoPairs(&active)
+	require.NoError(t, err)
+	require.Len(t, pairs, 1)
+	assert.Equal(t, "0.0001", pairs[0].MinOrderSize.String())
+	assert.True(t, pairs[0].TradesOn("ERSX"))
+	assert.False(t, pairs[0].TradesOn("FTXU"))
+	assert.Equal(t, []string{"/v1beta1/crypto/pairs", "/v1beta1/crypto/pairs?status=active"}, s.Requests())
+}
+
+func TestCryptoSnapshot(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.SetCryptoSnapshot("BTC/USD", v2.CryptoSnapshot{
+		LatestTrade: &v2.CryptoTrade{Exchange: "ERSX", Price: 50010, Size: 0.5},
+		Exchanges: map[string]v2.CryptoExchangeSnapshot{
+			"CBSE": {LatestQuote: &v2.CryptoQuote{BidPrice: 50000, BidSize: 1, AskPrice: 50020, AskSize: 2}},
+			"ERSX": {LatestQuote: &v2.CryptoQuote{BidPrice: 50030, BidSize: 3, AskPrice: 50040, AskSize: 4}},
+			"FTXU": {LatestTrade: &v2.CryptoTrade{Price: 50005}},
+		},
+	})
+	c := s.Client()
+
+	snapshot, err := c.GetCryptoSnapshot("BTC/USD")
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, snapshot.LatestTrade.Size)
+	require.Len(t, snapshot.Exchanges, 3)
+
+	bbo, ok := snapshot.BestBidAsk()
+	require.True(t, ok)
+	assert.Equal(t, v2.CryptoBBO{
+		BidExchange: "ERSX", BidPrice: 50030, BidSize: 3,
+		AskExchange: "CBSE", AskPrice: 50020, AskSize: 2,
+	}, bbo)
+	assert.Equal(t, -10.0, bbo.Spread())
+
+	spread, ok := snapshot.CrossExchangeSpread("CBSE", "ERSX")
+	require.True(t, ok)
+	assert.Equal(t, 10.0, spread)
+	_, ok = snapshot.CrossExchangeSpread("CBSE", "FTXU")
+	assert.False(t, ok)
+
+	_, err = c.GetCryptoSnapshot("ETH/USD")
+	assert.Error(t, err)
+}
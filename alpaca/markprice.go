@@ -0,0 +1,72 @@
+package alpaca
+
+import (
+	"fmt"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+)
+
+// MarkFallback is why the mark price of a symbol is the price of its latest
+// trade rather than the mid-point of its latest quote
+type MarkFallback string
+
+// List of mark price fallbacks
+const (
+	// MarkFallbackEmptyBook is when the quote is missing its bid, its ask
+	// or both
+	MarkFallbackEmptyBook MarkFallback = "empty_book"
+	// MarkFallbackCrossedBook is when the bid of the quote is above its ask
+	MarkFallbackCrossedBook MarkFallback = "crossed_book"
+)
+
+// MarkPrice is the price a symbol is marked at
+type MarkPrice struct {
+	Symbol string
+	// Bid and Ask are the prices of the latest quote, and Mid their
+	// mid-point when the quote is usable
+	Bid, Ask, Mid float64
+	// Price is Mid, or the price of the latest trade when the quote is not
+	// usable
+	Price float64
+	// Fallback is why Price is the price of the latest trade, and empty if
+	// it is Mid
+	Fallback MarkFallback
+	Quote    *v2.Quote
+	// Trade is the latest trade, only fetched for a fallback
+	Trade *v2.Trade
+}
+
+// GetMarkPrice returns the mark price of symbol: the mid-point of its latest
+// quote, or the price of its latest trade when the quote is one-sided, empty
+// or crossed.
+func (c *Client) GetMarkPrice(symbol string) (*MarkPrice, error) {
+	quote, err := c.GetLatestQuote(symbol)
+	if err != nil {
+		return nil, err
+	}
+	mark := &MarkPrice{Symbol: symbol, Bid: quote.BidPrice, Ask: quote.AskPrice, Quote: quote}
+	switch {
+	case quote.BidPrice <= 0 || quote.AskPrice <= 0:
+		mark.Fallback = MarkFallbackEmptyBook
+	case quote.BidPrice > quote.AskPrice:
+		mark.Fallback = MarkFallbackCrossedBook
+	default:
+		mark.Mid = (quote.BidPrice + quote.AskPrice) / 2
+		mark.Price = mark.Mid
+		return mark, nil
+	}
+
+	trade, err := c.GetLatestTrade(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("latest trade for %s mark price (%s): %w", symbol, mark.Fallback, err)
+	}
+	mark.Trade = trade
+	mark.Price = trade.Price
+	return mark, nil
+}
+
+// GetMarkPrice returns the mark price of symbol
+// with the default Alpaca client.
+func GetMarkPrice(symbol string) (*MarkPrice, error) {
+	return DefaultClient.GetMarkPrice(symbol)
+}
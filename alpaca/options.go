@@ -0,0 +1,131 @@
+package alpaca
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// optionContractsPageLimit is the page size used to list option contracts
+const optionContractsPageLimit = 1000
+
+// OptionContractsPager returns a pager over the option contracts matching
+// the filter.
+func (c *Client) OptionContractsPager(filter GetOptionContractsRequest) *Pager[OptionContract] {
+	rawURL := fmt.Sprintf("%s/%s/options/contracts", c.baseURL(), c.version(TradingEndpoint))
+
+	params := url.Values{}
+	if len(filter.UnderlyingSymbols) > 0 {
+		params.Set("underlying_symbols", strings.Join(filter.UnderlyingSymbols, ","))
+	}
+	if filter.Status != "" {
+		params.Set("status", filter.Status)
+	}
+	if filter.ExpirationDate != "" {
+		params.Set("expiration_date", filter.ExpirationDate)
+	}
+	if filter.ExpirationDateGTE != "" {
+		params.Set("expiration_date_gte", filter.ExpirationDateGTE)
+	}
+	if filter.ExpirationDateLTE != "" {
+		params.Set("expiration_date_lte", filter.ExpirationDateLTE)
+	}
+	if filter.RootSymbol != "" {
+		params.Set("root_symbol", filter.RootSymbol)
+	}
+	if filter.Type != "" {
+		params.Set("type", string(filter.Type))
+	}
+	if filter.Style != "" {
+		params.Set("style", string(filter.Style))
+	}
+	if filter.StrikePriceGTE != nil {
+		params.Set("strike_price_gte", filter.StrikePriceGTE.String())
+	}
+	if filter.StrikePriceLTE != nil {
+		params.Set("strike_price_lte", filter.StrikePriceLTE.String())
+	}
+
+	return NewPager(func(ctx context.Context, pageToken string, limit int) ([]OptionContract, string, error) {
+		var contractsResp optionContractsResponse
+		if err := c.getPage(ctx, rawURL, params, "limit", pageToken, limit, &contractsResp); err != nil {
+			return nil, "", err
+		}
+		return contractsResp.OptionContracts, nextPageToken(contractsResp.NextPageToken), nil
+	}, optionContractsPageLimit)
+}
+
+// GetOptionContracts returns the option contracts matching the filter,
+// following the pagination of the API to return all of them.
+func (c *Client) GetOptionContracts(filter GetOptionContractsRequest) ([]OptionContract, error) {
+	contracts, err := c.OptionContractsPager(filter).All(c.requestContext())
+	if err != nil {
+		return nil, err
+	}
+	if contracts == nil {
+		contracts = []OptionContract{}
+	}
+	return contracts, nil
+}
+
+// GetOptionContract returns an option contract by its symbol or ID.
+func (c *Client) GetOptionContract(symbolOrID string) (*OptionContract, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/options/contracts/%s", c.baseURL(), c.version(TradingEndpoint), symbolOrID))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	contract := &OptionContract{}
+
+	if err = unmarshal(resp, contract); err != nil {
+		return nil, err
+	}
+
+	return contract, nil
+}
+
+// ExerciseOptionPosition exercises the held option contracts of the
+// position with the given symbol or contract ID.
+func (c *Client) ExerciseOptionPosition(symbol string) error {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/positions/%s/exercise", c.baseURL(), c.version(TradingEndpoint), symbol))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := do(c, req)
+	if err != nil {
+		return err
+	}
+
+	return verify(resp)
+}
+
+// GetOptionContracts returns the option contracts matching the filter
+// with the default Alpaca client.
+func GetOptionContracts(filter GetOptionContractsRequest) ([]OptionContract, error) {
+	return DefaultClient.GetOptionContracts(filter)
+}
+
+// GetOptionContract returns an option contract by its symbol or ID
+// with the default Alpaca client.
+func GetOptionContract(symbolOrID string) (*OptionContract, error) {
+	return DefaultClient.GetOptionContract(symbolOrID)
+}
+
+// ExerciseOptionPosition exercises the held option contracts of the
+// position with the default Alpaca client.
+func ExerciseOptionPosition(symbol string) error {
+	return DefaultClient.ExerciseOptionPosition(symbol)
+}
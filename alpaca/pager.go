@@ -0,0 +1,200 @@
+package alpaca
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+)
+
+// ErrOrdersPageStuck is returned by an OrdersPager iteration that cannot
+// move past a page of orders all submitted at the same time.
+var ErrOrdersPageStuck = errors.New("orders page does not advance")
+
+// PageFunc fetches the page of a paginated endpoint following pageToken,
+// "" for the first page, with at most limit items. It returns the token of
+// the next page, "" after the last page.
+type PageFunc[T any] func(ctx context.Context, pageToken string, limit int) (items []T, nextPageToken string, err error)
+
+// Pager pages through a paginated endpoint, e.g.
+//
+//	it := client.TradesPager("AAPL", start, end).Iterate(ctx)
+//	for it.Next() {
+//		trade := it.Value()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Pager[T any] struct {
+	fetch    PageFunc[T]
+	pageSize int
+	limit    int
+}
+
+// NewPager creates a pager fetching pages of at most pageSize items with fetch
+func NewPager[T any](fetch PageFunc[T], pageSize int) *Pager[T] {
+	return &Pager[T]{fetch: fetch, pageSize: pageSize}
+}
+
+// Limit returns a copy of the pager stopping after n items, or after the
+// last page if n is 0
+func (p *Pager[T]) Limit(n int) *Pager[T] {
+	clone := *p
+	clone.limit = n
+	return &clone
+}
+
+// Iterate returns an iterator over the items of the pages. The pages are
+// fetched with ctx as the iterator reaches them.
+func (p *Pager[T]) Iterate(ctx context.Context) *Iterator[T] {
+	return &Iterator[T]{pager: p, ctx: ctx}
+}
+
+// All returns the items of all the pages
+func (p *Pager[T]) All(ctx context.Context) ([]T, error) {
+	var items []T
+	it := p.Iterate(ctx)
+	for it.Next() {
+		items = append(items, it.Value())
+	}
+	return items, it.Err()
+}
+
+// Iterator iterates over the items of a Pager. Call Next before every
+// Value and check Err once Next returns false.
+type Iterator[T any] struct {
+	pager *Pager[T]
+	ctx   context.Context
+
+	page      []T
+	pageToken string
+	fetched   bool
+	count     int
+	value     T
+	err       error
+}
+
+// Next advances to the next item, fetching the next page if needed. It
+// returns false after the last item or on error.
+func (it *Iterator[T]) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	limit := it.pager.limit
+	if limit > 0 && it.count >= limit {
+		return false
+	}
+	for len(it.page) == 0 {
+		if it.fetched && it.pageToken == "" {
+			return false
+		}
+		size := it.pager.pageSize
+		if limit > 0 && (size <= 0 || limit-it.count < size) {
+			size = limit - it.count
+		}
+		it.page, it.pageToken, it.err = it.pager.fetch(it.ctx, it.pageToken, size)
+		it.fetched = true
+		if it.err != nil {
+			return false
+		}
+	}
+	it.value, it.page = it.page[0], it.page[1:]
+	it.count++
+	return true
+}
+
+// Value returns the current item
+func (it *Iterator[T]) Value() T {
+	return it.value
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// TradesPager returns a pager over the trades for the given symbol
+// that happened between the given start and end times
+// with the default Alpaca client.
+func TradesPager(symbol string, start, end time.Time) *Pager[v2.Trade] {
+	return DefaultClient.TradesPager(symbol, start, end)
+}
+
+// QuotesPager returns a pager over the quotes for the given symbol
+// that happened between the given start and end times
+// with the default Alpaca client.
+func QuotesPager(symbol string, start, end time.Time) *Pager[v2.Quote] {
+	return DefaultClient.QuotesPager(symbol, start, end)
+}
+
+// BarsPager returns a pager over the bars for the given symbol
+// between the given start and end times, using the given timeframe
+// and adjustment with the default Alpaca client.
+func BarsPager(
+	symbol string, timeFrame v2.TimeFrame, adjustment v2.Adjustment, start, end time.Time,
+) *Pager[v2.Bar] {
+	return DefaultClient.BarsPager(symbol, timeFrame, adjustment, start, end)
+}
+
+// OrdersPager returns a pager over the orders for the account with the
+// given status with the default Alpaca client.
+func OrdersPager(status string, nested bool) *Pager[Order] {
+	return DefaultClient.OrdersPager(status, nested)
+}
+
+// ActivitiesPager returns a pager over the account activities
+// with the default Alpaca client.
+func ActivitiesPager(activityType *string, opts *AccountActivitiesRequest) *Pager[AccountActivity] {
+	return DefaultClient.ActivitiesPager(activityType, opts)
+}
+
+// OptionContractsPager returns a pager over the option contracts matching
+// the filter with the default Alpaca client.
+func OptionContractsPager(filter GetOptionContractsRequest) *Pager[OptionContract] {
+	return DefaultClient.OptionContractsPager(filter)
+}
+
+// getPage fetches into data the page of rawURL with params following
+// pageToken, "" for the first page, with at most limit items set as the
+// limitParam parameter
+func (c *Client) getPage(
+	ctx context.Context, rawURL string, params url.Values, limitParam, pageToken string, limit int, data interface{},
+) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	for key, values := range params {
+		q[key] = values
+	}
+	q.Set(limitParam, strconv.Itoa(limit))
+	if pageToken != "" {
+		q.Set("page_token", pageToken)
+	}
+	u.RawQuery = q.Encode()
+
+	resp, err := c.WithContext(ctx).get(u)
+	if err != nil {
+		return err
+	}
+	return unmarshal(resp, data)
+}
+
+// requestContext returns the context the REST calls of the client use
+func (c *Client) requestContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+func nextPageToken(token *string) string {
+	if token == nil {
+		return ""
+	}
+	return *token
+}
@@ -0,0 +1,83 @@
+package alpaca
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// maxDayTrades is the number of day trades a non pattern day trader
+	// account can make in 5 business days without being flagged
+	maxDayTrades = 3
+)
+
+// pdtEquityThreshold is the equity above which the pattern day trader
+// rule does not restrict day trading
+var pdtEquityThreshold = decimal.New(25000, 0)
+
+// marketLocation is the time zone trading days are counted in
+var marketLocation = loadMarketLocation()
+
+func loadMarketLocation() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// DayTradesRemaining returns how many more day trades the account can make
+// before it is flagged as a pattern day trader, or -1 if it is not limited,
+// i.e. it is already flagged or its equity is above $25,000.
+func (a *Account) DayTradesRemaining() int {
+	if a.PatternDayTrader || a.Equity.GreaterThanOrEqual(pdtEquityThreshold) {
+		return -1
+	}
+	if remaining := maxDayTrades - int(a.DaytradeCount); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// WouldTriggerPDT returns true if the proposed order would complete a day
+// trade, i.e. it closes a position in a symbol traded in the other direction
+// today, while the account has no day trades remaining. Such an order would
+// get the account flagged as a pattern day trader.
+func (c *Client) WouldTriggerPDT(proposed PlaceOrderRequest) (bool, error) {
+	if proposed.AssetKey == nil {
+		return false, nil
+	}
+
+	acct, err := c.GetAccount()
+	if err != nil {
+		return false, err
+	}
+	if acct.DayTradesRemaining() != 0 {
+		return false, nil
+	}
+
+	today := time.Now().In(marketLocation)
+	activityType := ActivityFill
+	fills, err := c.GetAccountActivities(&activityType, &AccountActivitiesRequest{Date: &today})
+	if err != nil {
+		return false, err
+	}
+	for _, fill := range fills {
+		if fill.Symbol != *proposed.AssetKey {
+			continue
+		}
+		// short sells are reported as sell_short
+		if !strings.HasPrefix(fill.Side, string(proposed.Side)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// WouldTriggerPDT returns true if the proposed order would get the
+// account flagged as a pattern day trader.
+func WouldTriggerPDT(proposed PlaceOrderRequest) (bool, error) {
+	return DefaultClient.WouldTriggerPDT(proposed)
+}
@@ -0,0 +1,81 @@
+package alpaca
+
+import (
+	"time"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+)
+
+// PositionBars is an open position with its recent bars and snapshot
+type PositionBars struct {
+	Position Position
+	// Bars are the split adjusted bars of the lookback period
+	Bars     []v2.Bar
+	Snapshot *v2.Snapshot
+}
+
+// GetPositionBars returns the open stock positions of the account, by
+// symbol, with their split adjusted bars of the given timeframe over the
+// lookback period up to now and their snapshots. The bars and snapshots of
+// all the positions are fetched with one multi-symbol request each. The
+// positions in other asset classes are left out.
+func (c *Client) GetPositionBars(timeFrame v2.TimeFrame, lookback time.Duration) (map[string]*PositionBars, error) {
+	positions, err := c.ListPositions()
+	if err != nil {
+		return nil, err
+	}
+	end := c.clock().Now()
+	return positionBars(c, positions, timeFrame, end.Add(-lookback), end)
+}
+
+// positionBars fetches the bars and snapshots of the stock positions with
+// data
+func positionBars(
+	data TradingClient, positions []Position, timeFrame v2.TimeFrame, start, end time.Time,
+) (map[string]*PositionBars, error) {
+	result := map[string]*PositionBars{}
+	var symbols []string
+	for _, p := range positions {
+		if p.Class != "" && p.Class != USEquity {
+			continue
+		}
+		result[p.Symbol] = &PositionBars{Position: p}
+		symbols = append(symbols, p.Symbol)
+	}
+	if len(symbols) == 0 {
+		return result, nil
+	}
+
+	bars, err := data.GetMultiBars(symbols, timeFrame, v2.Split, start, end)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := data.GetSnapshots(symbols)
+	if err != nil {
+		return nil, err
+	}
+	for symbol, pb := range result {
+		pb.Bars = bars[symbol]
+		pb.Snapshot = snapshots[symbol]
+	}
+	return result, nil
+}
+
+// PositionBarsOf returns the open stock positions of client, by symbol,
+// with their bars and snapshots fetched with data, e.g. for a simulator
+// whose positions are not those of the market data client.
+func PositionBarsOf(
+	client, data TradingClient, timeFrame v2.TimeFrame, start, end time.Time,
+) (map[string]*PositionBars, error) {
+	positions, err := client.ListPositions()
+	if err != nil {
+		return nil, err
+	}
+	return positionBars(data, positions, timeFrame, start, end)
+}
+
+// GetPositionBars returns the open stock positions of the account with
+// their bars and snapshots with the default Alpaca client.
+func GetPositionBars(timeFrame v2.TimeFrame, lookback time.Duration) (map[string]*PositionBars, error) {
+	return DefaultClient.GetPositionBars(timeFrame, lookback)
+}
@@ -0,0 +1,65 @@
+package alpaca
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit is the state of the rate limit of the API, as reported by the
+// X-RateLimit-* headers of a response
+type RateLimit struct {
+	// Limit is the number of requests allowed per window
+	Limit int
+	// Remaining is the number of requests left in the current window
+	Remaining int
+	// Reset is when the current window ends
+	Reset time.Time
+}
+
+// parseRateLimit reads the rate limit headers of a response. It returns
+// false if the response has none.
+func parseRateLimit(header http.Header) (RateLimit, bool) {
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return RateLimit{}, false
+	}
+	rl := RateLimit{Limit: limit}
+	rl.Remaining, _ = strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		rl.Reset = time.Unix(reset, 0)
+	}
+	return rl, true
+}
+
+// rateLimitTracker holds the last rate limit seen by a client
+type rateLimitTracker struct {
+	mu   sync.Mutex
+	last RateLimit
+	seen bool
+}
+
+func (t *rateLimitTracker) update(header http.Header) {
+	rl, ok := parseRateLimit(header)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.last, t.seen = rl, true
+}
+
+func (t *rateLimitTracker) get() (RateLimit, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last, t.seen
+}
+
+// RateLimit returns the rate limit reported by the last response of the
+// client that had rate limit headers, and false if there was none yet. The
+// copies made by WithContext share it with the client, the ones made by
+// WithCredentials, which are limited separately, have their own.
+func (c *Client) RateLimit() (RateLimit, bool) {
+	return c.rateLimit.get()
+}
@@ -0,0 +1,76 @@
+package alpaca
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DoRaw sends a request to an endpoint this package does not wrap (yet) and
+// returns the JSON response. The path is relative to the trading API URL,
+// e.g. "v2/account", unless it is an absolute URL such as one of the data API.
+// The body, if not nil, is sent as JSON. A client with order limits refuses
+// to place or replace orders with DoRaw, which would bypass the limits.
+func (c *Client) DoRaw(method, path string, body interface{}) (json.RawMessage, error) {
+	u := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		u = fmt.Sprintf("%s/%s", c.baseURL(), strings.TrimPrefix(path, "/"))
+	}
+	if c.orderLimits != nil && isOrderSubmission(method, u) {
+		return nil, limitExceeded("%s %s bypasses the order limits, use PlaceOrder or ReplaceOrder", method, path)
+	}
+
+	var r io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := do(c, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// isOrderSubmission returns true if the request places or replaces orders
+func isOrderSubmission(method, rawURL string) bool {
+	if method == http.MethodGet || method == http.MethodDelete {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// refused by http.NewRequest anyway
+		return false
+	}
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment == "orders" {
+			return true
+		}
+	}
+	return false
+}
+
+// DoRaw sends a request to an endpoint this package does not wrap
+// with the default Alpaca client.
+func DoRaw(method, path string, body interface{}) (json.RawMessage, error) {
+	return DefaultClient.DoRaw(method, path, body)
+}
@@ -0,0 +1,60 @@
+package alpaca
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader is the header carrying the ID of a REST call, sent by the
+// client and echoed by the API. Quote it when contacting Alpaca support
+// about a call.
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// ContextWithRequestID returns a copy of ctx making the calls of a client
+// created with WithContext send id instead of a generated request ID, e.g.
+// to reuse the ID of the incoming request that led to the call.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID set with ContextWithRequestID
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// setRequestID sets the request ID header of req, from its context or a
+// new random one, unless it is already set
+func setRequestID(req *http.Request) {
+	if req.Header.Get(RequestIDHeader) != "" {
+		return
+	}
+	id, ok := RequestIDFromContext(req.Context())
+	if !ok {
+		id = newRequestID()
+	}
+	req.Header.Set(RequestIDHeader, id)
+}
+
+// newRequestID returns a random 128 bit ID in hex
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// responseRequestID returns the request ID of resp: the one the API
+// answered with, or else the one the client sent
+func responseRequestID(resp *http.Response) string {
+	if id := resp.Header.Get(RequestIDHeader); id != "" {
+		return id
+	}
+	if resp.Request != nil {
+		return resp.Request.Header.Get(RequestIDHeader)
+	}
+	return ""
+}
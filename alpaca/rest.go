@@ -2,6 +2,7 @@ package alpaca
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -13,6 +14,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
 	"github.com/market-development-strategy/alpaca-trade-api-go/common"
 	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
 )
@@ -23,41 +25,60 @@ const (
 )
 
 var (
-	// DefaultClient is the default Alpaca client using the
-	// environment variable set credentials
-	DefaultClient = NewClient(common.Credentials())
-	base          = "https://api.alpaca.markets"
-	dataURL       = "https://data.alpaca.markets"
+	// DefaultClient is the default Alpaca client. It has no credentials of
+	// its own and reads common.Credentials() for every request.
+	DefaultClient = NewClient(nil)
+	// base is the trading API URL set by SetBaseUrl
+	base          string
 	apiVersion    = "v2"
 	clientTimeout = 10 * time.Second
 	do            = defaultDo
 )
 
-func defaultDo(c *Client, req *http.Request) (*http.Response, error) {
-	if c.credentials.OAuth != "" {
-		req.Header.Set("Authorization", "Bearer "+c.credentials.OAuth)
-	} else {
-		req.Header.Set("APCA-API-KEY-ID", c.credentials.ID)
-		req.Header.Set("APCA-API-SECRET-KEY", c.credentials.Secret)
+func defaultDo(c *Client, req *http.Request) (resp *http.Response, err error) {
+	if c.ctx != nil {
+		req = req.WithContext(c.ctx)
+	}
+	setRequestID(req)
+	req.Header.Set("User-Agent", common.UserAgent(c.application))
+	retries, statusCode := 0, 0
+	if c.tracer != nil {
+		var end func(statusCode, retries int, err error)
+		req, end = c.tracer.StartRequest(req)
+		defer func() {
+			end(statusCode, retries, err)
+		}()
+	}
+
+	if err := c.setAuthHeaders(req); err != nil {
+		return nil, err
 	}
 
-	client := &http.Client{
-		Timeout: clientTimeout,
+	client := c.httpClient
+	if client == nil {
+		client = &http.Client{
+			Timeout: clientTimeout,
+		}
 	}
-	var resp *http.Response
-	var err error
-	for i := 0; ; i++ {
+	for ; ; retries++ {
+		sent := c.clock().Now()
 		resp, err = client.Do(req)
 		if err != nil {
 			return nil, err
 		}
+		statusCode = resp.StatusCode
+		c.rateLimit.update(resp.Header)
+		c.timeSync.observe(resp.Header, sent, c.clock().Now())
 		if resp.StatusCode != http.StatusTooManyRequests {
 			break
 		}
-		if i >= rateLimitRetryCount {
+		if retries >= rateLimitRetryCount {
 			break
 		}
-		time.Sleep(rateLimitRetryDelay)
+		c.logger().Warn("rate limited, retrying",
+			"client", "rest", "method", req.Method, "path", req.URL.Path,
+			"request_id", req.Header.Get(RequestIDHeader), "attempt", retries+1, "delay", rateLimitRetryDelay)
+		c.clock().Sleep(rateLimitRetryDelay)
 	}
 
 	if err = verify(resp); err != nil {
@@ -67,25 +88,39 @@ func defaultDo(c *Client, req *http.Request) (*http.Response, error) {
 	return resp, nil
 }
 
+func (c *Client) setAuthHeaders(req *http.Request) error {
+	credentials := c.credentials
+	if c.credentialsProvider != nil {
+		key, err := c.credentialsProvider.Get(req.Context())
+		if err != nil {
+			return fmt.Errorf("failed to get credentials: %w", err)
+		}
+		credentials = &key
+	}
+	if credentials == nil {
+		// clients created without credentials use the environment
+		credentials = common.Credentials()
+	}
+
+	if credentials.OAuth != "" {
+		req.Header.Set("Authorization", "Bearer "+credentials.OAuth)
+	} else {
+		req.Header.Set("APCA-API-KEY-ID", credentials.ID)
+		req.Header.Set("APCA-API-SECRET-KEY", credentials.Secret)
+	}
+	return nil
+}
+
 const (
 	// v2MaxLimit is the maximum allowed limit parameter for all v2 endpoints
 	v2MaxLimit = 10000
+	// ordersMaxLimit is the maximum allowed limit parameter to list orders
+	ordersMaxLimit = 500
+	// activitiesMaxPageSize is the maximum allowed page size of the activities
+	activitiesMaxPageSize = 100
 )
 
 func init() {
-	if s := os.Getenv("APCA_API_BASE_URL"); s != "" {
-		base = s
-	} else if s := os.Getenv("ALPACA_BASE_URL"); s != "" {
-		// legacy compatibility...
-		base = s
-	}
-	if s := os.Getenv("APCA_DATA_URL"); s != "" {
-		dataURL = s
-	}
-	// also allow APCA_API_DATA_URL to be consistent with the python SDK
-	if s := os.Getenv("APCA_API_DATA_URL"); s != "" {
-		dataURL = s
-	}
 	if s := os.Getenv("APCA_API_VERSION"); s != "" {
 		apiVersion = s
 	}
@@ -95,6 +130,8 @@ func init() {
 			log.Fatal("invalid APCA_API_CLIENT_TIMEOUT: " + err.Error())
 		}
 		clientTimeout = d
+		// DefaultClient is created before init runs
+		DefaultClient.httpClient.Timeout = d
 	}
 }
 
@@ -103,6 +140,10 @@ func init() {
 type APIError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	// RequestID is the ID of the failed call, see RequestIDHeader
+	RequestID string `json:"-"`
+	// StatusCode is the HTTP status of the failed call
+	StatusCode int `json:"-"`
 }
 
 func (e *APIError) Error() string {
@@ -111,22 +152,236 @@ func (e *APIError) Error() string {
 
 // Client is an Alpaca REST API client
 type Client struct {
-	credentials *common.APIKey
-}
-
+	credentials         *common.APIKey
+	credentialsProvider common.CredentialsProvider
+	httpClient          *http.Client
+	ctx                 context.Context
+	tracer              RequestTracer
+	clk                 clock.Clock
+	lg                  common.Logger
+	application         string
+	base                string
+	data                string
+
+	validateShortSells bool
+	dryRun             bool
+	debugLogger        common.Logger
+	debugBodies        bool
+	roundPrices        bool
+	orderLimits        *orderLimits
+	journal            *Journal
+	assets             *assetCache
+	rateLimit          *rateLimitTracker
+	timeSync           *timeSync
+	versions           map[APIEndpoint]string
+}
+
+// ClientOption configures optional behaviour of a Client
+type ClientOption func(c *Client)
+
+// WithShortSellValidation makes PlaceOrder check that the asset can be
+// shorted before submitting a sell order that would open a short position.
+// Orders for assets that are not shortable fail with a *NotShortableError.
+func WithShortSellValidation() ClientOption {
+	return func(c *Client) {
+		c.validateShortSells = true
+	}
+}
+
+// SetBaseUrl sets the trading API URL of DefaultClient and of the clients
+// created afterwards without WithBaseURL.
+//
+// Deprecated: the setting is process-global; use WithBaseURL instead.
 func SetBaseUrl(baseUrl string) {
 	base = baseUrl
+	DefaultClient.base = baseUrl
+}
+
+// defaultBaseURL returns the trading API URL of a new client created
+// without WithBaseURL.
+func defaultBaseURL() string {
+	if base != "" {
+		return base
+	}
+	if s := os.Getenv("APCA_API_BASE_URL"); s != "" {
+		return s
+	}
+	if s := os.Getenv("ALPACA_BASE_URL"); s != "" {
+		// legacy compatibility...
+		return s
+	}
+	return "https://api.alpaca.markets"
+}
+
+// defaultDataURL returns the market data API URL of a new client created
+// without WithDataURL.
+func defaultDataURL() string {
+	// also allow APCA_API_DATA_URL to be consistent with the python SDK
+	if s := os.Getenv("APCA_API_DATA_URL"); s != "" {
+		return s
+	}
+	if s := os.Getenv("APCA_DATA_URL"); s != "" {
+		return s
+	}
+	return "https://data.alpaca.markets"
+}
+
+// WithBaseURL sets the trading API URL of the client, e.g.
+// "https://paper-api.alpaca.markets" for paper trading. It defaults to
+// the APCA_API_BASE_URL environment variable or the live trading URL.
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.base = baseURL
+	}
+}
+
+// WithDataURL sets the market data API URL of the client. It defaults to
+// the APCA_API_DATA_URL environment variable or the Alpaca data URL.
+func WithDataURL(dataURL string) ClientOption {
+	return func(c *Client) {
+		c.data = dataURL
+	}
+}
+
+// WithCredentials sets the credentials of a client created by
+// NewClientWithOptions.
+func WithCredentials(credentials *common.APIKey) ClientOption {
+	return func(c *Client) {
+		c.credentials = credentials
+	}
+}
+
+// WithCredentialsProvider makes the client get its credentials from provider
+// for every request instead of using fixed credentials, so they can be rotated
+// while the client is in use.
+func WithCredentialsProvider(provider common.CredentialsProvider) ClientOption {
+	return func(c *Client) {
+		c.credentialsProvider = provider
+	}
+}
+
+// WithClock sets the clock the client waits on before retrying a rate
+// limited request, clock.Real by default.
+func WithClock(c clock.Clock) ClientOption {
+	return func(cl *Client) {
+		cl.clk = c
+	}
+}
+
+// clock returns the clock of the client
+func (c *Client) clock() clock.Clock {
+	if c.clk != nil {
+		return c.clk
+	}
+	return clock.Real
+}
+
+// WithLogger sets the logger of the client, common.DefaultLogger by
+// default, e.g. a *slog.Logger.
+func WithLogger(logger common.Logger) ClientOption {
+	return func(c *Client) {
+		c.lg = logger
+	}
+}
+
+// logger returns the logger of the client
+func (c *Client) logger() common.Logger {
+	if c.lg != nil {
+		return c.lg
+	}
+	return common.DefaultLogger
+}
+
+// WithUserAgent adds application, e.g. "my-bot/2.1", to the User-Agent
+// header of the requests, in front of the SDK and its version.
+func WithUserAgent(application string) ClientOption {
+	return func(c *Client) {
+		c.application = application
+	}
+}
+
+// baseURL returns the client's trading API URL
+func (c *Client) baseURL() string {
+	return c.base
+}
+
+// dataBaseURL returns the client's market data API URL
+func (c *Client) dataBaseURL() string {
+	return c.data
+}
+
+// WithPriceRounding makes PlaceOrder round the limit and stop prices of
+// orders to valid tick sizes (see RoundToTick) instead of letting the
+// API reject sub-penny prices.
+func WithPriceRounding() ClientOption {
+	return func(c *Client) {
+		c.roundPrices = true
+	}
 }
 
 // NewClient creates a new Alpaca client with specified
-// credentials
-func NewClient(credentials *common.APIKey) *Client {
-	return &Client{credentials: credentials}
+// credentials and options. A client created with nil credentials and no
+// WithCredentialsProvider reads common.Credentials() for every request.
+// The trading and data URLs the options leave unset are resolved once, from
+// SetBaseUrl or the environment, when the client is created.
+func NewClient(credentials *common.APIKey, opts ...ClientOption) *Client {
+	c := &Client{
+		credentials: credentials,
+		httpClient: &http.Client{
+			Timeout: clientTimeout,
+		},
+		assets:    newAssetCache(assetCacheTTL),
+		rateLimit: &rateLimitTracker{},
+		timeSync:  &timeSync{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.base == "" {
+		c.base = defaultBaseURL()
+	}
+	if c.data == "" {
+		c.data = defaultDataURL()
+	}
+	if c.debugLogger != nil {
+		c.httpClient.Transport = &debugTransport{
+			next:   http.DefaultTransport,
+			logger: c.debugLogger,
+			bodies: c.debugBodies,
+		}
+	}
+	return c
+}
+
+// NewClientWithOptions creates a new Alpaca client configured only by its
+// options, without relying on process-global settings. For example
+//
+//	client := alpaca.NewClientWithOptions(
+//		alpaca.WithBaseURL("https://paper-api.alpaca.markets"),
+//		alpaca.WithCredentials(&common.APIKey{ID: id, Secret: secret}),
+//	)
+//
+// Without WithCredentials or WithCredentialsProvider, the client reads
+// common.Credentials() for every request.
+func NewClientWithOptions(opts ...ClientOption) *Client {
+	return NewClient(nil, opts...)
+}
+
+// WithCredentials returns a lightweight copy of the client that authenticates
+// with the given credentials. The copy shares the underlying HTTP client (and
+// its connection pool) with the original, so it is cheap to create one per
+// account or even per request, e.g. client.WithCredentials(creds).PlaceOrder(req).
+func (c *Client) WithCredentials(credentials *common.APIKey) *Client {
+	clone := *c
+	clone.credentials = credentials
+	clone.credentialsProvider = nil
+	clone.rateLimit = &rateLimitTracker{}
+	return &clone
 }
 
 // GetAccount returns the user's account information.
 func (c *Client) GetAccount() (*Account, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/account", base, apiVersion))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/account", c.baseURL(), c.version(TradingEndpoint)))
 	if err != nil {
 		return nil, err
 	}
@@ -147,7 +402,7 @@ func (c *Client) GetAccount() (*Account, error) {
 
 // GetConfigs returns the current account configurations
 func (c *Client) GetAccountConfigurations() (*AccountConfigurations, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/account/configurations", base, apiVersion))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/account/configurations", c.baseURL(), c.version(TradingEndpoint)))
 	if err != nil {
 		return nil, err
 	}
@@ -168,7 +423,7 @@ func (c *Client) GetAccountConfigurations() (*AccountConfigurations, error) {
 
 // EditConfigs patches the account configs
 func (c *Client) UpdateAccountConfigurations(newConfigs AccountConfigurationsRequest) (*AccountConfigurations, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/account/configurations", base, apiVersion))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/account/configurations", c.baseURL(), c.version(TradingEndpoint)))
 	if err != nil {
 		return nil, err
 	}
@@ -188,37 +443,14 @@ func (c *Client) UpdateAccountConfigurations(newConfigs AccountConfigurationsReq
 }
 
 func (c *Client) GetAccountActivities(activityType *string, opts *AccountActivitiesRequest) ([]AccountActivity, error) {
-	var u *url.URL
-	var err error
-	if activityType == nil {
-		u, err = url.Parse(fmt.Sprintf("%s/%s/account/activities", base, apiVersion))
-	} else {
-		u, err = url.Parse(fmt.Sprintf("%s/%s/account/activities/%s", base, apiVersion, *activityType))
-	}
+	u, err := url.Parse(c.activitiesURL(activityType))
 	if err != nil {
 		return nil, err
 	}
 
-	q := u.Query()
-	if opts != nil {
-		if opts.ActivityTypes != nil {
-			q.Set("activity_types", strings.Join(*opts.ActivityTypes, ","))
-		}
-		if opts.Date != nil {
-			q.Set("date", opts.Date.String())
-		}
-		if opts.Until != nil {
-			q.Set("until", opts.Until.String())
-		}
-		if opts.After != nil {
-			q.Set("after", opts.After.String())
-		}
-		if opts.Direction != nil {
-			q.Set("direction", *opts.Direction)
-		}
-		if opts.PageSize != nil {
-			q.Set("page_size", strconv.Itoa(*opts.PageSize))
-		}
+	q := activitiesParams(opts)
+	if opts != nil && opts.PageSize != nil {
+		q.Set("page_size", strconv.Itoa(*opts.PageSize))
 	}
 
 	u.RawQuery = q.Encode()
@@ -236,8 +468,61 @@ func (c *Client) GetAccountActivities(activityType *string, opts *AccountActivit
 	return activities, nil
 }
 
+// ActivitiesPager returns a pager over the account activities of the given
+// type, or of all types if activityType is nil, filtered by opts. The pages
+// hold opts.PageSize activities if set.
+func (c *Client) ActivitiesPager(activityType *string, opts *AccountActivitiesRequest) *Pager[AccountActivity] {
+	rawURL := c.activitiesURL(activityType)
+	params := activitiesParams(opts)
+	pageSize := activitiesMaxPageSize
+	if opts != nil && opts.PageSize != nil {
+		pageSize = *opts.PageSize
+	}
+	return NewPager(func(ctx context.Context, pageToken string, limit int) ([]AccountActivity, string, error) {
+		var activities []AccountActivity
+		if err := c.getPage(ctx, rawURL, params, "page_size", pageToken, limit, &activities); err != nil {
+			return nil, "", err
+		}
+		// the activities are paged by the ID of the last activity of the page
+		if len(activities) < limit {
+			return activities, "", nil
+		}
+		return activities, activities[len(activities)-1].ID, nil
+	}, pageSize)
+}
+
+func (c *Client) activitiesURL(activityType *string) string {
+	if activityType == nil {
+		return fmt.Sprintf("%s/%s/account/activities", c.baseURL(), c.version(TradingEndpoint))
+	}
+	return fmt.Sprintf("%s/%s/account/activities/%s", c.baseURL(), c.version(TradingEndpoint), *activityType)
+}
+
+func activitiesParams(opts *AccountActivitiesRequest) url.Values {
+	q := url.Values{}
+	if opts == nil {
+		return q
+	}
+	if opts.ActivityTypes != nil {
+		q.Set("activity_types", strings.Join(*opts.ActivityTypes, ","))
+	}
+	if opts.Date != nil {
+		q.Set("date", opts.Date.Format("2006-01-02"))
+	}
+	if opts.Until != nil {
+		q.Set("until", opts.Until.Format(time.RFC3339))
+	}
+	if opts.After != nil {
+		q.Set("after", opts.After.Format(time.RFC3339))
+	}
+	if opts.Direction != nil {
+		q.Set("direction", *opts.Direction)
+	}
+	return q
+}
+
 func (c *Client) GetPortfolioHistory(period *string, timeframe *RangeFreq, dateEnd *time.Time, extendedHours bool) (*PortfolioHistory, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/account/portfolio/history", base, apiVersion))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/account/portfolio/history", c.baseURL(), c.version(TradingEndpoint)))
 
 	if err != nil {
 		return nil, err
@@ -279,7 +564,7 @@ func (c *Client) GetPortfolioHistory(period *string, timeframe *RangeFreq, dateE
 
 // ListPositions lists the account's open positions.
 func (c *Client) ListPositions() ([]Position, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/positions", base, apiVersion))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/positions", c.baseURL(), c.version(TradingEndpoint)))
 	if err != nil {
 		return nil, err
 	}
@@ -300,7 +585,7 @@ func (c *Client) ListPositions() ([]Position, error) {
 
 // GetPosition returns the account's position for the provided symbol.
 func (c *Client) GetPosition(symbol string) (*Position, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/positions/%s", base, apiVersion, symbol))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/positions/%s", c.baseURL(), c.version(TradingEndpoint), symbol))
 	if err != nil {
 		return nil, err
 	}
@@ -328,7 +613,7 @@ func (c *Client) GetPosition(symbol string) (*Position, error) {
 // GetAggregates returns the bars for the given symbol, timespan and date-range
 func (c *Client) GetAggregates(symbol, timespan, from, to string) (*Aggregates, error) {
 	u, err := url.Parse(fmt.Sprintf("%s/v1/aggs/ticker/%s/range/1/%s/%s/%s",
-		dataURL, symbol, timespan, from, to))
+		c.dataBaseURL(), symbol, timespan, from, to))
 	if err != nil {
 		return nil, err
 	}
@@ -358,7 +643,7 @@ func (c *Client) GetAggregates(symbol, timespan, from, to string) (*Aggregates,
 
 // GetLastQuote returns the last quote for the given symbol
 func (c *Client) GetLastQuote(symbol string) (*LastQuoteResponse, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/v1/last_quote/stocks/%s", dataURL, symbol))
+	u, err := url.Parse(fmt.Sprintf("%s/v1/last_quote/stocks/%s", c.dataBaseURL(), symbol))
 	if err != nil {
 		return nil, err
 	}
@@ -385,7 +670,7 @@ func (c *Client) GetLastQuote(symbol string) (*LastQuoteResponse, error) {
 
 // GetLastTrade returns the last trade for the given symbol
 func (c *Client) GetLastTrade(symbol string) (*LastTradeResponse, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/v1/last/stocks/%s", dataURL, symbol))
+	u, err := url.Parse(fmt.Sprintf("%s/v1/last/stocks/%s", c.dataBaseURL(), symbol))
 	if err != nil {
 		return nil, err
 	}
@@ -410,6 +695,20 @@ func (c *Client) GetLastTrade(symbol string) (*LastTradeResponse, error) {
 	return lastTrade, nil
 }
 
+// TradesPager returns a pager over the trades for the given symbol
+// that happened between the given start and end times.
+func (c *Client) TradesPager(symbol string, start, end time.Time) *Pager[v2.Trade] {
+	rawURL := fmt.Sprintf("%s/%s/stocks/%s/trades", c.dataBaseURL(), c.version(StocksEndpoint), symbol)
+	params := timeRangeParams(start, end)
+	return NewPager(func(ctx context.Context, pageToken string, limit int) ([]v2.Trade, string, error) {
+		var tradeResp tradeResponse
+		if err := c.getPage(ctx, rawURL, params, "limit", pageToken, limit, &tradeResp); err != nil {
+			return nil, "", err
+		}
+		return tradeResp.Trades, nextPageToken(tradeResp.NextPageToken), nil
+	}, v2MaxLimit)
+}
+
 // GetTrades returns a channel that will be populated with the trades for the given symbol
 // that happened between the given start and end times, limited to the given limit.
 func (c *Client) GetTrades(symbol string, start, end time.Time, limit int) <-chan v2.TradeItem {
@@ -418,117 +717,76 @@ func (c *Client) GetTrades(symbol string, start, end time.Time, limit int) <-cha
 	go func() {
 		defer close(ch)
 
-		u, err := url.Parse(fmt.Sprintf("%s/v2/stocks/%s/trades", dataURL, symbol))
-		if err != nil {
-			ch <- v2.TradeItem{Error: err}
+		if limit <= 0 {
 			return
 		}
-
-		q := u.Query()
-		q.Set("start", start.Format(time.RFC3339))
-		q.Set("end", end.Format(time.RFC3339))
-
-		total := 0
-		pageToken := ""
-		for {
-			actualLimit := limit - total
-			if actualLimit <= 0 {
-				return
-			}
-			if actualLimit > v2MaxLimit {
-				actualLimit = v2MaxLimit
-			}
-			q.Set("limit", fmt.Sprintf("%d", actualLimit))
-			q.Set("page_token", pageToken)
-			u.RawQuery = q.Encode()
-
-			resp, err := c.get(u)
-			if err != nil {
-				ch <- v2.TradeItem{Error: err}
-				return
-			}
-
-			var tradeResp tradeResponse
-			if err = unmarshal(resp, &tradeResp); err != nil {
-				ch <- v2.TradeItem{Error: err}
-				return
-			}
-
-			for _, trade := range tradeResp.Trades {
-				ch <- v2.TradeItem{Trade: trade}
-			}
-			if tradeResp.NextPageToken == nil {
-				return
-			}
-			pageToken = *tradeResp.NextPageToken
-			total += len(tradeResp.Trades)
+		it := c.TradesPager(symbol, start, end).Limit(limit).Iterate(c.requestContext())
+		for it.Next() {
+			ch <- v2.TradeItem{Trade: it.Value()}
+		}
+		if err := it.Err(); err != nil {
+			ch <- v2.TradeItem{Error: err}
 		}
 	}()
 
 	return ch
 }
 
+// QuotesPager returns a pager over the quotes for the given symbol
+// that happened between the given start and end times.
+func (c *Client) QuotesPager(symbol string, start, end time.Time) *Pager[v2.Quote] {
+	rawURL := fmt.Sprintf("%s/%s/stocks/%s/quotes", c.dataBaseURL(), c.version(StocksEndpoint), symbol)
+	params := timeRangeParams(start, end)
+	return NewPager(func(ctx context.Context, pageToken string, limit int) ([]v2.Quote, string, error) {
+		var quoteResp quoteResponse
+		if err := c.getPage(ctx, rawURL, params, "limit", pageToken, limit, &quoteResp); err != nil {
+			return nil, "", err
+		}
+		return quoteResp.Quotes, nextPageToken(quoteResp.NextPageToken), nil
+	}, v2MaxLimit)
+}
+
 // GetQuotes returns a channel that will be populated with the quotes for the given symbol
 // that happened between the given start and end times, limited to the given limit.
 func (c *Client) GetQuotes(symbol string, start, end time.Time, limit int) <-chan v2.QuoteItem {
-	// NOTE: this method is very similar to GetTrades.
-	// With generics it would be almost trivial to refactor them to use a common base method,
-	// but without them it doesn't seem to be worth it
 	ch := make(chan v2.QuoteItem)
 
 	go func() {
 		defer close(ch)
 
-		u, err := url.Parse(fmt.Sprintf("%s/v2/stocks/%s/quotes", dataURL, symbol))
-		if err != nil {
-			ch <- v2.QuoteItem{Error: err}
+		if limit <= 0 {
 			return
 		}
-
-		q := u.Query()
-		q.Set("start", start.Format(time.RFC3339))
-		q.Set("end", end.Format(time.RFC3339))
-
-		total := 0
-		pageToken := ""
-		for {
-			actualLimit := limit - total
-			if actualLimit <= 0 {
-				return
-			}
-			if actualLimit > v2MaxLimit {
-				actualLimit = v2MaxLimit
-			}
-			q.Set("limit", fmt.Sprintf("%d", actualLimit))
-			q.Set("page_token", pageToken)
-			u.RawQuery = q.Encode()
-
-			resp, err := c.get(u)
-			if err != nil {
-				ch <- v2.QuoteItem{Error: err}
-				return
-			}
-
-			var quoteResp quoteResponse
-			if err = unmarshal(resp, &quoteResp); err != nil {
-				ch <- v2.QuoteItem{Error: err}
-				return
-			}
-
-			for _, quote := range quoteResp.Quotes {
-				ch <- v2.QuoteItem{Quote: quote}
-			}
-			if quoteResp.NextPageToken == nil {
-				return
-			}
-			pageToken = *quoteResp.NextPageToken
-			total += len(quoteResp.Quotes)
+		it := c.QuotesPager(symbol, start, end).Limit(limit).Iterate(c.requestContext())
+		for it.Next() {
+			ch <- v2.QuoteItem{Quote: it.Value()}
+		}
+		if err := it.Err(); err != nil {
+			ch <- v2.QuoteItem{Error: err}
 		}
 	}()
 
 	return ch
 }
 
+// BarsPager returns a pager over the bars for the given symbol
+// between the given start and end times, using the given timeframe and adjustment.
+func (c *Client) BarsPager(
+	symbol string, timeFrame v2.TimeFrame, adjustment v2.Adjustment, start, end time.Time,
+) *Pager[v2.Bar] {
+	rawURL := fmt.Sprintf("%s/%s/stocks/%s/bars", c.dataBaseURL(), c.version(StocksEndpoint), symbol)
+	params := timeRangeParams(start, end)
+	params.Set("adjustment", string(adjustment))
+	params.Set("timeframe", string(timeFrame))
+	return NewPager(func(ctx context.Context, pageToken string, limit int) ([]v2.Bar, string, error) {
+		var barResp barResponse
+		if err := c.getPage(ctx, rawURL, params, "limit", pageToken, limit, &barResp); err != nil {
+			return nil, "", err
+		}
+		return barResp.Bars, nextPageToken(barResp.NextPageToken), nil
+	}, v2MaxLimit)
+}
+
 // GetBars returns a channel that will be populated with the bars for the given symbol
 // between the given start and end times, limited to the given limit,
 // using the given and timeframe and adjustment.
@@ -541,61 +799,61 @@ func (c *Client) GetBars(
 	go func() {
 		defer close(ch)
 
-		u, err := url.Parse(fmt.Sprintf("%s/v2/stocks/%s/bars", dataURL, symbol))
-		if err != nil {
-			ch <- v2.BarItem{Error: err}
+		if limit <= 0 {
 			return
 		}
-
-		q := u.Query()
-		q.Set("start", start.Format(time.RFC3339))
-		q.Set("end", end.Format(time.RFC3339))
-		q.Set("adjustment", string(adjustment))
-		q.Set("timeframe", string(timeFrame))
-
-		total := 0
-		pageToken := ""
-		for {
-			actualLimit := limit - total
-			if actualLimit <= 0 {
-				return
-			}
-			if actualLimit > v2MaxLimit {
-				actualLimit = v2MaxLimit
-			}
-			q.Set("limit", fmt.Sprintf("%d", actualLimit))
-			q.Set("page_token", pageToken)
-			u.RawQuery = q.Encode()
-
-			resp, err := c.get(u)
-			if err != nil {
-				ch <- v2.BarItem{Error: err}
-				return
-			}
-
-			var barResp barResponse
-			if err = unmarshal(resp, &barResp); err != nil {
-				ch <- v2.BarItem{Error: err}
-				return
-			}
-
-			for _, bar := range barResp.Bars {
-				ch <- v2.BarItem{Bar: bar}
-			}
-			if barResp.NextPageToken == nil {
-				return
-			}
-			pageToken = *barResp.NextPageToken
-			total += len(barResp.Bars)
+		it := c.BarsPager(symbol, timeFrame, adjustment, start, end).Limit(limit).Iterate(c.requestContext())
+		for it.Next() {
+			ch <- v2.BarItem{Bar: it.Value()}
+		}
+		if err := it.Err(); err != nil {
+			ch <- v2.BarItem{Error: err}
 		}
 	}()
 
 	return ch
 }
 
+// GetMultiBars returns the bars for the given symbols between the given
+// start and end times, using the given timeframe and adjustment, fetching
+// the bars of all the symbols together page by page.
+func (c *Client) GetMultiBars(
+	symbols []string, timeFrame v2.TimeFrame, adjustment v2.Adjustment, start, end time.Time,
+) (map[string][]v2.Bar, error) {
+	rawURL := fmt.Sprintf("%s/%s/stocks/bars", c.dataBaseURL(), c.version(StocksEndpoint))
+	params := timeRangeParams(start, end)
+	params.Set("symbols", strings.Join(symbols, ","))
+	params.Set("adjustment", string(adjustment))
+	params.Set("timeframe", string(timeFrame))
+
+	bars := make(map[string][]v2.Bar, len(symbols))
+	pageToken := ""
+	for {
+		var multiBarResp multiBarResponse
+		if err := c.getPage(c.requestContext(), rawURL, params, "limit", pageToken, v2MaxLimit, &multiBarResp); err != nil {
+			return nil, err
+		}
+		for symbol, symbolBars := range multiBarResp.Bars {
+			bars[symbol] = append(bars[symbol], symbolBars...)
+		}
+		if pageToken = nextPageToken(multiBarResp.NextPageToken); pageToken == "" {
+			return bars, nil
+		}
+	}
+}
+
+// timeRangeParams returns the query parameters of the time range between
+// the given start and end times
+func timeRangeParams(start, end time.Time) url.Values {
+	params := url.Values{}
+	params.Set("start", start.Format(time.RFC3339))
+	params.Set("end", end.Format(time.RFC3339))
+	return params
+}
+
 // GetLatestTrade returns the latest trade for a given symbol
 func (c *Client) GetLatestTrade(symbol string) (*v2.Trade, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/v2/stocks/%s/trades/latest", dataURL, symbol))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/stocks/%s/trades/latest", c.dataBaseURL(), c.version(StocksEndpoint), symbol))
 	if err != nil {
 		return nil, err
 	}
@@ -616,7 +874,7 @@ func (c *Client) GetLatestTrade(symbol string) (*v2.Trade, error) {
 
 // GetLatestQuote returns the latest quote for a given symbol
 func (c *Client) GetLatestQuote(symbol string) (*v2.Quote, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/v2/stocks/%s/quotes/latest", dataURL, symbol))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/stocks/%s/quotes/latest", c.dataBaseURL(), c.version(StocksEndpoint), symbol))
 	if err != nil {
 		return nil, err
 	}
@@ -637,7 +895,7 @@ func (c *Client) GetLatestQuote(symbol string) (*v2.Quote, error) {
 
 // GetSnapshot returns the snapshot for a given symbol
 func (c *Client) GetSnapshot(symbol string) (*v2.Snapshot, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/v2/stocks/%s/snapshot", dataURL, symbol))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/stocks/%s/snapshot", c.dataBaseURL(), c.version(StocksEndpoint), symbol))
 	if err != nil {
 		return nil, err
 	}
@@ -658,8 +916,8 @@ func (c *Client) GetSnapshot(symbol string) (*v2.Snapshot, error) {
 
 // GetSnapshots returns the snapshots for multiple symbol
 func (c *Client) GetSnapshots(symbols []string) (map[string]*v2.Snapshot, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/v2/stocks/snapshots?symbols=%s",
-		dataURL, strings.Join(symbols, ",")))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/stocks/snapshots?symbols=%s",
+		c.dataBaseURL(), c.version(StocksEndpoint), strings.Join(symbols, ",")))
 	if err != nil {
 		return nil, err
 	}
@@ -680,7 +938,14 @@ func (c *Client) GetSnapshots(symbols []string) (map[string]*v2.Snapshot, error)
 
 // CloseAllPositions liquidates all open positions at market price.
 func (c *Client) CloseAllPositions() error {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/positions", base, apiVersion))
+	jc, record := c.journaled(JournalCloseAll, "", nil)
+	err := jc.closeAllPositions()
+	record(nil, err)
+	return err
+}
+
+func (c *Client) closeAllPositions() error {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/positions", c.baseURL(), c.version(TradingEndpoint)))
 	if err != nil {
 		return err
 	}
@@ -695,7 +960,14 @@ func (c *Client) CloseAllPositions() error {
 
 // ClosePosition liquidates the position for the given symbol at market price.
 func (c *Client) ClosePosition(symbol string) error {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/positions/%s", base, apiVersion, symbol))
+	jc, record := c.journaledPosition(JournalClose, symbol)
+	err := jc.closePosition(symbol)
+	record(nil, err)
+	return err
+}
+
+func (c *Client) closePosition(symbol string) error {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/positions/%s", c.baseURL(), c.version(TradingEndpoint), symbol))
 	if err != nil {
 		return err
 	}
@@ -710,7 +982,7 @@ func (c *Client) ClosePosition(symbol string) error {
 
 // GetClock returns the current market clock.
 func (c *Client) GetClock() (*Clock, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/clock", base, apiVersion))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/clock", c.baseURL(), c.version(TradingEndpoint)))
 	if err != nil {
 		return nil, err
 	}
@@ -732,7 +1004,7 @@ func (c *Client) GetClock() (*Clock, error) {
 // GetCalendar returns the market calendar, sliced by the start
 // and end dates.
 func (c *Client) GetCalendar(start, end *string) ([]CalendarDay, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/calendar", base, apiVersion))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/calendar", c.baseURL(), c.version(TradingEndpoint)))
 	if err != nil {
 		return nil, err
 	}
@@ -766,7 +1038,7 @@ func (c *Client) GetCalendar(start, end *string) ([]CalendarDay, error) {
 // ListOrders returns the list of orders for an account,
 // filtered by the input parameters.
 func (c *Client) ListOrders(status *string, until *time.Time, limit *int, nested *bool) ([]Order, error) {
-	urlString := fmt.Sprintf("%s/%s/orders", base, apiVersion)
+	urlString := fmt.Sprintf("%s/%s/orders", c.baseURL(), c.version(TradingEndpoint))
 	if nested != nil {
 		urlString += fmt.Sprintf("?nested=%v", *nested)
 	}
@@ -805,9 +1077,99 @@ func (c *Client) ListOrders(status *string, until *time.Time, limit *int, nested
 	return orders, nil
 }
 
+// OrdersPager returns a pager over the orders for an account with the
+// given status, "open" if empty, newest first. Pages overlap on the orders
+// submitted at the same time, which are only returned once, so a pager must
+// not be iterated concurrently. The iteration fails with ErrOrdersPageStuck
+// if a full page of orders was submitted at the same time.
+func (c *Client) OrdersPager(status string, nested bool) *Pager[Order] {
+	rawURL := fmt.Sprintf("%s/%s/orders", c.baseURL(), c.version(TradingEndpoint))
+	params := url.Values{}
+	if status != "" {
+		params.Set("status", status)
+	}
+	if nested {
+		params.Set("nested", "true")
+	}
+	// seen is the IDs of the orders returned by the current iteration
+	var seen map[string]bool
+	return NewPager(func(ctx context.Context, pageToken string, limit int) ([]Order, string, error) {
+		if pageToken == "" {
+			seen = make(map[string]bool)
+		}
+		pageParams := url.Values{}
+		for key, values := range params {
+			pageParams[key] = values
+		}
+		if pageToken != "" {
+			pageParams.Set("until", pageToken)
+		}
+		orders := []Order{}
+		if err := c.getPage(ctx, rawURL, pageParams, "limit", "", limit, &orders); err != nil {
+			return nil, "", err
+		}
+		page := make([]Order, 0, len(orders))
+		for _, order := range orders {
+			if !seen[order.ID] {
+				seen[order.ID] = true
+				page = append(page, order)
+			}
+		}
+		// the orders are paged by the submission time of the oldest order
+		// of the page, the API having no page tokens for them
+		if len(orders) < limit {
+			return page, "", nil
+		}
+		nextPageToken := orders[len(orders)-1].SubmittedAt.Format(time.RFC3339Nano)
+		if nextPageToken == pageToken {
+			return nil, "", fmt.Errorf("%w: %d orders submitted at %s", ErrOrdersPageStuck, len(orders), pageToken)
+		}
+		return page, nextPageToken, nil
+	}, ordersMaxLimit)
+}
+
 // PlaceOrder submits an order request to buy or sell an asset.
 func (c *Client) PlaceOrder(req PlaceOrderRequest) (*Order, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/orders", base, apiVersion))
+	if c.roundPrices {
+		roundPrices(&req)
+	}
+
+	// the orders rejected by the client are journaled too
+	jc, record := c.journaled(JournalPlace, "", req)
+	order, err := c.checkOrder(req)
+	if err == nil && order == nil {
+		order, err = jc.placeOrder(req)
+	}
+	if err != nil {
+		record(nil, err)
+		return nil, err
+	}
+	record(order, nil)
+	return order, nil
+}
+
+// checkOrder checks req against the limits and the validations of the
+// client. It returns the order req would be in dry run mode, nil otherwise.
+func (c *Client) checkOrder(req PlaceOrderRequest) (*Order, error) {
+	if err := c.checkLimits(req); err != nil {
+		return nil, err
+	}
+	if c.dryRun {
+		if err := c.ValidateOrder(req); err != nil {
+			return nil, err
+		}
+		return dryRunOrder(req), nil
+	}
+	if c.validateShortSells {
+		if err := c.checkShortSell(req); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (c *Client) placeOrder(req PlaceOrderRequest) (*Order, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/orders", c.baseURL(), c.version(TradingEndpoint)))
 	if err != nil {
 		return nil, err
 	}
@@ -828,11 +1190,37 @@ func (c *Client) PlaceOrder(req PlaceOrderRequest) (*Order, error) {
 
 // GetOrder submits a request to get an order by the order ID.
 func (c *Client) GetOrder(orderID string) (*Order, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/orders/%s", base, apiVersion, orderID))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/orders/%s", c.baseURL(), c.version(TradingEndpoint), orderID))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	order := &Order{}
+
+	if err = unmarshal(resp, order); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// GetNestedOrder submits a request to get an order by the order ID,
+// including its legs, e.g. the take profit and stop loss orders of a bracket.
+func (c *Client) GetNestedOrder(orderID string) (*Order, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/orders/%s", c.baseURL(), c.version(TradingEndpoint), orderID))
 	if err != nil {
 		return nil, err
 	}
 
+	q := u.Query()
+	q.Set("nested", "true")
+	u.RawQuery = q.Encode()
+
 	resp, err := c.get(u)
 	if err != nil {
 		return nil, err
@@ -849,7 +1237,7 @@ func (c *Client) GetOrder(orderID string) (*Order, error) {
 
 // GetOrderByClientOrderID submits a request to get an order by the client order ID.
 func (c *Client) GetOrderByClientOrderID(clientOrderID string) (*Order, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/orders:by_client_order_id", base, apiVersion))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/orders:by_client_order_id", c.baseURL(), c.version(TradingEndpoint)))
 	if err != nil {
 		return nil, err
 	}
@@ -874,7 +1262,22 @@ func (c *Client) GetOrderByClientOrderID(clientOrderID string) (*Order, error) {
 
 // ReplaceOrder submits a request to replace an order by id
 func (c *Client) ReplaceOrder(orderID string, req ReplaceOrderRequest) (*Order, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/orders/%s", base, apiVersion, orderID))
+	jc, record := c.journaled(JournalReplace, orderID, req)
+	err := c.checkReplaceLimits(orderID, req)
+	var order *Order
+	if err == nil {
+		order, err = jc.replaceOrder(orderID, req)
+	}
+	if err != nil {
+		record(nil, err)
+		return nil, err
+	}
+	record(order, nil)
+	return order, nil
+}
+
+func (c *Client) replaceOrder(orderID string, req ReplaceOrderRequest) (*Order, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/orders/%s", c.baseURL(), c.version(TradingEndpoint), orderID))
 	if err != nil {
 		return nil, err
 	}
@@ -895,7 +1298,14 @@ func (c *Client) ReplaceOrder(orderID string, req ReplaceOrderRequest) (*Order,
 
 // CancelOrder submits a request to cancel an open order.
 func (c *Client) CancelOrder(orderID string) error {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/orders/%s", base, apiVersion, orderID))
+	jc, record := c.journaled(JournalCancel, orderID, nil)
+	err := jc.cancelOrder(orderID)
+	record(nil, err)
+	return err
+}
+
+func (c *Client) cancelOrder(orderID string) error {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/orders/%s", c.baseURL(), c.version(TradingEndpoint), orderID))
 	if err != nil {
 		return err
 	}
@@ -910,7 +1320,14 @@ func (c *Client) CancelOrder(orderID string) error {
 
 // CancelAllOrders submits a request to cancel an open order.
 func (c *Client) CancelAllOrders() error {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/orders", base, apiVersion))
+	jc, record := c.journaled(JournalCancelAll, "", nil)
+	err := jc.cancelAllOrders()
+	record(nil, err)
+	return err
+}
+
+func (c *Client) cancelAllOrders() error {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/orders", c.baseURL(), c.version(TradingEndpoint)))
 	if err != nil {
 		return err
 	}
@@ -927,7 +1344,7 @@ func (c *Client) CancelAllOrders() error {
 // the input parameters.
 func (c *Client) ListAssets(status *string) ([]Asset, error) {
 	// TODO: support different asset classes
-	u, err := url.Parse(fmt.Sprintf("%s/%s/assets", base, apiVersion))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/assets", c.baseURL(), c.version(TradingEndpoint)))
 	if err != nil {
 		return nil, err
 	}
@@ -956,7 +1373,7 @@ func (c *Client) ListAssets(status *string) ([]Asset, error) {
 
 // GetAsset returns an asset for the given symbol.
 func (c *Client) GetAsset(symbol string) (*Asset, error) {
-	u, err := url.Parse(fmt.Sprintf("%s/%s/assets/%v", base, apiVersion, symbol))
+	u, err := url.Parse(fmt.Sprintf("%s/%s/assets/%v", c.baseURL(), c.version(TradingEndpoint), symbol))
 	if err != nil {
 		return nil, err
 	}
@@ -997,7 +1414,7 @@ func (c *Client) ListBars(symbols []string, opts ListBarParams) (map[string][]Ba
 		vals.Set("limit", strconv.FormatInt(int64(*opts.Limit), 10))
 	}
 
-	u, err := url.Parse(fmt.Sprintf("%s/v1/bars/%s?%v", dataURL, opts.Timeframe, vals.Encode()))
+	u, err := url.Parse(fmt.Sprintf("%s/v1/bars/%s?%v", c.dataBaseURL(), opts.Timeframe, vals.Encode()))
 	if err != nil {
 		return nil, err
 	}
@@ -1097,6 +1514,15 @@ func GetBars(
 	return DefaultClient.GetBars(symbol, timeFrame, adjustment, start, end, limit)
 }
 
+// GetMultiBars returns the bars for the given symbols between the given
+// start and end times, using the given timeframe and adjustment,
+// with the default Alpaca client.
+func GetMultiBars(
+	symbols []string, timeFrame v2.TimeFrame, adjustment v2.Adjustment, start, end time.Time,
+) (map[string][]v2.Bar, error) {
+	return DefaultClient.GetMultiBars(symbols, timeFrame, adjustment, start, end)
+}
+
 // GetLatestTrade returns the latest trade for a given symbol
 func GetLatestTrade(symbol string) (*v2.Trade, error) {
 	return DefaultClient.GetLatestTrade(symbol)
@@ -1154,6 +1580,12 @@ func GetOrder(orderID string) (*Order, error) {
 	return DefaultClient.GetOrder(orderID)
 }
 
+// GetNestedOrder returns a single order for the given `orderID`
+// including its legs using the default Alpaca client.
+func GetNestedOrder(orderID string) (*Order, error) {
+	return DefaultClient.GetNestedOrder(orderID)
+}
+
 // GetOrderByClientOrderID returns a single order for the given
 // `clientOrderID` using the default Alpaca client.
 func GetOrderByClientOrderID(clientOrderID string) (*Order, error) {
@@ -1265,6 +1697,8 @@ func verify(resp *http.Response) (err error) {
 			return fmt.Errorf("json unmarshal error: %s", err.Error())
 		}
 		if err == nil {
+			apiErr.RequestID = responseRequestID(resp)
+			apiErr.StatusCode = resp.StatusCode
 			err = &apiErr
 		}
 	}
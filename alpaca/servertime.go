@@ -0,0 +1,115 @@
+package alpaca
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+)
+
+// ClockOffset returns the offset of the server clock from the local one
+// estimated from a request sent at the local time sent, answered with the
+// server time server and received at the local time received, assuming the
+// server time is from halfway through the round trip.
+func ClockOffset(sent, received, server time.Time) time.Duration {
+	return server.Sub(sent.Add(received.Sub(sent) / 2))
+}
+
+// timeSync holds the estimated offset of the server clock from the local
+// one of a client
+type timeSync struct {
+	mu     sync.Mutex
+	offset time.Duration
+	seen   bool
+	// synced is true once the offset comes from SyncTime rather than the
+	// Date header of a response, which has a precision of a second only
+	synced bool
+}
+
+// observe updates the offset with the Date header of a response to a
+// request sent and received at the given local times, unless SyncTime set
+// it
+func (t *timeSync) observe(header http.Header, sent, received time.Time) {
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// the date is truncated to the second
+	offset := ClockOffset(sent, received, date.Add(500*time.Millisecond))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.synced {
+		t.offset, t.seen = offset, true
+	}
+}
+
+func (t *timeSync) set(offset time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.offset, t.seen, t.synced = offset, true, true
+}
+
+func (t *timeSync) get() (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.offset, t.seen
+}
+
+// SyncTime estimates the offset of the Alpaca server clock from the local
+// one with the timestamp of GetClock and returns it. The offset is then used
+// by Now instead of the one estimated from the Date header of the responses.
+func (c *Client) SyncTime() (time.Duration, error) {
+	sent := c.clock().Now()
+	market, err := c.GetClock()
+	if err != nil {
+		return 0, err
+	}
+	offset := ClockOffset(sent, c.clock().Now(), market.Timestamp)
+	c.timeSync.set(offset)
+	return offset, nil
+}
+
+// TimeOffset returns the offset of the Alpaca server clock from the local
+// one, estimated by SyncTime or else from the Date header of the last
+// response, and false if there was none yet. The copies of the client share
+// it.
+func (c *Client) TimeOffset() (time.Duration, bool) {
+	return c.timeSync.get()
+}
+
+// Now returns the time of the Alpaca server clock: the local time corrected
+// by TimeOffset. It is the local time until the client got a response.
+func (c *Client) Now() time.Time {
+	offset, _ := c.timeSync.get()
+	return c.clock().Now().Add(offset)
+}
+
+// ServerClock returns the clock of the client, WithClock or clock.Real,
+// telling the time of the Alpaca server clock like Now, e.g. for
+// stream.Clock or schedule.WithClock.
+func (c *Client) ServerClock() clock.Clock {
+	return serverClock{Clock: c.clock(), sync: c.timeSync}
+}
+
+type serverClock struct {
+	clock.Clock
+	sync *timeSync
+}
+
+func (c serverClock) Now() time.Time {
+	offset, _ := c.sync.get()
+	return c.Clock.Now().Add(offset)
+}
+
+// SyncTime estimates the offset of the Alpaca server clock from the local
+// one with the default Alpaca client.
+func SyncTime() (time.Duration, error) {
+	return DefaultClient.SyncTime()
+}
+
+// Now returns the time of the Alpaca server clock with the default Alpaca
+// client.
+func Now() time.Time {
+	return DefaultClient.Now()
+}
@@ -0,0 +1,139 @@
+package alpaca
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// assetCacheTTL is how long IsShortable trusts a previously fetched asset
+	assetCacheTTL = time.Minute
+)
+
+// NotShortableError is returned by PlaceOrder when short sell validation is
+// enabled and the order would open a short position in an asset that cannot
+// be shorted.
+type NotShortableError struct {
+	Symbol       string
+	Shortable    bool
+	EasyToBorrow bool
+}
+
+func (e *NotShortableError) Error() string {
+	if !e.Shortable {
+		return fmt.Sprintf("%s is not shortable", e.Symbol)
+	}
+	return fmt.Sprintf("%s is not easy to borrow", e.Symbol)
+}
+
+type cachedAsset struct {
+	asset     Asset
+	fetchedAt time.Time
+}
+
+// assetCache keeps recently fetched assets for a short time
+type assetCache struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	assets map[string]cachedAsset
+}
+
+func newAssetCache(ttl time.Duration) *assetCache {
+	return &assetCache{
+		ttl:    ttl,
+		assets: make(map[string]cachedAsset),
+	}
+}
+
+func (ac *assetCache) get(symbol string) (Asset, bool) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	cached, ok := ac.assets[symbol]
+	if !ok || time.Since(cached.fetchedAt) > ac.ttl {
+		return Asset{}, false
+	}
+	return cached.asset, true
+}
+
+func (ac *assetCache) put(symbol string, asset Asset) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	ac.assets[symbol] = cachedAsset{asset: asset, fetchedAt: time.Now()}
+}
+
+// getCachedAsset returns the asset for the symbol, fetching it
+// only if it is not in the client's asset cache yet
+func (c *Client) getCachedAsset(symbol string) (*Asset, error) {
+	if c.assets == nil {
+		c.assets = newAssetCache(assetCacheTTL)
+	}
+	if asset, ok := c.assets.get(symbol); ok {
+		return &asset, nil
+	}
+
+	asset, err := c.GetAsset(symbol)
+	if err != nil {
+		return nil, err
+	}
+	c.assets.put(symbol, *asset)
+	return asset, nil
+}
+
+// IsShortable returns true if the asset can be sold short right now,
+// that is it is both shortable and easy to borrow. Assets are cached
+// for a short time so checking frequently traded symbols is cheap.
+func (c *Client) IsShortable(symbol string) (bool, error) {
+	asset, err := c.getCachedAsset(symbol)
+	if err != nil {
+		return false, err
+	}
+	return asset.Shortable && asset.EasyToBorrow, nil
+}
+
+// checkShortSell returns a *NotShortableError if the order would
+// sell more than the current long position of a non-shortable asset.
+func (c *Client) checkShortSell(req PlaceOrderRequest) error {
+	if req.Side != Sell || req.AssetKey == nil || req.Qty.IsZero() {
+		return nil
+	}
+	symbol := *req.AssetKey
+
+	held := decimal.Zero
+	position, err := c.GetPosition(symbol)
+	if err != nil {
+		if !errors.Is(err, ErrPositionNotFound) {
+			return err
+		}
+	} else {
+		held = position.Qty
+	}
+	if held.GreaterThanOrEqual(req.Qty) {
+		// closing (part of) a long position
+		return nil
+	}
+
+	asset, err := c.getCachedAsset(symbol)
+	if err != nil {
+		return err
+	}
+	if !asset.Shortable || !asset.EasyToBorrow {
+		return &NotShortableError{
+			Symbol:       symbol,
+			Shortable:    asset.Shortable,
+			EasyToBorrow: asset.EasyToBorrow,
+		}
+	}
+	return nil
+}
+
+// IsShortable returns true if the asset can be sold short right now
+// using the default Alpaca client.
+func IsShortable(symbol string) (bool, error) {
+	return DefaultClient.IsShortable(symbol)
+}
@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"strings"
@@ -192,7 +192,8 @@ func (s *Stream) start() {
 					return
 				}
 			} else {
-				log.Printf("alpaca stream read error (%v)", err)
+				common.DefaultLogger.Warn("stream read error, reconnecting",
+					"client", "legacy_stream", "url", s.base, "error", err)
 			}
 
 			err := s.reconnect()
@@ -255,12 +256,17 @@ func (s *Stream) auth() (err error) {
 		return
 	}
 
+	credentials := common.Credentials()
+	data := map[string]interface{}{
+		"key_id":     credentials.ID,
+		"secret_key": credentials.Secret,
+	}
+	if credentials.OAuth != "" {
+		data = map[string]interface{}{"oauth_token": credentials.OAuth}
+	}
 	authRequest := ClientMsg{
 		Action: "authenticate",
-		Data: map[string]interface{}{
-			"key_id":     common.Credentials().ID,
-			"secret_key": common.Credentials().Secret,
-		},
+		Data:   data,
 	}
 
 	if err = s.conn.WriteJSON(authRequest); err != nil {
@@ -294,7 +300,7 @@ func GetStream() *Stream {
 		str = &Stream{
 			authenticated: atomic.Value{},
 			handlers:      sync.Map{},
-			base:          base,
+			base:          defaultBaseURL(),
 		}
 
 		str.authenticated.Store(false)
@@ -309,7 +315,7 @@ func GetDataStream() *Stream {
 		if s := os.Getenv("DATA_PROXY_WS"); s != "" {
 			streamUrl = s
 		} else {
-			streamUrl = dataURL
+			streamUrl = defaultDataURL()
 		}
 		dataStr = &Stream{
 			authenticated: atomic.Value{},
@@ -334,7 +340,9 @@ func (s *Stream) openSocket() (*websocket.Conn, error) {
 	connectionAttempts := 0
 	for connectionAttempts < MaxConnectionAttempts {
 		connectionAttempts++
-		c, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+		c, _, err := websocket.DefaultDialer.Dial(u.String(), http.Header{
+			"User-Agent": []string{common.UserAgent("")},
+		})
 		if err == nil {
 			return c, nil
 		}
@@ -0,0 +1,72 @@
+package alpaca
+
+import "github.com/shopspring/decimal"
+
+var (
+	// subPennyThreshold is the price under which sub-penny increments are allowed
+	subPennyThreshold = decimal.New(1, 0)
+)
+
+// TickPlaces returns the number of decimal places allowed for the price
+// according to the sub-penny rule: 2 for prices of at least $1 and 4 below.
+func TickPlaces(price decimal.Decimal) int32 {
+	if price.Abs().LessThan(subPennyThreshold) {
+		return 4
+	}
+	return 2
+}
+
+// RoundToTick rounds the price to a valid tick size. Buy prices are rounded
+// down and sell prices up, so the rounded price never allows a worse
+// execution than the original one.
+func RoundToTick(price decimal.Decimal, side Side) decimal.Decimal {
+	places := TickPlaces(price)
+	shifted := price.Shift(places)
+	if side == Sell {
+		shifted = shifted.Ceil()
+	} else {
+		shifted = shifted.Floor()
+	}
+	rounded := shifted.Shift(-places)
+	// rounding may cross the $1 threshold, e.g. 0.99999 -> 1.0000
+	if TickPlaces(rounded) != places {
+		return RoundToTick(rounded, side)
+	}
+	return rounded
+}
+
+// IsValidTick returns true if the price does not violate the sub-penny rule
+func IsValidTick(price decimal.Decimal) bool {
+	return price.Equal(price.Truncate(TickPlaces(price)))
+}
+
+// roundPrices rounds the prices of the order and its legs to valid ticks
+func roundPrices(req *PlaceOrderRequest) {
+	round := func(price *decimal.Decimal, side Side) *decimal.Decimal {
+		if price == nil {
+			return nil
+		}
+		rounded := RoundToTick(*price, side)
+		return &rounded
+	}
+
+	req.LimitPrice = round(req.LimitPrice, req.Side)
+	req.StopPrice = round(req.StopPrice, req.Side)
+
+	// the legs of bracket orders close the position, so they trade on the other side
+	exitSide := Sell
+	if req.Side == Sell {
+		exitSide = Buy
+	}
+	if req.TakeProfit != nil {
+		req.TakeProfit = &TakeProfit{
+			LimitPrice: round(req.TakeProfit.LimitPrice, exitSide),
+		}
+	}
+	if req.StopLoss != nil {
+		req.StopLoss = &StopLoss{
+			LimitPrice: round(req.StopLoss.LimitPrice, exitSide),
+			StopPrice:  round(req.StopLoss.StopPrice, exitSide),
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package alpaca
+
+import (
+	"context"
+	"net/http"
+)
+
+// RequestTracer observes the REST calls of a client, e.g. to trace them.
+// See the otelalpaca module for an OpenTelemetry implementation.
+type RequestTracer interface {
+	// StartRequest is called before a call is sent. It returns the request
+	// to send, which may carry a new context and extra headers, and the
+	// function called once the call completed with the final status code
+	// (0 without a response), the number of rate limit retries and the error.
+	StartRequest(req *http.Request) (*http.Request, func(statusCode, retries int, err error))
+}
+
+// WithRequestTracer makes the client report every REST call to tracer
+func WithRequestTracer(tracer RequestTracer) ClientOption {
+	return func(c *Client) {
+		c.tracer = tracer
+	}
+}
+
+// WithContext returns a lightweight copy of the client whose REST calls use
+// ctx, which can cancel them and carries the parent span of their traces,
+// e.g. client.WithContext(ctx).GetAccount().
+func (c *Client) WithContext(ctx context.Context) *Client {
+	clone := *c
+	clone.ctx = ctx
+	return &clone
+}
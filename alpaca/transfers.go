@@ -0,0 +1,86 @@
+package alpaca
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTransferFailed is the error of a crypto transfer that ended in the
+// FAILED status
+var ErrTransferFailed = errors.New("crypto transfer failed")
+
+// defaultTransferPollInterval is how often a transfer is polled when no
+// interval is given
+const defaultTransferPollInterval = 5 * time.Second
+
+// WaitForCryptoTransfer polls the transfer every interval, 5 seconds if 0,
+// until it is COMPLETE or FAILED, or ctx is done, e.g. after a timeout set
+// with context.WithTimeout. It returns the last state of the transfer along
+// with an error matching ErrTransferFailed with errors.Is if it failed, or
+// the error of ctx if it was still processing.
+func (c *Client) WaitForCryptoTransfer(
+	ctx context.Context, transferID string, interval time.Duration,
+) (*CryptoTransfer, error) {
+	if interval <= 0 {
+		interval = defaultTransferPollInterval
+	}
+	for {
+		transfer, err := c.WithContext(ctx).GetCryptoTransfer(transferID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+		if transfer.Status.IsTerminal() {
+			return transfer, transferError(transfer)
+		}
+
+		timer := c.clock().NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return transfer, fmt.Errorf("transfer %s still %s: %w", transferID, transfer.Status, ctx.Err())
+		case <-timer.C():
+		}
+	}
+}
+
+// CreateCryptoTransferAndWait withdraws crypto to a whitelisted address and
+// waits for the transfer to end as WaitForCryptoTransfer.
+func (c *Client) CreateCryptoTransferAndWait(
+	ctx context.Context, req CreateCryptoTransferRequest, interval time.Duration,
+) (*CryptoTransfer, error) {
+	transfer, err := c.WithContext(ctx).CreateCryptoTransfer(req)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.Status.IsTerminal() {
+		return transfer, transferError(transfer)
+	}
+	return c.WaitForCryptoTransfer(ctx, transfer.ID, interval)
+}
+
+// transferError returns the error of a transfer in a terminal status
+func transferError(transfer *CryptoTransfer) error {
+	if transfer.Status == TransferFailed {
+		return fmt.Errorf("transfer %s: %w", transfer.ID, ErrTransferFailed)
+	}
+	return nil
+}
+
+// WaitForCryptoTransfer polls the transfer until it is COMPLETE or FAILED,
+// or ctx is done, with the default Alpaca client.
+func WaitForCryptoTransfer(ctx context.Context, transferID string, interval time.Duration) (*CryptoTransfer, error) {
+	return DefaultClient.WaitForCryptoTransfer(ctx, transferID, interval)
+}
+
+// CreateCryptoTransferAndWait withdraws crypto to a whitelisted address and
+// waits for the transfer to end with the default Alpaca client.
+func CreateCryptoTransferAndWait(
+	ctx context.Context, req CreateCryptoTransferRequest, interval time.Duration,
+) (*CryptoTransfer, error) {
+	return DefaultClient.CreateCryptoTransferAndWait(ctx, req, interval)
+}
@@ -0,0 +1,351 @@
+package alpaca
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// maxMlegLegs is the maximum number of legs of a multi-leg order
+	maxMlegLegs = 4
+	// maxEquitySymbolLength is the length of the longest equity symbols,
+	// longer symbols ending with a quote currency are crypto pairs
+	maxEquitySymbolLength = 5
+)
+
+var cryptoQuoteCurrencies = []string{"USD", "USDT", "USDC", "BTC"}
+
+// OrderValidationError is returned by ValidateOrder for orders
+// the API would reject
+type OrderValidationError struct {
+	Reason string
+}
+
+func (e *OrderValidationError) Error() string {
+	return "invalid order: " + e.Reason
+}
+
+func invalidOrder(format string, args ...interface{}) error {
+	return &OrderValidationError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// WithDryRun makes PlaceOrder validate orders with ValidateOrder instead of
+// submitting them. The returned order mirrors the request and has no ID.
+// Useful to test strategy code without an API account.
+func WithDryRun() ClientOption {
+	return func(c *Client) {
+		c.dryRun = true
+	}
+}
+
+// isCryptoSymbol returns true for crypto pairs, e.g. BTC/USD or BTCUSD
+func isCryptoSymbol(symbol string) bool {
+	if strings.Contains(symbol, "/") {
+		return true
+	}
+	if len(symbol) <= maxEquitySymbolLength {
+		return false
+	}
+	for _, quote := range cryptoQuoteCurrencies {
+		if strings.HasSuffix(symbol, quote) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateOrder checks the order locally for mistakes the API would reject
+// it for, without sending any request. It returns an *OrderValidationError
+// describing the first problem found.
+func (c *Client) ValidateOrder(req PlaceOrderRequest) error {
+	if req.OrderClass == Mleg {
+		if err := validateLegs(req.Legs); err != nil {
+			return err
+		}
+	} else {
+		if req.AssetKey == nil || *req.AssetKey == "" {
+			return invalidOrder("symbol is required")
+		}
+		if req.Side != Buy && req.Side != Sell {
+			return invalidOrder("side must be %s or %s", Buy, Sell)
+		}
+	}
+	assetClass := orderAssetClass(req)
+	crypto := assetClass == Crypto
+
+	if err := validateQty(req, crypto); err != nil {
+		return err
+	}
+	if err := validatePrices(req); err != nil {
+		return err
+	}
+	if req.ExtendedHours && crypto {
+		return invalidOrder("crypto trades around the clock, extended hours does not apply")
+	}
+	if err := validateTimeInForce(req, assetClass); err != nil {
+		return err
+	}
+
+	if !crypto {
+		return validateTicks(req)
+	}
+	return nil
+}
+
+func validateLegs(legs []OrderLeg) error {
+	if len(legs) < 2 || len(legs) > maxMlegLegs {
+		return invalidOrder("%s orders must have 2 to %d legs", Mleg, maxMlegLegs)
+	}
+	for i, leg := range legs {
+		if leg.Symbol == "" {
+			return invalidOrder("leg %d: symbol is required", i)
+		}
+		if leg.Side != Buy && leg.Side != Sell {
+			return invalidOrder("leg %d: side must be %s or %s", i, Buy, Sell)
+		}
+		if !leg.RatioQty.IsPositive() {
+			return invalidOrder("leg %d: ratio qty must be positive", i)
+		}
+	}
+	return nil
+}
+
+func validateQty(req PlaceOrderRequest, crypto bool) error {
+	hasQty, hasNotional := !req.Qty.IsZero(), !req.Notional.IsZero()
+	switch {
+	case hasQty == hasNotional:
+		return invalidOrder("exactly one of qty and notional must be set")
+	case req.Qty.IsNegative() || req.Notional.IsNegative():
+		return invalidOrder("qty and notional must be positive")
+	}
+
+	if hasNotional && !crypto && req.Type != Market {
+		return invalidOrder("notional orders must be %s orders", Market)
+	}
+	if crypto {
+		return nil
+	}
+	fractional := hasNotional || !req.Qty.Equal(req.Qty.Truncate(0))
+	if fractional && req.TimeInForce != Day {
+		return invalidOrder("fractional orders must have %s time in force", Day)
+	}
+	if fractional && req.OrderClass != "" && req.OrderClass != Simple {
+		return invalidOrder("fractional orders must be simple orders")
+	}
+	return nil
+}
+
+func validatePrices(req PlaceOrderRequest) error {
+	switch req.Type {
+	case Market:
+	case Limit:
+		if req.LimitPrice == nil {
+			return invalidOrder("%s orders require a limit price", Limit)
+		}
+	case Stop:
+		if req.StopPrice == nil {
+			return invalidOrder("%s orders require a stop price", Stop)
+		}
+	case StopLimit:
+		if req.LimitPrice == nil || req.StopPrice == nil {
+			return invalidOrder("%s orders require a limit and a stop price", StopLimit)
+		}
+	case TrailingStop:
+		if (req.TrailPrice == nil) == (req.TrailPercent == nil) {
+			return invalidOrder("%s orders require exactly one of trail price and trail percent", TrailingStop)
+		}
+	default:
+		return invalidOrder("unknown order type %q", req.Type)
+	}
+
+	return validateLegPrices(req)
+}
+
+// timeInForceRule lists the order types a time in force is allowed for, by
+// asset class, during the regular and the extended hours
+type timeInForceRule struct {
+	types         map[string][]OrderType
+	extendedHours map[string][]OrderType
+}
+
+var equityOrderTypes = []OrderType{Market, Limit, Stop, StopLimit, TrailingStop}
+
+// timeInForceRules is the matrix of the times in force allowed by the API.
+// The times in force missing are not allowed at all.
+var timeInForceRules = map[TimeInForce]timeInForceRule{
+	Day: {
+		types: map[string][]OrderType{
+			USEquity: equityOrderTypes,
+			USOption: {Market, Limit, Stop, StopLimit},
+		},
+		extendedHours: map[string][]OrderType{USEquity: {Limit}},
+	},
+	GTC: {types: map[string][]OrderType{
+		USEquity: equityOrderTypes,
+		Crypto:   {Market, Limit, StopLimit},
+	}},
+	OPG: {types: map[string][]OrderType{USEquity: {Market, Limit}}},
+	CLS: {types: map[string][]OrderType{USEquity: {Market, Limit}}},
+	IOC: {types: map[string][]OrderType{
+		USEquity: {Market, Limit},
+		Crypto:   {Market, Limit, StopLimit},
+	}},
+	FOK: {types: map[string][]OrderType{USEquity: {Market, Limit}}},
+}
+
+// timesInForce are the times in force of the matrix, in the order they are
+// listed in
+var timesInForce = []TimeInForce{Day, GTC, OPG, CLS, IOC, FOK}
+
+func (r timeInForceRule) allows(assetClass string, orderType OrderType, extendedHours bool) bool {
+	types := r.types
+	if extendedHours {
+		types = r.extendedHours
+	}
+	for _, t := range types[assetClass] {
+		if t == orderType {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedTimeInForces returns the times in force the API allows for the
+// orders of the type in the asset class (USEquity, USOption or Crypto),
+// during the extended hours if extendedHours is set.
+func AllowedTimeInForces(assetClass string, orderType OrderType, extendedHours bool) []TimeInForce {
+	var allowed []TimeInForce
+	for _, tif := range timesInForce {
+		if timeInForceRules[tif].allows(assetClass, orderType, extendedHours) {
+			allowed = append(allowed, tif)
+		}
+	}
+	return allowed
+}
+
+// orderAssetClass returns the asset class of the order
+func orderAssetClass(req PlaceOrderRequest) string {
+	switch {
+	case req.OrderClass == Mleg:
+		return USOption
+	case req.AssetKey == nil:
+		return USEquity
+	case isCryptoSymbol(*req.AssetKey):
+		return Crypto
+	case isOptionSymbol(*req.AssetKey):
+		return USOption
+	}
+	return USEquity
+}
+
+// isOptionSymbol returns true for OCC option symbols, e.g.
+// AAPL240119C00150000: the underlying followed by the expiration date, the
+// type and the strike price
+func isOptionSymbol(symbol string) bool {
+	if len(symbol) < 16 {
+		return false
+	}
+	contract := symbol[len(symbol)-15:]
+	if contract[6] != 'C' && contract[6] != 'P' {
+		return false
+	}
+	for i, c := range contract {
+		if i != 6 && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func validateTimeInForce(req PlaceOrderRequest, assetClass string) error {
+	tif := req.TimeInForce
+	switch tif {
+	case GTX, GTD:
+		return invalidOrder("%s time in force is not supported", tif)
+	}
+	rule, ok := timeInForceRules[tif]
+	if !ok {
+		return invalidOrder("unknown time in force %q", tif)
+	}
+
+	if !rule.allows(assetClass, req.Type, req.ExtendedHours) {
+		orders := fmt.Sprintf("%s %s orders", assetClass, req.Type)
+		if req.ExtendedHours {
+			orders += " in the extended hours"
+		}
+		allowed := AllowedTimeInForces(assetClass, req.Type, req.ExtendedHours)
+		if len(allowed) == 0 {
+			return invalidOrder("%s are not allowed", orders)
+		}
+		names := make([]string, len(allowed))
+		for i, a := range allowed {
+			names[i] = string(a)
+		}
+		return invalidOrder("%s time in force is not allowed for %s, only %s",
+			tif, orders, strings.Join(names, ", "))
+	}
+
+	switch req.OrderClass {
+	case Bracket, Oco, Oto:
+		if tif != Day && tif != GTC {
+			return invalidOrder("%s orders must have %s or %s time in force", req.OrderClass, Day, GTC)
+		}
+	}
+	return nil
+}
+
+func validateTicks(req PlaceOrderRequest) error {
+	type namedPrice struct {
+		name  string
+		price *decimal.Decimal
+	}
+	prices := []namedPrice{
+		{"limit price", req.LimitPrice},
+		{"stop price", req.StopPrice},
+	}
+	if req.TakeProfit != nil {
+		prices = append(prices, namedPrice{"take profit limit price", req.TakeProfit.LimitPrice})
+	}
+	if req.StopLoss != nil {
+		prices = append(prices,
+			namedPrice{"stop loss limit price", req.StopLoss.LimitPrice},
+			namedPrice{"stop loss stop price", req.StopLoss.StopPrice},
+		)
+	}
+	for _, p := range prices {
+		if p.price != nil && !IsValidTick(*p.price) {
+			return invalidOrder("%s %s is not a valid tick size", p.name, p.price)
+		}
+	}
+	return nil
+}
+
+// dryRunOrder returns the order that would be created by the request
+func dryRunOrder(req PlaceOrderRequest) *Order {
+	order := &Order{
+		ClientOrderID: req.ClientOrderID,
+		Qty:           req.Qty,
+		Notional:      req.Notional,
+		Type:          req.Type,
+		Side:          req.Side,
+		TimeInForce:   req.TimeInForce,
+		LimitPrice:    req.LimitPrice,
+		StopPrice:     req.StopPrice,
+		TrailPrice:    req.TrailPrice,
+		TrailPercent:  req.TrailPercent,
+		ExtendedHours: req.ExtendedHours,
+		OrderClass:    req.OrderClass,
+	}
+	if req.AssetKey != nil {
+		order.Symbol = *req.AssetKey
+	}
+	return order
+}
+
+// ValidateOrder checks the order locally for mistakes the API would reject
+// it for with the default Alpaca client.
+func ValidateOrder(req PlaceOrderRequest) error {
+	return DefaultClient.ValidateOrder(req)
+}
@@ -0,0 +1,54 @@
+package alpaca
+
+// APIEndpoint is a group of endpoints that Alpaca versions together, such
+// as the market data of stocks. Alpaca moves such groups to a new version
+// from time to time, e.g. from v1beta1 to v2; WithAPIVersion lets a client
+// follow without waiting for a release of the SDK.
+type APIEndpoint string
+
+const (
+	// TradingEndpoint is the account, orders, positions, assets, clock,
+	// calendar, options and wallets endpoints of the trading API
+	TradingEndpoint APIEndpoint = "trading"
+	// EventsEndpoint is the server-sent events endpoints of the trading API
+	EventsEndpoint APIEndpoint = "events"
+	// StocksEndpoint is the trades, quotes, bars and snapshots endpoints of
+	// the market data API
+	StocksEndpoint APIEndpoint = "stocks"
+	// CryptoEndpoint is the crypto endpoints of the market data API
+	CryptoEndpoint APIEndpoint = "crypto"
+)
+
+// defaultAPIVersions are the versions the clients use unless they are set
+// with WithAPIVersion. The trading endpoints use apiVersion, which the
+// APCA_API_VERSION environment variable overrides.
+var defaultAPIVersions = map[APIEndpoint]string{
+	EventsEndpoint: "v2",
+	StocksEndpoint: "v2",
+	CryptoEndpoint: "v1beta1",
+}
+
+// WithAPIVersion pins the version the client requests endpoint with, e.g.
+//
+//	alpaca.WithAPIVersion(alpaca.StocksEndpoint, "v1beta3")
+//
+// The default versions are those the SDK was written against.
+func WithAPIVersion(endpoint APIEndpoint, version string) ClientOption {
+	return func(c *Client) {
+		if c.versions == nil {
+			c.versions = map[APIEndpoint]string{}
+		}
+		c.versions[endpoint] = version
+	}
+}
+
+// version returns the version the client requests endpoint with
+func (c *Client) version(endpoint APIEndpoint) string {
+	if v, ok := c.versions[endpoint]; ok {
+		return v
+	}
+	if endpoint == TradingEndpoint {
+		return apiVersion
+	}
+	return defaultAPIVersions[endpoint]
+}
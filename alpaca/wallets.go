@@ -0,0 +1,198 @@
+package alpaca
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// ListCryptoWallets returns the crypto funding wallets of the account,
+// optionally only the one of the given asset.
+func (c *Client) ListCryptoWallets(asset *string) ([]CryptoWallet, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/wallets", c.baseURL(), c.version(TradingEndpoint)))
+	if err != nil {
+		return nil, err
+	}
+
+	if asset != nil {
+		q := u.Query()
+		q.Set("asset", *asset)
+		u.RawQuery = q.Encode()
+	}
+
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	wallets := []CryptoWallet{}
+
+	if err = unmarshal(resp, &wallets); err != nil {
+		return nil, err
+	}
+
+	return wallets, nil
+}
+
+// ListWhitelistedAddresses returns the addresses crypto can be withdrawn to.
+func (c *Client) ListWhitelistedAddresses() ([]WhitelistedAddress, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/wallets/whitelists", c.baseURL(), c.version(TradingEndpoint)))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []WhitelistedAddress{}
+
+	if err = unmarshal(resp, &addresses); err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+// CreateWhitelistedAddress requests an address to be whitelisted for
+// withdrawals. It can only be used once its status is approved.
+func (c *Client) CreateWhitelistedAddress(req CreateWhitelistedAddressRequest) (*WhitelistedAddress, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/wallets/whitelists", c.baseURL(), c.version(TradingEndpoint)))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(u, req)
+	if err != nil {
+		return nil, err
+	}
+
+	address := &WhitelistedAddress{}
+
+	if err = unmarshal(resp, address); err != nil {
+		return nil, err
+	}
+
+	return address, nil
+}
+
+// DeleteWhitelistedAddress removes an address from the whitelist.
+func (c *Client) DeleteWhitelistedAddress(addressID string) error {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/wallets/whitelists/%s", c.baseURL(), c.version(TradingEndpoint), addressID))
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.delete(u)
+	if err != nil {
+		return err
+	}
+
+	return verify(resp)
+}
+
+// ListCryptoTransfers returns the crypto deposits and withdrawals of the account.
+func (c *Client) ListCryptoTransfers() ([]CryptoTransfer, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/wallets/transfers", c.baseURL(), c.version(TradingEndpoint)))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	transfers := []CryptoTransfer{}
+
+	if err = unmarshal(resp, &transfers); err != nil {
+		return nil, err
+	}
+
+	return transfers, nil
+}
+
+// GetCryptoTransfer returns a crypto transfer by its ID, e.g. to follow
+// the status of a withdrawal.
+func (c *Client) GetCryptoTransfer(transferID string) (*CryptoTransfer, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/wallets/transfers/%s", c.baseURL(), c.version(TradingEndpoint), transferID))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &CryptoTransfer{}
+
+	if err = unmarshal(resp, transfer); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// CreateCryptoTransfer withdraws crypto to a whitelisted address.
+func (c *Client) CreateCryptoTransfer(req CreateCryptoTransferRequest) (*CryptoTransfer, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/wallets/transfers", c.baseURL(), c.version(TradingEndpoint)))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.post(u, req)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &CryptoTransfer{}
+
+	if err = unmarshal(resp, transfer); err != nil {
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// ListCryptoWallets returns the crypto funding wallets of the account
+// with the default Alpaca client.
+func ListCryptoWallets(asset *string) ([]CryptoWallet, error) {
+	return DefaultClient.ListCryptoWallets(asset)
+}
+
+// ListWhitelistedAddresses returns the addresses crypto can be withdrawn
+// to with the default Alpaca client.
+func ListWhitelistedAddresses() ([]WhitelistedAddress, error) {
+	return DefaultClient.ListWhitelistedAddresses()
+}
+
+// CreateWhitelistedAddress requests an address to be whitelisted for
+// withdrawals with the default Alpaca client.
+func CreateWhitelistedAddress(req CreateWhitelistedAddressRequest) (*WhitelistedAddress, error) {
+	return DefaultClient.CreateWhitelistedAddress(req)
+}
+
+// DeleteWhitelistedAddress removes an address from the whitelist with
+// the default Alpaca client.
+func DeleteWhitelistedAddress(addressID string) error {
+	return DefaultClient.DeleteWhitelistedAddress(addressID)
+}
+
+// ListCryptoTransfers returns the crypto deposits and withdrawals of the
+// account with the default Alpaca client.
+func ListCryptoTransfers() ([]CryptoTransfer, error) {
+	return DefaultClient.ListCryptoTransfers()
+}
+
+// GetCryptoTransfer returns a crypto transfer by its ID with the
+// default Alpaca client.
+func GetCryptoTransfer(transferID string) (*CryptoTransfer, error) {
+	return DefaultClient.GetCryptoTransfer(transferID)
+}
+
+// CreateCryptoTransfer withdraws crypto to a whitelisted address with
+// the default Alpaca client.
+func CreateCryptoTransfer(req CreateCryptoTransferRequest) (*CryptoTransfer, error) {
+	return DefaultClient.CreateCryptoTransfer(req)
+}
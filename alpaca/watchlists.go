@@ -0,0 +1,113 @@
+package alpaca
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Watchlist is a named list of assets of the account, as edited in the
+// Alpaca dashboard
+type Watchlist struct {
+	ID        string    `json:"id"`
+	AccountID string    `json:"account_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Assets are only returned by GetWatchlist and GetWatchlistByName
+	Assets []Asset `json:"assets"`
+}
+
+// Symbols returns the symbols of the assets of the watchlist, in order.
+func (w *Watchlist) Symbols() []string {
+	symbols := make([]string, 0, len(w.Assets))
+	for _, asset := range w.Assets {
+		symbols = append(symbols, asset.Symbol)
+	}
+	return symbols
+}
+
+// ListWatchlists returns the watchlists of the account, without their
+// assets.
+func (c *Client) ListWatchlists() ([]Watchlist, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/watchlists", c.baseURL(), c.version(TradingEndpoint)))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	watchlists := []Watchlist{}
+
+	if err = unmarshal(resp, &watchlists); err != nil {
+		return nil, err
+	}
+
+	return watchlists, nil
+}
+
+// GetWatchlist returns a watchlist with its assets by its ID.
+func (c *Client) GetWatchlist(watchlistID string) (*Watchlist, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/watchlists/%s", c.baseURL(), c.version(TradingEndpoint), watchlistID))
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	watchlist := &Watchlist{}
+
+	if err = unmarshal(resp, watchlist); err != nil {
+		return nil, err
+	}
+
+	return watchlist, nil
+}
+
+// GetWatchlistByName returns a watchlist with its assets by its name.
+func (c *Client) GetWatchlistByName(name string) (*Watchlist, error) {
+	u, err := url.Parse(fmt.Sprintf("%s/%s/watchlists:by_name", c.baseURL(), c.version(TradingEndpoint)))
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Set("name", name)
+	u.RawQuery = q.Encode()
+
+	resp, err := c.get(u)
+	if err != nil {
+		return nil, err
+	}
+
+	watchlist := &Watchlist{}
+
+	if err = unmarshal(resp, watchlist); err != nil {
+		return nil, err
+	}
+
+	return watchlist, nil
+}
+
+// ListWatchlists returns the watchlists of the account with the default
+// Alpaca client.
+func ListWatchlists() ([]Watchlist, error) {
+	return DefaultClient.ListWatchlists()
+}
+
+// GetWatchlist returns a watchlist by its ID with the default Alpaca client.
+func GetWatchlist(watchlistID string) (*Watchlist, error) {
+	return DefaultClient.GetWatchlist(watchlistID)
+}
+
+// GetWatchlistByName returns a watchlist by its name with the default
+// Alpaca client.
+func GetWatchlistByName(name string) (*Watchlist, error) {
+	return DefaultClient.GetWatchlistByName(name)
+}
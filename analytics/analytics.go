@@ -0,0 +1,308 @@
+// Package analytics computes the performance of an account from its
+// portfolio history and account activities: daily returns, drawdown,
+// Sharpe and Sortino ratios, exposure and turnover.
+//
+//	report, err := analytics.Analyze(client, "3M")
+//	...
+//	fmt.Printf("sharpe %.2f, max drawdown %.1f%%\n", report.Sharpe, 100*report.MaxDrawdown)
+package analytics
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+)
+
+// TradingDaysPerYear annualizes the daily ratios
+const TradingDaysPerYear = 252
+
+// ErrNoHistory is returned when the portfolio history has less than two
+// days with equity
+var ErrNoHistory = errors.New("not enough portfolio history")
+
+// The activity types of the fills and the cash flows of the account
+const (
+	activityFill       = "FILL"
+	activityDeposit    = "CSD"
+	activityWithdrawal = "CSW"
+	activityJournal    = "JNLC"
+)
+
+var newYork = func() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}()
+
+// DailyReturn is the return of the account on a trading day, excluding the
+// deposits and withdrawals of the day
+type DailyReturn struct {
+	// Date is midnight of the day in New York
+	Date   time.Time
+	Equity float64
+	// Flows is the net amount deposited on the day, negative for withdrawals
+	Flows  float64
+	Return float64
+	// Exposure is the gross value of the positions at the end of the day,
+	// at the price of their last fill, over the equity
+	Exposure float64
+	// Traded is the notional filled on the day, buys and sells
+	Traded float64
+}
+
+// Report is the performance of the account over the period of the history.
+// The ratios are fractions, e.g. 0.05 for 5%.
+type Report struct {
+	Start        time.Time
+	End          time.Time
+	StartEquity  float64
+	EndEquity    float64
+	DailyReturns []DailyReturn
+	// TotalReturn compounds the daily returns
+	TotalReturn float64
+	// Volatility is the annualized standard deviation of the daily returns
+	Volatility float64
+	// Sharpe and Sortino are annualized, over the risk free rate
+	Sharpe  float64
+	Sortino float64
+	// MaxDrawdown is the largest fall of the compounded returns from a peak,
+	// between MaxDrawdownStart (the peak) and MaxDrawdownEnd (the trough)
+	MaxDrawdown      float64
+	MaxDrawdownStart time.Time
+	MaxDrawdownEnd   time.Time
+	// Exposure is the average of the daily exposures
+	Exposure float64
+	// Turnover is the notional filled over the period, over the average equity
+	Turnover float64
+}
+
+// Option configures the computation of a report
+type Option func(c *config)
+
+type config struct {
+	riskFreeRate float64
+}
+
+// WithRiskFreeRate sets the annual risk free rate the Sharpe and Sortino
+// ratios are computed over, 0 by default.
+func WithRiskFreeRate(rate float64) Option {
+	return func(c *config) {
+		c.riskFreeRate = rate
+	}
+}
+
+// Analyze fetches the daily portfolio history of period (e.g. "1M", "1A")
+// and the fills and cash flows of the period, and computes their report.
+func Analyze(client alpaca.TradingClient, period string, opts ...Option) (*Report, error) {
+	timeframe := alpaca.Day1
+	history, err := client.GetPortfolioHistory(&period, &timeframe, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(history.Timestamp) == 0 {
+		return nil, ErrNoHistory
+	}
+	after := time.Unix(history.Timestamp[0], 0)
+	activities, err := fetchActivities(client, after)
+	if err != nil {
+		return nil, err
+	}
+	return Compute(history, activities, opts...)
+}
+
+// fetchActivities fetches the fills and cash flows after a time, page by page
+func fetchActivities(client alpaca.TradingClient, after time.Time) ([]alpaca.AccountActivity, error) {
+	types := []string{activityFill, activityDeposit, activityWithdrawal, activityJournal}
+	direction := "asc"
+	pageSize := 100
+	seen := map[string]bool{}
+	var activities []alpaca.AccountActivity
+	for {
+		page, err := client.GetAccountActivities(nil, &alpaca.AccountActivitiesRequest{
+			ActivityTypes: &types,
+			After:         &after,
+			Direction:     &direction,
+			PageSize:      &pageSize,
+		})
+		if err != nil {
+			return nil, err
+		}
+		added := 0
+		for _, a := range page {
+			if seen[a.ID] {
+				continue
+			}
+			seen[a.ID] = true
+			activities = append(activities, a)
+			added++
+			if t := activityTime(a); t.After(after) {
+				after = t
+			}
+		}
+		// after has a resolution of a second, so the activities of the last
+		// second of a page come again with the next one
+		if len(page) < pageSize || added == 0 {
+			return activities, nil
+		}
+	}
+}
+
+// activityTime returns the time of a fill, or the date of a cash flow
+func activityTime(a alpaca.AccountActivity) time.Time {
+	if !a.TransactionTime.IsZero() {
+		return a.TransactionTime
+	}
+	return a.Date
+}
+
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.In(newYork).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, newYork)
+}
+
+// Compute computes the report of history, whose equity is taken at the end
+// of each day, adjusting the returns for the cash flows and computing the
+// exposure and turnover from the fills of activities.
+func Compute(history *alpaca.PortfolioHistory, activities []alpaca.AccountActivity, opts ...Option) (*Report, error) {
+	c := config{}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	// the equity at the end of each day, skipping the days before funding
+	var days []DailyReturn
+	for i, ts := range history.Timestamp {
+		if i >= len(history.Equity) {
+			break
+		}
+		equity, _ := history.Equity[i].Float64()
+		date := dateOf(time.Unix(ts, 0))
+		switch {
+		case len(days) > 0 && days[len(days)-1].Date.Equal(date):
+			days[len(days)-1].Equity = equity
+		case equity > 0 || len(days) > 0:
+			days = append(days, DailyReturn{Date: date, Equity: equity})
+		}
+	}
+	if len(days) < 2 {
+		return nil, ErrNoHistory
+	}
+
+	sorted := append([]alpaca.AccountActivity(nil), activities...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return activityTime(sorted[i]).Before(activityTime(sorted[j]))
+	})
+	applyActivities(days, sorted)
+
+	r := &Report{
+		Start:       days[0].Date,
+		End:         days[len(days)-1].Date,
+		StartEquity: days[0].Equity,
+		EndEquity:   days[len(days)-1].Equity,
+	}
+	var traded, equity float64
+	for i := range days {
+		traded += days[i].Traded
+		equity += days[i].Equity
+		r.Exposure += days[i].Exposure
+		if i == 0 {
+			continue
+		}
+		if prev := days[i-1].Equity; prev > 0 {
+			days[i].Return = (days[i].Equity - prev - days[i].Flows) / prev
+		}
+	}
+	r.Exposure /= float64(len(days))
+	r.Turnover = traded / (equity / float64(len(days)))
+	r.DailyReturns = days[1:]
+
+	r.compute(c.riskFreeRate / TradingDaysPerYear)
+	return r, nil
+}
+
+// applyActivities sets the flows, traded notional and exposure of days from
+// the activities sorted by time
+func applyActivities(days []DailyReturn, activities []alpaca.AccountActivity) {
+	positions := map[string]float64{}
+	prices := map[string]float64{}
+	next := 0
+	for i := range days {
+		end := days[i].Date.AddDate(0, 0, 1)
+		for ; next < len(activities) && activityTime(activities[next]).Before(end); next++ {
+			a := activities[next]
+			// the activities of the days before the history only count
+			// towards the positions
+			inHistory := !activityTime(a).Before(days[0].Date)
+			switch a.ActivityType {
+			case activityFill:
+				qty, _ := a.Qty.Float64()
+				price, _ := a.Price.Float64()
+				if a.Side != string(alpaca.Buy) {
+					qty = -qty
+				}
+				positions[a.Symbol] += qty
+				prices[a.Symbol] = price
+				if inHistory {
+					days[i].Traded += math.Abs(qty) * price
+				}
+			case activityDeposit, activityWithdrawal, activityJournal:
+				if inHistory {
+					amount, _ := a.NetAmount.Float64()
+					days[i].Flows += amount
+				}
+			}
+		}
+		if days[i].Equity > 0 {
+			var gross float64
+			for symbol, qty := range positions {
+				gross += math.Abs(qty) * prices[symbol]
+			}
+			days[i].Exposure = gross / days[i].Equity
+		}
+	}
+}
+
+// compute computes the return, risk and drawdown of the daily returns, with
+// the daily risk free rate rf
+func (r *Report) compute(rf float64) {
+	n := float64(len(r.DailyReturns))
+	var mean, downside float64
+	growth, peak := 1.0, 1.0
+	peakDate := r.Start
+	for _, d := range r.DailyReturns {
+		mean += d.Return
+		if excess := d.Return - rf; excess < 0 {
+			downside += excess * excess
+		}
+		growth *= 1 + d.Return
+		if growth > peak {
+			peak, peakDate = growth, d.Date
+		} else if drawdown := 1 - growth/peak; drawdown > r.MaxDrawdown {
+			r.MaxDrawdown = drawdown
+			r.MaxDrawdownStart, r.MaxDrawdownEnd = peakDate, d.Date
+		}
+	}
+	r.TotalReturn = growth - 1
+	mean /= n
+
+	var variance float64
+	for _, d := range r.DailyReturns {
+		variance += (d.Return - mean) * (d.Return - mean)
+	}
+	std := math.Sqrt(variance / n)
+	downside = math.Sqrt(downside / n)
+
+	annualize := math.Sqrt(TradingDaysPerYear)
+	r.Volatility = std * annualize
+	if std > 0 {
+		r.Sharpe = (mean - rf) / std * annualize
+	}
+	if downside > 0 {
+		r.Sortino = (mean - rf) / downside * annualize
+	}
+}
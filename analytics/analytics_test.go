@@ -0,0 +1,130 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type AnalyticsTestSuite struct {
+	suite.Suite
+	history    *alpaca.PortfolioHistory
+	activities []alpaca.AccountActivity
+}
+
+func TestAnalyticsTestSuite(t *testing.T) {
+	suite.Run(t, new(AnalyticsTestSuite))
+}
+
+func day(d int) time.Time {
+	return time.Date(2021, 3, d, 0, 0, 0, 0, newYork)
+}
+
+func fill(id string, at time.Time, side alpaca.Side, qty, price int64) alpaca.AccountActivity {
+	return alpaca.AccountActivity{
+		ID:              id,
+		ActivityType:    "FILL",
+		TransactionTime: at,
+		Symbol:          "AAPL",
+		Side:            string(side),
+		Qty:             decimal.New(qty, 0),
+		Price:           decimal.New(price, 0),
+	}
+}
+
+func (s *AnalyticsTestSuite) SetupTest() {
+	s.history = &alpaca.PortfolioHistory{Timeframe: alpaca.Day1}
+	// unfunded, then +10%, -10% and flat with a deposit of 1000
+	for i, equity := range []int64{0, 1000, 1100, 990, 1990} {
+		s.history.Timestamp = append(s.history.Timestamp, day(i+1).Unix())
+		s.history.Equity = append(s.history.Equity, decimal.New(equity, 0))
+	}
+	s.activities = []alpaca.AccountActivity{
+		fill("2", day(5).Add(10*time.Hour), alpaca.Sell, 5, 100),
+		{ID: "3", ActivityType: "CSD", Date: day(5), NetAmount: decimal.New(1000, 0)},
+		fill("1", day(2).Add(10*time.Hour), alpaca.Buy, 5, 100),
+	}
+}
+
+func (s *AnalyticsTestSuite) TestCompute() {
+	r, err := Compute(s.history, s.activities)
+	require.NoError(s.T(), err)
+
+	assert.Equal(s.T(), day(2), r.Start)
+	assert.Equal(s.T(), day(5), r.End)
+	assert.Equal(s.T(), 1000.0, r.StartEquity)
+	assert.Equal(s.T(), 1990.0, r.EndEquity)
+	require.Len(s.T(), r.DailyReturns, 3)
+	assert.InDelta(s.T(), 0.1, r.DailyReturns[0].Return, 1e-9)
+	assert.InDelta(s.T(), -0.1, r.DailyReturns[1].Return, 1e-9)
+	// the deposit is not a return
+	assert.Equal(s.T(), 1000.0, r.DailyReturns[2].Flows)
+	assert.InDelta(s.T(), 0, r.DailyReturns[2].Return, 1e-9)
+
+	assert.InDelta(s.T(), 1.1*0.9-1, r.TotalReturn, 1e-9)
+	assert.InDelta(s.T(), 0.1, r.MaxDrawdown, 1e-9)
+	assert.Equal(s.T(), day(3), r.MaxDrawdownStart)
+	assert.Equal(s.T(), day(4), r.MaxDrawdownEnd)
+	// the mean return is 0
+	assert.InDelta(s.T(), 0, r.Sharpe, 1e-9)
+	assert.InDelta(s.T(), math.Sqrt(0.02/3)*math.Sqrt(TradingDaysPerYear), r.Volatility, 1e-9)
+
+	assert.InDelta(s.T(), (0.5+500.0/1100+500.0/990+0)/4, r.Exposure, 1e-9)
+	assert.InDelta(s.T(), 1000/((1000.0+1100+990+1990)/4), r.Turnover, 1e-9)
+}
+
+func (s *AnalyticsTestSuite) TestRiskFreeRate() {
+	s.history.Equity[4] = decimal.New(2000, 0)
+	r, err := Compute(s.history, s.activities, WithRiskFreeRate(1.26))
+	require.NoError(s.T(), err)
+	assert.Less(s.T(), r.Sortino, 0.0)
+
+	noRate, err := Compute(s.history, s.activities)
+	require.NoError(s.T(), err)
+	assert.Greater(s.T(), noRate.Sharpe, r.Sharpe)
+	assert.Greater(s.T(), noRate.Sortino, 0.0)
+}
+
+func (s *AnalyticsTestSuite) TestNoHistory() {
+	s.history.Timestamp = s.history.Timestamp[:2]
+	_, err := Compute(s.history, nil)
+	assert.Equal(s.T(), ErrNoHistory, err)
+}
+
+func (s *AnalyticsTestSuite) TestAnalyze() {
+	pages := 0
+	mock := &alpacatest.MockClient{
+		GetPortfolioHistoryFunc: func(period *string, timeframe *alpaca.RangeFreq, dateEnd *time.Time, extendedHours bool) (*alpaca.PortfolioHistory, error) {
+			assert.Equal(s.T(), "1M", *period)
+			assert.Equal(s.T(), alpaca.Day1, *timeframe)
+			return s.history, nil
+		},
+		GetAccountActivitiesFunc: func(activityType *string, opts *alpaca.AccountActivitiesRequest) ([]alpaca.AccountActivity, error) {
+			assert.Equal(s.T(), []string{"FILL", "CSD", "CSW", "JNLC"}, *opts.ActivityTypes)
+			pages++
+			if pages == 1 {
+				assert.True(s.T(), day(1).Equal(*opts.After))
+				// a full page
+				page := make([]alpaca.AccountActivity, *opts.PageSize)
+				for i := range page {
+					page[i] = s.activities[2]
+				}
+				return page, nil
+			}
+			assert.True(s.T(), s.activities[2].TransactionTime.Equal(*opts.After))
+			return s.activities[:2], nil
+		},
+	}
+
+	r, err := Analyze(mock, "1M")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), 2, pages)
+	assert.InDelta(s.T(), 1000/((1000.0+1100+990+1990)/4), r.Turnover, 1e-9)
+}
@@ -0,0 +1,317 @@
+// Package backtest replays historical market data through the handlers of
+// a strategy, the same ones it subscribes to the live v2/stream with, and
+// simulates the fills of the orders it places against that data.
+package backtest
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/bars"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// DataSource provides the historical market data of a backtest.
+// *alpaca.Client implements it.
+type DataSource interface {
+	GetTrades(symbol string, start, end time.Time, limit int) <-chan v2.TradeItem
+	GetBars(
+		symbol string, timeFrame v2.TimeFrame, adjustment v2.Adjustment,
+		start, end time.Time, limit int,
+	) <-chan v2.BarItem
+}
+
+// Config configures a backtest.
+type Config struct {
+	// Start and End delimit the replayed data
+	Start, End time.Time
+	// TimeFrame of the replayed bars, v2.Min by default
+	TimeFrame v2.TimeFrame
+	// Adjustment of the replayed bars, v2.Raw by default
+	Adjustment v2.Adjustment
+	// Resample, if set, resamples the bars of TimeFrame into bars of this
+	// time frame, e.g. 5 * time.Minute, before they are replayed, as the
+	// bars.Aggregator resamples the bars of the stream
+	Resample time.Duration
+	// ResampleOptions configure the resampling
+	ResampleOptions bars.ResampleOptions
+	// Cash is the starting cash of the account
+	Cash float64
+	// Slippage is the fraction of the price by which market and stop
+	// orders fill worse, e.g. 0.001 for 10 basis points
+	Slippage float64
+	// Latency is the delay between placing an order and the first bar
+	// or trade it can fill against
+	Latency time.Duration
+}
+
+var (
+	// ErrRunning is returned when a backtest is run or subscribed to while it runs
+	ErrRunning = errors.New("backtest is running")
+	// ErrNoData is returned when a backtest is run without subscriptions
+	ErrNoData = errors.New("backtest has no subscriptions")
+)
+
+// event is a bar or a trade of the replayed data
+type event struct {
+	symbol    string
+	timestamp time.Time
+	bar       *v2.Bar
+	trade     *v2.Trade
+}
+
+// Backtest replays historical data and simulates a broker. Its Subscribe
+// methods mirror the ones of the v2/stream package, and its PlaceOrder and
+// CancelOrder methods the ones of alpaca.Client, so a strategy can be run
+// against it before going live.
+type Backtest struct {
+	src DataSource
+	cfg Config
+
+	mu            sync.Mutex
+	running       bool
+	now           time.Time
+	barHandlers   map[string]func(bar stream.Bar)
+	tradeHandlers map[string]func(trade stream.Trade)
+	updateHandler func(update alpaca.TradeUpdate)
+	broker        *broker
+}
+
+// New creates a backtest replaying the data of src.
+func New(src DataSource, cfg Config) *Backtest {
+	if cfg.TimeFrame == "" {
+		cfg.TimeFrame = v2.Min
+	}
+	if cfg.Adjustment == "" {
+		cfg.Adjustment = v2.Raw
+	}
+	return &Backtest{
+		src:           src,
+		cfg:           cfg,
+		barHandlers:   map[string]func(bar stream.Bar){},
+		tradeHandlers: map[string]func(trade stream.Trade){},
+	}
+}
+
+// SubscribeBars replays the bars of symbols to handler.
+func (b *Backtest) SubscribeBars(handler func(bar stream.Bar), symbols ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.running {
+		return ErrRunning
+	}
+	for _, symbol := range symbols {
+		b.barHandlers[symbol] = handler
+	}
+	return nil
+}
+
+// SubscribeTrades replays the trades of symbols to handler.
+func (b *Backtest) SubscribeTrades(handler func(trade stream.Trade), symbols ...string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.running {
+		return ErrRunning
+	}
+	for _, symbol := range symbols {
+		b.tradeHandlers[symbol] = handler
+	}
+	return nil
+}
+
+// SubscribeTradeUpdates sends the updates of the simulated orders to handler.
+func (b *Backtest) SubscribeTradeUpdates(handler func(update alpaca.TradeUpdate)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.running {
+		return ErrRunning
+	}
+	b.updateHandler = handler
+	return nil
+}
+
+// Now returns the time of the bar or trade being replayed.
+func (b *Backtest) Now() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.now
+}
+
+// Run loads the data of the subscribed symbols, replays it in time order
+// and returns the report of the simulated trading. Orders are filled against
+// a bar or trade before it is delivered to the handlers.
+func (b *Backtest) Run() (*Report, error) {
+	b.mu.Lock()
+	if b.running {
+		b.mu.Unlock()
+		return nil, ErrRunning
+	}
+	if len(b.barHandlers) == 0 && len(b.tradeHandlers) == 0 {
+		b.mu.Unlock()
+		return nil, ErrNoData
+	}
+	b.running = true
+	b.broker = newBroker(b.cfg)
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		b.running = false
+		b.mu.Unlock()
+	}()
+
+	events, err := b.load()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range events {
+		b.mu.Lock()
+		b.now = e.timestamp
+		updates := b.broker.match(e)
+		b.mu.Unlock()
+
+		b.sendUpdates(updates)
+		if e.bar != nil {
+			b.barHandlers[e.symbol](stream.Bar{
+				Symbol:     e.symbol,
+				Open:       e.bar.Open,
+				High:       e.bar.High,
+				Low:        e.bar.Low,
+				Close:      e.bar.Close,
+				Volume:     e.bar.Volume,
+				Timestamp:  e.bar.Timestamp,
+				TradeCount: e.bar.TradeCount,
+				VWAP:       e.bar.VWAP,
+			})
+		} else {
+			b.tradeHandlers[e.symbol](stream.Trade{
+				ID:         e.trade.ID,
+				Symbol:     e.symbol,
+				Exchange:   e.trade.Exchange,
+				Price:      e.trade.Price,
+				Size:       e.trade.Size,
+				Timestamp:  e.trade.Timestamp,
+				Conditions: e.trade.Conditions,
+				Tape:       e.trade.Tape,
+			})
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.broker.report(), nil
+}
+
+// load fetches the data of every subscription and sorts it by time
+func (b *Backtest) load() ([]event, error) {
+	var events []event
+	for symbol := range b.barHandlers {
+		items := b.src.GetBars(symbol, b.cfg.TimeFrame, b.cfg.Adjustment, b.cfg.Start, b.cfg.End, math.MaxInt32)
+		var symbolBars []v2.Bar
+		for item := range items {
+			if item.Error != nil {
+				return nil, item.Error
+			}
+			symbolBars = append(symbolBars, item.Bar)
+		}
+		symbolBars, err := b.resample(symbol, symbolBars)
+		if err != nil {
+			return nil, err
+		}
+		for i := range symbolBars {
+			bar := symbolBars[i]
+			events = append(events, event{symbol: symbol, timestamp: bar.Timestamp, bar: &bar})
+		}
+	}
+	for symbol := range b.tradeHandlers {
+		for item := range b.src.GetTrades(symbol, b.cfg.Start, b.cfg.End, math.MaxInt32) {
+			if item.Error != nil {
+				return nil, item.Error
+			}
+			trade := item.Trade
+			events = append(events, event{symbol: symbol, timestamp: trade.Timestamp, trade: &trade})
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].timestamp.Equal(events[j].timestamp) {
+			return events[i].symbol < events[j].symbol
+		}
+		return events[i].timestamp.Before(events[j].timestamp)
+	})
+	return events, nil
+}
+
+// timeFrames are the durations of the time frames of the replayed bars
+var timeFrames = map[v2.TimeFrame]time.Duration{
+	v2.Min:  time.Minute,
+	v2.Hour: time.Hour,
+	v2.Day:  bars.Day,
+}
+
+// resample resamples the bars of symbol as configured, if at all
+func (b *Backtest) resample(symbol string, symbolBars []v2.Bar) ([]v2.Bar, error) {
+	if b.cfg.Resample == 0 {
+		return symbolBars, nil
+	}
+	converted := make([]stream.Bar, len(symbolBars))
+	for i, bar := range symbolBars {
+		converted[i] = bars.FromV2(symbol, bar)
+	}
+	resampled, err := bars.Resample(converted, timeFrames[b.cfg.TimeFrame], b.cfg.Resample, b.cfg.ResampleOptions)
+	if err != nil {
+		return nil, err
+	}
+	symbolBars = make([]v2.Bar, len(resampled))
+	for i, bar := range resampled {
+		symbolBars[i] = bars.ToV2(bar)
+	}
+	return symbolBars, nil
+}
+
+func (b *Backtest) sendUpdates(updates []alpaca.TradeUpdate) {
+	if b.updateHandler == nil {
+		return
+	}
+	for _, u := range updates {
+		b.updateHandler(u)
+	}
+}
+
+// PlaceOrder places a simulated order, which fills against the replayed
+// data. Market, limit and stop orders are supported.
+func (b *Backtest) PlaceOrder(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+	b.mu.Lock()
+	if !b.running {
+		b.mu.Unlock()
+		return nil, errors.New("orders can only be placed while the backtest runs")
+	}
+	order, update, err := b.broker.place(req, b.now)
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	b.sendUpdates([]alpaca.TradeUpdate{update})
+	return order, nil
+}
+
+// CancelOrder cancels a simulated order that is not filled yet.
+func (b *Backtest) CancelOrder(orderID string) error {
+	b.mu.Lock()
+	if !b.running {
+		b.mu.Unlock()
+		return errors.New("orders can only be canceled while the backtest runs")
+	}
+	update, err := b.broker.cancel(orderID, b.now)
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	b.sendUpdates([]alpaca.TradeUpdate{update})
+	return nil
+}
@@ -0,0 +1,208 @@
+package backtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type BacktestTestSuite struct {
+	suite.Suite
+}
+
+func TestBacktestTestSuite(t *testing.T) {
+	suite.Run(t, new(BacktestTestSuite))
+}
+
+// fakeSource serves fixed bars and trades
+type fakeSource struct {
+	bars   map[string][]v2.Bar
+	trades map[string][]v2.Trade
+	err    error
+}
+
+func (f *fakeSource) GetBars(
+	symbol string, timeFrame v2.TimeFrame, adjustment v2.Adjustment,
+	start, end time.Time, limit int,
+) <-chan v2.BarItem {
+	ch := make(chan v2.BarItem, len(f.bars[symbol])+1)
+	for _, bar := range f.bars[symbol] {
+		ch <- v2.BarItem{Bar: bar}
+	}
+	if f.err != nil {
+		ch <- v2.BarItem{Error: f.err}
+	}
+	close(ch)
+	return ch
+}
+
+func (f *fakeSource) GetTrades(symbol string, start, end time.Time, limit int) <-chan v2.TradeItem {
+	ch := make(chan v2.TradeItem, len(f.trades[symbol]))
+	for _, trade := range f.trades[symbol] {
+		ch <- v2.TradeItem{Trade: trade}
+	}
+	close(ch)
+	return ch
+}
+
+var start = time.Date(2021, 3, 1, 14, 30, 0, 0, time.UTC)
+
+func bar(minute int, open, high, low, close float64) v2.Bar {
+	return v2.Bar{
+		Open:      open,
+		High:      high,
+		Low:       low,
+		Close:     close,
+		Volume:    100,
+		Timestamp: start.Add(time.Duration(minute) * time.Minute),
+	}
+}
+
+func (s *BacktestTestSuite) TestRun() {
+	src := &fakeSource{
+		bars: map[string][]v2.Bar{
+			"AAPL": {
+				bar(0, 100, 101, 99, 100),
+				bar(1, 100, 102, 100, 101),
+				bar(2, 101, 104, 101, 103),
+				bar(3, 103, 103, 102, 102),
+			},
+		},
+		trades: map[string][]v2.Trade{
+			"MSFT": {{ID: 1, Price: 200, Size: 10, Timestamp: start.Add(30 * time.Second)}},
+		},
+	}
+	bt := New(src, Config{Start: start, End: start.Add(time.Hour), Cash: 10000, Slippage: 0.01})
+
+	symbol := "AAPL"
+	var (
+		timestamps []time.Time
+		updates    []alpaca.TradeUpdate
+	)
+	require.NoError(s.T(), bt.SubscribeTradeUpdates(func(u alpaca.TradeUpdate) {
+		updates = append(updates, u)
+	}))
+	require.NoError(s.T(), bt.SubscribeTrades(func(t stream.Trade) {
+		timestamps = append(timestamps, t.Timestamp)
+		assert.Equal(s.T(), "MSFT", t.Symbol)
+	}, "MSFT"))
+	require.NoError(s.T(), bt.SubscribeBars(func(b stream.Bar) {
+		timestamps = append(timestamps, b.Timestamp)
+		assert.Equal(s.T(), b.Timestamp, bt.Now())
+		switch b.Timestamp {
+		case start:
+			// fills at the open of the next bar, with slippage
+			_, err := bt.PlaceOrder(alpaca.PlaceOrderRequest{
+				AssetKey: &symbol,
+				Qty:      decimal.New(10, 0),
+				Side:     alpaca.Buy,
+				Type:     alpaca.Market,
+			})
+			require.NoError(s.T(), err)
+		case start.Add(time.Minute):
+			// fills once the high reaches the limit
+			limit := decimal.New(103, 0)
+			_, err := bt.PlaceOrder(alpaca.PlaceOrderRequest{
+				AssetKey:   &symbol,
+				Qty:        decimal.New(5, 0),
+				Side:       alpaca.Sell,
+				Type:       alpaca.Limit,
+				LimitPrice: &limit,
+			})
+			require.NoError(s.T(), err)
+			// never fills and is canceled
+			limit = decimal.New(90, 0)
+			order, err := bt.PlaceOrder(alpaca.PlaceOrderRequest{
+				AssetKey:   &symbol,
+				Qty:        decimal.New(5, 0),
+				Side:       alpaca.Buy,
+				Type:       alpaca.Limit,
+				LimitPrice: &limit,
+			})
+			require.NoError(s.T(), err)
+			require.NoError(s.T(), bt.CancelOrder(order.ID))
+			assert.Error(s.T(), bt.CancelOrder(order.ID))
+		}
+	}, symbol))
+
+	report, err := bt.Run()
+	require.NoError(s.T(), err)
+
+	// the data is replayed in time order
+	require.Len(s.T(), timestamps, 5)
+	assert.Equal(s.T(), start.Add(30*time.Second), timestamps[1])
+
+	require.Len(s.T(), report.Fills, 2)
+	assert.Equal(s.T(), start.Add(time.Minute), report.Fills[0].Time)
+	assert.InDelta(s.T(), 101, report.Fills[0].Price, 1e-9)
+	assert.Equal(s.T(), start.Add(2*time.Minute), report.Fills[1].Time)
+	assert.InDelta(s.T(), 103, report.Fills[1].Price, 1e-9)
+
+	pos := report.Positions[symbol]
+	assert.Equal(s.T(), float64(5), pos.Qty)
+	assert.InDelta(s.T(), 101, pos.AvgEntryPrice, 1e-9)
+	assert.InDelta(s.T(), 10, pos.RealizedPL, 1e-9)
+	assert.InDelta(s.T(), 5, report.UnrealizedPL, 1e-9)
+	assert.InDelta(s.T(), 10000-1010+515, report.Cash, 1e-9)
+	assert.InDelta(s.T(), 15, report.PL(), 1e-9)
+
+	var events []alpaca.TradeEvent
+	for _, u := range updates {
+		events = append(events, u.Event)
+	}
+	assert.Equal(s.T(), []alpaca.TradeEvent{
+		alpaca.EventNew, alpaca.EventFill, alpaca.EventNew, alpaca.EventNew,
+		alpaca.EventCanceled, alpaca.EventFill,
+	}, events)
+}
+
+func (s *BacktestTestSuite) TestRunErrors() {
+	_, err := New(&fakeSource{}, Config{}).Run()
+	assert.Equal(s.T(), ErrNoData, err)
+
+	bt := New(&fakeSource{err: errors.New("data unavailable")}, Config{})
+	require.NoError(s.T(), bt.SubscribeBars(func(stream.Bar) {}, "AAPL"))
+	_, err = bt.Run()
+	assert.EqualError(s.T(), err, "data unavailable")
+
+	_, err = bt.PlaceOrder(alpaca.PlaceOrderRequest{})
+	assert.Error(s.T(), err)
+}
+
+func (s *BacktestTestSuite) TestResample() {
+	src := &fakeSource{
+		bars: map[string][]v2.Bar{
+			"AAPL": {
+				bar(0, 100, 101, 99, 100),
+				bar(1, 100, 102, 100, 101),
+				bar(2, 101, 104, 101, 103),
+				bar(3, 103, 103, 102, 102),
+				bar(4, 102, 102, 100, 100),
+			},
+		},
+	}
+	bt := New(src, Config{Start: start, End: start.Add(time.Hour), Cash: 10000, Resample: 2 * time.Minute})
+
+	var replayed []stream.Bar
+	require.NoError(s.T(), bt.SubscribeBars(func(b stream.Bar) {
+		replayed = append(replayed, b)
+	}, "AAPL"))
+	_, err := bt.Run()
+	require.NoError(s.T(), err)
+
+	// the bar of the last minute alone is partial
+	require.Len(s.T(), replayed, 2)
+	assert.Equal(s.T(), stream.Bar{Symbol: "AAPL", Open: 100, High: 102, Low: 99, Close: 101, Volume: 200, Timestamp: start},
+		replayed[0])
+	assert.Equal(s.T(), start.Add(2*time.Minute), replayed[1].Timestamp)
+	assert.Equal(s.T(), 104.0, replayed[1].High)
+	assert.Equal(s.T(), 102.0, replayed[1].Close)
+}
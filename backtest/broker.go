@@ -0,0 +1,222 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/shopspring/decimal"
+)
+
+// broker simulates the fills of the orders placed during a backtest
+type broker struct {
+	cfg        Config
+	nextID     int
+	open       []*alpaca.Order
+	orders     map[string]*alpaca.Order
+	cash       float64
+	positions  map[string]*Position
+	lastPrices map[string]float64
+	fills      []Fill
+}
+
+func newBroker(cfg Config) *broker {
+	return &broker{
+		cfg:        cfg,
+		orders:     map[string]*alpaca.Order{},
+		cash:       cfg.Cash,
+		positions:  map[string]*Position{},
+		lastPrices: map[string]float64{},
+	}
+}
+
+func (b *broker) place(req alpaca.PlaceOrderRequest, now time.Time) (*alpaca.Order, alpaca.TradeUpdate, error) {
+	if req.AssetKey == nil || *req.AssetKey == "" {
+		return nil, alpaca.TradeUpdate{}, errors.New("symbol is required")
+	}
+	if !req.Qty.IsPositive() {
+		return nil, alpaca.TradeUpdate{}, errors.New("qty must be positive, notional orders are not supported")
+	}
+	if req.Side != alpaca.Buy && req.Side != alpaca.Sell {
+		return nil, alpaca.TradeUpdate{}, fmt.Errorf("invalid side %q", req.Side)
+	}
+	switch req.Type {
+	case alpaca.Market:
+	case alpaca.Limit:
+		if req.LimitPrice == nil {
+			return nil, alpaca.TradeUpdate{}, errors.New("limit orders require a limit price")
+		}
+	case alpaca.Stop:
+		if req.StopPrice == nil {
+			return nil, alpaca.TradeUpdate{}, errors.New("stop orders require a stop price")
+		}
+	default:
+		return nil, alpaca.TradeUpdate{}, fmt.Errorf("%s orders are not supported by the backtest", req.Type)
+	}
+
+	b.nextID++
+	order := &alpaca.Order{
+		ID:            fmt.Sprintf("backtest-%d", b.nextID),
+		ClientOrderID: req.ClientOrderID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		SubmittedAt:   now,
+		Symbol:        *req.AssetKey,
+		Qty:           req.Qty,
+		Type:          req.Type,
+		Side:          req.Side,
+		TimeInForce:   req.TimeInForce,
+		LimitPrice:    copyPrice(req.LimitPrice),
+		StopPrice:     copyPrice(req.StopPrice),
+		Status:        alpaca.OrderNew,
+	}
+	b.orders[order.ID] = order
+	b.open = append(b.open, order)
+
+	o := *order
+	return &o, alpaca.TradeUpdate{Event: alpaca.EventNew, Order: o}, nil
+}
+
+// copyPrice keeps the orders from sharing the prices of the requests
+func copyPrice(price *decimal.Decimal) *decimal.Decimal {
+	if price == nil {
+		return nil
+	}
+	p := *price
+	return &p
+}
+
+func (b *broker) cancel(orderID string, now time.Time) (alpaca.TradeUpdate, error) {
+	for i, order := range b.open {
+		if order.ID != orderID {
+			continue
+		}
+		b.open = append(b.open[:i], b.open[i+1:]...)
+		order.Status = alpaca.OrderCanceled
+		order.UpdatedAt = now
+		order.CanceledAt = &now
+		return alpaca.TradeUpdate{Event: alpaca.EventCanceled, Order: *order}, nil
+	}
+	if _, ok := b.orders[orderID]; ok {
+		return alpaca.TradeUpdate{}, alpaca.ErrOrderNotCancelable
+	}
+	return alpaca.TradeUpdate{}, fmt.Errorf("order %s not found", orderID)
+}
+
+// match fills the open orders of the symbol of e that were placed at least
+// Latency before it, and returns their updates.
+func (b *broker) match(e event) []alpaca.TradeUpdate {
+	var open, high, low, last float64
+	if e.bar != nil {
+		open, high, low, last = e.bar.Open, e.bar.High, e.bar.Low, e.bar.Close
+	} else {
+		open, high, low, last = e.trade.Price, e.trade.Price, e.trade.Price, e.trade.Price
+	}
+
+	var updates []alpaca.TradeUpdate
+	remaining := b.open[:0]
+	for _, order := range b.open {
+		if order.Symbol != e.symbol || e.timestamp.Before(order.SubmittedAt.Add(b.cfg.Latency)) {
+			remaining = append(remaining, order)
+			continue
+		}
+		price, ok := b.fillPrice(order, open, high, low)
+		if !ok {
+			remaining = append(remaining, order)
+			continue
+		}
+		updates = append(updates, b.fill(order, price, e.timestamp))
+	}
+	b.open = remaining
+	b.lastPrices[e.symbol] = last
+	return updates
+}
+
+// fillPrice returns the price order fills at within the given range,
+// and false if it does not fill.
+func (b *broker) fillPrice(order *alpaca.Order, open, high, low float64) (float64, bool) {
+	buy := order.Side == alpaca.Buy
+	switch order.Type {
+	case alpaca.Limit:
+		limit, _ := order.LimitPrice.Float64()
+		if buy && low <= limit {
+			return math.Min(open, limit), true
+		}
+		if !buy && high >= limit {
+			return math.Max(open, limit), true
+		}
+		return 0, false
+	case alpaca.Stop:
+		stop, _ := order.StopPrice.Float64()
+		if buy && high >= stop {
+			return b.slip(math.Max(open, stop), buy), true
+		}
+		if !buy && low <= stop {
+			return b.slip(math.Min(open, stop), buy), true
+		}
+		return 0, false
+	default:
+		return b.slip(open, buy), true
+	}
+}
+
+func (b *broker) slip(price float64, buy bool) float64 {
+	if buy {
+		return price * (1 + b.cfg.Slippage)
+	}
+	return price * (1 - b.cfg.Slippage)
+}
+
+func (b *broker) fill(order *alpaca.Order, price float64, at time.Time) alpaca.TradeUpdate {
+	qty, _ := order.Qty.Float64()
+	signed := qty
+	if order.Side == alpaca.Sell {
+		signed = -qty
+	}
+	b.cash -= signed * price
+
+	pos, ok := b.positions[order.Symbol]
+	if !ok {
+		pos = &Position{Symbol: order.Symbol}
+		b.positions[order.Symbol] = pos
+	}
+	pos.apply(signed, price)
+
+	b.fills = append(b.fills, Fill{
+		OrderID: order.ID,
+		Symbol:  order.Symbol,
+		Side:    order.Side,
+		Qty:     qty,
+		Price:   price,
+		Time:    at,
+	})
+
+	avg := decimal.NewFromFloat(price)
+	order.Status = alpaca.OrderFilled
+	order.UpdatedAt = at
+	order.FilledAt = &at
+	order.FilledQty = order.Qty
+	order.FilledAvgPrice = &avg
+	return alpaca.TradeUpdate{Event: alpaca.EventFill, Order: *order}
+}
+
+func (b *broker) report() *Report {
+	r := &Report{
+		StartingCash: b.cfg.Cash,
+		Cash:         b.cash,
+		Equity:       b.cash,
+		Fills:        b.fills,
+		Positions:    map[string]Position{},
+	}
+	for symbol, pos := range b.positions {
+		p := *pos
+		p.LastPrice = b.lastPrices[symbol]
+		r.Positions[symbol] = p
+		r.Equity += p.Qty * p.LastPrice
+		r.RealizedPL += p.RealizedPL
+		r.UnrealizedPL += p.UnrealizedPL()
+	}
+	return r
+}
@@ -0,0 +1,65 @@
+package backtest
+
+import (
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/shopspring/decimal"
+)
+
+// Fill is a simulated execution of an order
+type Fill struct {
+	OrderID string
+	Symbol  string
+	Side    alpaca.Side
+	Qty     float64
+	Price   float64
+	Time    time.Time
+}
+
+// Position is the simulated position in a symbol, negative when short
+type Position struct {
+	Symbol        string
+	Qty           float64
+	AvgEntryPrice float64
+	// LastPrice is the last replayed price of the symbol
+	LastPrice  float64
+	RealizedPL float64
+}
+
+// UnrealizedPL returns the profit or loss of the position at its last price
+func (p Position) UnrealizedPL() float64 {
+	return p.Qty * (p.LastPrice - p.AvgEntryPrice)
+}
+
+// apply adds the signed quantity qty filled at price to the position,
+// realizing the profit or loss of the quantity it closes.
+func (p *Position) apply(qty, price float64) {
+	newQty, avg, realized := alpaca.ApplyFill(
+		decimal.NewFromFloat(p.Qty), decimal.NewFromFloat(p.AvgEntryPrice),
+		decimal.NewFromFloat(qty), decimal.NewFromFloat(price),
+	)
+	p.Qty, _ = newQty.Float64()
+	p.AvgEntryPrice, _ = avg.Float64()
+	pl, _ := realized.Float64()
+	p.RealizedPL += pl
+}
+
+// Report is the result of a backtest
+type Report struct {
+	// Fills are the simulated executions, in time order
+	Fills []Fill
+	// Positions are the positions in every traded symbol at the end of the backtest
+	Positions    map[string]Position
+	StartingCash float64
+	Cash         float64
+	// Equity is the cash plus the value of the positions at their last price
+	Equity       float64
+	RealizedPL   float64
+	UnrealizedPL float64
+}
+
+// PL returns the total profit or loss of the backtest
+func (r *Report) PL() float64 {
+	return r.Equity - r.StartingCash
+}
@@ -0,0 +1,188 @@
+package bars
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// corporateAction is a split or a cash dividend taking effect at the open of
+// its ex date
+type corporateAction struct {
+	exDate string
+	// ratio is the number of new shares per old share of a split
+	ratio float64
+	// cash is the cash dividend per share
+	cash float64
+}
+
+// Series is a continuous series of the bars of a symbol adjusted for its
+// splits and cash dividends as of its latest bar, combining historical and
+// streamed bars. A corporate action takes effect once a bar of its ex date
+// or later is added, adjusting every bar before it: the prices and VWAP are
+// divided by the ratio of a split and the volumes multiplied by it, and the
+// prices are multiplied by 1 - dividend / previous close for a dividend.
+type Series struct {
+	symbol   string
+	actions  []corporateAction
+	onAdjust func(bars []stream.Bar)
+
+	mu sync.Mutex
+	// raw are the unadjusted bars sorted by timestamp
+	raw []stream.Bar
+}
+
+// NewSeries creates the series of symbol adjusted for the announcements of
+// its corporate actions, as returned by alpaca.Client.ListAnnouncements,
+// according to adjustment: splits for v2.Split, cash dividends for
+// v2.Dividend or both for v2.All. Stock dividends count as splits. onAdjust,
+// if not nil, receives the whole adjusted series again each time a corporate
+// action takes effect, e.g. at the open after a split while streaming.
+func NewSeries(
+	symbol string,
+	adjustment v2.Adjustment,
+	announcements []alpaca.Announcement,
+	onAdjust func(bars []stream.Bar),
+) *Series {
+	splits := adjustment == v2.Split || adjustment == v2.All
+	dividends := adjustment == v2.Dividend || adjustment == v2.All
+
+	var actions []corporateAction
+	for _, a := range announcements {
+		if !a.Concerns(symbol) || a.ExDate == "" {
+			continue
+		}
+		if ratio, ok := a.SplitRatio(); ok {
+			if splits {
+				r, _ := ratio.Float64()
+				actions = append(actions, corporateAction{exDate: a.ExDate, ratio: r})
+			}
+			continue
+		}
+		if dividends && a.CAType == alpaca.CATypeDividend && a.Cash.IsPositive() {
+			cash, _ := a.Cash.Float64()
+			actions = append(actions, corporateAction{exDate: a.ExDate, cash: cash})
+		}
+	}
+	return &Series{symbol: symbol, actions: actions, onAdjust: onAdjust}
+}
+
+// AddBars adds historical bars of the symbol, e.g. returned by
+// alpaca.Client.GetBars. They must not be adjusted (v2.Raw).
+func (s *Series) AddBars(bars ...v2.Bar) {
+	converted := make([]stream.Bar, len(bars))
+	for i, bar := range bars {
+		converted[i] = FromV2(s.symbol, bar)
+	}
+	s.add(converted...)
+}
+
+// Add adds a bar of the symbol, replacing the bar of the same timestamp, if
+// any. It can be passed to stream.SubscribeBars.
+func (s *Series) Add(bar stream.Bar) {
+	if bar.Symbol == s.symbol {
+		s.add(bar)
+	}
+}
+
+// add adds bars and calls onAdjust if a corporate action took effect
+func (s *Series) add(bars ...stream.Bar) {
+	s.mu.Lock()
+	before := s.asOf()
+	for _, bar := range bars {
+		i := sort.Search(len(s.raw), func(i int) bool { return !s.raw[i].Timestamp.Before(bar.Timestamp) })
+		if i < len(s.raw) && s.raw[i].Timestamp.Equal(bar.Timestamp) {
+			s.raw[i] = bar
+			continue
+		}
+		s.raw = append(s.raw, stream.Bar{})
+		copy(s.raw[i+1:], s.raw[i:])
+		s.raw[i] = bar
+	}
+	var adjusted []stream.Bar
+	if s.onAdjust != nil && s.tookEffect(before, s.asOf()) {
+		adjusted = s.adjusted()
+	}
+	s.mu.Unlock()
+
+	if adjusted != nil {
+		s.onAdjust(adjusted)
+	}
+}
+
+// Bars returns the adjusted bars of the series sorted by timestamp
+func (s *Series) Bars() []stream.Bar {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.adjusted()
+}
+
+// asOf returns the date of the latest bar, with s.mu held
+func (s *Series) asOf() string {
+	if len(s.raw) == 0 {
+		return ""
+	}
+	return date(s.raw[len(s.raw)-1])
+}
+
+// tookEffect returns true if a corporate action took effect between the
+// dates before and after
+func (s *Series) tookEffect(before, after string) bool {
+	for _, a := range s.actions {
+		if a.exDate > before && a.exDate <= after {
+			return true
+		}
+	}
+	return false
+}
+
+// adjusted returns the adjusted copy of the bars, with s.mu held
+func (s *Series) adjusted() []stream.Bar {
+	asOf := s.asOf()
+	type factor struct {
+		exDate        string
+		price, volume float64
+	}
+	var factors []factor
+	for _, a := range s.actions {
+		if a.exDate > asOf {
+			continue
+		}
+		if a.ratio > 0 {
+			factors = append(factors, factor{exDate: a.exDate, price: 1 / a.ratio, volume: a.ratio})
+			continue
+		}
+		// the dividend is relative to the close before the ex date
+		i := sort.Search(len(s.raw), func(i int) bool { return date(s.raw[i]) >= a.exDate })
+		if i == 0 || s.raw[i-1].Close <= a.cash {
+			continue
+		}
+		factors = append(factors, factor{exDate: a.exDate, price: 1 - a.cash/s.raw[i-1].Close, volume: 1})
+	}
+
+	bars := make([]stream.Bar, len(s.raw))
+	for i, bar := range s.raw {
+		d := date(bar)
+		for _, f := range factors {
+			if d >= f.exDate {
+				continue
+			}
+			bar.Open *= f.price
+			bar.High *= f.price
+			bar.Low *= f.price
+			bar.Close *= f.price
+			bar.VWAP *= f.price
+			bar.Volume = uint64(float64(bar.Volume)*f.volume + 0.5)
+		}
+		bars[i] = bar
+	}
+	return bars
+}
+
+// date returns the New York date of the bar
+func date(bar stream.Bar) string {
+	return bar.Timestamp.In(newYork).Format("2006-01-02")
+}
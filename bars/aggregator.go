@@ -0,0 +1,230 @@
+// Package bars resamples 1-minute bars into bars of longer time frames
+// aligned to the trading sessions.
+package bars
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// Day is the time frame of the bars covering a whole session
+const Day = 24 * time.Hour
+
+// history is the number of recent bars of each symbol and time frame
+// that are kept to be corrected by late or updated minute bars
+const history = 3
+
+type seriesKey struct {
+	symbol    string
+	timeFrame time.Duration
+}
+
+// bucket collects the minute bars of one resampled bar
+type bucket struct {
+	start, end time.Time
+	minutes    map[time.Time]stream.Bar
+	emitted    bool
+}
+
+func (b *bucket) bar(symbol string) stream.Bar {
+	timestamps := make([]time.Time, 0, len(b.minutes))
+	for t := range b.minutes {
+		timestamps = append(timestamps, t)
+	}
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i].Before(timestamps[j]) })
+
+	bar := stream.Bar{Symbol: symbol, Timestamp: b.start}
+	var notional float64
+	for i, t := range timestamps {
+		m := b.minutes[t]
+		if i == 0 {
+			bar.Open, bar.High, bar.Low = m.Open, m.High, m.Low
+		}
+		if m.High > bar.High {
+			bar.High = m.High
+		}
+		if m.Low < bar.Low {
+			bar.Low = m.Low
+		}
+		bar.Close = m.Close
+		bar.Volume += m.Volume
+		bar.TradeCount += m.TradeCount
+		notional += m.VWAP * float64(m.Volume)
+	}
+	if bar.Volume > 0 {
+		bar.VWAP = notional / float64(bar.Volume)
+	}
+	return bar
+}
+
+// Aggregator resamples the 1-minute bars of the regular sessions into bars
+// of longer time frames. The bars of a time frame start at the session open
+// and the last bar of a session ends at its close, so on half-days it is
+// shorter. Day bars cover the whole session. Bars out of the sessions are
+// ignored.
+//
+// A bar is emitted once the minute bar ending it arrives, or once a minute
+// bar of a later bar arrives. A minute bar that arrives late, or that is
+// sent again with updated values, emits the corrected bar again, as long
+// as it belongs to one of the last few bars.
+type Aggregator struct {
+	sessions   Sessions
+	anchor     Anchor
+	timeFrames []time.Duration
+	handler    func(timeFrame time.Duration, bar stream.Bar)
+
+	mu     sync.Mutex
+	series map[seriesKey][]*bucket
+}
+
+// NewAggregator creates an aggregator sending the bars of timeFrames, whole
+// minutes or Day, to handler.
+func NewAggregator(
+	sessions Sessions,
+	handler func(timeFrame time.Duration, bar stream.Bar),
+	timeFrames ...time.Duration,
+) (*Aggregator, error) {
+	if len(timeFrames) == 0 {
+		return nil, fmt.Errorf("no time frames")
+	}
+	for _, tf := range timeFrames {
+		if tf <= 0 || tf%time.Minute != 0 {
+			return nil, fmt.Errorf("invalid time frame %s", tf)
+		}
+	}
+	return &Aggregator{
+		sessions:   sessions,
+		timeFrames: timeFrames,
+		handler:    handler,
+		series:     map[seriesKey][]*bucket{},
+	}, nil
+}
+
+// UseAnchor sets what the bars are aligned to, SessionOpen by default. With
+// MidnightUTC the bars of every hour are resampled, out of the sessions too.
+// It must be called before adding bars.
+func (a *Aggregator) UseAnchor(anchor Anchor) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.anchor = anchor
+}
+
+type emission struct {
+	timeFrame time.Duration
+	bar       stream.Bar
+}
+
+// Add adds a minute bar. It can be passed to stream.SubscribeBars.
+func (a *Aggregator) Add(bar stream.Bar) {
+	a.mu.Lock()
+	emissions := a.add(bar)
+	a.mu.Unlock()
+
+	a.emit(emissions)
+}
+
+// AddBars adds the minute bars of symbol, e.g. returned by alpaca.Client.GetBars.
+func (a *Aggregator) AddBars(symbol string, bars ...v2.Bar) {
+	var emissions []emission
+	a.mu.Lock()
+	for _, bar := range bars {
+		emissions = append(emissions, a.add(FromV2(symbol, bar))...)
+	}
+	a.mu.Unlock()
+
+	a.emit(emissions)
+}
+
+// Flush emits the bars that are not complete yet, e.g. after the last
+// historical bars were added.
+func (a *Aggregator) Flush() {
+	var emissions []emission
+	a.mu.Lock()
+	for key, buckets := range a.series {
+		for _, b := range buckets {
+			if !b.emitted {
+				b.emitted = true
+				emissions = append(emissions, emission{timeFrame: key.timeFrame, bar: b.bar(key.symbol)})
+			}
+		}
+	}
+	a.mu.Unlock()
+
+	sort.SliceStable(emissions, func(i, j int) bool {
+		return emissions[i].bar.Timestamp.Before(emissions[j].bar.Timestamp)
+	})
+	a.emit(emissions)
+}
+
+func (a *Aggregator) emit(emissions []emission) {
+	for _, e := range emissions {
+		a.handler(e.timeFrame, e.bar)
+	}
+}
+
+func (a *Aggregator) add(bar stream.Bar) []emission {
+	var emissions []emission
+	for _, tf := range a.timeFrames {
+		start, end, ok := window(a.anchor, a.sessions, bar.Timestamp, tf)
+		if !ok {
+			return nil
+		}
+
+		key := seriesKey{symbol: bar.Symbol, timeFrame: tf}
+		b, older := a.bucket(key, start, end)
+		if b == nil {
+			// too late to be corrected
+			continue
+		}
+		for _, o := range older {
+			o.emitted = true
+			emissions = append(emissions, emission{timeFrame: tf, bar: o.bar(bar.Symbol)})
+		}
+		b.minutes[bar.Timestamp] = bar
+		if b.emitted || !bar.Timestamp.Add(time.Minute).Before(end) {
+			b.emitted = true
+			emissions = append(emissions, emission{timeFrame: tf, bar: b.bar(bar.Symbol)})
+		}
+	}
+	return emissions
+}
+
+// bucket returns the bucket starting at start, creating it if needed, and
+// the older buckets that are complete now that it was created. It returns
+// nil when the bucket is older than the kept history.
+func (a *Aggregator) bucket(key seriesKey, start, end time.Time) (*bucket, []*bucket) {
+	buckets := a.series[key]
+	i := sort.Search(len(buckets), func(i int) bool { return !buckets[i].start.Before(start) })
+	if i < len(buckets) && buckets[i].start.Equal(start) {
+		return buckets[i], nil
+	}
+	if i == 0 && len(buckets) >= history {
+		return nil, nil
+	}
+
+	// a bucket older than the latest one is complete, so its
+	// bar is emitted as soon as a minute bar is added
+	b := &bucket{start: start, end: end, minutes: map[time.Time]stream.Bar{}, emitted: i < len(buckets)}
+	buckets = append(buckets, nil)
+	copy(buckets[i+1:], buckets[i:])
+	buckets[i] = b
+
+	var complete []*bucket
+	if i == len(buckets)-1 {
+		for _, o := range buckets[:i] {
+			if !o.emitted {
+				complete = append(complete, o)
+			}
+		}
+	}
+	if len(buckets) > history {
+		buckets = buckets[len(buckets)-history:]
+	}
+	a.series[key] = buckets
+	return b, complete
+}
@@ -0,0 +1,363 @@
+package bars
+
+import (
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type BarsTestSuite struct {
+	suite.Suite
+	emitted map[time.Duration][]stream.Bar
+}
+
+func TestBarsTestSuite(t *testing.T) {
+	suite.Run(t, new(BarsTestSuite))
+}
+
+func (s *BarsTestSuite) SetupTest() {
+	s.emitted = map[time.Duration][]stream.Bar{}
+}
+
+func (s *BarsTestSuite) handler(timeFrame time.Duration, bar stream.Bar) {
+	s.emitted[timeFrame] = append(s.emitted[timeFrame], bar)
+}
+
+func at(day, hour, minute int) time.Time {
+	return time.Date(2020, 11, day, hour, minute, 0, 0, newYork)
+}
+
+func minuteBar(t time.Time, price float64) stream.Bar {
+	return stream.Bar{Symbol: "AAPL", Open: price, High: price + 1, Low: price - 1, Close: price, Volume: 10, Timestamp: t}
+}
+
+func (s *BarsTestSuite) TestSessions() {
+	sessions, err := CalendarSessions([]alpaca.CalendarDay{
+		{Date: "2020-11-27", Open: "09:30", Close: "13:00"},
+	})
+	require.NoError(s.T(), err)
+	open, close, ok := sessions(at(27, 10, 0))
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), at(27, 9, 30), open)
+	assert.Equal(s.T(), at(27, 13, 0), close)
+	_, _, ok = sessions(at(26, 10, 0))
+	assert.False(s.T(), ok)
+
+	_, err = CalendarSessions([]alpaca.CalendarDay{{Date: "2020-11-27", Open: "9h30"}})
+	assert.Error(s.T(), err)
+
+	_, _, ok = RegularSessions()(at(28, 10, 0))
+	assert.False(s.T(), ok)
+	open, close, ok = RegularSessions()(at(27, 10, 0).UTC())
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), at(27, 9, 30), open)
+	assert.Equal(s.T(), at(27, 16, 0), close)
+}
+
+func (s *BarsTestSuite) TestHalfDay() {
+	sessions, err := CalendarSessions([]alpaca.CalendarDay{
+		{Date: "2020-11-27", Open: "09:30", Close: "13:00"},
+	})
+	require.NoError(s.T(), err)
+	a, err := NewAggregator(sessions, s.handler, time.Hour, Day)
+	require.NoError(s.T(), err)
+
+	// pre-market and every minute of the session
+	a.Add(minuteBar(at(27, 9, 0), 1))
+	for t := at(27, 9, 30); t.Before(at(27, 13, 0)); t = t.Add(time.Minute) {
+		a.Add(minuteBar(t, float64(t.Sub(at(27, 9, 30))/time.Minute)))
+	}
+
+	hours := s.emitted[time.Hour]
+	require.Len(s.T(), hours, 4)
+	assert.Equal(s.T(), at(27, 9, 30), hours[0].Timestamp)
+	assert.Equal(s.T(), float64(0), hours[0].Open)
+	assert.Equal(s.T(), float64(59), hours[0].Close)
+	assert.Equal(s.T(), float64(-1), hours[0].Low)
+	assert.Equal(s.T(), float64(60), hours[0].High)
+	assert.Equal(s.T(), uint64(600), hours[0].Volume)
+	// the last bar ends at the early close
+	assert.Equal(s.T(), at(27, 12, 30), hours[3].Timestamp)
+	assert.Equal(s.T(), uint64(300), hours[3].Volume)
+
+	days := s.emitted[Day]
+	require.Len(s.T(), days, 1)
+	assert.Equal(s.T(), at(27, 9, 30), days[0].Timestamp)
+	assert.Equal(s.T(), float64(209), days[0].Close)
+	assert.Equal(s.T(), uint64(2100), days[0].Volume)
+}
+
+func (s *BarsTestSuite) TestLateAndUpdatedBars() {
+	a, err := NewAggregator(RegularSessions(), s.handler, 5*time.Minute)
+	require.NoError(s.T(), err)
+	tf := 5 * time.Minute
+
+	a.Add(minuteBar(at(30, 9, 30), 10))
+	a.Add(minuteBar(at(30, 9, 32), 12))
+	// the first bar completes when a minute bar of the next one arrives
+	a.Add(minuteBar(at(30, 9, 35), 15))
+	require.Len(s.T(), s.emitted[tf], 1)
+	assert.Equal(s.T(), float64(12), s.emitted[tf][0].Close)
+	assert.Equal(s.T(), uint64(20), s.emitted[tf][0].Volume)
+
+	// a late minute bar emits the corrected bar
+	a.Add(minuteBar(at(30, 9, 34), 14))
+	require.Len(s.T(), s.emitted[tf], 2)
+	assert.Equal(s.T(), at(30, 9, 30), s.emitted[tf][1].Timestamp)
+	assert.Equal(s.T(), float64(14), s.emitted[tf][1].Close)
+	assert.Equal(s.T(), uint64(30), s.emitted[tf][1].Volume)
+
+	// the last minute bar completes a bar, and an update corrects it
+	a.Add(minuteBar(at(30, 9, 39), 19))
+	updated := minuteBar(at(30, 9, 39), 20)
+	updated.Volume = 50
+	a.Add(updated)
+	require.Len(s.T(), s.emitted[tf], 4)
+	assert.Equal(s.T(), float64(19), s.emitted[tf][2].Close)
+	assert.Equal(s.T(), float64(20), s.emitted[tf][3].Close)
+	assert.Equal(s.T(), uint64(60), s.emitted[tf][3].Volume)
+
+	// bars older than the kept history are ignored
+	a.AddBars("AAPL", v2.Bar{Close: 40, Timestamp: at(30, 9, 40)}, v2.Bar{Close: 45, Timestamp: at(30, 9, 45)})
+	a.Add(minuteBar(at(30, 9, 31), 11))
+	require.Len(s.T(), s.emitted[tf], 5)
+	assert.Equal(s.T(), at(30, 9, 40), s.emitted[tf][4].Timestamp)
+
+	a.Flush()
+	require.Len(s.T(), s.emitted[tf], 6)
+	assert.Equal(s.T(), float64(45), s.emitted[tf][5].Close)
+	a.Flush()
+	assert.Len(s.T(), s.emitted[tf], 6)
+}
+
+func (s *BarsTestSuite) TestInvalidTimeFrames() {
+	_, err := NewAggregator(RegularSessions(), s.handler)
+	assert.Error(s.T(), err)
+	_, err = NewAggregator(RegularSessions(), s.handler, 90*time.Second)
+	assert.Error(s.T(), err)
+}
+
+func (s *BarsTestSuite) TestVWAPAndTradeCount() {
+	a, err := NewAggregator(RegularSessions(), s.handler, 2*time.Minute)
+	require.NoError(s.T(), err)
+	first := minuteBar(at(27, 9, 30), 100)
+	first.TradeCount, first.VWAP = 3, 100
+	second := minuteBar(at(27, 9, 31), 101)
+	second.Volume, second.TradeCount, second.VWAP = 30, 5, 102
+	a.Add(first)
+	a.Add(second)
+
+	require.Len(s.T(), s.emitted[2*time.Minute], 1)
+	bar := s.emitted[2*time.Minute][0]
+	assert.Equal(s.T(), uint64(8), bar.TradeCount)
+	assert.InDelta(s.T(), 101.5, bar.VWAP, 1e-9)
+}
+
+func (s *BarsTestSuite) TestMerge() {
+	historical := []v2.Bar{
+		{Open: 1, Close: 2, Volume: 10, Timestamp: at(27, 9, 30), TradeCount: 4, VWAP: 1.5},
+		{Open: 2, Close: 3, Volume: 5, Timestamp: at(27, 9, 31), TradeCount: 1, VWAP: 2.5},
+	}
+	live := []stream.Bar{
+		{Symbol: "AAPL", Open: 3, Close: 4, Volume: 7, Timestamp: at(27, 9, 32), TradeCount: 2, VWAP: 3.5},
+		{Symbol: "MSFT", Open: 9, Timestamp: at(27, 9, 32)},
+		// the complete bar of the minute fetched while it was not over
+		{Symbol: "AAPL", Open: 2, Close: 3, Volume: 8, Timestamp: at(27, 9, 31), TradeCount: 3, VWAP: 2.6},
+	}
+
+	merged := Merge("AAPL", historical, live)
+	require.Len(s.T(), merged, 3)
+	assert.Equal(s.T(), FromV2("AAPL", historical[0]), merged[0])
+	assert.Equal(s.T(), live[2], merged[1])
+	assert.Equal(s.T(), live[0], merged[2])
+	assert.Equal(s.T(), uint64(4), merged[0].TradeCount)
+	assert.Equal(s.T(), 1.5, merged[0].VWAP)
+}
+
+func (s *BarsTestSuite) TestSeries() {
+	announcements := []alpaca.Announcement{
+		{CAType: alpaca.CATypeSplit, InitiatingSymbol: "AAPL", TargetSymbol: "AAPL", ExDate: "2020-11-25",
+			OldRate: decimal.New(1, 0), NewRate: decimal.New(4, 0)},
+		{CAType: alpaca.CATypeDividend, InitiatingSymbol: "AAPL", ExDate: "2020-11-24", Cash: decimal.New(4, 0)},
+		{CAType: alpaca.CATypeSplit, InitiatingSymbol: "MSFT", TargetSymbol: "MSFT", ExDate: "2020-11-24",
+			OldRate: decimal.New(1, 0), NewRate: decimal.New(2, 0)},
+	}
+	var adjustments [][]stream.Bar
+	series := NewSeries("AAPL", v2.All, announcements, func(bars []stream.Bar) {
+		adjustments = append(adjustments, bars)
+	})
+
+	series.AddBars(
+		v2.Bar{Open: 400, High: 400, Low: 400, Close: 400, Volume: 100, VWAP: 400, Timestamp: at(23, 9, 30)},
+		v2.Bar{Open: 400, High: 400, Low: 400, Close: 400, Volume: 100, VWAP: 400, Timestamp: at(24, 9, 30)},
+	)
+	// the dividend took effect with the bars of its ex date
+	require.Len(s.T(), adjustments, 1)
+	bars := series.Bars()
+	require.Len(s.T(), bars, 2)
+	assert.InDelta(s.T(), 396, bars[0].Close, 1e-9)
+	assert.Equal(s.T(), uint64(100), bars[0].Volume)
+	assert.InDelta(s.T(), 400, bars[1].Close, 1e-9)
+
+	// the split takes effect at the open of its ex date
+	series.Add(stream.Bar{Symbol: "AAPL", Open: 101, High: 101, Low: 101, Close: 101, Volume: 100, Timestamp: at(25, 9, 30)})
+	series.Add(stream.Bar{Symbol: "MSFT", Close: 1, Timestamp: at(25, 9, 30)})
+	require.Len(s.T(), adjustments, 2)
+	bars = adjustments[1]
+	require.Len(s.T(), bars, 3)
+	assert.InDelta(s.T(), 99, bars[0].Close, 1e-9)
+	assert.InDelta(s.T(), 99, bars[0].VWAP, 1e-9)
+	assert.Equal(s.T(), uint64(400), bars[0].Volume)
+	assert.InDelta(s.T(), 100, bars[1].Open, 1e-9)
+	assert.Equal(s.T(), uint64(400), bars[1].Volume)
+	assert.InDelta(s.T(), 101, bars[2].Close, 1e-9)
+	assert.Equal(s.T(), uint64(100), bars[2].Volume)
+
+	// a later bar of the same day adjusts nothing new
+	series.Add(stream.Bar{Symbol: "AAPL", Close: 102, Volume: 10, Timestamp: at(25, 9, 31)})
+	assert.Len(s.T(), adjustments, 2)
+	assert.Len(s.T(), series.Bars(), 4)
+
+	raw := NewSeries("AAPL", v2.Raw, announcements, nil)
+	raw.AddBars(v2.Bar{Close: 400, Timestamp: at(23, 9, 30)}, v2.Bar{Close: 100, Timestamp: at(25, 9, 30)})
+	assert.Equal(s.T(), 400.0, raw.Bars()[0].Close)
+}
+
+func (s *BarsTestSuite) TestSessionOf() {
+	sessions, err := CalendarSessions([]alpaca.CalendarDay{
+		{Date: "2020-11-25", Open: "09:30", Close: "16:00"},
+		{Date: "2020-11-27", Open: "09:30", Close: "13:00"},
+	})
+	require.NoError(s.T(), err)
+	for _, c := range []struct {
+		t    time.Time
+		want Session
+	}{
+		{at(25, 3, 59), Closed},
+		{at(25, 4, 0), PreMarket},
+		{at(25, 9, 29), PreMarket},
+		{at(25, 9, 30), Regular},
+		{at(25, 15, 59), Regular},
+		{at(25, 16, 0), AfterHours},
+		{at(25, 19, 59), AfterHours},
+		{at(25, 20, 0), Closed},
+		{at(26, 10, 0), Closed},
+		// the extended hours end 4 hours after an early close
+		{at(27, 16, 59), AfterHours},
+		{at(27, 17, 0), Closed},
+	} {
+		assert.Equal(s.T(), c.want, sessions.SessionOf(c.t), c.t)
+	}
+	assert.Equal(s.T(), "after-hours", AfterHours.String())
+
+	bars := []v2.Bar{{Timestamp: at(25, 8, 0)}, {Timestamp: at(25, 10, 0)}, {Timestamp: at(25, 17, 0)}}
+	assert.Equal(s.T(), bars[1:2], sessions.FilterBars(bars, Regular))
+	assert.Equal(s.T(), []v2.Bar{bars[0], bars[2]}, sessions.FilterBars(bars, PreMarket, AfterHours))
+	trades := []v2.Trade{{Timestamp: at(25, 9, 0)}, {Timestamp: at(25, 9, 30)}}
+	assert.Equal(s.T(), trades[1:], sessions.FilterTrades(trades, Regular))
+
+	var handled []stream.Trade
+	handler := sessions.TradeHandler(func(trade stream.Trade) { handled = append(handled, trade) }, Regular)
+	handler(stream.Trade{Symbol: "AAPL", Timestamp: at(25, 9, 0)})
+	handler(stream.Trade{Symbol: "AAPL", Timestamp: at(25, 9, 45)})
+	require.Len(s.T(), handled, 1)
+	assert.Equal(s.T(), at(25, 9, 45), handled[0].Timestamp)
+}
+
+func (s *BarsTestSuite) TestDailyBars() {
+	sessions, err := CalendarSessions([]alpaca.CalendarDay{
+		{Date: "2020-11-25", Open: "09:30", Close: "16:00"},
+		{Date: "2020-11-27", Open: "09:30", Close: "13:00"},
+	})
+	require.NoError(s.T(), err)
+	minute := func(t time.Time, price float64, volume uint64) v2.Bar {
+		return v2.Bar{Open: price, High: price + 1, Low: price - 1, Close: price, Volume: volume,
+			TradeCount: 1, VWAP: price, Timestamp: t}
+	}
+	daily := DailyBars(sessions, []v2.Bar{
+		minute(at(27, 12, 59), 110, 10),
+		minute(at(25, 9, 29), 90, 10), // pre-market
+		minute(at(25, 9, 30), 100, 10),
+		minute(at(25, 15, 59), 104, 30),
+		minute(at(25, 10, 0), 99, 10),
+		minute(at(25, 10, 0), 102, 20), // corrected
+		minute(at(27, 13, 0), 120, 10), // after the early close
+		minute(at(27, 9, 30), 108, 10),
+	})
+
+	require.Len(s.T(), daily, 2)
+	assert.Equal(s.T(), time.Date(2020, 11, 25, 0, 0, 0, 0, newYork), daily[0].Timestamp)
+	assert.Equal(s.T(), 100.0, daily[0].Open)
+	assert.Equal(s.T(), 105.0, daily[0].High)
+	assert.Equal(s.T(), 99.0, daily[0].Low)
+	assert.Equal(s.T(), 104.0, daily[0].Close)
+	assert.Equal(s.T(), uint64(60), daily[0].Volume)
+	assert.Equal(s.T(), uint64(3), daily[0].TradeCount)
+	assert.InDelta(s.T(), (100*10+102*20+104*30)/60.0, daily[0].VWAP, 1e-9)
+
+	assert.Equal(s.T(), time.Date(2020, 11, 27, 0, 0, 0, 0, newYork), daily[1].Timestamp)
+	assert.Equal(s.T(), 108.0, daily[1].Open)
+	assert.Equal(s.T(), 110.0, daily[1].Close)
+	assert.Equal(s.T(), uint64(20), daily[1].Volume)
+}
+
+func (s *BarsTestSuite) TestResample() {
+	minutes := []stream.Bar{
+		minuteBar(at(25, 9, 29), 90),
+		minuteBar(at(25, 9, 30), 100),
+		minuteBar(at(25, 9, 31), 101),
+		minuteBar(at(25, 9, 32), 102),
+		minuteBar(at(25, 9, 33), 103),
+		minuteBar(at(25, 9, 34), 104),
+	}
+	resampled, err := Resample(minutes, time.Minute, 5*time.Minute, ResampleOptions{})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), resampled, 1)
+	assert.Equal(s.T(), at(25, 9, 30), resampled[0].Timestamp)
+	assert.Equal(s.T(), 100.0, resampled[0].Open)
+	assert.Equal(s.T(), 104.0, resampled[0].Close)
+	assert.Equal(s.T(), uint64(50), resampled[0].Volume)
+
+	// aligned to midnight UTC, the pre-market minute counts and the bar of
+	// 14:30 UTC is partial
+	resampled, err = Resample(minutes, time.Minute, time.Hour, ResampleOptions{Anchor: MidnightUTC})
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), resampled)
+	resampled, err = Resample(minutes, time.Minute, time.Hour, ResampleOptions{Anchor: MidnightUTC, KeepPartial: true})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), resampled, 1)
+	assert.Equal(s.T(), time.Date(2020, 11, 25, 14, 0, 0, 0, time.UTC), resampled[0].Timestamp.UTC())
+	assert.Equal(s.T(), 90.0, resampled[0].Open)
+	assert.Equal(s.T(), uint64(60), resampled[0].Volume)
+
+	// the resampled bars are in the location of the bars
+	utc := make([]stream.Bar, len(minutes))
+	for i, bar := range minutes {
+		bar.Timestamp = bar.Timestamp.UTC()
+		utc[i] = bar
+	}
+	resampled, err = Resample(utc, time.Minute, 5*time.Minute, ResampleOptions{})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), resampled, 1)
+	assert.Equal(s.T(), at(25, 9, 30).UTC(), resampled[0].Timestamp)
+
+	_, err = Resample(minutes, time.Minute, 90*time.Second, ResampleOptions{})
+	assert.Error(s.T(), err)
+
+	a, err := NewAggregator(nil, s.handler, time.Hour)
+	require.NoError(s.T(), err)
+	a.UseAnchor(MidnightUTC)
+	for _, m := range minutes {
+		a.Add(m)
+	}
+	a.Add(minuteBar(time.Date(2020, 11, 25, 15, 0, 0, 0, time.UTC), 105))
+	require.Len(s.T(), s.emitted[time.Hour], 1)
+	assert.Equal(s.T(), 90.0, s.emitted[time.Hour][0].Open)
+}
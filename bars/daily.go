@@ -0,0 +1,42 @@
+package bars
+
+import (
+	"sort"
+	"time"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// DailyBars rolls up the 1-minute bars of a symbol, e.g. downloaded with
+// alpaca.Client.GetBars, into the daily bars of the sessions, like the 1Day
+// bars of the API: each covers the regular session, which ends at the early
+// close on half-days, and is stamped at midnight New York time. A minute bar
+// present more than once, e.g. corrected after it was first received, counts
+// once with its last values. The minute bars out of the sessions are
+// ignored, so the bars match the official ones as long as the minute bars
+// are of the same feed.
+func DailyBars(sessions Sessions, minutes []v2.Bar) []v2.Bar {
+	days := map[time.Time]*bucket{}
+	for _, m := range minutes {
+		open, close, ok := sessions(m.Timestamp)
+		if !ok || m.Timestamp.Before(open) || !m.Timestamp.Before(close) {
+			continue
+		}
+		y, mo, d := open.In(newYork).Date()
+		midnight := time.Date(y, mo, d, 0, 0, 0, 0, newYork)
+		b, ok := days[midnight]
+		if !ok {
+			b = &bucket{start: midnight, end: close, minutes: map[time.Time]stream.Bar{}}
+			days[midnight] = b
+		}
+		b.minutes[m.Timestamp] = FromV2("", m)
+	}
+
+	daily := make([]v2.Bar, 0, len(days))
+	for _, b := range days {
+		daily = append(daily, ToV2(b.bar("")))
+	}
+	sort.Slice(daily, func(i, j int) bool { return daily[i].Timestamp.Before(daily[j].Timestamp) })
+	return daily
+}
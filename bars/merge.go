@@ -0,0 +1,69 @@
+package bars
+
+import (
+	"sort"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// FromV2 returns the historical bar of symbol, e.g. returned by
+// alpaca.Client.GetBars, as a stream bar with all its fields.
+func FromV2(symbol string, bar v2.Bar) stream.Bar {
+	return stream.Bar{
+		Symbol:     symbol,
+		Open:       bar.Open,
+		High:       bar.High,
+		Low:        bar.Low,
+		Close:      bar.Close,
+		Volume:     bar.Volume,
+		Timestamp:  bar.Timestamp,
+		TradeCount: bar.TradeCount,
+		VWAP:       bar.VWAP,
+	}
+}
+
+// ToV2 returns the bar as a historical bar, without its symbol
+func ToV2(bar stream.Bar) v2.Bar {
+	return v2.Bar{
+		Open:       bar.Open,
+		High:       bar.High,
+		Low:        bar.Low,
+		Close:      bar.Close,
+		Volume:     bar.Volume,
+		Timestamp:  bar.Timestamp,
+		TradeCount: bar.TradeCount,
+		VWAP:       bar.VWAP,
+	}
+}
+
+// Merge merges the historical bars of symbol with the bars received from the
+// stream into one series sorted by timestamp, e.g. to warm up a strategy with
+// the bars of the day before its stream started. The bars of the same time
+// frame are expected. A stream bar replaces the historical bar of the same
+// timestamp, which may have been fetched before its minute ended.
+func Merge(symbol string, historical []v2.Bar, live []stream.Bar) []stream.Bar {
+	merged := make([]stream.Bar, 0, len(historical)+len(live))
+	index := make(map[int64]int, len(historical)+len(live))
+	add := func(bar stream.Bar) {
+		key := bar.Timestamp.UnixNano()
+		if i, ok := index[key]; ok {
+			merged[i] = bar
+			return
+		}
+		index[key] = len(merged)
+		merged = append(merged, bar)
+	}
+	for _, bar := range historical {
+		add(FromV2(symbol, bar))
+	}
+	for _, bar := range live {
+		if bar.Symbol == symbol {
+			add(bar)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Timestamp.Before(merged[j].Timestamp)
+	})
+	return merged
+}
@@ -0,0 +1,113 @@
+package bars
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// Anchor is the time the bars of a time frame are aligned to
+type Anchor int
+
+const (
+	// SessionOpen aligns the bars to the open of the regular sessions, the
+	// last bar of a session ending at its close, and ignores the bars out of
+	// the sessions. It is the default.
+	SessionOpen Anchor = iota
+	// MidnightUTC aligns the bars to midnight UTC every day, for the markets
+	// trading around the clock such as crypto. Day bars cover the UTC day.
+	MidnightUTC
+)
+
+// window returns the start and end of the bar of timeFrame t belongs to, in
+// the location of t, and false if t is out of the sessions
+func window(anchor Anchor, sessions Sessions, t time.Time, timeFrame time.Duration) (time.Time, time.Time, bool) {
+	var open, close time.Time
+	if anchor == MidnightUTC {
+		y, m, d := t.UTC().Date()
+		open = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		close = open.Add(24 * time.Hour)
+	} else {
+		var ok bool
+		open, close, ok = sessions(t)
+		if !ok || t.Before(open) || !t.Before(close) {
+			return time.Time{}, time.Time{}, false
+		}
+	}
+	if timeFrame >= Day {
+		return open.In(t.Location()), close.In(t.Location()), true
+	}
+	start := open.Add(t.Sub(open) / timeFrame * timeFrame)
+	end := start.Add(timeFrame)
+	if end.After(close) {
+		end = close
+	}
+	return start.In(t.Location()), end.In(t.Location()), true
+}
+
+// ResampleOptions configure Resample
+type ResampleOptions struct {
+	Anchor Anchor
+	// Sessions are the sessions the bars are aligned to with SessionOpen,
+	// RegularSessions by default
+	Sessions Sessions
+	// KeepPartial keeps the last bar of a symbol even if the bars it
+	// resamples do not cover its time frame yet, e.g. the bar of the current
+	// hour. Such a bar is dropped by default.
+	KeepPartial bool
+}
+
+// Resample converts the bars of the time frame from, of one or more
+// symbols, into bars of the time frame to, whole multiples of from or Day,
+// aligned as set by opts. The bars are returned sorted by timestamp and a
+// bar present more than once counts once with its last values, like with
+// Aggregator, which resamples the bars of the stream the same way.
+func Resample(bars []stream.Bar, from, to time.Duration, opts ResampleOptions) ([]stream.Bar, error) {
+	if from <= 0 || to < from || (to < Day && to%from != 0) {
+		return nil, fmt.Errorf("cannot resample %s bars into %s bars", from, to)
+	}
+	if opts.Sessions == nil {
+		opts.Sessions = RegularSessions()
+	}
+
+	type key struct {
+		symbol string
+		start  time.Time
+	}
+	buckets := map[key]*bucket{}
+	// last is when the last bar of each symbol ends
+	last := map[string]time.Time{}
+	for _, bar := range bars {
+		start, end, ok := window(opts.Anchor, opts.Sessions, bar.Timestamp, to)
+		if !ok {
+			continue
+		}
+		k := key{symbol: bar.Symbol, start: start}
+		b, ok := buckets[k]
+		if !ok {
+			b = &bucket{start: start, end: end, minutes: map[time.Time]stream.Bar{}}
+			buckets[k] = b
+		}
+		b.minutes[bar.Timestamp] = bar
+		if e := bar.Timestamp.Add(from); e.After(last[bar.Symbol]) {
+			last[bar.Symbol] = e
+		}
+	}
+
+	resampled := make([]stream.Bar, 0, len(buckets))
+	for k, b := range buckets {
+		if !opts.KeepPartial && last[k.symbol].Before(b.end) {
+			continue
+		}
+		resampled = append(resampled, b.bar(k.symbol))
+	}
+	sort.Slice(resampled, func(i, j int) bool {
+		if resampled[i].Timestamp.Equal(resampled[j].Timestamp) {
+			return resampled[i].Symbol < resampled[j].Symbol
+		}
+		return resampled[i].Timestamp.Before(resampled[j].Timestamp)
+	})
+	return resampled, nil
+}
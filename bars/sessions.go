@@ -0,0 +1,170 @@
+package bars
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+var newYork = func() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}()
+
+// Sessions returns the regular trading session of the day of t,
+// and false when the market is closed that day.
+type Sessions func(t time.Time) (open, close time.Time, ok bool)
+
+// RegularSessions returns the 9:30 to 16:00 New York sessions of every
+// weekday. It knows neither holidays nor early closes, use
+// CalendarSessions for them.
+func RegularSessions() Sessions {
+	return func(t time.Time) (time.Time, time.Time, bool) {
+		t = t.In(newYork)
+		if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+			return time.Time{}, time.Time{}, false
+		}
+		y, m, d := t.Date()
+		return time.Date(y, m, d, 9, 30, 0, 0, newYork), time.Date(y, m, d, 16, 0, 0, 0, newYork), true
+	}
+}
+
+// CalendarSessions returns the sessions of the market calendar, as returned
+// by alpaca.Client.GetCalendar, including its half-days. The market is
+// closed on the days missing from the calendar.
+func CalendarSessions(days []alpaca.CalendarDay) (Sessions, error) {
+	type session struct {
+		open, close time.Time
+	}
+	sessions := make(map[string]session, len(days))
+	for _, day := range days {
+		open, err := time.ParseInLocation("2006-01-02 15:04", day.Date+" "+day.Open, newYork)
+		if err != nil {
+			return nil, fmt.Errorf("invalid calendar day %s: %w", day.Date, err)
+		}
+		close, err := time.ParseInLocation("2006-01-02 15:04", day.Date+" "+day.Close, newYork)
+		if err != nil {
+			return nil, fmt.Errorf("invalid calendar day %s: %w", day.Date, err)
+		}
+		sessions[day.Date] = session{open: open, close: close}
+	}
+
+	return func(t time.Time) (time.Time, time.Time, bool) {
+		s, ok := sessions[t.In(newYork).Format("2006-01-02")]
+		return s.open, s.close, ok
+	}, nil
+}
+
+// Session is the part of the trading day a bar or a trade belongs to
+type Session int
+
+const (
+	// Closed is out of the sessions, e.g. at night or on a holiday
+	Closed Session = iota
+	// PreMarket is from 4:00 to the open
+	PreMarket
+	// Regular is from the open to the close
+	Regular
+	// AfterHours is from the close to 20:00, or 4 hours after an early close
+	AfterHours
+)
+
+// String returns the name of the session
+func (s Session) String() string {
+	switch s {
+	case PreMarket:
+		return "pre-market"
+	case Regular:
+		return "regular"
+	case AfterHours:
+		return "after-hours"
+	default:
+		return "closed"
+	}
+}
+
+// SessionOf returns the session of t. The extended hours are those of the
+// days the market opens.
+func (s Sessions) SessionOf(t time.Time) Session {
+	open, close, ok := s(t)
+	if !ok {
+		return Closed
+	}
+	y, m, d := open.In(newYork).Date()
+	preOpen := time.Date(y, m, d, 4, 0, 0, 0, newYork)
+	afterClose := time.Date(y, m, d, 20, 0, 0, 0, newYork)
+	if early := close.Add(4 * time.Hour); early.Before(afterClose) {
+		afterClose = early
+	}
+	switch {
+	case t.Before(preOpen):
+		return Closed
+	case t.Before(open):
+		return PreMarket
+	case t.Before(close):
+		return Regular
+	case t.Before(afterClose):
+		return AfterHours
+	default:
+		return Closed
+	}
+}
+
+// FilterBars returns the historical bars, e.g. returned by
+// alpaca.Client.GetBars, of the sessions include, e.g. Regular.
+func (s Sessions) FilterBars(bars []v2.Bar, include ...Session) []v2.Bar {
+	return filter(s, bars, func(bar v2.Bar) time.Time { return bar.Timestamp }, include)
+}
+
+// FilterTrades returns the historical trades, e.g. returned by
+// alpaca.Client.GetTrades, of the sessions include.
+func (s Sessions) FilterTrades(trades []v2.Trade, include ...Session) []v2.Trade {
+	return filter(s, trades, func(trade v2.Trade) time.Time { return trade.Timestamp }, include)
+}
+
+// BarHandler returns a bar handler, e.g. for stream.SubscribeBars, passing
+// the bars of the sessions include to handler.
+func (s Sessions) BarHandler(handler func(bar stream.Bar), include ...Session) func(bar stream.Bar) {
+	return func(bar stream.Bar) {
+		if s.in(bar.Timestamp, include) {
+			handler(bar)
+		}
+	}
+}
+
+// TradeHandler returns a trade handler, e.g. for stream.SubscribeTrades,
+// passing the trades of the sessions include to handler.
+func (s Sessions) TradeHandler(handler func(trade stream.Trade), include ...Session) func(trade stream.Trade) {
+	return func(trade stream.Trade) {
+		if s.in(trade.Timestamp, include) {
+			handler(trade)
+		}
+	}
+}
+
+// in returns true if t is in one of the sessions include
+func (s Sessions) in(t time.Time, include []Session) bool {
+	session := s.SessionOf(t)
+	for _, i := range include {
+		if i == session {
+			return true
+		}
+	}
+	return false
+}
+
+func filter[T any](s Sessions, items []T, timestamp func(T) time.Time, include []Session) []T {
+	var filtered []T
+	for _, item := range items {
+		if s.in(timestamp(item), include) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
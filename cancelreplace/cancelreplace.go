@@ -0,0 +1,210 @@
+// Package cancelreplace replaces an order by canceling it, waiting for the
+// cancel to complete and only then placing the replacement, reduced by what
+// the original filled in the meantime, e.g.
+//
+//	replacer := cancelreplace.New(client)
+//	stream.SubscribeTradeUpdates(replacer.HandleTradeUpdate)
+//	result, err := replacer.CancelReplace(ctx, order.ID, req)
+//
+// Placing the replacement right after requesting the cancel doubles the
+// position whenever the original fills before the cancel reaches the
+// exchange.
+package cancelreplace
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+)
+
+// Result is the outcome of a cancel and replace
+type Result struct {
+	// Original is the final state of the order canceled
+	Original *alpaca.Order
+	// RaceFilled is the quantity of the original filled while it was being
+	// canceled, deducted from the replacement
+	RaceFilled decimal.Decimal
+	// Replacement is the order placed, nil if the original filled all of it
+	// or was already filled
+	Replacement *alpaca.Order
+}
+
+// Option configures a Replacer
+type Option func(r *Replacer)
+
+// WithPollInterval sets how often the original order is polled while its
+// cancel is pending, every second by default. The trade updates passed to
+// the replacer end the wait sooner.
+func WithPollInterval(interval time.Duration) Option {
+	return func(r *Replacer) {
+		r.interval = interval
+	}
+}
+
+// WithClock sets the clock the polls are waited on, clock.Real by default.
+func WithClock(c clock.Clock) Option {
+	return func(r *Replacer) {
+		r.clock = c
+	}
+}
+
+// Replacer cancels and replaces orders
+type Replacer struct {
+	client   alpaca.TradingClient
+	interval time.Duration
+	clock    clock.Clock
+
+	mu sync.Mutex
+	// waiting are the channels of the orders waiting for a final state
+	waiting map[string]chan alpaca.Order
+}
+
+// New creates a replacer canceling and placing the orders with client.
+func New(client alpaca.TradingClient, opts ...Option) *Replacer {
+	r := &Replacer{
+		client:   client,
+		interval: time.Second,
+		clock:    clock.Real,
+		waiting:  map[string]chan alpaca.Order{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// HandleTradeUpdate ends the wait for the order reaching a final state. It
+// can be passed to stream.SubscribeTradeUpdates.
+func (r *Replacer) HandleTradeUpdate(update alpaca.TradeUpdate) {
+	if update.Event.IsTerminal() {
+		r.done(update.Order)
+	}
+}
+
+// HandleTradeEvent ends the wait for the order reaching a final state. It
+// can be passed to stream.SubscribeTradeEvents.
+func (r *Replacer) HandleTradeEvent(event stream.TradeUpdateEvent) {
+	if event.GetEvent().IsTerminal() {
+		r.done(event.GetOrder())
+	}
+}
+
+func (r *Replacer) done(order alpaca.Order) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ch, ok := r.waiting[order.ID]; ok {
+		select {
+		case ch <- order:
+		default:
+		}
+	}
+}
+
+// CancelReplace cancels the order orderID, waits until it is canceled or
+// otherwise final, then places req with its quantity, or notional, reduced
+// by what the original filled since the call. Nothing is placed if the
+// original filled all of req, or if it was already filled when called, there
+// being nothing left to replace. An error of the cancel or the wait leaves
+// the original as it is and places nothing.
+func (r *Replacer) CancelReplace(ctx context.Context, orderID string, req alpaca.PlaceOrderRequest) (*Result, error) {
+	before, err := r.client.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	if filled(before) {
+		return &Result{Original: before, RaceFilled: decimal.Zero}, nil
+	}
+	original := before
+	if !before.Status.IsTerminal() {
+		ch := make(chan alpaca.Order, 1)
+		r.mu.Lock()
+		r.waiting[orderID] = ch
+		r.mu.Unlock()
+		defer func() {
+			r.mu.Lock()
+			delete(r.waiting, orderID)
+			r.mu.Unlock()
+		}()
+
+		// an order not cancelable is filled or being filled, the wait tells
+		if err := r.client.CancelOrder(orderID); err != nil && !errors.Is(err, alpaca.ErrOrderNotCancelable) {
+			return nil, err
+		}
+		if original, err = r.wait(ctx, orderID, ch); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{
+		Original:   original,
+		RaceFilled: original.FilledQty.Sub(before.FilledQty),
+	}
+	if result.RaceFilled.IsPositive() {
+		if req.Notional.IsZero() {
+			req.Qty = req.Qty.Sub(result.RaceFilled)
+			if !req.Qty.IsPositive() {
+				return result, nil
+			}
+		} else {
+			filled := result.RaceFilled.Mul(raceFillPrice(before, original))
+			req.Notional = req.Notional.Sub(filled)
+			if !req.Notional.IsPositive() {
+				return result, nil
+			}
+		}
+	}
+	if result.Replacement, err = r.client.PlaceOrder(req); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// wait returns the order once final, from the trade updates or polling
+func (r *Replacer) wait(ctx context.Context, orderID string, ch chan alpaca.Order) (*alpaca.Order, error) {
+	for {
+		timer := r.clock.NewTimer(r.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case order := <-ch:
+			timer.Stop()
+			return &order, nil
+		case <-timer.C():
+		}
+		order, err := r.client.GetOrder(orderID)
+		if err != nil {
+			continue
+		}
+		if order.Status.IsTerminal() {
+			return order, nil
+		}
+	}
+}
+
+// raceFillPrice returns the average price of the quantity of the order
+// filled between before and after
+func raceFillPrice(before, after *alpaca.Order) decimal.Decimal {
+	if after.FilledAvgPrice == nil {
+		return decimal.Zero
+	}
+	notional := after.FilledQty.Mul(*after.FilledAvgPrice)
+	if before.FilledAvgPrice != nil {
+		notional = notional.Sub(before.FilledQty.Mul(*before.FilledAvgPrice))
+	}
+	return notional.Div(after.FilledQty.Sub(before.FilledQty))
+}
+
+// filled returns true if nothing is left to fill of order
+func filled(order *alpaca.Order) bool {
+	if order.Status == alpaca.OrderFilled {
+		return true
+	}
+	return order.Qty.IsPositive() && !order.FilledQty.LessThan(order.Qty)
+}
@@ -0,0 +1,118 @@
+package cancelreplace
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelReplace(t *testing.T) {
+	symbol := "AAPL"
+	req := alpaca.PlaceOrderRequest{AssetKey: &symbol, Qty: decimal.New(10, 0), Side: alpaca.Buy, Type: alpaca.Market}
+	var placed []alpaca.PlaceOrderRequest
+	var r *Replacer
+	mock := &alpacatest.MockClient{
+		GetOrderFunc: func(orderID string) (*alpaca.Order, error) {
+			return &alpaca.Order{ID: orderID, Status: alpaca.OrderNew, FilledQty: decimal.New(2, 0)}, nil
+		},
+		CancelOrderFunc: func(orderID string) error {
+			// the original fills 3 more before the cancel completes
+			r.HandleTradeUpdate(alpaca.TradeUpdate{Event: alpaca.EventCanceled, Order: alpaca.Order{
+				ID: orderID, Status: alpaca.OrderCanceled, FilledQty: decimal.New(5, 0),
+			}})
+			return nil
+		},
+		PlaceOrderFunc: func(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+			placed = append(placed, req)
+			return &alpaca.Order{ID: "replacement"}, nil
+		},
+	}
+	r = New(mock)
+
+	result, err := r.CancelReplace(context.Background(), "original", req)
+	require.NoError(t, err)
+	assert.Equal(t, alpaca.OrderCanceled, result.Original.Status)
+	assert.Equal(t, "3", result.RaceFilled.String())
+	require.NotNil(t, result.Replacement)
+	require.Len(t, placed, 1)
+	assert.Equal(t, "7", placed[0].Qty.String())
+	assert.Equal(t, []string{"GetOrder", "CancelOrder", "PlaceOrder"}, mock.Calls)
+}
+
+func TestCancelReplaceFilled(t *testing.T) {
+	fake := clock.NewFake(time.Date(2021, 10, 14, 13, 30, 0, 0, time.UTC))
+	avg := decimal.New(100, 0)
+	polls := 0
+	mock := &alpacatest.MockClient{
+		GetOrderFunc: func(orderID string) (*alpaca.Order, error) {
+			polls++
+			if polls < 3 {
+				return &alpaca.Order{ID: orderID, Status: alpaca.OrderPendingCancel}, nil
+			}
+			return &alpaca.Order{ID: orderID, Status: alpaca.OrderFilled, FilledQty: decimal.New(10, 0), FilledAvgPrice: &avg}, nil
+		},
+		CancelOrderFunc: func(orderID string) error {
+			return alpaca.ErrOrderNotCancelable
+		},
+	}
+	r := New(mock, WithClock(fake), WithPollInterval(time.Second))
+
+	symbol := "AAPL"
+	done := make(chan *Result)
+	go func() {
+		result, err := r.CancelReplace(context.Background(), "original", alpaca.PlaceOrderRequest{
+			AssetKey: &symbol, Notional: decimal.New(1000, 0), Side: alpaca.Buy, Type: alpaca.Market,
+		})
+		assert.NoError(t, err)
+		done <- result
+	}()
+	// polled until the original is final
+	fake.BlockUntil(1)
+	fake.Advance(time.Second)
+	fake.BlockUntil(1)
+	fake.Advance(time.Second)
+
+	result := <-done
+	assert.Equal(t, alpaca.OrderFilled, result.Original.Status)
+	assert.Equal(t, "10", result.RaceFilled.String())
+	// the original filled all of the replacement
+	assert.Nil(t, result.Replacement)
+	assert.Equal(t, []string{"GetOrder", "CancelOrder", "GetOrder", "GetOrder"}, mock.Calls)
+
+	// a canceled wait places nothing
+	polls = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := r.CancelReplace(ctx, "original", alpaca.PlaceOrderRequest{AssetKey: &symbol, Qty: decimal.New(1, 0)})
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestCancelReplaceAlreadyFilled(t *testing.T) {
+	symbol := "AAPL"
+	for _, original := range []alpaca.Order{
+		{ID: "original", Status: alpaca.OrderFilled, Qty: decimal.New(10, 0), FilledQty: decimal.New(10, 0)},
+		// the fill is not reflected by the status yet
+		{ID: "original", Status: alpaca.OrderPartiallyFilled, Qty: decimal.New(10, 0), FilledQty: decimal.New(10, 0)},
+	} {
+		original := original
+		mock := &alpacatest.MockClient{
+			GetOrderFunc: func(orderID string) (*alpaca.Order, error) {
+				return &original, nil
+			},
+		}
+		result, err := New(mock).CancelReplace(context.Background(), "original", alpaca.PlaceOrderRequest{
+			AssetKey: &symbol, Qty: decimal.New(10, 0), Side: alpaca.Buy, Type: alpaca.Market,
+		})
+		require.NoError(t, err)
+		assert.Same(t, &original, result.Original)
+		assert.Nil(t, result.Replacement)
+		assert.Equal(t, []string{"GetOrder"}, mock.Calls)
+	}
+}
@@ -0,0 +1,240 @@
+// Package clock abstracts the time functions the SDK waits on, so that the
+// backoffs, timeouts and schedules depending on them can be tested
+// deterministically with a Fake clock instead of real sleeps, e.g.
+//
+//	c := clock.NewFake(time.Date(2021, 3, 2, 9, 30, 0, 0, time.UTC))
+//	q := orderqueue.New(client, orderqueue.WithClock(c))
+//	...
+//	c.BlockUntil(1) // the queue waits for a token
+//	c.Advance(time.Second)
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells the time and creates timers
+type Clock interface {
+	Now() time.Time
+	// Sleep pauses the current goroutine for at least d
+	Sleep(d time.Duration)
+	// After waits for d and then sends the current time on the channel
+	// returned
+	After(d time.Duration) <-chan time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is a time.Timer of a Clock
+type Timer interface {
+	// C is the channel of the timer, see time.Timer.C
+	C() <-chan time.Time
+	// Stop stops the timer, see time.Timer.Stop
+	Stop() bool
+	// Reset changes the timer to expire after d, see time.Timer.Reset
+	Reset(d time.Duration) bool
+}
+
+// Ticker is a time.Ticker of a Clock
+type Ticker interface {
+	// C is the channel of the ticker, see time.Ticker.C
+	C() <-chan time.Time
+	// Stop turns off the ticker, see time.Ticker.Stop
+	Stop()
+}
+
+type realClock struct{}
+
+// Real is the clock of the time package
+var Real Clock = realClock{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) NewTimer(d time.Duration) Timer         { return realTimer{time.NewTimer(d)} }
+func (realClock) NewTicker(d time.Duration) Ticker       { return realTicker{time.NewTicker(d)} }
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// Fake is a clock whose time only moves when it is advanced, firing the
+// timers and tickers due, in order. Like the ones of the time package, their
+// channels hold a single value and drop the ticks not received.
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+var _ Clock = (*Fake)(nil)
+
+// NewFake creates a fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the time of the clock.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Sleep blocks until the clock is advanced by d.
+func (f *Fake) Sleep(d time.Duration) {
+	<-f.After(d)
+}
+
+// After returns a channel receiving the time once the clock is advanced by
+// d.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+// NewTimer creates a timer firing once the clock is advanced by d.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{fake: f, c: make(chan time.Time, 1)}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schedule(t, d)
+	return t
+}
+
+// NewTicker creates a ticker firing every time the clock is advanced by d.
+// It panics if d is not positive, as time.NewTicker.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	t := &fakeTimer{fake: f, c: make(chan time.Time, 1), period: d}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schedule(t, d)
+	return fakeTicker{t}
+}
+
+// Advance moves the clock forward by d, firing the timers due on the way.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.set(f.now.Add(d))
+	f.mu.Unlock()
+}
+
+// Set moves the clock to t, firing the timers due on the way. The clock
+// never moves backwards.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	if t.After(f.now) {
+		f.set(t)
+	}
+	f.mu.Unlock()
+}
+
+// Waiters returns the number of timers, tickers and sleeps waiting on the
+// clock.
+func (f *Fake) Waiters() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil blocks until at least n timers, tickers and sleeps wait on the
+// clock, so that a test advances the clock only once the code under test
+// waits on it.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+// set fires the timers due until t, in order, with f.mu held
+func (f *Fake) set(t time.Time) {
+	for len(f.waiters) > 0 && !f.waiters[0].when.After(t) {
+		w := f.waiters[0]
+		f.waiters = f.waiters[1:]
+		f.now = w.when
+		select {
+		case w.c <- w.when:
+		default:
+		}
+		if w.period > 0 {
+			f.schedule(w, w.period)
+		}
+	}
+	f.now = t
+}
+
+// schedule adds a timer firing in d, with f.mu held
+func (f *Fake) schedule(t *fakeTimer, d time.Duration) {
+	t.when = f.now.Add(d)
+	i := sort.Search(len(f.waiters), func(i int) bool {
+		return f.waiters[i].when.After(t.when)
+	})
+	f.waiters = append(f.waiters, nil)
+	copy(f.waiters[i+1:], f.waiters[i:])
+	f.waiters[i] = t
+	f.cond.Broadcast()
+}
+
+// remove removes a timer, and returns false if it was not waiting, with
+// f.mu held
+func (f *Fake) remove(t *fakeTimer) bool {
+	for i, w := range f.waiters {
+		if w == t {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeTimer is a timer or, with a period, a ticker of a Fake clock
+type fakeTimer struct {
+	fake   *Fake
+	c      chan time.Time
+	when   time.Time
+	period time.Duration
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+	return t.fake.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.fake.mu.Lock()
+	defer t.fake.mu.Unlock()
+	active := t.fake.remove(t)
+	t.fake.schedule(t, d)
+	return active
+}
+
+// fakeTicker is a ticker of a Fake clock
+type fakeTicker struct {
+	*fakeTimer
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeTimer.Stop()
+}
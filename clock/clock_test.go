@@ -0,0 +1,112 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var epoch = time.Date(2021, 3, 2, 9, 30, 0, 0, time.UTC)
+
+func received(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFakeTimers(t *testing.T) {
+	f := NewFake(epoch)
+	assert.Equal(t, epoch, f.Now())
+
+	short := f.NewTimer(time.Second)
+	long := f.NewTimer(time.Minute)
+	stopped := f.NewTimer(2 * time.Second)
+	assert.Equal(t, 3, f.Waiters())
+	assert.True(t, stopped.Stop())
+	assert.False(t, stopped.Stop())
+
+	f.Advance(5 * time.Second)
+	assert.Equal(t, epoch.Add(5*time.Second), f.Now())
+	at, ok := received(short.C())
+	require.True(t, ok)
+	assert.Equal(t, epoch.Add(time.Second), at)
+	_, ok = received(long.C())
+	assert.False(t, ok)
+	_, ok = received(stopped.C())
+	assert.False(t, ok)
+
+	// reset from now
+	assert.True(t, long.Reset(time.Second))
+	assert.False(t, short.Reset(time.Second))
+	f.Advance(time.Second)
+	_, ok = received(long.C())
+	assert.True(t, ok)
+	_, ok = received(short.C())
+	assert.True(t, ok)
+	assert.Equal(t, 0, f.Waiters())
+
+	// never backwards
+	f.Set(epoch)
+	assert.Equal(t, epoch.Add(6*time.Second), f.Now())
+}
+
+func TestFakeTicker(t *testing.T) {
+	f := NewFake(epoch)
+	ticker := f.NewTicker(time.Second)
+
+	f.Advance(time.Second)
+	at, ok := received(ticker.C())
+	require.True(t, ok)
+	assert.Equal(t, epoch.Add(time.Second), at)
+
+	// the ticks not received are dropped
+	f.Advance(3 * time.Second)
+	at, ok = received(ticker.C())
+	require.True(t, ok)
+	assert.Equal(t, epoch.Add(2*time.Second), at)
+	_, ok = received(ticker.C())
+	assert.False(t, ok)
+
+	ticker.Stop()
+	f.Advance(time.Second)
+	_, ok = received(ticker.C())
+	assert.False(t, ok)
+	assert.Panics(t, func() { f.NewTicker(0) })
+}
+
+func TestFakeSleep(t *testing.T) {
+	f := NewFake(epoch)
+	done := make(chan time.Time)
+	go func() {
+		f.Sleep(time.Minute)
+		done <- f.Now()
+	}()
+
+	f.BlockUntil(1)
+	f.Advance(30 * time.Second)
+	select {
+	case <-done:
+		assert.Fail(t, "woke up early")
+	case <-time.After(10 * time.Millisecond):
+	}
+	f.Advance(30 * time.Second)
+	assert.Equal(t, epoch.Add(time.Minute), <-done)
+}
+
+func TestReal(t *testing.T) {
+	start := Real.Now()
+	timer := Real.NewTimer(time.Millisecond)
+	<-timer.C()
+	assert.False(t, timer.Stop())
+	ticker := Real.NewTicker(time.Millisecond)
+	<-ticker.C()
+	ticker.Stop()
+	<-Real.After(time.Millisecond)
+	Real.Sleep(time.Millisecond)
+	assert.True(t, Real.Now().Sub(start) >= 4*time.Millisecond)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/recorder"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// historical are the flags of the bars and quotes commands
+type historical struct {
+	fs     *flag.FlagSet
+	start  *string
+	end    *string
+	limit  *int
+	output *string
+}
+
+func historicalFlags(name string, stderr io.Writer) *historical {
+	fs := flags(name, "SYMBOL...", stderr)
+	return &historical{
+		fs:     fs,
+		start:  fs.String("start", "", "start, as a date (2021-03-02) or RFC3339 time"),
+		end:    fs.String("end", "", "end, as a date or RFC3339 time (default now)"),
+		limit:  fs.Int("limit", 0, "maximum number per symbol (default no limit)"),
+		output: fs.String("o", "", "output file (default stdout)"),
+	}
+}
+
+// parse parses the arguments, returning the symbols and time range
+func (h *historical) parse(args []string) (symbols []string, start, end time.Time, err error) {
+	if err = h.fs.Parse(args); err != nil {
+		return
+	}
+	if h.fs.NArg() == 0 {
+		err = usageError(h.fs, "expected symbols")
+		return
+	}
+	if *h.start == "" {
+		err = usageError(h.fs, "-start is required")
+		return
+	}
+	if start, err = parseTime(*h.start); err != nil {
+		return
+	}
+	end = time.Now()
+	if *h.end != "" {
+		if end, err = parseTime(*h.end); err != nil {
+			return
+		}
+	}
+	return h.fs.Args(), start, end, nil
+}
+
+// create opens the output, to be closed by the returned func
+func (h *historical) create(stdout io.Writer) (io.Writer, func() error, error) {
+	if *h.output == "" {
+		return stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(*h.output)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, f.Close, nil
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, newYork)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected a date or RFC3339 time", s)
+	}
+	return t, nil
+}
+
+var newYork = func() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}()
+
+func bars(client alpaca.TradingClient, args []string, stdout, stderr io.Writer) (err error) {
+	h := historicalFlags("bars", stderr)
+	timeFrame := h.fs.String("timeframe", string(v2.Day), "time frame: 1Min, 1Hour or 1Day")
+	adjustment := h.fs.String("adjustment", string(v2.Raw), "adjustment: raw, split, dividend or all")
+	symbols, start, end, err := h.parse(args)
+	if err != nil {
+		return err
+	}
+	out, closeOut, err := h.create(stdout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := closeOut(); err == nil {
+			err = cerr
+		}
+	}()
+
+	w := csv.NewWriter(out)
+	if err := w.Write(recorder.BarsHeader); err != nil {
+		return err
+	}
+	for _, symbol := range symbols {
+		items := client.GetBars(symbol, v2.TimeFrame(*timeFrame), v2.Adjustment(*adjustment), start, end, *h.limit)
+		for item := range items {
+			if item.Error != nil {
+				return fmt.Errorf("%s: %w", symbol, item.Error)
+			}
+			b := item.Bar
+			if err := w.Write([]string{
+				symbol,
+				formatTime(b.Timestamp),
+				formatFloat(b.Open),
+				formatFloat(b.High),
+				formatFloat(b.Low),
+				formatFloat(b.Close),
+				strconv.FormatUint(b.Volume, 10),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func quotes(client alpaca.TradingClient, args []string, stdout, stderr io.Writer) (err error) {
+	h := historicalFlags("quotes", stderr)
+	symbols, start, end, err := h.parse(args)
+	if err != nil {
+		return err
+	}
+	out, closeOut, err := h.create(stdout)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := closeOut(); err == nil {
+			err = cerr
+		}
+	}()
+
+	w := csv.NewWriter(out)
+	if err := w.Write(recorder.QuotesHeader); err != nil {
+		return err
+	}
+	for _, symbol := range symbols {
+		for item := range client.GetQuotes(symbol, start, end, *h.limit) {
+			if item.Error != nil {
+				return fmt.Errorf("%s: %w", symbol, item.Error)
+			}
+			q := item.Quote
+			if err := w.Write([]string{
+				symbol,
+				formatTime(q.Timestamp),
+				string(q.BidExchange),
+				formatFloat(q.BidPrice),
+				strconv.FormatUint(uint64(q.BidSize), 10),
+				string(q.AskExchange),
+				formatFloat(q.AskPrice),
+				strconv.FormatUint(uint64(q.AskSize), 10),
+				strings.Join(q.Conditions, recorder.ConditionsSeparator),
+				string(q.Tape),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// ndjson writes the messages of the stream as JSON lines of the form
+// {"type":"trade","data":{...}}
+type ndjson struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+func newNDJSON(w io.Writer) *ndjson {
+	return &ndjson{enc: json.NewEncoder(w)}
+}
+
+func (n *ndjson) write(typ string, data interface{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.err != nil {
+		return
+	}
+	n.err = n.enc.Encode(struct {
+		Type string      `json:"type"`
+		Data interface{} `json:"data"`
+	}{typ, data})
+}
+
+// error returns the first error writing the messages
+func (n *ndjson) error() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.err
+}
+
+func (n *ndjson) trade(t stream.Trade)             { n.write("trade", t) }
+func (n *ndjson) quote(q stream.Quote)             { n.write("quote", q) }
+func (n *ndjson) bar(b stream.Bar)                 { n.write("bar", b) }
+func (n *ndjson) tradeUpdate(u alpaca.TradeUpdate) { n.write("trade_update", u) }
+
+func splitSymbols(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func tail(args []string, stdout, stderr io.Writer) error {
+	fs := flags("stream", "", stderr)
+	feed := fs.String("feed", "", "data feed: iex or sip (default iex)")
+	tradeSymbols := fs.String("trades", "", "comma separated symbols of the trades, * for all")
+	quoteSymbols := fs.String("quotes", "", "comma separated symbols of the quotes, * for all")
+	barSymbols := fs.String("bars", "", "comma separated symbols of the bars, * for all")
+	tradeUpdates := fs.Bool("trade-updates", false, "stream the updates of the account's orders")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *tradeSymbols == "" && *quoteSymbols == "" && *barSymbols == "" && !*tradeUpdates {
+		return usageError(fs, "expected -trades, -quotes, -bars or -trade-updates")
+	}
+
+	if *feed != "" {
+		if err := stream.UseFeed(*feed); err != nil {
+			return err
+		}
+	}
+	out := newNDJSON(stdout)
+	if symbols := splitSymbols(*tradeSymbols); len(symbols) > 0 {
+		if err := stream.SubscribeTrades(out.trade, symbols...); err != nil {
+			return err
+		}
+	}
+	if symbols := splitSymbols(*quoteSymbols); len(symbols) > 0 {
+		if err := stream.SubscribeQuotes(out.quote, symbols...); err != nil {
+			return err
+		}
+	}
+	if symbols := splitSymbols(*barSymbols); len(symbols) > 0 {
+		if err := stream.SubscribeBars(out.bar, symbols...); err != nil {
+			return err
+		}
+	}
+	if *tradeUpdates {
+		if err := stream.SubscribeTradeUpdates(out.tradeUpdate); err != nil {
+			return err
+		}
+	}
+
+	// until interrupted
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	<-interrupt
+	if err := stream.Close(); err != nil {
+		return err
+	}
+	return out.error()
+}
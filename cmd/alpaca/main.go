@@ -0,0 +1,99 @@
+// Command alpaca is a command line client of the Alpaca API.
+//
+//	alpaca account
+//	alpaca orders [-status open|closed|all] [-limit n]
+//	alpaca order -side buy|sell [-type market] [-tif day] [-limit-price p] [-stop-price p] SYMBOL QTY
+//	alpaca cancel ORDER_ID... | -all
+//	alpaca bars [-timeframe 1Day] [-adjustment raw] -start date [-end date] [-o file] SYMBOL...
+//	alpaca quotes -start date [-end date] [-o file] SYMBOL...
+//	alpaca stream [-feed iex|sip] [-trades AAPL,MSFT] [-quotes ...] [-bars ...] [-trade-updates]
+//
+// The credentials and endpoints are read from the same APCA_API_* environment
+// variables as the SDK. Bars and quotes are written as CSV, with the layout
+// of the recorder package, and the stream is written as NDJSON.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+)
+
+const usage = `usage: alpaca <command> [flags] [args]
+
+commands:
+  account   show the account
+  orders    list the orders
+  order     place an order
+  cancel    cancel orders
+  bars      write the bars of symbols as CSV
+  quotes    write the quotes of symbols as CSV
+  stream    write the live stream as NDJSON
+
+Run alpaca <command> -h for the flags of a command.
+`
+
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	client := alpaca.NewClientWithOptions()
+	if err := run(client, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if errors.Is(err, errUsage) || errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "alpaca:", err)
+		os.Exit(1)
+	}
+}
+
+func run(client alpaca.TradingClient, args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return errUsage
+	}
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "account":
+		return account(client, args, stdout, stderr)
+	case "orders":
+		return listOrders(client, args, stdout, stderr)
+	case "order":
+		return placeOrder(client, args, stdout, stderr)
+	case "cancel":
+		return cancelOrders(client, args, stdout, stderr)
+	case "bars":
+		return bars(client, args, stdout, stderr)
+	case "quotes":
+		return quotes(client, args, stdout, stderr)
+	case "stream":
+		return tail(args, stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return nil
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", cmd, usage)
+		return errUsage
+	}
+}
+
+// flags creates the flag set of a command, whose usage lists args after the flags
+func flags(name, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "usage: alpaca %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// usageError prints the usage of fs
+func usageError(fs *flag.FlagSet, msg string) error {
+	fmt.Fprintln(fs.Output(), msg)
+	fs.Usage()
+	return errUsage
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runCLI(client alpaca.TradingClient, args ...string) (string, string, error) {
+	var stdout, stderr bytes.Buffer
+	err := run(client, args, &stdout, &stderr)
+	return stdout.String(), stderr.String(), err
+}
+
+func TestUsage(t *testing.T) {
+	_, stderr, err := runCLI(&alpacatest.MockClient{})
+	assert.True(t, errors.Is(err, errUsage))
+	assert.Contains(t, stderr, "usage: alpaca <command>")
+
+	_, stderr, err = runCLI(&alpacatest.MockClient{}, "unknown")
+	assert.True(t, errors.Is(err, errUsage))
+	assert.Contains(t, stderr, `unknown command "unknown"`)
+
+	_, _, err = runCLI(&alpacatest.MockClient{}, "order", "-side", "hold", "AAPL", "1")
+	assert.True(t, errors.Is(err, errUsage))
+}
+
+func TestAccount(t *testing.T) {
+	mock := &alpacatest.MockClient{
+		GetAccountFunc: func() (*alpaca.Account, error) {
+			return &alpaca.Account{ID: "some_id", Cash: decimal.New(1000, 0)}, nil
+		},
+	}
+	stdout, _, err := runCLI(mock, "account")
+	require.NoError(t, err)
+	assert.Contains(t, stdout, `"id": "some_id"`)
+	assert.Contains(t, stdout, `"cash": "1000"`)
+}
+
+func TestOrders(t *testing.T) {
+	price := decimal.New(100, 0)
+	mock := &alpacatest.MockClient{
+		ListOrdersFunc: func(status *string, until *time.Time, limit *int, nested *bool) ([]alpaca.Order, error) {
+			assert.Equal(t, "all", *status)
+			assert.Equal(t, 10, *limit)
+			return []alpaca.Order{{ID: "order_id", Symbol: "AAPL", Side: alpaca.Buy, Type: alpaca.Limit,
+				Qty: decimal.New(5, 0), LimitPrice: &price, Status: alpaca.OrderNew}}, nil
+		},
+		PlaceOrderFunc: func(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+			assert.Equal(t, "AAPL", *req.AssetKey)
+			assert.True(t, req.Qty.Equal(decimal.New(5, 0)))
+			assert.Equal(t, alpaca.Buy, req.Side)
+			assert.Equal(t, alpaca.Limit, req.Type)
+			assert.Equal(t, alpaca.GTC, req.TimeInForce)
+			assert.True(t, req.LimitPrice.Equal(price))
+			assert.Nil(t, req.StopPrice)
+			return &alpaca.Order{ID: "order_id"}, nil
+		},
+		CancelOrderFunc: func(orderID string) error {
+			if orderID == "unknown" {
+				return alpaca.ErrOrderNotCancelable
+			}
+			return nil
+		},
+		CancelAllOrdersFunc: func() error {
+			return nil
+		},
+	}
+
+	stdout, _, err := runCLI(mock, "orders", "-status", "all", "-limit", "10")
+	require.NoError(t, err)
+	assert.Regexp(t, `ID\s+SYMBOL\s+SIDE`, stdout)
+	assert.Regexp(t, `order_id\s+AAPL\s+buy\s+limit\s+5\s+0\s+100\s+-\s+new`, stdout)
+
+	stdout, _, err = runCLI(mock, "order", "-side", "buy", "-type", "limit", "-tif", "gtc",
+		"-limit-price", "100", "AAPL", "5")
+	require.NoError(t, err)
+	assert.Contains(t, stdout, `"id": "order_id"`)
+
+	stdout, _, err = runCLI(mock, "cancel", "order_id", "unknown")
+	assert.True(t, errors.Is(err, alpaca.ErrOrderNotCancelable))
+	assert.Equal(t, "canceled order_id\n", stdout)
+
+	_, _, err = runCLI(mock, "cancel", "-all")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ListOrders", "PlaceOrder", "CancelOrder", "CancelOrder", "CancelAllOrders"}, mock.Calls)
+}
+
+func TestBarsAndQuotes(t *testing.T) {
+	ts := time.Date(2021, 3, 2, 14, 30, 0, 0, time.UTC)
+	mock := &alpacatest.MockClient{
+		GetBarsFunc: func(symbol string, timeFrame v2.TimeFrame, adjustment v2.Adjustment,
+			start, end time.Time, limit int) <-chan v2.BarItem {
+			assert.Equal(t, v2.Hour, timeFrame)
+			assert.Equal(t, v2.Split, adjustment)
+			assert.Equal(t, time.Date(2021, 3, 2, 0, 0, 0, 0, newYork), start)
+			assert.Equal(t, ts, end)
+			ch := make(chan v2.BarItem, 1)
+			ch <- v2.BarItem{Bar: v2.Bar{Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 1000, Timestamp: ts}}
+			close(ch)
+			return ch
+		},
+		GetQuotesFunc: func(symbol string, start, end time.Time, limit int) <-chan v2.QuoteItem {
+			ch := make(chan v2.QuoteItem, 1)
+			if symbol == "FAIL" {
+				ch <- v2.QuoteItem{Error: errors.New("failed")}
+			} else {
+				ch <- v2.QuoteItem{Quote: v2.Quote{BidPrice: 120.2, BidSize: 1, AskPrice: 120.3, AskSize: 2,
+					Timestamp: ts, Conditions: []string{"R"}, Tape: "C"}}
+			}
+			close(ch)
+			return ch
+		},
+	}
+
+	stdout, _, err := runCLI(mock, "bars", "-timeframe", "1Hour", "-adjustment", "split",
+		"-start", "2021-03-02", "-end", "2021-03-02T14:30:00Z", "AAPL", "MSFT")
+	require.NoError(t, err)
+	assert.Equal(t, "symbol,timestamp,open,high,low,close,volume\n"+
+		"AAPL,2021-03-02T14:30:00Z,1,2,0.5,1.5,1000\n"+
+		"MSFT,2021-03-02T14:30:00Z,1,2,0.5,1.5,1000\n", stdout)
+
+	_, _, err = runCLI(mock, "bars", "AAPL")
+	assert.True(t, errors.Is(err, errUsage))
+
+	// the CSV files can be replayed
+	path := filepath.Join(t.TempDir(), "quotes.csv")
+	stdout, _, err = runCLI(mock, "quotes", "-start", "2021-03-02", "-o", path, "AAPL")
+	require.NoError(t, err)
+	assert.Empty(t, stdout)
+	c := stream.NewFileReplayClient(path)
+	c.SetSpeed(0)
+	var replayed []stream.Quote
+	require.NoError(t, c.SubscribeQuotes(func(q stream.Quote) { replayed = append(replayed, q) }, "*"))
+	require.NoError(t, c.Connect(context.Background()))
+	require.NoError(t, <-c.Terminated())
+	require.Len(t, replayed, 1)
+	assert.Equal(t, stream.Quote{Symbol: "AAPL", BidPrice: 120.2, BidSize: 1, AskPrice: 120.3, AskSize: 2,
+		Timestamp: ts, Conditions: []string{"R"}, Tape: "C"}, replayed[0])
+
+	_, _, err = runCLI(mock, "quotes", "-start", "2021-03-02", "AAPL", "FAIL")
+	assert.EqualError(t, err, "FAIL: failed")
+}
+
+func TestNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	out := newNDJSON(&buf)
+	out.trade(stream.Trade{Symbol: "AAPL", Price: 120.25})
+	out.tradeUpdate(alpaca.TradeUpdate{Event: alpaca.EventFill, Order: alpaca.Order{ID: "order_id"}})
+	require.NoError(t, out.error())
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	assert.Contains(t, string(lines[0]), `{"type":"trade","data":{"ID":0,"Symbol":"AAPL","Exchange":"","Price":120.25`)
+	assert.Contains(t, string(lines[1]), `{"type":"trade_update","data":{"event":"fill","order":{"id":"order_id"`)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/shopspring/decimal"
+)
+
+func account(client alpaca.TradingClient, args []string, stdout, stderr io.Writer) error {
+	fs := flags("account", "", stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	acct, err := client.GetAccount()
+	if err != nil {
+		return err
+	}
+	return writeJSON(stdout, acct)
+}
+
+func listOrders(client alpaca.TradingClient, args []string, stdout, stderr io.Writer) error {
+	fs := flags("orders", "", stderr)
+	status := fs.String("status", "open", "status of the orders: open, closed or all")
+	limit := fs.Int("limit", 50, "maximum number of orders")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	orders, err := client.ListOrders(status, nil, limit, nil)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSYMBOL\tSIDE\tTYPE\tQTY\tFILLED\tLIMIT\tSTOP\tSTATUS\tSUBMITTED")
+	for _, o := range orders {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			o.ID, o.Symbol, o.Side, o.Type, o.Qty, o.FilledQty,
+			optional(o.LimitPrice), optional(o.StopPrice), o.Status,
+			o.SubmittedAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func optional(d *decimal.Decimal) string {
+	if d == nil {
+		return "-"
+	}
+	return d.String()
+}
+
+func placeOrder(client alpaca.TradingClient, args []string, stdout, stderr io.Writer) error {
+	fs := flags("order", "SYMBOL QTY", stderr)
+	side := fs.String("side", "", "buy or sell")
+	typ := fs.String("type", string(alpaca.Market), "market, limit, stop, stop_limit or trailing_stop")
+	tif := fs.String("tif", string(alpaca.Day), "time in force: day, gtc, opg, cls, ioc or fok")
+	limitPrice := fs.String("limit-price", "", "limit price")
+	stopPrice := fs.String("stop-price", "", "stop price")
+	trailPercent := fs.String("trail-percent", "", "trail percent of a trailing stop")
+	extendedHours := fs.Bool("extended-hours", false, "allow the order to fill in the extended hours")
+	clientOrderID := fs.String("client-order-id", "", "client order ID")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return usageError(fs, "expected a symbol and a quantity")
+	}
+	if *side != string(alpaca.Buy) && *side != string(alpaca.Sell) {
+		return usageError(fs, "-side must be buy or sell")
+	}
+	symbol := fs.Arg(0)
+	qty, err := decimal.NewFromString(fs.Arg(1))
+	if err != nil {
+		return fmt.Errorf("invalid quantity: %w", err)
+	}
+
+	req := alpaca.PlaceOrderRequest{
+		AssetKey:      &symbol,
+		Qty:           qty,
+		Side:          alpaca.Side(*side),
+		Type:          alpaca.OrderType(*typ),
+		TimeInForce:   alpaca.TimeInForce(*tif),
+		ExtendedHours: *extendedHours,
+		ClientOrderID: *clientOrderID,
+	}
+	if req.LimitPrice, err = parsePrice("limit price", *limitPrice); err != nil {
+		return err
+	}
+	if req.StopPrice, err = parsePrice("stop price", *stopPrice); err != nil {
+		return err
+	}
+	if req.TrailPercent, err = parsePrice("trail percent", *trailPercent); err != nil {
+		return err
+	}
+
+	order, err := client.PlaceOrder(req)
+	if err != nil {
+		return err
+	}
+	return writeJSON(stdout, order)
+}
+
+func parsePrice(name, s string) (*decimal.Decimal, error) {
+	if s == "" {
+		return nil, nil
+	}
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return &d, nil
+}
+
+func cancelOrders(client alpaca.TradingClient, args []string, stdout, stderr io.Writer) error {
+	fs := flags("cancel", "ORDER_ID...", stderr)
+	all := fs.Bool("all", false, "cancel all the open orders")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *all {
+		if fs.NArg() > 0 {
+			return usageError(fs, "-all takes no order IDs")
+		}
+		return client.CancelAllOrders()
+	}
+	if fs.NArg() == 0 {
+		return usageError(fs, "expected order IDs or -all")
+	}
+	for _, id := range fs.Args() {
+		if err := client.CancelOrder(id); err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		fmt.Fprintln(stdout, "canceled", id)
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
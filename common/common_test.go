@@ -1,10 +1,19 @@
 package common
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -28,3 +37,117 @@ func (s *CommonTestSuite) TestCredentials() {
 	assert.Equal(s.T(), "KEY_ID", Credentials().ID)
 	assert.Equal(s.T(), "SECRET_KEY", Credentials().Secret)
 }
+
+func (s *CommonTestSuite) TestProfiles() {
+	dir, err := ioutil.TempDir("", "alpaca")
+	require.NoError(s.T(), err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "credentials")
+	require.NoError(s.T(), ioutil.WriteFile(path, []byte(`
+# comment
+[default]
+key_id = DEFAULT_ID
+secret_key = DEFAULT_SECRET
+
+[paper]
+key_id = PAPER_ID
+secret_key = PAPER_SECRET
+polygon_key_id = POLY_ID
+`), 0600))
+	os.Setenv(EnvConfigFile, path)
+	defer os.Unsetenv(EnvConfigFile)
+
+	key, err := Profile("paper")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), &APIKey{ID: "PAPER_ID", Secret: "PAPER_SECRET", PolygonKeyID: "POLY_ID"}, key)
+
+	_, err = Profile("live")
+	assert.Error(s.T(), err)
+
+	// the environment variables take precedence
+	assert.Equal(s.T(), "KEY_ID", Credentials().ID)
+
+	os.Unsetenv(EnvApiKeyID)
+	os.Unsetenv(EnvApiSecretKey)
+	defer setEnv()
+
+	assert.Equal(s.T(), "DEFAULT_ID", Credentials().ID)
+	assert.Equal(s.T(), "DEFAULT_ID", Credentials().PolygonKeyID)
+
+	os.Setenv(EnvProfile, "paper")
+	defer os.Unsetenv(EnvProfile)
+	assert.Equal(s.T(), "PAPER_SECRET", Credentials().Secret)
+
+	require.NoError(s.T(), ioutil.WriteFile(path, []byte("key_id = X\n"), 0600))
+	_, err = Profile("paper")
+	assert.Error(s.T(), err)
+}
+
+func (s *CommonTestSuite) TestProviders() {
+	key, err := StaticProvider{Key: APIKey{ID: "STATIC"}}.Get(context.Background())
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "STATIC", key.ID)
+
+	key, err = EnvProvider{}.Get(context.Background())
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "KEY_ID", key.ID)
+
+	os.Unsetenv(EnvApiKeyID)
+	os.Unsetenv(EnvApiSecretKey)
+	os.Setenv(EnvConfigFile, "/nonexistent")
+	defer os.Unsetenv(EnvConfigFile)
+	defer setEnv()
+	_, err = EnvProvider{}.Get(context.Background())
+	assert.Error(s.T(), err)
+}
+
+func (s *CommonTestSuite) TestOAuth() {
+	now := time.Date(2021, 3, 4, 15, 0, 0, 0, time.UTC)
+	refreshes := 0
+	src := NewRefreshingTokenSource(TokenSourceFunc(func(ctx context.Context) (Token, error) {
+		refreshes++
+		if refreshes > 2 {
+			return Token{}, errors.New("refresh token revoked")
+		}
+		return Token{
+			AccessToken: fmt.Sprintf("token%d", refreshes),
+			Expiry:      now.Add(time.Hour),
+		}, nil
+	}), time.Minute)
+	src.(*refreshingTokenSource).now = func() time.Time { return now }
+	provider := OAuthProvider{Source: src}
+
+	key, err := provider.Get(context.Background())
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), APIKey{OAuth: "token1"}, key)
+
+	// the token is reused until it is about to expire
+	now = now.Add(58 * time.Minute)
+	key, err = provider.Get(context.Background())
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "token1", key.OAuth)
+
+	now = now.Add(time.Minute)
+	key, err = provider.Get(context.Background())
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "token2", key.OAuth)
+	assert.Equal(s.T(), 2, refreshes)
+
+	now = now.Add(2 * time.Hour)
+	_, err = provider.Get(context.Background())
+	assert.Error(s.T(), err)
+}
+
+func (s *CommonTestSuite) TestStdLogger() {
+	var buf bytes.Buffer
+	logger := NewStdLogger(log.New(&buf, "", 0), LevelInfo)
+
+	logger.Debug("ignored")
+	logger.Warn("stream reconnect failed", "stream", "data", "attempt", 2, "error", errors.New("unexpected EOF"))
+	logger.Info("odd", "dangling")
+	assert.Equal(s.T(),
+		"level=WARN msg=\"stream reconnect failed\" stream=data attempt=2 error=\"unexpected EOF\"\n"+
+			"level=INFO msg=odd !BADKEY=dangling\n",
+		buf.String())
+}
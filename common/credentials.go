@@ -26,6 +26,10 @@ type APIKey struct {
 
 // Credentials returns the user's Alpaca API key ID
 // and secret for use through the SDK.
+//
+// The environment variables take precedence. When none of the key variables
+// is set, the credentials are read from the profile of the credentials file
+// named by APCA_PROFILE, or the default profile (see ConfigFile).
 func Credentials() *APIKey {
 	var polygonKeyID string
 	if s := os.Getenv(EnvPolygonKeyID); s != "" {
@@ -33,10 +37,25 @@ func Credentials() *APIKey {
 	} else {
 		polygonKeyID = os.Getenv(EnvApiKeyID)
 	}
-	return &APIKey{
+	key := &APIKey{
 		ID:           os.Getenv(EnvApiKeyID),
 		PolygonKeyID: polygonKeyID,
 		Secret:       os.Getenv(EnvApiSecretKey),
 		OAuth:        os.Getenv(EnvApiOAuth),
 	}
+	if key.ID != "" || key.Secret != "" || key.OAuth != "" {
+		return key
+	}
+
+	name := os.Getenv(EnvProfile)
+	if name == "" {
+		name = DefaultProfile
+	}
+	if profile, err := Profile(name); err == nil {
+		if polygonKeyID != "" {
+			profile.PolygonKeyID = polygonKeyID
+		}
+		return profile
+	}
+	return key
 }
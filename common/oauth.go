@@ -0,0 +1,88 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Token is an OAuth access token
+type Token struct {
+	AccessToken string
+	// Expiry is the time the token expires, zero if it does not
+	Expiry time.Time
+}
+
+// valid returns true if the token is set and does not expire within margin
+func (t Token) valid(now time.Time, margin time.Duration) bool {
+	if t.AccessToken == "" {
+		return false
+	}
+	return t.Expiry.IsZero() || now.Add(margin).Before(t.Expiry)
+}
+
+// TokenSource returns OAuth access tokens, e.g. by running the refresh token
+// flow of an OAuth app
+type TokenSource interface {
+	Token(ctx context.Context) (Token, error)
+}
+
+// TokenSourceFunc adapts a function to a TokenSource
+type TokenSourceFunc func(ctx context.Context) (Token, error)
+
+// Token calls f(ctx)
+func (f TokenSourceFunc) Token(ctx context.Context) (Token, error) {
+	return f(ctx)
+}
+
+// refreshingTokenSource caches the token of a TokenSource
+type refreshingTokenSource struct {
+	src    TokenSource
+	margin time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	token Token
+}
+
+// NewRefreshingTokenSource returns a TokenSource that caches the tokens of
+// src and gets a new one from src when the cached one expires within margin.
+func NewRefreshingTokenSource(src TokenSource, margin time.Duration) TokenSource {
+	return &refreshingTokenSource{src: src, margin: margin, now: time.Now}
+}
+
+func (s *refreshingTokenSource) Token(ctx context.Context) (Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.valid(s.now(), s.margin) {
+		return s.token, nil
+	}
+	token, err := s.src.Token(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+	if token.AccessToken == "" {
+		return Token{}, errors.New("token source returned an empty access token")
+	}
+	s.token = token
+	return token, nil
+}
+
+// OAuthProvider is a CredentialsProvider authenticating with the OAuth
+// tokens of Source. Combined with NewRefreshingTokenSource, every client
+// accepting a CredentialsProvider uses a fresh token for each request or
+// connection.
+type OAuthProvider struct {
+	Source TokenSource
+}
+
+// Get returns the current token of the source as OAuth credentials
+func (p OAuthProvider) Get(ctx context.Context) (APIKey, error) {
+	token, err := p.Source.Token(ctx)
+	if err != nil {
+		return APIKey{}, err
+	}
+	return APIKey{OAuth: token.AccessToken}, nil
+}
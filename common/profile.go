@@ -0,0 +1,106 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// EnvConfigFile overrides the path of the credentials file
+	EnvConfigFile = "APCA_CONFIG_FILE"
+	// EnvProfile selects the profile Credentials falls back to
+	EnvProfile = "APCA_PROFILE"
+	// DefaultProfile is the profile used when APCA_PROFILE is not set
+	DefaultProfile = "default"
+)
+
+// ConfigFile returns the path of the credentials file, APCA_CONFIG_FILE
+// or ~/.alpaca/credentials.
+//
+// The file holds named profiles in INI format:
+//
+//	[paper]
+//	key_id = PK...
+//	secret_key = ...
+//
+//	[live]
+//	key_id = AK...
+//	secret_key = ...
+//
+// The keys of a profile are key_id, secret_key, oauth and polygon_key_id.
+func ConfigFile() string {
+	if s := os.Getenv(EnvConfigFile); s != "" {
+		return s
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".alpaca", "credentials")
+}
+
+// Profile returns the credentials of the named profile of the credentials
+// file, e.g. common.Profile("paper").
+func Profile(name string) (*APIKey, error) {
+	path := ConfigFile()
+	if path == "" {
+		return nil, fmt.Errorf("no credentials file")
+	}
+	profiles, err := readProfiles(path)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %s not found in %s", name, path)
+	}
+
+	key := &APIKey{
+		ID:           values["key_id"],
+		Secret:       values["secret_key"],
+		OAuth:        values["oauth"],
+		PolygonKeyID: values["polygon_key_id"],
+	}
+	if key.PolygonKeyID == "" {
+		key.PolygonKeyID = key.ID
+	}
+	return key, nil
+}
+
+// readProfiles parses the INI formatted credentials file at path
+func readProfiles(path string) (map[string]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profiles := map[string]map[string]string{}
+	var current map[string]string
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "#"), strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if profiles[name] == nil {
+				profiles[name] = map[string]string{}
+			}
+			current = profiles[name]
+		default:
+			i := strings.Index(line, "=")
+			if i < 0 || current == nil {
+				return nil, fmt.Errorf("%s:%d: invalid line", path, n)
+			}
+			current[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"context"
+	"errors"
+)
+
+// CredentialsProvider returns the credentials to authenticate with.
+// Clients call Get for every request or connection, so a provider backed by
+// a secret store can rotate the keys without restarting the clients.
+type CredentialsProvider interface {
+	Get(ctx context.Context) (APIKey, error)
+}
+
+// CredentialsProviderFunc adapts a function to a CredentialsProvider
+type CredentialsProviderFunc func(ctx context.Context) (APIKey, error)
+
+// Get calls f(ctx)
+func (f CredentialsProviderFunc) Get(ctx context.Context) (APIKey, error) {
+	return f(ctx)
+}
+
+// StaticProvider always returns the same credentials
+type StaticProvider struct {
+	Key APIKey
+}
+
+// Get returns the static credentials
+func (p StaticProvider) Get(ctx context.Context) (APIKey, error) {
+	return p.Key, nil
+}
+
+// EnvProvider returns the credentials of the environment variables or of the
+// credentials file, read again on every call (see Credentials).
+type EnvProvider struct{}
+
+// Get returns Credentials(), or an error if no credentials are configured
+func (EnvProvider) Get(ctx context.Context) (APIKey, error) {
+	key := Credentials()
+	if key.ID == "" && key.Secret == "" && key.OAuth == "" {
+		return APIKey{}, errors.New("no credentials in the environment or the credentials file")
+	}
+	return *key, nil
+}
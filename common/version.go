@@ -0,0 +1,19 @@
+package common
+
+import "strings"
+
+// Version is the version of the SDK
+const Version = "1.9.0"
+
+// UserAgent returns the User-Agent header the clients send with their
+// requests and websocket handshakes: the application, if set, followed by
+// the SDK and its version, e.g.
+//
+//	my-bot/2.1 alpaca-trade-api-go/1.9.0
+func UserAgent(application string) string {
+	sdk := "alpaca-trade-api-go/" + Version
+	if application = strings.TrimSpace(application); application != "" {
+		return application + " " + sdk
+	}
+	return sdk
+}
@@ -0,0 +1,241 @@
+// Package datasource abstracts where the bars of a strategy come from, so it
+// can be written once against DataSource and run unchanged in backtest
+// (Historical or Recorded), paper and live (Live) modes, e.g.
+//
+//	func run(ctx context.Context, src datasource.DataSource) error {
+//		history, err := src.Bars(ctx, "AAPL", datasource.Range{Start: start, End: end})
+//		...
+//		return src.SubscribeBars(ctx, onBar, "AAPL")
+//	}
+//
+//	run(ctx, datasource.NewLive(client, datasource.DefaultStream))
+//	run(ctx, datasource.NewRecorded("recordings/2021-03-02"))
+package datasource
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// ErrTimeFrame is returned for a time frame the data source does not have
+var ErrTimeFrame = errors.New("time frame not available")
+
+// Range delimits historical bars
+type Range struct {
+	// Start is included and End excluded
+	Start, End time.Time
+	// TimeFrame of the bars, v2.Min by default
+	TimeFrame v2.TimeFrame
+	// Adjustment of the bars, v2.Raw by default
+	Adjustment v2.Adjustment
+}
+
+func (r Range) withDefaults() Range {
+	if r.TimeFrame == "" {
+		r.TimeFrame = v2.Min
+	}
+	if r.Adjustment == "" {
+		r.Adjustment = v2.Raw
+	}
+	return r
+}
+
+// DataSource provides the historical and real time bars of symbols
+type DataSource interface {
+	// Bars returns the bars of symbol in r, in time order.
+	Bars(ctx context.Context, symbol string, r Range) ([]stream.Bar, error)
+	// SubscribeBars sends the minute bars of symbols to handler, one at a
+	// time, until ctx is done or the source has no more bars. It returns nil
+	// in the latter case.
+	SubscribeBars(ctx context.Context, handler func(bar stream.Bar), symbols ...string) error
+}
+
+// BarsClient fetches historical bars. *alpaca.Client implements it.
+type BarsClient interface {
+	GetBars(
+		symbol string, timeFrame v2.TimeFrame, adjustment v2.Adjustment,
+		start, end time.Time, limit int,
+	) <-chan v2.BarItem
+}
+
+// rest fetches the historical bars with the REST API
+type rest struct {
+	client BarsClient
+}
+
+// Bars returns the bars of symbol in r, fetched with the REST API.
+func (s rest) Bars(ctx context.Context, symbol string, r Range) ([]stream.Bar, error) {
+	r = r.withDefaults()
+	items := s.client.GetBars(symbol, r.TimeFrame, r.Adjustment, r.Start, r.End, math.MaxInt32)
+	var bars []stream.Bar
+	for {
+		select {
+		case <-ctx.Done():
+			go drain(items)
+			return nil, ctx.Err()
+		case item, ok := <-items:
+			if !ok {
+				return bars, nil
+			}
+			if item.Error != nil {
+				go drain(items)
+				return nil, item.Error
+			}
+			if !item.Bar.Timestamp.Before(r.End) {
+				continue
+			}
+			bars = append(bars, stream.Bar{
+				Symbol:     symbol,
+				Open:       item.Bar.Open,
+				High:       item.Bar.High,
+				Low:        item.Bar.Low,
+				Close:      item.Bar.Close,
+				Volume:     item.Bar.Volume,
+				Timestamp:  item.Bar.Timestamp,
+				TradeCount: item.Bar.TradeCount,
+				VWAP:       item.Bar.VWAP,
+			})
+		}
+	}
+}
+
+// drain unblocks the producer of items no longer read
+func drain(items <-chan v2.BarItem) {
+	for range items {
+	}
+}
+
+// Historical is the data source of a backtest on the REST API, replaying the
+// minute bars of a time range as fast as they are handled
+type Historical struct {
+	rest
+	replay Range
+}
+
+var _ DataSource = (*Historical)(nil)
+
+// NewHistorical creates a data source fetching the bars with client, whose
+// SubscribeBars replays the bars of replay, merged in time order.
+func NewHistorical(client BarsClient, replay Range) *Historical {
+	return &Historical{rest: rest{client: client}, replay: replay}
+}
+
+// SubscribeBars replays the bars of symbols in the replay range to handler.
+func (s *Historical) SubscribeBars(ctx context.Context, handler func(bar stream.Bar), symbols ...string) error {
+	var bars []stream.Bar
+	for _, symbol := range symbols {
+		b, err := s.Bars(ctx, symbol, s.replay)
+		if err != nil {
+			return err
+		}
+		bars = append(bars, b...)
+	}
+	sort.SliceStable(bars, func(i, j int) bool {
+		return bars[i].Timestamp.Before(bars[j].Timestamp)
+	})
+	for _, bar := range bars {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		handler(bar)
+	}
+	return nil
+}
+
+// Subscriber subscribes to the real time bars
+type Subscriber interface {
+	SubscribeBars(handler func(bar stream.Bar), symbols ...string) error
+	UnsubscribeBars(symbols ...string) error
+}
+
+type defaultStream struct{}
+
+func (defaultStream) SubscribeBars(handler func(bar stream.Bar), symbols ...string) error {
+	return stream.SubscribeBars(handler, symbols...)
+}
+
+func (defaultStream) UnsubscribeBars(symbols ...string) error {
+	return stream.UnsubscribeBars(symbols...)
+}
+
+// DefaultStream subscribes with the functions of the v2/stream package
+var DefaultStream Subscriber = defaultStream{}
+
+// Live is the data source of paper and live trading, with the historical
+// bars of the REST API and the real time bars of the data stream
+type Live struct {
+	rest
+	stream Subscriber
+}
+
+var _ DataSource = (*Live)(nil)
+
+// NewLive creates a data source fetching the bars with client and
+// subscribing to them with stream, e.g. DefaultStream.
+func NewLive(client BarsClient, stream Subscriber) *Live {
+	return &Live{rest: rest{client: client}, stream: stream}
+}
+
+// SubscribeBars subscribes handler to the bars of symbols until ctx is done,
+// and returns the error of ctx.
+func (s *Live) SubscribeBars(ctx context.Context, handler func(bar stream.Bar), symbols ...string) error {
+	if err := s.stream.SubscribeBars(handler, symbols...); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	if err := s.stream.UnsubscribeBars(symbols...); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// Recorded is the data source of the bars recorded to files by the recorder
+// package, replayed as fast as they are handled. It only has minute bars.
+type Recorded struct {
+	paths []string
+}
+
+var _ DataSource = (*Recorded)(nil)
+
+// NewRecorded creates a data source of the recorded files or directories at
+// paths, as stream.NewFileReplayClient.
+func NewRecorded(paths ...string) *Recorded {
+	return &Recorded{paths: paths}
+}
+
+// Bars returns the recorded bars of symbol in r, ErrTimeFrame unless r is
+// of minute bars.
+func (s *Recorded) Bars(ctx context.Context, symbol string, r Range) ([]stream.Bar, error) {
+	if r = r.withDefaults(); r.TimeFrame != v2.Min {
+		return nil, ErrTimeFrame
+	}
+	var bars []stream.Bar
+	err := s.SubscribeBars(ctx, func(bar stream.Bar) {
+		if !bar.Timestamp.Before(r.Start) && bar.Timestamp.Before(r.End) {
+			bars = append(bars, bar)
+		}
+	}, symbol)
+	if err != nil {
+		return nil, err
+	}
+	return bars, nil
+}
+
+// SubscribeBars replays the recorded bars of symbols to handler.
+func (s *Recorded) SubscribeBars(ctx context.Context, handler func(bar stream.Bar), symbols ...string) error {
+	c := stream.NewFileReplayClient(s.paths...)
+	c.SetSpeed(0)
+	if err := c.SubscribeBars(handler, symbols...); err != nil {
+		return err
+	}
+	if err := c.Connect(ctx); err != nil {
+		return err
+	}
+	return <-c.Terminated()
+}
@@ -0,0 +1,181 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/recorder"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type fakeClient struct {
+	bars  map[string][]v2.Bar
+	err   error
+	calls []v2.TimeFrame
+}
+
+func (c *fakeClient) GetBars(
+	symbol string, timeFrame v2.TimeFrame, adjustment v2.Adjustment,
+	start, end time.Time, limit int,
+) <-chan v2.BarItem {
+	c.calls = append(c.calls, timeFrame)
+	ch := make(chan v2.BarItem)
+	go func() {
+		defer close(ch)
+		for _, bar := range c.bars[symbol] {
+			if !bar.Timestamp.Before(start) && !bar.Timestamp.After(end) {
+				ch <- v2.BarItem{Bar: bar}
+			}
+		}
+		if c.err != nil {
+			ch <- v2.BarItem{Error: c.err}
+		}
+	}()
+	return ch
+}
+
+type fakeStream struct {
+	mu       sync.Mutex
+	handlers map[string]func(bar stream.Bar)
+}
+
+func (s *fakeStream) SubscribeBars(handler func(bar stream.Bar), symbols ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, symbol := range symbols {
+		s.handlers[symbol] = handler
+	}
+	return nil
+}
+
+func (s *fakeStream) UnsubscribeBars(symbols ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, symbol := range symbols {
+		delete(s.handlers, symbol)
+	}
+	return nil
+}
+
+type DataSourceTestSuite struct {
+	suite.Suite
+	client *fakeClient
+}
+
+func TestDataSourceTestSuite(t *testing.T) {
+	suite.Run(t, new(DataSourceTestSuite))
+}
+
+var start = time.Date(2021, 3, 2, 14, 30, 0, 0, time.UTC)
+
+func minute(i int) time.Time {
+	return start.Add(time.Duration(i) * time.Minute)
+}
+
+func (s *DataSourceTestSuite) SetupTest() {
+	s.client = &fakeClient{bars: map[string][]v2.Bar{
+		"AAPL": {{Close: 1, Timestamp: minute(0)}, {Close: 2, Timestamp: minute(1)}, {Close: 3, Timestamp: minute(2)}},
+		"MSFT": {{Close: 10, Timestamp: minute(1)}},
+	}}
+}
+
+func closes(bars []stream.Bar) []float64 {
+	var c []float64
+	for _, bar := range bars {
+		c = append(c, bar.Close)
+	}
+	return c
+}
+
+func (s *DataSourceTestSuite) TestHistorical() {
+	src := NewHistorical(s.client, Range{Start: minute(0), End: minute(2)})
+
+	bars, err := src.Bars(context.Background(), "AAPL", Range{Start: minute(1), End: minute(3)})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []float64{2, 3}, closes(bars))
+	assert.Equal(s.T(), "AAPL", bars[0].Symbol)
+	assert.Equal(s.T(), v2.Min, s.client.calls[0])
+
+	// merged in time order, the end excluded
+	var replayed []stream.Bar
+	err = src.SubscribeBars(context.Background(), func(bar stream.Bar) {
+		replayed = append(replayed, bar)
+	}, "MSFT", "AAPL")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []float64{1, 10, 2}, closes(replayed))
+}
+
+func (s *DataSourceTestSuite) TestErrors() {
+	s.client.err = errors.New("rate limited")
+	src := NewHistorical(s.client, Range{Start: minute(0), End: minute(3)})
+	_, err := src.Bars(context.Background(), "AAPL", Range{Start: minute(0), End: minute(3), TimeFrame: v2.Hour})
+	assert.EqualError(s.T(), err, "rate limited")
+	assert.Equal(s.T(), v2.Hour, s.client.calls[0])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = src.Bars(ctx, "AAPL", Range{Start: minute(0), End: minute(3)})
+	assert.Equal(s.T(), context.Canceled, err)
+}
+
+func (s *DataSourceTestSuite) TestLive() {
+	fake := &fakeStream{handlers: map[string]func(bar stream.Bar){}}
+	src := NewLive(s.client, fake)
+
+	bars, err := src.Bars(context.Background(), "MSFT", Range{Start: minute(0), End: minute(3)})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []float64{10}, closes(bars))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	received := make(chan stream.Bar, 1)
+	errs := make(chan error)
+	go func() {
+		errs <- src.SubscribeBars(ctx, func(bar stream.Bar) { received <- bar }, "AAPL")
+	}()
+	assert.Eventually(s.T(), func() bool {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		return fake.handlers["AAPL"] != nil
+	}, time.Second, time.Millisecond)
+	fake.mu.Lock()
+	fake.handlers["AAPL"](stream.Bar{Symbol: "AAPL", Close: 4})
+	fake.mu.Unlock()
+	assert.Equal(s.T(), 4.0, (<-received).Close)
+
+	cancel()
+	assert.Equal(s.T(), context.Canceled, <-errs)
+	assert.Empty(s.T(), fake.handlers)
+}
+
+func (s *DataSourceTestSuite) TestRecorded() {
+	dir := s.T().TempDir()
+	r := recorder.New(dir)
+	for _, symbol := range []string{"AAPL", "MSFT"} {
+		for _, bar := range s.client.bars[symbol] {
+			r.RecordBar(stream.Bar{Symbol: symbol, Close: bar.Close, Timestamp: bar.Timestamp})
+		}
+	}
+	require.NoError(s.T(), r.Close())
+	src := NewRecorded(dir)
+
+	bars, err := src.Bars(context.Background(), "AAPL", Range{Start: minute(1), End: minute(3)})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []float64{2, 3}, closes(bars))
+	assert.True(s.T(), minute(1).Equal(bars[0].Timestamp))
+	_, err = src.Bars(context.Background(), "AAPL", Range{TimeFrame: v2.Day})
+	assert.Equal(s.T(), ErrTimeFrame, err)
+
+	var replayed []stream.Bar
+	err = src.SubscribeBars(context.Background(), func(bar stream.Bar) {
+		replayed = append(replayed, bar)
+	}, "AAPL", "MSFT")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []float64{1, 2, 10, 3}, closes(replayed))
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// windowSize is the number of minute bars the momentum is measured over
+	windowSize = 10
+	// bookSize is the number of symbols held long and held short at most
+	bookSize = 2
+	// positionPct is the share of the equity put in each position
+	positionPct = 0.05
+	// takeProfitPct and stopLossPct set the exits of the brackets
+	takeProfitPct = 0.02
+	stopLossPct   = 0.01
+)
+
+var symbols = []string{"AAPL", "MSFT", "AMZN", "GOOGL", "META", "TSLA", "NVDA", "AMD", "NFLX", "JPM", "BAC", "XOM"}
+
+var closeOnExit = flag.Bool("close", false, "close the positions on exit")
+
+type barClose struct {
+	timestamp time.Time
+	close     float64
+}
+
+// book is a small long/short book: it enters the symbols with the strongest
+// and weakest momentum with bracket orders, and leaves the exits to their
+// take profit and stop loss legs.
+type book struct {
+	client *alpaca.Client
+
+	mu        sync.Mutex
+	bars      map[string][]barClose
+	positions map[string]decimal.Decimal
+	// pending are the open orders by symbol, which must fill or be canceled
+	// before the symbol is traded again
+	pending   map[string]string
+	shortable map[string]bool
+	// stale are the symbols that missed bars, e.g. while the stream
+	// reconnected, and whose window is fetched again
+	stale map[string]bool
+	// paused is set while the feed is not ok, and resync once it recovered
+	paused bool
+	resync bool
+}
+
+func main() {
+	flag.Parse()
+
+	// You can set your credentials here in the code, or (preferably) via the
+	// APCA_API_KEY_ID and APCA_API_SECRET_KEY environment variables
+	apiKey := "YOUR_API_KEY_HERE"
+	apiSecret := "YOUR_API_SECRET_HERE"
+	if common.Credentials().ID == "" {
+		os.Setenv(common.EnvApiKeyID, apiKey)
+	}
+	if common.Credentials().Secret == "" {
+		os.Setenv(common.EnvApiSecretKey, apiSecret)
+	}
+	alpaca.SetBaseUrl("https://paper-api.alpaca.markets")
+
+	// Stop on Ctrl-C or SIGTERM, e.g. from docker stop, and clean up before
+	// exiting instead of leaving orders working on the account.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	b := &book{
+		client:    alpaca.NewClient(common.Credentials()),
+		bars:      map[string][]barClose{},
+		positions: map[string]decimal.Decimal{},
+		pending:   map[string]string{},
+		shortable: map[string]bool{},
+		stale:     map[string]bool{},
+	}
+	if err := b.load(); err != nil {
+		log.Fatal(err)
+	}
+
+	// The streams reconnect by themselves. The trade events missed meanwhile
+	// are replayed, but the bars are not: handleBar spots the gaps, and the
+	// feed status tells an outage of the feed from one of the connection.
+	stream.SetFeedStatusHandler(b.handleFeedStatus)
+	if err := stream.SubscribeTradeEvents(b.handleTradeEvent); err != nil {
+		log.Fatal(err)
+	}
+	if err := stream.SubscribeBars(b.handleBar, symbols...); err != nil {
+		log.Fatal(err)
+	}
+	// Don't trade before the server confirmed the subscriptions.
+	waitCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	_, err := stream.WaitForSubscriptions(waitCtx, stream.SubscriptionResult{Bars: symbols})
+	cancel()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("subscribed to the bars of", symbols)
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			b.shutdown()
+			return
+		case <-ticker.C:
+			b.trade()
+		}
+	}
+}
+
+// load fetches the state of the account and the windows of the symbols.
+func (b *book) load() error {
+	for _, symbol := range symbols {
+		asset, err := b.client.GetAsset(symbol)
+		if err != nil {
+			return err
+		}
+		b.shortable[symbol] = asset.Shortable && asset.EasyToBorrow
+		b.stale[symbol] = true
+	}
+	if err := b.reconcile(); err != nil {
+		return err
+	}
+	return b.backfill()
+}
+
+// reconcile replaces the positions and the pending orders by those of the
+// account, after a start or an outage.
+func (b *book) reconcile() error {
+	positions, err := b.client.ListPositions()
+	if err != nil {
+		return err
+	}
+	status := "open"
+	orders, err := b.client.ListOrders(&status, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.positions = map[string]decimal.Decimal{}
+	for _, position := range positions {
+		b.positions[position.Symbol] = position.Qty
+	}
+	b.pending = map[string]string{}
+	for _, order := range orders {
+		b.pending[order.Symbol] = order.ID
+	}
+	b.resync = false
+	return nil
+}
+
+// backfill fetches the windows of the stale symbols again, keeping the bars
+// streamed in the meantime.
+func (b *book) backfill() error {
+	b.mu.Lock()
+	var stale []string
+	for symbol := range b.stale {
+		stale = append(stale, symbol)
+	}
+	b.mu.Unlock()
+	if len(stale) == 0 {
+		return nil
+	}
+
+	end := time.Now()
+	bars, err := b.client.GetMultiBars(stale, v2.Min, v2.Raw, end.Add(-2*windowSize*time.Minute), end)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, symbol := range stale {
+		var window []barClose
+		for _, bar := range bars[symbol] {
+			window = append(window, barClose{bar.Timestamp, bar.Close})
+		}
+		for _, bar := range b.bars[symbol] {
+			if len(window) == 0 || bar.timestamp.After(window[len(window)-1].timestamp) {
+				window = append(window, bar)
+			}
+		}
+		b.bars[symbol] = trim(window)
+		delete(b.stale, symbol)
+	}
+	return nil
+}
+
+// handleBar adds the minute bars of the stream to the windows.
+func (b *book) handleBar(bar stream.Bar) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	window := b.bars[bar.Symbol]
+	if n := len(window); n > 0 {
+		if gap := bar.Timestamp.Sub(window[n-1].timestamp); gap > time.Minute {
+			log.Printf("%s missed the bars of %v, fetching them again", bar.Symbol, gap-time.Minute)
+			b.stale[bar.Symbol] = true
+		}
+	}
+	b.bars[bar.Symbol] = trim(append(window, barClose{bar.Timestamp, bar.Close}))
+}
+
+// handleFeedStatus pauses the trading while the feed is degraded or down.
+func (b *book) handleFeedStatus(status stream.FeedStatus) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	log.Printf("feed %s: %s", status.Status, status.Message)
+	wasPaused := b.paused
+	b.paused = status.Status != stream.FeedOK
+	if wasPaused && !b.paused {
+		b.resync = true
+		for _, symbol := range symbols {
+			b.stale[symbol] = true
+		}
+	}
+}
+
+// handleTradeEvent follows the fills and the cancels of the orders, those of
+// the legs of the brackets included.
+func (b *book) handleTradeEvent(event stream.TradeUpdateEvent) {
+	order := event.GetOrder()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch e := event.(type) {
+	case stream.FillEvent:
+		log.Printf("%s %s filled %s @ %s, position %s", order.Symbol, order.Side, e.Qty, e.Price, e.PositionQty)
+		b.positions[order.Symbol] = e.PositionQty
+		if !e.IsPartial() && b.pending[order.Symbol] == order.ID {
+			delete(b.pending, order.Symbol)
+		}
+	case stream.CancelEvent:
+		log.Printf("%s order %s %s", order.Symbol, order.ID, e.Event)
+		if b.pending[order.Symbol] == order.ID {
+			delete(b.pending, order.Symbol)
+		}
+	}
+}
+
+// trade enters the symbols ranked in the book that are flat.
+func (b *book) trade() {
+	b.mu.Lock()
+	paused, resync := b.paused, b.resync
+	b.mu.Unlock()
+	if paused {
+		log.Println("feed not ok, not trading")
+		return
+	}
+	if resync {
+		if err := b.reconcile(); err != nil {
+			log.Println("reconcile:", err)
+			return
+		}
+	}
+	if err := b.backfill(); err != nil {
+		log.Println("backfill:", err)
+		return
+	}
+
+	clock, err := b.client.GetClock()
+	if err != nil {
+		log.Println("clock:", err)
+		return
+	}
+	// Leave the last 15 minutes to the exits of the brackets.
+	if !clock.IsOpen || clock.NextClose.Sub(clock.Timestamp) < 15*time.Minute {
+		return
+	}
+	account, err := b.client.GetAccount()
+	if err != nil {
+		log.Println("account:", err)
+		return
+	}
+	equity, _ := account.Equity.Float64()
+
+	longs, shorts := b.rank()
+	for _, symbol := range longs {
+		b.enter(symbol, alpaca.Buy, equity)
+	}
+	for _, symbol := range shorts {
+		b.enter(symbol, alpaca.Sell, equity)
+	}
+}
+
+// rank returns the symbols with the strongest rising momentum and the
+// shortable ones with the strongest falling momentum, with full windows.
+func (b *book) rank() (longs, shorts []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	type ranked struct {
+		symbol   string
+		momentum float64
+	}
+	var ranks []ranked
+	for _, symbol := range symbols {
+		window := b.bars[symbol]
+		if len(window) < windowSize {
+			continue
+		}
+		first, last := window[0].close, window[len(window)-1].close
+		ranks = append(ranks, ranked{symbol, (last - first) / first})
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		return ranks[i].momentum > ranks[j].momentum
+	})
+	for i := 0; i < len(ranks) && len(longs) < bookSize && ranks[i].momentum > 0; i++ {
+		longs = append(longs, ranks[i].symbol)
+	}
+	for i := len(ranks) - 1; i >= 0 && len(shorts) < bookSize && ranks[i].momentum < 0; i-- {
+		if b.shortable[ranks[i].symbol] {
+			shorts = append(shorts, ranks[i].symbol)
+		}
+	}
+	return longs, shorts
+}
+
+// enter opens a position in symbol with a bracket order, unless the symbol
+// is held or has an order pending.
+func (b *book) enter(symbol string, side alpaca.Side, equity float64) {
+	b.mu.Lock()
+	_, pending := b.pending[symbol]
+	flat := b.positions[symbol].IsZero()
+	window := b.bars[symbol]
+	b.mu.Unlock()
+	if pending || !flat {
+		return
+	}
+
+	price := window[len(window)-1].close
+	qty := int64(equity * positionPct / price)
+	if qty < 1 {
+		return
+	}
+	takeProfit, stopLoss := price*(1+takeProfitPct), price*(1-stopLossPct)
+	if side == alpaca.Sell {
+		takeProfit, stopLoss = price*(1-takeProfitPct), price*(1+stopLossPct)
+	}
+	order, err := b.client.PlaceOrder(alpaca.PlaceOrderRequest{
+		AssetKey:    &symbol,
+		Qty:         decimal.New(qty, 0),
+		Side:        side,
+		Type:        alpaca.Market,
+		TimeInForce: alpaca.Day,
+		OrderClass:  alpaca.Bracket,
+		TakeProfit:  alpaca.NewTakeProfit(decimal.NewFromFloat(takeProfit).Round(2)),
+		StopLoss:    alpaca.NewStopLoss(decimal.NewFromFloat(stopLoss).Round(2)),
+	})
+	if err != nil {
+		log.Printf("%s %s %d: %v", symbol, side, qty, err)
+		return
+	}
+	log.Printf("%s %s %d, take profit %.2f, stop loss %.2f", symbol, side, qty, takeProfit, stopLoss)
+
+	b.mu.Lock()
+	b.pending[symbol] = order.ID
+	b.mu.Unlock()
+}
+
+// shutdown stops the streams and cancels the orders, the legs of the
+// brackets included, closing the positions with -close.
+func (b *book) shutdown() {
+	log.Println("shutting down")
+	if err := stream.UnsubscribeBars(symbols...); err != nil {
+		log.Println("unsubscribe:", err)
+	}
+	if err := b.client.CancelAllOrders(); err != nil {
+		log.Println("cancel orders:", err)
+	}
+	if *closeOnExit {
+		if err := b.client.CloseAllPositions(); err != nil {
+			log.Println("close positions:", err)
+		}
+	}
+	if err := stream.Close(); err != nil {
+		log.Println("close streams:", err)
+	}
+}
+
+// trim keeps the last windowSize bars of window.
+func trim(window []barClose) []barClose {
+	if len(window) > windowSize {
+		return window[len(window)-windowSize:]
+	}
+	return window
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
 	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
 	"github.com/shopspring/decimal"
 )
 
@@ -66,11 +67,7 @@ func init() {
 
 func main() {
 	// First, cancel any existing orders so they don't impact our buying power.
-	status, until, limit := "open", time.Now(), 100
-	orders, _ := alpacaClient.client.ListOrders(&status, &until, &limit, nil)
-	for _, order := range orders {
-		_ = alpacaClient.client.CancelOrder(order.ID)
-	}
+	_ = alpacaClient.client.CancelAllOrders()
 
 	// Wait for market to open.
 	fmt.Println("Waiting for market to open...")
@@ -97,17 +94,8 @@ func (alp alpacaClientContainer) run() {
 		// Close all positions when 15 minutes til market close.
 		fmt.Println("Market closing soon.  Closing positions.")
 
-		positions, _ := alpacaClient.client.ListPositions()
-		for _, position := range positions {
-			var orderSide string
-			if position.Side == "long" {
-				orderSide = "sell"
-			} else {
-				orderSide = "buy"
-			}
-			qty, _ := position.Qty.Float64()
-			qty = math.Abs(qty)
-			alpacaClient.submitOrder(int(qty), position.Symbol, orderSide)
+		if err := alpacaClient.client.CloseAllPositions(); err != nil {
+			fmt.Printf("Positions could not be closed: %v\n", err)
 		}
 		// Run script again after market close for next trading day.
 		fmt.Println("Sleeping until market close (15 minutes).")
@@ -145,11 +133,7 @@ func (alp alpacaClientContainer) rebalance() {
 	fmt.Println()
 
 	// Clear existing orders again.
-	status, until, limit := "open", time.Now(), 100
-	orders, _ := alpacaClient.client.ListOrders(&status, &until, &limit, nil)
-	for _, order := range orders {
-		_ = alpacaClient.client.CancelOrder(order.ID)
-	}
+	_ = alpacaClient.client.CancelAllOrders()
 
 	// Remove positions that are no longer in the short or long list, and make a list of positions that do not need to change.  Adjust position quantities if needed.
 	alpacaClient.blacklist = nil
@@ -161,21 +145,21 @@ func (alp alpacaClientContainer) rebalance() {
 
 		rawQty, _ := position.Qty.Float64()
 		qty := int(math.Abs(rawQty))
-		side := "buy"
+		side := alpaca.Buy
 		if indLong < 0 {
 			// Position is not in long list.
 			if indShort < 0 {
 				// Position not in short list either.  Clear position.
 				if position.Side == "long" {
-					side = "sell"
+					side = alpaca.Sell
 				} else {
-					side = "buy"
+					side = alpaca.Buy
 				}
 				alpacaClient.submitOrder(int(math.Abs(float64(qty))), position.Symbol, side)
 			} else {
 				if position.Side == "long" {
 					// Position changed from long to short.  Clear long position to prep for short sell.
-					side = "sell"
+					side = alpaca.Sell
 					alpacaClient.submitOrder(qty, position.Symbol, side)
 				} else {
 					// Position in short list
@@ -186,11 +170,11 @@ func (alp alpacaClientContainer) rebalance() {
 						diff := qty - alpacaClient.short.qty
 						if diff > 0 {
 							// Too many short positions.  Buy some back to rebalance.
-							side = "buy"
+							side = alpaca.Buy
 						} else {
 							// Too little short positions.  Sell some more.
 							diff = int(math.Abs(float64(diff)))
-							side = "sell"
+							side = alpaca.Sell
 						}
 						qty = diff
 						alpacaClient.submitOrder(qty, position.Symbol, side)
@@ -203,7 +187,7 @@ func (alp alpacaClientContainer) rebalance() {
 			// Position in long list.
 			if position.Side == "short" {
 				// Position changed from short to long.  Clear short position to prep for long purchase.
-				side = "buy"
+				side = alpaca.Buy
 				alpacaClient.submitOrder(qty, position.Symbol, side)
 			} else {
 				if qty == alpacaClient.long.qty {
@@ -213,10 +197,10 @@ func (alp alpacaClientContainer) rebalance() {
 					diff := qty - alpacaClient.long.qty
 					if diff > 0 {
 						// Too many long positions.  Sell some to rebalance.
-						side = "sell"
+						side = alpaca.Sell
 					} else {
 						diff = int(math.Abs(float64(diff)))
-						side = "buy"
+						side = alpaca.Buy
 					}
 					qty = diff
 					alpacaClient.submitOrder(qty, position.Symbol, side)
@@ -228,7 +212,7 @@ func (alp alpacaClientContainer) rebalance() {
 	}
 
 	// Send orders to all remaining stocks in the long and short list.
-	longBOResp := alpacaClient.sendBatchOrder(alpacaClient.long.qty, alpacaClient.long.list, "buy")
+	longBOResp := alpacaClient.sendBatchOrder(alpacaClient.long.qty, alpacaClient.long.list, alpaca.Buy)
 	executed[0] = append(executed[0], longBOResp[0][:]...)
 	if len(longBOResp[1][:]) > 0 {
 		// Handle rejected/incomplete orders and determine new quantities to purchase.
@@ -243,7 +227,7 @@ func (alp alpacaClientContainer) rebalance() {
 		alpacaClient.long.adjustedQty = -1
 	}
 
-	shortBOResp := alpacaClient.sendBatchOrder(alpacaClient.short.qty, alpacaClient.short.list, "sell")
+	shortBOResp := alpacaClient.sendBatchOrder(alpacaClient.short.qty, alpacaClient.short.list, alpaca.Sell)
 	executed[1] = append(executed[1], shortBOResp[0][:]...)
 	if len(shortBOResp[1][:]) > 0 {
 		// Handle rejected/incomplete orders and determine new quantities to purchase.
@@ -261,14 +245,14 @@ func (alp alpacaClientContainer) rebalance() {
 	if alpacaClient.long.adjustedQty > -1 {
 		alpacaClient.long.qty = alpacaClient.long.adjustedQty - alpacaClient.long.qty
 		for _, stock := range executed[0] {
-			alpacaClient.submitOrder(alpacaClient.long.qty, stock, "buy")
+			alpacaClient.submitOrder(alpacaClient.long.qty, stock, alpaca.Buy)
 		}
 	}
 
 	if alpacaClient.short.adjustedQty > -1 {
 		alpacaClient.short.qty = alpacaClient.short.adjustedQty - alpacaClient.short.qty
 		for _, stock := range executed[1] {
-			alpacaClient.submitOrder(alpacaClient.short.qty, stock, "sell")
+			alpacaClient.submitOrder(alpacaClient.short.qty, stock, alpaca.Sell)
 		}
 	}
 }
@@ -313,32 +297,37 @@ func (alp alpacaClientContainer) rerank() {
 
 // Get the total price of the array of input stocks.
 func (alp alpacaClientContainer) getTotalPrice(arr []string) float64 {
+	if len(arr) == 0 {
+		return 0
+	}
+	snapshots, err := alpacaClient.client.GetSnapshots(arr)
+	if err != nil {
+		fmt.Printf("Snapshots could not be fetched: %v\n", err)
+		return 0
+	}
 	totalPrice := 0.0
 	for _, stock := range arr {
-		numBars := 1
-		bar, _ := alpacaClient.client.GetSymbolBars(stock, alpaca.ListBarParams{Timeframe: "minute", Limit: &numBars})
-		totalPrice += float64(bar[0].Close)
+		if snapshot := snapshots[stock]; snapshot != nil && snapshot.LatestTrade != nil {
+			totalPrice += snapshot.LatestTrade.Price
+		}
 	}
 	return totalPrice
 }
 
 // Submit an order if quantity is above 0.
-func (alp alpacaClientContainer) submitOrder(qty int, symbol string, side string) error {
-	account, _ := alpacaClient.client.GetAccount()
+func (alp alpacaClientContainer) submitOrder(qty int, symbol string, side alpaca.Side) error {
 	if qty > 0 {
-		adjSide := alpaca.Side(side)
 		_, err := alpacaClient.client.PlaceOrder(alpaca.PlaceOrderRequest{
-			AccountID:   account.ID,
 			AssetKey:    &symbol,
-			Qty:         decimal.NewFromFloat(float64(qty)),
-			Side:        adjSide,
-			Type:        "market",
-			TimeInForce: "day",
+			Qty:         decimal.New(int64(qty), 0),
+			Side:        side,
+			Type:        alpaca.Market,
+			TimeInForce: alpaca.Day,
 		})
 		if err == nil {
 			fmt.Printf("Market order of | %d %s %s | completed.\n", qty, symbol, side)
 		} else {
-			fmt.Printf("Order of | %d %s %s | did not go through.\n", qty, symbol, side)
+			fmt.Printf("Order of | %d %s %s | did not go through: %v\n", qty, symbol, side, err)
 		}
 		return err
 	}
@@ -347,7 +336,7 @@ func (alp alpacaClientContainer) submitOrder(qty int, symbol string, side string
 }
 
 // Submit a batch order that returns completed and uncompleted orders.
-func (alp alpacaClientContainer) sendBatchOrder(qty int, stocks []string, side string) [2][]string {
+func (alp alpacaClientContainer) sendBatchOrder(qty int, stocks []string, side alpaca.Side) [2][]string {
 	var executed []string
 	var incomplete []string
 	for _, stock := range stocks {
@@ -364,14 +353,28 @@ func (alp alpacaClientContainer) sendBatchOrder(qty int, stocks []string, side s
 	return [2][]string{executed, incomplete}
 }
 
-// Get percent changes of the stock prices over the past 10 days.
+// Get percent changes of the stock prices over the past 10 minutes.
 func (alp alpacaClientContainer) getPercentChanges() {
 	length := 10
+	symbols := make([]string, len(alpacaClient.allStocks))
+	for i, stock := range alpacaClient.allStocks {
+		symbols[i] = stock.name
+	}
+	endTime := time.Now()
+	startTime := endTime.Add(-time.Duration(length) * time.Minute)
+	bars, err := alpacaClient.client.GetMultiBars(symbols, v2.Min, v2.Raw, startTime, endTime)
+	if err != nil {
+		fmt.Printf("Bars could not be fetched: %v\n", err)
+		return
+	}
 	for i, stock := range alpacaClient.allStocks {
-		startTime, endTime := time.Unix(time.Now().Unix()-int64(length*60), 0), time.Now()
-		bars, _ := alpacaClient.client.GetSymbolBars(stock.name, alpaca.ListBarParams{Timeframe: "minute", StartDt: &startTime, EndDt: &endTime})
-		percentChange := (bars[len(bars)-1].Close - bars[0].Open) / bars[0].Open
-		alpacaClient.allStocks[i].pc = float64(percentChange)
+		stockBars := bars[stock.name]
+		if len(stockBars) == 0 {
+			alpacaClient.allStocks[i].pc = 0
+			continue
+		}
+		first, last := stockBars[0], stockBars[len(stockBars)-1]
+		alpacaClient.allStocks[i].pc = (last.Close - first.Open) / first.Open
 	}
 }
 
@@ -6,19 +6,19 @@ import (
 	"math"
 	"math/rand"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
 	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
 	"github.com/market-development-strategy/alpaca-trade-api-go/common"
-	"github.com/market-development-strategy/alpaca-trade-api-go/polygon"
-	"github.com/market-development-strategy/alpaca-trade-api-go/stream"
+	"github.com/market-development-strategy/alpaca-trade-api-go/positions"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
 	"github.com/shopspring/decimal"
 )
 
 type alpacaClientContainer struct {
 	client        *alpaca.Client
+	positions     *positions.Tracker
 	tickSize      int
 	tickIndex     int
 	baseBet       float64
@@ -55,7 +55,6 @@ func init() {
 	if common.Credentials().Secret == "" {
 		os.Setenv(common.EnvApiSecretKey, API_SECRET)
 	}
-	// os.Setenv("APCA_API_VERSION", "v1")
 	alpaca.SetBaseUrl(BASE_URL)
 
 	// Check if user input a stock, default is SPY
@@ -67,15 +66,16 @@ func init() {
 	client := alpaca.NewClient(common.Credentials())
 
 	// Cancel any open orders so they don't interfere with this script
-	client.CancelAllOrders()
+	if err := client.CancelAllOrders(); err != nil {
+		panic(err)
+	}
 
-	pos, err := client.GetPosition(stock)
-	position := int64(0)
-	if err != nil {
-		// No position exists
-	} else {
-		position = pos.Qty.IntPart()
+	// Track our positions from the fills of our orders
+	tracker := positions.New(client)
+	if err := tracker.Start(); err != nil {
+		panic(err)
 	}
+	position := tracker.Position(stock).Qty.IntPart()
 
 	// Figure out how much money we have to work with, accounting for margin
 	accountInfo, err := client.GetAccount()
@@ -84,16 +84,14 @@ func init() {
 	}
 
 	equity, _ := accountInfo.Equity.Float64()
-	marginMult, err := strconv.ParseFloat(accountInfo.Multiplier, 64)
-	if err != nil {
-		panic(err)
-	}
+	marginMult, _ := accountInfo.Multiplier.Float64()
 
 	totalBuyingPower := marginMult * equity
 	fmt.Printf("Initial total buying power = %.2f\n", totalBuyingPower)
 
 	alpacaClient = alpacaClientContainer{
 		client,
+		tracker,
 		5,
 		4,
 		.1,
@@ -114,58 +112,19 @@ func init() {
 }
 
 func main() {
-	USE_POLYGON := false
-
-	// First, cancel any existing orders so they don't impact our buying power.
-	status, until, limit := "open", time.Now(), 100
-	orders, _ := alpacaClient.client.ListOrders(&status, &until, &limit, nil)
-	for _, order := range orders {
-		_ = alpacaClient.client.CancelOrder(order.ID)
-	}
-
-	if USE_POLYGON {
-		stream.SetDataStream("polygon")
-		if err := stream.Register(fmt.Sprintf("A.%s", alpacaClient.stock), handleAggs); err != nil {
-			panic(err)
-		}
-	} else {
-		if err := stream.Register(fmt.Sprintf("T.%s", alpacaClient.stock), handleAlpacaAggs); err != nil {
-			panic(err)
-		}
+	if err := stream.SubscribeTrades(handleTrade, alpacaClient.stock); err != nil {
+		panic(err)
 	}
 
-	if err := stream.Register("trade_updates", handleTrades); err != nil {
+	if err := stream.SubscribeTradeUpdates(handleTradeUpdate); err != nil {
 		panic(err)
 	}
 
 	select {}
 }
 
-// Listen for second aggregates and perform trading logic
-func handleAggs(msg interface{}) {
-	data := msg.(polygon.StreamAggregate)
-
-	if data.Symbol != alpacaClient.stock {
-		return
-	}
-
-	alpacaClient.tickIndex = (alpacaClient.tickIndex + 1) % alpacaClient.tickSize
-	if alpacaClient.tickIndex == 0 {
-		// It's time to update
-
-		// Update price info
-		tickOpen := alpacaClient.lastPrice
-		tickClose := float64(data.ClosePrice)
-		alpacaClient.lastPrice = tickClose
-
-		alpacaClient.processTick(tickOpen, tickClose)
-	}
-}
-
-// Listen for quote data and perform trading logic
-func handleAlpacaAggs(msg interface{}) {
-	data := msg.(alpaca.StreamTrade)
-
+// Listen for trades and perform trading logic
+func handleTrade(data stream.Trade) {
 	if data.Symbol != alpacaClient.stock {
 		return
 	}
@@ -183,7 +142,7 @@ func handleAlpacaAggs(msg interface{}) {
 
 		// Update price info
 		tickOpen := alpacaClient.lastPrice
-		tickClose := float64(data.Price)
+		tickClose := data.Price
 		alpacaClient.lastPrice = tickClose
 
 		alpacaClient.processTick(tickOpen, tickClose)
@@ -191,8 +150,7 @@ func handleAlpacaAggs(msg interface{}) {
 }
 
 // Listen for updates to our orders
-func handleTrades(msg interface{}) {
-	data := msg.(alpaca.TradeUpdate)
+func handleTradeUpdate(data alpaca.TradeUpdate) {
 	fmt.Printf("%s event received for order %s.\n", data.Event, data.Order.ID)
 
 	if data.Order.Symbol != alpacaClient.stock {
@@ -203,25 +161,21 @@ func handleTrades(msg interface{}) {
 	eventType := data.Event
 	oid := data.Order.ID
 
-	if eventType == "fill" || eventType == "partial_fill" {
+	if eventType.IsFill() {
 		// Our position size has changed
-		pos, err := alpacaClient.client.GetPosition(alpacaClient.stock)
-		if err != nil {
-			alpacaClient.position = 0
-		} else {
-			alpacaClient.position = pos.Qty.IntPart()
-		}
+		alpacaClient.positions.HandleTradeUpdate(data)
+		alpacaClient.position = alpacaClient.positions.Position(alpacaClient.stock).Qty.IntPart()
 
 		fmt.Printf("New position size due to order fill: %d\n", alpacaClient.position)
-		if eventType == "fill" && alpacaClient.currOrder == oid {
+		if eventType == alpaca.EventFill && alpacaClient.currOrder == oid {
 			alpacaClient.currOrder = ""
 		}
-	} else if eventType == "rejected" || eventType == "canceled" {
+	} else if eventType == alpaca.EventRejected || eventType == alpaca.EventCanceled {
 		if alpacaClient.currOrder == oid {
 			// Our last order should be removed
 			alpacaClient.currOrder = ""
 		}
-	} else if eventType == "new" {
+	} else if eventType == alpaca.EventNew {
 		alpacaClient.currOrder = oid
 	} else {
 		fmt.Printf("Unexpected order event type %s received\n", eventType)
@@ -320,27 +274,21 @@ func (alp alpacaClientContainer) sendOrder(targetQty int) (string, error) {
 		fmt.Printf("Selling %d shares.\n", int64(qty))
 	}
 
-	// Follow [L] instructions to use limit orders
 	if qty > 0 {
-		account, _ := alp.client.GetAccount()
-
-		// [L] Uncomment line below
 		limitPrice := decimal.NewFromFloat(alp.lastPrice)
 
 		alp.currOrder = randomString()
-		alp.client.PlaceOrder(alpaca.PlaceOrderRequest{
-			AccountID: account.ID,
-			AssetKey:  &alp.stock,
-			Qty:       decimal.NewFromFloat(qty),
-			Side:      side,
-			Type:      alpaca.Limit, // [L] Change to alpaca.Limit
-			// [L] Uncomment line below
+		_, err := alp.client.PlaceOrder(alpaca.PlaceOrderRequest{
+			AssetKey:      &alp.stock,
+			Qty:           decimal.NewFromFloat(qty),
+			Side:          side,
+			Type:          alpaca.Limit,
 			LimitPrice:    &limitPrice,
 			TimeInForce:   alpaca.Day,
 			ClientOrderID: alp.currOrder,
 		})
 
-		return alp.currOrder, nil
+		return alp.currOrder, err
 	}
 
 	return "", errors.New("Non-positive quantity given")
@@ -2,24 +2,29 @@ package main
 
 import (
 	"fmt"
-	"math"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
 	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
 	"github.com/shopspring/decimal"
 )
 
 type alpacaClientContainer struct {
-	client         *alpaca.Client
-	runningAverage float64
-	lastOrder      string
-	amtBars        int
-	stock          string
+	client    *alpaca.Client
+	lastOrder string
+	amtBars   int
+	stock     string
+
+	// mu guards the closes of the last minute bars, appended by the stream
+	mu     sync.Mutex
+	closes []float64
 }
 
-var alpacaClient alpacaClientContainer
+var alpacaClient *alpacaClientContainer
 
 func init() {
 	API_KEY := "YOUR_API_KEY_HERE"
@@ -40,21 +45,17 @@ func init() {
 	if len(os.Args[1:]) == 1 {
 		stock = os.Args[1]
 	}
-	alpacaClient = alpacaClientContainer{
-		alpaca.NewClient(common.Credentials()),
-		0.0,
-		"",
-		20,
-		stock,
+	alpacaClient = &alpacaClientContainer{
+		client:  alpaca.NewClient(common.Credentials()),
+		amtBars: 20,
+		stock:   stock,
 	}
 }
 
 func main() {
 	// First, cancel any existing orders so they don't impact our buying power.
-	status, until, limit := "open", time.Now(), 100
-	orders, _ := alpacaClient.client.ListOrders(&status, &until, &limit, nil)
-	for _, order := range orders {
-		_ = alpacaClient.client.CancelOrder(order.ID)
+	if err := alpacaClient.client.CancelAllOrders(); err != nil {
+		panic(err)
 	}
 
 	// Wait for market to open
@@ -68,19 +69,22 @@ func main() {
 	}
 	fmt.Println("Market Opened.")
 
+	// Seed the running average with the minute bars we missed, then keep it
+	// up to date with the bars of the stream.
+	if err := alpacaClient.loadBars(); err != nil {
+		panic(err)
+	}
+	if err := stream.SubscribeBars(alpacaClient.handleBar, alpacaClient.stock); err != nil {
+		panic(err)
+	}
+
 	// Wait until 20 bars of data since market open have been collected.
 	fmt.Printf("Waiting for %d bars...\n", alpacaClient.amtBars)
 	for {
-		rawTime, _ := time.Parse(time.RFC3339, time.Now().String())
-		currTime := rawTime.String()
-		cal, _ := alpacaClient.client.GetCalendar(&currTime, &currTime)
-		marketOpen, _ := time.Parse(time.RFC3339, cal[0].Open)
-		bars, _ := alpacaClient.client.GetSymbolBars(alpacaClient.stock, alpaca.ListBarParams{Timeframe: "minute", StartDt: &marketOpen})
-		if len(bars) >= alpacaClient.amtBars {
+		if _, ok := alpacaClient.runningAverage(); ok {
 			break
-		} else {
-			time.Sleep(1 * time.Minute)
 		}
+		time.Sleep(1 * time.Minute)
 	}
 	fmt.Printf("We have %d bars.\n", alpacaClient.amtBars)
 
@@ -89,10 +93,64 @@ func main() {
 	}
 }
 
+// Fetch the minute bars of the running average window since market open.
+func (alp *alpacaClientContainer) loadBars() error {
+	clock, err := alp.client.GetClock()
+	if err != nil {
+		return err
+	}
+	start := clock.Timestamp.Add(-time.Duration(alp.amtBars) * time.Minute)
+	for item := range alp.client.GetBars(alp.stock, v2.Min, v2.Raw, start, clock.Timestamp, alp.amtBars) {
+		if item.Error != nil {
+			return item.Error
+		}
+		alp.addClose(item.Bar.Close)
+	}
+	return nil
+}
+
+// Listen for minute bars and record their closes.
+func (alp *alpacaClientContainer) handleBar(bar stream.Bar) {
+	if bar.Symbol != alp.stock {
+		return
+	}
+	alp.addClose(bar.Close)
+}
+
+func (alp *alpacaClientContainer) addClose(price float64) {
+	alp.mu.Lock()
+	defer alp.mu.Unlock()
+	alp.closes = append(alp.closes, price)
+	if len(alp.closes) > alp.amtBars {
+		alp.closes = alp.closes[len(alp.closes)-alp.amtBars:]
+	}
+}
+
+// Get the running average of the window and the last close, if the window
+// is full.
+func (alp *alpacaClientContainer) runningAverage() (float64, bool) {
+	alp.mu.Lock()
+	defer alp.mu.Unlock()
+	if len(alp.closes) < alp.amtBars {
+		return 0, false
+	}
+	average := 0.0
+	for _, price := range alp.closes {
+		average += price
+	}
+	return average / float64(len(alp.closes)), true
+}
+
+func (alp *alpacaClientContainer) lastClose() float64 {
+	alp.mu.Lock()
+	defer alp.mu.Unlock()
+	return alp.closes[len(alp.closes)-1]
+}
+
 // Rebalance our portfolio every minute based off running average data.
-func (alp alpacaClientContainer) run() {
-	if alpacaClient.lastOrder != "" {
-		_ = alp.client.CancelOrder(alpacaClient.lastOrder)
+func (alp *alpacaClientContainer) run() {
+	if alp.lastOrder != "" {
+		_ = alp.client.CancelOrder(alp.lastOrder)
 	}
 
 	// Figure out when the market will close so we can prepare to sell beforehand.
@@ -101,17 +159,8 @@ func (alp alpacaClientContainer) run() {
 		// Close all positions when 15 minutes til market close.
 		fmt.Println("Market closing soon.  Closing positions.")
 
-		positions, _ := alp.client.ListPositions()
-		for _, position := range positions {
-			var orderSide string
-			if position.Side == "long" {
-				orderSide = "sell"
-			} else {
-				orderSide = "buy"
-			}
-			qty, _ := position.Qty.Float64()
-			qty = math.Abs(qty)
-			alp.submitMarketOrder(int(qty), position.Symbol, orderSide)
+		if err := alp.client.CloseAllPositions(); err != nil {
+			fmt.Printf("Positions could not be closed: %v\n", err)
 		}
 		// Run script again after market close for next trading day.
 		fmt.Println("Sleeping until market close (15 minutes).")
@@ -124,7 +173,7 @@ func (alp alpacaClientContainer) run() {
 }
 
 // Spin until the market is open.
-func (alp alpacaClientContainer) awaitMarketOpen() bool {
+func (alp *alpacaClientContainer) awaitMarketOpen() bool {
 	clock, _ := alp.client.GetClock()
 	if clock.IsOpen {
 		return true
@@ -135,45 +184,34 @@ func (alp alpacaClientContainer) awaitMarketOpen() bool {
 }
 
 // Rebalance our position after an update.
-func (alp alpacaClientContainer) rebalance() {
+func (alp *alpacaClientContainer) rebalance() {
 	// Get our position, if any.
 	positionQty := 0
 	positionVal := 0.0
-	position, err := alp.client.GetPosition(alpacaClient.stock)
-	if err != nil {
-	} else {
+	position, err := alp.client.GetPosition(alp.stock)
+	if err == nil {
 		positionQty = int(position.Qty.IntPart())
 		positionVal, _ = position.MarketValue.Float64()
 	}
 
 	// Get the new updated price and running average.
-	bars, _ := alp.client.GetSymbolBars(alpacaClient.stock, alpaca.ListBarParams{Timeframe: "minute", Limit: &alpacaClient.amtBars})
-	currPrice := float64(bars[len(bars)-1].Close)
-	alpacaClient.runningAverage = 0.0
-	for _, bar := range bars {
-		alpacaClient.runningAverage += float64(bar.Close)
-	}
-	alpacaClient.runningAverage /= float64(alpacaClient.amtBars)
+	runningAverage, _ := alp.runningAverage()
+	currPrice := alp.lastClose()
 
-	if currPrice > alpacaClient.runningAverage {
+	if currPrice > runningAverage {
 		// Sell our position if the price is above the running average, if any.
 		if positionQty > 0 {
 			fmt.Println("Setting long position to zero")
-			alp.submitLimitOrder(positionQty, alpacaClient.stock, currPrice, "sell")
+			alp.submitLimitOrder(positionQty, alp.stock, currPrice, alpaca.Sell)
 		} else {
 			fmt.Println("No action required.")
 		}
-	} else if currPrice < alpacaClient.runningAverage {
+	} else if currPrice < runningAverage {
 		// Determine optimal amount of shares based on portfolio and market data.
 		account, _ := alp.client.GetAccount()
 		buyingPower, _ := account.BuyingPower.Float64()
-		positions, _ := alp.client.ListPositions()
-		portfolioVal, _ := account.Cash.Float64()
-		for _, position := range positions {
-			rawVal, _ := position.MarketValue.Float64()
-			portfolioVal += rawVal
-		}
-		portfolioShare := (alpacaClient.runningAverage - currPrice) / currPrice * 200
+		portfolioVal, _ := account.Equity.Float64()
+		portfolioShare := (runningAverage - currPrice) / currPrice * 200
 		targetPositionValue := portfolioVal * portfolioShare
 		amountToAdd := targetPositionValue - positionVal
 
@@ -183,65 +221,37 @@ func (alp alpacaClientContainer) rebalance() {
 				amountToAdd = buyingPower
 			}
 			var qtyToBuy = int(amountToAdd / currPrice)
-			alp.submitLimitOrder(qtyToBuy, alpacaClient.stock, currPrice, "buy")
+			alp.submitLimitOrder(qtyToBuy, alp.stock, currPrice, alpaca.Buy)
 		} else {
 			amountToAdd *= -1
 			var qtyToSell = int(amountToAdd / currPrice)
 			if qtyToSell > positionQty {
 				qtyToSell = positionQty
 			}
-			alp.submitLimitOrder(qtyToSell, alpacaClient.stock, currPrice, "sell")
+			alp.submitLimitOrder(qtyToSell, alp.stock, currPrice, alpaca.Sell)
 		}
 	}
 }
 
 // Submit a limit order if quantity is above 0.
-func (alp alpacaClientContainer) submitLimitOrder(qty int, symbol string, price float64, side string) error {
-	account, _ := alp.client.GetAccount()
+func (alp *alpacaClientContainer) submitLimitOrder(qty int, symbol string, price float64, side alpaca.Side) error {
 	if qty > 0 {
-		adjSide := alpaca.Side(side)
 		limPrice := decimal.NewFromFloat(price)
 		order, err := alp.client.PlaceOrder(alpaca.PlaceOrderRequest{
-			AccountID:   account.ID,
 			AssetKey:    &symbol,
-			Qty:         decimal.NewFromInt(int64(qty)),
-			Side:        adjSide,
-			Type:        "limit",
+			Qty:         decimal.New(int64(qty), 0),
+			Side:        side,
+			Type:        alpaca.Limit,
 			LimitPrice:  &limPrice,
-			TimeInForce: "day",
-		})
-		if err == nil {
-			fmt.Printf("Limit order of | %d %s %s | sent.\n", qty, symbol, side)
-		} else {
-			fmt.Printf("Order of | %d %s %s | did not go through.\n", qty, symbol, side)
-		}
-		alpacaClient.lastOrder = order.ID
-		return err
-	}
-	fmt.Printf("Quantity is <= 0, order of | %d %s %s | not sent.\n", qty, symbol, side)
-	return nil
-}
-
-// Submit a market order if quantity is above 0.
-func (alp alpacaClientContainer) submitMarketOrder(qty int, symbol string, side string) error {
-	account, _ := alp.client.GetAccount()
-	if qty > 0 {
-		adjSide := alpaca.Side(side)
-		lastOrder, err := alp.client.PlaceOrder(alpaca.PlaceOrderRequest{
-			AccountID:   account.ID,
-			AssetKey:    &symbol,
-			Qty:         decimal.NewFromInt(int64(qty)),
-			Side:        adjSide,
-			Type:        "market",
-			TimeInForce: "day",
+			TimeInForce: alpaca.Day,
 		})
-		if err == nil {
-			fmt.Printf("Market order of | %d %s %s | completed.\n", qty, symbol, side)
-			alpacaClient.lastOrder = lastOrder.ID
-		} else {
-			fmt.Printf("Order of | %d %s %s | did not go through.\n", qty, symbol, side)
+		if err != nil {
+			fmt.Printf("Order of | %d %s %s | did not go through: %v\n", qty, symbol, side, err)
+			return err
 		}
-		return err
+		fmt.Printf("Limit order of | %d %s %s | sent.\n", qty, symbol, side)
+		alp.lastOrder = order.ID
+		return nil
 	}
 	fmt.Printf("Quantity is <= 0, order of | %d %s %s | not sent.\n", qty, symbol, side)
 	return nil
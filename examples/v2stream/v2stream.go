@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
 	"github.com/market-development-strategy/alpaca-trade-api-go/common"
 	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
 )
@@ -24,7 +23,7 @@ func main() {
 	// uncomment if you have PRO subscription
 	// stream.UseFeed("sip")
 
-	if err := stream.SubscribeTradeUpdates(tradeUpdateHandler); err != nil {
+	if err := stream.SubscribeTradeEvents(tradeEventHandler); err != nil {
 		panic(err)
 	}
 
@@ -41,8 +40,15 @@ func main() {
 	select {}
 }
 
-func tradeUpdateHandler(update alpaca.TradeUpdate) {
-	fmt.Println("trade update", update)
+func tradeEventHandler(event stream.TradeUpdateEvent) {
+	switch e := event.(type) {
+	case stream.FillEvent:
+		fmt.Println("fill", e.Order.Symbol, e.Qty, "@", e.Price, "position", e.PositionQty)
+	case stream.CancelEvent:
+		fmt.Println("order", e.Order.ID, e.Event)
+	default:
+		fmt.Println("trade update", e.GetEvent(), e.GetOrder().ID)
+	}
 }
 
 func tradeHandler(trade stream.Trade) {
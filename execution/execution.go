@@ -0,0 +1,400 @@
+// Package execution works a large parent order as smaller child orders over
+// time, with a TWAP, volume participation (VWAP) or iceberg strategy, e.g.
+//
+//	exec, err := execution.New(client, execution.Parent{
+//		Symbol:   "AAPL",
+//		Side:     alpaca.Buy,
+//		Qty:      decimal.New(10000, 0),
+//		Duration: time.Hour,
+//	}, execution.TWAP(12))
+//	...
+//	err = exec.Run(ctx)
+package execution
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	// ErrCanceled is returned by Run when the execution was canceled
+	ErrCanceled = errors.New("execution canceled")
+	// ErrAmendBelowFilled is returned by Amend for a quantity lower than
+	// the quantity already filled
+	ErrAmendBelowFilled = errors.New("amended quantity below filled quantity")
+)
+
+// Parent is the order worked by an execution
+type Parent struct {
+	Symbol string
+	Side   alpaca.Side
+	Qty    decimal.Decimal
+	// LimitPrice makes the child orders limit orders, market ones otherwise
+	LimitPrice *decimal.Decimal
+	// Duration is how long the order is worked, what is not filled by then
+	// is left unfilled. It is required by TWAP, and 0 means no end for the
+	// other strategies.
+	Duration time.Duration
+}
+
+// Progress is the state of an execution
+type Progress struct {
+	Filled    decimal.Decimal
+	Remaining decimal.Decimal
+	// AvgPrice is the average price of the filled quantity
+	AvgPrice decimal.Decimal
+	// Working is the open quantity of the current child order
+	Working decimal.Decimal
+	// Children is the number of child orders placed
+	Children int
+	Done     bool
+}
+
+// Option configures an Execution
+type Option func(e *Execution)
+
+// WithPollInterval sets how often the child order is checked, and the
+// strategy reevaluated, every second by default.
+func WithPollInterval(interval time.Duration) Option {
+	return func(e *Execution) {
+		e.poll = interval
+	}
+}
+
+// OnProgress sets the callback called when a child order fills and when
+// the execution is done.
+func OnProgress(callback func(p Progress)) Option {
+	return func(e *Execution) {
+		e.onProgress = callback
+	}
+}
+
+// Execution works a parent order. It has at most one child order open at a
+// time, which it cancels and places again when the strategy asks for another
+// quantity.
+type Execution struct {
+	client     alpaca.TradingClient
+	strategy   Strategy
+	poll       time.Duration
+	onProgress func(p Progress)
+	canceled   chan struct{}
+	cancelOnce sync.Once
+
+	mu       sync.Mutex
+	parent   Parent
+	start    time.Time
+	volume   decimal.Decimal
+	filled   decimal.Decimal
+	notional decimal.Decimal
+	child    *alpaca.Order
+	// childFilled is the quantity and notional of child accounted for
+	childFilled, childNotional decimal.Decimal
+	children                   int
+	done                       bool
+}
+
+// New creates an execution of parent with strategy, placing the child orders
+// with client.
+func New(client alpaca.TradingClient, parent Parent, strategy Strategy, opts ...Option) (*Execution, error) {
+	if parent.Symbol == "" {
+		return nil, errors.New("execution: no symbol")
+	}
+	if parent.Side != alpaca.Buy && parent.Side != alpaca.Sell {
+		return nil, fmt.Errorf("execution: invalid side %q", parent.Side)
+	}
+	if !parent.Qty.IsPositive() {
+		return nil, errors.New("execution: non-positive quantity")
+	}
+	if err := strategy.validate(parent); err != nil {
+		return nil, fmt.Errorf("execution: %w", err)
+	}
+
+	e := &Execution{
+		client:   client,
+		strategy: strategy,
+		poll:     time.Second,
+		canceled: make(chan struct{}),
+		parent:   parent,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e, nil
+}
+
+// HandleTrade counts the volume traded in the symbol of the parent order,
+// which the VWAP strategy participates in. It can be passed to
+// stream.SubscribeTrades.
+func (e *Execution) HandleTrade(trade stream.Trade) {
+	if trade.Symbol != e.parent.Symbol {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.volume = e.volume.Add(decimal.New(int64(trade.Size), 0))
+}
+
+// Progress returns the progress of the execution.
+func (e *Execution) Progress() Progress {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.progress()
+}
+
+func (e *Execution) progress() Progress {
+	p := Progress{
+		Filled:    e.filled,
+		Remaining: e.parent.Qty.Sub(e.filled),
+		Working:   e.working(),
+		Children:  e.children,
+		Done:      e.done,
+	}
+	if e.filled.IsPositive() {
+		p.AvgPrice = e.notional.Div(e.filled)
+	}
+	return p
+}
+
+// working returns the open quantity of the child order
+func (e *Execution) working() decimal.Decimal {
+	if e.child == nil || e.child.Status.IsTerminal() {
+		return decimal.Zero
+	}
+	return e.child.Qty.Sub(e.child.FilledQty)
+}
+
+// Amend changes the quantity and limit price of the parent order, from the
+// next child order on. A nil limit price makes the child orders market ones.
+func (e *Execution) Amend(qty decimal.Decimal, limitPrice *decimal.Decimal) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if qty.LessThan(e.filled) {
+		return ErrAmendBelowFilled
+	}
+	parent := e.parent
+	parent.Qty, parent.LimitPrice = qty, limitPrice
+	if err := e.strategy.validate(parent); err != nil {
+		return fmt.Errorf("execution: %w", err)
+	}
+	e.parent = parent
+	return nil
+}
+
+// Cancel stops the execution, Run cancels the open child order and returns
+// ErrCanceled.
+func (e *Execution) Cancel() {
+	e.cancelOnce.Do(func() {
+		close(e.canceled)
+	})
+}
+
+// Run works the parent order until it is filled or its duration is over,
+// checking the child order at the poll interval. When ctx is done, the
+// execution is canceled or a request fails, it cancels the open child order
+// and returns the error.
+func (e *Execution) Run(ctx context.Context) error {
+	ticker := time.NewTicker(e.poll)
+	defer ticker.Stop()
+	for {
+		done, err := e.step(time.Now())
+		if err != nil {
+			e.abort()
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			e.abort()
+			return ctx.Err()
+		case <-e.canceled:
+			e.abort()
+			return ErrCanceled
+		case <-ticker.C:
+		}
+	}
+}
+
+// step checks the child order and places, cancels or amends it as the
+// strategy asks at now. It returns true once the execution is done.
+func (e *Execution) step(now time.Time) (bool, error) {
+	if err := e.sync(); err != nil {
+		return false, err
+	}
+
+	e.mu.Lock()
+	if e.start.IsZero() {
+		e.start = now
+	}
+	parent := e.parent
+	filled, working := e.filled, e.working()
+	s := state{parent: parent, filled: filled, working: working, volume: e.volume, elapsed: now.Sub(e.start)}
+	child := e.child
+	e.mu.Unlock()
+
+	if !filled.LessThan(parent.Qty) {
+		e.finish()
+		return true, nil
+	}
+	if parent.Duration > 0 && s.elapsed >= parent.Duration {
+		return true, e.abort()
+	}
+
+	target := decimal.Min(e.strategy.target(s), parent.Qty)
+	want := target.Sub(filled)
+	if working.IsPositive() {
+		if want.Equal(working) && (child.Type == alpaca.Limit) == (parent.LimitPrice != nil) {
+			return false, e.amend(child, parent.LimitPrice)
+		}
+		if err := e.client.CancelOrder(child.ID); err != nil && !errors.Is(err, alpaca.ErrOrderNotCancelable) {
+			return false, err
+		}
+		if err := e.sync(); err != nil {
+			return false, err
+		}
+		e.mu.Lock()
+		filled, working = e.filled, e.working()
+		e.mu.Unlock()
+		if working.IsPositive() {
+			// not canceled yet, placed again at the next step
+			return false, nil
+		}
+		// what the child order filled until it was canceled counts
+		want = target.Sub(filled)
+	}
+	if !want.IsPositive() {
+		return false, nil
+	}
+	return false, e.place(parent, want)
+}
+
+// amend replaces the limit price of the open child order if it changed
+func (e *Execution) amend(child *alpaca.Order, limitPrice *decimal.Decimal) error {
+	if limitPrice == nil || child.LimitPrice == nil || child.LimitPrice.Equal(*limitPrice) {
+		return nil
+	}
+	qty := child.Qty.Sub(child.FilledQty)
+	replacement, err := e.client.ReplaceOrder(child.ID, alpaca.ReplaceOrderRequest{
+		Qty:        &qty,
+		LimitPrice: limitPrice,
+	})
+	if errors.Is(err, alpaca.ErrOrderNotCancelable) {
+		// filled meanwhile
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.child = replacement
+	e.childFilled, e.childNotional = decimal.Zero, decimal.Zero
+	return nil
+}
+
+// place places a child order of qty
+func (e *Execution) place(parent Parent, qty decimal.Decimal) error {
+	req := alpaca.PlaceOrderRequest{
+		AssetKey:    &parent.Symbol,
+		Qty:         qty,
+		Side:        parent.Side,
+		Type:        alpaca.Market,
+		TimeInForce: alpaca.Day,
+	}
+	if parent.LimitPrice != nil {
+		req.Type = alpaca.Limit
+		req.LimitPrice = parent.LimitPrice
+	}
+	child, err := e.client.PlaceOrder(req)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	e.child = child
+	e.childFilled, e.childNotional = decimal.Zero, decimal.Zero
+	e.children++
+	e.mu.Unlock()
+	// a market order may be filled at once
+	e.account(child)
+	return nil
+}
+
+// sync fetches the open child order and accounts for its fills
+func (e *Execution) sync() error {
+	e.mu.Lock()
+	child := e.child
+	e.mu.Unlock()
+	if child == nil || child.Status.IsTerminal() {
+		return nil
+	}
+	order, err := e.client.GetOrder(child.ID)
+	if err != nil {
+		return err
+	}
+	e.account(order)
+	return nil
+}
+
+// account accounts for the quantity of the child order filled since it was
+// last accounted for
+func (e *Execution) account(order *alpaca.Order) {
+	e.mu.Lock()
+	if e.child == nil || e.child.ID != order.ID {
+		e.mu.Unlock()
+		return
+	}
+	e.child = order
+	qty := order.FilledQty.Sub(e.childFilled)
+	if !qty.IsPositive() || order.FilledAvgPrice == nil {
+		e.mu.Unlock()
+		return
+	}
+	notional := order.FilledQty.Mul(*order.FilledAvgPrice)
+	e.filled = e.filled.Add(qty)
+	e.notional = e.notional.Add(notional.Sub(e.childNotional))
+	e.childFilled, e.childNotional = order.FilledQty, notional
+	p := e.progress()
+	e.mu.Unlock()
+
+	if e.onProgress != nil {
+		e.onProgress(p)
+	}
+}
+
+// abort cancels the open child order and finishes the execution
+func (e *Execution) abort() error {
+	e.mu.Lock()
+	child, working := e.child, e.working()
+	e.mu.Unlock()
+	if working.IsPositive() {
+		if err := e.client.CancelOrder(child.ID); err != nil && !errors.Is(err, alpaca.ErrOrderNotCancelable) {
+			return err
+		}
+		if err := e.sync(); err != nil {
+			return err
+		}
+	}
+	e.finish()
+	return nil
+}
+
+// finish marks the execution done
+func (e *Execution) finish() {
+	e.mu.Lock()
+	e.done = true
+	p := e.progress()
+	e.mu.Unlock()
+
+	if e.onProgress != nil {
+		e.onProgress(p)
+	}
+}
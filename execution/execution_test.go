@@ -0,0 +1,274 @@
+package execution
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ExecutionTestSuite struct {
+	suite.Suite
+	mock     *alpacatest.MockClient
+	orders   map[string]*alpaca.Order
+	placed   []alpaca.PlaceOrderRequest
+	canceled []string
+	progress []Progress
+}
+
+func TestExecutionTestSuite(t *testing.T) {
+	suite.Run(t, new(ExecutionTestSuite))
+}
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func (s *ExecutionTestSuite) SetupTest() {
+	s.orders = map[string]*alpaca.Order{}
+	s.placed, s.canceled, s.progress = nil, nil, nil
+	s.mock = &alpacatest.MockClient{
+		PlaceOrderFunc: func(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+			s.placed = append(s.placed, req)
+			o := &alpaca.Order{
+				ID:         fmt.Sprintf("child_%d", len(s.placed)),
+				Symbol:     *req.AssetKey,
+				Qty:        req.Qty,
+				Side:       req.Side,
+				Type:       req.Type,
+				LimitPrice: req.LimitPrice,
+				Status:     alpaca.OrderNew,
+			}
+			s.orders[o.ID] = o
+			copy := *o
+			return &copy, nil
+		},
+		GetOrderFunc: func(orderID string) (*alpaca.Order, error) {
+			copy := *s.orders[orderID]
+			return &copy, nil
+		},
+		CancelOrderFunc: func(orderID string) error {
+			s.canceled = append(s.canceled, orderID)
+			s.orders[orderID].Status = alpaca.OrderCanceled
+			return nil
+		},
+		ReplaceOrderFunc: func(orderID string, req alpaca.ReplaceOrderRequest) (*alpaca.Order, error) {
+			old := s.orders[orderID]
+			old.Status = alpaca.OrderReplaced
+			o := *old
+			o.ID, o.Status = orderID+"_replaced", alpaca.OrderNew
+			o.Qty, o.FilledQty, o.FilledAvgPrice = *req.Qty, decimal.Zero, nil
+			o.LimitPrice = req.LimitPrice
+			s.orders[o.ID] = &o
+			copy := o
+			return &copy, nil
+		},
+	}
+}
+
+// fill fills qty more of an order at price
+func (s *ExecutionTestSuite) fill(orderID string, qty, price string) {
+	o := s.orders[orderID]
+	notional := decimal.Zero
+	if o.FilledAvgPrice != nil {
+		notional = o.FilledQty.Mul(*o.FilledAvgPrice)
+	}
+	o.FilledQty = o.FilledQty.Add(dec(qty))
+	avg := notional.Add(dec(qty).Mul(dec(price))).Div(o.FilledQty)
+	o.FilledAvgPrice = &avg
+	o.Status = alpaca.OrderPartiallyFilled
+	if o.FilledQty.Equal(o.Qty) {
+		o.Status = alpaca.OrderFilled
+	}
+}
+
+func (s *ExecutionTestSuite) execution(parent Parent, strategy Strategy) *Execution {
+	parent.Symbol, parent.Side = "AAPL", alpaca.Buy
+	e, err := New(s.mock, parent, strategy, OnProgress(func(p Progress) {
+		s.progress = append(s.progress, p)
+	}))
+	require.NoError(s.T(), err)
+	return e
+}
+
+func (s *ExecutionTestSuite) step(e *Execution, now time.Time) bool {
+	done, err := e.step(now)
+	require.NoError(s.T(), err)
+	return done
+}
+
+var start = time.Date(2021, 3, 2, 14, 30, 0, 0, time.UTC)
+
+func (s *ExecutionTestSuite) TestTWAP() {
+	e := s.execution(Parent{Qty: dec("100"), Duration: 30 * time.Minute}, TWAP(3))
+
+	assert.False(s.T(), s.step(e, start))
+	require.Len(s.T(), s.placed, 1)
+	assert.Equal(s.T(), "33", s.placed[0].Qty.String())
+	assert.Equal(s.T(), alpaca.Market, s.placed[0].Type)
+	s.fill("child_1", "33", "10")
+
+	// nothing more until the next slice
+	assert.False(s.T(), s.step(e, start.Add(9*time.Minute)))
+	assert.Len(s.T(), s.placed, 1)
+	assert.Equal(s.T(), "33", e.Progress().Filled.String())
+
+	assert.False(s.T(), s.step(e, start.Add(10*time.Minute)))
+	require.Len(s.T(), s.placed, 2)
+	assert.Equal(s.T(), "33", s.placed[1].Qty.String())
+	s.fill("child_2", "13", "12")
+
+	// the unfilled part of a slice rolls into the next one
+	assert.False(s.T(), s.step(e, start.Add(20*time.Minute)))
+	assert.Equal(s.T(), []string{"child_2"}, s.canceled)
+	require.Len(s.T(), s.placed, 3)
+	assert.Equal(s.T(), "54", s.placed[2].Qty.String())
+	s.fill("child_3", "54", "11")
+
+	assert.True(s.T(), s.step(e, start.Add(21*time.Minute)))
+	p := e.Progress()
+	assert.True(s.T(), p.Done)
+	assert.Equal(s.T(), "100", p.Filled.String())
+	assert.Equal(s.T(), "10.8", p.AvgPrice.String())
+	assert.Equal(s.T(), 3, p.Children)
+	assert.Equal(s.T(), p, s.progress[len(s.progress)-1])
+}
+
+func (s *ExecutionTestSuite) TestTWAPEnds() {
+	limit := dec("10")
+	e := s.execution(Parent{Qty: dec("100"), Duration: 30 * time.Minute, LimitPrice: &limit}, TWAP(2))
+
+	s.step(e, start)
+	require.Len(s.T(), s.placed, 1)
+	assert.Equal(s.T(), alpaca.Limit, s.placed[0].Type)
+	assert.Equal(s.T(), "10", s.placed[0].LimitPrice.String())
+	s.fill("child_1", "20", "10")
+
+	assert.True(s.T(), s.step(e, start.Add(30*time.Minute)))
+	assert.Equal(s.T(), []string{"child_1"}, s.canceled)
+	p := e.Progress()
+	assert.True(s.T(), p.Done)
+	assert.Equal(s.T(), "80", p.Remaining.String())
+	assert.True(s.T(), p.Working.IsZero())
+}
+
+func (s *ExecutionTestSuite) TestVWAP() {
+	e := s.execution(Parent{Qty: dec("100")}, VWAP(0.1, time.Minute))
+	trade := func(symbol string, size uint32) {
+		e.HandleTrade(stream.Trade{Symbol: symbol, Size: size})
+	}
+
+	trade("AAPL", 100)
+	s.step(e, start)
+	require.Len(s.T(), s.placed, 1)
+	assert.Equal(s.T(), "10", s.placed[0].Qty.String())
+	s.fill("child_1", "10", "10")
+
+	// the volume is only taken at each interval
+	trade("AAPL", 300)
+	trade("MSFT", 1000)
+	s.step(e, start.Add(30*time.Second))
+	assert.Len(s.T(), s.placed, 1)
+	s.step(e, start.Add(time.Minute))
+	require.Len(s.T(), s.placed, 2)
+	assert.Equal(s.T(), "30", s.placed[1].Qty.String())
+
+	// capped at the parent quantity
+	trade("AAPL", 10000)
+	s.step(e, start.Add(2*time.Minute))
+	assert.Equal(s.T(), []string{"child_2"}, s.canceled)
+	require.Len(s.T(), s.placed, 3)
+	assert.Equal(s.T(), "90", s.placed[2].Qty.String())
+}
+
+func (s *ExecutionTestSuite) TestIceberg() {
+	limit := dec("10")
+	e := s.execution(Parent{Qty: dec("250"), LimitPrice: &limit}, Iceberg(dec("100")))
+
+	s.step(e, start)
+	require.Len(s.T(), s.placed, 1)
+	assert.Equal(s.T(), "100", s.placed[0].Qty.String())
+
+	// a partial fill is left working
+	s.fill("child_1", "40", "10")
+	s.step(e, start.Add(time.Second))
+	assert.Len(s.T(), s.placed, 1)
+	assert.Empty(s.T(), s.canceled)
+
+	s.fill("child_1", "60", "10")
+	s.step(e, start.Add(2*time.Second))
+	require.Len(s.T(), s.placed, 2)
+	s.fill("child_2", "100", "10")
+	s.step(e, start.Add(3*time.Second))
+	require.Len(s.T(), s.placed, 3)
+	assert.Equal(s.T(), "50", s.placed[2].Qty.String())
+	s.fill("child_3", "50", "10")
+	assert.True(s.T(), s.step(e, start.Add(4*time.Second)))
+	assert.Equal(s.T(), "250", e.Progress().Filled.String())
+}
+
+func (s *ExecutionTestSuite) TestAmend() {
+	limit := dec("10")
+	e := s.execution(Parent{Qty: dec("250"), LimitPrice: &limit}, Iceberg(dec("100")))
+	s.step(e, start)
+	s.fill("child_1", "40", "10")
+
+	// a new limit price replaces the working child order
+	higher := dec("10.5")
+	require.NoError(s.T(), e.Amend(dec("250"), &higher))
+	s.step(e, start.Add(time.Second))
+	assert.Empty(s.T(), s.canceled)
+	assert.Equal(s.T(), alpaca.OrderReplaced, s.orders["child_1"].Status)
+	s.fill("child_1_replaced", "60", "10.5")
+	s.step(e, start.Add(2*time.Second))
+	p := e.Progress()
+	assert.Equal(s.T(), "100", p.Filled.String())
+	assert.Equal(s.T(), "10.3", p.AvgPrice.String())
+
+	// a lower quantity shrinks the working child order
+	require.NoError(s.T(), e.Amend(dec("150"), &higher))
+	s.step(e, start.Add(3*time.Second))
+	assert.Equal(s.T(), []string{"child_2"}, s.canceled)
+	require.Len(s.T(), s.placed, 3)
+	assert.Equal(s.T(), "50", s.placed[2].Qty.String())
+
+	assert.Equal(s.T(), ErrAmendBelowFilled, e.Amend(dec("50"), &higher))
+	assert.Error(s.T(), e.Amend(dec("150"), nil))
+}
+
+func (s *ExecutionTestSuite) TestCancel() {
+	e := s.execution(Parent{Qty: dec("100"), Duration: time.Hour}, TWAP(10))
+	errs := make(chan error)
+	go func() { errs <- e.Run(context.Background()) }()
+	e.Cancel()
+	e.Cancel()
+	assert.Equal(s.T(), ErrCanceled, <-errs)
+	assert.Equal(s.T(), []string{"child_1"}, s.canceled)
+	assert.True(s.T(), e.Progress().Done)
+}
+
+func (s *ExecutionTestSuite) TestNew() {
+	limit := dec("10")
+	for _, tc := range []struct {
+		parent   Parent
+		strategy Strategy
+	}{
+		{Parent{Symbol: "AAPL", Side: alpaca.Buy, Qty: dec("100")}, TWAP(4)},
+		{Parent{Symbol: "AAPL", Side: alpaca.Buy, Qty: dec("100"), Duration: time.Hour}, TWAP(0)},
+		{Parent{Symbol: "AAPL", Side: alpaca.Buy, Qty: dec("100")}, Iceberg(dec("10"))},
+		{Parent{Symbol: "AAPL", Side: alpaca.Buy, Qty: dec("100")}, VWAP(1.5, time.Minute)},
+		{Parent{Symbol: "AAPL", Side: "hold", Qty: dec("100"), LimitPrice: &limit}, Iceberg(dec("10"))},
+		{Parent{Symbol: "AAPL", Side: alpaca.Sell, LimitPrice: &limit}, Iceberg(dec("10"))},
+	} {
+		_, err := New(s.mock, tc.parent, tc.strategy)
+		assert.Error(s.T(), err)
+	}
+}
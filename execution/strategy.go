@@ -0,0 +1,122 @@
+package execution
+
+import (
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// Strategy decides how much of the parent order is worked at a time. It is
+// created by TWAP, VWAP or Iceberg, and is used by a single execution.
+type Strategy interface {
+	validate(parent Parent) error
+	// target returns the quantity that should be filled or working
+	target(s state) decimal.Decimal
+}
+
+// state is what a strategy decides on
+type state struct {
+	parent  Parent
+	filled  decimal.Decimal
+	working decimal.Decimal
+	// volume is the volume traded in the symbol since the start
+	volume  decimal.Decimal
+	elapsed time.Duration
+}
+
+// round rounds qty down to whole shares, unless the parent order is fractional
+func (s state) round(qty decimal.Decimal) decimal.Decimal {
+	if s.parent.Qty.Equal(s.parent.Qty.Truncate(0)) {
+		return qty.Floor()
+	}
+	return qty
+}
+
+type twap struct {
+	slices int
+}
+
+// TWAP splits the parent order into slices of equal quantity, one at the
+// start of each equal part of its duration. What a slice does not fill is
+// added to the next one.
+func TWAP(slices int) Strategy {
+	return &twap{slices: slices}
+}
+
+func (t *twap) validate(parent Parent) error {
+	if t.slices <= 0 {
+		return errors.New("non-positive number of slices")
+	}
+	if parent.Duration <= 0 {
+		return errors.New("TWAP requires a duration")
+	}
+	return nil
+}
+
+func (t *twap) target(s state) decimal.Decimal {
+	slice := int64(s.elapsed/(s.parent.Duration/time.Duration(t.slices))) + 1
+	if slice >= int64(t.slices) {
+		return s.parent.Qty
+	}
+	return s.round(s.parent.Qty.Mul(decimal.New(slice, 0)).Div(decimal.New(int64(t.slices), 0)))
+}
+
+type vwap struct {
+	rate     decimal.Decimal
+	interval time.Duration
+	// period is the interval of elapsed the volume was taken at
+	period time.Duration
+	volume decimal.Decimal
+}
+
+// VWAP participates in rate (e.g. 0.1 for 10%) of the volume traded in the
+// symbol, counted by Execution.HandleTrade, so that the order follows the
+// volume of the market. The quantity worked is updated at every interval.
+func VWAP(rate float64, interval time.Duration) Strategy {
+	return &vwap{rate: decimal.NewFromFloat(rate), interval: interval, period: -1}
+}
+
+func (v *vwap) validate(parent Parent) error {
+	if !v.rate.IsPositive() || v.rate.GreaterThan(decimal.New(1, 0)) {
+		return errors.New("participation rate not in (0, 1]")
+	}
+	if v.interval <= 0 {
+		return errors.New("non-positive interval")
+	}
+	return nil
+}
+
+func (v *vwap) target(s state) decimal.Decimal {
+	if period := s.elapsed / v.interval; period != v.period {
+		v.period, v.volume = period, s.volume
+	}
+	return s.round(v.volume.Mul(v.rate))
+}
+
+type iceberg struct {
+	display decimal.Decimal
+}
+
+// Iceberg shows display of the parent order at a time, as limit orders at
+// its limit price, placing the next one once the shown one is filled.
+func Iceberg(display decimal.Decimal) Strategy {
+	return &iceberg{display: display}
+}
+
+func (i *iceberg) validate(parent Parent) error {
+	if !i.display.IsPositive() {
+		return errors.New("non-positive display size")
+	}
+	if parent.LimitPrice == nil {
+		return errors.New("iceberg requires a limit price")
+	}
+	return nil
+}
+
+func (i *iceberg) target(s state) decimal.Decimal {
+	if s.working.IsPositive() {
+		return s.filled.Add(s.working)
+	}
+	return s.filled.Add(i.display)
+}
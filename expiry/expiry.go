@@ -0,0 +1,245 @@
+// Package expiry cancels the orders not filled within a time to live, finer
+// than the day and GTC times in force, e.g.
+//
+//	expirer := expiry.New(client)
+//	defer expirer.Close()
+//	// cancels the orders of a previous run that expire later
+//	if err := expirer.Reconcile(); err != nil {
+//		panic(err)
+//	}
+//	stream.SubscribeTradeUpdates(expirer.HandleTradeUpdate)
+//	order, err := expirer.PlaceOrderWithTTL(req, 30*time.Second)
+//
+// The deadline of an order is encoded in its client order ID, so a restarted
+// process finds the deadlines of the orders still open with Reconcile.
+package expiry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// prefix starts the client order IDs carrying a deadline
+const prefix = "ttl-"
+
+// ClientOrderID returns clientOrderID tagged with the deadline of the
+// order, e.g. ttl-kuqf7ar4-abc for abc.
+func ClientOrderID(deadline time.Time, clientOrderID string) string {
+	return prefix + strconv.FormatInt(deadline.UnixMilli(), 36) + "-" + clientOrderID
+}
+
+// Deadline returns the deadline a client order ID was tagged with by
+// ClientOrderID, and false if it has none.
+func Deadline(clientOrderID string) (time.Time, bool) {
+	if !strings.HasPrefix(clientOrderID, prefix) {
+		return time.Time{}, false
+	}
+	encoded := strings.TrimPrefix(clientOrderID, prefix)
+	if i := strings.IndexByte(encoded, '-'); i >= 0 {
+		encoded = encoded[:i]
+	}
+	ms, err := strconv.ParseInt(encoded, 36, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(ms), true
+}
+
+// Option configures an Expirer
+type Option func(e *Expirer)
+
+// WithClock sets the clock the deadlines are waited on, clock.Real by
+// default.
+func WithClock(c clock.Clock) Option {
+	return func(e *Expirer) {
+		e.clock = c
+	}
+}
+
+// OnExpire sets the callback called with the ID of each order canceled
+// because it expired.
+func OnExpire(callback func(orderID string)) Option {
+	return func(e *Expirer) {
+		e.onExpire = callback
+	}
+}
+
+// OnError sets the callback called when an expired order could not be
+// canceled. The orders filled or canceled in the meantime are not errors.
+func OnError(callback func(orderID string, err error)) Option {
+	return func(e *Expirer) {
+		e.onError = callback
+	}
+}
+
+// Expirer places orders with a time to live and cancels them once it is
+// over. The cancels are requested from the time the order was placed, so
+// an order may still fill while its cancel is in flight.
+type Expirer struct {
+	client   alpaca.TradingClient
+	clock    clock.Clock
+	onExpire func(orderID string)
+	onError  func(orderID string, err error)
+
+	mu sync.Mutex
+	// pending are the stop channels of the orders waiting for their
+	// deadline
+	pending map[string]chan struct{}
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// New creates an expirer placing and canceling the orders with client.
+func New(client alpaca.TradingClient, opts ...Option) *Expirer {
+	e := &Expirer{
+		client:  client,
+		clock:   clock.Real,
+		pending: map[string]chan struct{}{},
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// PlaceOrderWithTTL places the order and cancels it if it is still open
+// after ttl. The client order ID of the request, or a random one if it has
+// none, is tagged with the deadline.
+func (e *Expirer) PlaceOrderWithTTL(req alpaca.PlaceOrderRequest, ttl time.Duration) (*alpaca.Order, error) {
+	deadline := e.clock.Now().Add(ttl)
+	if req.ClientOrderID == "" {
+		req.ClientOrderID = randomID()
+	}
+	req.ClientOrderID = ClientOrderID(deadline, req.ClientOrderID)
+	order, err := e.client.PlaceOrder(req)
+	if err != nil {
+		return nil, err
+	}
+	e.schedule(order.ID, deadline)
+	return order, nil
+}
+
+// Reconcile schedules the cancels of the open orders tagged with a
+// deadline, e.g. placed before a restart, canceling the overdue ones now.
+func (e *Expirer) Reconcile() error {
+	status := "open"
+	limit := 500
+	orders, err := e.client.ListOrders(&status, nil, &limit, nil)
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		if deadline, ok := Deadline(order.ClientOrderID); ok {
+			e.schedule(order.ID, deadline)
+		}
+	}
+	return nil
+}
+
+// HandleTradeUpdate forgets the orders that reached a final state. It can
+// be passed to stream.SubscribeTradeUpdates.
+func (e *Expirer) HandleTradeUpdate(update alpaca.TradeUpdate) {
+	if update.Event.IsTerminal() {
+		e.forget(update.Order.ID)
+	}
+}
+
+// HandleTradeEvent forgets the orders that reached a final state. It can
+// be passed to stream.SubscribeTradeEvents.
+func (e *Expirer) HandleTradeEvent(event stream.TradeUpdateEvent) {
+	if event.GetEvent().IsTerminal() {
+		e.forget(event.GetOrder().ID)
+	}
+}
+
+// Pending returns the number of orders waiting for their deadline.
+func (e *Expirer) Pending() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.pending)
+}
+
+// Close stops waiting for the deadlines. The orders placed are left open,
+// for Reconcile to pick them up after a restart.
+func (e *Expirer) Close() {
+	e.mu.Lock()
+	e.closed = true
+	for id, stop := range e.pending {
+		close(stop)
+		delete(e.pending, id)
+	}
+	e.mu.Unlock()
+	e.wg.Wait()
+}
+
+// schedule cancels the order at deadline, unless it is already scheduled
+func (e *Expirer) schedule(orderID string, deadline time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.pending[orderID]; ok || e.closed {
+		return
+	}
+	stop := make(chan struct{})
+	e.pending[orderID] = stop
+	timer := e.clock.NewTimer(deadline.Sub(e.clock.Now()))
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		select {
+		case <-stop:
+			timer.Stop()
+		case <-timer.C():
+			e.expire(orderID, stop)
+		}
+	}()
+}
+
+// expire cancels the order, unless it was forgotten in the meantime
+func (e *Expirer) expire(orderID string, stop chan struct{}) {
+	e.mu.Lock()
+	if e.pending[orderID] != stop {
+		e.mu.Unlock()
+		return
+	}
+	delete(e.pending, orderID)
+	e.mu.Unlock()
+
+	err := e.client.CancelOrder(orderID)
+	switch {
+	case err == nil:
+		if e.onExpire != nil {
+			e.onExpire(orderID)
+		}
+	case errors.Is(err, alpaca.ErrOrderNotCancelable):
+	default:
+		if e.onError != nil {
+			e.onError(orderID, err)
+		}
+	}
+}
+
+// forget stops waiting for the deadline of the order
+func (e *Expirer) forget(orderID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if stop, ok := e.pending[orderID]; ok {
+		close(stop)
+		delete(e.pending, orderID)
+	}
+}
+
+func randomID() string {
+	var b [12]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
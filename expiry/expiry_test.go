@@ -0,0 +1,89 @@
+package expiry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientOrderID(t *testing.T) {
+	deadline := time.UnixMilli(1634218230500)
+	id := ClientOrderID(deadline, "abc-1")
+	assert.True(t, len(id) < 48)
+	got, ok := Deadline(id)
+	require.True(t, ok)
+	assert.True(t, got.Equal(deadline))
+
+	_, ok = Deadline("abc-1")
+	assert.False(t, ok)
+	_, ok = Deadline("ttl-!!-abc")
+	assert.False(t, ok)
+}
+
+func TestExpirer(t *testing.T) {
+	start := time.Date(2021, 10, 14, 13, 30, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+	canceled := make(chan string, 10)
+	var placed []alpaca.PlaceOrderRequest
+	mock := &alpacatest.MockClient{
+		PlaceOrderFunc: func(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+			placed = append(placed, req)
+			return &alpaca.Order{ID: req.ClientOrderID[len(req.ClientOrderID)-1:], ClientOrderID: req.ClientOrderID}, nil
+		},
+		CancelOrderFunc: func(orderID string) error {
+			canceled <- orderID
+			if orderID == "2" {
+				return alpaca.ErrOrderNotCancelable
+			}
+			return nil
+		},
+		ListOrdersFunc: func(status *string, until *time.Time, limit *int, nested *bool) ([]alpaca.Order, error) {
+			return []alpaca.Order{
+				{ID: "3", ClientOrderID: ClientOrderID(start.Add(-time.Second), "3")},
+				{ID: "4", ClientOrderID: "4"},
+			}, nil
+		},
+	}
+	expired := make(chan string, 10)
+	e := New(mock, WithClock(fake), OnExpire(func(orderID string) { expired <- orderID }))
+	defer e.Close()
+
+	_, err := e.PlaceOrderWithTTL(alpaca.PlaceOrderRequest{ClientOrderID: "1"}, 30*time.Second)
+	require.NoError(t, err)
+	deadline, ok := Deadline(placed[0].ClientOrderID)
+	require.True(t, ok)
+	assert.True(t, deadline.Equal(start.Add(30*time.Second)))
+	_, err = e.PlaceOrderWithTTL(alpaca.PlaceOrderRequest{ClientOrderID: "2"}, time.Minute)
+	require.NoError(t, err)
+	_, err = e.PlaceOrderWithTTL(alpaca.PlaceOrderRequest{ClientOrderID: "5"}, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 3, e.Pending())
+
+	// order 5 filled before its deadline
+	e.HandleTradeUpdate(alpaca.TradeUpdate{Event: alpaca.EventFill, Order: alpaca.Order{ID: "5"}})
+	assert.Equal(t, 2, e.Pending())
+
+	fake.BlockUntil(2)
+	fake.Advance(30 * time.Second)
+	assert.Equal(t, "1", <-canceled)
+	assert.Equal(t, "1", <-expired)
+
+	// order 2 filled while its cancel was in flight
+	fake.BlockUntil(1)
+	fake.Advance(30 * time.Second)
+	assert.Equal(t, "2", <-canceled)
+
+	// the overdue order of a previous run is canceled at once
+	require.NoError(t, e.Reconcile())
+	assert.Equal(t, 1, e.Pending())
+	fake.BlockUntil(1)
+	fake.Advance(0)
+	assert.Equal(t, "3", <-canceled)
+	assert.Equal(t, "3", <-expired)
+	assert.Empty(t, expired)
+}
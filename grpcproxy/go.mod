@@ -0,0 +1,12 @@
+module github.com/market-development-strategy/alpaca-trade-api-go/grpcproxy
+
+go 1.16
+
+require (
+	github.com/market-development-strategy/alpaca-trade-api-go v0.0.0
+	github.com/stretchr/testify v1.7.0
+	google.golang.org/grpc v1.43.0
+	google.golang.org/protobuf v1.27.1
+)
+
+replace github.com/market-development-strategy/alpaca-trade-api-go => ../
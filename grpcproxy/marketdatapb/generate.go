@@ -0,0 +1,5 @@
+// Package marketdatapb is the protobuf and gRPC code of marketdata.proto,
+// the schema non-Go clients of the proxy generate their code from.
+package marketdatapb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative marketdata.proto
@@ -0,0 +1,136 @@
+// Package grpcproxy serves the data stream over gRPC, so that several
+// services, in any language, share one connection to Alpaca and one symbol
+// limit. The service is defined in marketdatapb/marketdata.proto.
+//
+//	lis, err := net.Listen("tcp", ":50051")
+//	...
+//	srv := grpc.NewServer()
+//	marketdatapb.RegisterMarketDataServer(srv, grpcproxy.NewServer(hub.New(hub.DefaultUpstream)))
+//	srv.Serve(lis)
+//
+// It is a separate module so that the SDK does not depend on gRPC.
+package grpcproxy
+
+import (
+	"errors"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/grpcproxy/marketdatapb"
+	"github.com/market-development-strategy/alpaca-trade-api-go/hub"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Option configures a Server
+type Option func(s *Server)
+
+// WithBufferSize sets how many events are buffered per client before it is
+// disconnected for being too slow, 10000 by default.
+func WithBufferSize(size int) Option {
+	return func(s *Server) {
+		s.bufferSize = size
+	}
+}
+
+// Server implements the MarketData service on top of a hub, each client
+// being a subscriber of the hub.
+type Server struct {
+	marketdatapb.UnimplementedMarketDataServer
+
+	hub        *hub.Hub
+	bufferSize int
+}
+
+// NewServer creates a server streaming the events of h.
+func NewServer(h *hub.Hub, opts ...Option) *Server {
+	s := &Server{hub: h, bufferSize: 10000}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Stream streams the events of the symbols of req until the client cancels it.
+func (s *Server) Stream(req *marketdatapb.StreamRequest, srv marketdatapb.MarketData_StreamServer) error {
+	if len(req.Trades) == 0 && len(req.Quotes) == 0 && len(req.Bars) == 0 {
+		return status.Error(codes.InvalidArgument, "no trades, quotes or bars requested")
+	}
+	sub, err := s.hub.Subscribe(hub.Subscription{
+		Trades: req.Trades,
+		Quotes: req.Quotes,
+		Bars:   req.Bars,
+	}, s.bufferSize)
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	defer sub.Close()
+
+	for {
+		select {
+		case <-srv.Context().Done():
+			return nil
+		case e, ok := <-sub.Events():
+			if !ok {
+				if errors.Is(sub.Err(), hub.ErrSlowSubscriber) {
+					return status.Error(codes.ResourceExhausted, sub.Err().Error())
+				}
+				return status.Error(codes.Unavailable, sub.Err().Error())
+			}
+			if err := srv.Send(toProto(e)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toProto(e hub.Event) *marketdatapb.Event {
+	switch {
+	case e.Trade != nil:
+		return &marketdatapb.Event{Event: &marketdatapb.Event_Trade{Trade: tradeToProto(*e.Trade)}}
+	case e.Quote != nil:
+		return &marketdatapb.Event{Event: &marketdatapb.Event_Quote{Quote: quoteToProto(*e.Quote)}}
+	default:
+		return &marketdatapb.Event{Event: &marketdatapb.Event_Bar{Bar: barToProto(*e.Bar)}}
+	}
+}
+
+func tradeToProto(t stream.Trade) *marketdatapb.Trade {
+	return &marketdatapb.Trade{
+		Id:         t.ID,
+		Symbol:     t.Symbol,
+		Exchange:   string(t.Exchange),
+		Price:      t.Price,
+		Size:       t.Size,
+		Timestamp:  timestamppb.New(t.Timestamp),
+		Conditions: t.Conditions,
+		Tape:       string(t.Tape),
+	}
+}
+
+func quoteToProto(q stream.Quote) *marketdatapb.Quote {
+	return &marketdatapb.Quote{
+		Symbol:      q.Symbol,
+		BidExchange: string(q.BidExchange),
+		BidPrice:    q.BidPrice,
+		BidSize:     q.BidSize,
+		AskExchange: string(q.AskExchange),
+		AskPrice:    q.AskPrice,
+		AskSize:     q.AskSize,
+		Timestamp:   timestamppb.New(q.Timestamp),
+		Conditions:  q.Conditions,
+		Tape:        string(q.Tape),
+	}
+}
+
+func barToProto(b stream.Bar) *marketdatapb.Bar {
+	return &marketdatapb.Bar{
+		Symbol:    b.Symbol,
+		Open:      b.Open,
+		High:      b.High,
+		Low:       b.Low,
+		Close:     b.Close,
+		Volume:    b.Volume,
+		Timestamp: timestamppb.New(b.Timestamp),
+	}
+}
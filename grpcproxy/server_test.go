@@ -0,0 +1,121 @@
+package grpcproxy
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/grpcproxy/marketdatapb"
+	"github.com/market-development-strategy/alpaca-trade-api-go/hub"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeUpstream lets the test emit the events of the symbols the hub
+// subscribed to
+type fakeUpstream struct {
+	mu         sync.Mutex
+	trade      func(trade stream.Trade)
+	bar        func(bar stream.Bar)
+	subscribed map[string]bool
+}
+
+func (u *fakeUpstream) subscribe(kind string, symbols []string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, symbol := range symbols {
+		u.subscribed[kind+" "+symbol] = true
+	}
+}
+
+func (u *fakeUpstream) isSubscribed(kind, symbol string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.subscribed[kind+" "+symbol]
+}
+
+func (u *fakeUpstream) SubscribeTrades(handler func(trade stream.Trade), symbols ...string) error {
+	u.mu.Lock()
+	u.trade = handler
+	u.mu.Unlock()
+	u.subscribe("trades", symbols)
+	return nil
+}
+
+func (u *fakeUpstream) SubscribeQuotes(handler func(quote stream.Quote), symbols ...string) error {
+	u.subscribe("quotes", symbols)
+	return nil
+}
+
+func (u *fakeUpstream) SubscribeBars(handler func(bar stream.Bar), symbols ...string) error {
+	u.mu.Lock()
+	u.bar = handler
+	u.mu.Unlock()
+	u.subscribe("bars", symbols)
+	return nil
+}
+
+func (u *fakeUpstream) UnsubscribeTrades(symbols ...string) error { return nil }
+func (u *fakeUpstream) UnsubscribeQuotes(symbols ...string) error { return nil }
+func (u *fakeUpstream) UnsubscribeBars(symbols ...string) error   { return nil }
+
+func TestStreamFiltersPerClient(t *testing.T) {
+	upstream := &fakeUpstream{subscribed: map[string]bool{}}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	marketdatapb.RegisterMarketDataServer(srv, NewServer(hub.New(upstream)))
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, "bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	client := marketdatapb.NewMarketDataClient(conn)
+
+	aapl, err := client.Stream(ctx, &marketdatapb.StreamRequest{Trades: []string{"AAPL"}})
+	require.NoError(t, err)
+	all, err := client.Stream(ctx, &marketdatapb.StreamRequest{Trades: []string{"MSFT"}, Bars: []string{"*"}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return upstream.isSubscribed("trades", "AAPL") && upstream.isSubscribed("trades", "MSFT") &&
+			upstream.isSubscribed("bars", "*")
+	}, time.Second, time.Millisecond)
+
+	ts := time.Date(2021, 10, 14, 13, 30, 0, 0, time.UTC)
+	upstream.trade(stream.Trade{ID: 1, Symbol: "MSFT", Price: 300, Timestamp: ts})
+	upstream.trade(stream.Trade{ID: 2, Symbol: "AAPL", Price: 140, Timestamp: ts})
+	upstream.bar(stream.Bar{Symbol: "TSLA", Close: 800, Timestamp: ts})
+
+	// each client only receives the events of its symbols
+	e, err := aapl.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), e.GetTrade().GetId())
+	assert.Equal(t, "AAPL", e.GetTrade().GetSymbol())
+	assert.True(t, ts.Equal(e.GetTrade().GetTimestamp().AsTime()))
+
+	e, err = all.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "MSFT", e.GetTrade().GetSymbol())
+	e, err = all.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "TSLA", e.GetBar().GetSymbol())
+	assert.Equal(t, 800.0, e.GetBar().GetClose())
+
+	// a request without symbols is rejected
+	empty, err := client.Stream(ctx, &marketdatapb.StreamRequest{})
+	require.NoError(t, err)
+	_, err = empty.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,294 @@
+// Package hub shares one connection of the data stream between many
+// subscribers, each with its own trades, quotes and bars, so that several
+// consumers (e.g. the clients of the grpcproxy server) share one connection
+// and one symbol limit. A symbol is subscribed upstream while at least one
+// subscriber wants it.
+//
+//	h := hub.New(hub.DefaultUpstream)
+//	sub, err := h.Subscribe(hub.Subscription{Trades: []string{"AAPL"}}, 1000)
+//	...
+//	defer sub.Close()
+//	for e := range sub.Events() {
+//		...
+//	}
+package hub
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+var (
+	// ErrSlowSubscriber is the error of a subscriber that was dropped
+	// because it did not keep up with its events
+	ErrSlowSubscriber = errors.New("subscriber too slow")
+	// ErrClosed is the error of a subscriber that was closed
+	ErrClosed = errors.New("subscriber closed")
+)
+
+// Upstream is the data stream the hub subscribes to. The stream package
+// implements it, see DefaultUpstream.
+type Upstream interface {
+	SubscribeTrades(handler func(trade stream.Trade), symbols ...string) error
+	SubscribeQuotes(handler func(quote stream.Quote), symbols ...string) error
+	SubscribeBars(handler func(bar stream.Bar), symbols ...string) error
+	UnsubscribeTrades(symbols ...string) error
+	UnsubscribeQuotes(symbols ...string) error
+	UnsubscribeBars(symbols ...string) error
+}
+
+type defaultUpstream struct{}
+
+func (defaultUpstream) SubscribeTrades(handler func(trade stream.Trade), symbols ...string) error {
+	return stream.SubscribeTrades(handler, symbols...)
+}
+
+func (defaultUpstream) SubscribeQuotes(handler func(quote stream.Quote), symbols ...string) error {
+	return stream.SubscribeQuotes(handler, symbols...)
+}
+
+func (defaultUpstream) SubscribeBars(handler func(bar stream.Bar), symbols ...string) error {
+	return stream.SubscribeBars(handler, symbols...)
+}
+
+func (defaultUpstream) UnsubscribeTrades(symbols ...string) error {
+	return stream.UnsubscribeTrades(symbols...)
+}
+
+func (defaultUpstream) UnsubscribeQuotes(symbols ...string) error {
+	return stream.UnsubscribeQuotes(symbols...)
+}
+
+func (defaultUpstream) UnsubscribeBars(symbols ...string) error {
+	return stream.UnsubscribeBars(symbols...)
+}
+
+// DefaultUpstream is the data stream of the stream package
+var DefaultUpstream Upstream = defaultUpstream{}
+
+// Subscription is the symbols of the trades, quotes and bars a subscriber
+// wants, "*" for all of them
+type Subscription struct {
+	Trades []string
+	Quotes []string
+	Bars   []string
+}
+
+// Event is a trade, quote or bar, only one of which is set
+type Event struct {
+	Trade *stream.Trade
+	Quote *stream.Quote
+	Bar   *stream.Bar
+}
+
+const (
+	trades = iota
+	quotes
+	bars
+	kinds
+)
+
+// Hub dispatches the events of its upstream to its subscribers.
+type Hub struct {
+	upstream Upstream
+
+	// mu guards the subscribers. It is never held while calling upstream,
+	// which calls the dispatch handlers with its own locks held.
+	mu          sync.Mutex
+	subscribers map[*Subscriber]struct{}
+
+	// refsMu serializes the upstream subscriptions. refs counts the
+	// subscribers of each symbol of each kind.
+	refsMu sync.Mutex
+	refs   [kinds]map[string]int
+}
+
+// New creates a hub subscribing to upstream.
+func New(upstream Upstream) *Hub {
+	h := &Hub{
+		upstream:    upstream,
+		subscribers: map[*Subscriber]struct{}{},
+	}
+	for kind := range h.refs {
+		h.refs[kind] = map[string]int{}
+	}
+	return h
+}
+
+// Subscriber receives the events of its subscription.
+type Subscriber struct {
+	hub     *Hub
+	symbols [kinds]map[string]bool
+	events  chan Event
+	err     error
+}
+
+// Subscribe subscribes to the events of sub, buffering up to buffer of them.
+// A subscriber whose buffer is full is dropped with ErrSlowSubscriber, so
+// that it does not hold back the other subscribers.
+func (h *Hub) Subscribe(sub Subscription, buffer int) (*Subscriber, error) {
+	s := &Subscriber{hub: h, events: make(chan Event, buffer)}
+	for kind, symbols := range [kinds][]string{sub.Trades, sub.Quotes, sub.Bars} {
+		s.symbols[kind] = map[string]bool{}
+		for _, symbol := range symbols {
+			s.symbols[kind][symbol] = true
+		}
+	}
+
+	if err := h.ref(s); err != nil {
+		return nil, err
+	}
+	h.mu.Lock()
+	h.subscribers[s] = struct{}{}
+	h.mu.Unlock()
+	return s, nil
+}
+
+// ref subscribes upstream to the symbols of s no other subscriber wants yet
+func (h *Hub) ref(s *Subscriber) error {
+	h.refsMu.Lock()
+	defer h.refsMu.Unlock()
+
+	var added [kinds][]string
+	for kind := range s.symbols {
+		for symbol := range s.symbols[kind] {
+			if h.refs[kind][symbol] == 0 {
+				added[kind] = append(added[kind], symbol)
+			}
+		}
+	}
+	if err := h.subscribeUpstream(added); err != nil {
+		return err
+	}
+	for kind := range s.symbols {
+		for symbol := range s.symbols[kind] {
+			h.refs[kind][symbol]++
+		}
+	}
+	return nil
+}
+
+// unref unsubscribes upstream from the symbols of s no other subscriber wants
+func (h *Hub) unref(s *Subscriber) error {
+	h.refsMu.Lock()
+	defer h.refsMu.Unlock()
+
+	var removed [kinds][]string
+	for kind := range s.symbols {
+		for symbol := range s.symbols[kind] {
+			if h.refs[kind][symbol]--; h.refs[kind][symbol] == 0 {
+				delete(h.refs[kind], symbol)
+				removed[kind] = append(removed[kind], symbol)
+			}
+		}
+	}
+	return h.unsubscribeUpstream(removed)
+}
+
+func (h *Hub) subscribeUpstream(symbols [kinds][]string) error {
+	if len(symbols[trades]) > 0 {
+		if err := h.upstream.SubscribeTrades(h.dispatchTrade, symbols[trades]...); err != nil {
+			return err
+		}
+	}
+	if len(symbols[quotes]) > 0 {
+		if err := h.upstream.SubscribeQuotes(h.dispatchQuote, symbols[quotes]...); err != nil {
+			return err
+		}
+	}
+	if len(symbols[bars]) > 0 {
+		if err := h.upstream.SubscribeBars(h.dispatchBar, symbols[bars]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Hub) unsubscribeUpstream(symbols [kinds][]string) error {
+	if len(symbols[trades]) > 0 {
+		if err := h.upstream.UnsubscribeTrades(symbols[trades]...); err != nil {
+			return err
+		}
+	}
+	if len(symbols[quotes]) > 0 {
+		if err := h.upstream.UnsubscribeQuotes(symbols[quotes]...); err != nil {
+			return err
+		}
+	}
+	if len(symbols[bars]) > 0 {
+		if err := h.upstream.UnsubscribeBars(symbols[bars]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Hub) dispatchTrade(trade stream.Trade) {
+	h.dispatch(trades, trade.Symbol, Event{Trade: &trade})
+}
+
+func (h *Hub) dispatchQuote(quote stream.Quote) {
+	h.dispatch(quotes, quote.Symbol, Event{Quote: &quote})
+}
+
+func (h *Hub) dispatchBar(bar stream.Bar) {
+	h.dispatch(bars, bar.Symbol, Event{Bar: &bar})
+}
+
+func (h *Hub) dispatch(kind int, symbol string, e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscribers {
+		if !s.symbols[kind][symbol] && !s.symbols[kind]["*"] {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			if h.remove(s, ErrSlowSubscriber) {
+				// not from the handler, upstream may hold its locks
+				go h.unref(s)
+			}
+		}
+	}
+}
+
+// remove removes s and closes its events, with h.mu held. It returns
+// false if s was already removed.
+func (h *Hub) remove(s *Subscriber, err error) bool {
+	if _, ok := h.subscribers[s]; !ok {
+		return false
+	}
+	delete(h.subscribers, s)
+	s.err = err
+	close(s.events)
+	return true
+}
+
+// Events returns the channel of the events of the subscriber. It is closed
+// once the subscriber is closed or dropped, see Err.
+func (s *Subscriber) Events() <-chan Event {
+	return s.events
+}
+
+// Err returns why the events channel was closed: ErrClosed or
+// ErrSlowSubscriber. It returns nil while the channel is open.
+func (s *Subscriber) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	return s.err
+}
+
+// Close unsubscribes the subscriber. Upstream, the symbols no other
+// subscriber wants are unsubscribed from.
+func (s *Subscriber) Close() error {
+	s.hub.mu.Lock()
+	removed := s.hub.remove(s, ErrClosed)
+	s.hub.mu.Unlock()
+	if !removed {
+		return nil
+	}
+	return s.hub.unref(s)
+}
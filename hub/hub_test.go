@@ -0,0 +1,163 @@
+package hub
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeUpstream records the subscriptions of the hub
+type fakeUpstream struct {
+	mu     sync.Mutex
+	trade  func(trade stream.Trade)
+	quote  func(quote stream.Quote)
+	trades map[string]bool
+	quotes map[string]bool
+	bars   map[string]bool
+	calls  []string
+}
+
+func newFakeUpstream() *fakeUpstream {
+	return &fakeUpstream{trades: map[string]bool{}, quotes: map[string]bool{}, bars: map[string]bool{}}
+}
+
+func (u *fakeUpstream) record(call string, subscribed map[string]bool, on bool, symbols []string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	sorted := append([]string(nil), symbols...)
+	sort.Strings(sorted)
+	for _, symbol := range sorted {
+		u.calls = append(u.calls, call+" "+symbol)
+		if on {
+			subscribed[symbol] = true
+		} else {
+			delete(subscribed, symbol)
+		}
+	}
+}
+
+func (u *fakeUpstream) SubscribeTrades(handler func(trade stream.Trade), symbols ...string) error {
+	u.trade = handler
+	u.record("+trades", u.trades, true, symbols)
+	return nil
+}
+
+func (u *fakeUpstream) SubscribeQuotes(handler func(quote stream.Quote), symbols ...string) error {
+	u.quote = handler
+	u.record("+quotes", u.quotes, true, symbols)
+	return nil
+}
+
+func (u *fakeUpstream) SubscribeBars(handler func(bar stream.Bar), symbols ...string) error {
+	u.record("+bars", u.bars, true, symbols)
+	return nil
+}
+
+func (u *fakeUpstream) UnsubscribeTrades(symbols ...string) error {
+	u.record("-trades", u.trades, false, symbols)
+	return nil
+}
+
+func (u *fakeUpstream) UnsubscribeQuotes(symbols ...string) error {
+	u.record("-quotes", u.quotes, false, symbols)
+	return nil
+}
+
+func (u *fakeUpstream) UnsubscribeBars(symbols ...string) error {
+	u.record("-bars", u.bars, false, symbols)
+	return nil
+}
+
+type HubTestSuite struct {
+	suite.Suite
+	upstream *fakeUpstream
+	hub      *Hub
+}
+
+func TestHubTestSuite(t *testing.T) {
+	suite.Run(t, new(HubTestSuite))
+}
+
+func (s *HubTestSuite) SetupTest() {
+	s.upstream = newFakeUpstream()
+	s.hub = New(s.upstream)
+}
+
+func drain(sub *Subscriber) []Event {
+	var events []Event
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				return events
+			}
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func (s *HubTestSuite) TestSharedSubscriptions() {
+	a, err := s.hub.Subscribe(Subscription{Trades: []string{"AAPL", "MSFT"}, Quotes: []string{"AAPL"}}, 10)
+	require.NoError(s.T(), err)
+	b, err := s.hub.Subscribe(Subscription{Trades: []string{"AAPL", "TSLA"}}, 10)
+	require.NoError(s.T(), err)
+	// AAPL is only subscribed upstream once
+	assert.Equal(s.T(), []string{"+trades AAPL", "+trades MSFT", "+quotes AAPL", "+trades TSLA"}, s.upstream.calls)
+
+	s.upstream.trade(stream.Trade{Symbol: "AAPL", Price: 1})
+	s.upstream.trade(stream.Trade{Symbol: "TSLA", Price: 2})
+	s.upstream.quote(stream.Quote{Symbol: "AAPL", BidPrice: 3})
+
+	events := drain(a)
+	require.Len(s.T(), events, 2)
+	assert.Equal(s.T(), 1.0, events[0].Trade.Price)
+	assert.Equal(s.T(), 3.0, events[1].Quote.BidPrice)
+	events = drain(b)
+	require.Len(s.T(), events, 2)
+	assert.Equal(s.T(), "AAPL", events[0].Trade.Symbol)
+	assert.Equal(s.T(), "TSLA", events[1].Trade.Symbol)
+
+	// the symbols b still wants stay subscribed upstream
+	require.NoError(s.T(), a.Close())
+	assert.Equal(s.T(), map[string]bool{"AAPL": true, "TSLA": true}, s.upstream.trades)
+	assert.Empty(s.T(), s.upstream.quotes)
+	_, open := <-a.Events()
+	assert.False(s.T(), open)
+	assert.Equal(s.T(), ErrClosed, a.Err())
+	require.NoError(s.T(), a.Close())
+
+	require.NoError(s.T(), b.Close())
+	assert.Empty(s.T(), s.upstream.trades)
+}
+
+func (s *HubTestSuite) TestWildcard() {
+	all, err := s.hub.Subscribe(Subscription{Trades: []string{"*"}}, 10)
+	require.NoError(s.T(), err)
+	s.upstream.trade(stream.Trade{Symbol: "AAPL"})
+	s.upstream.trade(stream.Trade{Symbol: "MSFT"})
+	assert.Len(s.T(), drain(all), 2)
+	assert.Nil(s.T(), all.Err())
+}
+
+func (s *HubTestSuite) TestSlowSubscriber() {
+	slow, err := s.hub.Subscribe(Subscription{Trades: []string{"AAPL"}}, 1)
+	require.NoError(s.T(), err)
+	fast, err := s.hub.Subscribe(Subscription{Trades: []string{"AAPL"}}, 10)
+	require.NoError(s.T(), err)
+
+	s.upstream.trade(stream.Trade{Symbol: "AAPL"})
+	s.upstream.trade(stream.Trade{Symbol: "AAPL"})
+	assert.Len(s.T(), drain(fast), 2)
+	assert.Len(s.T(), drain(slow), 1)
+	_, open := <-slow.Events()
+	assert.False(s.T(), open)
+	assert.Equal(s.T(), ErrSlowSubscriber, slow.Err())
+	assert.True(s.T(), s.upstream.trades["AAPL"])
+}
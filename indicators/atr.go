@@ -0,0 +1,61 @@
+package indicators
+
+import (
+	"math"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// ATR is the average true range of Wilder. Unlike the other indicators it
+// needs the high, low and close of the bars.
+type ATR struct {
+	ranges    wilder
+	prevClose float64
+	started   bool
+}
+
+// NewATR creates the average true range of period bars.
+func NewATR(period int) *ATR {
+	return &ATR{ranges: wilder{period: period}}
+}
+
+// Add adds the high, low and close of the next bar
+func (a *ATR) Add(high, low, close float64) {
+	tr := high - low
+	if a.started {
+		tr = math.Max(tr, math.Max(math.Abs(high-a.prevClose), math.Abs(low-a.prevClose)))
+	}
+	a.prevClose, a.started = close, true
+	a.ranges.add(tr)
+}
+
+// AddBar adds a streamed bar, it can be passed to stream.SubscribeBars
+func (a *ATR) AddBar(bar stream.Bar) {
+	a.Add(bar.High, bar.Low, bar.Close)
+}
+
+// Ready returns true once period bars were added
+func (a *ATR) Ready() bool {
+	return a.ranges.ready()
+}
+
+// Value returns the current average true range
+func (a *ATR) Value() float64 {
+	if !a.Ready() {
+		return math.NaN()
+	}
+	return a.ranges.value
+}
+
+// ATRSeries returns the average true range of period bars after each of bars,
+// NaN until it is ready.
+func ATRSeries(bars []v2.Bar, period int) []float64 {
+	atr := NewATR(period)
+	series := make([]float64, len(bars))
+	for i, bar := range bars {
+		atr.Add(bar.High, bar.Low, bar.Close)
+		series[i] = atr.Value()
+	}
+	return series
+}
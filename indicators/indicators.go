@@ -0,0 +1,269 @@
+// Package indicators computes technical indicators on the bars of the SDK,
+// either on a whole slice of historical bars or incrementally, one value
+// at a time, from the streaming bar handlers.
+//
+//	rsi := indicators.NewRSI(14)
+//	stream.SubscribeBars(func(bar stream.Bar) {
+//		rsi.Add(bar.Close)
+//		if rsi.Ready() && rsi.Value() > 70 {
+//			// overbought
+//		}
+//	}, "AAPL")
+package indicators
+
+import (
+	"math"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+)
+
+// Indicator is an indicator computed from a series of values, e.g. closes
+type Indicator interface {
+	// Add adds the next value of the series
+	Add(value float64)
+	// Ready returns true once enough values were added for Value to be defined
+	Ready() bool
+	// Value returns the current value of the indicator, NaN until it is ready
+	Value() float64
+}
+
+// Closes returns the closes of bars
+func Closes(bars []v2.Bar) []float64 {
+	closes := make([]float64, len(bars))
+	for i, bar := range bars {
+		closes[i] = bar.Close
+	}
+	return closes
+}
+
+// Series adds values to ind and returns its value after each of them,
+// NaN until it is ready. e.g. Series(NewSMA(20), Closes(bars)).
+func Series(ind Indicator, values []float64) []float64 {
+	series := make([]float64, len(values))
+	for i, v := range values {
+		ind.Add(v)
+		series[i] = ind.Value()
+	}
+	return series
+}
+
+// SMA is the simple moving average
+type SMA struct {
+	period int
+	window []float64
+	next   int
+	count  int
+	sum    float64
+}
+
+// NewSMA creates the simple moving average of the last period values.
+func NewSMA(period int) *SMA {
+	return &SMA{period: period, window: make([]float64, period)}
+}
+
+// Add adds the next value of the series
+func (s *SMA) Add(value float64) {
+	s.sum += value - s.window[s.next]
+	s.window[s.next] = value
+	s.next = (s.next + 1) % s.period
+	if s.count < s.period {
+		s.count++
+	}
+}
+
+// Ready returns true once period values were added
+func (s *SMA) Ready() bool {
+	return s.count == s.period
+}
+
+// Value returns the average of the last period values
+func (s *SMA) Value() float64 {
+	if !s.Ready() {
+		return math.NaN()
+	}
+	return s.sum / float64(s.period)
+}
+
+// EMA is the exponential moving average
+type EMA struct {
+	sma   *SMA
+	k     float64
+	value float64
+}
+
+// NewEMA creates the exponential moving average with the smoothing factor
+// 2/(period+1), seeded with the simple average of the first period values.
+func NewEMA(period int) *EMA {
+	return &EMA{sma: NewSMA(period), k: 2 / float64(period+1)}
+}
+
+// Add adds the next value of the series
+func (e *EMA) Add(value float64) {
+	if !e.sma.Ready() {
+		e.sma.Add(value)
+		e.value = e.sma.Value()
+		return
+	}
+	e.value += e.k * (value - e.value)
+}
+
+// Ready returns true once period values were added
+func (e *EMA) Ready() bool {
+	return e.sma.Ready()
+}
+
+// Value returns the current average
+func (e *EMA) Value() float64 {
+	if !e.Ready() {
+		return math.NaN()
+	}
+	return e.value
+}
+
+// wilder is the smoothed moving average of Wilder, used by RSI and ATR
+type wilder struct {
+	period int
+	count  int
+	value  float64
+}
+
+func (w *wilder) add(value float64) {
+	if w.count < w.period {
+		w.count++
+		w.value += (value - w.value) / float64(w.count)
+		return
+	}
+	w.value += (value - w.value) / float64(w.period)
+}
+
+func (w *wilder) ready() bool {
+	return w.count == w.period
+}
+
+// RSI is the relative strength index of Wilder, between 0 and 100
+type RSI struct {
+	gains, losses wilder
+	prev          float64
+	started       bool
+}
+
+// NewRSI creates the relative strength index of period changes, ready
+// after period+1 values.
+func NewRSI(period int) *RSI {
+	return &RSI{gains: wilder{period: period}, losses: wilder{period: period}}
+}
+
+// Add adds the next value of the series
+func (r *RSI) Add(value float64) {
+	if !r.started {
+		r.prev, r.started = value, true
+		return
+	}
+	change := value - r.prev
+	r.prev = value
+	r.gains.add(math.Max(change, 0))
+	r.losses.add(math.Max(-change, 0))
+}
+
+// Ready returns true once period changes were added
+func (r *RSI) Ready() bool {
+	return r.gains.ready()
+}
+
+// Value returns the current index
+func (r *RSI) Value() float64 {
+	if !r.Ready() {
+		return math.NaN()
+	}
+	if r.losses.value == 0 {
+		if r.gains.value == 0 {
+			return 50
+		}
+		return 100
+	}
+	return 100 - 100/(1+r.gains.value/r.losses.value)
+}
+
+// MACD is the moving average convergence divergence
+type MACD struct {
+	fast, slow, signal *EMA
+}
+
+// NewMACD creates the difference of the fast and slow EMAs, with the
+// signal EMA of that difference, usually NewMACD(12, 26, 9).
+func NewMACD(fast, slow, signal int) *MACD {
+	return &MACD{fast: NewEMA(fast), slow: NewEMA(slow), signal: NewEMA(signal)}
+}
+
+// Add adds the next value of the series
+func (m *MACD) Add(value float64) {
+	m.fast.Add(value)
+	m.slow.Add(value)
+	if m.slow.Ready() && m.fast.Ready() {
+		m.signal.Add(m.fast.Value() - m.slow.Value())
+	}
+}
+
+// Ready returns true once the signal line is defined
+func (m *MACD) Ready() bool {
+	return m.signal.Ready()
+}
+
+// Value returns the MACD line
+func (m *MACD) Value() float64 {
+	if !m.Ready() {
+		return math.NaN()
+	}
+	return m.fast.Value() - m.slow.Value()
+}
+
+// Signal returns the signal line
+func (m *MACD) Signal() float64 {
+	return m.signal.Value()
+}
+
+// Histogram returns the difference of the MACD and signal lines
+func (m *MACD) Histogram() float64 {
+	return m.Value() - m.Signal()
+}
+
+// Bollinger are the Bollinger bands
+type Bollinger struct {
+	sma *SMA
+	k   float64
+}
+
+// NewBollinger creates the bands k standard deviations away from the
+// simple moving average of period values, usually NewBollinger(20, 2).
+func NewBollinger(period int, k float64) *Bollinger {
+	return &Bollinger{sma: NewSMA(period), k: k}
+}
+
+// Add adds the next value of the series
+func (b *Bollinger) Add(value float64) {
+	b.sma.Add(value)
+}
+
+// Ready returns true once period values were added
+func (b *Bollinger) Ready() bool {
+	return b.sma.Ready()
+}
+
+// Value returns the middle band
+func (b *Bollinger) Value() float64 {
+	return b.sma.Value()
+}
+
+// Bands returns the middle, upper and lower bands
+func (b *Bollinger) Bands() (middle, upper, lower float64) {
+	middle = b.sma.Value()
+	if !b.Ready() {
+		return middle, math.NaN(), math.NaN()
+	}
+	var variance float64
+	for _, v := range b.sma.window {
+		variance += (v - middle) * (v - middle)
+	}
+	dev := b.k * math.Sqrt(variance/float64(b.sma.period))
+	return middle, middle + dev, middle - dev
+}
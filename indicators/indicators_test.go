@@ -0,0 +1,101 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type IndicatorsTestSuite struct {
+	suite.Suite
+}
+
+func TestIndicatorsTestSuite(t *testing.T) {
+	suite.Run(t, new(IndicatorsTestSuite))
+}
+
+func (s *IndicatorsTestSuite) TestSMA() {
+	series := Series(NewSMA(3), []float64{1, 2, 3, 4, 5})
+	assert.True(s.T(), math.IsNaN(series[0]))
+	assert.True(s.T(), math.IsNaN(series[1]))
+	assert.Equal(s.T(), []float64{2, 3, 4}, series[2:])
+}
+
+func (s *IndicatorsTestSuite) TestEMA() {
+	ema := NewEMA(3)
+	series := Series(ema, []float64{1, 2, 3, 4, 5})
+	// seeded with the SMA, then smoothed with k = 0.5
+	assert.Equal(s.T(), []float64{2, 3, 4}, series[2:])
+	ema.Add(10)
+	assert.Equal(s.T(), float64(7), ema.Value())
+}
+
+func (s *IndicatorsTestSuite) TestRSI() {
+	rsi := NewRSI(2)
+	series := Series(rsi, []float64{10, 11, 10, 12})
+	assert.True(s.T(), math.IsNaN(series[1]))
+	// avg gain 0.5, avg loss 0.5
+	assert.InDelta(s.T(), 50, series[2], 1e-9)
+	// avg gain (0.5+2)/2, avg loss 0.5/2
+	assert.InDelta(s.T(), 100-100/(1+1.25/0.25), series[3], 1e-9)
+
+	up := NewRSI(2)
+	Series(up, []float64{1, 2, 3})
+	assert.Equal(s.T(), float64(100), up.Value())
+}
+
+func (s *IndicatorsTestSuite) TestMACD() {
+	macd := NewMACD(2, 3, 2)
+	for _, v := range []float64{1, 2, 3} {
+		macd.Add(v)
+	}
+	assert.False(s.T(), macd.Ready())
+	assert.True(s.T(), math.IsNaN(macd.Value()))
+	macd.Add(4)
+	require.True(s.T(), macd.Ready())
+	// fast EMA 3.5, slow EMA 3 and signal average of 0.5 and 0.5
+	assert.InDelta(s.T(), 0.5, macd.Value(), 1e-9)
+	assert.InDelta(s.T(), 0.5, macd.Signal(), 1e-9)
+	assert.InDelta(s.T(), 0, macd.Histogram(), 1e-9)
+}
+
+func (s *IndicatorsTestSuite) TestBollinger() {
+	b := NewBollinger(4, 2)
+	Series(b, []float64{2, 4, 4, 4})
+	_, upper, _ := b.Bands()
+	assert.False(s.T(), math.IsNaN(upper))
+	b.Add(6)
+	middle, upper, lower := b.Bands()
+	// 4, 4, 4 and 6 have a standard deviation of sqrt(0.75)
+	assert.InDelta(s.T(), 4.5, middle, 1e-9)
+	assert.InDelta(s.T(), 4.5+2*math.Sqrt(0.75), upper, 1e-9)
+	assert.InDelta(s.T(), 4.5-2*math.Sqrt(0.75), lower, 1e-9)
+
+	_, upper, _ = NewBollinger(2, 2).Bands()
+	assert.True(s.T(), math.IsNaN(upper))
+}
+
+func (s *IndicatorsTestSuite) TestATR() {
+	bars := []v2.Bar{
+		{High: 10, Low: 8, Close: 9},
+		// the gap from the previous close is the true range
+		{High: 14, Low: 12, Close: 13},
+		{High: 13, Low: 12, Close: 12},
+	}
+	assert.Equal(s.T(), []float64{9, 13, 12}, Closes(bars))
+	series := ATRSeries(bars, 2)
+	assert.True(s.T(), math.IsNaN(series[0]))
+	assert.InDelta(s.T(), 3.5, series[1], 1e-9)
+	assert.InDelta(s.T(), (3.5+1)/2, series[2], 1e-9)
+
+	atr := NewATR(2)
+	for _, bar := range bars {
+		atr.AddBar(stream.Bar{High: bar.High, Low: bar.Low, Close: bar.Close})
+	}
+	assert.Equal(s.T(), series[2], atr.Value())
+}
@@ -0,0 +1,69 @@
+// Package kafkabridge publishes the market data forwarded by a stream.Bridge
+// to Kafka, e.g.
+//
+//	pub := kafkabridge.NewPublisher([]string{"localhost:9092"})
+//	defer pub.Close()
+//	bridge := stream.NewBridge(pub, stream.WithTopicPrefix("alpaca."))
+//	defer bridge.Close(context.Background())
+//	bridge.Subscribe([]string{"AAPL"}, []string{"AAPL"}, nil)
+//
+// It is a separate module so that the SDK does not depend on a Kafka client.
+package kafkabridge
+
+import (
+	"context"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/segmentio/kafka-go"
+)
+
+// Option configures a Publisher
+type Option func(w *kafka.Writer)
+
+// WithWriterConfig lets the Kafka writer be configured further, e.g. its
+// batching or transport.
+func WithWriterConfig(configure func(w *kafka.Writer)) Option {
+	return Option(configure)
+}
+
+// Publisher publishes the messages of a bridge to the Kafka topics named
+// after their stream.Message topic. The messages are partitioned by the hash
+// of their symbol, so the messages of a symbol stay in order, and are only
+// acknowledged once written to all in-sync replicas.
+type Publisher struct {
+	writer writer
+}
+
+// writer is the part of *kafka.Writer used by Publisher
+type writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// NewPublisher creates a publisher writing to the Kafka brokers.
+func NewPublisher(brokers []string, opts ...Option) *Publisher {
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return &Publisher{writer: w}
+}
+
+// Publish writes msg, returning once Kafka acknowledged it.
+func (p *Publisher) Publish(ctx context.Context, msg stream.Message) error {
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Topic: msg.Topic,
+		Key:   []byte(msg.Key),
+		Value: msg.Value,
+		Time:  msg.Timestamp,
+	})
+}
+
+// Close flushes the pending messages and closes the writer.
+func (p *Publisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,76 @@
+package kafkabridge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWriter struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	written  []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.attempts++
+	if w.failures > 0 {
+		w.failures--
+		return kafka.NotEnoughReplicas
+	}
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	return nil
+}
+
+func TestPartitionBySymbol(t *testing.T) {
+	balancer := NewPublisher([]string{"localhost:9092"}).writer.(*kafka.Writer).Balancer
+	require.NotNil(t, balancer)
+
+	partitions := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	seen := map[string]int{}
+	for i := 0; i < 3; i++ {
+		for _, symbol := range []string{"AAPL", "MSFT", "TSLA", "SPY"} {
+			partition := balancer.Balance(kafka.Message{Key: []byte(symbol)}, partitions...)
+			if p, ok := seen[symbol]; ok {
+				assert.Equal(t, p, partition, symbol)
+			}
+			seen[symbol] = partition
+		}
+	}
+}
+
+func TestPublishRetriedUntilAcknowledged(t *testing.T) {
+	w := &fakeWriter{failures: 2}
+	pub := &Publisher{writer: w}
+	bridge := stream.NewBridge(pub, stream.WithTopicPrefix("alpaca."), stream.WithRetryDelay(time.Millisecond))
+
+	bridge.ForwardTrade(stream.Trade{Symbol: "AAPL", Price: 100, Timestamp: time.Unix(1, 0)})
+	require.NoError(t, bridge.Close(context.Background()))
+
+	assert.Equal(t, 3, w.attempts)
+	require.Len(t, w.written, 1)
+	assert.Equal(t, "alpaca.trades", w.written[0].Topic)
+	assert.Equal(t, "AAPL", string(w.written[0].Key))
+	assert.Equal(t, time.Unix(1, 0), w.written[0].Time)
+}
+
+func TestPublishReturnsWriteError(t *testing.T) {
+	pub := &Publisher{writer: &fakeWriter{failures: 1}}
+	err := pub.Publish(context.Background(), stream.Message{Topic: "trades", Key: "AAPL"})
+	assert.True(t, errors.Is(err, kafka.NotEnoughReplicas))
+	assert.NoError(t, pub.Publish(context.Background(), stream.Message{Topic: "trades", Key: "AAPL"}))
+}
@@ -0,0 +1,311 @@
+// Package lots tracks the tax lots of the positions of an account from their
+// fills, where the REST positions only have an average entry price, e.g.
+//
+//	book := lots.New(lots.WithMethod(lots.LIFO))
+//	stream.SubscribeTradeUpdates(book.HandleTradeUpdate)
+//	...
+//	for _, lot := range book.Lots("AAPL") {
+//		fmt.Println(lot.OpenedAt, lot.Qty, lot.UnrealizedPL(price))
+//	}
+//
+// The fills can also be loaded from the account activities with
+// AddActivities, e.g. to rebuild the lots of the positions opened before the
+// process started.
+package lots
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+)
+
+// Method is the order the lots of a position are closed in
+type Method int
+
+const (
+	// FIFO closes the oldest lots first
+	FIFO Method = iota
+	// LIFO closes the newest lots first
+	LIFO
+)
+
+// Lot is the quantity of a position opened by one fill
+type Lot struct {
+	Symbol  string
+	OrderID string
+	// Qty is the quantity still open, negative for a short lot
+	Qty      decimal.Decimal
+	Price    decimal.Decimal
+	OpenedAt time.Time
+}
+
+// CostBasis returns the cost of the quantity still open, negative for a
+// short lot.
+func (l Lot) CostBasis() decimal.Decimal {
+	return l.Qty.Mul(l.Price)
+}
+
+// UnrealizedPL returns the profit or loss of the lot if it was closed at
+// price.
+func (l Lot) UnrealizedPL(price decimal.Decimal) decimal.Decimal {
+	return l.Qty.Mul(price.Sub(l.Price))
+}
+
+// Closed is the part of a lot closed by a fill
+type Closed struct {
+	Lot
+	ExitPrice decimal.Decimal
+	ClosedAt  time.Time
+}
+
+// RealizedPL returns the profit or loss realized by closing the lot.
+func (c Closed) RealizedPL() decimal.Decimal {
+	return c.UnrealizedPL(c.ExitPrice)
+}
+
+// Option configures a Book
+type Option func(b *Book)
+
+// WithMethod sets the order the lots are closed in, FIFO by default.
+func WithMethod(method Method) Option {
+	return func(b *Book) {
+		b.method = method
+	}
+}
+
+// OnClose sets the callback called with each lot, or part of a lot, closed
+// by a fill.
+func OnClose(callback func(c Closed)) Option {
+	return func(b *Book) {
+		b.onClose = callback
+	}
+}
+
+// Book tracks the lots of the positions of an account. Like the positions
+// tracker, it applies the fills once each by the progress of the filled
+// quantity of their order, so repeated or late updates do not count twice,
+// and the same fills may come from both the trade updates and the account
+// activities.
+type Book struct {
+	method  Method
+	onClose func(c Closed)
+
+	mu sync.RWMutex
+	// lots are the open lots of each symbol, oldest first
+	lots   map[string][]Lot
+	closed []Closed
+	fills  *alpaca.FillDeltas
+}
+
+// New creates an empty book.
+func New(opts ...Option) *Book {
+	b := &Book{
+		lots:  map[string][]Lot{},
+		fills: alpaca.NewFillDeltas(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// HandleTradeUpdate applies the fill of a trade update. It can be passed to
+// stream.SubscribeTradeUpdates.
+func (b *Book) HandleTradeUpdate(update alpaca.TradeUpdate) {
+	switch {
+	case update.Event.IsFill():
+		b.applyOrder(update.Order)
+	case update.Event.IsTerminal():
+		b.forget(update.Order.ID)
+	}
+}
+
+// HandleTradeEvent applies the fill of a typed trade update. It can be
+// passed to stream.SubscribeTradeEvents.
+func (b *Book) HandleTradeEvent(event stream.TradeUpdateEvent) {
+	switch {
+	case event.GetEvent().IsFill():
+		b.applyOrder(event.GetOrder())
+	case event.GetEvent().IsTerminal():
+		b.forget(event.GetOrder().ID)
+	}
+}
+
+// applyOrder applies the quantity of order filled since it was last
+// applied, at the price derived from its average fill price
+func (b *Book) applyOrder(order alpaca.Order) {
+	at := order.UpdatedAt
+	if order.FilledAt != nil {
+		at = *order.FilledAt
+	}
+
+	b.mu.Lock()
+	qty, price, ok := b.fills.Order(order)
+	var closed []Closed
+	if ok {
+		closed = b.add(order.Symbol, order.ID, qty, price, at)
+	}
+	b.mu.Unlock()
+	b.notifyClosed(closed)
+}
+
+// AddFill applies a fill of the account activities.
+func (b *Book) AddFill(f *alpaca.Fill) {
+	b.mu.Lock()
+	qty, ok := b.fills.Fill(f)
+	var closed []Closed
+	if ok {
+		closed = b.add(f.Symbol, f.OrderID, qty, f.Price, f.TransactionTime)
+	}
+	b.mu.Unlock()
+	b.notifyClosed(closed)
+}
+
+// forget drops the fills of an order canceled, expired or rejected
+func (b *Book) forget(orderID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.fills.Forget(orderID)
+}
+
+// notifyClosed calls the OnClose callback with the closed lots, outside of
+// the lock of the book so that the callback may query it
+func (b *Book) notifyClosed(closed []Closed) {
+	if b.onClose == nil {
+		return
+	}
+	for _, c := range closed {
+		b.onClose(c)
+	}
+}
+
+// AddActivities applies the fills of activities, in the order they were
+// executed, ignoring the other activities.
+func (b *Book) AddActivities(activities []alpaca.AccountActivity) {
+	var fills []*alpaca.Fill
+	for _, activity := range activities {
+		if f, ok := activity.Typed().(*alpaca.Fill); ok {
+			fills = append(fills, f)
+		}
+	}
+	sort.SliceStable(fills, func(i, j int) bool {
+		return fills[i].TransactionTime.Before(fills[j].TransactionTime)
+	})
+	for _, f := range fills {
+		b.AddFill(f)
+	}
+}
+
+// add adds the signed quantity qty filled at price, closing the lots of the
+// other side first and opening a lot with what is left. It returns the
+// closed lots.
+func (b *Book) add(symbol, orderID string, qty, price decimal.Decimal, at time.Time) []Closed {
+	var closedLots []Closed
+	lots := b.lots[symbol]
+	for len(lots) > 0 && !qty.IsZero() && lots[0].Qty.Sign() != qty.Sign() {
+		i := 0
+		if b.method == LIFO {
+			i = len(lots) - 1
+		}
+		lot := &lots[i]
+		closedQty := decimal.Min(qty.Abs(), lot.Qty.Abs())
+		if lot.Qty.IsNegative() {
+			closedQty = closedQty.Neg()
+		}
+
+		closed := Closed{Lot: *lot, ExitPrice: price, ClosedAt: at}
+		closed.Qty = closedQty
+		b.closed = append(b.closed, closed)
+		closedLots = append(closedLots, closed)
+
+		lot.Qty = lot.Qty.Sub(closedQty)
+		qty = qty.Add(closedQty)
+		if lot.Qty.IsZero() {
+			lots = append(lots[:i], lots[i+1:]...)
+		}
+	}
+	if !qty.IsZero() {
+		lots = append(lots, Lot{
+			Symbol:   symbol,
+			OrderID:  orderID,
+			Qty:      qty,
+			Price:    price,
+			OpenedAt: at,
+		})
+	}
+	if len(lots) == 0 {
+		delete(b.lots, symbol)
+		return closedLots
+	}
+	b.lots[symbol] = lots
+	return closedLots
+}
+
+// Lots returns the open lots of symbol, oldest first.
+func (b *Book) Lots(symbol string) []Lot {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]Lot(nil), b.lots[symbol]...)
+}
+
+// Symbols returns the symbols with open lots.
+func (b *Book) Symbols() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	symbols := make([]string, 0, len(b.lots))
+	for symbol := range b.lots {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Qty returns the quantity of the open lots of symbol, negative for a short
+// position.
+func (b *Book) Qty(symbol string) decimal.Decimal {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	qty := decimal.Zero
+	for _, lot := range b.lots[symbol] {
+		qty = qty.Add(lot.Qty)
+	}
+	return qty
+}
+
+// UnrealizedPL returns the profit or loss of the open lots of symbol if they
+// were closed at price.
+func (b *Book) UnrealizedPL(symbol string, price decimal.Decimal) decimal.Decimal {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	pl := decimal.Zero
+	for _, lot := range b.lots[symbol] {
+		pl = pl.Add(lot.UnrealizedPL(price))
+	}
+	return pl
+}
+
+// Closed returns the lots, or parts of lots, closed so far, in the order
+// they were closed.
+func (b *Book) Closed() []Closed {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]Closed(nil), b.closed...)
+}
+
+// RealizedPL returns the profit or loss realized by the lots of symbol
+// closed so far.
+func (b *Book) RealizedPL(symbol string) decimal.Decimal {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	pl := decimal.Zero
+	for _, c := range b.closed {
+		if c.Symbol == symbol {
+			pl = pl.Add(c.RealizedPL())
+		}
+	}
+	return pl
+}
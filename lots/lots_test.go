@@ -0,0 +1,104 @@
+package lots
+
+import (
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBook(t *testing.T) {
+	start := time.Date(2021, 10, 14, 13, 30, 0, 0, time.UTC)
+	dec := func(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+	activities := []alpaca.AccountActivity{
+		{ID: "3", ActivityType: alpaca.ActivityFill, Type: "fill", OrderID: "o2", Symbol: "AAPL", Side: "buy",
+			Qty: dec("5"), CumQty: dec("10"), Price: dec("112"), TransactionTime: start.Add(2 * time.Minute)},
+		{ID: "1", ActivityType: alpaca.ActivityFill, Type: "fill", OrderID: "o1", Symbol: "AAPL", Side: "buy",
+			Qty: dec("10"), CumQty: dec("10"), Price: dec("100"), TransactionTime: start},
+		{ID: "2", ActivityType: alpaca.ActivityFill, Type: "partial_fill", OrderID: "o2", Symbol: "AAPL", Side: "buy",
+			Qty: dec("5"), CumQty: dec("5"), Price: dec("110"), TransactionTime: start.Add(time.Minute)},
+		{ID: "4", ActivityType: alpaca.ActivityDividend, Symbol: "AAPL", NetAmount: dec("2")},
+	}
+	avg := dec("120")
+	sell := alpaca.TradeUpdate{Event: alpaca.EventFill, Order: alpaca.Order{
+		ID: "o3", Symbol: "AAPL", Side: alpaca.Sell, FilledQty: dec("12"), FilledAvgPrice: &avg,
+		UpdatedAt: start.Add(time.Hour),
+	}}
+
+	var closed []Closed
+	fifo := New(OnClose(func(c Closed) { closed = append(closed, c) }))
+	fifo.AddActivities(activities)
+	// the activities loaded again count once
+	fifo.AddActivities(activities)
+	require.Len(t, fifo.Lots("AAPL"), 3)
+	assert.Equal(t, "20", fifo.Qty("AAPL").String())
+	assert.Equal(t, "90", fifo.UnrealizedPL("AAPL", dec("110")).String())
+
+	fifo.HandleTradeUpdate(sell)
+	fifo.HandleTradeUpdate(sell)
+	lots := fifo.Lots("AAPL")
+	require.Len(t, lots, 2)
+	assert.Equal(t, "3", lots[0].Qty.String())
+	assert.Equal(t, "110", lots[0].Price.String())
+	assert.Equal(t, "5", lots[1].Qty.String())
+	assert.Equal(t, "220", fifo.RealizedPL("AAPL").String())
+	require.Len(t, closed, 2)
+	assert.Equal(t, "o1", closed[0].OrderID)
+	assert.Equal(t, "2", closed[1].Qty.String())
+	assert.True(t, closed[1].ClosedAt.Equal(start.Add(time.Hour)))
+
+	lifo := New(WithMethod(LIFO))
+	lifo.AddActivities(activities)
+	lifo.HandleTradeUpdate(sell)
+	lots = lifo.Lots("AAPL")
+	require.Len(t, lots, 1)
+	assert.Equal(t, "8", lots[0].Qty.String())
+	assert.Equal(t, "100", lots[0].Price.String())
+	assert.Equal(t, "130", lifo.RealizedPL("AAPL").String())
+
+	// selling more than is held opens a short lot
+	avg = dec("130")
+	fifo.HandleTradeUpdate(alpaca.TradeUpdate{Event: alpaca.EventFill, Order: alpaca.Order{
+		ID: "o4", Symbol: "AAPL", Side: alpaca.Sell, FilledQty: dec("10"), FilledAvgPrice: &avg,
+	}})
+	lots = fifo.Lots("AAPL")
+	require.Len(t, lots, 1)
+	assert.Equal(t, "-2", lots[0].Qty.String())
+	assert.Equal(t, "-260", lots[0].CostBasis().String())
+	assert.Equal(t, "20", lots[0].UnrealizedPL(dec("120")).String())
+	assert.Equal(t, []string{"AAPL"}, fifo.Symbols())
+
+	// a short sale opens a short lot
+	fifo.AddFill(&alpaca.Fill{ID: "5", OrderID: "o5", Type: "fill", Symbol: "TSLA", Side: "sell_short",
+		Qty: dec("10"), CumQty: dec("10"), Price: dec("800"), TransactionTime: start})
+	lots = fifo.Lots("TSLA")
+	require.Len(t, lots, 1)
+	assert.Equal(t, "-10", lots[0].Qty.String())
+	assert.Equal(t, "800", lots[0].Price.String())
+}
+
+func TestBookOnCloseQueriesBook(t *testing.T) {
+	dec := func(s string) decimal.Decimal { return decimal.RequireFromString(s) }
+	price := dec("100")
+	var b *Book
+	var remaining []string
+	b = New(OnClose(func(c Closed) {
+		// the callback is called outside of the lock of the book
+		remaining = append(remaining, b.Qty(c.Symbol).String())
+	}))
+	b.HandleTradeUpdate(alpaca.TradeUpdate{Event: alpaca.EventFill, Order: alpaca.Order{
+		ID: "o1", Symbol: "AAPL", Side: alpaca.Buy, Status: alpaca.OrderFilled, FilledQty: dec("10"), FilledAvgPrice: &price,
+	}})
+	sell := alpaca.TradeUpdate{Event: alpaca.EventFill, Order: alpaca.Order{
+		ID: "o2", Symbol: "AAPL", Side: alpaca.Sell, Status: alpaca.OrderFilled, FilledQty: dec("4"), FilledAvgPrice: &price,
+	}}
+	b.HandleTradeUpdate(sell)
+	assert.Equal(t, []string{"6"}, remaining)
+
+	// the late updates of the terminal orders are ignored
+	b.HandleTradeUpdate(sell)
+	assert.Equal(t, "6", b.Qty("AAPL").String())
+}
@@ -0,0 +1,11 @@
+module github.com/market-development-strategy/alpaca-trade-api-go/natsbridge
+
+go 1.16
+
+require (
+	github.com/market-development-strategy/alpaca-trade-api-go v0.0.0
+	github.com/nats-io/nats.go v1.13.0
+	github.com/stretchr/testify v1.6.1
+)
+
+replace github.com/market-development-strategy/alpaca-trade-api-go => ../
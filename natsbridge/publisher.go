@@ -0,0 +1,43 @@
+// Package natsbridge publishes the market data forwarded by a stream.Bridge
+// to NATS JetStream, e.g.
+//
+//	nc, err := nats.Connect(nats.DefaultURL)
+//	...
+//	pub, err := natsbridge.NewPublisher(nc)
+//	...
+//	bridge := stream.NewBridge(pub, stream.WithTopicPrefix("alpaca."))
+//	defer bridge.Close(context.Background())
+//	bridge.Subscribe([]string{"AAPL"}, []string{"AAPL"}, nil)
+//
+// It is a separate module so that the SDK does not depend on a NATS client.
+package natsbridge
+
+import (
+	"context"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/nats-io/nats.go"
+)
+
+// Publisher publishes the messages of a bridge to the JetStream subjects
+// <topic>.<symbol>, e.g. "alpaca.trades.AAPL", so they can be consumed per
+// symbol, in order. The subjects must be bound to a stream. Messages are
+// only acknowledged once stored by JetStream.
+type Publisher struct {
+	js nats.JetStreamContext
+}
+
+// NewPublisher creates a publisher publishing on nc.
+func NewPublisher(nc *nats.Conn, opts ...nats.JSOpt) (*Publisher, error) {
+	js, err := nc.JetStream(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Publisher{js: js}, nil
+}
+
+// Publish publishes msg, returning once JetStream acknowledged it.
+func (p *Publisher) Publish(ctx context.Context, msg stream.Message) error {
+	_, err := p.js.Publish(msg.Topic+"."+msg.Key, msg.Value, nats.Context(ctx))
+	return err
+}
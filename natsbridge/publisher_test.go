@@ -0,0 +1,63 @@
+package natsbridge
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeJetStream acknowledges the published messages once failures publishes failed
+type fakeJetStream struct {
+	nats.JetStreamContext
+
+	mu        sync.Mutex
+	failures  int
+	attempts  int
+	published []string
+}
+
+func (js *fakeJetStream) Publish(subj string, data []byte, opts ...nats.PubOpt) (*nats.PubAck, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	js.attempts++
+	if js.failures > 0 {
+		js.failures--
+		return nil, nats.ErrTimeout
+	}
+	js.published = append(js.published, subj)
+	return &nats.PubAck{Stream: "ALPACA", Sequence: uint64(len(js.published))}, nil
+}
+
+func TestPublishSubjectPerSymbol(t *testing.T) {
+	js := &fakeJetStream{}
+	pub := &Publisher{js: js}
+
+	for _, symbol := range []string{"AAPL", "MSFT", "AAPL"} {
+		require.NoError(t, pub.Publish(context.Background(), stream.Message{Topic: "alpaca.trades", Key: symbol}))
+	}
+	assert.Equal(t, []string{"alpaca.trades.AAPL", "alpaca.trades.MSFT", "alpaca.trades.AAPL"}, js.published)
+}
+
+func TestPublishRetriedUntilAcknowledged(t *testing.T) {
+	js := &fakeJetStream{failures: 2}
+	bridge := stream.NewBridge(&Publisher{js: js}, stream.WithTopicPrefix("alpaca."), stream.WithRetryDelay(time.Millisecond))
+
+	bridge.ForwardQuote(stream.Quote{Symbol: "AAPL"})
+	require.NoError(t, bridge.Close(context.Background()))
+
+	assert.Equal(t, 3, js.attempts)
+	assert.Equal(t, []string{"alpaca.quotes.AAPL"}, js.published)
+}
+
+func TestPublishReturnsAckError(t *testing.T) {
+	pub := &Publisher{js: &fakeJetStream{failures: 1}}
+	err := pub.Publish(context.Background(), stream.Message{Topic: "trades", Key: "AAPL"})
+	assert.True(t, errors.Is(err, nats.ErrTimeout))
+}
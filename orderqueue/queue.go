@@ -0,0 +1,307 @@
+// Package orderqueue submits orders asynchronously, one at a time and within
+// a rate limit, so that a strategy placing many orders at once neither hits
+// the rate limit of the API nor waits behind its own orders to reduce risk,
+// e.g.
+//
+//	q := orderqueue.New(client, orderqueue.WithRateLimit(3, 10))
+//	defer q.Close(context.Background())
+//	futures := make([]*orderqueue.Future, len(reqs))
+//	for i, req := range reqs {
+//		futures[i] = q.Place(req, orderqueue.Normal)
+//	}
+//	// jumps ahead of the orders still queued
+//	stop := q.Place(closeRequest, orderqueue.High)
+//	order, err := stop.Wait()
+package orderqueue
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+)
+
+// ErrQueueClosed is the error of the requests submitted to a closed queue,
+// and of the ones still queued when Close gives up
+var ErrQueueClosed = errors.New("order queue is closed")
+
+// Priority orders the requests of the queue. The requests of a higher
+// priority are submitted first, and the ones of the same priority in the
+// order they were queued.
+type Priority int
+
+const (
+	// Normal is the priority of most orders
+	Normal Priority = 0
+	// High is the priority of the orders reducing risk, e.g. stop losses
+	// and cancels
+	High Priority = 10
+)
+
+// Future is the result of a queued request
+type Future struct {
+	done  chan struct{}
+	order *alpaca.Order
+	err   error
+}
+
+// Done returns a channel closed once the request completed.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the request to complete and returns its order, nil for a
+// cancel, and error.
+func (f *Future) Wait() (*alpaca.Order, error) {
+	<-f.done
+	return f.order, f.err
+}
+
+func (f *Future) complete(order *alpaca.Order, err error) {
+	f.order, f.err = order, err
+	close(f.done)
+}
+
+// Option configures a Queue
+type Option func(q *Queue)
+
+// WithRateLimit sets the number of requests submitted per second, and the
+// number that can be submitted at once after a quiet period, 3 and 10 by
+// default.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(q *Queue) {
+		q.rate, q.burst = perSecond, burst
+	}
+}
+
+// WithClock sets the clock of the rate limit, clock.Real by default.
+func WithClock(c clock.Clock) Option {
+	return func(q *Queue) {
+		q.clock = c
+	}
+}
+
+// request is a request of the queue
+type request struct {
+	priority Priority
+	seq      uint64
+	submit   func() (*alpaca.Order, error)
+	future   *Future
+}
+
+// requests is a heap of requests, by priority then sequence
+type requests []*request
+
+func (r requests) Len() int { return len(r) }
+func (r requests) Less(i, j int) bool {
+	if r[i].priority != r[j].priority {
+		return r[i].priority > r[j].priority
+	}
+	return r[i].seq < r[j].seq
+}
+func (r requests) Swap(i, j int)       { r[i], r[j] = r[j], r[i] }
+func (r *requests) Push(x interface{}) { *r = append(*r, x.(*request)) }
+func (r *requests) Pop() interface{} {
+	old := *r
+	req := old[len(old)-1]
+	*r = old[:len(old)-1]
+	return req
+}
+
+// Queue submits the requests queued to it with a client, one at a time, by
+// priority, within its rate limit.
+type Queue struct {
+	client alpaca.TradingClient
+	rate   float64
+	burst  int
+	clock  clock.Clock
+
+	mu      sync.Mutex
+	pending requests
+	seq     uint64
+	closed  bool
+	// tokens are the requests that can be submitted now, refilled at rate
+	tokens   float64
+	refilled time.Time
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New creates a queue submitting with client, and starts submitting.
+func New(client alpaca.TradingClient, opts ...Option) *Queue {
+	q := &Queue{
+		client: client,
+		rate:   3,
+		burst:  10,
+		clock:  clock.Real,
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	q.tokens = float64(q.burst)
+	q.refilled = q.clock.Now()
+	go q.run()
+	return q
+}
+
+// Place queues the placement of an order.
+func (q *Queue) Place(req alpaca.PlaceOrderRequest, priority Priority) *Future {
+	return q.enqueue(priority, func() (*alpaca.Order, error) {
+		return q.client.PlaceOrder(req)
+	})
+}
+
+// Replace queues the replacement of an order.
+func (q *Queue) Replace(orderID string, req alpaca.ReplaceOrderRequest, priority Priority) *Future {
+	return q.enqueue(priority, func() (*alpaca.Order, error) {
+		return q.client.ReplaceOrder(orderID, req)
+	})
+}
+
+// Cancel queues the cancellation of an order.
+func (q *Queue) Cancel(orderID string, priority Priority) *Future {
+	return q.enqueue(priority, func() (*alpaca.Order, error) {
+		return nil, q.client.CancelOrder(orderID)
+	})
+}
+
+// Len returns the number of requests waiting to be submitted.
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.pending)
+}
+
+func (q *Queue) enqueue(priority Priority, submit func() (*alpaca.Order, error)) *Future {
+	f := &Future{done: make(chan struct{})}
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		f.complete(nil, ErrQueueClosed)
+		return f
+	}
+	q.seq++
+	heap.Push(&q.pending, &request{priority: priority, seq: q.seq, submit: submit, future: f})
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return f
+}
+
+// Close stops queueing requests and waits for the queued ones to be
+// submitted. When ctx is done first, the requests still queued fail with
+// ErrQueueClosed and Close returns the error of ctx.
+func (q *Queue) Close(ctx context.Context) error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return ErrQueueClosed
+	}
+	q.closed = true
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		close(q.stop)
+		<-q.done
+		return ctx.Err()
+	}
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	for q.wait() {
+	}
+
+	// stopped by Close
+	q.mu.Lock()
+	pending := q.pending
+	q.pending = nil
+	q.mu.Unlock()
+	for _, req := range pending {
+		req.future.complete(nil, ErrQueueClosed)
+	}
+}
+
+// wait submits the next request or waits for it, and returns false once the
+// queue is drained or stopped
+func (q *Queue) wait() bool {
+	select {
+	case <-q.stop:
+		return false
+	default:
+	}
+
+	req, wait := q.next()
+	switch {
+	case req != nil:
+		req.future.complete(req.submit())
+		return true
+	case wait == 0:
+		// closed and drained
+		return false
+	case wait < 0:
+		select {
+		case <-q.wake:
+			return true
+		case <-q.stop:
+			return false
+		}
+	default:
+		timer := q.clock.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C():
+			return true
+		case <-q.stop:
+			return false
+		}
+	}
+}
+
+// next returns the request to submit now, or how long to wait for a token,
+// -1 to wait for a request and 0 once the queue is closed and drained
+func (q *Queue) next() (*request, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		if q.closed {
+			return nil, 0
+		}
+		return nil, -1
+	}
+
+	now := q.clock.Now()
+	q.tokens += now.Sub(q.refilled).Seconds() * q.rate
+	if q.tokens > float64(q.burst) {
+		q.tokens = float64(q.burst)
+	}
+	q.refilled = now
+	if q.tokens < 1 {
+		wait := time.Duration((1 - q.tokens) / q.rate * float64(time.Second))
+		if wait <= 0 {
+			wait = time.Millisecond
+		}
+		return nil, wait
+	}
+	q.tokens--
+	return heap.Pop(&q.pending).(*request), 0
+}
@@ -0,0 +1,191 @@
+package orderqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type QueueTestSuite struct {
+	suite.Suite
+	mock *alpacatest.MockClient
+
+	mu      sync.Mutex
+	symbols []string
+	// release unblocks the placements, when set
+	release chan struct{}
+}
+
+func TestQueueTestSuite(t *testing.T) {
+	suite.Run(t, new(QueueTestSuite))
+}
+
+func (s *QueueTestSuite) SetupTest() {
+	s.symbols = nil
+	s.release = nil
+	s.mock = &alpacatest.MockClient{
+		PlaceOrderFunc: func(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+			if s.release != nil {
+				<-s.release
+			}
+			s.mu.Lock()
+			s.symbols = append(s.symbols, *req.AssetKey)
+			s.mu.Unlock()
+			if *req.AssetKey == "GME" {
+				return nil, alpaca.ErrAssetNotTradable
+			}
+			return &alpaca.Order{ID: "order_" + *req.AssetKey, Symbol: *req.AssetKey}, nil
+		},
+		ReplaceOrderFunc: func(orderID string, req alpaca.ReplaceOrderRequest) (*alpaca.Order, error) {
+			return &alpaca.Order{ID: orderID + "_replaced"}, nil
+		},
+		CancelOrderFunc: func(orderID string) error {
+			return nil
+		},
+	}
+}
+
+func placeRequest(symbol string) alpaca.PlaceOrderRequest {
+	return alpaca.PlaceOrderRequest{
+		AssetKey:    &symbol,
+		Side:        alpaca.Buy,
+		Type:        alpaca.Market,
+		TimeInForce: alpaca.Day,
+	}
+}
+
+func (s *QueueTestSuite) TestFutures() {
+	q := New(s.mock)
+
+	order, err := q.Place(placeRequest("AAPL"), Normal).Wait()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "order_AAPL", order.ID)
+
+	_, err = q.Place(placeRequest("GME"), Normal).Wait()
+	assert.Equal(s.T(), alpaca.ErrAssetNotTradable, err)
+
+	order, err = q.Replace("order_AAPL", alpaca.ReplaceOrderRequest{}, Normal).Wait()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "order_AAPL_replaced", order.ID)
+
+	order, err = q.Cancel("order_AAPL", High).Wait()
+	assert.NoError(s.T(), err)
+	assert.Nil(s.T(), order)
+
+	f := q.Cancel("order_AAPL", Normal)
+	<-f.Done()
+
+	require.NoError(s.T(), q.Close(context.Background()))
+	assert.Equal(s.T(), []string{"PlaceOrder", "PlaceOrder", "ReplaceOrder", "CancelOrder", "CancelOrder"}, s.mock.Calls)
+}
+
+func (s *QueueTestSuite) TestPriority() {
+	s.release = make(chan struct{})
+	q := New(s.mock)
+
+	first := q.Place(placeRequest("SPY"), Normal)
+	// the worker is blocked placing SPY once it is no longer pending
+	require.Eventually(s.T(), func() bool { return q.Len() == 0 }, time.Second, time.Millisecond)
+
+	futures := []*Future{
+		q.Place(placeRequest("AAPL"), Normal),
+		q.Place(placeRequest("MSFT"), Normal),
+		q.Place(placeRequest("TSLA"), High),
+		q.Place(placeRequest("AMZN"), Normal),
+		q.Place(placeRequest("NFLX"), High),
+	}
+	assert.Equal(s.T(), 5, q.Len())
+	close(s.release)
+
+	for _, f := range append(futures, first) {
+		_, err := f.Wait()
+		assert.NoError(s.T(), err)
+	}
+	require.NoError(s.T(), q.Close(context.Background()))
+	assert.Equal(s.T(), []string{"SPY", "TSLA", "NFLX", "AAPL", "MSFT", "AMZN"}, s.symbols)
+}
+
+func (s *QueueTestSuite) TestRateLimit() {
+	c := clock.NewFake(time.Date(2021, 3, 2, 9, 30, 0, 0, time.UTC))
+	q := New(s.mock, WithRateLimit(1, 2), WithClock(c))
+
+	var futures []*Future
+	for _, symbol := range []string{"AAPL", "MSFT", "TSLA", "AMZN"} {
+		futures = append(futures, q.Place(placeRequest(symbol), Normal))
+	}
+	// the burst is submitted at once
+	_, err := futures[1].Wait()
+	require.NoError(s.T(), err)
+
+	// then one a second
+	for _, f := range futures[2:] {
+		c.BlockUntil(1)
+		c.Advance(999 * time.Millisecond)
+		select {
+		case <-f.Done():
+			assert.Fail(s.T(), "submitted early")
+		default:
+		}
+		c.Advance(time.Millisecond)
+		_, err = f.Wait()
+		require.NoError(s.T(), err)
+	}
+	require.NoError(s.T(), q.Close(context.Background()))
+	assert.Equal(s.T(), []string{"AAPL", "MSFT", "TSLA", "AMZN"}, s.symbols)
+}
+
+func (s *QueueTestSuite) TestCloseDrains() {
+	q := New(s.mock, WithRateLimit(100, 1))
+	var futures []*Future
+	for _, symbol := range []string{"AAPL", "MSFT", "TSLA"} {
+		futures = append(futures, q.Place(placeRequest(symbol), Normal))
+	}
+
+	require.NoError(s.T(), q.Close(context.Background()))
+	for _, f := range futures {
+		select {
+		case <-f.Done():
+		default:
+			assert.Fail(s.T(), "future not done")
+		}
+		_, err := f.Wait()
+		assert.NoError(s.T(), err)
+	}
+	assert.Equal(s.T(), []string{"AAPL", "MSFT", "TSLA"}, s.symbols)
+
+	_, err := q.Place(placeRequest("AMZN"), High).Wait()
+	assert.Equal(s.T(), ErrQueueClosed, err)
+	assert.Equal(s.T(), ErrQueueClosed, q.Close(context.Background()))
+}
+
+func (s *QueueTestSuite) TestCloseGivesUp() {
+	q := New(s.mock, WithRateLimit(1, 1))
+	placed := q.Place(placeRequest("AAPL"), Normal)
+	_, err := placed.Wait()
+	require.NoError(s.T(), err)
+	// waits about a second for a token
+	pending := []*Future{
+		q.Place(placeRequest("MSFT"), Normal),
+		q.Cancel("order_AAPL", High),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = q.Close(ctx)
+	assert.True(s.T(), errors.Is(err, context.DeadlineExceeded))
+	for _, f := range pending {
+		_, err := f.Wait()
+		assert.Equal(s.T(), ErrQueueClosed, err)
+	}
+	assert.Equal(s.T(), []string{"PlaceOrder"}, s.mock.Calls)
+	assert.Equal(s.T(), 0, q.Len())
+}
@@ -0,0 +1,247 @@
+// Package ordertracker maintains a local view of the state of the orders of
+// an account from the trade updates of the stream, reconciled periodically
+// against the REST API so that missed updates are caught up, e.g.
+//
+//	tracker := ordertracker.New(client, ordertracker.OnMissedFill(func(f ordertracker.MissedFill) {
+//		log.Printf("missed fill of %s %s", f.Qty, f.Order.Symbol)
+//	}))
+//	stream.SubscribeTradeUpdates(tracker.HandleTradeUpdate)
+//	go tracker.Run(ctx)
+package ordertracker
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+)
+
+// Source is where a transition was learned from
+type Source int
+
+const (
+	// StreamSource is the trade updates stream
+	StreamSource Source = iota
+	// ReconcileSource is the REST API, when reconciling
+	ReconcileSource
+	// LocalSource is an order passed to Track
+	LocalSource
+)
+
+// String returns the name of the source
+func (s Source) String() string {
+	switch s {
+	case StreamSource:
+		return "stream"
+	case ReconcileSource:
+		return "reconcile"
+	default:
+		return "local"
+	}
+}
+
+// Transition is a change of the status of an order
+type Transition struct {
+	// Order is the new state of the order
+	Order alpaca.Order
+	// From is the previous status, empty for an order that was not tracked
+	From alpaca.OrderStatus
+	To   alpaca.OrderStatus
+	// Event is the trade update event, empty unless Source is StreamSource
+	Event  alpaca.TradeEvent
+	Source Source
+}
+
+// MissedFill is a quantity of an order found filled without a fill
+// event for it
+type MissedFill struct {
+	Order  alpaca.Order
+	Qty    decimal.Decimal
+	Source Source
+}
+
+// Option configures a Tracker
+type Option func(t *Tracker)
+
+// WithInterval sets how often Run reconciles, every minute by default.
+func WithInterval(interval time.Duration) Option {
+	return func(t *Tracker) {
+		t.interval = interval
+	}
+}
+
+// OnTransition sets the callback called on every change of status. The
+// callbacks are called one at a time and must not apply updates themselves.
+func OnTransition(callback func(t Transition)) Option {
+	return func(t *Tracker) {
+		t.onTransition = callback
+	}
+}
+
+// OnMissedFill sets the callback called when a fill was missed.
+func OnMissedFill(callback func(f MissedFill)) Option {
+	return func(t *Tracker) {
+		t.onMissedFill = callback
+	}
+}
+
+// Tracker tracks the orders of an account. An update is only applied if the
+// order was not updated since, so late updates and reconciliations racing
+// with the stream do not move an order back, and a terminal order (see
+// alpaca.OrderStatus.IsTerminal) never changes.
+type Tracker struct {
+	client       alpaca.TradingClient
+	interval     time.Duration
+	onTransition func(t Transition)
+	onMissedFill func(f MissedFill)
+
+	// callbacks serializes the callbacks, in the order of the updates
+	callbacks sync.Mutex
+	mu        sync.RWMutex
+	orders    map[string]alpaca.Order
+}
+
+// New creates a tracker reconciling with client.
+func New(client alpaca.TradingClient, opts ...Option) *Tracker {
+	t := &Tracker{
+		client:   client,
+		interval: time.Minute,
+		orders:   map[string]alpaca.Order{},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// HandleTradeUpdate applies a trade update. It can be passed to
+// stream.SubscribeTradeUpdates.
+func (t *Tracker) HandleTradeUpdate(update alpaca.TradeUpdate) {
+	t.apply(StreamSource, update.Event, update.Order)
+}
+
+// HandleTradeEvent applies a typed trade update. It can be passed to
+// stream.SubscribeTradeEvents.
+func (t *Tracker) HandleTradeEvent(event stream.TradeUpdateEvent) {
+	t.apply(StreamSource, event.GetEvent(), event.GetOrder())
+}
+
+// Track tracks an order, e.g. as returned by PlaceOrder, before its first
+// trade update.
+func (t *Tracker) Track(order alpaca.Order) {
+	t.apply(LocalSource, "", order)
+}
+
+// Order returns the tracked state of an order.
+func (t *Tracker) Order(orderID string) (alpaca.Order, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	order, ok := t.orders[orderID]
+	return order, ok
+}
+
+// Orders returns the tracked orders, open ones only if open is set, by
+// time of submission.
+func (t *Tracker) Orders(open bool) []alpaca.Order {
+	t.mu.RLock()
+	orders := make([]alpaca.Order, 0, len(t.orders))
+	for _, order := range t.orders {
+		if !open || order.Status.IsOpen() {
+			orders = append(orders, order)
+		}
+	}
+	t.mu.RUnlock()
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].SubmittedAt.Before(orders[j].SubmittedAt)
+	})
+	return orders
+}
+
+// Forget stops tracking the terminal orders last updated before a time.
+func (t *Tracker) Forget(before time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id, order := range t.orders {
+		if order.Status.IsTerminal() && order.UpdatedAt.Before(before) {
+			delete(t.orders, id)
+		}
+	}
+}
+
+// Run reconciles at the interval of the tracker until ctx is done. The
+// errors of the reconciliations are retried at the next one.
+func (t *Tracker) Run(ctx context.Context) error {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		t.Reconcile()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile fetches the open orders, and the tracked open orders that are
+// no longer open, and applies their state.
+func (t *Tracker) Reconcile() error {
+	status := "open"
+	limit := 500
+	open, err := t.client.ListOrders(&status, nil, &limit, nil)
+	if err != nil {
+		return err
+	}
+	listed := make(map[string]bool, len(open))
+	for _, order := range open {
+		listed[order.ID] = true
+		t.apply(ReconcileSource, "", order)
+	}
+
+	for _, order := range t.Orders(true) {
+		if listed[order.ID] {
+			continue
+		}
+		// closed since it was last updated
+		closed, err := t.client.GetOrder(order.ID)
+		if err != nil {
+			return err
+		}
+		t.apply(ReconcileSource, "", *closed)
+	}
+	return nil
+}
+
+func (t *Tracker) apply(source Source, event alpaca.TradeEvent, order alpaca.Order) {
+	t.callbacks.Lock()
+	defer t.callbacks.Unlock()
+
+	t.mu.Lock()
+	prev, tracked := t.orders[order.ID]
+	if tracked && (prev.Status.IsTerminal() || order.UpdatedAt.Before(prev.UpdatedAt)) {
+		t.mu.Unlock()
+		return
+	}
+	t.orders[order.ID] = order
+	t.mu.Unlock()
+
+	if missed := order.FilledQty.Sub(prev.FilledQty); missed.IsPositive() && !event.IsFill() && tracked {
+		if t.onMissedFill != nil {
+			t.onMissedFill(MissedFill{Order: order, Qty: missed, Source: source})
+		}
+	}
+	if (!tracked || prev.Status != order.Status) && t.onTransition != nil {
+		t.onTransition(Transition{
+			Order:  order,
+			From:   prev.Status,
+			To:     order.Status,
+			Event:  event,
+			Source: source,
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package ordertracker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TrackerTestSuite struct {
+	suite.Suite
+	mock        *alpacatest.MockClient
+	tracker     *Tracker
+	transitions []Transition
+	missed      []MissedFill
+}
+
+func TestTrackerTestSuite(t *testing.T) {
+	suite.Run(t, new(TrackerTestSuite))
+}
+
+var start = time.Date(2021, 3, 2, 14, 30, 0, 0, time.UTC)
+
+func order(id string, status alpaca.OrderStatus, filled int64, updated int) alpaca.Order {
+	return alpaca.Order{
+		ID:          id,
+		Symbol:      "AAPL",
+		Qty:         decimal.New(10, 0),
+		FilledQty:   decimal.New(filled, 0),
+		Status:      status,
+		SubmittedAt: start,
+		UpdatedAt:   start.Add(time.Duration(updated) * time.Second),
+	}
+}
+
+func (s *TrackerTestSuite) SetupTest() {
+	s.mock = &alpacatest.MockClient{}
+	s.transitions, s.missed = nil, nil
+	s.tracker = New(s.mock,
+		OnTransition(func(t Transition) { s.transitions = append(s.transitions, t) }),
+		OnMissedFill(func(f MissedFill) { s.missed = append(s.missed, f) }),
+	)
+}
+
+func (s *TrackerTestSuite) statuses() []string {
+	var statuses []string
+	for _, t := range s.transitions {
+		statuses = append(statuses, string(t.From)+">"+string(t.To))
+	}
+	return statuses
+}
+
+func (s *TrackerTestSuite) TestStream() {
+	s.tracker.Track(order("1", alpaca.OrderAccepted, 0, 0))
+	s.tracker.HandleTradeUpdate(alpaca.TradeUpdate{Event: alpaca.EventNew, Order: order("1", alpaca.OrderNew, 0, 1)})
+	s.tracker.HandleTradeEvent(stream.FillEvent{OrderEvent: stream.OrderEvent{
+		Event: alpaca.EventPartialFill, Order: order("1", alpaca.OrderPartiallyFilled, 4, 2)}})
+	// a late update does not move the order back
+	s.tracker.HandleTradeUpdate(alpaca.TradeUpdate{Event: alpaca.EventNew, Order: order("1", alpaca.OrderNew, 0, 1)})
+	s.tracker.HandleTradeUpdate(alpaca.TradeUpdate{Event: alpaca.EventFill, Order: order("1", alpaca.OrderFilled, 10, 3)})
+	// nor does anything after a terminal status
+	s.tracker.HandleTradeUpdate(alpaca.TradeUpdate{Event: alpaca.EventCanceled, Order: order("1", alpaca.OrderCanceled, 10, 4)})
+
+	assert.Equal(s.T(), []string{">accepted", "accepted>new", "new>partially_filled", "partially_filled>filled"}, s.statuses())
+	assert.Equal(s.T(), LocalSource, s.transitions[0].Source)
+	assert.Equal(s.T(), alpaca.EventFill, s.transitions[3].Event)
+	assert.Empty(s.T(), s.missed)
+
+	o, ok := s.tracker.Order("1")
+	require.True(s.T(), ok)
+	assert.Equal(s.T(), alpaca.OrderFilled, o.Status)
+	assert.Empty(s.T(), s.tracker.Orders(true))
+	assert.Len(s.T(), s.tracker.Orders(false), 1)
+
+	s.tracker.Forget(start.Add(time.Minute))
+	_, ok = s.tracker.Order("1")
+	assert.False(s.T(), ok)
+}
+
+func (s *TrackerTestSuite) TestMissedFillOnCancel() {
+	s.tracker.HandleTradeUpdate(alpaca.TradeUpdate{Event: alpaca.EventNew, Order: order("1", alpaca.OrderNew, 0, 0)})
+	// the partial fill before the cancel was missed
+	s.tracker.HandleTradeUpdate(alpaca.TradeUpdate{Event: alpaca.EventCanceled, Order: order("1", alpaca.OrderCanceled, 3, 2)})
+	require.Len(s.T(), s.missed, 1)
+	assert.True(s.T(), s.missed[0].Qty.Equal(decimal.New(3, 0)))
+	assert.Equal(s.T(), StreamSource, s.missed[0].Source)
+}
+
+func (s *TrackerTestSuite) TestReconcile() {
+	s.tracker.HandleTradeUpdate(alpaca.TradeUpdate{Event: alpaca.EventNew, Order: order("1", alpaca.OrderNew, 0, 0)})
+	s.tracker.HandleTradeUpdate(alpaca.TradeUpdate{Event: alpaca.EventNew, Order: order("2", alpaca.OrderNew, 0, 0)})
+
+	s.mock.ListOrdersFunc = func(status *string, until *time.Time, limit *int, nested *bool) ([]alpaca.Order, error) {
+		assert.Equal(s.T(), "open", *status)
+		// 1 was partially filled, 3 was placed elsewhere
+		return []alpaca.Order{order("1", alpaca.OrderPartiallyFilled, 5, 1), order("3", alpaca.OrderNew, 0, 1)}, nil
+	}
+	s.mock.GetOrderFunc = func(orderID string) (*alpaca.Order, error) {
+		assert.Equal(s.T(), "2", orderID)
+		// 2 filled
+		o := order("2", alpaca.OrderFilled, 10, 2)
+		return &o, nil
+	}
+	require.NoError(s.T(), s.tracker.Reconcile())
+
+	assert.Equal(s.T(), []string{">new", ">new", "new>partially_filled", ">new", "new>filled"}, s.statuses())
+	assert.Equal(s.T(), ReconcileSource, s.transitions[2].Source)
+	require.Len(s.T(), s.missed, 2)
+	assert.Equal(s.T(), "1", s.missed[0].Order.ID)
+	assert.True(s.T(), s.missed[0].Qty.Equal(decimal.New(5, 0)))
+	assert.Equal(s.T(), "2", s.missed[1].Order.ID)
+	assert.True(s.T(), s.missed[1].Qty.Equal(decimal.New(10, 0)))
+	assert.Len(s.T(), s.tracker.Orders(true), 2)
+
+	s.mock.ListOrdersFunc = func(status *string, until *time.Time, limit *int, nested *bool) ([]alpaca.Order, error) {
+		return nil, errors.New("unavailable")
+	}
+	assert.EqualError(s.T(), s.tracker.Reconcile(), "unavailable")
+}
+
+func (s *TrackerTestSuite) TestRun() {
+	reconciled := make(chan struct{}, 10)
+	s.mock.ListOrdersFunc = func(status *string, until *time.Time, limit *int, nested *bool) ([]alpaca.Order, error) {
+		reconciled <- struct{}{}
+		return nil, nil
+	}
+	s.tracker.interval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.tracker.Run(ctx) }()
+	<-reconciled
+	<-reconciled
+	cancel()
+	assert.Equal(s.T(), context.Canceled, <-done)
+}
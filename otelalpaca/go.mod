@@ -0,0 +1,13 @@
+module github.com/market-development-strategy/alpaca-trade-api-go/otelalpaca
+
+go 1.16
+
+require (
+	github.com/market-development-strategy/alpaca-trade-api-go v0.0.0
+	github.com/stretchr/testify v1.7.0
+	go.opentelemetry.io/otel v1.3.0
+	go.opentelemetry.io/otel/sdk v1.3.0
+	go.opentelemetry.io/otel/trace v1.3.0
+)
+
+replace github.com/market-development-strategy/alpaca-trade-api-go => ../
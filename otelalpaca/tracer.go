@@ -0,0 +1,113 @@
+// Package otelalpaca traces the REST and stream clients of the Alpaca SDK
+// with OpenTelemetry. It is a module of its own so that the SDK does not
+// depend on OpenTelemetry.
+//
+//	client := alpaca.NewClientWithOptions(alpaca.WithRequestTracer(otelalpaca.NewTracer()))
+//	account, err := client.WithContext(ctx).GetAccount()
+//
+//	c := stream.NewTradingClient(stream.WithTracer(otelalpaca.NewTracer()))
+package otelalpaca
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/stream"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/market-development-strategy/alpaca-trade-api-go/otelalpaca"
+
+// Tracer creates a span for every REST call and stream operation.
+// It implements alpaca.RequestTracer and stream.Tracer.
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+var (
+	_ alpaca.RequestTracer = (*Tracer)(nil)
+	_ stream.Tracer        = (*Tracer)(nil)
+)
+
+// Option configures a Tracer.
+type Option func(t *Tracer)
+
+// WithTracerProvider sets the provider of the spans, otel.GetTracerProvider() by default.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(t *Tracer) {
+		t.tracer = provider.Tracer(instrumentationName)
+	}
+}
+
+// WithPropagator sets the propagator injecting the trace context into the
+// request headers, otel.GetTextMapPropagator() by default.
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(t *Tracer) {
+		t.propagator = propagator
+	}
+}
+
+// NewTracer creates a tracer using the global OpenTelemetry provider and
+// propagator unless they are set with the options.
+func NewTracer(opts ...Option) *Tracer {
+	t := &Tracer{
+		tracer:     otel.GetTracerProvider().Tracer(instrumentationName),
+		propagator: otel.GetTextMapPropagator(),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// StartRequest starts the span of a REST call, a child of the span of the
+// request context, and injects its context into the request headers.
+func (t *Tracer) StartRequest(req *http.Request) (*http.Request, func(statusCode, retries int, err error)) {
+	ctx, span := t.tracer.Start(req.Context(), "alpaca "+req.Method+" "+req.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.method", req.Method),
+			attribute.String("http.url", req.URL.String()),
+			attribute.String("alpaca.endpoint", req.URL.Path),
+			attribute.String("alpaca.request_id", req.Header.Get(alpaca.RequestIDHeader)),
+		),
+	)
+	req = req.WithContext(ctx)
+	t.propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	return req, func(statusCode, retries int, err error) {
+		span.SetAttributes(attribute.Int("alpaca.retries", retries))
+		if statusCode > 0 {
+			span.SetAttributes(attribute.Int("http.status_code", statusCode))
+		}
+		switch {
+		case err != nil:
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		case statusCode >= http.StatusBadRequest:
+			span.SetStatus(codes.Error, http.StatusText(statusCode))
+		}
+		span.End()
+	}
+}
+
+// Start starts the span of a stream operation.
+func (t *Tracer) Start(ctx context.Context, operation string, channels []string) (context.Context, func(err error)) {
+	ctx, span := t.tracer.Start(ctx, "alpaca stream "+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.StringSlice("alpaca.channels", channels)),
+	)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
@@ -0,0 +1,75 @@
+package otelalpaca
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestTracer() (*Tracer, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return NewTracer(WithTracerProvider(provider), WithPropagator(propagation.TraceContext{})), recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestStartRequest(t *testing.T) {
+	tracer, recorder := newTestTracer()
+
+	req := httptest.NewRequest(http.MethodGet, "https://api.alpaca.markets/v2/orders?status=open", nil)
+	req, finish := tracer.StartRequest(req)
+	finish(http.StatusTooManyRequests, 2, nil)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "alpaca GET /v2/orders", span.Name())
+	attrs := attributes(span)
+	assert.Equal(t, "GET", attrs["http.method"].AsString())
+	assert.Equal(t, "/v2/orders", attrs["alpaca.endpoint"].AsString())
+	assert.Equal(t, int64(http.StatusTooManyRequests), attrs["http.status_code"].AsInt64())
+	assert.Equal(t, int64(2), attrs["alpaca.retries"].AsInt64())
+	assert.Equal(t, codes.Error, span.Status().Code)
+
+	// the span context is propagated to the API
+	traceparent := req.Header.Get("traceparent")
+	require.NotEmpty(t, traceparent)
+	assert.Contains(t, traceparent, span.SpanContext().TraceID().String())
+	assert.Contains(t, traceparent, span.SpanContext().SpanID().String())
+}
+
+func TestStartRequestChildSpan(t *testing.T) {
+	tracer, recorder := newTestTracer()
+
+	ctx, finishParent := tracer.Start(context.Background(), "subscribe", []string{"trade_updates"})
+	req := httptest.NewRequest(http.MethodPost, "https://api.alpaca.markets/v2/orders", nil).WithContext(ctx)
+	_, finish := tracer.StartRequest(req)
+	finish(0, 0, errors.New("connection reset"))
+	finishParent(nil)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	child, parent := spans[0], spans[1]
+	assert.Equal(t, parent.SpanContext().SpanID(), child.Parent().SpanID())
+	assert.Equal(t, codes.Error, child.Status().Code)
+	assert.NotContains(t, attributes(child), attribute.Key("http.status_code"))
+	assert.Equal(t, []string{"trade_updates"}, attributes(parent)["alpaca.channels"].AsStringSlice())
+	assert.Equal(t, codes.Unset, parent.Status().Code)
+}
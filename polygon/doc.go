@@ -0,0 +1,8 @@
+// Package polygon is a client for the Polygon integration of Alpaca.
+//
+// Deprecated: the Polygon integration is not available for new API keys.
+// Use the Alpaca Data v2 REST methods of the alpaca package and the v2/stream
+// package instead. While migrating, MigrateToAlpacaDataV2 (or
+// NewMigrationClient) and GetMigrationStream serve the v2 REST methods and
+// the stream channels from Alpaca Data v2 with the Polygon entities.
+package polygon
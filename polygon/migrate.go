@@ -0,0 +1,327 @@
+package polygon
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	v2stream "github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// defaultTicksLimit is the default number of ticks Polygon returned
+// from its v2 ticks endpoints.
+const defaultTicksLimit = 5000
+
+var (
+	warned sync.Map
+
+	migrationOnce sync.Once
+	migrationStr  *MigrationStream
+
+	newYork = func() *time.Location {
+		loc, err := time.LoadLocation("America/New_York")
+		if err != nil {
+			return time.UTC
+		}
+		return loc
+	}()
+
+	// subscription functions of the Alpaca Data v2 stream, replaced in tests
+	subscribeTrades   = v2stream.SubscribeTrades
+	subscribeQuotes   = v2stream.SubscribeQuotes
+	subscribeBars     = v2stream.SubscribeBars
+	unsubscribeTrades = v2stream.UnsubscribeTrades
+	unsubscribeQuotes = v2stream.UnsubscribeQuotes
+	unsubscribeBars   = v2stream.UnsubscribeBars
+)
+
+// deprecated logs, once per name, that a Polygon API is used and what
+// replaces it on Alpaca Data v2.
+func deprecated(name, replacement string) {
+	if _, loaded := warned.LoadOrStore(name, true); loaded {
+		return
+	}
+	logger().Warn("deprecated Polygon API, the Polygon integration is not available for new API keys",
+		"client", "polygon", "api", name, "replacement", replacement)
+}
+
+// MigrateToAlpacaDataV2 makes DefaultClient serve the v2 REST methods from
+// Alpaca Data v2, so code written against the Polygon integration keeps
+// running while it is migrated.
+func MigrateToAlpacaDataV2() {
+	DefaultClient = NewMigrationClient(nil)
+}
+
+// NewMigrationClient creates a client whose GetHistoricAggregatesV2,
+// GetHistoricTradesV2 and GetHistoricQuotesV2 are served by Alpaca Data v2
+// and converted to the Polygon entities. The other methods still call Polygon.
+// With nil credentials the client reads common.Credentials() for every request.
+func NewMigrationClient(credentials *common.APIKey) *Client {
+	return &Client{
+		credentials: credentials,
+		data:        alpaca.NewClient(credentials),
+	}
+}
+
+func (c *Client) aggregatesFromV2(
+	symbol string,
+	multiplier int,
+	resolution AggType,
+	from, to *time.Time,
+	unadjusted *bool) (*HistoricAggregatesV2, error) {
+
+	var timeFrame v2.TimeFrame
+	switch resolution {
+	case Minute:
+		timeFrame = v2.TimeFrame(fmt.Sprintf("%dMin", multiplier))
+	case Day:
+		timeFrame = v2.TimeFrame(fmt.Sprintf("%dDay", multiplier))
+	default:
+		return nil, fmt.Errorf("unsupported aggregate resolution %s", resolution)
+	}
+	adjustment := v2.Split
+	if unadjusted != nil && *unadjusted {
+		adjustment = v2.Raw
+	}
+
+	agg := &HistoricAggregatesV2{Symbol: symbol, Adjusted: adjustment != v2.Raw}
+	for item := range c.data.GetBars(symbol, timeFrame, adjustment, *from, *to, 50000) {
+		if item.Error != nil {
+			return nil, item.Error
+		}
+		agg.Ticks = append(agg.Ticks, AggTick{
+			Open:              item.Bar.Open,
+			High:              item.Bar.High,
+			Low:               item.Bar.Low,
+			Close:             item.Bar.Close,
+			Volume:            float64(item.Bar.Volume),
+			EpochMilliseconds: item.Bar.Timestamp.UnixNano() / int64(time.Millisecond),
+		})
+	}
+	agg.QueryCount = len(agg.Ticks)
+	agg.ResultsCount = len(agg.Ticks)
+	return agg, nil
+}
+
+// ticksRange returns the time range of a ticks request for the given
+// trading date (2006-01-02), starting at opts.Timestamp when set.
+func ticksRange(date string, opts *HistoricTicksV2Params) (start, end time.Time, limit int, err error) {
+	day, err := time.ParseInLocation("2006-01-02", date, newYork)
+	if err != nil {
+		return
+	}
+	start, end, limit = day, day.AddDate(0, 0, 1), defaultTicksLimit
+	if opts == nil {
+		return
+	}
+	if opts.Reverse {
+		err = errors.New("reverse ticks are not supported by Alpaca Data v2")
+		return
+	}
+	if opts.Timestamp > 0 {
+		start = time.Unix(0, opts.Timestamp)
+	}
+	if opts.TimestampLimit > 0 {
+		end = time.Unix(0, opts.TimestampLimit)
+	}
+	if opts.Limit > 0 {
+		limit = int(opts.Limit)
+	}
+	return
+}
+
+func (c *Client) tradesFromV2(ticker, date string, opts *HistoricTicksV2Params) (*HistoricTradesV2, error) {
+	start, end, limit, err := ticksRange(date, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	trades := &HistoricTradesV2{Ticker: ticker}
+	for item := range c.data.GetTrades(ticker, start, end, limit) {
+		if item.Error != nil {
+			return nil, item.Error
+		}
+		t := item.Trade
+		timestamp := t.Timestamp.UnixNano()
+		id := strconv.FormatInt(t.ID, 10)
+		size := int(t.Size)
+		price := t.Price
+		trades.Results = append(trades.Results, TradeTickV2{
+			SIPTimestamp: &timestamp,
+			ID:           &id,
+			Size:         &size,
+			Price:        &price,
+		})
+	}
+	trades.ResultsCount = int64(len(trades.Results))
+	return trades, nil
+}
+
+func (c *Client) quotesFromV2(ticker, date string, opts *HistoricTicksV2Params) (*HistoricQuotesV2, error) {
+	start, end, limit, err := ticksRange(date, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	quotes := &HistoricQuotesV2{Ticker: ticker}
+	for item := range c.data.GetQuotes(ticker, start, end, limit) {
+		if item.Error != nil {
+			return nil, item.Error
+		}
+		q := item.Quote
+		timestamp := q.Timestamp.UnixNano()
+		bidPrice, askPrice := q.BidPrice, q.AskPrice
+		bidSize, askSize := int(q.BidSize), int(q.AskSize)
+		quotes.Results = append(quotes.Results, QuoteTickV2{
+			SIPTimestamp: &timestamp,
+			BidPrice:     &bidPrice,
+			AskPrice:     &askPrice,
+			BidSize:      &bidSize,
+			AskSize:      &askSize,
+		})
+	}
+	quotes.ResultsCount = int64(len(quotes.Results))
+	return quotes, nil
+}
+
+// MigrationStream serves the Polygon stream channels (T., Q. and AM.)
+// from the Alpaca Data v2 stream, delivering the Polygon entities
+// (StreamTrade, StreamQuote and StreamAggregate) to the handlers.
+// Second aggregates (A.) have no equivalent and are rejected.
+type MigrationStream struct {
+	mu       sync.RWMutex
+	handlers map[string]func(msg interface{})
+}
+
+// GetMigrationStream returns the singleton migration stream.
+func GetMigrationStream() *MigrationStream {
+	migrationOnce.Do(func() {
+		migrationStr = &MigrationStream{
+			handlers: map[string]func(msg interface{}){},
+		}
+	})
+	return migrationStr
+}
+
+// Subscribe to the specified Polygon stream channel on Alpaca Data v2.
+func (s *MigrationStream) Subscribe(channel string, handler func(msg interface{})) error {
+	event, symbol, err := splitChannel(channel)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.handlers[channel] = handler
+	s.mu.Unlock()
+
+	switch event {
+	case Trades:
+		err = subscribeTrades(s.handleTrade, symbol)
+	case Quotes:
+		err = subscribeQuotes(s.handleQuote, symbol)
+	case MinuteAggs:
+		err = subscribeBars(s.handleBar, symbol)
+	}
+	if err != nil {
+		s.mu.Lock()
+		delete(s.handlers, channel)
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// Unsubscribe the specified Polygon stream channel.
+func (s *MigrationStream) Unsubscribe(channel string) error {
+	event, symbol, err := splitChannel(channel)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.handlers, channel)
+	s.mu.Unlock()
+
+	switch event {
+	case Trades:
+		return unsubscribeTrades(symbol)
+	case Quotes:
+		return unsubscribeQuotes(symbol)
+	default:
+		return unsubscribeBars(symbol)
+	}
+}
+
+// Close closes the underlying Alpaca Data v2 stream.
+func (s *MigrationStream) Close() error {
+	return v2stream.Close()
+}
+
+func splitChannel(channel string) (event, symbol string, err error) {
+	parts := strings.SplitN(channel, ".", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid stream (%s)", channel)
+	}
+	switch parts[0] {
+	case Trades, Quotes, MinuteAggs:
+		return parts[0], parts[1], nil
+	case SecondAggs:
+		return "", "", fmt.Errorf("second aggregates (%s) are not available on Alpaca Data v2", channel)
+	default:
+		return "", "", fmt.Errorf("invalid stream (%s)", channel)
+	}
+}
+
+func (s *MigrationStream) dispatch(event, symbol string, msg interface{}) {
+	s.mu.RLock()
+	handler, ok := s.handlers[event+"."+symbol]
+	if !ok {
+		handler = s.handlers[event+".*"]
+	}
+	s.mu.RUnlock()
+
+	if handler != nil {
+		handler(msg)
+	}
+}
+
+func (s *MigrationStream) handleTrade(t v2stream.Trade) {
+	s.dispatch(Trades, t.Symbol, StreamTrade{
+		Symbol:    t.Symbol,
+		TradeID:   strconv.FormatInt(t.ID, 10),
+		Price:     t.Price,
+		Size:      int64(t.Size),
+		Timestamp: t.Timestamp.UnixNano() / int64(time.Millisecond),
+	})
+}
+
+func (s *MigrationStream) handleQuote(q v2stream.Quote) {
+	s.dispatch(Quotes, q.Symbol, StreamQuote{
+		Symbol:    q.Symbol,
+		BidPrice:  q.BidPrice,
+		AskPrice:  q.AskPrice,
+		BidSize:   int64(q.BidSize),
+		AskSize:   int64(q.AskSize),
+		Timestamp: q.Timestamp.UnixNano() / int64(time.Millisecond),
+	})
+}
+
+func (s *MigrationStream) handleBar(b v2stream.Bar) {
+	start := b.Timestamp.UnixNano() / int64(time.Millisecond)
+	s.dispatch(MinuteAggs, b.Symbol, StreamAggregate{
+		Event:          MinuteAggs,
+		Symbol:         b.Symbol,
+		Volume:         int(b.Volume),
+		OpenPrice:      b.Open,
+		ClosePrice:     b.Close,
+		HighPrice:      b.High,
+		LowPrice:       b.Low,
+		StartTimestamp: start,
+		EndTimestamp:   start + int64(time.Minute/time.Millisecond),
+	})
+}
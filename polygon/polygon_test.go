@@ -4,12 +4,19 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
 
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	v2stream "github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -122,6 +129,127 @@ func (s *PolygonTestSuite) TestPolygon() {
 	}
 }
 
+func (s *PolygonTestSuite) TestMigrationClient() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/stocks/AAPL/bars":
+			assert.Equal(s.T(), "5Min", r.URL.Query().Get("timeframe"))
+			assert.Equal(s.T(), "raw", r.URL.Query().Get("adjustment"))
+			fmt.Fprint(w, `{"bars":[{"o":1,"h":3,"l":0.5,"c":2,"v":100,"t":"2021-10-15T13:30:00Z"}],"symbol":"AAPL","next_page_token":null}`)
+		case "/v2/stocks/AAPL/trades":
+			assert.Equal(s.T(), "2021-10-15T00:00:00-04:00", r.URL.Query().Get("start"))
+			assert.Equal(s.T(), "10", r.URL.Query().Get("limit"))
+			fmt.Fprint(w, `{"trades":[{"i":42,"x":"V","p":144.5,"s":10,"t":"2021-10-15T13:30:00Z"}],"symbol":"AAPL","next_page_token":null}`)
+		case "/v2/stocks/AAPL/quotes":
+			fmt.Fprint(w, `{"quotes":[{"bp":144.4,"bs":2,"ap":144.6,"as":3,"t":"2021-10-15T13:30:00Z"}],"symbol":"AAPL","next_page_token":null}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := &Client{
+		credentials: &common.APIKey{ID: "key", Secret: "secret"},
+		data: alpaca.NewClientWithOptions(
+			alpaca.WithCredentials(&common.APIKey{ID: "key", Secret: "secret"}),
+			alpaca.WithDataURL(server.URL),
+		),
+	}
+	ts := time.Date(2021, 10, 15, 13, 30, 0, 0, time.UTC)
+
+	from, to := ts, ts.Add(time.Hour)
+	unadjusted := true
+	aggs, err := c.GetHistoricAggregatesV2("AAPL", 5, Minute, &from, &to, &unadjusted)
+	require.NoError(s.T(), err)
+	assert.False(s.T(), aggs.Adjusted)
+	require.Len(s.T(), aggs.Ticks, 1)
+	assert.Equal(s.T(), AggTick{Open: 1, High: 3, Low: 0.5, Close: 2, Volume: 100,
+		EpochMilliseconds: ts.UnixNano() / int64(time.Millisecond)}, aggs.Ticks[0])
+
+	_, err = c.GetHistoricAggregatesV2("AAPL", 1, AggType("week"), &from, &to, nil)
+	assert.Error(s.T(), err)
+
+	trades, err := c.GetHistoricTradesV2("AAPL", "2021-10-15", &HistoricTicksV2Params{Limit: 10})
+	require.NoError(s.T(), err)
+	require.Len(s.T(), trades.Results, 1)
+	assert.Equal(s.T(), "42", *trades.Results[0].ID)
+	assert.Equal(s.T(), 144.5, *trades.Results[0].Price)
+	assert.Equal(s.T(), ts.UnixNano(), *trades.Results[0].SIPTimestamp)
+
+	_, err = c.GetHistoricTradesV2("AAPL", "2021-10-15", &HistoricTicksV2Params{Reverse: true})
+	assert.Error(s.T(), err)
+
+	quotes, err := c.GetHistoricQuotesV2("AAPL", "2021-10-15", nil)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), quotes.Results, 1)
+	assert.Equal(s.T(), 144.4, *quotes.Results[0].BidPrice)
+	assert.Equal(s.T(), 3, *quotes.Results[0].AskSize)
+}
+
+func (s *PolygonTestSuite) TestDeprecated() {
+	var buf bytes.Buffer
+	Logger = common.NewStdLogger(log.New(&buf, "", 0), common.LevelInfo)
+	defer func() { Logger = nil }()
+
+	// the deprecation is logged once per API
+	deprecated("TestAPI", "TestReplacement")
+	deprecated("TestAPI", "TestReplacement")
+	assert.Equal(s.T(), `level=WARN msg="deprecated Polygon API, the Polygon integration is not available for new API keys"`+
+		" client=polygon api=TestAPI replacement=TestReplacement\n", buf.String())
+}
+
+func (s *PolygonTestSuite) TestMigrationStream() {
+	var tradeHandler func(v2stream.Trade)
+	var barHandler func(v2stream.Bar)
+	var subscribed, unsubscribed []string
+	subscribeTrades = func(handler func(v2stream.Trade), symbols ...string) error {
+		tradeHandler = handler
+		subscribed = append(subscribed, symbols...)
+		return nil
+	}
+	subscribeBars = func(handler func(v2stream.Bar), symbols ...string) error {
+		barHandler = handler
+		subscribed = append(subscribed, symbols...)
+		return nil
+	}
+	unsubscribeBars = func(symbols ...string) error {
+		unsubscribed = append(unsubscribed, symbols...)
+		return nil
+	}
+	defer func() {
+		subscribeTrades = v2stream.SubscribeTrades
+		subscribeBars = v2stream.SubscribeBars
+		unsubscribeBars = v2stream.UnsubscribeBars
+	}()
+
+	ms := &MigrationStream{handlers: map[string]func(msg interface{}){}}
+	var msgs []interface{}
+	handler := func(msg interface{}) { msgs = append(msgs, msg) }
+
+	assert.Error(s.T(), ms.Subscribe("A.AAPL", handler))
+	assert.Error(s.T(), ms.Subscribe("X.AAPL", handler))
+	require.NoError(s.T(), ms.Subscribe("T.*", handler))
+	require.NoError(s.T(), ms.Subscribe("AM.AAPL", handler))
+	assert.Equal(s.T(), []string{"*", "AAPL"}, subscribed)
+
+	ts := time.Date(2021, 10, 15, 13, 30, 0, 0, time.UTC)
+	tradeHandler(v2stream.Trade{ID: 7, Symbol: "MSFT", Price: 300, Size: 5, Timestamp: ts})
+	barHandler(v2stream.Bar{Symbol: "AAPL", Open: 1, High: 3, Low: 0.5, Close: 2, Volume: 100, Timestamp: ts})
+	barHandler(v2stream.Bar{Symbol: "MSFT", Timestamp: ts})
+
+	require.Len(s.T(), msgs, 2)
+	ms1 := ts.UnixNano() / int64(time.Millisecond)
+	assert.Equal(s.T(), StreamTrade{Symbol: "MSFT", TradeID: "7", Price: 300, Size: 5, Timestamp: ms1}, msgs[0])
+	assert.Equal(s.T(), StreamAggregate{Event: MinuteAggs, Symbol: "AAPL", Volume: 100,
+		OpenPrice: 1, ClosePrice: 2, HighPrice: 3, LowPrice: 0.5,
+		StartTimestamp: ms1, EndTimestamp: ms1 + 60000}, msgs[1])
+
+	require.NoError(s.T(), ms.Unsubscribe("AM.AAPL"))
+	assert.Equal(s.T(), []string{"AAPL"}, unsubscribed)
+	barHandler(v2stream.Bar{Symbol: "AAPL", Timestamp: ts})
+	assert.Len(s.T(), msgs, 2)
+}
+
 type nopCloser struct {
 	io.Reader
 }
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
 	"github.com/market-development-strategy/alpaca-trade-api-go/common"
 	try "gopkg.in/matryer/try.v1"
 )
@@ -26,15 +27,27 @@ const (
 )
 
 var (
-	// DefaultClient is the default Polygon client using the
-	// environment variable set credentials
-	DefaultClient = NewClient(common.Credentials())
-	base          = "https://api.polygon.io"
-	get           = func(u *url.URL) (*http.Response, error) {
+	// DefaultClient is the default Polygon client. It has no credentials of
+	// its own and reads common.Credentials() for every request.
+	DefaultClient = NewClient(nil)
+
+	// Logger is the logger of the package, common.DefaultLogger if nil
+	Logger common.Logger
+
+	base = "https://api.polygon.io"
+	get  = func(u *url.URL) (*http.Response, error) {
 		return http.Get(u.String())
 	}
 )
 
+// logger returns the logger of the package
+func logger() common.Logger {
+	if Logger != nil {
+		return Logger
+	}
+	return common.DefaultLogger
+}
+
 func init() {
 	if s := os.Getenv("POLYGON_BASE_URL"); s != "" {
 		base = s
@@ -55,14 +68,24 @@ func (e *APIError) Error() string {
 // Client is a Polygon REST API client
 type Client struct {
 	credentials *common.APIKey
+	// data serves the v2 methods from Alpaca Data v2 when set
+	data *alpaca.Client
 }
 
 // NewClient creates a new Polygon client with specified
-// credentials
+// credentials. With nil credentials the client reads
+// common.Credentials() for every request.
 func NewClient(credentials *common.APIKey) *Client {
 	return &Client{credentials: credentials}
 }
 
+func (c *Client) polygonKeyID() string {
+	if c.credentials == nil {
+		return common.Credentials().PolygonKeyID
+	}
+	return c.credentials.PolygonKeyID
+}
+
 // GetHistoricAggregates requests Polygon's v1 REST API for historic aggregates
 // for the provided resolution based on the provided query parameters.
 func (c *Client) GetHistoricAggregates(
@@ -70,6 +93,7 @@ func (c *Client) GetHistoricAggregates(
 	resolution AggType,
 	from, to *time.Time,
 	limit *int) (*HistoricAggregates, error) {
+	deprecated("GetHistoricAggregates", "alpaca.GetBars")
 
 	u, err := url.Parse(fmt.Sprintf(aggURL, base, resolution, symbol))
 	if err != nil {
@@ -77,7 +101,7 @@ func (c *Client) GetHistoricAggregates(
 	}
 
 	q := u.Query()
-	q.Set("apiKey", c.credentials.PolygonKeyID)
+	q.Set("apiKey", c.polygonKeyID())
 
 	if from != nil {
 		q.Set("from", from.Format(time.RFC3339))
@@ -113,12 +137,17 @@ func (c *Client) GetHistoricAggregates(
 
 // GetHistoricAggregates requests Polygon's v2 REST API for historic aggregates
 // for the provided resolution based on the provided query parameters.
+// A client created by NewMigrationClient serves it from Alpaca Data v2.
 func (c *Client) GetHistoricAggregatesV2(
 	symbol string,
 	multiplier int,
 	resolution AggType,
 	from, to *time.Time,
 	unadjusted *bool) (*HistoricAggregatesV2, error) {
+	if c.data != nil {
+		return c.aggregatesFromV2(symbol, multiplier, resolution, from, to, unadjusted)
+	}
+	deprecated("GetHistoricAggregatesV2", "alpaca.GetBars")
 
 	u, err := url.Parse(fmt.Sprintf(aggv2URL, base, symbol, multiplier, resolution, from.Unix()*1000, to.Unix()*1000))
 	if err != nil {
@@ -126,7 +155,7 @@ func (c *Client) GetHistoricAggregatesV2(
 	}
 
 	q := u.Query()
-	q.Set("apiKey", c.credentials.PolygonKeyID)
+	q.Set("apiKey", c.polygonKeyID())
 
 	if unadjusted != nil {
 		q.Set("unadjusted", strconv.FormatBool(*unadjusted))
@@ -161,6 +190,7 @@ func (c *Client) GetHistoricTrades(
 	symbol string,
 	date string,
 	opts *GetHistoricTradesParams) (totalTrades *HistoricTrades, err error) {
+	deprecated("GetHistoricTrades", "alpaca.GetTrades")
 
 	offset := int64(0)
 	limit := int64(10000)
@@ -177,7 +207,7 @@ func (c *Client) GetHistoricTrades(
 		}
 
 		q := u.Query()
-		q.Set("apiKey", c.credentials.PolygonKeyID)
+		q.Set("apiKey", c.polygonKeyID())
 		q.Set("limit", strconv.FormatInt(limit, 10))
 
 		if offset > 0 {
@@ -223,14 +253,20 @@ func (c *Client) GetHistoricTrades(
 
 // GetHistoricTradesV2 requests polygon's REST API for historic trades
 // on the provided date.
+// A client created by NewMigrationClient serves it from Alpaca Data v2.
 func (c *Client) GetHistoricTradesV2(ticker string, date string, opts *HistoricTicksV2Params) (*HistoricTradesV2, error) {
+	if c.data != nil {
+		return c.tradesFromV2(ticker, date, opts)
+	}
+	deprecated("GetHistoricTradesV2", "alpaca.GetTrades")
+
 	u, err := url.Parse(fmt.Sprintf(tradesv2URL, base, ticker, date))
 	if err != nil {
 		return nil, err
 	}
 
 	q := u.Query()
-	q.Set("apiKey", c.credentials.PolygonKeyID)
+	q.Set("apiKey", c.polygonKeyID())
 	u.RawQuery = q.Encode()
 
 	resp, err := c.get(u, opts)
@@ -253,6 +289,7 @@ func (c *Client) GetHistoricTradesV2(ticker string, date string, opts *HistoricT
 // Deprecated: This v1 endpoint should no longer be used, as it will be removed from the Polygon API
 // in the future. Please use GetHistoricQuotesV2 instead.
 func (c *Client) GetHistoricQuotes(symbol, date string) (totalQuotes *HistoricQuotes, err error) {
+	deprecated("GetHistoricQuotes", "alpaca.GetQuotes")
 	offset := int64(0)
 	for {
 		u, err := url.Parse(fmt.Sprintf(quotesURL, base, symbol, date))
@@ -261,7 +298,7 @@ func (c *Client) GetHistoricQuotes(symbol, date string) (totalQuotes *HistoricQu
 		}
 
 		q := u.Query()
-		q.Set("apiKey", c.credentials.PolygonKeyID)
+		q.Set("apiKey", c.polygonKeyID())
 		q.Set("limit", strconv.FormatInt(10000, 10))
 
 		if offset > 0 {
@@ -307,14 +344,20 @@ func (c *Client) GetHistoricQuotes(symbol, date string) (totalQuotes *HistoricQu
 
 // GetHistoricQuotesV2 requests polygon's REST API for historic trades
 // on the provided date.
+// A client created by NewMigrationClient serves it from Alpaca Data v2.
 func (c *Client) GetHistoricQuotesV2(ticker string, date string, opts *HistoricTicksV2Params) (*HistoricQuotesV2, error) {
+	if c.data != nil {
+		return c.quotesFromV2(ticker, date, opts)
+	}
+	deprecated("GetHistoricQuotesV2", "alpaca.GetQuotes")
+
 	u, err := url.Parse(fmt.Sprintf(quotesv2URL, base, ticker, date))
 	if err != nil {
 		return nil, err
 	}
 
 	q := u.Query()
-	q.Set("apiKey", c.credentials.PolygonKeyID)
+	q.Set("apiKey", c.polygonKeyID())
 	u.RawQuery = q.Encode()
 
 	resp, err := c.get(u, opts)
@@ -333,13 +376,14 @@ func (c *Client) GetHistoricQuotesV2(ticker string, date string, opts *HistoricT
 
 // GetStockExchanges requests available stock and equity exchanges on polygon.io
 func (c *Client) GetStockExchanges() ([]StockExchange, error) {
+	deprecated("GetStockExchanges", "the exchange codes of Alpaca Data v2")
 	u, err := url.Parse(fmt.Sprintf(exchangeURL, base))
 	if err != nil {
 		return nil, err
 	}
 
 	q := u.Query()
-	q.Set("apiKey", c.credentials.PolygonKeyID)
+	q.Set("apiKey", c.polygonKeyID())
 
 	u.RawQuery = q.Encode()
 
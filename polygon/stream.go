@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"strings"
 	"sync"
@@ -123,7 +122,7 @@ func (s *Stream) handleError(err error) {
 			return
 		}
 	} else {
-		log.Printf("polygon stream read error (%v)", err)
+		logger().Warn("stream read error, reconnecting", "client", "polygon_stream", "error", err)
 	}
 
 	s.reconnect()
@@ -256,7 +255,11 @@ func (s *Stream) auth() (err error) {
 }
 
 // GetStream returns the singleton Polygon stream structure.
+//
+// Deprecated: use the Alpaca Data v2 stream (v2/stream), or
+// GetMigrationStream to keep the Polygon channels and entities.
 func GetStream() *Stream {
+	deprecated("GetStream", "v2/stream or GetMigrationStream")
 	once.Do(func() {
 		str = &Stream{
 			authenticated: atomic.Value{},
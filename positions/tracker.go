@@ -0,0 +1,304 @@
+// Package positions maintains the positions of an account in real time from
+// the fills of the trade updates stream, instead of polling GetPosition after
+// every fill, e.g.
+//
+//	tracker := positions.New(client)
+//	if err := tracker.Start(); err != nil {
+//		panic(err)
+//	}
+//	stream.SubscribeTradeUpdates(tracker.HandleTradeUpdate)
+//	go tracker.Run(ctx)
+//	...
+//	pos := tracker.Position("AAPL")
+package positions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+)
+
+// Position is the tracked position in a symbol
+type Position struct {
+	Symbol string
+	// Qty is negative for a short position
+	Qty           decimal.Decimal
+	AvgEntryPrice decimal.Decimal
+	// RealizedPL is the profit or loss realized by the fills tracked since
+	// the position was last loaded from the REST API
+	RealizedPL decimal.Decimal
+	UpdatedAt  time.Time
+}
+
+// CostBasis returns the cost of the position at its average entry price,
+// negative for a short position.
+func (p Position) CostBasis() decimal.Decimal {
+	return p.Qty.Mul(p.AvgEntryPrice)
+}
+
+// Drift is a difference between a tracked position and the position of the
+// REST API, after which the tracked position was reset to the REST one
+type Drift struct {
+	Symbol string
+	// Tracked is the quantity that was tracked
+	Tracked decimal.Decimal
+	// Actual is the quantity of the REST API
+	Actual decimal.Decimal
+}
+
+// Option configures a Tracker
+type Option func(t *Tracker)
+
+// WithInterval sets how often Run reconciles, every 5 minutes by default.
+func WithInterval(interval time.Duration) Option {
+	return func(t *Tracker) {
+		t.interval = interval
+	}
+}
+
+// WithClock sets the clock Run waits on, clock.Real by default.
+func WithClock(c clock.Clock) Option {
+	return func(t *Tracker) {
+		t.clock = c
+	}
+}
+
+// OnDrift sets the callback called when a tracked position is corrected.
+func OnDrift(callback func(d Drift)) Option {
+	return func(t *Tracker) {
+		t.onDrift = callback
+	}
+}
+
+// Tracker tracks the positions of an account. The fills are applied once
+// each, by the progress of the filled quantity of their order, so repeated
+// or late updates do not count twice.
+type Tracker struct {
+	client   alpaca.TradingClient
+	interval time.Duration
+	clock    clock.Clock
+	onDrift  func(d Drift)
+
+	mu        sync.RWMutex
+	positions map[string]*Position
+	fills     *alpaca.FillDeltas
+	// suspects are the symbols whose tracked quantity differed from the
+	// REST one at the last reconciliation, with the REST quantity
+	suspects map[string]decimal.Decimal
+}
+
+// New creates a tracker loading and reconciling the positions with client.
+func New(client alpaca.TradingClient, opts ...Option) *Tracker {
+	t := &Tracker{
+		client:    client,
+		interval:  5 * time.Minute,
+		clock:     clock.Real,
+		positions: map[string]*Position{},
+		fills:     alpaca.NewFillDeltas(),
+		suspects:  map[string]decimal.Decimal{},
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Start loads the positions from the REST API. The quantities already filled
+// of the open orders are in the positions, so only their later fills are
+// applied.
+func (t *Tracker) Start() error {
+	positions, err := t.client.ListPositions()
+	if err != nil {
+		return err
+	}
+	status := "open"
+	limit := 500
+	open, err := t.client.ListOrders(&status, nil, &limit, nil)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.clock.Now()
+	t.positions = map[string]*Position{}
+	for _, p := range positions {
+		t.positions[p.Symbol] = fromREST(p, now)
+	}
+	t.fills = alpaca.NewFillDeltas()
+	for _, order := range open {
+		t.fills.Seed(order)
+	}
+	return nil
+}
+
+func fromREST(p alpaca.Position, now time.Time) *Position {
+	qty := p.Qty
+	if p.Side == "short" && qty.IsPositive() {
+		qty = qty.Neg()
+	}
+	return &Position{
+		Symbol:        p.Symbol,
+		Qty:           qty,
+		AvgEntryPrice: p.EntryPrice,
+		UpdatedAt:     now,
+	}
+}
+
+// HandleTradeUpdate applies the fill of a trade update. It can be passed
+// to stream.SubscribeTradeUpdates.
+func (t *Tracker) HandleTradeUpdate(update alpaca.TradeUpdate) {
+	switch {
+	case update.Event.IsFill():
+		t.apply(update.Order)
+	case update.Event.IsTerminal():
+		t.forget(update.Order.ID)
+	}
+}
+
+// HandleTradeEvent applies the fill of a typed trade update. It can be
+// passed to stream.SubscribeTradeEvents.
+func (t *Tracker) HandleTradeEvent(event stream.TradeUpdateEvent) {
+	switch {
+	case event.GetEvent().IsFill():
+		t.apply(event.GetOrder())
+	case event.GetEvent().IsTerminal():
+		t.forget(event.GetOrder().ID)
+	}
+}
+
+// apply applies the quantity of order filled since it was last applied, at
+// the price it was filled at, derived from the average fill price
+func (t *Tracker) apply(order alpaca.Order) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	qty, price, ok := t.fills.Order(order)
+	if !ok {
+		return
+	}
+	p, ok := t.positions[order.Symbol]
+	if !ok {
+		p = &Position{Symbol: order.Symbol}
+		t.positions[order.Symbol] = p
+	}
+	p.add(qty, price)
+	p.UpdatedAt = t.clock.Now()
+	if p.Qty.IsZero() {
+		delete(t.positions, order.Symbol)
+	}
+}
+
+// forget drops the fills of an order canceled, expired or rejected
+func (t *Tracker) forget(orderID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fills.Forget(orderID)
+}
+
+// add adds the signed quantity qty filled at price, realizing the profit or
+// loss of the quantity it closes
+func (p *Position) add(qty, price decimal.Decimal) {
+	var realized decimal.Decimal
+	p.Qty, p.AvgEntryPrice, realized = alpaca.ApplyFill(p.Qty, p.AvgEntryPrice, qty, price)
+	p.RealizedPL = p.RealizedPL.Add(realized)
+}
+
+// Position returns the tracked position in symbol, with a zero quantity if
+// there is none.
+func (t *Tracker) Position(symbol string) Position {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if p, ok := t.positions[symbol]; ok {
+		return *p
+	}
+	return Position{Symbol: symbol}
+}
+
+// Positions returns the tracked positions.
+func (t *Tracker) Positions() []Position {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	positions := make([]Position, 0, len(t.positions))
+	for _, p := range t.positions {
+		positions = append(positions, *p)
+	}
+	return positions
+}
+
+// Run reconciles at the interval of the tracker until ctx is done. The
+// errors of the reconciliations are retried at the next one.
+func (t *Tracker) Run(ctx context.Context) error {
+	ticker := t.clock.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+			t.Reconcile()
+		}
+	}
+}
+
+// Reconcile compares the tracked positions with the REST ones. As fills may
+// be in flight between the REST API and the stream, a position is only reset
+// to the REST one, and reported with OnDrift, when it differed at two
+// reconciliations in a row with the same REST quantity.
+func (t *Tracker) Reconcile() error {
+	positions, err := t.client.ListPositions()
+	if err != nil {
+		return err
+	}
+	now := t.clock.Now()
+	actual := make(map[string]*Position, len(positions))
+	for _, p := range positions {
+		actual[p.Symbol] = fromREST(p, now)
+	}
+
+	var drifts []Drift
+	t.mu.Lock()
+	symbols := map[string]bool{}
+	for symbol := range t.positions {
+		symbols[symbol] = true
+	}
+	for symbol := range actual {
+		symbols[symbol] = true
+	}
+	suspects := map[string]decimal.Decimal{}
+	for symbol := range symbols {
+		tracked, remote := decimal.Zero, decimal.Zero
+		if p, ok := t.positions[symbol]; ok {
+			tracked = p.Qty
+		}
+		if p, ok := actual[symbol]; ok {
+			remote = p.Qty
+		}
+		if tracked.Equal(remote) {
+			continue
+		}
+		if prev, ok := t.suspects[symbol]; !ok || !prev.Equal(remote) {
+			suspects[symbol] = remote
+			continue
+		}
+		drifts = append(drifts, Drift{Symbol: symbol, Tracked: tracked, Actual: remote})
+		if p, ok := actual[symbol]; ok {
+			t.positions[symbol] = p
+		} else {
+			delete(t.positions, symbol)
+		}
+	}
+	t.suspects = suspects
+	t.mu.Unlock()
+
+	if t.onDrift != nil {
+		for _, d := range drifts {
+			t.onDrift(d)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package positions
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type TrackerTestSuite struct {
+	suite.Suite
+	remote  []alpaca.Position
+	open    []alpaca.Order
+	clock   *clock.Fake
+	tracker *Tracker
+	drifts  []Drift
+}
+
+func TestTrackerTestSuite(t *testing.T) {
+	suite.Run(t, new(TrackerTestSuite))
+}
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func (s *TrackerTestSuite) SetupTest() {
+	s.remote = []alpaca.Position{
+		{Symbol: "AAPL", Qty: dec("10"), EntryPrice: dec("100"), Side: "long"},
+		{Symbol: "TSLA", Qty: dec("5"), EntryPrice: dec("600"), Side: "short"},
+	}
+	price := dec("100")
+	s.open = []alpaca.Order{
+		{ID: "open", Symbol: "AAPL", Side: alpaca.Buy, FilledQty: dec("10"), FilledAvgPrice: &price},
+	}
+	mock := &alpacatest.MockClient{
+		ListPositionsFunc: func() ([]alpaca.Position, error) {
+			return s.remote, nil
+		},
+		ListOrdersFunc: func(status *string, until *time.Time, limit *int, nested *bool) ([]alpaca.Order, error) {
+			return s.open, nil
+		},
+	}
+	s.drifts = nil
+	s.clock = clock.NewFake(time.Date(2021, 10, 14, 13, 30, 0, 0, time.UTC))
+	s.tracker = New(mock,
+		WithClock(s.clock),
+		WithInterval(time.Minute),
+		OnDrift(func(d Drift) { s.drifts = append(s.drifts, d) }),
+	)
+	require.NoError(s.T(), s.tracker.Start())
+}
+
+func fillUpdate(id, symbol string, side alpaca.Side, filled, avg string) alpaca.TradeUpdate {
+	price := dec(avg)
+	return alpaca.TradeUpdate{Event: alpaca.EventPartialFill, Order: alpaca.Order{
+		ID: id, Symbol: symbol, Side: side, FilledQty: dec(filled), FilledAvgPrice: &price,
+	}}
+}
+
+func (s *TrackerTestSuite) TestFills() {
+	assert.True(s.T(), s.tracker.Position("TSLA").Qty.Equal(dec("-5")))
+
+	// two partial fills of a buy, at 110 then 120
+	s.tracker.HandleTradeUpdate(fillUpdate("1", "AAPL", alpaca.Buy, "10", "110"))
+	s.tracker.HandleTradeUpdate(fillUpdate("1", "AAPL", alpaca.Buy, "20", "115"))
+	// repeated and late updates are ignored
+	s.tracker.HandleTradeUpdate(fillUpdate("1", "AAPL", alpaca.Buy, "20", "115"))
+	s.tracker.HandleTradeUpdate(fillUpdate("1", "AAPL", alpaca.Buy, "10", "110"))
+	pos := s.tracker.Position("AAPL")
+	assert.True(s.T(), pos.Qty.Equal(dec("30")))
+	assert.True(s.T(), pos.AvgEntryPrice.Equal(dec("110")), pos.AvgEntryPrice.String())
+	assert.True(s.T(), pos.CostBasis().Equal(dec("3300")))
+
+	// selling 40 closes the position and opens a short of 10
+	s.tracker.HandleTradeEvent(stream.FillEvent{OrderEvent: stream.OrderEvent{
+		Event: alpaca.EventFill,
+		Order: fillUpdate("2", "AAPL", alpaca.Sell, "40", "120").Order,
+	}})
+	pos = s.tracker.Position("AAPL")
+	assert.True(s.T(), pos.Qty.Equal(dec("-10")))
+	assert.True(s.T(), pos.AvgEntryPrice.Equal(dec("120")))
+	assert.True(s.T(), pos.RealizedPL.Equal(dec("300")))
+
+	// covering the short of TSLA removes it
+	s.tracker.HandleTradeUpdate(fillUpdate("3", "TSLA", alpaca.Buy, "5", "590"))
+	assert.True(s.T(), s.tracker.Position("TSLA").Qty.IsZero())
+	assert.Len(s.T(), s.tracker.Positions(), 1)
+
+	// non fill events are ignored
+	update := fillUpdate("4", "MSFT", alpaca.Buy, "5", "200")
+	update.Event = alpaca.EventCanceled
+	s.tracker.HandleTradeUpdate(update)
+	assert.True(s.T(), s.tracker.Position("MSFT").Qty.IsZero())
+}
+
+func (s *TrackerTestSuite) TestReconcile() {
+	// an in flight fill is reflected by the REST API before the stream
+	s.remote[0].Qty = dec("15")
+	require.NoError(s.T(), s.tracker.Reconcile())
+	assert.Empty(s.T(), s.drifts)
+	s.tracker.HandleTradeUpdate(fillUpdate("1", "AAPL", alpaca.Buy, "5", "100"))
+	require.NoError(s.T(), s.tracker.Reconcile())
+	assert.Empty(s.T(), s.drifts)
+
+	// a missed fill is corrected at the second reconciliation
+	s.remote = s.remote[:1]
+	require.NoError(s.T(), s.tracker.Reconcile())
+	assert.Empty(s.T(), s.drifts)
+	require.NoError(s.T(), s.tracker.Reconcile())
+	require.Len(s.T(), s.drifts, 1)
+	assert.Equal(s.T(), "TSLA", s.drifts[0].Symbol)
+	assert.True(s.T(), s.drifts[0].Tracked.Equal(dec("-5")))
+	assert.True(s.T(), s.drifts[0].Actual.IsZero())
+	assert.True(s.T(), s.tracker.Position("TSLA").Qty.IsZero())
+	assert.True(s.T(), s.tracker.Position("AAPL").Qty.Equal(dec("15")))
+}
+
+func (s *TrackerTestSuite) TestStartWithPartlyFilledOrder() {
+	// the 10 shares filled before Start are in the REST position, so only
+	// the 5 filled since are applied
+	s.tracker.HandleTradeUpdate(fillUpdate("open", "AAPL", alpaca.Buy, "15", "110"))
+	pos := s.tracker.Position("AAPL")
+	assert.True(s.T(), pos.Qty.Equal(dec("15")), pos.Qty.String())
+	assert.True(s.T(), pos.AvgEntryPrice.Equal(dec("110")), pos.AvgEntryPrice.String())
+	assert.True(s.T(), pos.UpdatedAt.Equal(s.clock.Now()))
+}
+
+func (s *TrackerTestSuite) TestRun() {
+	listed := make(chan struct{}, 1)
+	mock := &alpacatest.MockClient{
+		ListPositionsFunc: func() ([]alpaca.Position, error) {
+			listed <- struct{}{}
+			return s.remote, nil
+		},
+		ListOrdersFunc: func(status *string, until *time.Time, limit *int, nested *bool) ([]alpaca.Order, error) {
+			return nil, nil
+		},
+	}
+	drifts := make(chan Drift, 1)
+	tracker := New(mock, WithClock(s.clock), WithInterval(time.Minute), OnDrift(func(d Drift) { drifts <- d }))
+	require.NoError(s.T(), tracker.Start())
+	<-listed
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- tracker.Run(ctx) }()
+
+	// a missed fill is corrected at the second tick
+	s.remote = s.remote[:1]
+	s.clock.BlockUntil(1)
+	s.clock.Advance(time.Minute)
+	<-listed
+	s.clock.Advance(time.Minute)
+	<-listed
+	assert.Equal(s.T(), "TSLA", (<-drifts).Symbol)
+
+	cancel()
+	assert.Equal(s.T(), context.Canceled, <-done)
+}
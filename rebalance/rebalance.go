@@ -0,0 +1,335 @@
+// Package rebalance computes, and optionally places, the orders moving a
+// portfolio to target weights, e.g.
+//
+//	r := rebalance.New(client,
+//		rebalance.WithDriftBand(decimal.New(2, -2)),
+//		rebalance.WithRounding(rebalance.Notional),
+//	)
+//	results, err := r.Rebalance(rebalance.Targets{
+//		"VTI": decimal.New(6, -1),
+//		"BND": decimal.New(4, -1),
+//	})
+//
+// The weights are fractions of the equity of the account, the rest is left
+// in cash. The positions in the symbols without a target are closed.
+package rebalance
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/shopspring/decimal"
+)
+
+// fractionalPlaces are the decimal places of the fractional quantities
+const fractionalPlaces = 9
+
+// Targets are the target weights of symbols, fractions of the equity
+type Targets map[string]decimal.Decimal
+
+// Rounding is how the orders are sized
+type Rounding int
+
+const (
+	// WholeShares rounds the quantities of the orders to whole shares
+	WholeShares Rounding = iota
+	// Fractional places fractional quantities for the fractionable assets
+	Fractional
+	// Notional places notional orders, rounded to the cent, for the
+	// fractionable assets
+	Notional
+)
+
+// Portfolio is the state a rebalance starts from
+type Portfolio struct {
+	Equity decimal.Decimal
+	// Positions are the quantities held, negative for short positions
+	Positions map[string]decimal.Decimal
+	// Prices are the prices the positions are valued and the orders sized
+	// at, required for every symbol of the targets and the positions
+	Prices map[string]decimal.Decimal
+	// Fractionable are the symbols that can be traded in fractions
+	Fractionable map[string]bool
+}
+
+// Order is an order of a rebalance
+type Order struct {
+	Symbol string
+	Side   alpaca.Side
+	// Qty is the quantity of the order, zero for a notional order
+	Qty      decimal.Decimal
+	Notional decimal.Decimal
+	// Price is the price the order was sized at
+	Price decimal.Decimal
+	// Weight and Target are the weights of the symbol before and after
+	Weight decimal.Decimal
+	Target decimal.Decimal
+}
+
+// Request returns the day market order request of the order.
+func (o Order) Request() alpaca.PlaceOrderRequest {
+	symbol := o.Symbol
+	return alpaca.PlaceOrderRequest{
+		AssetKey:    &symbol,
+		Qty:         o.Qty,
+		Notional:    o.Notional,
+		Side:        o.Side,
+		Type:        alpaca.Market,
+		TimeInForce: alpaca.Day,
+	}
+}
+
+// Option configures a rebalance
+type Option func(c *config)
+
+type config struct {
+	band        decimal.Decimal
+	rounding    Rounding
+	minNotional decimal.Decimal
+	keepOthers  bool
+}
+
+// WithDriftBand leaves the symbols whose weight is within band of their
+// target as they are, e.g. decimal.New(2, -2) for 2 percentage points. The
+// positions without a target are closed whatever their weight.
+func WithDriftBand(band decimal.Decimal) Option {
+	return func(c *config) {
+		c.band = band
+	}
+}
+
+// WithRounding sets how the orders are sized, WholeShares by default.
+func WithRounding(rounding Rounding) Option {
+	return func(c *config) {
+		c.rounding = rounding
+	}
+}
+
+// WithMinOrderNotional skips the orders worth less than min, $1 by default.
+func WithMinOrderNotional(min decimal.Decimal) Option {
+	return func(c *config) {
+		c.minNotional = min
+	}
+}
+
+// KeepUntargeted leaves the positions in the symbols without a target as
+// they are, instead of closing them.
+func KeepUntargeted() Option {
+	return func(c *config) {
+		c.keepOthers = true
+	}
+}
+
+func newConfig(opts []Option) config {
+	c := config{minNotional: decimal.New(1, 0)}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// Compute returns the orders moving portfolio to targets, the sells first
+// so that they free the cash of the buys.
+func Compute(targets Targets, portfolio Portfolio, opts ...Option) ([]Order, error) {
+	c := newConfig(opts)
+	total := decimal.Zero
+	for symbol, weight := range targets {
+		if weight.IsNegative() {
+			return nil, fmt.Errorf("negative target weight %s for %s", weight, symbol)
+		}
+		total = total.Add(weight)
+	}
+	if total.GreaterThan(decimal.New(1, 0)) {
+		return nil, fmt.Errorf("target weights sum to %s, over 1", total)
+	}
+	if !portfolio.Equity.IsPositive() {
+		return nil, errors.New("no equity to rebalance")
+	}
+
+	var sells, buys []Order
+	for _, symbol := range symbols(targets, portfolio.Positions, c.keepOthers) {
+		price := portfolio.Prices[symbol]
+		if !price.IsPositive() {
+			return nil, fmt.Errorf("no price for %s", symbol)
+		}
+		held := portfolio.Positions[symbol]
+		target, targeted := targets[symbol]
+		o := Order{
+			Symbol: symbol,
+			Price:  price,
+			Weight: held.Mul(price).Div(portfolio.Equity),
+			Target: target,
+		}
+
+		if !targeted || (target.IsZero() && !held.IsZero()) {
+			// closed whole, whatever the rounding
+			o.Qty = held.Abs()
+			o.Side = alpaca.Sell
+			if held.IsNegative() {
+				o.Side = alpaca.Buy
+			}
+		} else {
+			if o.Weight.Sub(target).Abs().LessThan(c.band) {
+				continue
+			}
+			diff := target.Mul(portfolio.Equity).Sub(held.Mul(price))
+			o.Side = alpaca.Buy
+			if diff.IsNegative() {
+				o.Side = alpaca.Sell
+			}
+			c.size(&o, diff.Abs(), portfolio.Fractionable[symbol])
+		}
+		if o.Qty.IsZero() && o.Notional.IsZero() {
+			continue
+		}
+		if value := o.Qty.Mul(price).Add(o.Notional); value.LessThan(c.minNotional) {
+			continue
+		}
+		if o.Side == alpaca.Sell {
+			sells = append(sells, o)
+		} else {
+			buys = append(buys, o)
+		}
+	}
+	return append(sells, buys...), nil
+}
+
+// size sets the quantity or notional of the order trading value
+func (c config) size(o *Order, value decimal.Decimal, fractionable bool) {
+	switch {
+	case fractionable && c.rounding == Notional:
+		o.Notional = value.Truncate(2)
+	case fractionable && c.rounding == Fractional:
+		o.Qty = value.Div(o.Price).Truncate(fractionalPlaces)
+	default:
+		o.Qty = value.Div(o.Price).Floor()
+	}
+}
+
+// symbols returns the symbols to rebalance, sorted
+func symbols(targets Targets, positions map[string]decimal.Decimal, keepOthers bool) []string {
+	var symbols []string
+	for symbol := range targets {
+		symbols = append(symbols, symbol)
+	}
+	if !keepOthers {
+		for symbol, qty := range positions {
+			if _, ok := targets[symbol]; !ok && !qty.IsZero() {
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Result is an order placed by Rebalance
+type Result struct {
+	Order Order
+	// Placed is the order placed, nil if it failed with Err
+	Placed *alpaca.Order
+	Err    error
+}
+
+// Rebalancer rebalances the portfolio of an account
+type Rebalancer struct {
+	client alpaca.TradingClient
+	opts   []Option
+}
+
+// New creates a rebalancer of the account of client.
+func New(client alpaca.TradingClient, opts ...Option) *Rebalancer {
+	return &Rebalancer{client: client, opts: opts}
+}
+
+// Portfolio loads the equity and positions of the account, the latest
+// trade prices of symbols and of the positions, and which of them are
+// fractionable.
+func (r *Rebalancer) Portfolio(symbols []string) (Portfolio, error) {
+	account, err := r.client.GetAccount()
+	if err != nil {
+		return Portfolio{}, err
+	}
+	positions, err := r.client.ListPositions()
+	if err != nil {
+		return Portfolio{}, err
+	}
+	p := Portfolio{
+		Equity:       account.Equity,
+		Positions:    map[string]decimal.Decimal{},
+		Prices:       map[string]decimal.Decimal{},
+		Fractionable: map[string]bool{},
+	}
+	all := map[string]bool{}
+	for _, symbol := range symbols {
+		all[symbol] = true
+	}
+	for _, position := range positions {
+		qty := position.Qty
+		if position.Side == "short" && qty.IsPositive() {
+			qty = qty.Neg()
+		}
+		p.Positions[position.Symbol] = qty
+		all[position.Symbol] = true
+	}
+	if len(all) == 0 {
+		return p, nil
+	}
+
+	list := make([]string, 0, len(all))
+	for symbol := range all {
+		list = append(list, symbol)
+	}
+	sort.Strings(list)
+	snapshots, err := r.client.GetSnapshots(list)
+	if err != nil {
+		return Portfolio{}, err
+	}
+	for _, symbol := range list {
+		if s := snapshots[symbol]; s != nil && s.LatestTrade != nil {
+			p.Prices[symbol] = decimal.NewFromFloat(s.LatestTrade.Price)
+		}
+	}
+	if newConfig(r.opts).rounding != WholeShares {
+		for _, symbol := range list {
+			asset, err := r.client.GetAsset(symbol)
+			if err != nil {
+				return Portfolio{}, err
+			}
+			p.Fractionable[symbol] = asset.Fractionable
+		}
+	}
+	return p, nil
+}
+
+// Plan returns the orders rebalancing the account to targets, without
+// placing them.
+func (r *Rebalancer) Plan(targets Targets) ([]Order, error) {
+	symbols := make([]string, 0, len(targets))
+	for symbol := range targets {
+		symbols = append(symbols, symbol)
+	}
+	portfolio, err := r.Portfolio(symbols)
+	if err != nil {
+		return nil, err
+	}
+	return Compute(targets, portfolio, r.opts...)
+}
+
+// Rebalance places the orders rebalancing the account to targets, the sells
+// first. The buys are placed whether or not the sells filled already, the
+// orders failing for lack of buying power are returned with their error.
+func (r *Rebalancer) Rebalance(targets Targets) ([]Result, error) {
+	orders, err := r.Plan(targets)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]Result, 0, len(orders))
+	for _, o := range orders {
+		placed, err := r.client.PlaceOrder(o.Request())
+		results = append(results, Result{Order: o, Placed: placed, Err: err})
+	}
+	return results, nil
+}
@@ -0,0 +1,116 @@
+package rebalance
+
+import (
+	"testing"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func portfolio() Portfolio {
+	return Portfolio{
+		Equity: dec("10000"),
+		Positions: map[string]decimal.Decimal{
+			"VTI": dec("20"),
+			"BND": dec("50"),
+			"GME": dec("10"),
+		},
+		Prices: map[string]decimal.Decimal{
+			"VTI": dec("200"),
+			"BND": dec("80"),
+			"GME": dec("20"),
+		},
+		Fractionable: map[string]bool{"VTI": true, "BND": true},
+	}
+}
+
+var targets = Targets{"VTI": dec("0.6"), "BND": dec("0.3")}
+
+func TestCompute(t *testing.T) {
+	orders, err := Compute(targets, portfolio())
+	require.NoError(t, err)
+	require.Len(t, orders, 3)
+	// the sells first
+	assert.Equal(t, "BND", orders[0].Symbol)
+	assert.Equal(t, alpaca.Sell, orders[0].Side)
+	assert.Equal(t, "12", orders[0].Qty.String())
+	assert.Equal(t, "0.4", orders[0].Weight.String())
+	// the positions without a target are closed
+	assert.Equal(t, "GME", orders[1].Symbol)
+	assert.Equal(t, "10", orders[1].Qty.String())
+	assert.Equal(t, "VTI", orders[2].Symbol)
+	assert.Equal(t, alpaca.Buy, orders[2].Side)
+	assert.Equal(t, "10", orders[2].Qty.String())
+
+	orders, err = Compute(targets, portfolio(), WithDriftBand(dec("0.15")), KeepUntargeted())
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.Equal(t, "VTI", orders[0].Symbol)
+
+	orders, err = Compute(targets, portfolio(), WithRounding(Notional), WithMinOrderNotional(dec("1500")))
+	require.NoError(t, err)
+	require.Len(t, orders, 1)
+	assert.True(t, orders[0].Qty.IsZero())
+	assert.Equal(t, "2000", orders[0].Notional.String())
+	req := orders[0].Request()
+	assert.Equal(t, "VTI", *req.AssetKey)
+	assert.Equal(t, alpaca.Day, req.TimeInForce)
+
+	orders, err = Compute(targets, portfolio(), WithRounding(Fractional))
+	require.NoError(t, err)
+	assert.Equal(t, "12.5", orders[0].Qty.String())
+	// GME is not fractionable
+	assert.Equal(t, "10", orders[1].Qty.String())
+
+	_, err = Compute(Targets{"VTI": dec("0.6"), "BND": dec("0.5")}, portfolio())
+	assert.Error(t, err)
+	_, err = Compute(Targets{"QQQ": dec("0.1")}, portfolio())
+	assert.EqualError(t, err, "no price for QQQ")
+}
+
+func TestRebalancer(t *testing.T) {
+	var placed []alpaca.PlaceOrderRequest
+	mock := &alpacatest.MockClient{
+		GetAccountFunc: func() (*alpaca.Account, error) {
+			return &alpaca.Account{Equity: dec("10000")}, nil
+		},
+		ListPositionsFunc: func() ([]alpaca.Position, error) {
+			return []alpaca.Position{
+				{Symbol: "VTI", Qty: dec("20"), Side: "long"},
+				{Symbol: "BND", Qty: dec("50"), Side: "long"},
+				{Symbol: "GME", Qty: dec("10"), Side: "short"},
+			}, nil
+		},
+		GetSnapshotsFunc: func(symbols []string) (map[string]*v2.Snapshot, error) {
+			assert.Equal(t, []string{"BND", "GME", "VTI"}, symbols)
+			return map[string]*v2.Snapshot{
+				"VTI": {LatestTrade: &v2.Trade{Price: 200}},
+				"BND": {LatestTrade: &v2.Trade{Price: 80}},
+				"GME": {LatestTrade: &v2.Trade{Price: 20}},
+			}, nil
+		},
+		PlaceOrderFunc: func(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+			placed = append(placed, req)
+			return &alpaca.Order{ID: *req.AssetKey}, nil
+		},
+	}
+
+	results, err := New(mock).Rebalance(targets)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	require.Len(t, placed, 3)
+	// the short position is covered
+	assert.Equal(t, "GME", *placed[1].AssetKey)
+	assert.Equal(t, alpaca.Buy, placed[1].Side)
+	assert.Equal(t, "VTI", results[2].Order.Symbol)
+	assert.Equal(t, "GME", results[1].Placed.ID)
+	assert.NoError(t, results[2].Err)
+}
@@ -0,0 +1,293 @@
+// Package recorder archives the market data of the stream to CSV files
+// partitioned by symbol and trading date:
+//
+//	<dir>/<symbol>/<yyyy-mm-dd>/trades.csv
+//	<dir>/<symbol>/<yyyy-mm-dd>/quotes.csv
+//	<dir>/<symbol>/<yyyy-mm-dd>/bars.csv
+//
+// e.g.
+//
+//	r := recorder.New("ticks")
+//	defer r.Close()
+//	stream.SubscribeTrades(r.RecordTrade, "AAPL", "MSFT")
+//	stream.SubscribeQuotes(r.RecordQuote, "AAPL", "MSFT")
+package recorder
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// The names of the files of each kind of data
+const (
+	TradesFile = "trades.csv"
+	QuotesFile = "quotes.csv"
+	BarsFile   = "bars.csv"
+)
+
+var (
+	// TradesHeader is the header of the trades files
+	TradesHeader = []string{"symbol", "timestamp", "id", "exchange", "price", "size", "conditions", "tape"}
+	// QuotesHeader is the header of the quotes files
+	QuotesHeader = []string{"symbol", "timestamp", "bid_exchange", "bid_price", "bid_size",
+		"ask_exchange", "ask_price", "ask_size", "conditions", "tape"}
+	// BarsHeader is the header of the bars files
+	BarsHeader = []string{"symbol", "timestamp", "open", "high", "low", "close", "volume"}
+)
+
+// ConditionsSeparator separates the conditions of a trade or quote in their column
+const ConditionsSeparator = "|"
+
+// ErrClosed is returned when a closed recorder is flushed or closed
+var ErrClosed = errors.New("recorder is closed")
+
+var newYork = func() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}()
+
+// Option configures a Recorder
+type Option func(r *Recorder)
+
+// WithFlushInterval sets how often the buffered records are written to the
+// files, every second by default.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(r *Recorder) {
+		r.flushInterval = interval
+	}
+}
+
+type partition struct {
+	symbol, date, file string
+}
+
+type file struct {
+	f   *os.File
+	buf *bufio.Writer
+	csv *csv.Writer
+}
+
+// Recorder writes the trades, quotes and bars it is given to CSV files.
+// Records are buffered and flushed periodically, and when a symbol moves
+// on to the next trading date the files of the previous date are closed.
+// Existing files are appended to.
+type Recorder struct {
+	dir           string
+	flushInterval time.Duration
+
+	mu     sync.Mutex
+	files  map[partition]*file
+	err    error
+	closed bool
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New creates a recorder writing to dir.
+func New(dir string, opts ...Option) *Recorder {
+	r := &Recorder{
+		dir:           dir,
+		flushInterval: time.Second,
+		files:         map[partition]*file{},
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	r.wg.Add(1)
+	go r.flushPeriodically()
+	return r
+}
+
+func (r *Recorder) flushPeriodically() {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.Flush()
+		}
+	}
+}
+
+// RecordTrade records a trade. It can be passed to stream.SubscribeTrades.
+func (r *Recorder) RecordTrade(t stream.Trade) {
+	r.write(t.Symbol, t.Timestamp, TradesFile, TradesHeader, []string{
+		t.Symbol,
+		formatTime(t.Timestamp),
+		strconv.FormatInt(t.ID, 10),
+		string(t.Exchange),
+		formatFloat(t.Price),
+		strconv.FormatUint(uint64(t.Size), 10),
+		strings.Join(t.Conditions, ConditionsSeparator),
+		string(t.Tape),
+	})
+}
+
+// RecordQuote records a quote. It can be passed to stream.SubscribeQuotes.
+func (r *Recorder) RecordQuote(q stream.Quote) {
+	r.write(q.Symbol, q.Timestamp, QuotesFile, QuotesHeader, []string{
+		q.Symbol,
+		formatTime(q.Timestamp),
+		string(q.BidExchange),
+		formatFloat(q.BidPrice),
+		strconv.FormatUint(uint64(q.BidSize), 10),
+		string(q.AskExchange),
+		formatFloat(q.AskPrice),
+		strconv.FormatUint(uint64(q.AskSize), 10),
+		strings.Join(q.Conditions, ConditionsSeparator),
+		string(q.Tape),
+	})
+}
+
+// RecordBar records a bar. It can be passed to stream.SubscribeBars.
+func (r *Recorder) RecordBar(b stream.Bar) {
+	r.write(b.Symbol, b.Timestamp, BarsFile, BarsHeader, []string{
+		b.Symbol,
+		formatTime(b.Timestamp),
+		formatFloat(b.Open),
+		formatFloat(b.High),
+		formatFloat(b.Low),
+		formatFloat(b.Close),
+		strconv.FormatUint(b.Volume, 10),
+	})
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func (r *Recorder) write(symbol string, timestamp time.Time, name string, header, record []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.err != nil {
+		return
+	}
+
+	p := partition{symbol: symbol, date: timestamp.In(newYork).Format("2006-01-02"), file: name}
+	f, ok := r.files[p]
+	if !ok {
+		r.rotate(p)
+		var err error
+		if f, err = open(filepath.Join(r.dir, symbol, p.date, name), header); err != nil {
+			r.err = err
+			return
+		}
+		r.files[p] = f
+	}
+	if err := f.csv.Write(record); err != nil {
+		r.err = err
+	}
+}
+
+// rotate closes the files of the previous dates of p, with r.mu held
+func (r *Recorder) rotate(p partition) {
+	for other, f := range r.files {
+		if other.symbol == p.symbol && other.file == p.file && other.date < p.date {
+			if err := f.close(); err != nil && r.err == nil {
+				r.err = err
+			}
+			delete(r.files, other)
+		}
+	}
+}
+
+func open(path string, header []string) (*file, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	buf := bufio.NewWriter(f)
+	w := &file{f: f, buf: buf, csv: csv.NewWriter(buf)}
+	if info.Size() == 0 {
+		if err := w.csv.Write(header); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+func (f *file) flush() error {
+	f.csv.Flush()
+	if err := f.csv.Error(); err != nil {
+		return err
+	}
+	return f.buf.Flush()
+}
+
+func (f *file) close() error {
+	err := f.flush()
+	if cerr := f.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Flush writes the buffered records to the files. It returns the first
+// error the recorder ran into, after which it stops recording.
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return ErrClosed
+	}
+	for _, f := range r.files {
+		if err := f.flush(); err != nil && r.err == nil {
+			r.err = err
+		}
+	}
+	return r.err
+}
+
+// Close flushes and closes the files. It returns the first error the
+// recorder ran into.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrClosed
+	}
+	r.closed = true
+	close(r.done)
+	for p, f := range r.files {
+		if err := f.close(); err != nil && r.err == nil {
+			r.err = err
+		}
+		delete(r.files, p)
+	}
+	err := r.err
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	return err
+}
@@ -0,0 +1,86 @@
+package recorder
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RecorderTestSuite struct {
+	suite.Suite
+}
+
+func TestRecorderTestSuite(t *testing.T) {
+	suite.Run(t, new(RecorderTestSuite))
+}
+
+func (s *RecorderTestSuite) read(path ...string) string {
+	b, err := ioutil.ReadFile(filepath.Join(path...))
+	require.NoError(s.T(), err)
+	return string(b)
+}
+
+func (s *RecorderTestSuite) TestRecord() {
+	dir := s.T().TempDir()
+	// partitions use the New York date, 01:59 UTC on March 2 is still March 1
+	day1 := time.Date(2021, 3, 2, 1, 59, 0, 0, time.UTC)
+	day2 := time.Date(2021, 3, 2, 14, 30, 0, 500, time.UTC)
+
+	r := New(dir, WithFlushInterval(10*time.Millisecond))
+	r.RecordTrade(stream.Trade{ID: 1, Symbol: "AAPL", Exchange: "Q", Price: 120.25, Size: 100,
+		Timestamp: day1, Conditions: []string{"@", "I"}, Tape: "C"})
+	r.RecordQuote(stream.Quote{Symbol: "AAPL", BidExchange: "Q", BidPrice: 120.2, BidSize: 1,
+		AskExchange: "P", AskPrice: 120.3, AskSize: 2, Timestamp: day1, Conditions: []string{"R"}, Tape: "C"})
+
+	// the periodic flush writes the records
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(s.T(),
+		"symbol,timestamp,id,exchange,price,size,conditions,tape\n"+
+			"AAPL,2021-03-02T01:59:00Z,1,Q,120.25,100,@|I,C\n",
+		s.read(dir, "AAPL", "2021-03-01", TradesFile))
+
+	r.RecordTrade(stream.Trade{ID: 2, Symbol: "AAPL", Price: 121, Size: 5, Timestamp: day2})
+	r.RecordBar(stream.Bar{Symbol: "MSFT", Open: 1, High: 2, Low: 0.5, Close: 1.5, Volume: 1000, Timestamp: day2})
+	require.NoError(s.T(), r.Flush())
+	require.NoError(s.T(), r.Close())
+	assert.Equal(s.T(), ErrClosed, r.Close())
+
+	assert.Equal(s.T(),
+		"symbol,timestamp,bid_exchange,bid_price,bid_size,ask_exchange,ask_price,ask_size,conditions,tape\n"+
+			"AAPL,2021-03-02T01:59:00Z,Q,120.2,1,P,120.3,2,R,C\n",
+		s.read(dir, "AAPL", "2021-03-01", QuotesFile))
+	assert.Equal(s.T(),
+		"symbol,timestamp,id,exchange,price,size,conditions,tape\n"+
+			"AAPL,2021-03-02T14:30:00.0000005Z,2,,121,5,,\n",
+		s.read(dir, "AAPL", "2021-03-02", TradesFile))
+	assert.Equal(s.T(),
+		"symbol,timestamp,open,high,low,close,volume\n"+
+			"MSFT,2021-03-02T14:30:00.0000005Z,1,2,0.5,1.5,1000\n",
+		s.read(dir, "MSFT", "2021-03-02", BarsFile))
+
+	// existing files are appended to
+	r = New(dir)
+	r.RecordBar(stream.Bar{Symbol: "MSFT", Close: 2, Timestamp: day2})
+	require.NoError(s.T(), r.Close())
+	assert.Equal(s.T(),
+		"symbol,timestamp,open,high,low,close,volume\n"+
+			"MSFT,2021-03-02T14:30:00.0000005Z,1,2,0.5,1.5,1000\n"+
+			"MSFT,2021-03-02T14:30:00.0000005Z,0,0,0,2,0\n",
+		s.read(dir, "MSFT", "2021-03-02", BarsFile))
+}
+
+func (s *RecorderTestSuite) TestError() {
+	dir := s.T().TempDir()
+	require.NoError(s.T(), ioutil.WriteFile(filepath.Join(dir, "AAPL"), nil, 0644))
+
+	r := New(dir)
+	r.RecordTrade(stream.Trade{Symbol: "AAPL", Timestamp: time.Now()})
+	assert.Error(s.T(), r.Flush())
+	assert.Error(s.T(), r.Close())
+}
@@ -0,0 +1,225 @@
+// Package recurring buys a fixed notional of symbols on a recurring basis,
+// dollar-cost averaging, at the market open of the sessions they are due on,
+// e.g.
+//
+//	plan := recurring.New(client, recurring.Weekly(time.Monday), map[string]decimal.Decimal{
+//		"VOO": decimal.New(100, 0),
+//		"AAPL": decimal.New(25, 0),
+//	}, recurring.OnResult(func(r recurring.Result) { log.Println(r) }))
+//	err := plan.Run(ctx)
+package recurring
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/schedule"
+	"github.com/shopspring/decimal"
+)
+
+var newYork = func() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}()
+
+func dateOf(t time.Time) time.Time {
+	y, m, d := t.In(newYork).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, newYork)
+}
+
+// Cadence is how often the symbols are bought. It is created by Daily,
+// Weekly or Monthly.
+type Cadence interface {
+	// last returns the last date the plan is due on, on or before date
+	last(date time.Time) time.Time
+}
+
+type daily struct{}
+
+// Daily buys at every session.
+func Daily() Cadence {
+	return daily{}
+}
+
+func (daily) last(date time.Time) time.Time {
+	return date
+}
+
+type weekly struct {
+	day time.Weekday
+}
+
+// Weekly buys every week on day, or at the next session if the market is
+// closed that day.
+func Weekly(day time.Weekday) Cadence {
+	return weekly{day: day}
+}
+
+func (w weekly) last(date time.Time) time.Time {
+	return date.AddDate(0, 0, -((int(date.Weekday()) - int(w.day) + 7) % 7))
+}
+
+type monthly struct {
+	day int
+}
+
+// Monthly buys every month on day, the last day of the month for the months
+// shorter than day, or at the next session if the market is closed that day.
+func Monthly(day int) Cadence {
+	return monthly{day: day}
+}
+
+func (m monthly) last(date time.Time) time.Time {
+	due := m.in(date.Year(), date.Month())
+	if due.After(date) {
+		due = m.in(date.Year(), date.Month()-1)
+	}
+	return due
+}
+
+// in returns the date due in a month
+func (m monthly) in(year int, month time.Month) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, newYork)
+	if last := first.AddDate(0, 1, -1); m.day > last.Day() {
+		return last
+	}
+	return first.AddDate(0, 0, m.day-1)
+}
+
+// Result is the order of a symbol placed by the plan
+type Result struct {
+	Time     time.Time
+	Symbol   string
+	Notional decimal.Decimal
+	// Order is the order placed, nil if it failed with Err
+	Order *alpaca.Order
+	Err   error
+}
+
+func (r Result) String() string {
+	if r.Err != nil {
+		return fmt.Sprintf("%s %s of %s failed: %v", r.Time.Format(time.RFC3339), r.Notional, r.Symbol, r.Err)
+	}
+	return fmt.Sprintf("%s %s of %s placed as %s", r.Time.Format(time.RFC3339), r.Notional, r.Symbol, r.Order.ID)
+}
+
+// Option configures a Plan
+type Option func(p *Plan)
+
+// OnResult sets the callback called with the result of every order placed.
+func OnResult(callback func(r Result)) Option {
+	return func(p *Plan) {
+		p.onResult = callback
+	}
+}
+
+// WithLastRun sets the time the plan last bought, e.g. saved from the last
+// result of a previous run, so that a restarted plan does not buy twice. By
+// default the plan is due from the day it is created.
+func WithLastRun(t time.Time) Option {
+	return func(p *Plan) {
+		p.lastRun = dateOf(t)
+	}
+}
+
+// WithSessionTime sets when the plan buys in the sessions it is due, at the
+// open by default.
+func WithSessionTime(t schedule.SessionTime) Option {
+	return func(p *Plan) {
+		p.at = t
+	}
+}
+
+// Plan buys a notional of symbols at the sessions its cadence is due.
+type Plan struct {
+	client   alpaca.TradingClient
+	cadence  Cadence
+	notional map[string]decimal.Decimal
+	onResult func(r Result)
+	at       schedule.SessionTime
+
+	mu      sync.Mutex
+	lastRun time.Time
+	results []Result
+}
+
+// New creates a plan buying the notional of each symbol of notional at
+// cadence, with client.
+func New(client alpaca.TradingClient, cadence Cadence, notional map[string]decimal.Decimal, opts ...Option) *Plan {
+	p := &Plan{
+		client:   client,
+		cadence:  cadence,
+		notional: notional,
+		at:       schedule.Open,
+		lastRun:  dateOf(time.Now()).AddDate(0, 0, -1),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Register runs the plan with a scheduler.
+func (p *Plan) Register(s *schedule.Scheduler) {
+	s.At(p.at, func(t time.Time) {
+		p.Execute(t)
+	})
+}
+
+// Run runs the plan with a scheduler of its own until ctx is done.
+func (p *Plan) Run(ctx context.Context) error {
+	s := schedule.New(p.client)
+	p.Register(s)
+	return s.Run(ctx)
+}
+
+// Execute places the notional orders of the symbols if the plan is due at
+// the session of now, that is if a date of the cadence passed since it last
+// ran. It returns the results, none if the plan was not due.
+func (p *Plan) Execute(now time.Time) []Result {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	today := dateOf(now)
+	if !p.cadence.last(today).After(p.lastRun) {
+		return nil
+	}
+	p.lastRun = today
+
+	symbols := make([]string, 0, len(p.notional))
+	for symbol := range p.notional {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	results := make([]Result, 0, len(symbols))
+	for _, symbol := range symbols {
+		symbol := symbol
+		r := Result{Time: now, Symbol: symbol, Notional: p.notional[symbol]}
+		r.Order, r.Err = p.client.PlaceOrder(alpaca.PlaceOrderRequest{
+			AssetKey:    &symbol,
+			Notional:    r.Notional,
+			Side:        alpaca.Buy,
+			Type:        alpaca.Market,
+			TimeInForce: alpaca.Day,
+		})
+		results = append(results, r)
+		p.results = append(p.results, r)
+		if p.onResult != nil {
+			p.onResult(r)
+		}
+	}
+	return results
+}
+
+// Results returns the results of the orders placed so far.
+func (p *Plan) Results() []Result {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Result(nil), p.results...)
+}
@@ -0,0 +1,112 @@
+package recurring
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type RecurringTestSuite struct {
+	suite.Suite
+	mock   *alpacatest.MockClient
+	placed []alpaca.PlaceOrderRequest
+}
+
+func TestRecurringTestSuite(t *testing.T) {
+	suite.Run(t, new(RecurringTestSuite))
+}
+
+func open(m time.Month, d int) time.Time {
+	return time.Date(2021, m, d, 9, 30, 0, 0, newYork)
+}
+
+func (s *RecurringTestSuite) SetupTest() {
+	s.placed = nil
+	s.mock = &alpacatest.MockClient{
+		PlaceOrderFunc: func(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+			s.placed = append(s.placed, req)
+			if *req.AssetKey == "GME" {
+				return nil, alpaca.ErrAssetNotTradable
+			}
+			return &alpaca.Order{ID: "order_" + *req.AssetKey}, nil
+		},
+	}
+}
+
+func (s *RecurringTestSuite) TestCadences() {
+	for _, tc := range []struct {
+		cadence Cadence
+		date    time.Time
+		last    time.Time
+	}{
+		{Daily(), open(3, 3), open(3, 3)},
+		{Weekly(time.Monday), open(3, 1), open(3, 1)},
+		{Weekly(time.Monday), open(3, 7), open(3, 1)},
+		{Weekly(time.Friday), open(3, 1), open(2, 26)},
+		{Monthly(15), open(3, 15), open(3, 15)},
+		{Monthly(15), open(3, 14), open(2, 15)},
+		{Monthly(31), open(3, 1), open(2, 28)},
+		{Monthly(31), open(4, 30), open(4, 30)},
+		{Monthly(1), open(1, 1).AddDate(0, 0, 5), open(1, 1)},
+	} {
+		assert.True(s.T(), dateOf(tc.last).Equal(tc.cadence.last(dateOf(tc.date))),
+			"%v on %s", tc.cadence, tc.date.Format("2006-01-02"))
+	}
+}
+
+func (s *RecurringTestSuite) TestExecute() {
+	var results []Result
+	plan := New(s.mock, Weekly(time.Monday), map[string]decimal.Decimal{
+		"VOO":  decimal.New(100, 0),
+		"AAPL": decimal.New(25, 0),
+	}, WithLastRun(open(2, 22)), OnResult(func(r Result) { results = append(results, r) }))
+
+	// Monday the 1st of March
+	got := plan.Execute(open(3, 1))
+	require.Len(s.T(), got, 2)
+	assert.Equal(s.T(), "AAPL", got[0].Symbol)
+	assert.Equal(s.T(), "order_AAPL", got[0].Order.ID)
+	assert.Equal(s.T(), got, results)
+	require.Len(s.T(), s.placed, 2)
+	assert.Equal(s.T(), "VOO", *s.placed[1].AssetKey)
+	assert.True(s.T(), s.placed[1].Notional.Equal(decimal.New(100, 0)))
+	assert.True(s.T(), s.placed[1].Qty.IsZero())
+	assert.Equal(s.T(), alpaca.Market, s.placed[1].Type)
+	assert.Equal(s.T(), alpaca.Day, s.placed[1].TimeInForce)
+
+	// not twice the same week
+	assert.Empty(s.T(), plan.Execute(open(3, 1).Add(time.Hour)))
+	assert.Empty(s.T(), plan.Execute(open(3, 5)))
+	assert.Len(s.T(), s.placed, 2)
+	assert.Len(s.T(), plan.Results(), 2)
+}
+
+func (s *RecurringTestSuite) TestHoliday() {
+	plan := New(s.mock, Monthly(1), map[string]decimal.Decimal{"VOO": decimal.New(100, 0)},
+		WithLastRun(time.Date(2020, 12, 1, 9, 30, 0, 0, newYork)))
+
+	// the market is closed on new year's day, the next session buys
+	require.Len(s.T(), plan.Execute(time.Date(2021, 1, 4, 9, 30, 0, 0, newYork)), 1)
+	assert.Empty(s.T(), plan.Execute(time.Date(2021, 1, 5, 9, 30, 0, 0, newYork)))
+	assert.Len(s.T(), plan.Execute(time.Date(2021, 2, 1, 9, 30, 0, 0, newYork)), 1)
+}
+
+func (s *RecurringTestSuite) TestErrors() {
+	plan := New(s.mock, Daily(), map[string]decimal.Decimal{
+		"GME": decimal.New(10, 0),
+		"VOO": decimal.New(10, 0),
+	})
+	results := plan.Execute(time.Now())
+	require.Len(s.T(), results, 2)
+	assert.True(s.T(), errors.Is(results[0].Err, alpaca.ErrAssetNotTradable))
+	assert.Nil(s.T(), results[0].Order)
+	assert.Contains(s.T(), results[0].String(), "10 of GME failed")
+	assert.NoError(s.T(), results[1].Err)
+}
@@ -0,0 +1,459 @@
+// Package risk enforces pre-trade rules on the orders of a strategy before
+// they reach the API, e.g.
+//
+//	guard := risk.NewGuard(client,
+//		risk.WithMaxOrderNotional(decimal.New(10000, 0)),
+//		risk.WithMaxDailyLoss(decimal.New(500, 0)),
+//		risk.WithRestrictedSymbols("GME", "AMC"),
+//	)
+//	// guard is an alpaca.TradingClient
+//	_, err := guard.PlaceOrder(req)
+//	if errors.Is(err, risk.ErrMaxOrderNotional) { ... }
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/shopspring/decimal"
+)
+
+// The rules an order can violate. The errors of the guard match them with errors.Is.
+var (
+	ErrMaxOrderNotional    = errors.New("max order notional exceeded")
+	ErrMaxPosition         = errors.New("max position exceeded")
+	ErrMaxDailyLoss        = errors.New("max daily loss reached")
+	ErrRestrictedSymbol    = errors.New("symbol is restricted")
+	ErrOutsideTradingHours = errors.New("outside trading hours")
+	ErrSelfCross           = errors.New("order would cross an open order")
+)
+
+// Violation is the error of an order rejected by the guard
+type Violation struct {
+	// Rule is one of the Err variables of this package
+	Rule   error
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return "order rejected: " + v.Reason
+}
+
+// Unwrap returns the rule, so that errors.Is matches it
+func (v *Violation) Unwrap() error {
+	return v.Rule
+}
+
+func violation(rule error, format string, args ...interface{}) error {
+	return &Violation{Rule: rule, Reason: fmt.Sprintf(format, args...)}
+}
+
+var newYork = func() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}()
+
+// AuditEntry is a decision of the guard on an order
+type AuditEntry struct {
+	Time time.Time
+	// Action is "place" or "replace"
+	Action string
+	// Request is the order, for a replacement the order once replaced
+	Request alpaca.PlaceOrderRequest
+	// Err is why the order was rejected, nil if it was let through
+	Err error
+}
+
+// SelfCrossAction is what the guard does with an order that would cross an
+// open order of the other side in the same symbol, which the API rejects as
+// a potential wash trade
+type SelfCrossAction int
+
+const (
+	// SelfCrossBlock rejects the order with ErrSelfCross
+	SelfCrossBlock SelfCrossAction = iota
+	// SelfCrossCancel cancels the open orders it would cross, then places it
+	SelfCrossCancel
+	// SelfCrossWarn only calls the OnSelfCross callback, then places it
+	SelfCrossWarn
+)
+
+// Option configures a Guard
+type Option func(g *Guard)
+
+// WithMaxOrderNotional rejects the orders worth more than max.
+func WithMaxOrderNotional(max decimal.Decimal) Option {
+	return func(g *Guard) {
+		g.maxOrderNotional = max
+	}
+}
+
+// WithMaxPosition rejects the orders that would leave a position, long or
+// short, worth more than max at the price of the order.
+func WithMaxPosition(max decimal.Decimal) Option {
+	return func(g *Guard) {
+		g.maxPosition = max
+	}
+}
+
+// WithMaxDailyLoss rejects the orders that do not reduce a position once
+// the equity of the account fell by max since the previous close.
+func WithMaxDailyLoss(max decimal.Decimal) Option {
+	return func(g *Guard) {
+		g.maxDailyLoss = max
+	}
+}
+
+// WithRestrictedSymbols rejects the orders of symbols.
+func WithRestrictedSymbols(symbols ...string) Option {
+	return func(g *Guard) {
+		for _, symbol := range symbols {
+			g.restricted[symbol] = true
+		}
+	}
+}
+
+// WithTradingHours rejects the orders placed outside of the window of each
+// day between start and end, offsets from midnight in New York, e.g.
+// 9*time.Hour+35*time.Minute and 15*time.Hour+55*time.Minute.
+func WithTradingHours(start, end time.Duration) Option {
+	return func(g *Guard) {
+		g.hoursStart, g.hoursEnd = start, end
+	}
+}
+
+// WithSelfCrossCheck checks the orders against the open orders of the
+// account, and handles the ones that would cross an open order of the other
+// side in the same symbol with action.
+func WithSelfCrossCheck(action SelfCrossAction) Option {
+	return func(g *Guard) {
+		g.selfCross = true
+		g.selfCrossAction = action
+	}
+}
+
+// OnSelfCross sets the callback called with each open order an order would
+// cross, whatever the action of WithSelfCrossCheck.
+func OnSelfCross(callback func(req alpaca.PlaceOrderRequest, open alpaca.Order)) Option {
+	return func(g *Guard) {
+		g.onSelfCross = callback
+	}
+}
+
+// WithAuditLog sets the callback called with every decision of the guard.
+func WithAuditLog(audit func(entry AuditEntry)) Option {
+	return func(g *Guard) {
+		g.audit = audit
+	}
+}
+
+// WithClock sets the clock of the trading hours, time.Now by default.
+func WithClock(now func() time.Time) Option {
+	return func(g *Guard) {
+		g.now = now
+	}
+}
+
+// Guard is an alpaca.TradingClient checking the orders placed and replaced
+// through it against its rules, rejecting the violating ones locally with a
+// *Violation. The other methods are those of the wrapped client.
+//
+// Market orders are valued at the latest trade of their symbol. The rules on
+// notional, position and daily loss do not apply to multi-leg orders.
+type Guard struct {
+	alpaca.TradingClient
+
+	maxOrderNotional decimal.Decimal
+	maxPosition      decimal.Decimal
+	maxDailyLoss     decimal.Decimal
+	restricted       map[string]bool
+	hoursStart       time.Duration
+	hoursEnd         time.Duration
+	selfCross        bool
+	selfCrossAction  SelfCrossAction
+	onSelfCross      func(req alpaca.PlaceOrderRequest, open alpaca.Order)
+	audit            func(entry AuditEntry)
+	now              func() time.Time
+	auditMu          sync.Mutex
+}
+
+var _ alpaca.TradingClient = (*Guard)(nil)
+
+// NewGuard creates a guard placing the orders it lets through with client.
+func NewGuard(client alpaca.TradingClient, opts ...Option) *Guard {
+	g := &Guard{
+		TradingClient: client,
+		restricted:    map[string]bool{},
+		now:           time.Now,
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// PlaceOrder places req if it violates no rule. With SelfCrossCancel, the
+// open orders it would cross are canceled first.
+func (g *Guard) PlaceOrder(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+	crossed, err := g.check(req, "")
+	if err != nil {
+		g.log("place", req, err)
+		return nil, err
+	}
+	if err := g.cancelCrossed(crossed); err != nil {
+		return nil, err
+	}
+	g.log("place", req, nil)
+	return g.TradingClient.PlaceOrder(req)
+}
+
+// ReplaceOrder replaces an order if the order once replaced violates no rule.
+func (g *Guard) ReplaceOrder(orderID string, req alpaca.ReplaceOrderRequest) (*alpaca.Order, error) {
+	order, err := g.TradingClient.GetOrder(orderID)
+	if err != nil {
+		return nil, err
+	}
+	replaced := alpaca.PlaceOrderRequest{
+		AssetKey:    &order.Symbol,
+		Qty:         order.Qty.Sub(order.FilledQty),
+		Side:        order.Side,
+		Type:        order.Type,
+		TimeInForce: order.TimeInForce,
+		LimitPrice:  order.LimitPrice,
+		StopPrice:   order.StopPrice,
+	}
+	if req.Qty != nil {
+		replaced.Qty = *req.Qty
+	}
+	if req.LimitPrice != nil {
+		replaced.LimitPrice = req.LimitPrice
+	}
+	if req.StopPrice != nil {
+		replaced.StopPrice = req.StopPrice
+	}
+
+	crossed, err := g.check(replaced, orderID)
+	if err != nil {
+		g.log("replace", replaced, err)
+		return nil, err
+	}
+	if err := g.cancelCrossed(crossed); err != nil {
+		return nil, err
+	}
+	g.log("replace", replaced, nil)
+	return g.TradingClient.ReplaceOrder(orderID, req)
+}
+
+func (g *Guard) log(action string, req alpaca.PlaceOrderRequest, err error) {
+	if g.audit == nil {
+		return
+	}
+	g.auditMu.Lock()
+	defer g.auditMu.Unlock()
+	g.audit(AuditEntry{Time: g.now(), Action: action, Request: req, Err: err})
+}
+
+// Check checks req against the rules, without placing it. It returns a
+// *Violation for the first rule violated, or the error of a request the
+// check needed. The open orders req would cross are not canceled.
+func (g *Guard) Check(req alpaca.PlaceOrderRequest) error {
+	_, err := g.check(req, "")
+	return err
+}
+
+// check checks req against the rules, returning the open orders to cancel
+// before placing it. The order replaced by req, if any, is orderID.
+func (g *Guard) check(req alpaca.PlaceOrderRequest, orderID string) ([]alpaca.Order, error) {
+	if err := g.checkRules(req); err != nil {
+		return nil, err
+	}
+	if !g.selfCross || req.AssetKey == nil {
+		return nil, nil
+	}
+	crossed, err := g.crossed(req, orderID)
+	if err != nil {
+		return nil, err
+	}
+	for _, open := range crossed {
+		if g.onSelfCross != nil {
+			g.onSelfCross(req, open)
+		}
+	}
+	if len(crossed) == 0 {
+		return nil, nil
+	}
+	switch g.selfCrossAction {
+	case SelfCrossBlock:
+		return nil, violation(ErrSelfCross, "%s %s would cross open %s order %s",
+			req.Side, *req.AssetKey, crossed[0].Side, crossed[0].ID)
+	case SelfCrossCancel:
+		return crossed, nil
+	}
+	return nil, nil
+}
+
+func (g *Guard) checkRules(req alpaca.PlaceOrderRequest) error {
+	if err := g.checkHours(); err != nil {
+		return err
+	}
+	if req.AssetKey == nil {
+		for _, leg := range req.Legs {
+			if g.restricted[leg.Symbol] {
+				return violation(ErrRestrictedSymbol, "%s is restricted", leg.Symbol)
+			}
+		}
+		return nil
+	}
+	symbol := *req.AssetKey
+	if g.restricted[symbol] {
+		return violation(ErrRestrictedSymbol, "%s is restricted", symbol)
+	}
+	if g.maxOrderNotional.IsZero() && g.maxPosition.IsZero() && g.maxDailyLoss.IsZero() {
+		return nil
+	}
+
+	price, err := g.price(req)
+	if err != nil {
+		return err
+	}
+	qty, notional := req.Qty, req.Qty.Mul(price)
+	if !req.Notional.IsZero() {
+		notional = req.Notional
+		qty = req.Notional.Div(price)
+	}
+	if !g.maxOrderNotional.IsZero() && notional.GreaterThan(g.maxOrderNotional) {
+		return violation(ErrMaxOrderNotional, "%s %s worth %s, over %s",
+			req.Side, symbol, notional.StringFixed(2), g.maxOrderNotional)
+	}
+	if g.maxPosition.IsZero() && g.maxDailyLoss.IsZero() {
+		return nil
+	}
+
+	position, err := g.position(symbol)
+	if err != nil {
+		return err
+	}
+	signed := qty
+	if req.Side == alpaca.Sell {
+		signed = qty.Neg()
+	}
+	after := position.Add(signed)
+	if !g.maxPosition.IsZero() && after.Abs().GreaterThan(position.Abs()) {
+		if value := after.Abs().Mul(price); value.GreaterThan(g.maxPosition) {
+			return violation(ErrMaxPosition, "position in %s would be worth %s, over %s",
+				symbol, value.StringFixed(2), g.maxPosition)
+		}
+	}
+	if !g.maxDailyLoss.IsZero() && !reduces(position, after) {
+		account, err := g.TradingClient.GetAccount()
+		if err != nil {
+			return err
+		}
+		if loss := account.LastEquity.Sub(account.Equity); loss.GreaterThanOrEqual(g.maxDailyLoss) {
+			return violation(ErrMaxDailyLoss, "lost %s today, only orders reducing positions are allowed",
+				loss.StringFixed(2))
+		}
+	}
+	return nil
+}
+
+// crossed returns the open orders of the other side in the symbol of req
+// that req would trade with, but the order orderID
+func (g *Guard) crossed(req alpaca.PlaceOrderRequest, orderID string) ([]alpaca.Order, error) {
+	status := "open"
+	limit := 500
+	orders, err := g.TradingClient.ListOrders(&status, nil, &limit, nil)
+	if err != nil {
+		return nil, err
+	}
+	var crossed []alpaca.Order
+	for _, open := range orders {
+		if open.ID == orderID || open.Symbol != *req.AssetKey || open.Side == req.Side {
+			continue
+		}
+		if crosses(req, open) {
+			crossed = append(crossed, open)
+		}
+	}
+	return crossed, nil
+}
+
+// crosses returns true if req could execute against open. The open orders
+// without a limit price, and the orders placed without one, may execute at
+// any price.
+func crosses(req alpaca.PlaceOrderRequest, open alpaca.Order) bool {
+	if req.LimitPrice == nil || open.LimitPrice == nil {
+		return true
+	}
+	if req.Side == alpaca.Buy {
+		return req.LimitPrice.GreaterThanOrEqual(*open.LimitPrice)
+	}
+	return req.LimitPrice.LessThanOrEqual(*open.LimitPrice)
+}
+
+// cancelCrossed cancels the open orders crossed, the orders filled or
+// canceled in the meantime are not errors
+func (g *Guard) cancelCrossed(crossed []alpaca.Order) error {
+	for _, open := range crossed {
+		err := g.TradingClient.CancelOrder(open.ID)
+		if err != nil && !errors.Is(err, alpaca.ErrOrderNotCancelable) {
+			return fmt.Errorf("canceling crossed order %s: %w", open.ID, err)
+		}
+	}
+	return nil
+}
+
+// reduces returns true if a position moving from before to after is reduced
+// without reversing
+func reduces(before, after decimal.Decimal) bool {
+	return after.Abs().LessThan(before.Abs()) && (after.IsZero() || after.Sign() == before.Sign())
+}
+
+func (g *Guard) checkHours() error {
+	if g.hoursStart == 0 && g.hoursEnd == 0 {
+		return nil
+	}
+	now := g.now().In(newYork)
+	y, m, d := now.Date()
+	offset := now.Sub(time.Date(y, m, d, 0, 0, 0, 0, newYork))
+	if offset < g.hoursStart || offset >= g.hoursEnd {
+		return violation(ErrOutsideTradingHours, "%s is outside of the trading hours", now.Format("15:04:05 MST"))
+	}
+	return nil
+}
+
+// price returns the price req is valued at
+func (g *Guard) price(req alpaca.PlaceOrderRequest) (decimal.Decimal, error) {
+	if req.LimitPrice != nil {
+		return *req.LimitPrice, nil
+	}
+	if req.StopPrice != nil {
+		return *req.StopPrice, nil
+	}
+	trade, err := g.TradingClient.GetLatestTrade(*req.AssetKey)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("pricing %s: %w", *req.AssetKey, err)
+	}
+	return decimal.NewFromFloat(trade.Price), nil
+}
+
+// position returns the signed quantity of the position in symbol
+func (g *Guard) position(symbol string) (decimal.Decimal, error) {
+	p, err := g.TradingClient.GetPosition(symbol)
+	if errors.Is(err, alpaca.ErrPositionNotFound) {
+		return decimal.Zero, nil
+	}
+	if err != nil {
+		return decimal.Zero, err
+	}
+	if p.Side == "short" && p.Qty.IsPositive() {
+		return p.Qty.Neg(), nil
+	}
+	return p.Qty, nil
+}
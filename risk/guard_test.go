@@ -0,0 +1,234 @@
+package risk
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type GuardTestSuite struct {
+	suite.Suite
+	mock     *alpacatest.MockClient
+	position *alpaca.Position
+	equity   decimal.Decimal
+	audit    []AuditEntry
+}
+
+func TestGuardTestSuite(t *testing.T) {
+	suite.Run(t, new(GuardTestSuite))
+}
+
+func (s *GuardTestSuite) SetupTest() {
+	s.position = nil
+	s.equity = decimal.New(10000, 0)
+	s.audit = nil
+	s.mock = &alpacatest.MockClient{
+		GetLatestTradeFunc: func(symbol string) (*v2.Trade, error) {
+			return &v2.Trade{Price: 100}, nil
+		},
+		GetPositionFunc: func(symbol string) (*alpaca.Position, error) {
+			if s.position == nil {
+				return nil, alpaca.ErrPositionNotFound
+			}
+			return s.position, nil
+		},
+		GetAccountFunc: func() (*alpaca.Account, error) {
+			return &alpaca.Account{LastEquity: decimal.New(10000, 0), Equity: s.equity}, nil
+		},
+		PlaceOrderFunc: func(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+			return &alpaca.Order{ID: "order_id"}, nil
+		},
+	}
+}
+
+func (s *GuardTestSuite) guard(opts ...Option) *Guard {
+	opts = append(opts, WithAuditLog(func(e AuditEntry) { s.audit = append(s.audit, e) }))
+	return NewGuard(s.mock, opts...)
+}
+
+func order(side alpaca.Side, qty int64, limit *decimal.Decimal) alpaca.PlaceOrderRequest {
+	symbol := "AAPL"
+	return alpaca.PlaceOrderRequest{
+		AssetKey:    &symbol,
+		Qty:         decimal.New(qty, 0),
+		Side:        side,
+		Type:        alpaca.Market,
+		TimeInForce: alpaca.Day,
+		LimitPrice:  limit,
+	}
+}
+
+func (s *GuardTestSuite) TestMaxOrderNotional() {
+	g := s.guard(WithMaxOrderNotional(decimal.New(5000, 0)))
+
+	// valued at the latest trade
+	_, err := g.PlaceOrder(order(alpaca.Buy, 50, nil))
+	require.NoError(s.T(), err)
+	_, err = g.PlaceOrder(order(alpaca.Buy, 51, nil))
+	assert.True(s.T(), errors.Is(err, ErrMaxOrderNotional))
+	var violation *Violation
+	require.True(s.T(), errors.As(err, &violation))
+	assert.Equal(s.T(), "order rejected: buy AAPL worth 5100.00, over 5000", violation.Error())
+
+	// or at the limit price
+	limit := decimal.New(90, 0)
+	_, err = g.PlaceOrder(order(alpaca.Buy, 55, &limit))
+	require.NoError(s.T(), err)
+
+	require.Len(s.T(), s.audit, 3)
+	assert.Nil(s.T(), s.audit[0].Err)
+	assert.Equal(s.T(), "place", s.audit[1].Action)
+	assert.Equal(s.T(), err, s.audit[2].Err)
+	assert.Equal(s.T(), []string{"GetLatestTrade", "PlaceOrder", "GetLatestTrade", "PlaceOrder"}, s.mock.Calls)
+}
+
+func (s *GuardTestSuite) TestMaxPosition() {
+	g := s.guard(WithMaxPosition(decimal.New(5000, 0)))
+	s.position = &alpaca.Position{Symbol: "AAPL", Qty: decimal.New(40, 0), Side: "long"}
+
+	_, err := g.PlaceOrder(order(alpaca.Buy, 10, nil))
+	require.NoError(s.T(), err)
+	_, err = g.PlaceOrder(order(alpaca.Buy, 11, nil))
+	assert.True(s.T(), errors.Is(err, ErrMaxPosition))
+	// reversing into a short over the limit
+	_, err = g.PlaceOrder(order(alpaca.Sell, 100, nil))
+	assert.True(s.T(), errors.Is(err, ErrMaxPosition))
+	_, err = g.PlaceOrder(order(alpaca.Sell, 90, nil))
+	require.NoError(s.T(), err)
+
+	// reducing a position over the limit is allowed
+	s.position = &alpaca.Position{Symbol: "AAPL", Qty: decimal.New(80, 0), Side: "short"}
+	_, err = g.PlaceOrder(order(alpaca.Buy, 10, nil))
+	require.NoError(s.T(), err)
+	_, err = g.PlaceOrder(order(alpaca.Sell, 1, nil))
+	assert.True(s.T(), errors.Is(err, ErrMaxPosition))
+}
+
+func (s *GuardTestSuite) TestMaxDailyLoss() {
+	g := s.guard(WithMaxDailyLoss(decimal.New(500, 0)))
+	s.equity = decimal.New(9600, 0)
+	_, err := g.PlaceOrder(order(alpaca.Buy, 10, nil))
+	require.NoError(s.T(), err)
+
+	s.equity = decimal.New(9500, 0)
+	_, err = g.PlaceOrder(order(alpaca.Buy, 10, nil))
+	assert.True(s.T(), errors.Is(err, ErrMaxDailyLoss))
+
+	// closing is still allowed
+	s.position = &alpaca.Position{Symbol: "AAPL", Qty: decimal.New(10, 0), Side: "long"}
+	_, err = g.PlaceOrder(order(alpaca.Sell, 10, nil))
+	require.NoError(s.T(), err)
+	_, err = g.PlaceOrder(order(alpaca.Sell, 11, nil))
+	assert.True(s.T(), errors.Is(err, ErrMaxDailyLoss))
+}
+
+func (s *GuardTestSuite) TestRestrictedSymbolsAndHours() {
+	now := time.Date(2021, 3, 2, 9, 30, 0, 0, newYork)
+	g := s.guard(
+		WithRestrictedSymbols("AAPL"),
+		WithTradingHours(9*time.Hour+35*time.Minute, 15*time.Hour+55*time.Minute),
+		WithClock(func() time.Time { return now }),
+	)
+
+	_, err := g.PlaceOrder(order(alpaca.Buy, 1, nil))
+	assert.True(s.T(), errors.Is(err, ErrOutsideTradingHours))
+	now = now.Add(5 * time.Minute)
+	_, err = g.PlaceOrder(order(alpaca.Buy, 1, nil))
+	assert.True(s.T(), errors.Is(err, ErrRestrictedSymbol))
+
+	msft := "MSFT"
+	req := order(alpaca.Buy, 1, nil)
+	req.AssetKey = &msft
+	_, err = g.PlaceOrder(req)
+	require.NoError(s.T(), err)
+
+	_, err = g.PlaceOrder(alpaca.PlaceOrderRequest{OrderClass: alpaca.Mleg, Legs: []alpaca.OrderLeg{
+		{Symbol: "MSFT"}, {Symbol: "AAPL"},
+	}})
+	assert.True(s.T(), errors.Is(err, ErrRestrictedSymbol))
+
+	now = time.Date(2021, 3, 2, 15, 55, 0, 0, newYork)
+	_, err = g.PlaceOrder(req)
+	assert.True(s.T(), errors.Is(err, ErrOutsideTradingHours))
+	assert.Equal(s.T(), now, s.audit[len(s.audit)-1].Time)
+}
+
+func (s *GuardTestSuite) TestReplaceOrder() {
+	limit := decimal.New(100, 0)
+	s.mock.GetOrderFunc = func(orderID string) (*alpaca.Order, error) {
+		return &alpaca.Order{ID: orderID, Symbol: "AAPL", Qty: decimal.New(50, 0), FilledQty: decimal.New(10, 0),
+			Side: alpaca.Buy, Type: alpaca.Limit, LimitPrice: &limit}, nil
+	}
+	s.mock.ReplaceOrderFunc = func(orderID string, req alpaca.ReplaceOrderRequest) (*alpaca.Order, error) {
+		return &alpaca.Order{ID: "replacement"}, nil
+	}
+	g := s.guard(WithMaxOrderNotional(decimal.New(5000, 0)))
+
+	higher := decimal.New(120, 0)
+	replacement, err := g.ReplaceOrder("order_id", alpaca.ReplaceOrderRequest{LimitPrice: &higher})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "replacement", replacement.ID)
+
+	qty := decimal.New(50, 0)
+	_, err = g.ReplaceOrder("order_id", alpaca.ReplaceOrderRequest{Qty: &qty, LimitPrice: &higher})
+	assert.True(s.T(), errors.Is(err, ErrMaxOrderNotional))
+	assert.Equal(s.T(), "replace", s.audit[1].Action)
+	assert.True(s.T(), s.audit[1].Request.LimitPrice.Equal(higher))
+}
+
+func (s *GuardTestSuite) TestSelfCross() {
+	price := func(p int64) *decimal.Decimal {
+		d := decimal.New(p, 0)
+		return &d
+	}
+	s.mock.ListOrdersFunc = func(status *string, until *time.Time, limit *int, nested *bool) ([]alpaca.Order, error) {
+		return []alpaca.Order{
+			{ID: "ask", Symbol: "AAPL", Side: alpaca.Sell, Type: alpaca.Limit, LimitPrice: price(105)},
+			{ID: "bid", Symbol: "AAPL", Side: alpaca.Buy, Type: alpaca.Limit, LimitPrice: price(95)},
+			{ID: "msft", Symbol: "MSFT", Side: alpaca.Sell, Type: alpaca.Limit, LimitPrice: price(100)},
+		}, nil
+	}
+	var canceled []string
+	s.mock.CancelOrderFunc = func(orderID string) error {
+		canceled = append(canceled, orderID)
+		return nil
+	}
+	var crossed []string
+	onSelfCross := OnSelfCross(func(req alpaca.PlaceOrderRequest, open alpaca.Order) {
+		crossed = append(crossed, open.ID)
+	})
+
+	g := s.guard(WithSelfCrossCheck(SelfCrossBlock), onSelfCross)
+	// a buy below the open sell does not cross it
+	_, err := g.PlaceOrder(order(alpaca.Buy, 1, price(104)))
+	require.NoError(s.T(), err)
+	_, err = g.PlaceOrder(order(alpaca.Buy, 1, price(105)))
+	assert.True(s.T(), errors.Is(err, ErrSelfCross))
+	assert.Equal(s.T(), "order rejected: buy AAPL would cross open sell order ask", err.Error())
+	_, err = g.PlaceOrder(order(alpaca.Sell, 1, nil))
+	assert.True(s.T(), errors.Is(err, ErrSelfCross))
+	assert.Equal(s.T(), []string{"ask", "bid"}, crossed)
+
+	crossed = nil
+	g = s.guard(WithSelfCrossCheck(SelfCrossCancel), onSelfCross)
+	_, err = g.PlaceOrder(order(alpaca.Sell, 1, price(90)))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"bid"}, canceled)
+	require.NoError(s.T(), g.Check(order(alpaca.Buy, 1, nil)))
+	assert.Equal(s.T(), []string{"bid"}, canceled)
+
+	crossed, canceled = nil, nil
+	g = s.guard(WithSelfCrossCheck(SelfCrossWarn), onSelfCross)
+	_, err = g.PlaceOrder(order(alpaca.Buy, 1, nil))
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"ask"}, crossed)
+	assert.Empty(s.T(), canceled)
+}
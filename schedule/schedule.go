@@ -0,0 +1,305 @@
+// Package schedule runs the jobs of a strategy at times of the trading
+// sessions of the market calendar, so that holidays, half-days and daylight
+// saving time are taken care of, e.g.
+//
+//	s := schedule.New(client)
+//	s.At(schedule.Open.Add(5*time.Minute), rebalance)
+//	s.Every(time.Minute).DuringMarketHours(checkStops)
+//	s.OnClose(report)
+//	err := s.Run(ctx)
+package schedule
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/bars"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+)
+
+// ErrNoSessions is returned by Run when the jobs do not run on any day of
+// the calendar for more than a month
+var ErrNoSessions = errors.New("no market session in calendar")
+
+const maxEmptyDays = 31
+
+var newYork = func() *time.Location {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}()
+
+// SessionTime is a time of the trading sessions, relative to their open or
+// close. The offsets are expected to keep it within the day of the session.
+type SessionTime struct {
+	close  bool
+	offset time.Duration
+}
+
+// The open and the close of the sessions, 13:00 New York on half-days.
+var (
+	Open  = SessionTime{}
+	Close = SessionTime{close: true}
+)
+
+// Add returns the session time d after t, before for a negative d.
+func (t SessionTime) Add(d time.Duration) SessionTime {
+	return SessionTime{close: t.close, offset: t.offset + d}
+}
+
+// in returns the time of the session open to close
+func (t SessionTime) in(open, close time.Time) time.Time {
+	if t.close {
+		return close.Add(t.offset)
+	}
+	return open.Add(t.offset)
+}
+
+// Option configures a Scheduler
+type Option func(s *Scheduler)
+
+// WithLookahead sets how many days of calendar are fetched at once, 30 by
+// default.
+func WithLookahead(days int) Option {
+	return func(s *Scheduler) {
+		s.lookahead = days
+	}
+}
+
+// WithClock sets the local clock, clock.Real by default. The scheduler runs
+// on the market clock, the local one corrected by the difference with
+// GetClock.
+func WithClock(c clock.Clock) Option {
+	return func(s *Scheduler) {
+		s.clock = c
+	}
+}
+
+// Scheduler runs jobs at session times. The jobs are called one at a time,
+// with the time they were scheduled at. A job still running at the time of
+// the next one delays it, and the times missed while a job ran are skipped.
+type Scheduler struct {
+	client    alpaca.TradingClient
+	lookahead int
+	clock     clock.Clock
+
+	mu   sync.Mutex
+	jobs []*job
+
+	sessions bars.Sessions
+	// until is the first day after the calendar fetched
+	until time.Time
+	// skew is the market clock minus the local one
+	skew time.Duration
+}
+
+type job struct {
+	start, end SessionTime
+	// every is 0 for a job run once per session, at start
+	every time.Duration
+	fn    func(t time.Time)
+}
+
+// New creates a scheduler fetching the calendar and clock with client.
+func New(client alpaca.TradingClient, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		client:    client,
+		lookahead: 30,
+		clock:     clock.Real,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// At runs fn at t of every session.
+func (s *Scheduler) At(t SessionTime, fn func(t time.Time)) {
+	s.add(&job{start: t, fn: fn})
+}
+
+// OnOpen runs fn at the open of every session.
+func (s *Scheduler) OnOpen(fn func(t time.Time)) {
+	s.At(Open, fn)
+}
+
+// OnClose runs fn at the close of every session, early on half-days.
+func (s *Scheduler) OnClose(fn func(t time.Time)) {
+	s.At(Close, fn)
+}
+
+// Interval is a job repeated during the sessions, created by Every
+type Interval struct {
+	s     *Scheduler
+	every time.Duration
+}
+
+// Every returns a job repeated every d, to be given the hours it runs in
+// and its function.
+func (s *Scheduler) Every(d time.Duration) *Interval {
+	return &Interval{s: s, every: d}
+}
+
+// DuringMarketHours runs fn from the open of every session, included, to its
+// close, excluded.
+func (i *Interval) DuringMarketHours(fn func(t time.Time)) {
+	i.Between(Open, Close, fn)
+}
+
+// Between runs fn from start of every session, included, to end, excluded,
+// e.g. Between(Open.Add(-time.Hour), Open, fn) before the open.
+func (i *Interval) Between(start, end SessionTime, fn func(t time.Time)) {
+	if i.every <= 0 {
+		panic("schedule: non-positive interval")
+	}
+	i.s.add(&job{start: start, end: end, every: i.every, fn: fn})
+}
+
+func (s *Scheduler) add(j *job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, j)
+}
+
+// now returns the time of the market clock
+func (s *Scheduler) now() time.Time {
+	return s.clock.Now().Add(s.skew)
+}
+
+// Run runs the jobs until ctx is done. It returns the errors of the calendar
+// and the clock, fetched at start and whenever the calendar runs out.
+func (s *Scheduler) Run(ctx context.Context) error {
+	if err := s.load(s.now()); err != nil {
+		return err
+	}
+	last := s.now()
+	for {
+		at, jobs, err := s.next(last)
+		if err != nil {
+			return err
+		}
+		if jobs == nil {
+			// no jobs yet, check again later
+			at = s.now().Add(time.Minute)
+		}
+
+		timer := s.clock.NewTimer(at.Sub(s.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+		last = at
+		if jobs == nil {
+			continue
+		}
+		for _, j := range jobs {
+			j.fn(at)
+		}
+		// skip the times missed while the jobs ran
+		if now := s.now(); now.After(last) {
+			last = now
+		}
+	}
+}
+
+// next returns the first time after which jobs run, and these jobs, fetching
+// more calendar as needed. The jobs are nil if there are none.
+func (s *Scheduler) next(after time.Time) (time.Time, []*job, error) {
+	s.mu.Lock()
+	jobs := append([]*job(nil), s.jobs...)
+	s.mu.Unlock()
+	if len(jobs) == 0 {
+		return time.Time{}, nil, nil
+	}
+
+	empty := 0
+	y, m, d := after.In(newYork).Date()
+	for day := time.Date(y, m, d, 12, 0, 0, 0, newYork); ; day = day.AddDate(0, 0, 1) {
+		if !day.Before(s.until) {
+			if empty >= maxEmptyDays {
+				return time.Time{}, nil, ErrNoSessions
+			}
+			if err := s.load(day); err != nil {
+				return time.Time{}, nil, err
+			}
+		}
+		open, close, ok := s.sessions(day)
+		if !ok {
+			empty++
+			continue
+		}
+		if at, due := earliest(jobs, open, close, after); due != nil {
+			return at, due, nil
+		}
+		empty++
+	}
+}
+
+// earliest returns the first time of the session open to close after which
+// jobs run, and these jobs
+func earliest(jobs []*job, open, close, after time.Time) (time.Time, []*job) {
+	var at time.Time
+	var due []*job
+	for _, j := range jobs {
+		t, ok := j.next(open, close, after)
+		if !ok {
+			continue
+		}
+		switch {
+		case due == nil || t.Before(at):
+			at, due = t, []*job{j}
+		case t.Equal(at):
+			due = append(due, j)
+		}
+	}
+	return at, due
+}
+
+// next returns the first time of j in the session open to close after a time
+func (j *job) next(open, close, after time.Time) (time.Time, bool) {
+	start := j.start.in(open, close)
+	if j.every == 0 {
+		return start, start.After(after)
+	}
+	end := j.end.in(open, close)
+	t := start
+	if !t.After(after) {
+		n := after.Sub(start)/j.every + 1
+		t = start.Add(n * j.every)
+	}
+	return t, t.Before(end)
+}
+
+// load fetches the lookahead days of calendar from the day of from, and the
+// skew of the local clock
+func (s *Scheduler) load(from time.Time) error {
+	y, m, d := from.In(newYork).Date()
+	first := time.Date(y, m, d, 0, 0, 0, 0, newYork)
+	until := first.AddDate(0, 0, s.lookahead)
+	start := first.Format("2006-01-02")
+	end := until.AddDate(0, 0, -1).Format("2006-01-02")
+	days, err := s.client.GetCalendar(&start, &end)
+	if err != nil {
+		return err
+	}
+	sessions, err := bars.CalendarSessions(days)
+	if err != nil {
+		return err
+	}
+
+	sent := s.clock.Now()
+	market, err := s.client.GetClock()
+	if err != nil {
+		return err
+	}
+	s.sessions, s.until = sessions, until
+	s.skew = alpaca.ClockOffset(sent, s.clock.Now(), market.Timestamp)
+	return nil
+}
@@ -0,0 +1,190 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ScheduleTestSuite struct {
+	suite.Suite
+	mock      *alpacatest.MockClient
+	calendar  []alpaca.CalendarDay
+	calendars [][2]string
+	market    time.Time
+}
+
+func TestScheduleTestSuite(t *testing.T) {
+	suite.Run(t, new(ScheduleTestSuite))
+}
+
+func ny(y int, m time.Month, d, h, min int) time.Time {
+	return time.Date(y, m, d, h, min, 0, 0, newYork)
+}
+
+func (s *ScheduleTestSuite) SetupTest() {
+	s.calendar = []alpaca.CalendarDay{
+		{Date: "2021-03-12", Open: "09:30", Close: "16:00"},
+		// daylight saving time starts on the 14th
+		{Date: "2021-03-15", Open: "09:30", Close: "16:00"},
+		{Date: "2021-04-01", Open: "09:30", Close: "16:00"},
+		// Good Friday is a holiday
+		{Date: "2021-04-05", Open: "09:30", Close: "16:00"},
+		{Date: "2021-11-24", Open: "09:30", Close: "16:00"},
+		// half-day after Thanksgiving
+		{Date: "2021-11-26", Open: "09:30", Close: "13:00"},
+		{Date: "2021-11-29", Open: "09:30", Close: "16:00"},
+	}
+	s.calendars = nil
+	s.market = time.Time{}
+	s.mock = &alpacatest.MockClient{
+		GetCalendarFunc: func(start, end *string) ([]alpaca.CalendarDay, error) {
+			s.calendars = append(s.calendars, [2]string{*start, *end})
+			var days []alpaca.CalendarDay
+			for _, day := range s.calendar {
+				if day.Date >= *start && day.Date <= *end {
+					days = append(days, day)
+				}
+			}
+			return days, nil
+		},
+		GetClockFunc: func() (*alpaca.Clock, error) {
+			if s.market.IsZero() {
+				return &alpaca.Clock{Timestamp: time.Now()}, nil
+			}
+			return &alpaca.Clock{Timestamp: s.market}, nil
+		},
+	}
+}
+
+// times returns the times of the jobs of scheduler after a time, until n
+func (s *ScheduleTestSuite) times(scheduler *Scheduler, after time.Time, n int) []time.Time {
+	var times []time.Time
+	for len(times) < n {
+		at, jobs, err := scheduler.next(after)
+		require.NoError(s.T(), err)
+		require.NotNil(s.T(), jobs)
+		times = append(times, at)
+		after = at
+	}
+	return times
+}
+
+func (s *ScheduleTestSuite) TestAt() {
+	scheduler := New(s.mock)
+	scheduler.At(Open.Add(5*time.Minute), func(time.Time) {})
+
+	times := s.times(scheduler, ny(2021, 3, 12, 9, 35), 3)
+	// skips the weekend, across the change of time
+	assert.True(s.T(), ny(2021, 3, 15, 9, 35).Equal(times[0]))
+	assert.Equal(s.T(), 13, times[0].UTC().Hour())
+	// skips the days missing from the calendar
+	assert.True(s.T(), ny(2021, 4, 1, 9, 35).Equal(times[1]))
+	assert.True(s.T(), ny(2021, 4, 5, 9, 35).Equal(times[2]))
+	assert.Equal(s.T(), [][2]string{{"2021-03-12", "2021-04-10"}}, s.calendars)
+}
+
+func (s *ScheduleTestSuite) TestOnClose() {
+	scheduler := New(s.mock)
+	scheduler.OnClose(func(time.Time) {})
+	scheduler.At(Close.Add(-10*time.Minute), func(time.Time) {})
+
+	times := s.times(scheduler, ny(2021, 11, 24, 12, 0), 4)
+	assert.True(s.T(), ny(2021, 11, 24, 15, 50).Equal(times[0]))
+	assert.True(s.T(), ny(2021, 11, 24, 16, 0).Equal(times[1]))
+	// early on the half-day
+	assert.True(s.T(), ny(2021, 11, 26, 12, 50).Equal(times[2]))
+	assert.True(s.T(), ny(2021, 11, 26, 13, 0).Equal(times[3]))
+}
+
+func (s *ScheduleTestSuite) TestEveryDuringMarketHours() {
+	scheduler := New(s.mock)
+	scheduler.Every(30 * time.Minute).DuringMarketHours(func(time.Time) {})
+	scheduler.OnClose(func(time.Time) {})
+
+	times := s.times(scheduler, ny(2021, 11, 26, 11, 10), 5)
+	assert.True(s.T(), ny(2021, 11, 26, 11, 30).Equal(times[0]))
+	assert.True(s.T(), ny(2021, 11, 26, 12, 30).Equal(times[2]))
+	// the close is not an interval time, but the close of the half-day
+	assert.True(s.T(), ny(2021, 11, 26, 13, 0).Equal(times[3]))
+	_, jobs, err := scheduler.next(times[2])
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), jobs, 1)
+	// the first interval time is the open
+	assert.True(s.T(), ny(2021, 11, 29, 9, 30).Equal(times[4]))
+}
+
+func (s *ScheduleTestSuite) TestBetween() {
+	scheduler := New(s.mock)
+	scheduler.Every(20*time.Minute).Between(Open.Add(-time.Hour), Open, func(time.Time) {})
+
+	times := s.times(scheduler, ny(2021, 3, 12, 12, 0), 4)
+	assert.True(s.T(), ny(2021, 3, 15, 8, 30).Equal(times[0]))
+	assert.True(s.T(), ny(2021, 3, 15, 9, 10).Equal(times[2]))
+	assert.True(s.T(), ny(2021, 4, 1, 8, 30).Equal(times[3]))
+	assert.Panics(s.T(), func() { scheduler.Every(0).DuringMarketHours(func(time.Time) {}) })
+}
+
+func (s *ScheduleTestSuite) TestNoSessions() {
+	scheduler := New(s.mock)
+	scheduler.OnOpen(func(time.Time) {})
+	_, _, err := scheduler.next(ny(2022, 1, 1, 0, 0))
+	assert.Equal(s.T(), ErrNoSessions, err)
+
+	s.mock.GetCalendarFunc = func(start, end *string) ([]alpaca.CalendarDay, error) {
+		return nil, errors.New("calendar unavailable")
+	}
+	err = New(s.mock).Run(context.Background())
+	assert.EqualError(s.T(), err, "calendar unavailable")
+}
+
+func (s *ScheduleTestSuite) TestRun() {
+	// the local clock is an hour behind the market one, which is a minute
+	// before the open
+	open := ny(2021, 3, 15, 9, 30)
+	s.market = open.Add(-time.Minute)
+	local := clock.NewFake(s.market.Add(-time.Hour))
+	scheduler := New(s.mock, WithClock(local))
+
+	var mu sync.Mutex
+	var ran []time.Time
+	done := make(chan struct{})
+	scheduler.OnOpen(func(t time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, t)
+		close(done)
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() { errs <- scheduler.Run(ctx) }()
+
+	local.BlockUntil(1)
+	local.Advance(59 * time.Second)
+	select {
+	case <-done:
+		require.FailNow(s.T(), "the job ran early")
+	default:
+	}
+	local.Advance(time.Second)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow(s.T(), "the job did not run")
+	}
+	cancel()
+	assert.Equal(s.T(), context.Canceled, <-errs)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(s.T(), ran, 1)
+	assert.True(s.T(), open.Equal(ran[0]))
+}
@@ -0,0 +1,298 @@
+// Package screener filters a universe of assets by their attributes and
+// their market snapshots, and ranks the ones that pass, e.g.
+//
+//	s := screener.New(client,
+//		screener.WithPriceRange(5, 50),
+//		screener.WithMinAvgVolume(1000000, 20),
+//		screener.WithChangeRange(0.05, 1),
+//		screener.WithShortable(),
+//		screener.WithRank(screener.ByChange),
+//		screener.WithLimit(20),
+//	)
+//	results, err := s.Screen()
+package screener
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+)
+
+// snapshotBatch is the number of symbols of a snapshots request
+const snapshotBatch = 500
+
+// Result is an asset that passed the screen
+type Result struct {
+	Asset alpaca.Asset
+	// Price is the price of the latest trade
+	Price float64
+	// Change is the change of Price from the previous close, e.g. 0.05 for 5%
+	Change float64
+	// Volume is the volume of the day
+	Volume uint64
+	// AvgVolume is the average daily volume, 0 unless WithMinAvgVolume is set
+	AvgVolume float64
+}
+
+// Symbol returns the symbol of the asset
+func (r Result) Symbol() string {
+	return r.Asset.Symbol
+}
+
+// Rank orders the results, returning true if a ranks before b
+type Rank func(a, b Result) bool
+
+// The ranks of the results, largest first
+var (
+	ByChange       Rank = func(a, b Result) bool { return a.Change > b.Change }
+	ByAbsChange    Rank = func(a, b Result) bool { return math.Abs(a.Change) > math.Abs(b.Change) }
+	ByVolume       Rank = func(a, b Result) bool { return a.Volume > b.Volume }
+	ByAvgVolume    Rank = func(a, b Result) bool { return a.AvgVolume > b.AvgVolume }
+	ByDollarVolume Rank = func(a, b Result) bool {
+		return a.Price*float64(a.Volume) > b.Price*float64(b.Volume)
+	}
+)
+
+// Option configures a Screener
+type Option func(s *Screener)
+
+// WithUniverse screens symbols only, instead of every active and tradable
+// US equity.
+func WithUniverse(symbols ...string) Option {
+	return func(s *Screener) {
+		s.universe = append(s.universe, symbols...)
+	}
+}
+
+// WithPriceRange keeps the assets whose price is in [min, max], 0 for no
+// bound.
+func WithPriceRange(min, max float64) Option {
+	return func(s *Screener) {
+		s.minPrice, s.maxPrice = min, max
+	}
+}
+
+// WithChangeRange keeps the assets whose change from the previous close is
+// in [min, max], e.g. 0.05 and math.Inf(1) for the gainers of 5% or more.
+func WithChangeRange(min, max float64) Option {
+	return func(s *Screener) {
+		s.minChange, s.maxChange = min, max
+	}
+}
+
+// WithMinAvgVolume keeps the assets whose average daily volume over the
+// last days is at least volume. It costs a bars request per asset left by
+// the other filters.
+func WithMinAvgVolume(volume float64, days int) Option {
+	return func(s *Screener) {
+		s.minAvgVolume, s.avgVolumeDays = volume, days
+	}
+}
+
+// WithShortable keeps the shortable assets.
+func WithShortable() Option {
+	return func(s *Screener) {
+		s.shortable = true
+	}
+}
+
+// WithEasyToBorrow keeps the shortable and easy to borrow assets.
+func WithEasyToBorrow() Option {
+	return func(s *Screener) {
+		s.shortable, s.easyToBorrow = true, true
+	}
+}
+
+// WithRank sets how the results are ranked, ByDollarVolume by default.
+func WithRank(rank Rank) Option {
+	return func(s *Screener) {
+		s.rank = rank
+	}
+}
+
+// WithLimit keeps the n first results, all of them by default.
+func WithLimit(n int) Option {
+	return func(s *Screener) {
+		s.limit = n
+	}
+}
+
+// Screener screens assets. Only the active and tradable assets pass.
+type Screener struct {
+	client        alpaca.TradingClient
+	universe      []string
+	minPrice      float64
+	maxPrice      float64
+	minChange     float64
+	maxChange     float64
+	minAvgVolume  float64
+	avgVolumeDays int
+	shortable     bool
+	easyToBorrow  bool
+	rank          Rank
+	limit         int
+	now           func() time.Time
+}
+
+// New creates a screener fetching the assets and market data with client.
+func New(client alpaca.TradingClient, opts ...Option) *Screener {
+	s := &Screener{
+		client:    client,
+		minChange: math.Inf(-1),
+		maxChange: math.Inf(1),
+		rank:      ByDollarVolume,
+		now:       time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Screen returns the ranked results of the assets that pass the screen.
+func (s *Screener) Screen() ([]Result, error) {
+	assets, err := s.assets()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for start := 0; start < len(assets); start += snapshotBatch {
+		batch := assets[start:min(start+snapshotBatch, len(assets))]
+		symbols := make([]string, len(batch))
+		for i, asset := range batch {
+			symbols[i] = asset.Symbol
+		}
+		snapshots, err := s.client.GetSnapshots(symbols)
+		if err != nil {
+			return nil, err
+		}
+		for _, asset := range batch {
+			if r, ok := s.screen(asset, snapshots[asset.Symbol]); ok {
+				results = append(results, r)
+			}
+		}
+	}
+
+	if s.minAvgVolume > 0 {
+		kept := results[:0]
+		for _, r := range results {
+			avg, err := s.avgVolume(r.Asset.Symbol)
+			if err != nil {
+				return nil, err
+			}
+			if r.AvgVolume = avg; avg >= s.minAvgVolume {
+				kept = append(kept, r)
+			}
+		}
+		results = kept
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return s.rank(results[i], results[j])
+	})
+	if s.limit > 0 && len(results) > s.limit {
+		results = results[:s.limit]
+	}
+	return results, nil
+}
+
+// assets returns the assets of the universe that pass the asset filters
+func (s *Screener) assets() ([]alpaca.Asset, error) {
+	status := "active"
+	all, err := s.client.ListAssets(&status)
+	if err != nil {
+		return nil, err
+	}
+	var universe map[string]bool
+	if len(s.universe) > 0 {
+		universe = make(map[string]bool, len(s.universe))
+		for _, symbol := range s.universe {
+			universe[symbol] = true
+		}
+	}
+
+	var assets []alpaca.Asset
+	for _, asset := range all {
+		switch {
+		case universe != nil && !universe[asset.Symbol]:
+		case universe == nil && asset.Class != "us_equity":
+		case !asset.Tradable:
+		case s.shortable && !asset.Shortable:
+		case s.easyToBorrow && !asset.EasyToBorrow:
+		default:
+			assets = append(assets, asset)
+		}
+	}
+	return assets, nil
+}
+
+// screen applies the snapshot filters to asset
+func (s *Screener) screen(asset alpaca.Asset, snapshot *v2.Snapshot) (Result, bool) {
+	if snapshot == nil {
+		return Result{}, false
+	}
+	r := Result{Asset: asset}
+	switch {
+	case snapshot.LatestTrade != nil:
+		r.Price = snapshot.LatestTrade.Price
+	case snapshot.DailyBar != nil:
+		r.Price = snapshot.DailyBar.Close
+	default:
+		return Result{}, false
+	}
+	if snapshot.DailyBar != nil {
+		r.Volume = snapshot.DailyBar.Volume
+	}
+	if snapshot.PrevDailyBar != nil && snapshot.PrevDailyBar.Close > 0 {
+		r.Change = r.Price/snapshot.PrevDailyBar.Close - 1
+	}
+
+	if r.Price < s.minPrice || (s.maxPrice > 0 && r.Price > s.maxPrice) {
+		return Result{}, false
+	}
+	if r.Change < s.minChange || r.Change > s.maxChange {
+		return Result{}, false
+	}
+	return r, true
+}
+
+// avgVolume returns the average volume of the last days of symbol
+func (s *Screener) avgVolume(symbol string) (float64, error) {
+	end := s.now()
+	// enough calendar days for the trading days, with weekends and holidays
+	start := end.AddDate(0, 0, -2*s.avgVolumeDays-7)
+	var volumes []uint64
+	items := s.client.GetBars(symbol, v2.Day, v2.Raw, start, end, math.MaxInt32)
+	for item := range items {
+		if item.Error != nil {
+			go func() {
+				for range items {
+				}
+			}()
+			return 0, item.Error
+		}
+		volumes = append(volumes, item.Bar.Volume)
+	}
+	if len(volumes) > s.avgVolumeDays {
+		volumes = volumes[len(volumes)-s.avgVolumeDays:]
+	}
+	if len(volumes) == 0 {
+		return 0, nil
+	}
+	var total float64
+	for _, v := range volumes {
+		total += float64(v)
+	}
+	return total / float64(len(volumes)), nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
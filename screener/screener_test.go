@@ -0,0 +1,159 @@
+package screener
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ScreenerTestSuite struct {
+	suite.Suite
+	mock      *alpacatest.MockClient
+	assets    []alpaca.Asset
+	snapshots map[string]*v2.Snapshot
+	volumes   map[string][]uint64
+	batches   [][]string
+}
+
+func TestScreenerTestSuite(t *testing.T) {
+	suite.Run(t, new(ScreenerTestSuite))
+}
+
+func snapshot(price, prevClose float64, volume uint64) *v2.Snapshot {
+	return &v2.Snapshot{
+		LatestTrade:  &v2.Trade{Price: price},
+		DailyBar:     &v2.Bar{Close: price, Volume: volume},
+		PrevDailyBar: &v2.Bar{Close: prevClose},
+	}
+}
+
+func (s *ScreenerTestSuite) SetupTest() {
+	equity := func(symbol string, shortable, etb bool) alpaca.Asset {
+		return alpaca.Asset{Symbol: symbol, Class: "us_equity", Status: "active", Tradable: true,
+			Shortable: shortable, EasyToBorrow: etb}
+	}
+	s.assets = []alpaca.Asset{
+		equity("AAPL", true, true),
+		equity("GME", true, false),
+		equity("PENNY", false, false),
+		equity("BRK.A", true, true),
+		{Symbol: "HALT", Class: "us_equity", Status: "active", Tradable: false},
+		{Symbol: "BTCUSD", Class: "crypto", Status: "active", Tradable: true},
+		equity("NODATA", true, true),
+	}
+	s.snapshots = map[string]*v2.Snapshot{
+		"AAPL":   snapshot(121, 120, 1000000),
+		"GME":    snapshot(150, 100, 5000000),
+		"PENNY":  snapshot(0.5, 0.4, 9000000),
+		"BRK.A":  snapshot(400000, 400000, 100),
+		"HALT":   snapshot(10, 10, 0),
+		"BTCUSD": snapshot(50000, 40000, 10),
+		// quiet with no trade today
+		"NODATA": {DailyBar: &v2.Bar{Close: 20}},
+	}
+	s.volumes = map[string][]uint64{"AAPL": {100, 1000, 2000}, "GME": {10, 20, 30}}
+	s.batches = nil
+	s.mock = &alpacatest.MockClient{
+		ListAssetsFunc: func(status *string) ([]alpaca.Asset, error) {
+			assert.Equal(s.T(), "active", *status)
+			return s.assets, nil
+		},
+		GetSnapshotsFunc: func(symbols []string) (map[string]*v2.Snapshot, error) {
+			s.batches = append(s.batches, symbols)
+			snapshots := map[string]*v2.Snapshot{}
+			for _, symbol := range symbols {
+				if snap, ok := s.snapshots[symbol]; ok {
+					snapshots[symbol] = snap
+				}
+			}
+			return snapshots, nil
+		},
+		GetBarsFunc: func(symbol string, timeFrame v2.TimeFrame, adjustment v2.Adjustment, start, end time.Time, limit int) <-chan v2.BarItem {
+			assert.Equal(s.T(), v2.Day, timeFrame)
+			ch := make(chan v2.BarItem)
+			go func() {
+				defer close(ch)
+				for _, v := range s.volumes[symbol] {
+					ch <- v2.BarItem{Bar: v2.Bar{Volume: v}}
+				}
+			}()
+			return ch
+		},
+	}
+}
+
+func symbols(results []Result) []string {
+	var symbols []string
+	for _, r := range results {
+		symbols = append(symbols, r.Symbol())
+	}
+	return symbols
+}
+
+func (s *ScreenerTestSuite) TestScreen() {
+	results, err := New(s.mock).Screen()
+	require.NoError(s.T(), err)
+	// only tradable equities, by dollar volume
+	assert.Equal(s.T(), []string{"GME", "AAPL", "BRK.A", "PENNY", "NODATA"}, symbols(results))
+	assert.Equal(s.T(), 0.5, results[0].Change)
+	assert.Equal(s.T(), uint64(5000000), results[0].Volume)
+	assert.Equal(s.T(), 20.0, results[4].Price)
+	assert.Zero(s.T(), results[4].Change)
+}
+
+func (s *ScreenerTestSuite) TestFilters() {
+	results, err := New(s.mock, WithPriceRange(1, 1000), WithChangeRange(0.001, 1)).Screen()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"GME", "AAPL"}, symbols(results))
+
+	results, err = New(s.mock, WithShortable(), WithRank(ByChange)).Screen()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"GME", "AAPL", "BRK.A", "NODATA"}, symbols(results))
+
+	results, err = New(s.mock, WithEasyToBorrow(), WithRank(ByVolume), WithLimit(2)).Screen()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"AAPL", "BRK.A"}, symbols(results))
+
+	results, err = New(s.mock, WithUniverse("BTCUSD", "GME", "HALT")).Screen()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"GME", "BTCUSD"}, symbols(results))
+}
+
+func (s *ScreenerTestSuite) TestAvgVolume() {
+	results, err := New(s.mock, WithPriceRange(1, 1000), WithMinAvgVolume(1000, 2), WithRank(ByAvgVolume)).Screen()
+	require.NoError(s.T(), err)
+	require.Equal(s.T(), []string{"AAPL"}, symbols(results))
+	assert.Equal(s.T(), 1500.0, results[0].AvgVolume)
+
+	s.mock.GetBarsFunc = func(symbol string, timeFrame v2.TimeFrame, adjustment v2.Adjustment, start, end time.Time, limit int) <-chan v2.BarItem {
+		ch := make(chan v2.BarItem, 2)
+		ch <- v2.BarItem{Error: errors.New("rate limited")}
+		ch <- v2.BarItem{Error: errors.New("rate limited")}
+		close(ch)
+		return ch
+	}
+	_, err = New(s.mock, WithMinAvgVolume(1000, 2)).Screen()
+	assert.EqualError(s.T(), err, "rate limited")
+}
+
+func (s *ScreenerTestSuite) TestBatches() {
+	s.assets = nil
+	for i := 0; i < 1200; i++ {
+		symbol := fmt.Sprintf("S%d", i)
+		s.assets = append(s.assets, alpaca.Asset{Symbol: symbol, Class: "us_equity", Tradable: true})
+		s.snapshots[symbol] = snapshot(10, 10, uint64(i))
+	}
+	results, err := New(s.mock, WithRank(ByVolume), WithLimit(1)).Screen()
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []string{"S1199"}, symbols(results))
+	require.Len(s.T(), s.batches, 3)
+	assert.Len(s.T(), s.batches[2], 200)
+}
@@ -0,0 +1,422 @@
+package sim
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+)
+
+// The market data, clock, calendar and asset methods are served by the
+// client set with WithMarketData. The account methods the simulator has no
+// state for, and the crypto and options methods, are not supported.
+
+func (s *Simulator) market() (alpaca.TradingClient, error) {
+	if s.data == nil {
+		return nil, ErrNotSupported
+	}
+	return s.data, nil
+}
+
+// GetAccountConfigurations is not supported by the simulator.
+func (s *Simulator) GetAccountConfigurations() (*alpaca.AccountConfigurations, error) {
+	return nil, ErrNotSupported
+}
+
+// UpdateAccountConfigurations is not supported by the simulator.
+func (s *Simulator) UpdateAccountConfigurations(
+	newConfigs alpaca.AccountConfigurationsRequest,
+) (*alpaca.AccountConfigurations, error) {
+	return nil, ErrNotSupported
+}
+
+// GetAccountActivities is not supported by the simulator.
+func (s *Simulator) GetAccountActivities(
+	activityType *string, opts *alpaca.AccountActivitiesRequest,
+) ([]alpaca.AccountActivity, error) {
+	return nil, ErrNotSupported
+}
+
+// GetPortfolioHistory is not supported by the simulator.
+func (s *Simulator) GetPortfolioHistory(
+	period *string, timeframe *alpaca.RangeFreq, dateEnd *time.Time, extendedHours bool,
+) (*alpaca.PortfolioHistory, error) {
+	return nil, ErrNotSupported
+}
+
+// GetAggregates is served by the market data client.
+func (s *Simulator) GetAggregates(symbol, timespan, from, to string) (*alpaca.Aggregates, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetAggregates(symbol, timespan, from, to)
+}
+
+// GetLastQuote is served by the market data client.
+func (s *Simulator) GetLastQuote(symbol string) (*alpaca.LastQuoteResponse, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetLastQuote(symbol)
+}
+
+// GetLastTrade is served by the market data client.
+func (s *Simulator) GetLastTrade(symbol string) (*alpaca.LastTradeResponse, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetLastTrade(symbol)
+}
+
+// GetTrades is served by the market data client.
+func (s *Simulator) GetTrades(symbol string, start, end time.Time, limit int) <-chan v2.TradeItem {
+	data, err := s.market()
+	if err != nil {
+		ch := make(chan v2.TradeItem, 1)
+		ch <- v2.TradeItem{Error: err}
+		close(ch)
+		return ch
+	}
+	return data.GetTrades(symbol, start, end, limit)
+}
+
+// GetQuotes is served by the market data client.
+func (s *Simulator) GetQuotes(symbol string, start, end time.Time, limit int) <-chan v2.QuoteItem {
+	data, err := s.market()
+	if err != nil {
+		ch := make(chan v2.QuoteItem, 1)
+		ch <- v2.QuoteItem{Error: err}
+		close(ch)
+		return ch
+	}
+	return data.GetQuotes(symbol, start, end, limit)
+}
+
+// GetBars is served by the market data client.
+func (s *Simulator) GetBars(
+	symbol string, timeFrame v2.TimeFrame, adjustment v2.Adjustment,
+	start, end time.Time, limit int,
+) <-chan v2.BarItem {
+	data, err := s.market()
+	if err != nil {
+		ch := make(chan v2.BarItem, 1)
+		ch <- v2.BarItem{Error: err}
+		close(ch)
+		return ch
+	}
+	return data.GetBars(symbol, timeFrame, adjustment, start, end, limit)
+}
+
+// GetMultiBars is served by the market data client.
+func (s *Simulator) GetMultiBars(
+	symbols []string, timeFrame v2.TimeFrame, adjustment v2.Adjustment, start, end time.Time,
+) (map[string][]v2.Bar, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetMultiBars(symbols, timeFrame, adjustment, start, end)
+}
+
+// GetLatestTrade is served by the market data client.
+func (s *Simulator) GetLatestTrade(symbol string) (*v2.Trade, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetLatestTrade(symbol)
+}
+
+// GetLatestQuote is served by the market data client.
+func (s *Simulator) GetLatestQuote(symbol string) (*v2.Quote, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetLatestQuote(symbol)
+}
+
+// GetMarkPrice is served by the market data client.
+func (s *Simulator) GetMarkPrice(symbol string) (*alpaca.MarkPrice, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetMarkPrice(symbol)
+}
+
+// GetSnapshot is served by the market data client.
+func (s *Simulator) GetSnapshot(symbol string) (*v2.Snapshot, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetSnapshot(symbol)
+}
+
+// GetSnapshots is served by the market data client.
+func (s *Simulator) GetSnapshots(symbols []string) (map[string]*v2.Snapshot, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetSnapshots(symbols)
+}
+
+// GetPositionBars returns the simulated positions with their bars and
+// snapshots served by the market data client.
+func (s *Simulator) GetPositionBars(
+	timeFrame v2.TimeFrame, lookback time.Duration,
+) (map[string]*alpaca.PositionBars, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	end := s.now()
+	return alpaca.PositionBarsOf(s, data, timeFrame, end.Add(-lookback), end)
+}
+
+// ListBars is served by the market data client.
+func (s *Simulator) ListBars(symbols []string, opts alpaca.ListBarParams) (map[string][]alpaca.Bar, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.ListBars(symbols, opts)
+}
+
+// GetSymbolBars is served by the market data client.
+func (s *Simulator) GetSymbolBars(symbol string, opts alpaca.ListBarParams) ([]alpaca.Bar, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetSymbolBars(symbol, opts)
+}
+
+// ListCryptoPairs is served by the market data client.
+func (s *Simulator) ListCryptoPairs(status *string) ([]alpaca.CryptoPair, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.ListCryptoPairs(status)
+}
+
+// GetCryptoSnapshot is served by the market data client.
+func (s *Simulator) GetCryptoSnapshot(symbol string) (*v2.CryptoSnapshot, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetCryptoSnapshot(symbol)
+}
+
+// GetClock is served by the market data client.
+func (s *Simulator) GetClock() (*alpaca.Clock, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetClock()
+}
+
+// GetCalendar is served by the market data client.
+func (s *Simulator) GetCalendar(start, end *string) ([]alpaca.CalendarDay, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetCalendar(start, end)
+}
+
+// ListAnnouncements is served by the market data client.
+func (s *Simulator) ListAnnouncements(req alpaca.ListAnnouncementsRequest) ([]alpaca.Announcement, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.ListAnnouncements(req)
+}
+
+// ListAssets is served by the market data client.
+func (s *Simulator) ListAssets(status *string) ([]alpaca.Asset, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.ListAssets(status)
+}
+
+// GetAsset is served by the market data client.
+func (s *Simulator) GetAsset(symbol string) (*alpaca.Asset, error) {
+	data, err := s.market()
+	if err != nil {
+		return nil, err
+	}
+	return data.GetAsset(symbol)
+}
+
+// IsShortable is served by the market data client.
+func (s *Simulator) IsShortable(symbol string) (bool, error) {
+	data, err := s.market()
+	if err != nil {
+		return false, err
+	}
+	return data.IsShortable(symbol)
+}
+
+// WouldTriggerPDT returns false, the simulator has no day trading rules.
+func (s *Simulator) WouldTriggerPDT(proposed alpaca.PlaceOrderRequest) (bool, error) {
+	return false, nil
+}
+
+// ListCryptoWallets is not supported by the simulator.
+func (s *Simulator) ListCryptoWallets(asset *string) ([]alpaca.CryptoWallet, error) {
+	return nil, ErrNotSupported
+}
+
+// ListWhitelistedAddresses is not supported by the simulator.
+func (s *Simulator) ListWhitelistedAddresses() ([]alpaca.WhitelistedAddress, error) {
+	return nil, ErrNotSupported
+}
+
+// CreateWhitelistedAddress is not supported by the simulator.
+func (s *Simulator) CreateWhitelistedAddress(
+	req alpaca.CreateWhitelistedAddressRequest,
+) (*alpaca.WhitelistedAddress, error) {
+	return nil, ErrNotSupported
+}
+
+// DeleteWhitelistedAddress is not supported by the simulator.
+func (s *Simulator) DeleteWhitelistedAddress(addressID string) error {
+	return ErrNotSupported
+}
+
+// ListCryptoTransfers is not supported by the simulator.
+func (s *Simulator) ListCryptoTransfers() ([]alpaca.CryptoTransfer, error) {
+	return nil, ErrNotSupported
+}
+
+// GetCryptoTransfer is not supported by the simulator.
+func (s *Simulator) GetCryptoTransfer(transferID string) (*alpaca.CryptoTransfer, error) {
+	return nil, ErrNotSupported
+}
+
+// CreateCryptoTransfer is not supported by the simulator.
+func (s *Simulator) CreateCryptoTransfer(req alpaca.CreateCryptoTransferRequest) (*alpaca.CryptoTransfer, error) {
+	return nil, ErrNotSupported
+}
+
+// WaitForCryptoTransfer is not supported by the simulator.
+func (s *Simulator) WaitForCryptoTransfer(
+	ctx context.Context, transferID string, interval time.Duration,
+) (*alpaca.CryptoTransfer, error) {
+	return nil, ErrNotSupported
+}
+
+// CreateCryptoTransferAndWait is not supported by the simulator.
+func (s *Simulator) CreateCryptoTransferAndWait(
+	ctx context.Context, req alpaca.CreateCryptoTransferRequest, interval time.Duration,
+) (*alpaca.CryptoTransfer, error) {
+	return nil, ErrNotSupported
+}
+
+// ListWatchlists is not supported by the simulator.
+func (s *Simulator) ListWatchlists() ([]alpaca.Watchlist, error) {
+	return nil, ErrNotSupported
+}
+
+// GetWatchlist is not supported by the simulator.
+func (s *Simulator) GetWatchlist(watchlistID string) (*alpaca.Watchlist, error) {
+	return nil, ErrNotSupported
+}
+
+// GetWatchlistByName is not supported by the simulator.
+func (s *Simulator) GetWatchlistByName(name string) (*alpaca.Watchlist, error) {
+	return nil, ErrNotSupported
+}
+
+// ListCryptoFees is not supported by the simulator.
+func (s *Simulator) ListCryptoFees(opts *alpaca.AccountActivitiesRequest) ([]alpaca.CryptoFee, error) {
+	return nil, ErrNotSupported
+}
+
+// GetCryptoFeeTier is not supported by the simulator.
+func (s *Simulator) GetCryptoFeeTier() (*alpaca.CryptoFeeTier, error) {
+	return nil, ErrNotSupported
+}
+
+// GetOptionContracts is not supported by the simulator.
+func (s *Simulator) GetOptionContracts(filter alpaca.GetOptionContractsRequest) ([]alpaca.OptionContract, error) {
+	return nil, ErrNotSupported
+}
+
+// GetOptionContract is not supported by the simulator.
+func (s *Simulator) GetOptionContract(symbolOrID string) (*alpaca.OptionContract, error) {
+	return nil, ErrNotSupported
+}
+
+// ExerciseOptionPosition is not supported by the simulator.
+func (s *Simulator) ExerciseOptionPosition(symbol string) error {
+	return ErrNotSupported
+}
+
+// DoRaw is not supported by the simulator.
+func (s *Simulator) DoRaw(method, path string, body interface{}) (json.RawMessage, error) {
+	return nil, ErrNotSupported
+}
+
+// StreamTradeUpdateEvents calls handler with the trade updates of the
+// simulated orders, since req.Since when set, until ctx is canceled or,
+// if req.Until is set, until the updates recorded so far up to that time
+// were handled.
+func (s *Simulator) StreamTradeUpdateEvents(
+	ctx context.Context, req alpaca.StreamEventsRequest, handler func(event alpaca.TradeUpdateEvent),
+) error {
+	next := 0
+	if req.Since == nil && req.Until == nil {
+		// only the updates from now on
+		s.mu.Lock()
+		next = len(s.events)
+		s.mu.Unlock()
+	}
+	for {
+		s.mu.Lock()
+		events := s.events[next:]
+		next = len(s.events)
+		changed := s.notify
+		s.mu.Unlock()
+
+		for _, e := range events {
+			if req.Since != nil && e.At.Before(*req.Since) {
+				continue
+			}
+			if req.Until != nil && e.At.After(*req.Until) {
+				continue
+			}
+			handler(e)
+		}
+		if req.Until != nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// StreamNonTradeActivityEvents is not supported by the simulator.
+func (s *Simulator) StreamNonTradeActivityEvents(
+	ctx context.Context, req alpaca.StreamEventsRequest, handler func(event alpaca.NonTradeActivityEvent),
+) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,697 @@
+// Package sim is a paper-trading simulator implementing alpaca.TradingClient
+// locally. It tracks orders, positions and cash, fills the orders against the
+// market data it is fed, live or replayed, and emits the trade updates of the
+// simulated orders, so a bot can be tested end to end without an account.
+//
+//	s := sim.New(sim.WithCash(decimal.New(100000, 0)))
+//	stream.SubscribeQuotes(s.UpdateQuote, "AAPL")
+//	bot := NewBot(s) // takes an alpaca.TradingClient
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+)
+
+// ErrNotSupported is returned by the methods the simulator does not implement
+// and, without WithMarketData, by the market data methods.
+var ErrNotSupported = errors.New("not supported by the simulator")
+
+// ErrOrderNotFound is returned for unknown order IDs
+var ErrOrderNotFound = errors.New("order not found")
+
+// Option configures a simulator
+type Option func(s *Simulator)
+
+// WithCash sets the starting cash of the simulated account, 0 by default.
+func WithCash(cash decimal.Decimal) Option {
+	return func(s *Simulator) {
+		s.cash = cash
+	}
+}
+
+// WithMarketData serves the market data, clock, calendar and asset methods
+// from client, e.g. an alpaca.Client or an alpacatest.MockClient.
+func WithMarketData(client alpaca.TradingClient) Option {
+	return func(s *Simulator) {
+		s.data = client
+	}
+}
+
+// WithClock sets the function returning the simulated time, time.Now by default.
+// Replaying historical data, pass a function returning the time of the replayed data.
+func WithClock(now func() time.Time) Option {
+	return func(s *Simulator) {
+		s.now = now
+	}
+}
+
+// price is the last known market of a symbol
+type price struct {
+	bid, ask, last decimal.Decimal
+}
+
+type position struct {
+	qty, avgEntryPrice decimal.Decimal
+}
+
+// Simulator is a local alpaca.TradingClient. Feed it market data with
+// UpdateTrade, UpdateQuote and UpdateBar: market orders fill at the ask
+// (buys) or the bid (sells) of the last quote, or at the last trade price
+// or bar close, and limit, stop and stop limit orders fill once the market
+// reaches their prices. Orders are filled completely, IOC and FOK orders
+// that do not fill right away are canceled, and the other orders never
+// expire. Buying power is the cash not reserved by open buy orders.
+type Simulator struct {
+	data alpaca.TradingClient
+	now  func() time.Time
+
+	mu        sync.Mutex
+	nextID    int
+	cash      decimal.Decimal
+	orders    []*alpaca.Order
+	triggered map[string]bool
+	positions map[string]*position
+	prices    map[string]price
+	handlers  []func(update alpaca.TradeUpdate)
+	events    []alpaca.TradeUpdateEvent
+	notify    chan struct{}
+}
+
+var _ alpaca.TradingClient = (*Simulator)(nil)
+
+// New creates a simulator.
+func New(opts ...Option) *Simulator {
+	s := &Simulator{
+		now:       time.Now,
+		triggered: map[string]bool{},
+		positions: map[string]*position{},
+		prices:    map[string]price{},
+		notify:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SubscribeTradeUpdates calls handler with the updates of the simulated
+// orders, from the goroutine placing the orders or feeding the market data.
+func (s *Simulator) SubscribeTradeUpdates(handler func(update alpaca.TradeUpdate)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers = append(s.handlers, handler)
+}
+
+// UpdateTrade fills the orders of the trade symbol at the trade price.
+// It can be passed to stream.SubscribeTrades.
+func (s *Simulator) UpdateTrade(trade stream.Trade) {
+	p := decimal.NewFromFloat(trade.Price)
+	s.update(trade.Symbol, price{bid: p, ask: p, last: p})
+}
+
+// UpdateQuote fills the orders of the quote symbol at its bid or ask.
+// It can be passed to stream.SubscribeQuotes.
+func (s *Simulator) UpdateQuote(quote stream.Quote) {
+	s.mu.Lock()
+	last := s.prices[quote.Symbol].last
+	s.mu.Unlock()
+	bid, ask := decimal.NewFromFloat(quote.BidPrice), decimal.NewFromFloat(quote.AskPrice)
+	if last.IsZero() {
+		last = bid.Add(ask).Div(decimal.New(2, 0))
+	}
+	s.update(quote.Symbol, price{bid: bid, ask: ask, last: last})
+}
+
+// UpdateBar fills the orders of the bar symbol at the bar close.
+// It can be passed to stream.SubscribeBars.
+func (s *Simulator) UpdateBar(bar stream.Bar) {
+	p := decimal.NewFromFloat(bar.Close)
+	s.update(bar.Symbol, price{bid: p, ask: p, last: p})
+}
+
+func (s *Simulator) update(symbol string, p price) {
+	s.mu.Lock()
+	s.prices[symbol] = p
+	var updates []alpaca.TradeUpdateEvent
+	for _, order := range s.orders {
+		if order.Symbol == symbol && isOpen(order) {
+			if u, ok := s.match(order); ok {
+				updates = append(updates, u)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	s.publish(updates...)
+}
+
+func isOpen(order *alpaca.Order) bool {
+	return order.Status == alpaca.OrderNew
+}
+
+// match fills order if the market reached its price, with s.mu held
+func (s *Simulator) match(order *alpaca.Order) (alpaca.TradeUpdateEvent, bool) {
+	p, ok := s.prices[order.Symbol]
+	if !ok {
+		return alpaca.TradeUpdateEvent{}, false
+	}
+	buy := order.Side == alpaca.Buy
+	fill := p.bid
+	if buy {
+		fill = p.ask
+	}
+
+	if order.Type == alpaca.Stop || order.Type == alpaca.StopLimit {
+		if !s.triggered[order.ID] {
+			if buy && fill.LessThan(*order.StopPrice) || !buy && fill.GreaterThan(*order.StopPrice) {
+				return alpaca.TradeUpdateEvent{}, false
+			}
+			s.triggered[order.ID] = true
+		}
+	}
+	if order.Type == alpaca.Limit || order.Type == alpaca.StopLimit {
+		if buy && fill.GreaterThan(*order.LimitPrice) || !buy && fill.LessThan(*order.LimitPrice) {
+			return alpaca.TradeUpdateEvent{}, false
+		}
+	}
+	return s.fill(order, fill), true
+}
+
+// fill executes order at price, with s.mu held
+func (s *Simulator) fill(order *alpaca.Order, price decimal.Decimal) alpaca.TradeUpdateEvent {
+	now := s.now()
+	qty := order.Qty
+	if order.Side == alpaca.Sell {
+		qty = qty.Neg()
+	}
+	s.cash = s.cash.Sub(qty.Mul(price))
+
+	pos, ok := s.positions[order.Symbol]
+	if !ok {
+		pos = &position{}
+		s.positions[order.Symbol] = pos
+	}
+	pos.apply(qty, price)
+	if pos.qty.IsZero() {
+		delete(s.positions, order.Symbol)
+	}
+
+	order.Status = alpaca.OrderFilled
+	order.UpdatedAt = now
+	order.FilledAt = &now
+	order.FilledQty = order.Qty
+	order.FilledAvgPrice = &price
+	delete(s.triggered, order.ID)
+
+	return s.event(alpaca.EventFill, order, func(e *alpaca.TradeUpdateEvent) {
+		e.ExecutionID = fmt.Sprintf("%s-fill", order.ID)
+		e.Price = price
+		e.Qty = order.Qty
+		e.PositionQty = pos.qty
+	})
+}
+
+// apply adds the signed quantity qty filled at price to the position
+func (p *position) apply(qty, price decimal.Decimal) {
+	p.qty, p.avgEntryPrice, _ = alpaca.ApplyFill(p.qty, p.avgEntryPrice, qty, price)
+}
+
+// event records the trade update of order, with s.mu held
+func (s *Simulator) event(
+	event alpaca.TradeEvent, order *alpaca.Order, with func(e *alpaca.TradeUpdateEvent),
+) alpaca.TradeUpdateEvent {
+	now := s.now()
+	e := alpaca.TradeUpdateEvent{
+		EventID:   fmt.Sprintf("%d", len(s.events)+1),
+		At:        now,
+		AccountID: accountID,
+		Event:     event,
+		Order:     *order,
+		Timestamp: now,
+	}
+	if with != nil {
+		with(&e)
+	}
+	s.events = append(s.events, e)
+	close(s.notify)
+	s.notify = make(chan struct{})
+	return e
+}
+
+// publish calls the trade update handlers, without s.mu held
+func (s *Simulator) publish(events ...alpaca.TradeUpdateEvent) {
+	if len(events) == 0 {
+		return
+	}
+	s.mu.Lock()
+	handlers := s.handlers
+	s.mu.Unlock()
+	for _, e := range events {
+		for _, handler := range handlers {
+			handler(alpaca.TradeUpdate{Event: e.Event, Order: e.Order})
+		}
+	}
+}
+
+func (s *Simulator) validate(req alpaca.PlaceOrderRequest) error {
+	if req.AssetKey == nil || *req.AssetKey == "" {
+		return errors.New("symbol is required")
+	}
+	if !req.Qty.IsPositive() {
+		return errors.New("qty must be positive, notional orders are not supported by the simulator")
+	}
+	if req.Side != alpaca.Buy && req.Side != alpaca.Sell {
+		return fmt.Errorf("invalid side %q", req.Side)
+	}
+	switch req.Type {
+	case alpaca.Market:
+	case alpaca.Limit:
+		if req.LimitPrice == nil {
+			return errors.New("limit orders require a limit price")
+		}
+	case alpaca.Stop:
+		if req.StopPrice == nil {
+			return errors.New("stop orders require a stop price")
+		}
+	case alpaca.StopLimit:
+		if req.LimitPrice == nil || req.StopPrice == nil {
+			return errors.New("stop limit orders require a limit and a stop price")
+		}
+	default:
+		return fmt.Errorf("%s orders are not supported by the simulator", req.Type)
+	}
+	if req.OrderClass != "" && req.OrderClass != alpaca.Simple {
+		return fmt.Errorf("%s orders are not supported by the simulator", req.OrderClass)
+	}
+	return nil
+}
+
+// cost returns the estimated cost of a buy order, with s.mu held
+func (s *Simulator) cost(qty decimal.Decimal, limitPrice *decimal.Decimal, symbol string) decimal.Decimal {
+	if limitPrice != nil {
+		return qty.Mul(*limitPrice)
+	}
+	return qty.Mul(s.prices[symbol].ask)
+}
+
+// buyingPower returns the cash not reserved by the open buy orders, with s.mu held
+func (s *Simulator) buyingPower() decimal.Decimal {
+	bp := s.cash
+	for _, order := range s.orders {
+		if isOpen(order) && order.Side == alpaca.Buy {
+			bp = bp.Sub(s.cost(order.Qty, order.LimitPrice, order.Symbol))
+		}
+	}
+	if bp.IsNegative() {
+		return decimal.Zero
+	}
+	return bp
+}
+
+// ValidateOrder checks that the simulator accepts req without placing it.
+func (s *Simulator) ValidateOrder(req alpaca.PlaceOrderRequest) error {
+	if err := s.validate(req); err != nil {
+		return err
+	}
+	if req.Side == alpaca.Buy {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.cost(req.Qty, req.LimitPrice, *req.AssetKey).GreaterThan(s.buyingPower()) {
+			return alpaca.ErrInsufficientBuyingPower
+		}
+	}
+	return nil
+}
+
+// PlaceOrder places a simulated order, which fills right away when the
+// market already reached its price.
+func (s *Simulator) PlaceOrder(req alpaca.PlaceOrderRequest) (*alpaca.Order, error) {
+	if err := s.ValidateOrder(req); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.nextID++
+	now := s.now()
+	order := &alpaca.Order{
+		ID:            fmt.Sprintf("sim-%d", s.nextID),
+		ClientOrderID: req.ClientOrderID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		SubmittedAt:   now,
+		Symbol:        *req.AssetKey,
+		Class:         "us_equity",
+		Qty:           req.Qty,
+		Type:          req.Type,
+		Side:          req.Side,
+		TimeInForce:   req.TimeInForce,
+		LimitPrice:    copyPrice(req.LimitPrice),
+		StopPrice:     copyPrice(req.StopPrice),
+		Status:        alpaca.OrderNew,
+		OrderClass:    alpaca.Simple,
+		ExtendedHours: req.ExtendedHours,
+	}
+	if order.ClientOrderID == "" {
+		order.ClientOrderID = order.ID
+	}
+	s.orders = append(s.orders, order)
+	updates := []alpaca.TradeUpdateEvent{s.event(alpaca.EventNew, order, nil)}
+	if u, ok := s.match(order); ok {
+		updates = append(updates, u)
+	} else if order.TimeInForce == alpaca.IOC || order.TimeInForce == alpaca.FOK {
+		updates = append(updates, s.cancel(order))
+	}
+	o := *order
+	s.mu.Unlock()
+
+	s.publish(updates...)
+	return &o, nil
+}
+
+func copyPrice(price *decimal.Decimal) *decimal.Decimal {
+	if price == nil {
+		return nil
+	}
+	p := *price
+	return &p
+}
+
+// cancel cancels order, with s.mu held
+func (s *Simulator) cancel(order *alpaca.Order) alpaca.TradeUpdateEvent {
+	now := s.now()
+	order.Status = alpaca.OrderCanceled
+	order.UpdatedAt = now
+	order.CanceledAt = &now
+	delete(s.triggered, order.ID)
+	return s.event(alpaca.EventCanceled, order, nil)
+}
+
+// find returns the order with the given ID, with s.mu held
+func (s *Simulator) find(orderID string) (*alpaca.Order, error) {
+	for _, order := range s.orders {
+		if order.ID == orderID {
+			return order, nil
+		}
+	}
+	return nil, ErrOrderNotFound
+}
+
+// GetOrder returns a simulated order.
+func (s *Simulator) GetOrder(orderID string) (*alpaca.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, err := s.find(orderID)
+	if err != nil {
+		return nil, err
+	}
+	o := *order
+	return &o, nil
+}
+
+// GetNestedOrder returns a simulated order, which never has legs.
+func (s *Simulator) GetNestedOrder(orderID string) (*alpaca.Order, error) {
+	return s.GetOrder(orderID)
+}
+
+// GetOrderByClientOrderID returns the simulated order with the given client order ID.
+func (s *Simulator) GetOrderByClientOrderID(clientOrderID string) (*alpaca.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, order := range s.orders {
+		if order.ClientOrderID == clientOrderID {
+			o := *order
+			return &o, nil
+		}
+	}
+	return nil, ErrOrderNotFound
+}
+
+// ListOrders lists the simulated orders, newest first. status is "open"
+// (by default), "closed" or "all", and limit defaults to 50.
+func (s *Simulator) ListOrders(status *string, until *time.Time, limit *int, nested *bool) ([]alpaca.Order, error) {
+	st := "open"
+	if status != nil {
+		st = *status
+	}
+	max := 50
+	if limit != nil {
+		max = *limit
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	orders := []alpaca.Order{}
+	for i := len(s.orders) - 1; i >= 0 && len(orders) < max; i-- {
+		order := s.orders[i]
+		if st == "open" && !isOpen(order) || st == "closed" && isOpen(order) {
+			continue
+		}
+		if until != nil && !order.SubmittedAt.Before(*until) {
+			continue
+		}
+		orders = append(orders, *order)
+	}
+	return orders, nil
+}
+
+// ReplaceOrder replaces an open simulated order with a new one.
+func (s *Simulator) ReplaceOrder(orderID string, req alpaca.ReplaceOrderRequest) (*alpaca.Order, error) {
+	s.mu.Lock()
+	old, err := s.find(orderID)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if !isOpen(old) {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("order %s is %s and cannot be replaced", orderID, old.Status)
+	}
+	place := alpaca.PlaceOrderRequest{
+		AssetKey:      &old.Symbol,
+		Qty:           old.Qty,
+		Side:          old.Side,
+		Type:          old.Type,
+		TimeInForce:   old.TimeInForce,
+		LimitPrice:    old.LimitPrice,
+		StopPrice:     old.StopPrice,
+		ClientOrderID: req.ClientOrderID,
+		ExtendedHours: old.ExtendedHours,
+	}
+	if req.Qty != nil {
+		place.Qty = *req.Qty
+	}
+	if req.LimitPrice != nil {
+		place.LimitPrice = req.LimitPrice
+	}
+	if req.StopPrice != nil {
+		place.StopPrice = req.StopPrice
+	}
+	if req.TimeInForce != "" {
+		place.TimeInForce = req.TimeInForce
+	}
+	// the replaced order does not reserve buying power anymore
+	old.Status = alpaca.OrderPendingReplace
+	s.mu.Unlock()
+
+	order, err := s.PlaceOrder(place)
+
+	s.mu.Lock()
+	if err != nil {
+		old.Status = alpaca.OrderNew
+		s.mu.Unlock()
+		return nil, err
+	}
+	now := s.now()
+	old.Status = alpaca.OrderReplaced
+	old.UpdatedAt = now
+	old.ReplacedAt = &now
+	old.ReplacedBy = &order.ID
+	replaced := s.event(alpaca.EventReplaced, old, nil)
+	if o, err := s.find(order.ID); err == nil {
+		o.Replaces = &old.ID
+		order.Replaces = &old.ID
+	}
+	s.mu.Unlock()
+
+	s.publish(replaced)
+	return order, nil
+}
+
+// CancelOrder cancels an open simulated order.
+func (s *Simulator) CancelOrder(orderID string) error {
+	s.mu.Lock()
+	order, err := s.find(orderID)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	if !isOpen(order) {
+		s.mu.Unlock()
+		return alpaca.ErrOrderNotCancelable
+	}
+	update := s.cancel(order)
+	s.mu.Unlock()
+
+	s.publish(update)
+	return nil
+}
+
+// CancelAllOrders cancels every open simulated order.
+func (s *Simulator) CancelAllOrders() error {
+	s.mu.Lock()
+	var updates []alpaca.TradeUpdateEvent
+	for _, order := range s.orders {
+		if isOpen(order) {
+			updates = append(updates, s.cancel(order))
+		}
+	}
+	s.mu.Unlock()
+
+	s.publish(updates...)
+	return nil
+}
+
+const accountID = "sim"
+
+// GetAccount returns the simulated account, valuing the positions at the
+// last price of their symbol.
+func (s *Simulator) GetAccount() (*alpaca.Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	long, short := decimal.Zero, decimal.Zero
+	for symbol, pos := range s.positions {
+		value := pos.qty.Mul(s.prices[symbol].last)
+		if pos.qty.IsPositive() {
+			long = long.Add(value)
+		} else {
+			short = short.Add(value)
+		}
+	}
+	equity := s.cash.Add(long).Add(short)
+	bp := s.buyingPower()
+	return &alpaca.Account{
+		ID:                       accountID,
+		AccountNumber:            accountID,
+		Status:                   "ACTIVE",
+		Currency:                 "USD",
+		Cash:                     s.cash,
+		CashWithdrawable:         s.cash,
+		ShortingEnabled:          true,
+		BuyingPower:              bp,
+		RegTBuyingPower:          bp,
+		NonMarginableBuyingPower: bp,
+		Equity:                   equity,
+		LastEquity:               equity,
+		Multiplier:               decimal.New(1, 0),
+		LongMarketValue:          long,
+		ShortMarketValue:         short,
+		PortfolioValue:           equity,
+	}, nil
+}
+
+// ListPositions lists the simulated positions, sorted by symbol.
+func (s *Simulator) ListPositions() ([]alpaca.Position, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	positions := make([]alpaca.Position, 0, len(s.positions))
+	for symbol := range s.positions {
+		positions = append(positions, s.position(symbol))
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Symbol < positions[j].Symbol })
+	return positions, nil
+}
+
+// GetPosition returns the simulated position in symbol.
+func (s *Simulator) GetPosition(symbol string) (*alpaca.Position, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.positions[symbol]; !ok {
+		return nil, alpaca.ErrPositionNotFound
+	}
+	p := s.position(symbol)
+	return &p, nil
+}
+
+// position returns the entity of the position in symbol, with s.mu held
+func (s *Simulator) position(symbol string) alpaca.Position {
+	pos := s.positions[symbol]
+	current := s.prices[symbol].last
+	side := "long"
+	if pos.qty.IsNegative() {
+		side = "short"
+	}
+	value := pos.qty.Mul(current)
+	cost := pos.qty.Mul(pos.avgEntryPrice)
+	p := alpaca.Position{
+		Symbol:       symbol,
+		Class:        "us_equity",
+		AccountID:    accountID,
+		EntryPrice:   pos.avgEntryPrice,
+		Qty:          pos.qty,
+		QtyAvailable: pos.qty,
+		Side:         side,
+		MarketValue:  value,
+		CostBasis:    cost,
+		UnrealizedPL: value.Sub(cost),
+		CurrentPrice: current,
+	}
+	if !cost.IsZero() {
+		p.UnrealizedPLPC = p.UnrealizedPL.Div(cost.Abs())
+	}
+	return p
+}
+
+// ClosePosition places a market order closing the simulated position in symbol.
+func (s *Simulator) ClosePosition(symbol string) error {
+	s.mu.Lock()
+	pos, ok := s.positions[symbol]
+	var qty decimal.Decimal
+	if ok {
+		qty = pos.qty
+	}
+	s.mu.Unlock()
+	if !ok {
+		return alpaca.ErrPositionNotFound
+	}
+
+	side := alpaca.Sell
+	if qty.IsNegative() {
+		side = alpaca.Buy
+	}
+	_, err := s.PlaceOrder(alpaca.PlaceOrderRequest{
+		AssetKey:    &symbol,
+		Qty:         qty.Abs(),
+		Side:        side,
+		Type:        alpaca.Market,
+		TimeInForce: alpaca.Day,
+	})
+	return err
+}
+
+// CloseAllPositions cancels the open orders and closes every simulated position.
+func (s *Simulator) CloseAllPositions() error {
+	if err := s.CancelAllOrders(); err != nil {
+		return err
+	}
+	positions, err := s.ListPositions()
+	if err != nil {
+		return err
+	}
+	for _, p := range positions {
+		if err := s.ClosePosition(p.Symbol); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package sim
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SimTestSuite struct {
+	suite.Suite
+	sim     *Simulator
+	updates []alpaca.TradeUpdate
+}
+
+func TestSimTestSuite(t *testing.T) {
+	suite.Run(t, new(SimTestSuite))
+}
+
+func (s *SimTestSuite) SetupTest() {
+	s.sim = New(WithCash(decimal.New(10000, 0)))
+	s.updates = nil
+	s.sim.SubscribeTradeUpdates(func(u alpaca.TradeUpdate) {
+		s.updates = append(s.updates, u)
+	})
+}
+
+func dec(p int64) *decimal.Decimal {
+	d := decimal.New(p, 0)
+	return &d
+}
+
+func (s *SimTestSuite) order(side alpaca.Side, typ alpaca.OrderType, qty int64, limit, stop *decimal.Decimal) (*alpaca.Order, error) {
+	symbol := "AAPL"
+	return s.sim.PlaceOrder(alpaca.PlaceOrderRequest{
+		AssetKey:    &symbol,
+		Qty:         decimal.New(qty, 0),
+		Side:        side,
+		Type:        typ,
+		TimeInForce: alpaca.Day,
+		LimitPrice:  limit,
+		StopPrice:   stop,
+	})
+}
+
+func (s *SimTestSuite) events() []alpaca.TradeEvent {
+	events := []alpaca.TradeEvent{}
+	for _, u := range s.updates {
+		events = append(events, u.Event)
+	}
+	return events
+}
+
+func (s *SimTestSuite) TestFills() {
+	s.sim.UpdateQuote(stream.Quote{Symbol: "AAPL", BidPrice: 99, AskPrice: 101})
+
+	// market buys fill right away at the ask
+	order, err := s.order(alpaca.Buy, alpaca.Market, 10, nil, nil)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), alpaca.OrderFilled, order.Status)
+	assert.True(s.T(), order.FilledAvgPrice.Equal(decimal.New(101, 0)))
+	assert.Equal(s.T(), []alpaca.TradeEvent{alpaca.EventNew, alpaca.EventFill}, s.events())
+
+	s.sim.UpdateTrade(stream.Trade{Symbol: "AAPL", Price: 110})
+	pos, err := s.sim.GetPosition("AAPL")
+	require.NoError(s.T(), err)
+	assert.True(s.T(), pos.Qty.Equal(decimal.New(10, 0)))
+	assert.True(s.T(), pos.EntryPrice.Equal(decimal.New(101, 0)))
+	assert.True(s.T(), pos.UnrealizedPL.Equal(decimal.New(90, 0)))
+
+	// limit sells fill once the market reaches the limit
+	order, err = s.order(alpaca.Sell, alpaca.Limit, 5, dec(115), nil)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), alpaca.OrderNew, order.Status)
+	s.sim.UpdateBar(stream.Bar{Symbol: "AAPL", Close: 116})
+	order, err = s.sim.GetOrder(order.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), alpaca.OrderFilled, order.Status)
+
+	// stop sells fill once the market falls to the stop
+	_, err = s.order(alpaca.Sell, alpaca.Stop, 5, nil, dec(105))
+	require.NoError(s.T(), err)
+	s.sim.UpdateTrade(stream.Trade{Symbol: "AAPL", Price: 106})
+	s.sim.UpdateTrade(stream.Trade{Symbol: "AAPL", Price: 104})
+	_, err = s.sim.GetPosition("AAPL")
+	assert.True(s.T(), errors.Is(err, alpaca.ErrPositionNotFound))
+
+	acct, err := s.sim.GetAccount()
+	require.NoError(s.T(), err)
+	assert.True(s.T(), acct.Cash.Equal(decimal.New(10000-1010+580+520, 0)), acct.Cash.String())
+	assert.True(s.T(), acct.Equity.Equal(acct.Cash))
+	positions, err := s.sim.ListPositions()
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), positions)
+}
+
+func (s *SimTestSuite) TestBuyingPower() {
+	_, err := s.order(alpaca.Buy, alpaca.Limit, 60, dec(100), nil)
+	require.NoError(s.T(), err)
+	acct, err := s.sim.GetAccount()
+	require.NoError(s.T(), err)
+	assert.True(s.T(), acct.BuyingPower.Equal(decimal.New(4000, 0)))
+
+	_, err = s.order(alpaca.Buy, alpaca.Limit, 50, dec(100), nil)
+	assert.True(s.T(), errors.Is(err, alpaca.ErrInsufficientBuyingPower))
+	_, err = s.order(alpaca.Buy, alpaca.TrailingStop, 1, nil, nil)
+	assert.Error(s.T(), err)
+
+	// short sales are allowed
+	s.sim.UpdateTrade(stream.Trade{Symbol: "AAPL", Price: 120})
+	_, err = s.order(alpaca.Sell, alpaca.Market, 10, nil, nil)
+	require.NoError(s.T(), err)
+	pos, err := s.sim.GetPosition("AAPL")
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), "short", pos.Side)
+	require.NoError(s.T(), s.sim.CloseAllPositions())
+	positions, err := s.sim.ListPositions()
+	require.NoError(s.T(), err)
+	assert.Empty(s.T(), positions)
+}
+
+func (s *SimTestSuite) TestOrderLifecycle() {
+	s.sim.UpdateTrade(stream.Trade{Symbol: "AAPL", Price: 100})
+
+	// an IOC order that does not fill right away is canceled
+	symbol := "AAPL"
+	ioc, err := s.sim.PlaceOrder(alpaca.PlaceOrderRequest{
+		AssetKey:    &symbol,
+		Qty:         decimal.New(1, 0),
+		Side:        alpaca.Buy,
+		Type:        alpaca.Limit,
+		TimeInForce: alpaca.IOC,
+		LimitPrice:  dec(90),
+	})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), alpaca.OrderCanceled, ioc.Status)
+
+	order, err := s.order(alpaca.Buy, alpaca.Limit, 1, dec(90), nil)
+	require.NoError(s.T(), err)
+	replacement, err := s.sim.ReplaceOrder(order.ID, alpaca.ReplaceOrderRequest{LimitPrice: dec(95)})
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), order.ID, *replacement.Replaces)
+	assert.True(s.T(), replacement.LimitPrice.Equal(decimal.New(95, 0)))
+	old, err := s.sim.GetOrder(order.ID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), alpaca.OrderReplaced, old.Status)
+
+	open, err := s.sim.ListOrders(nil, nil, nil, nil)
+	require.NoError(s.T(), err)
+	require.Len(s.T(), open, 1)
+	assert.Equal(s.T(), replacement.ID, open[0].ID)
+
+	found, err := s.sim.GetOrderByClientOrderID(replacement.ClientOrderID)
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), replacement.ID, found.ID)
+
+	require.NoError(s.T(), s.sim.CancelOrder(replacement.ID))
+	assert.True(s.T(), errors.Is(s.sim.CancelOrder(replacement.ID), alpaca.ErrOrderNotCancelable))
+	assert.Equal(s.T(), ErrOrderNotFound, s.sim.CancelOrder("unknown"))
+
+	all := "all"
+	orders, err := s.sim.ListOrders(&all, nil, nil, nil)
+	require.NoError(s.T(), err)
+	assert.Len(s.T(), orders, 3)
+
+	assert.Equal(s.T(), []alpaca.TradeEvent{
+		alpaca.EventNew, alpaca.EventCanceled,
+		alpaca.EventNew, alpaca.EventNew, alpaca.EventReplaced, alpaca.EventCanceled,
+	}, s.events())
+}
+
+func (s *SimTestSuite) TestStreamTradeUpdateEvents() {
+	s.sim.UpdateTrade(stream.Trade{Symbol: "AAPL", Price: 100})
+	_, err := s.order(alpaca.Buy, alpaca.Market, 1, nil, nil)
+	require.NoError(s.T(), err)
+
+	// past updates are replayed
+	var replayed []alpaca.TradeEvent
+	since, until := time.Time{}, time.Now()
+	err = s.sim.StreamTradeUpdateEvents(context.Background(),
+		alpaca.StreamEventsRequest{Since: &since, Until: &until},
+		func(e alpaca.TradeUpdateEvent) { replayed = append(replayed, e.Event) })
+	require.NoError(s.T(), err)
+	assert.Equal(s.T(), []alpaca.TradeEvent{alpaca.EventNew, alpaca.EventFill}, replayed)
+
+	// new updates are streamed until the context is canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan alpaca.TradeUpdateEvent, 2)
+	done := make(chan error, 1)
+	go func() {
+		done <- s.sim.StreamTradeUpdateEvents(ctx, alpaca.StreamEventsRequest{},
+			func(e alpaca.TradeUpdateEvent) { events <- e })
+	}()
+	time.Sleep(10 * time.Millisecond)
+	_, err = s.order(alpaca.Sell, alpaca.Market, 1, nil, nil)
+	require.NoError(s.T(), err)
+	for _, want := range []alpaca.TradeEvent{alpaca.EventNew, alpaca.EventFill} {
+		select {
+		case e := <-events:
+			assert.Equal(s.T(), want, e.Event)
+		case <-time.After(time.Second):
+			require.Fail(s.T(), "no event", want)
+		}
+	}
+	cancel()
+	assert.Equal(s.T(), context.Canceled, <-done)
+}
+
+func (s *SimTestSuite) TestMarketData() {
+	_, err := s.sim.GetClock()
+	assert.Equal(s.T(), ErrNotSupported, err)
+	item := <-s.sim.GetBars("AAPL", "1Min", "raw", time.Now(), time.Now(), 10)
+	assert.Equal(s.T(), ErrNotSupported, item.Error)
+
+	mock := &alpacatest.MockClient{
+		GetClockFunc: func() (*alpaca.Clock, error) {
+			return &alpaca.Clock{IsOpen: true}, nil
+		},
+	}
+	clock, err := New(WithMarketData(mock)).GetClock()
+	require.NoError(s.T(), err)
+	assert.True(s.T(), clock.IsOpen)
+	assert.Equal(s.T(), []string{"GetClock"}, mock.Calls)
+}
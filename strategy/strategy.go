@@ -0,0 +1,345 @@
+// Package strategy runs a trading strategy in an event loop, wiring the data
+// and trade update streams, the trading client and an order tracker, and
+// delivering the events to the strategy one at a time, e.g.
+//
+//	type momentum struct {
+//		strategy.Base
+//		engine *strategy.Engine
+//	}
+//
+//	func (m *momentum) OnStart(e *strategy.Engine) error {
+//		m.engine = e
+//		return nil
+//	}
+//
+//	func (m *momentum) OnBar(bar stream.Bar) {
+//		// no locking needed, the callbacks are never called concurrently
+//		m.engine.Client().PlaceOrder(...)
+//	}
+//
+//	engine := strategy.New(client, &momentum{}, strategy.WithBars("AAPL"))
+//	err := engine.Run(ctx)
+package strategy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/ordertracker"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// Strategy is called by an Engine. Embed Base to implement only some of
+// the callbacks.
+type Strategy interface {
+	// OnStart is called once the open orders are known and the trade updates
+	// subscribed, before any market data. An error stops the engine.
+	OnStart(e *Engine) error
+	OnBar(bar stream.Bar)
+	OnTrade(trade stream.Trade)
+	OnQuote(quote stream.Quote)
+	// OnOrderUpdate is called with the trade updates of the account, after
+	// they were applied to the order tracker of the engine
+	OnOrderUpdate(update alpaca.TradeUpdate)
+	// OnStop is called once the market data is unsubscribed, while the trade
+	// updates are still tracked, e.g. to cancel the open orders.
+	OnStop()
+}
+
+// Base implements the callbacks of a Strategy with no-ops
+type Base struct{}
+
+// OnStart does nothing
+func (Base) OnStart(e *Engine) error { return nil }
+
+// OnBar does nothing
+func (Base) OnBar(bar stream.Bar) {}
+
+// OnTrade does nothing
+func (Base) OnTrade(trade stream.Trade) {}
+
+// OnQuote does nothing
+func (Base) OnQuote(quote stream.Quote) {}
+
+// OnOrderUpdate does nothing
+func (Base) OnOrderUpdate(update alpaca.TradeUpdate) {}
+
+// OnStop does nothing
+func (Base) OnStop() {}
+
+// Stream provides the market data and the trade updates
+type Stream interface {
+	SubscribeTrades(handler func(trade stream.Trade), symbols ...string) error
+	SubscribeQuotes(handler func(quote stream.Quote), symbols ...string) error
+	SubscribeBars(handler func(bar stream.Bar), symbols ...string) error
+	SubscribeTradeUpdates(handler func(update alpaca.TradeUpdate)) error
+	UnsubscribeTrades(symbols ...string) error
+	UnsubscribeQuotes(symbols ...string) error
+	UnsubscribeBars(symbols ...string) error
+	UnsubscribeTradeUpdates() error
+}
+
+type defaultStream struct{}
+
+func (defaultStream) SubscribeTrades(handler func(trade stream.Trade), symbols ...string) error {
+	return stream.SubscribeTrades(handler, symbols...)
+}
+
+func (defaultStream) SubscribeQuotes(handler func(quote stream.Quote), symbols ...string) error {
+	return stream.SubscribeQuotes(handler, symbols...)
+}
+
+func (defaultStream) SubscribeBars(handler func(bar stream.Bar), symbols ...string) error {
+	return stream.SubscribeBars(handler, symbols...)
+}
+
+func (defaultStream) SubscribeTradeUpdates(handler func(update alpaca.TradeUpdate)) error {
+	return stream.SubscribeTradeUpdates(handler)
+}
+
+func (defaultStream) UnsubscribeTrades(symbols ...string) error {
+	return stream.UnsubscribeTrades(symbols...)
+}
+
+func (defaultStream) UnsubscribeQuotes(symbols ...string) error {
+	return stream.UnsubscribeQuotes(symbols...)
+}
+
+func (defaultStream) UnsubscribeBars(symbols ...string) error {
+	return stream.UnsubscribeBars(symbols...)
+}
+
+func (defaultStream) UnsubscribeTradeUpdates() error {
+	return stream.UnsubscribeTradeUpdates()
+}
+
+// DefaultStream uses the functions of the v2/stream package
+var DefaultStream Stream = defaultStream{}
+
+// Option configures an Engine
+type Option func(e *Engine)
+
+// WithTrades subscribes the strategy to the trades of symbols.
+func WithTrades(symbols ...string) Option {
+	return func(e *Engine) {
+		e.trades = append(e.trades, symbols...)
+	}
+}
+
+// WithQuotes subscribes the strategy to the quotes of symbols.
+func WithQuotes(symbols ...string) Option {
+	return func(e *Engine) {
+		e.quotes = append(e.quotes, symbols...)
+	}
+}
+
+// WithBars subscribes the strategy to the minute bars of symbols.
+func WithBars(symbols ...string) Option {
+	return func(e *Engine) {
+		e.bars = append(e.bars, symbols...)
+	}
+}
+
+// WithStream sets the stream of the engine, DefaultStream by default, e.g.
+// a backtest.
+func WithStream(s Stream) Option {
+	return func(e *Engine) {
+		e.stream = s
+	}
+}
+
+// WithBufferSize sets how many events are queued for the strategy, 10000
+// by default. The stream waits for room in the queue when it is full.
+func WithBufferSize(size int) Option {
+	return func(e *Engine) {
+		e.bufferSize = size
+	}
+}
+
+// WithTrackerOptions sets the options of the order tracker of the engine.
+func WithTrackerOptions(opts ...ordertracker.Option) Option {
+	return func(e *Engine) {
+		e.trackerOpts = append(e.trackerOpts, opts...)
+	}
+}
+
+// event is one of the events of the queue
+type event struct {
+	bar    *stream.Bar
+	trade  *stream.Trade
+	quote  *stream.Quote
+	update *alpaca.TradeUpdate
+}
+
+// Engine runs a strategy
+type Engine struct {
+	client      alpaca.TradingClient
+	strategy    Strategy
+	stream      Stream
+	trades      []string
+	quotes      []string
+	bars        []string
+	bufferSize  int
+	trackerOpts []ordertracker.Option
+	orders      *ordertracker.Tracker
+
+	events chan event
+	// stopped is closed once the engine stops delivering events
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates an engine running s, trading with client.
+func New(client alpaca.TradingClient, s Strategy, opts ...Option) *Engine {
+	e := &Engine{
+		client:     client,
+		strategy:   s,
+		stream:     DefaultStream,
+		bufferSize: 10000,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	e.orders = ordertracker.New(client, e.trackerOpts...)
+	e.events = make(chan event, e.bufferSize)
+	e.stopped = make(chan struct{})
+	return e
+}
+
+// Client returns the trading client of the engine.
+func (e *Engine) Client() alpaca.TradingClient {
+	return e.client
+}
+
+// Orders returns the order tracker of the engine, fed with the trade
+// updates and reconciled periodically while the engine runs.
+func (e *Engine) Orders() *ordertracker.Tracker {
+	return e.orders
+}
+
+// Run starts the strategy and delivers its events until ctx is done. It
+// then stops the strategy and returns the error of ctx, or the first error
+// of the startup.
+//
+// The startup tracks the open orders, subscribes the trade updates, calls
+// OnStart and subscribes the market data, in that order. The shutdown
+// unsubscribes the market data, calls OnStop once the queued events are
+// delivered and unsubscribes the trade updates.
+func (e *Engine) Run(ctx context.Context) error {
+	if err := e.orders.Reconcile(); err != nil {
+		return err
+	}
+	if err := e.stream.SubscribeTradeUpdates(func(update alpaca.TradeUpdate) {
+		e.orders.HandleTradeUpdate(update)
+		e.queue(event{update: &update})
+	}); err != nil {
+		return err
+	}
+	defer e.stream.UnsubscribeTradeUpdates()
+
+	// reconciles until Run returns
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		e.orders.Run(ctx)
+	}()
+	defer wg.Wait()
+	defer cancel()
+
+	if err := e.strategy.OnStart(e); err != nil {
+		return err
+	}
+	if err := e.subscribe(); err != nil {
+		e.unsubscribe()
+		e.stop()
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			e.unsubscribe()
+			e.stop()
+			return ctx.Err()
+		case ev := <-e.events:
+			e.deliver(ev)
+		}
+	}
+}
+
+func (e *Engine) subscribe() error {
+	if len(e.trades) > 0 {
+		if err := e.stream.SubscribeTrades(func(trade stream.Trade) {
+			e.queue(event{trade: &trade})
+		}, e.trades...); err != nil {
+			return err
+		}
+	}
+	if len(e.quotes) > 0 {
+		if err := e.stream.SubscribeQuotes(func(quote stream.Quote) {
+			e.queue(event{quote: &quote})
+		}, e.quotes...); err != nil {
+			return err
+		}
+	}
+	if len(e.bars) > 0 {
+		if err := e.stream.SubscribeBars(func(bar stream.Bar) {
+			e.queue(event{bar: &bar})
+		}, e.bars...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *Engine) unsubscribe() {
+	if len(e.trades) > 0 {
+		e.stream.UnsubscribeTrades(e.trades...)
+	}
+	if len(e.quotes) > 0 {
+		e.stream.UnsubscribeQuotes(e.quotes...)
+	}
+	if len(e.bars) > 0 {
+		e.stream.UnsubscribeBars(e.bars...)
+	}
+}
+
+// queue queues an event for the strategy, unless the engine stopped
+func (e *Engine) queue(ev event) {
+	select {
+	case e.events <- ev:
+	case <-e.stopped:
+	}
+}
+
+// stop delivers the queued events and stops the strategy
+func (e *Engine) stop() {
+	e.stopOnce.Do(func() {
+		close(e.stopped)
+	})
+	for {
+		select {
+		case ev := <-e.events:
+			e.deliver(ev)
+		default:
+			e.strategy.OnStop()
+			return
+		}
+	}
+}
+
+func (e *Engine) deliver(ev event) {
+	switch {
+	case ev.bar != nil:
+		e.strategy.OnBar(*ev.bar)
+	case ev.trade != nil:
+		e.strategy.OnTrade(*ev.trade)
+	case ev.quote != nil:
+		e.strategy.OnQuote(*ev.quote)
+	case ev.update != nil:
+		e.strategy.OnOrderUpdate(*ev.update)
+	}
+}
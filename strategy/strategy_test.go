@@ -0,0 +1,238 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// log records the calls of the stream and the strategy, in order
+type log struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *log) add(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, fmt.Sprintf(format, args...))
+}
+
+func (l *log) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.calls...)
+}
+
+type fakeStream struct {
+	log     *log
+	mu      sync.Mutex
+	bars    func(bar stream.Bar)
+	trades  func(trade stream.Trade)
+	quotes  func(quote stream.Quote)
+	updates func(update alpaca.TradeUpdate)
+	err     error
+}
+
+func (s *fakeStream) SubscribeTrades(handler func(trade stream.Trade), symbols ...string) error {
+	s.log.add("subscribe trades %v", symbols)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trades = handler
+	return nil
+}
+
+func (s *fakeStream) SubscribeQuotes(handler func(quote stream.Quote), symbols ...string) error {
+	s.log.add("subscribe quotes %v", symbols)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quotes = handler
+	return nil
+}
+
+func (s *fakeStream) SubscribeBars(handler func(bar stream.Bar), symbols ...string) error {
+	s.log.add("subscribe bars %v", symbols)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bars = handler
+	return s.err
+}
+
+func (s *fakeStream) SubscribeTradeUpdates(handler func(update alpaca.TradeUpdate)) error {
+	s.log.add("subscribe trade updates")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updates = handler
+	return nil
+}
+
+func (s *fakeStream) UnsubscribeTrades(symbols ...string) error {
+	s.log.add("unsubscribe trades %v", symbols)
+	return nil
+}
+
+func (s *fakeStream) UnsubscribeQuotes(symbols ...string) error {
+	s.log.add("unsubscribe quotes %v", symbols)
+	return nil
+}
+
+func (s *fakeStream) UnsubscribeBars(symbols ...string) error {
+	s.log.add("unsubscribe bars %v", symbols)
+	return nil
+}
+
+func (s *fakeStream) UnsubscribeTradeUpdates() error {
+	s.log.add("unsubscribe trade updates")
+	return nil
+}
+
+type recording struct {
+	Base
+	log      *log
+	engine   *Engine
+	startErr error
+	// running counts the callbacks in progress, to check they never overlap
+	running int
+	overlap bool
+}
+
+func (r *recording) enter() {
+	r.running++
+	if r.running > 1 {
+		r.overlap = true
+	}
+}
+
+func (r *recording) OnStart(e *Engine) error {
+	r.engine = e
+	_, tracked := e.Orders().Order("open_order")
+	r.log.add("start, tracking open order: %v", tracked)
+	return r.startErr
+}
+
+func (r *recording) OnBar(bar stream.Bar) {
+	r.enter()
+	defer func() { r.running-- }()
+	time.Sleep(time.Millisecond)
+	r.log.add("bar %s", bar.Symbol)
+}
+
+func (r *recording) OnTrade(trade stream.Trade) {
+	r.enter()
+	defer func() { r.running-- }()
+	r.log.add("trade %s", trade.Symbol)
+}
+
+func (r *recording) OnOrderUpdate(update alpaca.TradeUpdate) {
+	order, _ := r.engine.Orders().Order(update.Order.ID)
+	r.log.add("update %s %s", update.Event, order.Status)
+}
+
+func (r *recording) OnStop() {
+	r.log.add("stop")
+}
+
+type EngineTestSuite struct {
+	suite.Suite
+	log      *log
+	stream   *fakeStream
+	strategy *recording
+	mock     *alpacatest.MockClient
+}
+
+func TestEngineTestSuite(t *testing.T) {
+	suite.Run(t, new(EngineTestSuite))
+}
+
+func (s *EngineTestSuite) SetupTest() {
+	s.log = &log{}
+	s.stream = &fakeStream{log: s.log}
+	s.strategy = &recording{log: s.log}
+	s.mock = &alpacatest.MockClient{
+		ListOrdersFunc: func(status *string, until *time.Time, limit *int, nested *bool) ([]alpaca.Order, error) {
+			return []alpaca.Order{{ID: "open_order", Status: alpaca.OrderNew}}, nil
+		},
+	}
+}
+
+func (s *EngineTestSuite) started() bool {
+	s.stream.mu.Lock()
+	defer s.stream.mu.Unlock()
+	return s.stream.bars != nil
+}
+
+func (s *EngineTestSuite) TestRun() {
+	engine := New(s.mock, s.strategy, WithStream(s.stream), WithBars("AAPL", "MSFT"), WithTrades("AAPL"))
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() { errs <- engine.Run(ctx) }()
+	require.Eventually(s.T(), s.started, time.Second, time.Millisecond)
+
+	// the handlers of the stream run concurrently
+	var wg sync.WaitGroup
+	for _, symbol := range []string{"AAPL", "MSFT"} {
+		symbol := symbol
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			s.stream.bars(stream.Bar{Symbol: symbol})
+		}()
+		go func() {
+			defer wg.Done()
+			s.stream.trades(stream.Trade{Symbol: symbol})
+		}()
+	}
+	wg.Wait()
+	s.stream.updates(alpaca.TradeUpdate{Event: alpaca.EventFill,
+		Order: alpaca.Order{ID: "open_order", Status: alpaca.OrderFilled, UpdatedAt: time.Now()}})
+
+	cancel()
+	assert.Equal(s.T(), context.Canceled, <-errs)
+	assert.False(s.T(), s.strategy.overlap)
+
+	calls := s.log.get()
+	assert.Equal(s.T(), []string{
+		"subscribe trade updates",
+		"start, tracking open order: true",
+		"subscribe trades [AAPL]",
+		"subscribe bars [AAPL MSFT]",
+	}, calls[:4])
+	// the events are all delivered before the strategy stops
+	require.Len(s.T(), calls, 4+5+2+2)
+	assert.ElementsMatch(s.T(), []string{
+		"bar AAPL", "bar MSFT", "trade AAPL", "trade MSFT", "update fill filled",
+		"unsubscribe trades [AAPL]", "unsubscribe bars [AAPL MSFT]",
+	}, calls[4:11])
+	assert.Equal(s.T(), []string{"stop", "unsubscribe trade updates"}, calls[11:])
+}
+
+func (s *EngineTestSuite) TestStartErrors() {
+	s.strategy.startErr = errors.New("no buying power")
+	err := New(s.mock, s.strategy, WithStream(s.stream), WithBars("AAPL")).Run(context.Background())
+	assert.EqualError(s.T(), err, "no buying power")
+	assert.Equal(s.T(), []string{
+		"subscribe trade updates",
+		"start, tracking open order: true",
+		"unsubscribe trade updates",
+	}, s.log.get())
+
+	s.SetupTest()
+	s.stream.err = errors.New("connection refused")
+	err = New(s.mock, s.strategy, WithStream(s.stream), WithBars("AAPL")).Run(context.Background())
+	assert.EqualError(s.T(), err, "connection refused")
+	assert.Equal(s.T(), []string{"unsubscribe bars [AAPL]", "stop", "unsubscribe trade updates"}, s.log.get()[3:])
+
+	s.mock.ListOrdersFunc = nil
+	err = New(s.mock, s.strategy, WithStream(s.stream)).Run(context.Background())
+	assert.Error(s.T(), err)
+}
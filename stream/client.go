@@ -0,0 +1,610 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	"github.com/shopspring/decimal"
+	"nhooyr.io/websocket"
+)
+
+const (
+	defaultTradingURL = "https://api.alpaca.markets"
+	defaultDataURL    = "https://data.alpaca.markets"
+
+	defaultReconnectLimit = 20
+	defaultReconnectDelay = 150 * time.Millisecond
+)
+
+var (
+	// ErrConnectCalledMultipleTimes is returned when Connect is called
+	// more than once on the same Client.
+	ErrConnectCalledMultipleTimes = errors.New("stream: connect called multiple times")
+	// ErrNotConnected is returned by Close when the client was never connected.
+	ErrNotConnected = errors.New("stream: not connected")
+)
+
+// AccountUpdate is an update of the account sent on the account_updates channel.
+type AccountUpdate struct {
+	ID               string          `json:"id"`
+	CreatedAt        time.Time       `json:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at"`
+	DeletedAt        *time.Time      `json:"deleted_at"`
+	Status           string          `json:"status"`
+	Currency         string          `json:"currency"`
+	Cash             decimal.Decimal `json:"cash"`
+	CashWithdrawable decimal.Decimal `json:"cash_withdrawable"`
+}
+
+// Option configures a Client.
+type Option func(c *Client)
+
+// WithBaseURL overrides the endpoint the client connects to.
+func WithBaseURL(u string) Option {
+	return func(c *Client) {
+		c.base = u
+	}
+}
+
+// WithCredentials sets the API key used to authenticate the connection,
+// instead of common.Credentials().
+func WithCredentials(credentials *common.APIKey) Option {
+	return func(c *Client) {
+		c.credentials = credentials
+	}
+}
+
+// WithCredentialsProvider makes the client get its credentials from provider
+// every time it connects, so rotated keys are used after a reconnect.
+func WithCredentialsProvider(provider common.CredentialsProvider) Option {
+	return func(c *Client) {
+		c.credentialsProvider = provider
+	}
+}
+
+// Tracer observes the operations of a Client, e.g. to trace them. See the
+// otelalpaca module for an OpenTelemetry implementation.
+type Tracer interface {
+	// Start is called when an operation ("connect", "listen" or "unlisten")
+	// starts, with the channels it is about, and returns the context of the
+	// operation and the function called with its result once it is done.
+	Start(ctx context.Context, operation string, channels []string) (context.Context, func(err error))
+}
+
+// WithTracer makes the client report its connections and subscriptions to tracer
+func WithTracer(tracer Tracer) Option {
+	return func(c *Client) {
+		c.tracer = tracer
+	}
+}
+
+// WithLogger sets the logger of the client, common.DefaultLogger by
+// default, e.g. a *slog.Logger.
+func WithLogger(logger common.Logger) Option {
+	return func(c *Client) {
+		c.lg = logger
+	}
+}
+
+// WithUserAgent adds application, e.g. "my-bot/2.1", to the User-Agent
+// header of the websocket handshake, in front of the SDK and its version.
+func WithUserAgent(application string) Option {
+	return func(c *Client) {
+		c.application = application
+	}
+}
+
+// WithReconnectSettings sets how many times in a row the client tries to
+// reconnect after the connection is lost, and the delay between attempts.
+// The delay grows linearly with the number of failed attempts.
+// A limit of 0 disables reconnection.
+func WithReconnectSettings(limit int, delay time.Duration) Option {
+	return func(c *Client) {
+		c.reconnectLimit = limit
+		c.reconnectDelay = delay
+	}
+}
+
+// Client is a websocket connection to either the trading stream
+// (trade_updates, account_updates) or the legacy data stream (Q., T., AM.).
+// Register handlers, call Connect, then watch Terminated.
+type Client struct {
+	base                string
+	credentials         *common.APIKey
+	credentialsProvider common.CredentialsProvider
+	tracer              Tracer
+	lg                  common.Logger
+	application         string
+	reconnectLimit      int
+	reconnectDelay      time.Duration
+
+	handlersMutex sync.RWMutex
+	handlers      map[string]func(data json.RawMessage)
+
+	connMutex     sync.Mutex
+	conn          *websocket.Conn
+	connectCalled bool
+	cancel        context.CancelFunc
+	terminated    chan error
+}
+
+// NewTradingClient returns a client for the trading stream. The endpoint
+// defaults to the APCA_API_BASE_URL environment variable or the Alpaca
+// trading URL.
+func NewTradingClient(opts ...Option) *Client {
+	base := defaultTradingURL
+	if s := os.Getenv("APCA_API_BASE_URL"); s != "" {
+		base = s
+	}
+	return newClient(base, opts)
+}
+
+// NewDataClient returns a client for the legacy data stream. The endpoint
+// defaults to the DATA_PROXY_WS or APCA_API_DATA_URL environment variable
+// or the Alpaca data URL.
+func NewDataClient(opts ...Option) *Client {
+	base := defaultDataURL
+	if s := os.Getenv("APCA_API_DATA_URL"); s != "" {
+		base = s
+	}
+	if s := os.Getenv("DATA_PROXY_WS"); s != "" {
+		base = s
+	}
+	return newClient(base, opts)
+}
+
+func newClient(base string, opts []Option) *Client {
+	c := &Client{
+		base:           base,
+		reconnectLimit: defaultReconnectLimit,
+		reconnectDelay: defaultReconnectDelay,
+		handlers:       map[string]func(json.RawMessage){},
+		terminated:     make(chan error, 1),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// logger returns the logger of the client
+func (c *Client) logger() common.Logger {
+	if c.lg != nil {
+		return c.lg
+	}
+	return common.DefaultLogger
+}
+
+// RegisterTradeUpdates sets the handler of the trade_updates channel.
+func (c *Client) RegisterTradeUpdates(handler func(update alpaca.TradeUpdate)) error {
+	return c.register(alpaca.TradeUpdates, func(data json.RawMessage) {
+		var update alpaca.TradeUpdate
+		if err := json.Unmarshal(data, &update); err != nil {
+			c.logger().Warn("failed to decode trade update", "client", "stream", "url", c.base, "error", err)
+			return
+		}
+		handler(update)
+	})
+}
+
+// RegisterAccountUpdates sets the handler of the account_updates channel.
+func (c *Client) RegisterAccountUpdates(handler func(update AccountUpdate)) error {
+	return c.register(alpaca.AccountUpdates, func(data json.RawMessage) {
+		var update AccountUpdate
+		if err := json.Unmarshal(data, &update); err != nil {
+			c.logger().Warn("failed to decode account update", "client", "stream", "url", c.base, "error", err)
+			return
+		}
+		handler(update)
+	})
+}
+
+// RegisterQuotes sets the quote handler of symbol. Use "*" for all symbols.
+func (c *Client) RegisterQuotes(symbol string, handler func(quote alpaca.StreamQuote)) error {
+	return c.register("Q."+symbol, func(data json.RawMessage) {
+		var quote alpaca.StreamQuote
+		if err := json.Unmarshal(data, &quote); err != nil {
+			c.logger().Warn("failed to decode quote", "client", "stream", "url", c.base, "error", err)
+			return
+		}
+		handler(quote)
+	})
+}
+
+// RegisterTrades sets the trade handler of symbol. Use "*" for all symbols.
+func (c *Client) RegisterTrades(symbol string, handler func(trade alpaca.StreamTrade)) error {
+	return c.register("T."+symbol, func(data json.RawMessage) {
+		var trade alpaca.StreamTrade
+		if err := json.Unmarshal(data, &trade); err != nil {
+			c.logger().Warn("failed to decode trade", "client", "stream", "url", c.base, "error", err)
+			return
+		}
+		handler(trade)
+	})
+}
+
+// RegisterAggregates sets the minute aggregate handler of symbol.
+// Use "*" for all symbols.
+func (c *Client) RegisterAggregates(symbol string, handler func(agg alpaca.StreamAgg)) error {
+	return c.register("AM."+symbol, func(data json.RawMessage) {
+		var agg alpaca.StreamAgg
+		if err := json.Unmarshal(data, &agg); err != nil {
+			c.logger().Warn("failed to decode aggregate", "client", "stream", "url", c.base, "error", err)
+			return
+		}
+		handler(agg)
+	})
+}
+
+// Subscriptions returns the registered channels, sorted.
+func (c *Client) Subscriptions() []string {
+	c.handlersMutex.RLock()
+	defer c.handlersMutex.RUnlock()
+
+	channels := make([]string, 0, len(c.handlers))
+	for channel := range c.handlers {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// Deregister removes the handler of channel and stops listening to it.
+func (c *Client) Deregister(channel string) error {
+	c.handlersMutex.Lock()
+	_, ok := c.handlers[channel]
+	delete(c.handlers, channel)
+	c.handlersMutex.Unlock()
+	if !ok {
+		return fmt.Errorf("not subscribed to %s", channel)
+	}
+
+	return c.send("unlisten", []string{channel})
+}
+
+func (c *Client) register(channel string, handler func(data json.RawMessage)) error {
+	if err := validateChannel(channel); err != nil {
+		return err
+	}
+
+	c.handlersMutex.Lock()
+	c.handlers[channel] = handler
+	c.handlersMutex.Unlock()
+
+	return c.send("listen", []string{channel})
+}
+
+func validateChannel(channel string) error {
+	switch {
+	case channel == alpaca.TradeUpdates,
+		channel == alpaca.AccountUpdates,
+		strings.HasPrefix(channel, "Q.") && len(channel) > 2,
+		strings.HasPrefix(channel, "T.") && len(channel) > 2,
+		strings.HasPrefix(channel, "AM.") && len(channel) > 3:
+		return nil
+	default:
+		return fmt.Errorf("invalid stream (%s)", channel)
+	}
+}
+
+// Connect opens the connection, authenticates and starts listening to the
+// registered channels. It returns once the connection is established; the
+// client then keeps reading (and reconnecting when needed) until ctx is
+// cancelled, Close is called or reconnection fails. Terminated reports why
+// it stopped.
+func (c *Client) Connect(ctx context.Context) error {
+	c.connMutex.Lock()
+	if c.connectCalled {
+		c.connMutex.Unlock()
+		return ErrConnectCalledMultipleTimes
+	}
+	c.connectCalled = true
+	ctx, cancel := context.WithCancel(ctx)
+	c.cancel = cancel
+	c.connMutex.Unlock()
+
+	if err := c.connect(ctx); err != nil {
+		cancel()
+		c.terminated <- err
+		close(c.terminated)
+		return err
+	}
+
+	go c.run(ctx)
+	return nil
+}
+
+// Terminated returns a channel that receives the reason the client stopped
+// and is then closed. The error is nil when the client was stopped by Close
+// or by cancelling the context passed to Connect.
+func (c *Client) Terminated() <-chan error {
+	return c.terminated
+}
+
+// Close stops the client and closes the connection.
+func (c *Client) Close() error {
+	c.connMutex.Lock()
+	cancel := c.cancel
+	c.connMutex.Unlock()
+	if cancel == nil {
+		return ErrNotConnected
+	}
+
+	cancel()
+	return nil
+}
+
+func (c *Client) run(ctx context.Context) {
+	var err error
+	defer func() {
+		c.closeConn()
+		c.terminated <- err
+		close(c.terminated)
+	}()
+
+	for {
+		c.connMutex.Lock()
+		conn := c.conn
+		c.connMutex.Unlock()
+
+		_, b, readErr := conn.Read(ctx)
+		if readErr == nil {
+			c.handleMessage(b)
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		c.logger().Warn("stream read error, reconnecting",
+			"client", "stream", "url", c.base, "channels", len(c.Subscriptions()), "error", readErr)
+
+		if err = c.reconnect(ctx); err != nil {
+			if ctx.Err() != nil {
+				err = nil
+			}
+			return
+		}
+	}
+}
+
+func (c *Client) reconnect(ctx context.Context) error {
+	c.closeConn()
+
+	var err error
+	for attempt := 1; attempt <= c.reconnectLimit; attempt++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * c.reconnectDelay):
+		}
+
+		if err = c.connect(ctx); err == nil {
+			return nil
+		}
+		c.logger().Warn("reconnect failed",
+			"client", "stream", "url", c.base, "attempt", attempt, "limit", c.reconnectLimit, "error", err)
+	}
+	if err == nil {
+		err = errors.New("reconnection disabled")
+	}
+	return fmt.Errorf("alpaca stream: connection lost: %w", err)
+}
+
+func (c *Client) connect(ctx context.Context) (err error) {
+	if c.tracer != nil {
+		var end func(err error)
+		ctx, end = c.tracer.Start(ctx, "connect", c.Subscriptions())
+		defer func() { end(err) }()
+	}
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.auth(ctx, conn); err != nil {
+		conn.Close(websocket.StatusNormalClosure, "")
+		return err
+	}
+
+	c.connMutex.Lock()
+	c.conn = conn
+	c.connMutex.Unlock()
+
+	c.handlersMutex.RLock()
+	channels := make([]string, 0, len(c.handlers))
+	for channel := range c.handlers {
+		channels = append(channels, channel)
+	}
+	c.handlersMutex.RUnlock()
+	if len(channels) == 0 {
+		return nil
+	}
+	return c.send("listen", channels)
+}
+
+func (c *Client) closeConn() {
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+	if c.conn != nil {
+		c.conn.Close(websocket.StatusNormalClosure, "")
+		c.conn = nil
+	}
+}
+
+func (c *Client) dial(ctx context.Context) (*websocket.Conn, error) {
+	ub, err := url.Parse(c.base)
+	if err != nil {
+		return nil, err
+	}
+	scheme := "wss"
+	switch ub.Scheme {
+	case "http", "ws":
+		scheme = "ws"
+	}
+	u := url.URL{Scheme: scheme, Host: ub.Host, Path: "/stream"}
+
+	conn, _, err := websocket.Dial(ctx, u.String(), &websocket.DialOptions{
+		HTTPHeader: http.Header{"User-Agent": []string{common.UserAgent(c.application)}},
+	})
+	return conn, err
+}
+
+func (c *Client) auth(ctx context.Context, conn *websocket.Conn) error {
+	credentials := c.credentials
+	if c.credentialsProvider != nil {
+		key, err := c.credentialsProvider.Get(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get credentials: %w", err)
+		}
+		credentials = &key
+	}
+	if credentials == nil {
+		credentials = common.Credentials()
+	}
+	data := map[string]interface{}{
+		"key_id":     credentials.ID,
+		"secret_key": credentials.Secret,
+	}
+	if credentials.OAuth != "" {
+		data = map[string]interface{}{"oauth_token": credentials.OAuth}
+	}
+	msg, err := json.Marshal(alpaca.ClientMsg{
+		Action: "authenticate",
+		Data:   data,
+	})
+	if err != nil {
+		return err
+	}
+	if err := conn.Write(ctx, websocket.MessageText, msg); err != nil {
+		return err
+	}
+
+	// ensure the auth response comes in a timely manner
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, b, err := conn.Read(ctx)
+	if err != nil {
+		return err
+	}
+	var resp serverMsg
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return err
+	}
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(resp.Data, &status); err != nil {
+		return err
+	}
+	if resp.Stream != "authorization" || !strings.EqualFold(status.Status, "authorized") {
+		return errors.New("failed to authorize alpaca stream")
+	}
+	return nil
+}
+
+func (c *Client) send(action string, channels []string) (err error) {
+	c.connMutex.Lock()
+	defer c.connMutex.Unlock()
+	if c.conn == nil {
+		// channels are listened to once connected
+		return nil
+	}
+
+	ctx := context.TODO()
+	if c.tracer != nil {
+		var end func(err error)
+		ctx, end = c.tracer.Start(ctx, action, channels)
+		defer func() { end(err) }()
+	}
+
+	msg, err := json.Marshal(alpaca.ClientMsg{
+		Action: action,
+		Data: map[string]interface{}{
+			"streams": channels,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return c.conn.Write(ctx, websocket.MessageText, msg)
+}
+
+// serverMsg is the envelope of every message on the stream
+type serverMsg struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+}
+
+func (c *Client) handleMessage(b []byte) {
+	var msg serverMsg
+	if err := json.Unmarshal(b, &msg); err != nil {
+		c.logger().Warn("failed to decode message", "client", "stream", "url", c.base, "error", err)
+		return
+	}
+	if handler := c.findHandler(msg.Stream); handler != nil {
+		handler(msg.Data)
+	}
+}
+
+func (c *Client) findHandler(stream string) func(json.RawMessage) {
+	c.handlersMutex.RLock()
+	defer c.handlersMutex.RUnlock()
+
+	if handler, ok := c.handlers[stream]; ok {
+		return handler
+	}
+	if i := strings.Index(stream, "."); i > 0 {
+		return c.handlers[stream[:i]+".*"]
+	}
+	return nil
+}
+
+// Subscribe implements Stream for the compatibility layer: handler gets the
+// same values the legacy alpaca stream delivered. The client connects on the
+// first subscription.
+func (c *Client) Subscribe(channel string, handler func(msg interface{})) error {
+	var err error
+	switch {
+	case channel == alpaca.TradeUpdates:
+		err = c.RegisterTradeUpdates(func(u alpaca.TradeUpdate) { handler(u) })
+	case strings.HasPrefix(channel, "Q."):
+		err = c.RegisterQuotes(channel[2:], func(q alpaca.StreamQuote) { handler(q) })
+	case strings.HasPrefix(channel, "T."):
+		err = c.RegisterTrades(channel[2:], func(t alpaca.StreamTrade) { handler(t) })
+	case strings.HasPrefix(channel, "AM."):
+		err = c.RegisterAggregates(channel[3:], func(a alpaca.StreamAgg) { handler(a) })
+	default:
+		err = c.register(channel, func(data json.RawMessage) {
+			var msg interface{}
+			if err := json.Unmarshal(data, &msg); err == nil {
+				handler(msg)
+			}
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	err = c.Connect(context.Background())
+	if errors.Is(err, ErrConnectCalledMultipleTimes) {
+		return nil
+	}
+	return err
+}
+
+// Unsubscribe implements Stream for the compatibility layer.
+func (c *Client) Unsubscribe(channel string) error {
+	return c.Deregister(channel)
+}
@@ -17,6 +17,9 @@ var (
 	dataStreamName string = "alpaca"
 )
 
+// SetDataStream selects the data stream, "alpaca" or "polygon". The Polygon
+// channels (T., Q. and AM.) are served by Alpaca Data v2 and deliver the
+// polygon entities, see polygon.GetMigrationStream.
 func SetDataStream(streamName string) {
 	switch streamName {
 	case "alpaca":
@@ -34,12 +37,13 @@ func Register(stream string, handler func(msg interface{})) (err error) {
 
 			var dataStream Stream
 			if dataStreamName == "alpaca" {
-				dataStream = alpaca.GetDataStream()
+				dataStream = NewDataClient()
 			} else if dataStreamName == "polygon" {
-				dataStream = polygon.GetStream()
+				// the Polygon channels are served by Alpaca Data v2
+				dataStream = polygon.GetMigrationStream()
 			}
 			u = &Unified{
-				alpaca: alpaca.GetStream(),
+				alpaca: NewTradingClient(),
 				data:   dataStream,
 			}
 		}
@@ -58,6 +62,16 @@ func Register(stream string, handler func(msg interface{})) (err error) {
 	return
 }
 
+// RegisterTradeUpdates registers a typed handler for the trade_updates
+// channel, without the type assertion Register requires.
+func RegisterTradeUpdates(handler func(update alpaca.TradeUpdate)) error {
+	return Register(alpaca.TradeUpdates, func(msg interface{}) {
+		if update, ok := msg.(alpaca.TradeUpdate); ok {
+			handler(update)
+		}
+	})
+}
+
 // Deregister a handler for a given stream, Alpaca or Polygon.
 func Deregister(stream string) (err error) {
 	once.Do(func() {
@@ -100,7 +114,7 @@ type Unified struct {
 }
 
 // Stream is the generic streaming interface implemented by
-// both alpaca and polygon.
+// Client and polygon.
 type Stream interface {
 	Subscribe(key string, handler func(msg interface{})) error
 	Unsubscribe(key string) error
@@ -1,12 +1,20 @@
 package stream
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	"nhooyr.io/websocket"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -47,6 +55,244 @@ func (s *StreamTestSuite) TestStream() {
 	assert.NotNil(s.T(), Deregister(alpaca.TradeUpdates))
 }
 
+// legacyServer accepts connections speaking the legacy stream protocol,
+// reports every listen request and sends msgs after authentication. When
+// drop is set, the first connection is closed right after the messages.
+func legacyServer(t *testing.T, listened chan<- []string, drop bool, msgs ...string) *httptest.Server {
+	connections := 0
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close(websocket.StatusNormalClosure, "")
+		connections++
+		first := connections == 1
+
+		ctx := context.Background()
+		_, b, err := conn.Read(ctx)
+		require.NoError(t, err)
+		var auth alpaca.ClientMsg
+		require.NoError(t, json.Unmarshal(b, &auth))
+		assert.Equal(t, "authenticate", auth.Action)
+		assert.Equal(t, "key", auth.Data.(map[string]interface{})["key_id"])
+		require.NoError(t, conn.Write(ctx, websocket.MessageText,
+			[]byte(`{"stream":"authorization","data":{"action":"authenticate","status":"authorized"}}`)))
+
+		_, b, err = conn.Read(ctx)
+		if err != nil {
+			return
+		}
+		var listen struct {
+			Data struct {
+				Streams []string `json:"streams"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(b, &listen))
+		listened <- listen.Data.Streams
+
+		for _, msg := range msgs {
+			require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte(msg)))
+		}
+		if drop && first {
+			return
+		}
+		for {
+			if _, _, err := conn.Read(ctx); err != nil {
+				return
+			}
+		}
+	}))
+}
+
+func (s *StreamTestSuite) TestClient() {
+	listened := make(chan []string, 2)
+	server := legacyServer(s.T(), listened, true,
+		`{"stream":"trade_updates","data":{"event":"fill","order":{"id":"order1"}}}`,
+		`{"stream":"T.AAPL","data":{"ev":"T","T":"AAPL","p":120.5}}`,
+	)
+	defer server.Close()
+
+	c := NewTradingClient(
+		WithBaseURL(server.URL),
+		WithCredentials(&common.APIKey{ID: "key", Secret: "secret"}),
+		WithReconnectSettings(3, 10*time.Millisecond),
+	)
+	updates := make(chan alpaca.TradeUpdate, 2)
+	trades := make(chan alpaca.StreamTrade, 2)
+	require.NoError(s.T(), c.RegisterTradeUpdates(func(u alpaca.TradeUpdate) { updates <- u }))
+	require.NoError(s.T(), c.RegisterTrades("*", func(t alpaca.StreamTrade) { trades <- t }))
+	assert.Error(s.T(), c.RegisterQuotes("", func(alpaca.StreamQuote) {}))
+
+	require.NoError(s.T(), c.Connect(context.Background()))
+	assert.Equal(s.T(), []string{"T.*", alpaca.TradeUpdates}, c.Subscriptions())
+	assert.Equal(s.T(), ErrConnectCalledMultipleTimes, c.Connect(context.Background()))
+
+	// the client reconnects after the first connection drops and
+	// listens to the registered channels again
+	for i := 0; i < 2; i++ {
+		select {
+		case streams := <-listened:
+			assert.ElementsMatch(s.T(), []string{alpaca.TradeUpdates, "T.*"}, streams)
+		case <-time.After(time.Second):
+			require.Fail(s.T(), "no listen message")
+		}
+		select {
+		case u := <-updates:
+			assert.Equal(s.T(), alpaca.EventFill, u.Event)
+			assert.Equal(s.T(), "order1", u.Order.ID)
+		case <-time.After(time.Second):
+			require.Fail(s.T(), "no trade update")
+		}
+		select {
+		case t := <-trades:
+			assert.Equal(s.T(), "AAPL", t.Symbol)
+			assert.Equal(s.T(), float32(120.5), t.Price)
+		case <-time.After(time.Second):
+			require.Fail(s.T(), "no trade")
+		}
+	}
+
+	require.NoError(s.T(), c.Close())
+	select {
+	case err := <-c.Terminated():
+		assert.NoError(s.T(), err)
+	case <-time.After(time.Second):
+		require.Fail(s.T(), "client not terminated")
+	}
+}
+
+func (s *StreamTestSuite) TestClientReconnectFailure() {
+	listened := make(chan []string, 1)
+	server := legacyServer(s.T(), listened, true)
+
+	c := NewDataClient(
+		WithBaseURL(server.URL),
+		WithCredentials(&common.APIKey{ID: "key", Secret: "secret"}),
+		WithReconnectSettings(2, 100*time.Millisecond),
+	)
+	require.NoError(s.T(), c.RegisterQuotes("AAPL", func(alpaca.StreamQuote) {}))
+	require.NoError(s.T(), c.Connect(context.Background()))
+	<-listened
+	server.Close()
+
+	select {
+	case err := <-c.Terminated():
+		assert.Error(s.T(), err)
+	case <-time.After(2 * time.Second):
+		require.Fail(s.T(), "client not terminated")
+	}
+}
+
+type spanRecord struct {
+	operation string
+	channels  []string
+	err       error
+}
+
+// fakeTracer records the operations reported to a Tracer.
+type fakeTracer struct {
+	spans chan spanRecord
+}
+
+func (t *fakeTracer) Start(ctx context.Context, operation string, channels []string) (context.Context, func(err error)) {
+	return ctx, func(err error) {
+		t.spans <- spanRecord{operation: operation, channels: channels, err: err}
+	}
+}
+
+func (s *StreamTestSuite) TestClientTracer() {
+	listened := make(chan []string, 1)
+	server := legacyServer(s.T(), listened, false)
+	defer server.Close()
+
+	tracer := &fakeTracer{spans: make(chan spanRecord, 4)}
+	c := NewTradingClient(
+		WithBaseURL(server.URL),
+		WithCredentials(&common.APIKey{ID: "key", Secret: "secret"}),
+		WithTracer(tracer),
+	)
+	require.NoError(s.T(), c.RegisterTradeUpdates(func(alpaca.TradeUpdate) {}))
+	require.NoError(s.T(), c.Connect(context.Background()))
+	defer c.Close()
+
+	// listening happens within the connect span, which ends last
+	for _, want := range []string{"listen", "connect"} {
+		select {
+		case span := <-tracer.spans:
+			assert.Equal(s.T(), want, span.operation)
+			assert.Equal(s.T(), []string{alpaca.TradeUpdates}, span.channels)
+			assert.NoError(s.T(), span.err)
+		case <-time.After(time.Second):
+			require.Fail(s.T(), "no span", want)
+		}
+	}
+}
+
+// fakeStreamer stops with err right after connecting, or runs until
+// its context is cancelled when err is nil.
+type fakeStreamer struct {
+	err        error
+	terminated chan error
+}
+
+func (f *fakeStreamer) Connect(ctx context.Context) error {
+	f.terminated = make(chan error, 1)
+	if f.err != nil {
+		f.terminated <- f.err
+		return nil
+	}
+	go func() {
+		<-ctx.Done()
+		f.terminated <- nil
+	}()
+	return nil
+}
+
+func (f *fakeStreamer) Close() error             { return nil }
+func (f *fakeStreamer) Terminated() <-chan error { return f.terminated }
+func (f *fakeStreamer) Subscriptions() []string  { return []string{alpaca.TradeUpdates} }
+
+func (s *StreamTestSuite) TestSupervisor() {
+	var restarts []string
+	policy := RestartPolicy{
+		MaxRestarts: 2,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  2 * time.Millisecond,
+		OnRestart: func(name string, err error) {
+			restarts = append(restarts, name)
+		},
+	}
+
+	// a failing streamer runs out of restarts and stops the others
+	sup := NewSupervisor(policy)
+	healthyStarted := make(chan struct{}, 1)
+	sup.Add("failing", func() Streamer { return &fakeStreamer{err: fmt.Errorf("connection lost")} })
+	sup.Add("healthy", func() Streamer {
+		healthyStarted <- struct{}{}
+		return &fakeStreamer{}
+	})
+	err := sup.Run(context.Background())
+	require.Error(s.T(), err)
+	assert.Contains(s.T(), err.Error(), "stream failing: connection lost")
+	assert.Equal(s.T(), []string{"failing", "failing"}, restarts)
+	assert.Len(s.T(), healthyStarted, 1)
+
+	// streamers that keep running stop with the context
+	sup = NewSupervisor(policy)
+	sup.Add("healthy", func() Streamer { return &fakeStreamer{} })
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- sup.Run(ctx) }()
+	require.Eventually(s.T(), func() bool { return sup.Streamer("healthy") != nil }, time.Second, time.Millisecond)
+	assert.Equal(s.T(), []string{alpaca.TradeUpdates}, sup.Streamer("healthy").Subscriptions())
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(s.T(), err)
+	case <-time.After(time.Second):
+		require.Fail(s.T(), "supervisor did not stop")
+	}
+}
+
 type MockStream struct {
 	fail bool
 }
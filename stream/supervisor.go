@@ -0,0 +1,172 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	v2stream "github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// Streamer is the lifecycle shared by the streaming clients: register
+// handlers, Connect, then wait on Terminated until Close is called or the
+// connection is lost for good.
+type Streamer interface {
+	Connect(ctx context.Context) error
+	Close() error
+	Terminated() <-chan error
+	Subscriptions() []string
+}
+
+var (
+	_ Streamer = (*Client)(nil)
+	_ Streamer = (*v2stream.DataStreamer)(nil)
+)
+
+// RestartPolicy controls how a Supervisor restarts its streamers.
+type RestartPolicy struct {
+	// MaxRestarts is the number of restarts allowed per streamer,
+	// negative for no limit.
+	MaxRestarts int
+	// Backoff is the delay before the first restart, doubled after each
+	// restart up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// OnRestart, if set, is called before each restart with the error that
+	// stopped the streamer.
+	OnRestart func(name string, err error)
+	// Logger logs the restarts when OnRestart is not set,
+	// common.DefaultLogger if nil.
+	Logger common.Logger
+}
+
+// DefaultRestartPolicy restarts streamers forever, backing off up to a minute.
+var DefaultRestartPolicy = RestartPolicy{
+	MaxRestarts: -1,
+	Backoff:     time.Second,
+	MaxBackoff:  time.Minute,
+}
+
+// Supervisor runs several streamers and restarts any of them that fails
+// according to a shared RestartPolicy. A streamer can only be connected
+// once, so the supervisor builds a new one through its factory for each
+// (re)start; the factory is where the handlers are registered.
+type Supervisor struct {
+	policy    RestartPolicy
+	mu        sync.Mutex
+	factories map[string]func() Streamer
+	running   map[string]Streamer
+}
+
+// NewSupervisor returns a supervisor using policy.
+func NewSupervisor(policy RestartPolicy) *Supervisor {
+	return &Supervisor{
+		policy:    policy,
+		factories: map[string]func() Streamer{},
+		running:   map[string]Streamer{},
+	}
+}
+
+// Add registers a streamer under name. It must be called before Run.
+func (s *Supervisor) Add(name string, newStreamer func() Streamer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.factories[name] = newStreamer
+}
+
+// Streamer returns the currently running streamer registered under name,
+// or nil.
+func (s *Supervisor) Streamer(name string) Streamer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running[name]
+}
+
+// Run connects every streamer and restarts the ones that stop with an error,
+// until ctx is cancelled. It returns nil once ctx is cancelled and every
+// streamer stopped, or the error of the first streamer that ran out of
+// restarts, after stopping the others.
+func (s *Supervisor) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	factories := make(map[string]func() Streamer, len(s.factories))
+	for name, factory := range s.factories {
+		factories[name] = factory
+	}
+	s.mu.Unlock()
+
+	errs := make(chan error, len(factories))
+	var wg sync.WaitGroup
+	for name, factory := range factories {
+		wg.Add(1)
+		go func(name string, factory func() Streamer) {
+			defer wg.Done()
+			if err := s.supervise(ctx, name, factory); err != nil {
+				errs <- err
+				cancel()
+			}
+		}(name, factory)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+func (s *Supervisor) supervise(ctx context.Context, name string, factory func() Streamer) error {
+	backoff := s.policy.Backoff
+	for restarts := 0; ; restarts++ {
+		err := s.runOnce(ctx, name, factory())
+		if ctx.Err() != nil || err == nil {
+			// cancelled, or closed on purpose
+			return nil
+		}
+		if s.policy.MaxRestarts >= 0 && restarts >= s.policy.MaxRestarts {
+			return fmt.Errorf("stream %s: %w", name, err)
+		}
+
+		if s.policy.OnRestart != nil {
+			s.policy.OnRestart(name, err)
+		} else {
+			logger := s.policy.Logger
+			if logger == nil {
+				logger = common.DefaultLogger
+			}
+			logger.Warn("streamer stopped, restarting",
+				"client", "supervisor", "streamer", name, "attempt", restarts+1, "delay", backoff, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if s.policy.MaxBackoff > 0 && backoff > s.policy.MaxBackoff {
+			backoff = s.policy.MaxBackoff
+		}
+	}
+}
+
+func (s *Supervisor) runOnce(ctx context.Context, name string, streamer Streamer) error {
+	s.mu.Lock()
+	s.running[name] = streamer
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, name)
+		s.mu.Unlock()
+	}()
+
+	if err := streamer.Connect(ctx); err != nil {
+		return err
+	}
+	return <-streamer.Terminated()
+}
@@ -1,16 +1,24 @@
 package v2
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Trade is a stock trade that happened on the market
 type Trade struct {
 	ID         int64     `json:"i"`
-	Exchange   string    `json:"x"`
+	Exchange   Exchange  `json:"x"`
 	Price      float64   `json:"p"`
 	Size       uint32    `json:"s"`
 	Timestamp  time.Time `json:"t"`
 	Conditions []string  `json:"c"`
-	Tape       string    `json:"z"`
+	Tape       Tape      `json:"z"`
+}
+
+// HasCondition returns true if the trade has condition c
+func (t Trade) HasCondition(c TradeCondition) bool {
+	return HasTradeCondition(t.Conditions, c)
 }
 
 // TradeItem contains a single trade or an error
@@ -21,15 +29,20 @@ type TradeItem struct {
 
 // Quote is a stock quote from the market
 type Quote struct {
-	BidExchange string    `json:"bx"`
+	BidExchange Exchange  `json:"bx"`
 	BidPrice    float64   `json:"bp"`
 	BidSize     uint32    `json:"bs"`
-	AskExchange string    `json:"ax"`
+	AskExchange Exchange  `json:"ax"`
 	AskPrice    float64   `json:"ap"`
 	AskSize     uint32    `json:"as"`
 	Timestamp   time.Time `json:"t"`
 	Conditions  []string  `json:"c"`
-	Tape        string    `json:"z"`
+	Tape        Tape      `json:"z"`
+}
+
+// HasCondition returns true if the quote has condition c
+func (q Quote) HasCondition(c QuoteCondition) bool {
+	return HasQuoteCondition(q.Conditions, c)
 }
 
 // QuoteItem contains a single quote or an error
@@ -67,6 +80,10 @@ type Bar struct {
 	Close     float64   `json:"c"`
 	Volume    uint64    `json:"v"`
 	Timestamp time.Time `json:"t"`
+	// TradeCount is the number of trades aggregated by the bar
+	TradeCount uint64 `json:"n"`
+	// VWAP is the volume-weighted average price of the trades of the bar
+	VWAP float64 `json:"vw"`
 }
 
 // BarItem contains a single bar or an error
@@ -82,4 +99,119 @@ type Snapshot struct {
 	MinuteBar    *Bar   `json:"minuteBar"`
 	DailyBar     *Bar   `json:"dailyBar"`
 	PrevDailyBar *Bar   `json:"prevDailyBar"`
+	// Raw is the JSON the snapshot was decoded from, including the fields
+	// not (yet) known by this package
+	Raw json.RawMessage `json:"-"`
+}
+
+func (s *Snapshot) UnmarshalJSON(data []byte) error {
+	type alias Snapshot
+	if err := json.Unmarshal(data, (*alias)(s)); err != nil {
+		return err
+	}
+	s.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// CryptoTrade is a crypto trade that happened on an exchange
+type CryptoTrade struct {
+	ID        int64     `json:"i"`
+	Exchange  string    `json:"x"`
+	Price     float64   `json:"p"`
+	Size      float64   `json:"s"`
+	Timestamp time.Time `json:"t"`
+	// TakerSide is B if the taker bought, S if it sold
+	TakerSide string `json:"tks"`
+}
+
+// CryptoQuote is a crypto quote from an exchange
+type CryptoQuote struct {
+	Exchange  string    `json:"x"`
+	BidPrice  float64   `json:"bp"`
+	BidSize   float64   `json:"bs"`
+	AskPrice  float64   `json:"ap"`
+	AskSize   float64   `json:"as"`
+	Timestamp time.Time `json:"t"`
+}
+
+// CryptoExchangeSnapshot is the latest trade and quote of a crypto pair on
+// an exchange
+type CryptoExchangeSnapshot struct {
+	LatestTrade *CryptoTrade `json:"latestTrade"`
+	LatestQuote *CryptoQuote `json:"latestQuote"`
+}
+
+// CryptoSnapshot is a snapshot of a crypto pair
+type CryptoSnapshot struct {
+	Symbol string `json:"symbol"`
+	// LatestTrade is the latest trade on any exchange
+	LatestTrade *CryptoTrade `json:"latestTrade"`
+	// Exchanges are the snapshots of the pair on every exchange it trades
+	// on, keyed by exchange
+	Exchanges map[string]CryptoExchangeSnapshot `json:"exchanges"`
+	// Raw is the JSON the snapshot was decoded from, including the fields
+	// not (yet) known by this package
+	Raw json.RawMessage `json:"-"`
+}
+
+func (s *CryptoSnapshot) UnmarshalJSON(data []byte) error {
+	type alias CryptoSnapshot
+	if err := json.Unmarshal(data, (*alias)(s)); err != nil {
+		return err
+	}
+	s.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// CryptoBBO is the best bid and offer of a crypto pair across exchanges
+type CryptoBBO struct {
+	BidExchange string
+	BidPrice    float64
+	BidSize     float64
+	AskExchange string
+	AskPrice    float64
+	AskSize     float64
+}
+
+// Spread returns the ask price minus the bid price. It is negative if the
+// market is crossed, i.e. the pair can be bought on the ask exchange below
+// the price it can be sold at on the bid exchange.
+func (b CryptoBBO) Spread() float64 {
+	return b.AskPrice - b.BidPrice
+}
+
+// BestBidAsk consolidates the latest quotes of the exchanges into the
+// highest bid and the lowest ask. It returns false if no exchange has both.
+func (s *CryptoSnapshot) BestBidAsk() (CryptoBBO, bool) {
+	var bbo CryptoBBO
+	hasBid, hasAsk := false, false
+	for exchange, snapshot := range s.Exchanges {
+		quote := snapshot.LatestQuote
+		if quote == nil {
+			continue
+		}
+		if quote.BidPrice > 0 && (!hasBid || quote.BidPrice > bbo.BidPrice ||
+			quote.BidPrice == bbo.BidPrice && exchange < bbo.BidExchange) {
+			bbo.BidExchange, bbo.BidPrice, bbo.BidSize = exchange, quote.BidPrice, quote.BidSize
+			hasBid = true
+		}
+		if quote.AskPrice > 0 && (!hasAsk || quote.AskPrice < bbo.AskPrice ||
+			quote.AskPrice == bbo.AskPrice && exchange < bbo.AskExchange) {
+			bbo.AskExchange, bbo.AskPrice, bbo.AskSize = exchange, quote.AskPrice, quote.AskSize
+			hasAsk = true
+		}
+	}
+	return bbo, hasBid && hasAsk
+}
+
+// CrossExchangeSpread returns the profit per unit of buying the pair at the
+// ask of the buy exchange and selling it at the bid of the sell exchange,
+// i.e. the bid of sell minus the ask of buy, before fees. It returns false
+// if either exchange has no quote.
+func (s *CryptoSnapshot) CrossExchangeSpread(buy, sell string) (float64, bool) {
+	buyQuote, sellQuote := s.Exchanges[buy].LatestQuote, s.Exchanges[sell].LatestQuote
+	if buyQuote == nil || sellQuote == nil || buyQuote.AskPrice <= 0 || sellQuote.BidPrice <= 0 {
+		return 0, false
+	}
+	return sellQuote.BidPrice - buyQuote.AskPrice, true
 }
@@ -0,0 +1,226 @@
+package v2
+
+// Exchange is the code of the exchange of a stock trade or quote
+type Exchange string
+
+// List of exchanges
+const (
+	ExchangeNYSEAmerican      Exchange = "A"
+	ExchangeNasdaqBX          Exchange = "B"
+	ExchangeNSX               Exchange = "C"
+	ExchangeFINRAADF          Exchange = "D"
+	ExchangeMarketIndependent Exchange = "E"
+	ExchangeMIAX              Exchange = "H"
+	ExchangeISE               Exchange = "I"
+	ExchangeCboeEDGA          Exchange = "J"
+	ExchangeCboeEDGX          Exchange = "K"
+	ExchangeLTSE              Exchange = "L"
+	ExchangeNYSEChicago       Exchange = "M"
+	ExchangeNYSE              Exchange = "N"
+	ExchangeNYSEArca          Exchange = "P"
+	ExchangeNasdaq            Exchange = "Q"
+	ExchangeNasdaqSmallCap    Exchange = "S"
+	ExchangeNasdaqInt         Exchange = "T"
+	ExchangeMEMX              Exchange = "U"
+	ExchangeIEX               Exchange = "V"
+	ExchangeCBOE              Exchange = "W"
+	ExchangeNasdaqPSX         Exchange = "X"
+	ExchangeCboeBYX           Exchange = "Y"
+	ExchangeCboeBZX           Exchange = "Z"
+)
+
+var exchangeDescriptions = map[Exchange]string{
+	ExchangeNYSEAmerican:      "NYSE American",
+	ExchangeNasdaqBX:          "Nasdaq BX",
+	ExchangeNSX:               "National Stock Exchange",
+	ExchangeFINRAADF:          "FINRA ADF",
+	ExchangeMarketIndependent: "Market Independent",
+	ExchangeMIAX:              "MIAX",
+	ExchangeISE:               "International Securities Exchange",
+	ExchangeCboeEDGA:          "Cboe EDGA",
+	ExchangeCboeEDGX:          "Cboe EDGX",
+	ExchangeLTSE:              "Long-Term Stock Exchange",
+	ExchangeNYSEChicago:       "NYSE Chicago",
+	ExchangeNYSE:              "New York Stock Exchange",
+	ExchangeNYSEArca:          "NYSE Arca",
+	ExchangeNasdaq:            "Nasdaq",
+	ExchangeNasdaqSmallCap:    "Nasdaq Small Cap",
+	ExchangeNasdaqInt:         "Nasdaq Int",
+	ExchangeMEMX:              "Members Exchange",
+	ExchangeIEX:               "IEX",
+	ExchangeCBOE:              "Cboe",
+	ExchangeNasdaqPSX:         "Nasdaq PSX",
+	ExchangeCboeBYX:           "Cboe BYX",
+	ExchangeCboeBZX:           "Cboe BZX",
+}
+
+// Description returns the name of the exchange, or an empty string if the
+// code is unknown
+func (e Exchange) Description() string {
+	return exchangeDescriptions[e]
+}
+
+// String returns the name of the exchange, or its code if it is unknown
+func (e Exchange) String() string {
+	return describe(string(e), e.Description())
+}
+
+// Tape is the consolidated tape a stock is reported on, depending on its
+// listing exchange
+type Tape string
+
+// List of tapes
+const (
+	// TapeA is for the stocks listed on the NYSE
+	TapeA Tape = "A"
+	// TapeB is for the stocks listed on NYSE Arca, NYSE American and the
+	// regional exchanges
+	TapeB Tape = "B"
+	// TapeC is for the stocks listed on Nasdaq
+	TapeC Tape = "C"
+)
+
+var tapeDescriptions = map[Tape]string{
+	TapeA: "Tape A (NYSE)",
+	TapeB: "Tape B (NYSE Arca, NYSE American and regional exchanges)",
+	TapeC: "Tape C (Nasdaq)",
+}
+
+// Description returns the listing exchanges of the tape, or an empty string
+// if it is unknown
+func (t Tape) Description() string {
+	return tapeDescriptions[t]
+}
+
+// String returns the description of the tape, or its code if it is unknown
+func (t Tape) String() string {
+	return describe(string(t), t.Description())
+}
+
+// TradeCondition is a sale condition of a stock trade. Only the conditions
+// with the same meaning on every tape have constants.
+type TradeCondition string
+
+// List of trade conditions
+const (
+	TradeRegularSale         TradeCondition = "@"
+	TradeCashSale            TradeCondition = "C"
+	TradeIntermarketSweep    TradeCondition = "F"
+	TradePriceVariation      TradeCondition = "H"
+	TradeOddLot              TradeCondition = "I"
+	TradeOfficialClose       TradeCondition = "M"
+	TradeNextDay             TradeCondition = "N"
+	TradeOpeningPrints       TradeCondition = "O"
+	TradePriorReferencePrice TradeCondition = "P"
+	TradeOfficialOpen        TradeCondition = "Q"
+	TradeSeller              TradeCondition = "R"
+	TradeFormT               TradeCondition = "T"
+	TradeExtendedHoursSold   TradeCondition = "U"
+	TradeContingent          TradeCondition = "V"
+	TradeSoldOutOfSequence   TradeCondition = "Z"
+	TradeDerivativelyPriced  TradeCondition = "4"
+	TradeClosingPrints       TradeCondition = "6"
+	TradeQualifiedContingent TradeCondition = "7"
+)
+
+var tradeConditionDescriptions = map[TradeCondition]string{
+	TradeRegularSale:         "Regular Sale",
+	TradeCashSale:            "Cash Sale",
+	TradeIntermarketSweep:    "Intermarket Sweep",
+	TradePriceVariation:      "Price Variation Trade",
+	TradeOddLot:              "Odd Lot Trade",
+	TradeOfficialClose:       "Market Center Official Close",
+	TradeNextDay:             "Next Day",
+	TradeOpeningPrints:       "Opening Prints",
+	TradePriorReferencePrice: "Prior Reference Price",
+	TradeOfficialOpen:        "Market Center Official Open",
+	TradeSeller:              "Seller",
+	TradeFormT:               "Form T (Extended Hours Trade)",
+	TradeExtendedHoursSold:   "Extended Trading Hours (Sold Out of Sequence)",
+	TradeContingent:          "Contingent Trade",
+	TradeSoldOutOfSequence:   "Sold (Out of Sequence)",
+	TradeDerivativelyPriced:  "Derivatively Priced",
+	TradeClosingPrints:       "Closing Prints",
+	TradeQualifiedContingent: "Qualified Contingent Trade",
+}
+
+// Description returns the meaning of the condition, or an empty string if
+// it is unknown
+func (c TradeCondition) Description() string {
+	return tradeConditionDescriptions[c]
+}
+
+// String returns the description of the condition, or its code if it is
+// unknown
+func (c TradeCondition) String() string {
+	return describe(string(c), c.Description())
+}
+
+// IsExtendedHours returns true if the condition marks a trade of the
+// extended hours
+func (c TradeCondition) IsExtendedHours() bool {
+	return c == TradeFormT || c == TradeExtendedHoursSold
+}
+
+// QuoteCondition is a condition of a stock quote. Only the most common
+// conditions have constants.
+type QuoteCondition string
+
+// List of quote conditions
+const (
+	QuoteRegular           QuoteCondition = "R"
+	QuoteOpening           QuoteCondition = "O"
+	QuoteClosing           QuoteCondition = "C"
+	QuoteFastTrading       QuoteCondition = "F"
+	QuoteNewsDissemination QuoteCondition = "D"
+	QuoteOrderImbalance    QuoteCondition = "I"
+)
+
+var quoteConditionDescriptions = map[QuoteCondition]string{
+	QuoteRegular:           "Regular",
+	QuoteOpening:           "Opening Quote",
+	QuoteClosing:           "Closing Quote",
+	QuoteFastTrading:       "Fast Trading",
+	QuoteNewsDissemination: "News Dissemination",
+	QuoteOrderImbalance:    "Order Imbalance",
+}
+
+// Description returns the meaning of the condition, or an empty string if
+// it is unknown
+func (c QuoteCondition) Description() string {
+	return quoteConditionDescriptions[c]
+}
+
+// String returns the description of the condition, or its code if it is
+// unknown
+func (c QuoteCondition) String() string {
+	return describe(string(c), c.Description())
+}
+
+// HasTradeCondition returns true if conditions, e.g. the conditions of a
+// trade, contain c
+func HasTradeCondition(conditions []string, c TradeCondition) bool {
+	return hasCondition(conditions, string(c))
+}
+
+// HasQuoteCondition returns true if conditions, e.g. the conditions of a
+// quote, contain c
+func HasQuoteCondition(conditions []string, c QuoteCondition) bool {
+	return hasCondition(conditions, string(c))
+}
+
+func hasCondition(conditions []string, c string) bool {
+	for _, condition := range conditions {
+		if condition == c {
+			return true
+		}
+	}
+	return false
+}
+
+func describe(code, description string) string {
+	if description == "" {
+		return code
+	}
+	return description
+}
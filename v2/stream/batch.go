@@ -0,0 +1,135 @@
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// BatchHandlers turns handlers of batches of messages into the handlers of
+// single messages the subscribe functions take, so that high-throughput
+// consumers, e.g. writing to a database, handle many messages at once:
+//
+//	batches := stream.WithBatchHandlers(500, 100*time.Millisecond)
+//	err := stream.SubscribeTrades(batches.Trades(func(trades []stream.Trade) {
+//		db.InsertTrades(trades)
+//	}), "*")
+//
+// The batches of a handler are delivered one at a time, in order.
+type BatchHandlers struct {
+	maxBatch int
+	maxDelay time.Duration
+
+	mu       sync.Mutex
+	flushers []func()
+}
+
+// WithBatchHandlers returns batch handlers delivering a batch once it has
+// maxBatch messages or maxDelay after its first message, whichever comes
+// first. A maxDelay of 0 only delivers full batches.
+func WithBatchHandlers(maxBatch int, maxDelay time.Duration) *BatchHandlers {
+	if maxBatch < 1 {
+		maxBatch = 1
+	}
+	return &BatchHandlers{maxBatch: maxBatch, maxDelay: maxDelay}
+}
+
+// Trades returns a trade handler passing the trades to handler in batches
+func (h *BatchHandlers) Trades(handler func(trades []Trade)) func(trade Trade) {
+	b := newBatcher(handler, h.maxBatch, h.maxDelay)
+	h.addFlusher(b.flush)
+	return b.add
+}
+
+// Quotes returns a quote handler passing the quotes to handler in batches
+func (h *BatchHandlers) Quotes(handler func(quotes []Quote)) func(quote Quote) {
+	b := newBatcher(handler, h.maxBatch, h.maxDelay)
+	h.addFlusher(b.flush)
+	return b.add
+}
+
+// Bars returns a bar handler passing the bars to handler in batches
+func (h *BatchHandlers) Bars(handler func(bars []Bar)) func(bar Bar) {
+	b := newBatcher(handler, h.maxBatch, h.maxDelay)
+	h.addFlusher(b.flush)
+	return b.add
+}
+
+// Flush delivers the pending batches of all the handlers right away, e.g.
+// before the program exits
+func (h *BatchHandlers) Flush() {
+	h.mu.Lock()
+	flushers := append([]func(){}, h.flushers...)
+	h.mu.Unlock()
+	for _, flush := range flushers {
+		flush()
+	}
+}
+
+func (h *BatchHandlers) addFlusher(flush func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.flushers = append(h.flushers, flush)
+}
+
+// batcher collects messages into the batches of a handler
+type batcher[T any] struct {
+	handler  func(batch []T)
+	maxBatch int
+	maxDelay time.Duration
+
+	mu    sync.Mutex
+	batch []T
+	// generation identifies the pending batch, so that the timer of a batch
+	// already delivered does nothing
+	generation int
+	// deliverMu delivers the batches one at a time, in order
+	deliverMu sync.Mutex
+}
+
+func newBatcher[T any](handler func(batch []T), maxBatch int, maxDelay time.Duration) *batcher[T] {
+	return &batcher[T]{handler: handler, maxBatch: maxBatch, maxDelay: maxDelay}
+}
+
+func (b *batcher[T]) add(msg T) {
+	b.mu.Lock()
+	b.batch = append(b.batch, msg)
+	if len(b.batch) >= b.maxBatch {
+		b.deliver()
+		return
+	}
+	if len(b.batch) == 1 && b.maxDelay > 0 {
+		generation := b.generation
+		timeout := Clock.After(b.maxDelay)
+		go func() {
+			<-timeout
+			b.mu.Lock()
+			if generation != b.generation || len(b.batch) == 0 {
+				b.mu.Unlock()
+				return
+			}
+			b.deliver()
+		}()
+	}
+	b.mu.Unlock()
+}
+
+func (b *batcher[T]) flush() {
+	b.mu.Lock()
+	if len(b.batch) == 0 {
+		b.mu.Unlock()
+		return
+	}
+	b.deliver()
+}
+
+// deliver passes the pending batch to the handler. It must be called with mu
+// held, which it releases.
+func (b *batcher[T]) deliver() {
+	batch := b.batch
+	b.batch = nil
+	b.generation++
+	b.deliverMu.Lock()
+	b.mu.Unlock()
+	defer b.deliverMu.Unlock()
+	b.handler(batch)
+}
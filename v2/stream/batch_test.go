@@ -0,0 +1,48 @@
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchHandlers(t *testing.T) {
+	fake := clock.NewFake(time.Date(2021, 3, 2, 9, 30, 0, 0, time.UTC))
+	Clock = fake
+	defer func() { Clock = clock.Real }()
+
+	batches := make(chan []Trade, 10)
+	h := WithBatchHandlers(3, time.Second)
+	handler := h.Trades(func(trades []Trade) {
+		batches <- trades
+	})
+
+	// full batches are delivered right away
+	for id := int64(1); id <= 4; id++ {
+		handler(Trade{ID: id})
+	}
+	require.Len(t, batches, 1)
+	assert.Len(t, <-batches, 3)
+
+	// the others once their delay is over
+	fake.BlockUntil(2)
+	fake.Advance(time.Second)
+	select {
+	case batch := <-batches:
+		require.Len(t, batch, 1)
+		assert.EqualValues(t, 4, batch[0].ID)
+	case <-time.After(time.Second):
+		t.Fatal("batch not delivered after its delay")
+	}
+
+	// or when flushed
+	handler(Trade{ID: 5})
+	h.Flush()
+	require.Len(t, batches, 1)
+	assert.EqualValues(t, 5, (<-batches)[0].ID)
+	h.Flush()
+	assert.Len(t, batches, 0)
+}
@@ -0,0 +1,216 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+)
+
+// Topics of the messages forwarded by a Bridge
+const (
+	TradesTopic = "trades"
+	QuotesTopic = "quotes"
+	BarsTopic   = "bars"
+)
+
+// ErrBridgeClosed is returned when a closed bridge is used
+var ErrBridgeClosed = errors.New("bridge is closed")
+
+// Message is a trade, quote or bar forwarded by a Bridge
+type Message struct {
+	// Topic is TradesTopic, QuotesTopic or BarsTopic, with the prefix of the bridge
+	Topic string
+	// Key is the symbol, publishers partition the messages by it so that
+	// the messages of a symbol stay in order
+	Key string
+	// Value is the encoded trade, quote or bar
+	Value     []byte
+	Timestamp time.Time
+}
+
+// Publisher publishes the messages of a Bridge to a message bus. See the
+// kafkabridge and natsbridge modules for Kafka and NATS publishers.
+type Publisher interface {
+	// Publish returns once the bus acknowledged msg, or with an error
+	Publish(ctx context.Context, msg Message) error
+}
+
+// BridgeOption configures a Bridge
+type BridgeOption func(b *Bridge)
+
+// WithTopicPrefix prefixes the topics of the messages, e.g. "alpaca." for "alpaca.trades".
+func WithTopicPrefix(prefix string) BridgeOption {
+	return func(b *Bridge) {
+		b.prefix = prefix
+	}
+}
+
+// WithEncoder sets the encoding of the trades, quotes and bars, json.Marshal by default.
+func WithEncoder(encode func(v interface{}) ([]byte, error)) BridgeOption {
+	return func(b *Bridge) {
+		b.encode = encode
+	}
+}
+
+// WithBufferSize sets how many messages are buffered while the publisher
+// is slow or failing, 10000 by default.
+func WithBufferSize(size int) BridgeOption {
+	return func(b *Bridge) {
+		b.bufferSize = size
+	}
+}
+
+// WithRetryDelay sets the delay between two attempts to publish a message, 1s by default.
+func WithRetryDelay(delay time.Duration) BridgeOption {
+	return func(b *Bridge) {
+		b.retryDelay = delay
+	}
+}
+
+// WithLogger sets the logger of the bridge, the package Logger by default.
+func WithLogger(logger common.Logger) BridgeOption {
+	return func(b *Bridge) {
+		b.logger = logger
+	}
+}
+
+// Bridge forwards the trades, quotes and bars of the data stream to a
+// Publisher. Delivery is at least once: a message is retried until the
+// publisher acknowledges it, so it may be published more than once but is
+// not dropped, and once the buffer is full the handlers block until the
+// publisher catches up.
+type Bridge struct {
+	publisher  Publisher
+	prefix     string
+	encode     func(v interface{}) ([]byte, error)
+	bufferSize int
+	retryDelay time.Duration
+	logger     common.Logger
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan Message
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewBridge creates a bridge forwarding to publisher.
+func NewBridge(publisher Publisher, opts ...BridgeOption) *Bridge {
+	b := &Bridge{
+		publisher:  publisher,
+		encode:     json.Marshal,
+		bufferSize: 10000,
+		retryDelay: time.Second,
+		done:       make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.logger == nil {
+		b.logger = logger()
+	}
+	b.queue = make(chan Message, b.bufferSize)
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+
+	go b.run()
+	return b
+}
+
+// Subscribe subscribes the bridge to the trades, quotes and bars of the
+// given symbols on the data stream of this package.
+func (b *Bridge) Subscribe(trades, quotes, bars []string) error {
+	if len(trades) > 0 {
+		if err := SubscribeTrades(b.ForwardTrade, trades...); err != nil {
+			return err
+		}
+	}
+	if len(quotes) > 0 {
+		if err := SubscribeQuotes(b.ForwardQuote, quotes...); err != nil {
+			return err
+		}
+	}
+	if len(bars) > 0 {
+		if err := SubscribeBars(b.ForwardBar, bars...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForwardTrade forwards a trade. It can be passed to SubscribeTrades.
+func (b *Bridge) ForwardTrade(trade Trade) {
+	b.forward(TradesTopic, trade.Symbol, trade.Timestamp, trade)
+}
+
+// ForwardQuote forwards a quote. It can be passed to SubscribeQuotes.
+func (b *Bridge) ForwardQuote(quote Quote) {
+	b.forward(QuotesTopic, quote.Symbol, quote.Timestamp, quote)
+}
+
+// ForwardBar forwards a bar. It can be passed to SubscribeBars.
+func (b *Bridge) ForwardBar(bar Bar) {
+	b.forward(BarsTopic, bar.Symbol, bar.Timestamp, bar)
+}
+
+func (b *Bridge) forward(topic, symbol string, timestamp time.Time, v interface{}) {
+	value, err := b.encode(v)
+	if err != nil {
+		b.logger.Error("failed to encode message", "client", "bridge", "topic", topic, "symbol", symbol, "error", err)
+		return
+	}
+	msg := Message{Topic: b.prefix + topic, Key: symbol, Value: value, Timestamp: timestamp}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return
+	}
+	b.queue <- msg
+}
+
+func (b *Bridge) run() {
+	defer close(b.done)
+	for msg := range b.queue {
+		for attempt := 1; ; attempt++ {
+			err := b.publisher.Publish(b.ctx, msg)
+			if err == nil {
+				break
+			}
+			b.logger.Warn("failed to publish, retrying", "client", "bridge", "topic", msg.Topic,
+				"attempt", attempt, "delay", b.retryDelay, "error", err)
+			select {
+			case <-b.ctx.Done():
+				return
+			case <-Clock.After(b.retryDelay):
+			}
+		}
+	}
+}
+
+// Close stops forwarding and waits until the buffered messages are
+// published, or ctx is done, in which case the messages left are dropped.
+func (b *Bridge) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBridgeClosed
+	}
+	b.closed = true
+	close(b.queue)
+	b.mu.Unlock()
+
+	select {
+	case <-b.done:
+		b.cancel()
+		return nil
+	case <-ctx.Done():
+		b.cancel()
+		<-b.done
+		return ctx.Err()
+	}
+}
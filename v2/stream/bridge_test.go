@@ -0,0 +1,73 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePublisher struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	msgs     []Message
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, msg Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempts++
+	if p.failures > 0 {
+		p.failures--
+		return errors.New("broker unavailable")
+	}
+	p.msgs = append(p.msgs, msg)
+	return nil
+}
+
+func TestBridge(t *testing.T) {
+	// the first two attempts fail, as while the broker reconnects
+	pub := &fakePublisher{failures: 2}
+	b := NewBridge(pub, WithTopicPrefix("alpaca."), WithRetryDelay(time.Millisecond))
+
+	ts := time.Date(2021, 3, 2, 14, 30, 0, 0, time.UTC)
+	b.ForwardTrade(Trade{Symbol: "AAPL", Price: 120.25, Size: 100, Timestamp: ts})
+	b.ForwardQuote(Quote{Symbol: "MSFT", BidPrice: 230.1, AskPrice: 230.2, Timestamp: ts})
+	b.ForwardBar(Bar{Symbol: "AAPL", Close: 120.5, Timestamp: ts})
+	require.NoError(t, b.Close(context.Background()))
+	assert.Equal(t, ErrBridgeClosed, b.Close(context.Background()))
+
+	// nothing is dropped and the order is kept
+	require.Len(t, pub.msgs, 3)
+	assert.Equal(t, 5, pub.attempts)
+	assert.Equal(t, "alpaca.trades", pub.msgs[0].Topic)
+	assert.Equal(t, "AAPL", pub.msgs[0].Key)
+	assert.Equal(t, ts, pub.msgs[0].Timestamp)
+	var trade Trade
+	require.NoError(t, json.Unmarshal(pub.msgs[0].Value, &trade))
+	assert.Equal(t, 120.25, trade.Price)
+	assert.Equal(t, "alpaca.quotes", pub.msgs[1].Topic)
+	assert.Equal(t, "MSFT", pub.msgs[1].Key)
+	assert.Equal(t, "alpaca.bars", pub.msgs[2].Topic)
+
+	// forwarding after close is a no-op
+	b.ForwardTrade(Trade{Symbol: "AAPL"})
+	assert.Len(t, pub.msgs, 3)
+}
+
+func TestBridgeCloseTimeout(t *testing.T) {
+	pub := &fakePublisher{failures: 1 << 30}
+	b := NewBridge(pub, WithRetryDelay(time.Millisecond))
+	b.ForwardTrade(Trade{Symbol: "AAPL"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, b.Close(ctx))
+	assert.Empty(t, pub.msgs)
+}
@@ -0,0 +1,119 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrDataStreamerConnected is returned when a DataStreamer is connected twice
+	ErrDataStreamerConnected = errors.New("data streamer already connected")
+
+	// ErrDataStreamerNotConnected is returned when a DataStreamer is closed
+	// before it was connected
+	ErrDataStreamerNotConnected = errors.New("data streamer not connected")
+)
+
+// DataStreamer runs the data v2 stream of the package as a stream.Streamer,
+// so that a stream.Supervisor restarts it once it fails to reconnect. Its
+// handlers are those registered with SubscribeTrades, SubscribeQuotes and
+// SubscribeBars. It can only be connected once: the factory of the
+// supervisor returns a NewDataStreamer for each (re)start.
+type DataStreamer struct {
+	mu         sync.Mutex
+	cancel     context.CancelFunc
+	terminated chan error
+}
+
+// NewDataStreamer returns a streamer of the data v2 stream.
+func NewDataStreamer() *DataStreamer {
+	return &DataStreamer{terminated: make(chan error, 1)}
+}
+
+// Connect connects the data v2 stream, unless it is already connected, and
+// subscribes the registered handlers. The stream then keeps reading (and
+// reconnecting when needed) until ctx is cancelled, Close is called or
+// reconnecting fails. Terminated reports why it stopped.
+func (d *DataStreamer) Connect(ctx context.Context) error {
+	d.mu.Lock()
+	if d.cancel != nil {
+		d.mu.Unlock()
+		return ErrDataStreamerConnected
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	d.cancel = cancel
+	d.mu.Unlock()
+
+	initStreamsOnce()
+	ds := dataStream
+	// the stream may have terminated before, with nobody waiting for it
+	select {
+	case <-ds.terminated:
+	default:
+	}
+	ds.closed.Store(false)
+	if err := ds.ensureRunning(); err != nil {
+		cancel()
+		d.terminated <- err
+		close(d.terminated)
+		return err
+	}
+
+	go func() {
+		var err error
+		select {
+		case err = <-ds.terminated:
+		case <-ctx.Done():
+			ds.close(true)
+			// the reader stops before the next Connect starts a new one
+			<-ds.terminated
+		}
+		cancel()
+		d.terminated <- err
+		close(d.terminated)
+	}()
+	return nil
+}
+
+// Terminated returns a channel that receives the reason the stream stopped
+// and is then closed. The error is nil when the stream was stopped by Close
+// or by cancelling the context passed to Connect.
+func (d *DataStreamer) Terminated() <-chan error {
+	return d.terminated
+}
+
+// Close closes the data v2 stream.
+func (d *DataStreamer) Close() error {
+	d.mu.Lock()
+	cancel := d.cancel
+	d.mu.Unlock()
+	if cancel == nil {
+		return ErrDataStreamerNotConnected
+	}
+
+	cancel()
+	return nil
+}
+
+// Subscriptions returns the subscriptions of the registered handlers, e.g.
+// "trades:AAPL".
+func (d *DataStreamer) Subscriptions() []string {
+	initStreamsOnce()
+	dataStream.handlersMutex.RLock()
+	defer dataStream.handlersMutex.RUnlock()
+
+	var subscriptions []string
+	for symbol := range dataStream.tradeHandlers {
+		subscriptions = append(subscriptions, "trades:"+symbol)
+	}
+	for symbol := range dataStream.quoteHandlers {
+		subscriptions = append(subscriptions, "quotes:"+symbol)
+	}
+	for symbol := range dataStream.barHandlers {
+		subscriptions = append(subscriptions, "bars:"+symbol)
+	}
+	sort.Strings(subscriptions)
+	return subscriptions
+}
@@ -0,0 +1,94 @@
+package stream
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+	"nhooyr.io/websocket"
+)
+
+func writeMsgpack(t *testing.T, conn *websocket.Conn, msg interface{}) {
+	b, err := msgpack.Marshal(msg)
+	require.NoError(t, err)
+	require.NoError(t, conn.Write(context.Background(), websocket.MessageBinary, b))
+}
+
+func TestDataStreamerReconnectFailure(t *testing.T) {
+	var connections int32
+	rejectedClosed := make(chan websocket.StatusCode, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&connections, 1)
+		conn, err := websocket.Accept(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close(websocket.StatusNormalClosure, "")
+
+		ctx := context.Background()
+		if n == 2 {
+			// the reconnection is rejected before the connected message
+			writeMsgpack(t, conn, []map[string]string{{"T": "error", "msg": "connection limit exceeded"}})
+			_, _, err := conn.Read(ctx)
+			rejectedClosed <- websocket.CloseStatus(err)
+			return
+		}
+		writeMsgpack(t, conn, []map[string]string{{"T": "success", "msg": "connected"}})
+		_, _, err = conn.Read(ctx)
+		require.NoError(t, err)
+		writeMsgpack(t, conn, []map[string]string{{"T": "success", "msg": "authenticated"}})
+		if n == 1 {
+			conn.Close(websocket.StatusInternalError, "restarting")
+			return
+		}
+		conn.Read(ctx)
+	}))
+	defer server.Close()
+
+	defer func(u string) { DataStreamURL = u }(DataStreamURL)
+	defer func(attempts int) { MaxConnectionAttempts = attempts }(MaxConnectionAttempts)
+	DataStreamURL = server.URL
+	MaxConnectionAttempts = 1
+	SetCredentialsProvider(common.CredentialsProviderFunc(func(ctx context.Context) (common.APIKey, error) {
+		return common.APIKey{ID: "key", Secret: "secret"}, nil
+	}))
+	defer SetCredentialsProvider(nil)
+	initStreamsOnce()
+	defer func(s *datav2stream) { dataStream = s }(dataStream)
+	dataStream = newDatav2Stream()
+
+	// the failed reconnection terminates the streamer instead of panicking
+	s := NewDataStreamer()
+	require.NoError(t, s.Connect(context.Background()))
+	select {
+	case err := <-s.Terminated():
+		assert.EqualError(t, err, "missing connected message")
+	case <-time.After(time.Second):
+		require.Fail(t, "streamer not terminated")
+	}
+	// and the rejected connection is closed
+	select {
+	case status := <-rejectedClosed:
+		assert.Equal(t, websocket.StatusProtocolError, status)
+	case <-time.After(time.Second):
+		require.Fail(t, "rejected connection not closed")
+	}
+
+	// a new streamer reconnects the stream, until it is closed
+	s = NewDataStreamer()
+	require.NoError(t, s.Connect(context.Background()))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&connections))
+	require.NoError(t, s.Close())
+	select {
+	case err := <-s.Terminated():
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		require.Fail(t, "streamer not terminated")
+	}
+	assert.Equal(t, ErrDataStreamerConnected, s.Connect(context.Background()))
+}
@@ -5,7 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
@@ -14,7 +14,9 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
 	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
 	"github.com/vmihailenco/msgpack/v5"
 	"nhooyr.io/websocket"
 )
@@ -27,6 +29,19 @@ var (
 	// MaxConnectionAttempts is the maximum number of retries for connecting to the websocket
 	MaxConnectionAttempts = 3
 
+	// Clock is the clock the package waits on: the delays between connection
+	// attempts, between the retries of a Bridge and between the messages of a
+	// FileReplayClient. It is clock.Real by default.
+	Clock clock.Clock = clock.Real
+
+	// Logger is the logger of the package streams and of the bridges created
+	// without WithLogger. common.DefaultLogger is used if it is nil.
+	Logger common.Logger
+
+	// Application, if set, e.g. "my-bot/2.1", is added to the User-Agent
+	// header of the websocket handshakes, in front of the SDK and its version.
+	Application string
+
 	messageBufferSize = 1000
 )
 
@@ -36,7 +51,8 @@ var (
 
 type datav2stream struct {
 	// opts
-	feed string
+	feed     string
+	overflow BufferOverflow
 
 	// connection flow
 	conn          *websocket.Conn
@@ -47,14 +63,36 @@ type datav2stream struct {
 	tradeHandlers map[string]func(trade Trade)
 	quoteHandlers map[string]func(quote Quote)
 	barHandlers   map[string]func(bar Bar)
+	// dedup drops the messages delivered again, if set, see UseDedupWindow
+	dedup *dedup
+	// subscriptions are the subscriptions confirmed by the server
+	subscriptions subscriptions
+	// feedStatus is the status of the feed reported by the server
+	feedStatus feedStatus
+	// queues are the queues of the handlers per message type, if they have
+	// their own, see UseHandlerQueues
+	queues map[string]chan func()
+
+	// terminated receives the error the reader stopped with, nil if the
+	// stream was closed
+	terminated chan error
 
 	// concurrency
-	readerOnce    sync.Once
+	reading       bool
+	readerMutex   sync.Mutex
 	wsWriteMutex  sync.Mutex
 	wsReadMutex   sync.Mutex
 	handlersMutex sync.RWMutex
 }
 
+// logger returns the logger of the package
+func logger() common.Logger {
+	if Logger != nil {
+		return Logger
+	}
+	return common.DefaultLogger
+}
+
 func newDatav2Stream() *datav2stream {
 	if s := os.Getenv("DATA_PROXY_WS"); s != "" {
 		DataStreamURL = s
@@ -65,6 +103,7 @@ func newDatav2Stream() *datav2stream {
 		tradeHandlers: make(map[string]func(trade Trade)),
 		quoteHandlers: make(map[string]func(quote Quote)),
 		barHandlers:   make(map[string]func(bar Bar)),
+		terminated:    make(chan error, 1),
 	}
 
 	stream.authenticated.Store(false)
@@ -92,6 +131,68 @@ func (s *datav2stream) useFeed(feed string) error {
 	return s.close(false)
 }
 
+func (s *datav2stream) useBufferOverflow(overflow BufferOverflow) error {
+	switch overflow {
+	case BlockOnOverflow, DropOnOverflow, SpillOnOverflow, DropOldestOnOverflow:
+	default:
+		return fmt.Errorf("unsupported buffer overflow: %d", overflow)
+	}
+	if s.conn != nil {
+		return errors.New("the buffer overflow must be set before subscribing")
+	}
+	s.overflow = overflow
+	return nil
+}
+
+func (s *datav2stream) useDedupWindow(window time.Duration) error {
+	if window <= 0 {
+		return fmt.Errorf("invalid dedup window: %v", window)
+	}
+	s.handlersMutex.Lock()
+	defer s.handlersMutex.Unlock()
+	s.dedup = newDedup(window)
+	return nil
+}
+
+func (s *datav2stream) useHandlerQueues(size int) error {
+	if size < 1 {
+		return fmt.Errorf("invalid handler queue size: %d", size)
+	}
+	s.handlersMutex.Lock()
+	defer s.handlersMutex.Unlock()
+	if s.queues != nil {
+		return errors.New("handler queues already in use")
+	}
+
+	s.queues = make(map[string]chan func())
+	for _, T := range []string{"t", "q", "b"} {
+		queue := make(chan func(), size)
+		s.queues[T] = queue
+		go func() {
+			for deliver := range queue {
+				deliver()
+			}
+		}()
+	}
+	return nil
+}
+
+// dispatch calls deliver with the message of type T, from the goroutine of
+// the queue of T if the handlers have their own queues. It must be called
+// with handlersMutex held.
+func (s *datav2stream) dispatch(T string, deliver func()) {
+	queue, ok := s.queues[T]
+	if !ok {
+		deliver()
+		return
+	}
+	select {
+	case queue <- deliver:
+	default:
+		logger().Warn("handler queue full, dropping message", "client", "data_stream", "type", T, "size", cap(queue))
+	}
+}
+
 func (s *datav2stream) subscribeTrades(handler func(trade Trade), symbols ...string) error {
 	if err := s.ensureRunning(); err != nil {
 		return err
@@ -186,11 +287,21 @@ func (s *datav2stream) close(final bool) error {
 		s.closed.Store(true)
 	}
 
-	if err := s.conn.Close(websocket.StatusNormalClosure, ""); err != nil {
-		return err
-	}
+	// the connection is dropped even if it was already broken
+	err := s.conn.Close(websocket.StatusNormalClosure, "")
 	s.conn = nil
-	return nil
+	return err
+}
+
+// connection returns the current connection, or a normal closure error if
+// close dropped it since the last read
+func (s *datav2stream) connection() (*websocket.Conn, error) {
+	s.wsWriteMutex.Lock()
+	defer s.wsWriteMutex.Unlock()
+	if s.conn == nil {
+		return nil, websocket.CloseError{Code: websocket.StatusNormalClosure}
+	}
+	return s.conn, nil
 }
 
 func (s *datav2stream) ensureRunning() error {
@@ -199,11 +310,17 @@ func (s *datav2stream) ensureRunning() error {
 	}
 
 	if err := s.connect(); err != nil {
+		// do not keep a connection that failed to authenticate or subscribe
+		// without a reader
+		s.close(false)
 		return err
 	}
-	s.readerOnce.Do(func() {
-		go s.readForever()
-	})
+	s.readerMutex.Lock()
+	defer s.readerMutex.Unlock()
+	if !s.reading {
+		s.reading = true
+		go s.read()
+	}
 	return nil
 }
 
@@ -216,7 +333,9 @@ func (s *datav2stream) connect() error {
 	if err != nil {
 		return err
 	}
+	s.wsWriteMutex.Lock()
 	s.conn = conn
+	s.wsWriteMutex.Unlock()
 	if err := s.auth(); err != nil {
 		return err
 	}
@@ -235,63 +354,244 @@ func (s *datav2stream) connect() error {
 	return s.sub(trades, quotes, bars)
 }
 
-func (s *datav2stream) readForever() {
+// read reads the stream until it is closed or fails to reconnect. It then
+// lets the next connection start a new reader, and sends the error it
+// stopped with, nil if it was closed, to terminated.
+func (s *datav2stream) read() {
+	var err error
+	if s.overflow == DropOldestOnOverflow {
+		err = s.readForeverRing()
+	} else {
+		err = s.readForever()
+	}
+	if errors.Is(err, errClosed) {
+		err = nil
+	}
+	s.readerMutex.Lock()
+	s.reading = false
+	s.readerMutex.Unlock()
+	select {
+	case s.terminated <- err:
+	default:
+	}
+}
+
+func (s *datav2stream) readForever() error {
 	msgs := make(chan []byte, messageBufferSize)
 	defer close(msgs)
 	go s.handleMessages(msgs)
 
+	var spill *spillQueue
+	if s.overflow == SpillOnOverflow {
+		spill = newSpillQueue()
+		drained := make(chan struct{})
+		go s.drainSpill(spill, msgs, drained)
+		defer func() {
+			spill.close()
+			<-drained
+		}()
+	}
+
 	for {
+		var msgType websocket.MessageType
+		var b []byte
 		s.wsReadMutex.Lock()
-		msgType, b, err := s.conn.Read(context.TODO())
+		conn, err := s.connection()
+		if err == nil {
+			msgType, b, err = conn.Read(context.TODO())
+		}
 		s.wsReadMutex.Unlock()
 
 		if err != nil {
-			if websocket.CloseStatus(err) == websocket.StatusNormalClosure {
-				// if this was a graceful closure, don't reconnect
-				if s.closed.Load().(bool) {
-					return
-				}
-			} else {
-				log.Printf("alpaca stream read error (%v)", err)
+			if err := s.reconnect(err); err != nil {
+				return err
 			}
+		}
+		if msgType != websocket.MessageBinary {
+			continue
+		}
+		s.buffer(msgs, spill, b)
+	}
+}
+
+// readForeverRing is readForever with the DropOldestOnOverflow buffer: the
+// messages are read into a single reused buffer and copied to the ring, so
+// reading them allocates nothing once the ring's slots have grown.
+func (s *datav2stream) readForeverRing() error {
+	ring := newRingBuffer(messageBufferSize)
+	defer ring.close()
+	go s.handleRing(ring)
+
+	var buf bytes.Buffer
+	for {
+		buf.Reset()
+		var msgType websocket.MessageType
+		s.wsReadMutex.Lock()
+		conn, err := s.connection()
+		if err == nil {
+			var r io.Reader
+			msgType, r, err = conn.Reader(context.TODO())
+			if err == nil {
+				_, err = buf.ReadFrom(r)
+			}
+		}
+		s.wsReadMutex.Unlock()
 
-			err := s.connect()
-			if err != nil {
-				panic(err)
+		if err != nil {
+			if err := s.reconnect(err); err != nil {
+				return err
 			}
+			continue
 		}
 		if msgType != websocket.MessageBinary {
 			continue
 		}
+		if ring.push(buf.Bytes()) {
+			logger().Warn("message buffer full, dropping oldest message", "client", "data_stream", "size", messageBufferSize)
+		}
+	}
+}
+
+// errClosed is returned by reconnect when the stream was closed on purpose
+var errClosed = errors.New("stream closed")
+
+// reconnect reconnects the stream after the read error err. It returns
+// errClosed if the stream was closed on purpose and must not be reconnected,
+// or the error it failed to reconnect with, after closing the stream.
+func (s *datav2stream) reconnect(err error) error {
+	if websocket.CloseStatus(err) == websocket.StatusNormalClosure {
+		// if this was a graceful closure, don't reconnect
+		if s.closed.Load().(bool) {
+			return errClosed
+		}
+	} else {
+		logger().Warn("stream read error, reconnecting",
+			"client", "data_stream", "feed", s.feed, "error", err)
+	}
+
+	if err := s.connect(); err != nil {
+		logger().Error("failed to reconnect, stream terminated",
+			"client", "data_stream", "feed", s.feed, "error", err)
+		s.close(false)
+		return err
+	}
+	return nil
+}
+
+// buffer adds b to the messages waiting for the handlers, handling the
+// overflow of the buffer
+func (s *datav2stream) buffer(msgs chan<- []byte, spill *spillQueue, b []byte) {
+	switch s.overflow {
+	case DropOnOverflow:
+		select {
+		case msgs <- b:
+		default:
+			logger().Warn("message buffer full, dropping message", "client", "data_stream", "size", cap(msgs))
+		}
+	case SpillOnOverflow:
+		// the messages go to the file as long as it has any, to keep their order
+		if spill.len() == 0 {
+			select {
+			case msgs <- b:
+				return
+			default:
+			}
+		}
+		if err := spill.push(b); err != nil {
+			logger().Error("failed to spill message, dropping it", "client", "data_stream", "error", err)
+		}
+	default:
 		msgs <- b
 	}
 }
 
+// drainSpill moves the messages of spill to msgs as the handlers catch up
+// until spill is closed, then closes drained
+func (s *datav2stream) drainSpill(spill *spillQueue, msgs chan<- []byte, drained chan<- struct{}) {
+	defer close(drained)
+	for {
+		b, ok, err := spill.next()
+		if err != nil {
+			logger().Error("failed to read spilled message", "client", "data_stream", "error", err)
+			return
+		}
+		if !ok {
+			return
+		}
+		msgs <- b
+		if err := spill.advance(); err != nil {
+			logger().Error("failed to remove spilled message", "client", "data_stream", "error", err)
+			return
+		}
+	}
+}
+
+// handleRing handles the messages of ring until it is closed, popping each
+// into the same buffer
+func (s *datav2stream) handleRing(ring *ringBuffer) {
+	var msg []byte
+	for {
+		var ok bool
+		msg, ok = ring.pop(msg)
+		if !ok {
+			return
+		}
+		if err := s.handleMessage(msg); err != nil {
+			logger().Warn("failed to handle message", "client", "data_stream", "error", err)
+		}
+	}
+}
+
 func (s *datav2stream) handleMessages(msgs <-chan []byte) {
 	for msg := range msgs {
 		if err := s.handleMessage(msg); err != nil {
-			log.Printf("error handling incoming message: %v", err)
+			logger().Warn("failed to handle message", "client", "data_stream", "error", err)
 		}
 	}
 }
 
+// handleMessage handles the messages of the frame b. The messages before
+// the first malformed one, e.g. of a frame truncated by a proxy, are still
+// delivered, and the error of the malformed one is returned.
 func (s *datav2stream) handleMessage(b []byte) error {
+	deliveries, err := s.decodeMessages(b)
+	for _, deliver := range deliveries {
+		deliver()
+	}
+	return err
+}
+
+// decodeMessages decodes the messages of the frame b into the functions
+// delivering them, so that the handlers are called outside of the decoding:
+// a panic of the decoder on malformed input is returned as an error, while
+// those of the handlers are not swallowed.
+func (s *datav2stream) decodeMessages(b []byte) (deliveries []func(), err error) {
 	d := msgpack.GetDecoder()
-	defer msgpack.PutDecoder(d)
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed message: %v", r)
+		}
+		// the decoder keeps the buffer it grew for the length declared by a
+		// malformed frame, e.g. 4 GB for a truncated str32, so it does not go
+		// back to the pool to grow further with the next malformed frames
+		if err == nil {
+			msgpack.PutDecoder(d)
+		}
+	}()
 
 	reader := bytes.NewReader(b)
 	d.Reset(reader)
 
 	arrLen, err := d.DecodeArrayLen()
 	if err != nil || arrLen < 1 {
-		return err
+		return nil, err
 	}
 
 	for i := 0; i < arrLen; i++ {
 		var n int
 		n, err = d.DecodeMapLen()
 		if err != nil {
-			return err
+			return deliveries, err
 		}
 		if n < 1 {
 			continue
@@ -299,49 +599,83 @@ func (s *datav2stream) handleMessage(b []byte) error {
 
 		key, err := d.DecodeString()
 		if err != nil {
-			return err
+			return deliveries, err
 		}
 		if key != "T" {
-			return fmt.Errorf("first key is not T but: %s", key)
+			return deliveries, fmt.Errorf("first key is not T but: %s", key)
 		}
 		T, err := d.DecodeString()
 		if err != nil {
-			return err
+			return deliveries, err
 		}
 		n-- // T already processed
 
+		var deliver func()
 		switch T {
 		case "t":
-			err = s.handleTrade(d, n)
+			deliver, err = s.decodeTrade(d, n)
 		case "q":
-			err = s.handleQuote(d, n)
+			deliver, err = s.decodeQuote(d, n)
 		case "b":
-			err = s.handleBar(d, n)
+			deliver, err = s.decodeBar(d, n)
+		case "subscription":
+			var confirmed *SubscriptionResult
+			if confirmed, err = decodeSubscription(d, n); err == nil {
+				deliver = func() { s.subscriptions.update(confirmed, nil) }
+			}
+		case "status":
+			var status FeedStatus
+			if status, err = decodeFeedStatus(d, n); err == nil {
+				deliver = func() {
+					logger().Info("feed status", "client", "data_stream", "feed", s.feed,
+						"status", status.Status, "message", status.Message)
+					s.feedStatus.set(status)
+				}
+			}
+		case "error":
+			var subErr *SubscriptionError
+			if subErr, err = decodeError(d, n); err == nil {
+				deliver = func() {
+					logger().Warn("stream error", "client", "data_stream", "code", subErr.Code, "error", subErr.Message)
+					s.subscriptions.update(nil, subErr)
+				}
+			}
 		default:
 			err = s.handleOther(d, n)
 		}
 		if err != nil {
-			return err
+			return deliveries, err
+		}
+		if deliver != nil {
+			deliveries = append(deliveries, deliver)
 		}
 	}
 
-	return nil
+	return deliveries, nil
 }
 
-func (s *datav2stream) handleTrade(d *msgpack.Decoder, n int) error {
+// decodeTrade decodes the n fields left of a trade message into the function
+// delivering it, nil if no handler wants it
+func (s *datav2stream) decodeTrade(d *msgpack.Decoder, n int) (func(), error) {
+	if !s.wants("t", "") {
+		return nil, s.handleOther(d, n)
+	}
 	trade := Trade{}
 	for i := 0; i < n; i++ {
 		key, err := d.DecodeString()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		switch key {
 		case "i":
 			trade.ID, err = d.DecodeInt64()
 		case "S":
-			trade.Symbol, err = d.DecodeString()
+			if trade.Symbol, err = d.DecodeString(); err == nil && !s.wants("t", trade.Symbol) {
+				// skip the fields left of the messages no handler wants
+				return nil, s.handleOther(d, n-i-1)
+			}
 		case "x":
-			trade.Exchange, err = d.DecodeString()
+			trade.Exchange, err = decodeExchange(d)
 		case "p":
 			trade.Price, err = d.DecodeFloat64()
 		case "s":
@@ -349,57 +683,60 @@ func (s *datav2stream) handleTrade(d *msgpack.Decoder, n int) error {
 		case "t":
 			trade.Timestamp, err = d.DecodeTime()
 		case "c":
-			var condCount int
-			if condCount, err = d.DecodeArrayLen(); err != nil {
-				return err
-			}
-			trade.Conditions = make([]string, condCount)
-			for c := 0; c < condCount; c++ {
-				if cond, err := d.DecodeString(); err != nil {
-					return err
-				} else {
-					trade.Conditions[c] = cond
-				}
-			}
+			trade.Conditions, err = decodeStrings(d)
 		case "z":
-			trade.Tape, err = d.DecodeString()
+			trade.Tape, err = decodeTape(d)
 		default:
 			err = d.Skip()
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
+	return func() { s.deliverTrade(trade) }, nil
+}
+
+func (s *datav2stream) deliverTrade(trade Trade) {
 	s.handlersMutex.RLock()
 	defer s.handlersMutex.RUnlock()
+	if s.dedup != nil && s.dedup.seenBefore("t", trade.Symbol, trade.Timestamp, trade.ID) {
+		return
+	}
 	handler, ok := s.tradeHandlers[trade.Symbol]
 	if !ok {
 		if handler, ok = s.tradeHandlers["*"]; !ok {
-			return nil
+			return
 		}
 	}
-	handler(trade)
-	return nil
+	s.dispatch("t", func() { handler(trade) })
 }
 
-func (s *datav2stream) handleQuote(d *msgpack.Decoder, n int) error {
+// decodeQuote decodes the n fields left of a quote message into the function
+// delivering it, nil if no handler wants it
+func (s *datav2stream) decodeQuote(d *msgpack.Decoder, n int) (func(), error) {
+	if !s.wants("q", "") {
+		return nil, s.handleOther(d, n)
+	}
 	quote := Quote{}
 	for i := 0; i < n; i++ {
 		key, err := d.DecodeString()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		switch key {
 		case "S":
-			quote.Symbol, err = d.DecodeString()
+			if quote.Symbol, err = d.DecodeString(); err == nil && !s.wants("q", quote.Symbol) {
+				// skip the fields left of the messages no handler wants
+				return nil, s.handleOther(d, n-i-1)
+			}
 		case "bx":
-			quote.BidExchange, err = d.DecodeString()
+			quote.BidExchange, err = decodeExchange(d)
 		case "bp":
 			quote.BidPrice, err = d.DecodeFloat64()
 		case "bs":
 			quote.BidSize, err = d.DecodeUint32()
 		case "ax":
-			quote.AskExchange, err = d.DecodeString()
+			quote.AskExchange, err = decodeExchange(d)
 		case "ap":
 			quote.AskPrice, err = d.DecodeFloat64()
 		case "as":
@@ -407,49 +744,52 @@ func (s *datav2stream) handleQuote(d *msgpack.Decoder, n int) error {
 		case "t":
 			quote.Timestamp, err = d.DecodeTime()
 		case "c":
-			var condCount int
-			if condCount, err = d.DecodeArrayLen(); err != nil {
-				return err
-			}
-			quote.Conditions = make([]string, condCount)
-			for c := 0; c < condCount; c++ {
-				if cond, err := d.DecodeString(); err != nil {
-					return err
-				} else {
-					quote.Conditions[c] = cond
-				}
-			}
+			quote.Conditions, err = decodeStrings(d)
 		case "z":
-			quote.Tape, err = d.DecodeString()
+			quote.Tape, err = decodeTape(d)
 		default:
 			err = d.Skip()
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
+	return func() { s.deliverQuote(quote) }, nil
+}
+
+func (s *datav2stream) deliverQuote(quote Quote) {
 	s.handlersMutex.RLock()
 	defer s.handlersMutex.RUnlock()
+	if s.dedup != nil && s.dedup.seenBefore("q", quote.Symbol, quote.Timestamp, 0) {
+		return
+	}
 	handler, ok := s.quoteHandlers[quote.Symbol]
 	if !ok {
 		if handler, ok = s.quoteHandlers["*"]; !ok {
-			return nil
+			return
 		}
 	}
-	handler(quote)
-	return nil
+	s.dispatch("q", func() { handler(quote) })
 }
 
-func (s *datav2stream) handleBar(d *msgpack.Decoder, n int) error {
+// decodeBar decodes the n fields left of a bar message into the function
+// delivering it, nil if no handler wants it
+func (s *datav2stream) decodeBar(d *msgpack.Decoder, n int) (func(), error) {
+	if !s.wants("b", "") {
+		return nil, s.handleOther(d, n)
+	}
 	bar := Bar{}
 	for i := 0; i < n; i++ {
 		key, err := d.DecodeString()
 		if err != nil {
-			return err
+			return nil, err
 		}
 		switch key {
 		case "S":
-			bar.Symbol, err = d.DecodeString()
+			if bar.Symbol, err = d.DecodeString(); err == nil && !s.wants("b", bar.Symbol) {
+				// skip the fields left of the messages no handler wants
+				return nil, s.handleOther(d, n-i-1)
+			}
 		case "o":
 			bar.Open, err = d.DecodeFloat64()
 		case "h":
@@ -462,23 +802,96 @@ func (s *datav2stream) handleBar(d *msgpack.Decoder, n int) error {
 			bar.Volume, err = d.DecodeUint64()
 		case "t":
 			bar.Timestamp, err = d.DecodeTime()
+		case "n":
+			bar.TradeCount, err = d.DecodeUint64()
+		case "vw":
+			bar.VWAP, err = d.DecodeFloat64()
 		default:
 			err = d.Skip()
 		}
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
+	return func() { s.deliverBar(bar) }, nil
+}
+
+func (s *datav2stream) deliverBar(bar Bar) {
 	s.handlersMutex.RLock()
 	defer s.handlersMutex.RUnlock()
+	if s.dedup != nil && s.dedup.seenBefore("b", bar.Symbol, bar.Timestamp, 0) {
+		return
+	}
 	handler, ok := s.barHandlers[bar.Symbol]
 	if !ok {
 		if handler, ok = s.barHandlers["*"]; !ok {
-			return nil
+			return
 		}
 	}
-	handler(bar)
-	return nil
+	s.dispatch("b", func() { handler(bar) })
+}
+
+func decodeExchange(d *msgpack.Decoder) (v2.Exchange, error) {
+	exchange, err := d.DecodeString()
+	return v2.Exchange(exchange), err
+}
+
+func decodeTape(d *msgpack.Decoder) (v2.Tape, error) {
+	tape, err := d.DecodeString()
+	return v2.Tape(tape), err
+}
+
+// maxStringsPrealloc bounds the strings preallocated by decodeStrings
+const maxStringsPrealloc = 64
+
+// decodeStrings decodes an array of strings, nil for a nil array. The length
+// of the array only preallocates up to maxStringsPrealloc strings, so that
+// the length of a malformed array can't allocate more than the frame holds.
+func decodeStrings(d *msgpack.Decoder) ([]string, error) {
+	n, err := d.DecodeArrayLen()
+	if err != nil || n < 0 {
+		return nil, err
+	}
+	prealloc := n
+	if prealloc > maxStringsPrealloc {
+		prealloc = maxStringsPrealloc
+	}
+	strs := make([]string, 0, prealloc)
+	for i := 0; i < n; i++ {
+		str, err := d.DecodeString()
+		if err != nil {
+			return nil, err
+		}
+		strs = append(strs, str)
+	}
+	return strs, nil
+}
+
+// wants returns true if a handler wants the messages of type T of symbol,
+// or of any symbol if symbol is empty
+func (s *datav2stream) wants(T, symbol string) bool {
+	s.handlersMutex.RLock()
+	defer s.handlersMutex.RUnlock()
+	switch T {
+	case "t":
+		return hasHandler(s.tradeHandlers, symbol)
+	case "q":
+		return hasHandler(s.quoteHandlers, symbol)
+	case "b":
+		return hasHandler(s.barHandlers, symbol)
+	}
+	return false
+}
+
+func hasHandler[H any](handlers map[string]H, symbol string) bool {
+	if symbol == "" {
+		return len(handlers) > 0
+	}
+	if _, ok := handlers[symbol]; ok {
+		return true
+	}
+	_, ok := handlers["*"]
+	return ok
 }
 
 func (s *datav2stream) handleOther(d *msgpack.Decoder, n int) error {
@@ -507,6 +920,8 @@ func (s *datav2stream) handleSubscription(subscribe bool, trades []string, quote
 	if len(trades)+len(quotes)+len(bars) == 0 {
 		return nil
 	}
+	logger().Debug("updating subscriptions", "client", "data_stream", "feed", s.feed, "subscribe", subscribe,
+		"trades", len(trades), "quotes", len(quotes), "bars", len(bars))
 
 	action := "subscribe"
 	if !subscribe {
@@ -526,6 +941,8 @@ func (s *datav2stream) handleSubscription(subscribe bool, trades []string, quote
 	s.wsWriteMutex.Lock()
 	defer s.wsWriteMutex.Unlock()
 
+	// the errors of the previous changes are outdated
+	s.subscriptions.update(nil, nil)
 	if err := s.conn.Write(context.TODO(), websocket.MessageBinary, msg); err != nil {
 		return err
 	}
@@ -542,11 +959,11 @@ func (s *datav2stream) auth() (err error) {
 		return
 	}
 
-	msg, err := msgpack.Marshal(map[string]string{
-		"action": "auth",
-		"key":    common.Credentials().ID,
-		"secret": common.Credentials().Secret,
-	})
+	credentials, err := streamCredentials()
+	if err != nil {
+		return err
+	}
+	msg, err := msgpack.Marshal(authMsg(credentials))
 	if err != nil {
 		return err
 	}
@@ -605,16 +1022,22 @@ func openSocket(feed string) (*websocket.Conn, error) {
 			CompressionMode: websocket.CompressionContextTakeover,
 			HTTPHeader: http.Header{
 				"Content-Type": []string{"application/msgpack"},
+				"User-Agent":   []string{common.UserAgent(Application)},
 			},
 		})
 		if err == nil {
-			return c, readConnected(c)
+			if err := readConnected(c); err != nil {
+				c.Close(websocket.StatusProtocolError, "")
+				return nil, err
+			}
+			return c, nil
 		}
-		log.Printf("failed to open Alpaca data stream: %v", err)
+		logger().Warn("failed to open stream", "client", "data_stream", "feed", feed,
+			"attempt", attempts, "max_attempts", MaxConnectionAttempts, "error", err)
 		if attempts == MaxConnectionAttempts {
 			return nil, err
 		}
-		time.Sleep(time.Second)
+		Clock.Sleep(time.Second)
 	}
 	return nil, errors.New("could not open Alpaca data stream (max retries exceeded)")
 }
@@ -1,9 +1,13 @@
 package stream
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"testing"
 	"time"
 
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/vmihailenco/msgpack/v5"
@@ -43,16 +47,18 @@ type quoteWithT struct {
 
 // barWithT is the incoming bar message that also contains the T type key
 type barWithT struct {
-	Type      string    `msgpack:"T"`
-	Symbol    string    `msgpack:"S"`
-	Open      float64   `msgpack:"o"`
-	High      float64   `msgpack:"h"`
-	Low       float64   `msgpack:"l"`
-	Close     float64   `msgpack:"c"`
-	Volume    uint64    `msgpack:"v"`
-	Timestamp time.Time `msgpack:"t"`
+	Type       string    `msgpack:"T"`
+	Symbol     string    `msgpack:"S"`
+	Open       float64   `msgpack:"o"`
+	High       float64   `msgpack:"h"`
+	Low        float64   `msgpack:"l"`
+	Close      float64   `msgpack:"c"`
+	Volume     uint64    `msgpack:"v"`
+	Timestamp  time.Time `msgpack:"t"`
+	TradeCount uint64    `msgpack:"n"`
+	VWAP       float64   `msgpack:"vw"`
 	// NewField is for testing correct handling of added fields in the future
-	NewField uint64 `msgpack:"n"`
+	NewField uint64 `msgpack:"nf"`
 }
 
 type other struct {
@@ -89,14 +95,16 @@ var testQuote = quoteWithT{
 }
 
 var testBar = barWithT{
-	Type:      "b",
-	Symbol:    "TEST",
-	Open:      100,
-	High:      101.2,
-	Low:       98.67,
-	Close:     101.1,
-	Volume:    2560,
-	Timestamp: time.Date(2021, 03, 05, 16, 0, 0, 0, time.UTC),
+	Type:       "b",
+	Symbol:     "TEST",
+	Open:       100,
+	High:       101.2,
+	Low:        98.67,
+	Close:      101.1,
+	Volume:     2560,
+	Timestamp:  time.Date(2021, 03, 05, 16, 0, 0, 0, time.UTC),
+	TradeCount: 38,
+	VWAP:       100.52,
 }
 
 var testOther = other{
@@ -150,6 +158,11 @@ func TestHandleMessages(t *testing.T) {
 	assert.True(t, quote.Timestamp.Equal(testTime))
 	assert.EqualValues(t, []string{"R"}, quote.Conditions)
 	assert.EqualValues(t, "B", quote.Tape)
+	assert.Equal(t, v2.ExchangeNasdaqPSX, trade.Exchange)
+	assert.Equal(t, "Nasdaq PSX", trade.Exchange.String())
+	assert.Equal(t, v2.TapeB, quote.Tape)
+	assert.True(t, quote.HasCondition(v2.QuoteRegular))
+	assert.False(t, trade.HasCondition(v2.TradeRegularSale))
 
 	assert.EqualValues(t, "TEST", bar.Symbol)
 	assert.EqualValues(t, 100, bar.Open)
@@ -157,6 +170,142 @@ func TestHandleMessages(t *testing.T) {
 	assert.EqualValues(t, 98.67, bar.Low)
 	assert.EqualValues(t, 101.1, bar.Close)
 	assert.EqualValues(t, 2560, bar.Volume)
+	assert.EqualValues(t, 38, bar.TradeCount)
+	assert.EqualValues(t, 100.52, bar.VWAP)
+}
+
+func TestHandlerQueues(t *testing.T) {
+	s := &datav2stream{}
+	release := make(chan struct{})
+	bars := make(chan Bar, 10)
+	s.barHandlers = map[string]func(bar Bar){
+		"*": func(bar Bar) {
+			<-release
+			bars <- bar
+		},
+	}
+	trades := make(chan Trade, 10)
+	s.tradeHandlers = map[string]func(trade Trade){
+		"*": func(trade Trade) {
+			trades <- trade
+		},
+	}
+	require.Error(t, s.useHandlerQueues(0))
+	require.NoError(t, s.useHandlerQueues(1))
+	require.Error(t, s.useHandlerQueues(1))
+
+	barMsg, err := msgpack.Marshal([]interface{}{testBar})
+	require.NoError(t, err)
+	tradeMsg, err := msgpack.Marshal([]interface{}{testTrade})
+	require.NoError(t, err)
+
+	// the first bar blocks its handler, the second waits in the queue and
+	// the third is dropped
+	require.NoError(t, s.handleMessage(barMsg))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, s.handleMessage(barMsg))
+	require.NoError(t, s.handleMessage(barMsg))
+
+	// the trades are delivered meanwhile
+	require.NoError(t, s.handleMessage(tradeMsg))
+	select {
+	case trade := <-trades:
+		assert.EqualValues(t, 42, trade.ID)
+	case <-time.After(time.Second):
+		t.Fatal("trade not delivered while the bar handler is blocked")
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-bars:
+		case <-time.After(time.Second):
+			t.Fatal("bar not delivered")
+		}
+	}
+	select {
+	case <-bars:
+		t.Fatal("bar delivered from a full queue")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestDedupWindow(t *testing.T) {
+	s := &datav2stream{}
+	var trades []Trade
+	s.tradeHandlers = map[string]func(trade Trade){
+		"*": func(trade Trade) {
+			trades = append(trades, trade)
+		},
+	}
+	var bars []Bar
+	s.barHandlers = map[string]func(bar Bar){
+		"*": func(bar Bar) {
+			bars = append(bars, bar)
+		},
+	}
+	require.Error(t, s.useDedupWindow(0))
+	require.NoError(t, s.useDedupWindow(time.Minute))
+
+	next := testTrade
+	next.ID++
+	later := testTrade
+	later.ID = 100
+	later.Timestamp = testTime.Add(2 * time.Minute)
+	for _, msgs := range [][]interface{}{
+		{testTrade, testBar},
+		// resent after a reconnect
+		{testTrade, next, testBar},
+		// the first trade is out of the window once a later one is seen
+		{later, testTrade},
+	} {
+		b, err := msgpack.Marshal(msgs)
+		require.NoError(t, err)
+		require.NoError(t, s.handleMessage(b))
+	}
+
+	require.Len(t, trades, 4)
+	assert.EqualValues(t, []int64{42, 43, 100, 42}, []int64{trades[0].ID, trades[1].ID, trades[2].ID, trades[3].ID})
+	assert.Len(t, bars, 1)
+}
+
+func TestSkipUnwantedMessages(t *testing.T) {
+	other := testTrade
+	other.Symbol = "OTHER"
+	b, err := msgpack.Marshal([]interface{}{other, testQuote, testBar, testTrade})
+	require.NoError(t, err)
+
+	s := &datav2stream{}
+	var trades []Trade
+	s.tradeHandlers = map[string]func(trade Trade){
+		"TEST": func(got Trade) {
+			trades = append(trades, got)
+		},
+	}
+	assert.True(t, s.wants("t", ""))
+	assert.False(t, s.wants("t", "OTHER"))
+	assert.False(t, s.wants("q", ""))
+
+	// the skipped messages don't break the decoding of the next ones
+	require.NoError(t, s.handleMessage(b))
+	require.Len(t, trades, 1)
+	assert.EqualValues(t, "TEST", trades[0].Symbol)
+	assert.EqualValues(t, "A", trades[0].Tape)
+}
+
+func BenchmarkHandleUnwantedMessages(b *testing.B) {
+	msgs, _ := msgpack.Marshal([]interface{}{testTrade, testQuote, testBar})
+	s := &datav2stream{
+		tradeHandlers: map[string]func(trade Trade){
+			"OTHER": func(trade Trade) {},
+		},
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		s.handleMessage(msgs)
+	}
 }
 
 func BenchmarkHandleMessages(b *testing.B) {
@@ -179,3 +328,190 @@ func BenchmarkHandleMessages(b *testing.B) {
 		s.handleMessage(msgs)
 	}
 }
+
+func TestWaitForSubscriptions(t *testing.T) {
+	s := &datav2stream{}
+	expected := SubscriptionResult{Trades: []string{"AAPL"}, Bars: []string{"*"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := s.subscriptions.wait(ctx, expected)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	type subscription struct {
+		Type   string   `msgpack:"T"`
+		Trades []string `msgpack:"trades"`
+		Quotes []string `msgpack:"quotes"`
+		Bars   []string `msgpack:"bars"`
+	}
+	done := make(chan SubscriptionResult)
+	go func() {
+		confirmed, err := s.subscriptions.wait(context.Background(), expected)
+		assert.NoError(t, err)
+		done <- confirmed
+	}()
+	for _, msg := range []subscription{
+		{Type: "subscription", Trades: []string{"AAPL"}},
+		{Type: "subscription", Trades: []string{"AAPL", "MSFT"}, Quotes: []string{}, Bars: []string{"*"}},
+	} {
+		b, err := msgpack.Marshal([]interface{}{msg})
+		require.NoError(t, err)
+		require.NoError(t, s.handleMessage(b))
+	}
+	select {
+	case confirmed := <-done:
+		assert.Equal(t, []string{"AAPL", "MSFT"}, confirmed.Trades)
+		assert.Equal(t, []string{"*"}, confirmed.Bars)
+	case <-time.After(time.Second):
+		t.Fatal("subscriptions not confirmed")
+	}
+
+	// the errors of the server stop the wait
+	type errorMsg struct {
+		Type    string `msgpack:"T"`
+		Code    int    `msgpack:"code"`
+		Message string `msgpack:"msg"`
+	}
+	b, err := msgpack.Marshal([]interface{}{errorMsg{Type: "error", Code: 405, Message: "symbol limit exceeded"}})
+	require.NoError(t, err)
+	require.NoError(t, s.handleMessage(b))
+	_, err = s.subscriptions.wait(context.Background(), SubscriptionResult{Quotes: []string{"TSLA"}})
+	var subErr *SubscriptionError
+	require.True(t, errors.As(err, &subErr))
+	assert.Equal(t, 405, subErr.Code)
+	assert.Equal(t, []string{"AAPL", "MSFT"}, s.subscriptions.get().Trades)
+}
+
+func TestFeedStatus(t *testing.T) {
+	s := &datav2stream{}
+	var statuses []FeedStatus
+	s.feedStatus.setHandler(func(status FeedStatus) {
+		statuses = append(statuses, status)
+	})
+	assert.Equal(t, "", s.feedStatus.get().Status)
+
+	type statusMsg struct {
+		Type      string    `msgpack:"T"`
+		Status    string    `msgpack:"status"`
+		Message   string    `msgpack:"msg"`
+		Timestamp time.Time `msgpack:"t"`
+	}
+	for _, msg := range []statusMsg{
+		{Type: "status", Status: FeedDegraded, Message: "delayed quotes from NYSE", Timestamp: testTime},
+		// unchanged
+		{Type: "status", Status: FeedDegraded, Message: "delayed quotes from NYSE", Timestamp: testTime.Add(time.Minute)},
+		{Type: "status", Status: FeedOK, Timestamp: testTime.Add(time.Hour)},
+	} {
+		b, err := msgpack.Marshal([]interface{}{msg})
+		require.NoError(t, err)
+		require.NoError(t, s.handleMessage(b))
+	}
+
+	require.Len(t, statuses, 2)
+	assert.Equal(t, FeedDegraded, statuses[0].Status)
+	assert.Equal(t, "delayed quotes from NYSE", statuses[0].Message)
+	assert.True(t, statuses[0].Timestamp.Equal(testTime))
+	assert.Equal(t, FeedOK, s.feedStatus.get().Status)
+}
+
+// truncatedStr32 is a trade whose symbol is a str32 of 0xfffffff0 bytes
+// truncated to 4 bytes
+var truncatedStr32 = []byte{
+	0x91, 0x83,
+	0xa1, 'T', 0xa1, 't',
+	0xa1, 'S', 0xdb, 0xff, 0xff, 0xff, 0xf0, 'T', 'E', 'S', 'T',
+}
+
+func TestMalformedMessages(t *testing.T) {
+	var trades []Trade
+	s := &datav2stream{
+		tradeHandlers: map[string]func(trade Trade){
+			"*": func(trade Trade) {
+				trades = append(trades, trade)
+			},
+		},
+	}
+
+	// a nil array of conditions
+	noConditions := testTrade
+	noConditions.Conditions = nil
+	b, err := msgpack.Marshal([]interface{}{noConditions})
+	require.NoError(t, err)
+	require.NoError(t, s.handleMessage(b))
+	require.Len(t, trades, 1)
+	assert.Nil(t, trades[0].Conditions)
+
+	// an array of conditions announcing more strings than the frame holds
+	var buf bytes.Buffer
+	e := msgpack.NewEncoder(&buf)
+	require.NoError(t, e.EncodeArrayLen(1))
+	require.NoError(t, e.EncodeMapLen(3))
+	for _, str := range []string{"T", "t", "S", "TEST", "c"} {
+		require.NoError(t, e.EncodeString(str))
+	}
+	buf.Write([]byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	require.NoError(t, e.EncodeString(" "))
+	assert.Error(t, s.handleMessage(buf.Bytes()))
+	assert.Len(t, trades, 1)
+
+	// a symbol announcing about 4 GB, that the decoder would keep growing
+	// its buffer for if it went back to the pool
+	for i := 0; i < 64; i++ {
+		assert.Error(t, s.handleMessage(truncatedStr32))
+	}
+	assert.Len(t, trades, 1)
+
+	// the messages before the truncation are delivered
+	trades = nil
+	b, err = msgpack.Marshal([]interface{}{testTrade, testTrade})
+	require.NoError(t, err)
+	assert.Error(t, s.handleMessage(b[:len(b)-5]))
+	assert.Len(t, trades, 1)
+
+	// the panics of the handlers are not swallowed
+	s.tradeHandlers["*"] = func(trade Trade) {
+		panic("handler")
+	}
+	assert.PanicsWithValue(t, "handler", func() {
+		s.handleMessage(b)
+	})
+}
+
+func FuzzHandleMessage(f *testing.F) {
+	type subscriptionMsg struct {
+		Type   string   `msgpack:"T"`
+		Trades []string `msgpack:"trades"`
+		Bars   []string `msgpack:"bars"`
+	}
+	type statusMsg struct {
+		Type   string `msgpack:"T"`
+		Status string `msgpack:"status"`
+	}
+	for _, msg := range []interface{}{
+		testTrade, testQuote, testBar, testOther,
+		subscriptionMsg{Type: "subscription", Trades: []string{"AAPL"}, Bars: []string{"*"}},
+		statusMsg{Type: "status", Status: FeedDegraded},
+	} {
+		b, err := msgpack.Marshal([]interface{}{msg})
+		require.NoError(f, err)
+		f.Add(b)
+		f.Add(b[:len(b)/2])
+	}
+	f.Add(truncatedStr32)
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		s := &datav2stream{
+			tradeHandlers: map[string]func(trade Trade){
+				"*": func(trade Trade) {},
+			},
+			quoteHandlers: map[string]func(quote Quote){
+				"*": func(quote Quote) {},
+			},
+			barHandlers: map[string]func(bar Bar){
+				"*": func(bar Bar) {},
+			},
+		}
+		// a malformed frame is an error, never a panic
+		s.handleMessage(b)
+	})
+}
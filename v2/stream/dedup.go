@@ -0,0 +1,70 @@
+package stream
+
+import (
+	"sync"
+	"time"
+)
+
+// dedupKey identifies a message of the data stream of a given type
+type dedupKey struct {
+	symbol    string
+	timestamp int64
+	id        int64
+}
+
+// dedupSeries are the messages of a type remembered by a dedup
+type dedupSeries struct {
+	seen   map[dedupKey]struct{}
+	order  []dedupKey
+	newest int64
+}
+
+// dedup remembers the messages of the data stream whose timestamps are
+// within a window of the newest one of their type, to drop those delivered
+// again after a reconnect
+type dedup struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	series map[string]*dedupSeries
+}
+
+func newDedup(window time.Duration) *dedup {
+	return &dedup{
+		window: window,
+		series: make(map[string]*dedupSeries),
+	}
+}
+
+// seenBefore records the message of type T and returns true if it was
+// already recorded
+func (d *dedup) seenBefore(T, symbol string, timestamp time.Time, id int64) bool {
+	key := dedupKey{symbol: symbol, timestamp: timestamp.UnixNano(), id: id}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	series, ok := d.series[T]
+	if !ok {
+		series = &dedupSeries{seen: make(map[dedupKey]struct{})}
+		d.series[T] = series
+	}
+	if _, ok := series.seen[key]; ok {
+		return true
+	}
+	series.seen[key] = struct{}{}
+	series.order = append(series.order, key)
+	if key.timestamp > series.newest {
+		series.newest = key.timestamp
+	}
+
+	// forget the messages out of the window, oldest recorded first
+	oldest := series.newest - int64(d.window)
+	i := 0
+	for ; i < len(series.order) && series.order[i].timestamp < oldest; i++ {
+		delete(series.seen, series.order[i])
+	}
+	if i > 0 {
+		series.order = append(series.order[:0], series.order[i:]...)
+	}
+	return false
+}
@@ -1,31 +1,47 @@
 package stream
 
-import "time"
+import (
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+	"github.com/shopspring/decimal"
+)
 
 // Trade is a stock trade that happened on the market
 type Trade struct {
 	ID         int64
 	Symbol     string
-	Exchange   string
+	Exchange   v2.Exchange
 	Price      float64
 	Size       uint32
 	Timestamp  time.Time
 	Conditions []string
-	Tape       string
+	Tape       v2.Tape
+}
+
+// HasCondition returns true if the trade has condition c
+func (t Trade) HasCondition(c v2.TradeCondition) bool {
+	return v2.HasTradeCondition(t.Conditions, c)
 }
 
 // Quote is a stock quote from the market
 type Quote struct {
 	Symbol      string
-	BidExchange string
+	BidExchange v2.Exchange
 	BidPrice    float64
 	BidSize     uint32
-	AskExchange string
+	AskExchange v2.Exchange
 	AskPrice    float64
 	AskSize     uint32
 	Timestamp   time.Time
 	Conditions  []string
-	Tape        string
+	Tape        v2.Tape
+}
+
+// HasCondition returns true if the quote has condition c
+func (q Quote) HasCondition(c v2.QuoteCondition) bool {
+	return v2.HasQuoteCondition(q.Conditions, c)
 }
 
 // Bar is an aggregate of trades
@@ -37,4 +53,67 @@ type Bar struct {
 	Close     float64
 	Volume    uint64
 	Timestamp time.Time
+	// TradeCount is the number of trades aggregated by the bar
+	TradeCount uint64
+	// VWAP is the volume-weighted average price of the trades of the bar
+	VWAP float64
+}
+
+// TradeUpdateEvent is a typed update on one of the account's orders.
+// The concrete type is one of FillEvent, CancelEvent, ReplaceEvent or OrderEvent.
+type TradeUpdateEvent interface {
+	GetEvent() alpaca.TradeEvent
+	GetOrder() alpaca.Order
+	GetTimestamp() time.Time
+}
+
+// OrderEvent is a trade update without any event specific payload
+type OrderEvent struct {
+	// EventID is set when the server sends one
+	EventID   string
+	Event     alpaca.TradeEvent
+	Order     alpaca.Order
+	Timestamp time.Time
+}
+
+// GetEvent returns the event type
+func (e OrderEvent) GetEvent() alpaca.TradeEvent {
+	return e.Event
+}
+
+// GetOrder returns the order the event is about
+func (e OrderEvent) GetOrder() alpaca.Order {
+	return e.Order
+}
+
+// GetTimestamp returns the time the event happened
+func (e OrderEvent) GetTimestamp() time.Time {
+	return e.Timestamp
+}
+
+// FillEvent is sent when an order is partially or fully filled.
+// The commission charged for the order so far is in Order.Commission.
+type FillEvent struct {
+	OrderEvent
+	ExecutionID string
+	Price       decimal.Decimal
+	Qty         decimal.Decimal
+	PositionQty decimal.Decimal
+}
+
+// IsPartial returns true if the order still has some unfilled quantity
+func (e FillEvent) IsPartial() bool {
+	return e.Event == alpaca.EventPartialFill
+}
+
+// CancelEvent is sent when an order is canceled, expired or rejected
+type CancelEvent struct {
+	OrderEvent
+}
+
+// ReplaceEvent is sent when an order is replaced. Order is the replaced
+// (original) order and ReplacedBy is the ID of the order replacing it.
+type ReplaceEvent struct {
+	OrderEvent
+	ReplacedBy string
 }
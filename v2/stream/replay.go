@@ -0,0 +1,443 @@
+package stream
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+)
+
+// ErrReplayStarted is returned when a FileReplayClient is connected twice
+// or subscribed to after it was connected
+var ErrReplayStarted = errors.New("replay already started")
+
+// replayed is a trade, quote or bar read from a recorded file
+type replayed struct {
+	timestamp time.Time
+	trade     *Trade
+	quote     *Quote
+	bar       *Bar
+}
+
+// FileReplayClient replays the trades, quotes and bars recorded to CSV files
+// by the recorder package to the same handlers as the live data stream, so
+// a strategy can be regression tested against captured sessions. Its
+// subscription methods mirror the functions of this package.
+type FileReplayClient struct {
+	paths []string
+	speed float64
+
+	mu            sync.Mutex
+	started       bool
+	tradeHandlers map[string]func(trade Trade)
+	quoteHandlers map[string]func(quote Quote)
+	barHandlers   map[string]func(bar Bar)
+	cancel        context.CancelFunc
+	terminated    chan error
+}
+
+// NewFileReplayClient creates a client replaying the recorded files at paths.
+// A directory path replays every trades.csv, quotes.csv and bars.csv below it.
+func NewFileReplayClient(paths ...string) *FileReplayClient {
+	return &FileReplayClient{
+		paths:         paths,
+		speed:         1,
+		tradeHandlers: map[string]func(trade Trade){},
+		quoteHandlers: map[string]func(quote Quote){},
+		barHandlers:   map[string]func(bar Bar){},
+		terminated:    make(chan error, 1),
+	}
+}
+
+// SetSpeed sets the speed multiplier of the replay, applied to the time
+// between two recorded messages: 1 (the default) replays in real time, 10
+// ten times faster and 0 as fast as possible. It must be called before Connect.
+func (c *FileReplayClient) SetSpeed(multiplier float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.speed = multiplier
+}
+
+// SubscribeTrades replays the trades of symbols ("*" for all) to handler.
+func (c *FileReplayClient) SubscribeTrades(handler func(trade Trade), symbols ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return ErrReplayStarted
+	}
+	for _, symbol := range symbols {
+		c.tradeHandlers[symbol] = handler
+	}
+	return nil
+}
+
+// SubscribeQuotes replays the quotes of symbols ("*" for all) to handler.
+func (c *FileReplayClient) SubscribeQuotes(handler func(quote Quote), symbols ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return ErrReplayStarted
+	}
+	for _, symbol := range symbols {
+		c.quoteHandlers[symbol] = handler
+	}
+	return nil
+}
+
+// SubscribeBars replays the bars of symbols ("*" for all) to handler.
+func (c *FileReplayClient) SubscribeBars(handler func(bar Bar), symbols ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.started {
+		return ErrReplayStarted
+	}
+	for _, symbol := range symbols {
+		c.barHandlers[symbol] = handler
+	}
+	return nil
+}
+
+// UnsubscribeTrades stops replaying the trades of symbols.
+func (c *FileReplayClient) UnsubscribeTrades(symbols ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, symbol := range symbols {
+		delete(c.tradeHandlers, symbol)
+	}
+	return nil
+}
+
+// UnsubscribeQuotes stops replaying the quotes of symbols.
+func (c *FileReplayClient) UnsubscribeQuotes(symbols ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, symbol := range symbols {
+		delete(c.quoteHandlers, symbol)
+	}
+	return nil
+}
+
+// UnsubscribeBars stops replaying the bars of symbols.
+func (c *FileReplayClient) UnsubscribeBars(symbols ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, symbol := range symbols {
+		delete(c.barHandlers, symbol)
+	}
+	return nil
+}
+
+// Connect reads the recorded files and starts replaying them in time order.
+// It returns an error if a file cannot be read. Terminated reports the end
+// of the replay.
+func (c *FileReplayClient) Connect(ctx context.Context) error {
+	c.mu.Lock()
+	if c.started {
+		c.mu.Unlock()
+		return ErrReplayStarted
+	}
+	c.started = true
+	speed := c.speed
+	c.mu.Unlock()
+
+	msgs, err := readRecorded(c.paths)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	c.cancel = cancel
+	c.mu.Unlock()
+	go func() {
+		defer cancel()
+		c.terminated <- c.replay(ctx, msgs, speed)
+	}()
+	return nil
+}
+
+// Terminated returns a channel the client sends nil to once every message
+// was replayed, or the error of the context once it is canceled or Close
+// is called.
+func (c *FileReplayClient) Terminated() <-chan error {
+	return c.terminated
+}
+
+// Close stops the replay.
+func (c *FileReplayClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+	}
+	return nil
+}
+
+func (c *FileReplayClient) replay(ctx context.Context, msgs []replayed, speed float64) error {
+	var prev time.Time
+	for i, msg := range msgs {
+		if i > 0 && speed > 0 {
+			if wait := time.Duration(float64(msg.timestamp.Sub(prev)) / speed); wait > 0 {
+				timer := Clock.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C():
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		prev = msg.timestamp
+		c.dispatch(msg)
+	}
+	return nil
+}
+
+func (c *FileReplayClient) dispatch(msg replayed) {
+	c.mu.Lock()
+	var handle func()
+	switch {
+	case msg.trade != nil:
+		if handler := lookupTrade(c.tradeHandlers, msg.trade.Symbol); handler != nil {
+			handle = func() { handler(*msg.trade) }
+		}
+	case msg.quote != nil:
+		if handler := lookupQuote(c.quoteHandlers, msg.quote.Symbol); handler != nil {
+			handle = func() { handler(*msg.quote) }
+		}
+	default:
+		if handler := lookupBar(c.barHandlers, msg.bar.Symbol); handler != nil {
+			handle = func() { handler(*msg.bar) }
+		}
+	}
+	c.mu.Unlock()
+
+	if handle != nil {
+		handle()
+	}
+}
+
+func lookupTrade(handlers map[string]func(trade Trade), symbol string) func(trade Trade) {
+	if handler, ok := handlers[symbol]; ok {
+		return handler
+	}
+	return handlers["*"]
+}
+
+func lookupQuote(handlers map[string]func(quote Quote), symbol string) func(quote Quote) {
+	if handler, ok := handlers[symbol]; ok {
+		return handler
+	}
+	return handlers["*"]
+}
+
+func lookupBar(handlers map[string]func(bar Bar), symbol string) func(bar Bar) {
+	if handler, ok := handlers[symbol]; ok {
+		return handler
+	}
+	return handlers["*"]
+}
+
+// readRecorded reads the recorded files at paths, sorted by time
+func readRecorded(paths []string) ([]replayed, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			switch info.Name() {
+			case "trades.csv", "quotes.csv", "bars.csv":
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var msgs []replayed
+	for _, file := range files {
+		m, err := readRecordedFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		msgs = append(msgs, m...)
+	}
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].timestamp.Before(msgs[j].timestamp)
+	})
+	return msgs, nil
+}
+
+// readRecordedFile reads a recorded file, whose kind of data is told
+// by the columns of its header
+func readRecordedFile(path string) ([]replayed, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	var parse func(rec *record) replayed
+	switch {
+	case has(columns, "bid_price"):
+		parse = parseQuote
+	case has(columns, "open"):
+		parse = parseBar
+	case has(columns, "price"):
+		parse = parseTrade
+	default:
+		return nil, errors.New("unknown recorded data")
+	}
+
+	var msgs []replayed
+	for {
+		fields, err := r.Read()
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rec := &record{columns: columns, fields: fields}
+		msg := parse(rec)
+		if rec.err != nil {
+			return nil, rec.err
+		}
+		msgs = append(msgs, msg)
+	}
+}
+
+func has(columns map[string]int, name string) bool {
+	_, ok := columns[name]
+	return ok
+}
+
+// record reads the columns of a CSV record, keeping the first parse error
+type record struct {
+	columns map[string]int
+	fields  []string
+	err     error
+}
+
+func (r *record) str(name string) string {
+	i, ok := r.columns[name]
+	if !ok || i >= len(r.fields) {
+		return ""
+	}
+	return r.fields[i]
+}
+
+func (r *record) time(name string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, r.str(name))
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return t
+}
+
+func (r *record) float(name string) float64 {
+	s := r.str(name)
+	if s == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return f
+}
+
+func (r *record) uint(name string, bits int) uint64 {
+	s := r.str(name)
+	if s == "" {
+		return 0
+	}
+	u, err := strconv.ParseUint(s, 10, bits)
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return u
+}
+
+func (r *record) conditions() []string {
+	if s := r.str("conditions"); s != "" {
+		return strings.Split(s, "|")
+	}
+	return nil
+}
+
+func parseTrade(r *record) replayed {
+	t := &Trade{
+		ID:         int64(r.uint("id", 63)),
+		Symbol:     r.str("symbol"),
+		Exchange:   v2.Exchange(r.str("exchange")),
+		Price:      r.float("price"),
+		Size:       uint32(r.uint("size", 32)),
+		Timestamp:  r.time("timestamp"),
+		Conditions: r.conditions(),
+		Tape:       v2.Tape(r.str("tape")),
+	}
+	return replayed{timestamp: t.Timestamp, trade: t}
+}
+
+func parseQuote(r *record) replayed {
+	q := &Quote{
+		Symbol:      r.str("symbol"),
+		BidExchange: v2.Exchange(r.str("bid_exchange")),
+		BidPrice:    r.float("bid_price"),
+		BidSize:     uint32(r.uint("bid_size", 32)),
+		AskExchange: v2.Exchange(r.str("ask_exchange")),
+		AskPrice:    r.float("ask_price"),
+		AskSize:     uint32(r.uint("ask_size", 32)),
+		Timestamp:   r.time("timestamp"),
+		Conditions:  r.conditions(),
+		Tape:        v2.Tape(r.str("tape")),
+	}
+	return replayed{timestamp: q.Timestamp, quote: q}
+}
+
+func parseBar(r *record) replayed {
+	b := &Bar{
+		Symbol:    r.str("symbol"),
+		Open:      r.float("open"),
+		High:      r.float("high"),
+		Low:       r.float("low"),
+		Close:     r.float("close"),
+		Volume:    r.uint("volume", 64),
+		Timestamp: r.time("timestamp"),
+	}
+	return replayed{timestamp: b.Timestamp, bar: b}
+}
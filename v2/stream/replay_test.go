@@ -0,0 +1,121 @@
+package stream
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRecorded(t *testing.T, dir, symbol, date, name, content string) {
+	path := filepath.Join(dir, symbol, date, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+}
+
+func TestFileReplayClient(t *testing.T) {
+	dir := t.TempDir()
+	writeRecorded(t, dir, "AAPL", "2021-03-02", "trades.csv",
+		"symbol,timestamp,id,exchange,price,size,conditions,tape\n"+
+			"AAPL,2021-03-02T14:30:00Z,1,Q,120.25,100,@|I,C\n"+
+			"AAPL,2021-03-02T14:30:00.2Z,2,Q,120.5,10,,C\n")
+	writeRecorded(t, dir, "AAPL", "2021-03-02", "quotes.csv",
+		"symbol,timestamp,bid_exchange,bid_price,bid_size,ask_exchange,ask_price,ask_size,conditions,tape\n"+
+			"AAPL,2021-03-02T14:30:00.1Z,Q,120.2,1,P,120.3,2,R,C\n")
+	writeRecorded(t, dir, "MSFT", "2021-03-02", "bars.csv",
+		"symbol,timestamp,open,high,low,close,volume\n"+
+			"MSFT,2021-03-02T14:30:00.15Z,1,2,0.5,1.5,1000\n")
+
+	c := NewFileReplayClient(dir)
+	c.SetSpeed(2)
+	var got []string
+	require.NoError(t, c.SubscribeTrades(func(trade Trade) {
+		got = append(got, "trade")
+		if trade.ID == 1 {
+			assert.Equal(t, "AAPL", trade.Symbol)
+			assert.Equal(t, 120.25, trade.Price)
+			assert.Equal(t, uint32(100), trade.Size)
+			assert.Equal(t, []string{"@", "I"}, trade.Conditions)
+		}
+	}, "AAPL"))
+	require.NoError(t, c.SubscribeQuotes(func(quote Quote) {
+		got = append(got, "quote")
+		assert.Equal(t, 120.3, quote.AskPrice)
+	}, "*"))
+	require.NoError(t, c.SubscribeBars(func(bar Bar) {
+		got = append(got, "bar")
+		assert.Equal(t, uint64(1000), bar.Volume)
+	}, "MSFT"))
+
+	start := time.Now()
+	require.NoError(t, c.Connect(context.Background()))
+	assert.Equal(t, ErrReplayStarted, c.Connect(context.Background()))
+	assert.Equal(t, ErrReplayStarted, c.SubscribeBars(func(Bar) {}, "AAPL"))
+	select {
+	case err := <-c.Terminated():
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		require.Fail(t, "replay not terminated")
+	}
+	// 200ms of recorded messages replayed twice as fast
+	assert.True(t, time.Since(start) >= 100*time.Millisecond)
+	assert.Equal(t, []string{"trade", "quote", "bar", "trade"}, got)
+}
+
+func TestFileReplayClientClose(t *testing.T) {
+	dir := t.TempDir()
+	writeRecorded(t, dir, "AAPL", "2021-03-02", "trades.csv",
+		"symbol,timestamp,id,exchange,price,size,conditions,tape\n"+
+			"AAPL,2021-03-02T14:30:00Z,1,Q,120.25,100,,C\n"+
+			"AAPL,2021-03-02T15:30:00Z,2,Q,120.5,10,,C\n")
+
+	c := NewFileReplayClient(filepath.Join(dir, "AAPL", "2021-03-02", "trades.csv"))
+	trades := make(chan Trade, 2)
+	require.NoError(t, c.SubscribeTrades(func(trade Trade) { trades <- trade }, "AAPL"))
+	require.NoError(t, c.Connect(context.Background()))
+	<-trades
+	require.NoError(t, c.Close())
+	assert.Equal(t, context.Canceled, <-c.Terminated())
+	assert.Len(t, trades, 0)
+
+	writeRecorded(t, dir, "MSFT", "2021-03-02", "trades.csv",
+		"symbol,timestamp,id,exchange,price,size,conditions,tape\n"+
+			"MSFT,yesterday,1,Q,1,1,,C\n")
+	assert.Error(t, NewFileReplayClient(dir).Connect(context.Background()))
+}
+
+func TestFileReplayClientClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2021, 3, 2, 9, 30, 0, 0, time.UTC))
+	Clock = fake
+	defer func() { Clock = clock.Real }()
+
+	dir := t.TempDir()
+	writeRecorded(t, dir, "AAPL", "2021-03-02", "trades.csv",
+		"symbol,timestamp,id,exchange,price,size,conditions,tape\n"+
+			"AAPL,2021-03-02T14:30:00Z,1,Q,120.25,100,,C\n"+
+			"AAPL,2021-03-02T15:30:00Z,2,Q,120.5,10,,C\n")
+
+	c := NewFileReplayClient(dir)
+	trades := make(chan Trade, 2)
+	require.NoError(t, c.SubscribeTrades(func(trade Trade) { trades <- trade }, "AAPL"))
+	require.NoError(t, c.Connect(context.Background()))
+	assert.Equal(t, int64(1), (<-trades).ID)
+
+	// an hour apart in real time
+	fake.BlockUntil(1)
+	fake.Advance(59 * time.Minute)
+	select {
+	case <-trades:
+		require.Fail(t, "replayed early")
+	default:
+	}
+	fake.Advance(time.Minute)
+	assert.Equal(t, int64(2), (<-trades).ID)
+	assert.NoError(t, <-c.Terminated())
+}
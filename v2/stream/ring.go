@@ -0,0 +1,75 @@
+package stream
+
+import (
+	"sync"
+)
+
+// ringBuffer is a FIFO of a fixed number of messages that overwrites its
+// oldest message when it is full. Its slots are allocated once and their
+// memory is reused by the messages that follow.
+type ringBuffer struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	slots  [][]byte
+	head   int
+	n      int
+	closed bool
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	r := &ringBuffer{slots: make([][]byte, size)}
+	r.cond = sync.NewCond(&r.mu)
+	return r
+}
+
+// push copies b to the ring, overwriting its oldest message if it is full.
+// It returns whether a message was dropped to make room for b.
+func (r *ringBuffer) push(b []byte) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+	dropped := r.n == len(r.slots)
+	if dropped {
+		r.head = (r.head + 1) % len(r.slots)
+		r.n--
+	}
+	i := (r.head + r.n) % len(r.slots)
+	r.slots[i] = append(r.slots[i][:0], b...)
+	r.n++
+	r.cond.Signal()
+	return dropped
+}
+
+// pop waits for the oldest message of the ring, removes it and copies it to
+// dst, which is grown as needed. It returns false once the ring is closed.
+func (r *ringBuffer) pop(dst []byte) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for r.n == 0 && !r.closed {
+		r.cond.Wait()
+	}
+	if r.closed {
+		return dst, false
+	}
+	dst = append(dst[:0], r.slots[r.head]...)
+	r.head = (r.head + 1) % len(r.slots)
+	r.n--
+	return dst, true
+}
+
+// len returns the number of messages in the ring
+func (r *ringBuffer) len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.n
+}
+
+// close drops the messages of the ring and wakes up pop
+func (r *ringBuffer) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	r.cond.Broadcast()
+}
@@ -0,0 +1,49 @@
+package stream
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingBuffer(t *testing.T) {
+	r := newRingBuffer(3)
+	for i := 0; i < 3; i++ {
+		assert.False(t, r.push([]byte(fmt.Sprint(i))))
+	}
+	// the ring is full: the oldest messages make room for the new ones
+	assert.True(t, r.push([]byte("3")))
+	assert.True(t, r.push([]byte("4")))
+	assert.Equal(t, 3, r.len())
+
+	var b []byte
+	for _, want := range []string{"2", "3", "4"} {
+		var ok bool
+		b, ok = r.pop(b)
+		assert.True(t, ok)
+		assert.Equal(t, want, string(b))
+	}
+	assert.Equal(t, 0, r.len())
+
+	// the slots are reused by the messages that follow
+	r.push([]byte("56"))
+	b, _ = r.pop(b)
+	assert.Equal(t, "56", string(b))
+	msg := []byte("7")
+	allocs := testing.AllocsPerRun(100, func() {
+		r.push(msg)
+		b, _ = r.pop(b)
+	})
+	assert.Zero(t, allocs)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, ok := r.pop(nil)
+		assert.False(t, ok)
+	}()
+	r.close()
+	<-done
+	assert.False(t, r.push([]byte("8")))
+}
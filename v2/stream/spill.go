@@ -0,0 +1,151 @@
+package stream
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// BufferOverflow is what the data v2 stream does with the messages it
+// receives while its buffer of messages waiting for the handlers is full
+type BufferOverflow int
+
+const (
+	// BlockOnOverflow stops reading the connection until the handlers catch
+	// up. It is the default.
+	BlockOnOverflow BufferOverflow = iota
+	// DropOnOverflow drops and logs the messages.
+	DropOnOverflow
+	// SpillOnOverflow spools the messages to a temporary file and delivers
+	// them, in order, once the handlers catch up, so that no message is lost
+	// however late it is delivered.
+	SpillOnOverflow
+	// DropOldestOnOverflow keeps the messages in a ring buffer preallocated
+	// once, reusing its memory for every message, and overwrites the oldest
+	// message when it is full, so the memory used by the stream stays fixed
+	// however slow the handlers are.
+	DropOldestOnOverflow
+)
+
+// spillQueue is a FIFO of messages spooled to a temporary file
+type spillQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	file   *os.File
+	n      int
+	offset int64
+	head   []byte
+	closed bool
+}
+
+func newSpillQueue() *spillQueue {
+	q := &spillQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// len returns the number of messages in the queue
+func (q *spillQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.n
+}
+
+// push appends b to the queue, creating its file if needed
+func (q *spillQueue) push(b []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return errors.New("spill queue closed")
+	}
+	if q.file == nil {
+		f, err := os.CreateTemp("", "alpaca-stream-*.spill")
+		if err != nil {
+			return err
+		}
+		q.file = f
+	}
+
+	record := make([]byte, 4+len(b))
+	binary.BigEndian.PutUint32(record, uint32(len(b)))
+	copy(record[4:], b)
+	if _, err := q.file.Write(record); err != nil {
+		return err
+	}
+	q.n++
+	q.cond.Signal()
+	return nil
+}
+
+// next waits for the first message of the queue and returns it without
+// removing it. It returns false once the queue is closed.
+func (q *spillQueue) next() ([]byte, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.n == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return nil, false, nil
+	}
+	if q.head != nil {
+		return q.head, true, nil
+	}
+
+	var size [4]byte
+	if err := q.readAt(size[:], q.offset); err != nil {
+		return nil, false, err
+	}
+	b := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if err := q.readAt(b, q.offset+4); err != nil {
+		return nil, false, err
+	}
+	q.head = b
+	return b, true, nil
+}
+
+// readAt fills b from the file at offset
+func (q *spillQueue) readAt(b []byte, offset int64) error {
+	n, err := q.file.ReadAt(b, offset)
+	if n == len(b) {
+		return nil
+	}
+	return err
+}
+
+// advance removes the first message of the queue, and empties the file once
+// all its messages are removed
+func (q *spillQueue) advance() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.head == nil {
+		return nil
+	}
+	q.offset += 4 + int64(len(q.head))
+	q.head = nil
+	q.n--
+	if q.n > 0 {
+		return nil
+	}
+	q.offset = 0
+	if err := q.file.Truncate(0); err != nil {
+		return err
+	}
+	_, err := q.file.Seek(0, io.SeekStart)
+	return err
+}
+
+// close drops the messages of the queue and removes its file
+func (q *spillQueue) close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+	if q.file == nil {
+		return nil
+	}
+	q.file.Close()
+	return os.Remove(q.file.Name())
+}
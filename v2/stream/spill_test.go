@@ -0,0 +1,101 @@
+package stream
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpillQueue(t *testing.T) {
+	q := newSpillQueue()
+	require.NoError(t, q.push([]byte("a")))
+	require.NoError(t, q.push([]byte("bc")))
+	assert.Equal(t, 2, q.len())
+
+	b, ok, err := q.next()
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, "a", string(b))
+	// next returns the same message until it is removed
+	b, _, _ = q.next()
+	assert.Equal(t, "a", string(b))
+	require.NoError(t, q.advance())
+
+	require.NoError(t, q.push([]byte("def")))
+	for _, want := range []string{"bc", "def"} {
+		b, ok, err = q.next()
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, want, string(b))
+		require.NoError(t, q.advance())
+	}
+	assert.Equal(t, 0, q.len())
+	info, err := q.file.Stat()
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, info.Size())
+
+	name := q.file.Name()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, ok, _ := q.next()
+		assert.False(t, ok)
+	}()
+	require.NoError(t, q.close())
+	<-done
+	_, err = os.Stat(name)
+	assert.True(t, os.IsNotExist(err))
+	assert.Error(t, q.push([]byte("g")))
+}
+
+func TestSpillOnOverflow(t *testing.T) {
+	s := &datav2stream{overflow: SpillOnOverflow}
+	msgs := make(chan []byte, 2)
+	spill := newSpillQueue()
+	drained := make(chan struct{})
+
+	// the handlers are stalled: the buffer fills up and the rest is spilled
+	for i := 0; i < 10; i++ {
+		s.buffer(msgs, spill, []byte(fmt.Sprint(i)))
+	}
+	assert.Equal(t, 8, spill.len())
+
+	go s.drainSpill(spill, msgs, drained)
+	for i := 0; i < 10; i++ {
+		select {
+		case b := <-msgs:
+			assert.Equal(t, fmt.Sprint(i), string(b))
+		case <-time.After(time.Second):
+			t.Fatal("message not delivered")
+		}
+		if i == 5 {
+			// new messages wait for the spilled ones
+			s.buffer(msgs, spill, []byte("10"))
+		}
+	}
+	select {
+	case b := <-msgs:
+		assert.Equal(t, "10", string(b))
+	case <-time.After(time.Second):
+		t.Fatal("message not delivered")
+	}
+
+	require.NoError(t, spill.close())
+	<-drained
+}
+
+func TestDropOnOverflow(t *testing.T) {
+	s := &datav2stream{overflow: DropOnOverflow}
+	msgs := make(chan []byte, 2)
+	for i := 0; i < 3; i++ {
+		s.buffer(msgs, nil, []byte(fmt.Sprint(i)))
+	}
+	assert.Len(t, msgs, 2)
+	assert.Error(t, s.useBufferOverflow(BufferOverflow(42)))
+	assert.NoError(t, s.useBufferOverflow(SpillOnOverflow))
+	assert.NoError(t, s.useBufferOverflow(DropOldestOnOverflow))
+}
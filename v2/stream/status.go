@@ -0,0 +1,84 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Feed statuses reported by the server
+const (
+	FeedOK       = "ok"
+	FeedDegraded = "degraded"
+	FeedDown     = "down"
+)
+
+// FeedStatus is the status of the data feed itself, as opposed to the
+// connection to it, e.g. degraded during the issues of an exchange
+type FeedStatus struct {
+	// Status is FeedOK, FeedDegraded or FeedDown, or empty until the server
+	// reported a status
+	Status string
+	// Message describes the issue, if any
+	Message string
+	// Timestamp is when the server reported the status
+	Timestamp time.Time
+}
+
+// feedStatus is the last status of the feed and the handler of its changes
+type feedStatus struct {
+	mu      sync.Mutex
+	status  FeedStatus
+	handler func(status FeedStatus)
+}
+
+func (f *feedStatus) get() FeedStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status
+}
+
+func (f *feedStatus) setHandler(handler func(status FeedStatus)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handler = handler
+}
+
+// set records status and calls the handler if it changed
+func (f *feedStatus) set(status FeedStatus) {
+	f.mu.Lock()
+	changed := status.Status != f.status.Status || status.Message != f.status.Message
+	f.status = status
+	handler := f.handler
+	f.mu.Unlock()
+
+	if changed && handler != nil {
+		handler(status)
+	}
+}
+
+// decodeFeedStatus decodes the n fields left of a status message
+func decodeFeedStatus(d *msgpack.Decoder, n int) (FeedStatus, error) {
+	var status FeedStatus
+	for i := 0; i < n; i++ {
+		key, err := d.DecodeString()
+		if err != nil {
+			return status, err
+		}
+		switch key {
+		case "status":
+			status.Status, err = d.DecodeString()
+		case "msg":
+			status.Message, err = d.DecodeString()
+		case "t":
+			status.Timestamp, err = d.DecodeTime()
+		default:
+			err = d.Skip()
+		}
+		if err != nil {
+			return status, err
+		}
+	}
+	return status, nil
+}
@@ -1,16 +1,23 @@
 package stream
 
 import (
-	"log"
+	"context"
 	"sync"
+	"time"
 
 	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
 )
 
 var (
-	once         sync.Once
-	dataStream   *datav2stream
-	alpacaStream *alpaca.Stream
+	// credentialsProvider provides the credentials of the data and trade
+	// updates streams every time they connect, if set
+	credentialsProvider common.CredentialsProvider
+
+	once             sync.Once
+	dataStream       *datav2stream
+	alpacaStream     *alpaca.Stream
+	tradeEventStream *tradeUpdatesStream
 )
 
 func initStreamsOnce() {
@@ -21,15 +28,78 @@ func initStreamsOnce() {
 		if alpacaStream == nil {
 			alpacaStream = alpaca.GetStream()
 		}
+		if tradeEventStream == nil {
+			tradeEventStream = newTradeUpdatesStream()
+		}
 	})
 }
 
+// SetCredentialsProvider sets the provider of the credentials the streams
+// authenticate with, instead of the environment. It is called on every
+// (re)connect, so rotated keys are picked up after a reconnect.
+func SetCredentialsProvider(provider common.CredentialsProvider) {
+	credentialsProvider = provider
+}
+
+// streamCredentials returns the credentials to authenticate the streams with
+func streamCredentials() (common.APIKey, error) {
+	if credentialsProvider != nil {
+		return credentialsProvider.Get(context.TODO())
+	}
+	return *common.Credentials(), nil
+}
+
+// authMsg returns the auth message of the v2 streams for credentials
+func authMsg(credentials common.APIKey) map[string]string {
+	if credentials.OAuth != "" {
+		return map[string]string{
+			"action":      "auth",
+			"oauth_token": credentials.OAuth,
+		}
+	}
+	return map[string]string{
+		"action": "auth",
+		"key":    credentials.ID,
+		"secret": credentials.Secret,
+	}
+}
+
 // UseFeed sets the feed used by the data v2 stream. Supported feeds: iex, sip.
 func UseFeed(feed string) error {
 	initStreamsOnce()
 	return dataStream.useFeed(feed)
 }
 
+// UseBufferOverflow sets what the data v2 stream does with the messages it
+// receives while the handlers are too slow for its buffer, e.g.
+// SpillOnOverflow to delay them on disk rather than lose any. It must be
+// called before subscribing.
+func UseBufferOverflow(overflow BufferOverflow) error {
+	initStreamsOnce()
+	return dataStream.useBufferOverflow(overflow)
+}
+
+// UseDedupWindow makes the data v2 stream drop the trades, quotes and bars
+// already delivered, e.g. sent again by the server after a reconnect, so the
+// handlers don't count them twice. The messages are identified by their
+// type, symbol, timestamp and trade ID, and remembered as long as their
+// timestamp is within window of the newest message of their type.
+func UseDedupWindow(window time.Duration) error {
+	initStreamsOnce()
+	return dataStream.useDedupWindow(window)
+}
+
+// UseHandlerQueues gives the trades, quotes and bars of the data v2 stream
+// their own queue of size messages and goroutine calling their handlers, so
+// a slow handler only delays the messages of its type instead of all of them.
+// The messages of a type whose queue is full are dropped and logged.
+// By default the handlers are called one after the other from a single
+// goroutine. It can only be called once.
+func UseHandlerQueues(size int) error {
+	initStreamsOnce()
+	return dataStream.useHandlerQueues(size)
+}
+
 // SubscribeTrades issues a subscribe command to the given symbols and
 // registers the handler to be called for each trade.
 func SubscribeTrades(handler func(trade Trade), symbols ...string) error {
@@ -58,13 +128,54 @@ func SubscribeTradeUpdates(handler func(update alpaca.TradeUpdate)) error {
 	return alpacaStream.Subscribe(alpaca.TradeUpdates, func(msg interface{}) {
 		update, ok := msg.(alpaca.TradeUpdate)
 		if !ok {
-			log.Printf("unexpected trade update: %v", msg)
+			logger().Warn("unexpected trade update", "client", "trade_updates_stream", "message", msg)
 			return
 		}
 		handler(update)
 	})
 }
 
+// SubscribeTradeEvents subscribes to the user's trade updates on a
+// reconnecting websocket and registers the handler to be called with a typed
+// event (FillEvent, CancelEvent, ReplaceEvent or OrderEvent) for each update.
+// After a reconnect the updates missed in the meantime are replayed from the
+// trade events endpoint, without delivering any update twice.
+func SubscribeTradeEvents(handler func(event TradeUpdateEvent)) error {
+	initStreamsOnce()
+	return tradeEventStream.subscribe(handler)
+}
+
+// Subscriptions returns the subscriptions of the data v2 stream last
+// confirmed by the server.
+func Subscriptions() SubscriptionResult {
+	initStreamsOnce()
+	return dataStream.subscriptions.get()
+}
+
+// WaitForSubscriptions waits until the server confirmed every subscription
+// of expected, e.g. before trading starts. It returns the confirmed
+// subscriptions, and a *SubscriptionError if the server rejected a change of
+// the subscriptions meanwhile, or the error of ctx once it is done.
+func WaitForSubscriptions(ctx context.Context, expected SubscriptionResult) (SubscriptionResult, error) {
+	initStreamsOnce()
+	return dataStream.subscriptions.wait(ctx, expected)
+}
+
+// GetFeedStatus returns the status of the feed of the data v2 stream last
+// reported by the server, to tell the issues of the feed itself, e.g. during
+// the outage of an exchange, from those of the connection.
+func GetFeedStatus() FeedStatus {
+	initStreamsOnce()
+	return dataStream.feedStatus.get()
+}
+
+// SetFeedStatusHandler sets the handler called whenever the server reports
+// a change of the status of the feed of the data v2 stream.
+func SetFeedStatusHandler(handler func(status FeedStatus)) {
+	initStreamsOnce()
+	dataStream.feedStatus.setHandler(handler)
+}
+
 // UnsubscribeTrades issues an unsubscribe command for the given trade symbols
 func UnsubscribeTrades(symbols ...string) error {
 	initStreamsOnce()
@@ -89,17 +200,29 @@ func UnsubscribeTradeUpdates() error {
 	return alpacaStream.Unsubscribe(alpaca.TradeUpdates)
 }
 
+// UnsubscribeTradeEvents stops the delivery of typed trade events
+func UnsubscribeTradeEvents() error {
+	initStreamsOnce()
+	return tradeEventStream.unsubscribe()
+}
+
 // Close gracefully closes all streams
 func Close() error {
-	var alpacaErr, dataErr error
+	var alpacaErr, dataErr, tradeUpdatesErr error
 	if alpacaStream != nil {
 		alpacaErr = alpacaStream.Close()
 	}
 	if dataStream != nil {
 		dataErr = dataStream.close(true)
 	}
+	if tradeEventStream != nil {
+		tradeUpdatesErr = tradeEventStream.close(true)
+	}
 	if alpacaErr != nil {
 		return alpacaErr
 	}
-	return dataErr
+	if dataErr != nil {
+		return dataErr
+	}
+	return tradeUpdatesErr
 }
@@ -0,0 +1,150 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// SubscriptionResult is the symbols of the data v2 stream per message type,
+// e.g. the subscriptions confirmed by the server
+type SubscriptionResult struct {
+	Trades []string
+	Quotes []string
+	Bars   []string
+}
+
+// Contains returns true if every subscription of expected is in r
+func (r SubscriptionResult) Contains(expected SubscriptionResult) bool {
+	return containsAll(r.Trades, expected.Trades) &&
+		containsAll(r.Quotes, expected.Quotes) &&
+		containsAll(r.Bars, expected.Bars)
+}
+
+func containsAll(symbols, expected []string) bool {
+	set := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		set[symbol] = true
+	}
+	for _, symbol := range expected {
+		if !set[symbol] {
+			return false
+		}
+	}
+	return true
+}
+
+// SubscriptionError is an error the server sent after a change of the
+// subscriptions, e.g. because the symbol limit of the account is exceeded
+type SubscriptionError struct {
+	Code    int
+	Message string
+}
+
+func (e *SubscriptionError) Error() string {
+	return fmt.Sprintf("subscription error %d: %s", e.Code, e.Message)
+}
+
+// subscriptions are the subscriptions confirmed by the server
+type subscriptions struct {
+	mu        sync.Mutex
+	confirmed SubscriptionResult
+	err       error
+	// changed is closed and replaced whenever confirmed or err change
+	changed chan struct{}
+}
+
+func (s *subscriptions) update(confirmed *SubscriptionResult, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if confirmed != nil {
+		s.confirmed = *confirmed
+	}
+	s.err = err
+	if s.changed != nil {
+		close(s.changed)
+	}
+	s.changed = make(chan struct{})
+}
+
+func (s *subscriptions) get() SubscriptionResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.confirmed
+}
+
+// wait waits until the confirmed subscriptions contain expected, the server
+// sends an error or ctx is done
+func (s *subscriptions) wait(ctx context.Context, expected SubscriptionResult) (SubscriptionResult, error) {
+	for {
+		s.mu.Lock()
+		confirmed, err := s.confirmed, s.err
+		if s.changed == nil {
+			s.changed = make(chan struct{})
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if confirmed.Contains(expected) {
+			return confirmed, nil
+		}
+		if err != nil {
+			return confirmed, err
+		}
+		select {
+		case <-ctx.Done():
+			return confirmed, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// decodeSubscription decodes the n fields left of a subscription message
+func decodeSubscription(d *msgpack.Decoder, n int) (*SubscriptionResult, error) {
+	result := &SubscriptionResult{}
+	for i := 0; i < n; i++ {
+		key, err := d.DecodeString()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "trades":
+			result.Trades, err = decodeStrings(d)
+		case "quotes":
+			result.Quotes, err = decodeStrings(d)
+		case "bars":
+			result.Bars, err = decodeStrings(d)
+		default:
+			err = d.Skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// decodeError decodes the n fields left of an error message
+func decodeError(d *msgpack.Decoder, n int) (*SubscriptionError, error) {
+	e := &SubscriptionError{}
+	for i := 0; i < n; i++ {
+		key, err := d.DecodeString()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "code":
+			e.Code, err = d.DecodeInt()
+		case "msg":
+			e.Message, err = d.DecodeString()
+		default:
+			err = d.Skip()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,445 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	"github.com/shopspring/decimal"
+	"nhooyr.io/websocket"
+)
+
+var (
+	// TradingStreamURL is the base URL of the trading API whose websocket
+	// delivers the account's trade updates.
+	// The APCA_API_BASE_URL environment variable overrides it.
+	TradingStreamURL = "https://api.alpaca.markets"
+
+	// replayTradeEvents reads the trade updates that happened between since
+	// and until from the trade events endpoint
+	replayTradeEvents = func(since, until time.Time, handler func(event alpaca.TradeUpdateEvent)) error {
+		ctx, cancel := context.WithTimeout(context.Background(), replayTimeout)
+		defer cancel()
+		return alpaca.StreamTradeUpdateEvents(ctx, alpaca.StreamEventsRequest{Since: &since, Until: &until}, handler)
+	}
+)
+
+const (
+	replayTimeout = 30 * time.Second
+	// maxSeenEvents is the number of recent events remembered to drop
+	// the duplicates between replayed and live events
+	maxSeenEvents = 1024
+	// maxReconnectDelay bounds the delay between the reconnection attempts
+	maxReconnectDelay = time.Minute
+)
+
+type tradeUpdatesStream struct {
+	// baseURL is the URL of the trading API the websocket is opened on
+	baseURL string
+
+	// connection flow
+	conn          *websocket.Conn
+	authenticated atomic.Value
+	closed        atomic.Value
+
+	// handlers
+	handler func(event TradeUpdateEvent)
+
+	// replay
+	lastEventTime time.Time
+	seen          map[string]bool
+	seenOrder     []string
+
+	// concurrency
+	readerOnce    sync.Once
+	wsWriteMutex  sync.Mutex
+	wsReadMutex   sync.Mutex
+	handlersMutex sync.RWMutex
+}
+
+func newTradeUpdatesStream() *tradeUpdatesStream {
+	baseURL := TradingStreamURL
+	if s := os.Getenv("APCA_API_BASE_URL"); s != "" {
+		baseURL = s
+	}
+	s := &tradeUpdatesStream{
+		baseURL:       baseURL,
+		authenticated: atomic.Value{},
+	}
+
+	s.authenticated.Store(false)
+	s.closed.Store(false)
+
+	return s
+}
+
+func (s *tradeUpdatesStream) subscribe(handler func(event TradeUpdateEvent)) error {
+	s.handlersMutex.Lock()
+	s.handler = handler
+	s.handlersMutex.Unlock()
+
+	if s.conn == nil {
+		// connect listens to trade updates once the handler is set
+		return s.ensureRunning()
+	}
+	return s.listen([]string{alpaca.TradeUpdates})
+}
+
+func (s *tradeUpdatesStream) unsubscribe() error {
+	if s.conn == nil {
+		return errors.New("not yet subscribed to trade updates")
+	}
+
+	s.handlersMutex.Lock()
+	s.handler = nil
+	s.handlersMutex.Unlock()
+
+	return s.listen([]string{})
+}
+
+func (s *tradeUpdatesStream) close(final bool) error {
+	if s.conn == nil {
+		return nil
+	}
+
+	s.wsWriteMutex.Lock()
+	defer s.wsWriteMutex.Unlock()
+
+	if final {
+		s.closed.Store(true)
+	}
+
+	if err := s.conn.Close(websocket.StatusNormalClosure, ""); err != nil {
+		return err
+	}
+	s.conn = nil
+	return nil
+}
+
+func (s *tradeUpdatesStream) ensureRunning() error {
+	if s.conn != nil {
+		return nil
+	}
+
+	if err := s.connect(); err != nil {
+		return err
+	}
+	s.readerOnce.Do(func() {
+		go s.readForever()
+	})
+	return nil
+}
+
+func (s *tradeUpdatesStream) connect() error {
+	// first close any previous connections
+	s.close(false)
+
+	s.authenticated.Store(false)
+	conn, err := openTradingSocket(s.baseURL)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	if err := s.auth(); err != nil {
+		return err
+	}
+
+	s.handlersMutex.RLock()
+	subscribed := s.handler != nil
+	s.handlersMutex.RUnlock()
+	if !subscribed {
+		return nil
+	}
+	return s.listen([]string{alpaca.TradeUpdates})
+}
+
+func (s *tradeUpdatesStream) readForever() {
+	for {
+		s.wsReadMutex.Lock()
+		_, b, err := s.conn.Read(context.TODO())
+		s.wsReadMutex.Unlock()
+
+		if err != nil {
+			if websocket.CloseStatus(err) == websocket.StatusNormalClosure {
+				// if this was a graceful closure, don't reconnect
+				if s.closed.Load().(bool) {
+					return
+				}
+			} else {
+				logger().Warn("stream read error, reconnecting", "client", "trade_updates_stream", "error", err)
+			}
+
+			if !s.reconnect() {
+				return
+			}
+			s.replay()
+			continue
+		}
+
+		if err := s.handleMessage(b); err != nil {
+			logger().Warn("failed to handle trade update", "client", "trade_updates_stream", "error", err)
+		}
+	}
+}
+
+// reconnect connects again after a read error, retrying with a delay
+// doubled after each failure up to maxReconnectDelay until it succeeds. It
+// returns false if the stream was closed meanwhile.
+func (s *tradeUpdatesStream) reconnect() bool {
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		if s.closed.Load().(bool) {
+			return false
+		}
+		err := s.connect()
+		if err == nil {
+			return true
+		}
+		logger().Error("failed to reconnect, retrying", "client", "trade_updates_stream",
+			"attempt", attempt, "delay", delay, "error", err)
+		Clock.Sleep(delay)
+		if delay *= 2; delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+// tradingServerMsg is the envelope of every message on the trading stream
+type tradingServerMsg struct {
+	Stream string          `json:"stream"`
+	Data   json.RawMessage `json:"data"`
+}
+
+func (s *tradeUpdatesStream) handleMessage(b []byte) error {
+	var msg tradingServerMsg
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return err
+	}
+	if msg.Stream != alpaca.TradeUpdates {
+		return nil
+	}
+
+	var u tradeUpdate
+	if err := json.Unmarshal(msg.Data, &u); err != nil {
+		return err
+	}
+	s.deliver(u)
+	return nil
+}
+
+// deliver passes u to the handler unless it was already delivered.
+// Events are identified by type, order, execution and time rather than
+// event ID, since the websocket does not always send one.
+func (s *tradeUpdatesStream) deliver(u tradeUpdate) {
+	s.handlersMutex.Lock()
+	key := strings.Join([]string{
+		string(u.Event), u.Order.ID, u.ExecutionID, u.Timestamp.UTC().Format(time.RFC3339Nano),
+	}, "/")
+	if s.seen[key] {
+		s.handlersMutex.Unlock()
+		return
+	}
+	if s.seen == nil {
+		s.seen = map[string]bool{}
+	}
+	s.seen[key] = true
+	s.seenOrder = append(s.seenOrder, key)
+	if len(s.seenOrder) > maxSeenEvents {
+		delete(s.seen, s.seenOrder[0])
+		s.seenOrder = s.seenOrder[1:]
+	}
+	if u.Timestamp.After(s.lastEventTime) {
+		s.lastEventTime = u.Timestamp
+	}
+	handler := s.handler
+	s.handlersMutex.Unlock()
+
+	if handler != nil {
+		handler(u.event())
+	}
+}
+
+// replay delivers the trade updates missed while the websocket was
+// disconnected, read from the trade events endpoint since the last event.
+func (s *tradeUpdatesStream) replay() {
+	s.handlersMutex.RLock()
+	since := s.lastEventTime
+	subscribed := s.handler != nil
+	s.handlersMutex.RUnlock()
+	if since.IsZero() || !subscribed {
+		return
+	}
+
+	err := replayTradeEvents(since, Clock.Now(), func(e alpaca.TradeUpdateEvent) {
+		s.deliver(tradeUpdate{
+			EventID:     e.EventID,
+			Event:       e.Event,
+			ExecutionID: e.ExecutionID,
+			Order:       e.Order,
+			Timestamp:   e.Timestamp,
+			Price:       e.Price,
+			Qty:         e.Qty,
+			PositionQty: e.PositionQty,
+		})
+	})
+	if err != nil {
+		logger().Error("failed to replay missed events", "client", "trade_updates_stream", "error", err)
+	}
+}
+
+func (s *tradeUpdatesStream) listen(streams []string) error {
+	msg, err := json.Marshal(alpaca.ClientMsg{
+		Action: "listen",
+		Data: map[string]interface{}{
+			"streams": streams,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	s.wsWriteMutex.Lock()
+	defer s.wsWriteMutex.Unlock()
+
+	return s.conn.Write(context.TODO(), websocket.MessageText, msg)
+}
+
+func (s *tradeUpdatesStream) isAuthenticated() bool {
+	return s.authenticated.Load().(bool)
+}
+
+func (s *tradeUpdatesStream) auth() (err error) {
+	if s.isAuthenticated() {
+		return
+	}
+
+	credentials, err := streamCredentials()
+	if err != nil {
+		return err
+	}
+	msg, err := json.Marshal(authMsg(credentials))
+	if err != nil {
+		return err
+	}
+
+	s.wsWriteMutex.Lock()
+	defer s.wsWriteMutex.Unlock()
+
+	if err := s.conn.Write(context.TODO(), websocket.MessageText, msg); err != nil {
+		return err
+	}
+
+	// ensure the auth response comes in a timely manner
+	ctx, cancel := context.WithTimeout(context.TODO(), 5*time.Second)
+	defer cancel()
+
+	s.wsReadMutex.Lock()
+	defer s.wsReadMutex.Unlock()
+
+	_, b, err := s.conn.Read(ctx)
+	if err != nil {
+		return err
+	}
+
+	var resp tradingServerMsg
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return err
+	}
+	var status struct {
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(resp.Data, &status); err != nil {
+		return err
+	}
+	if resp.Stream != "authorization" || !strings.EqualFold(status.Status, "authorized") {
+		return errors.New("failed to authorize alpaca trade updates stream")
+	}
+
+	s.authenticated.Store(true)
+
+	return
+}
+
+func openTradingSocket(baseURL string) (*websocket.Conn, error) {
+	scheme := "wss"
+	ub, _ := url.Parse(baseURL)
+	switch ub.Scheme {
+	case "http", "ws":
+		scheme = "ws"
+	}
+	u := url.URL{Scheme: scheme, Host: ub.Host, Path: "/stream"}
+	for attempts := 1; attempts <= MaxConnectionAttempts; attempts++ {
+		c, _, err := websocket.Dial(context.TODO(), u.String(), &websocket.DialOptions{
+			HTTPHeader: http.Header{"User-Agent": []string{common.UserAgent(Application)}},
+		})
+		if err == nil {
+			return c, nil
+		}
+		logger().Warn("failed to open stream", "client", "trade_updates_stream",
+			"attempt", attempts, "max_attempts", MaxConnectionAttempts, "error", err)
+		if attempts == MaxConnectionAttempts {
+			return nil, err
+		}
+		Clock.Sleep(time.Second)
+	}
+	return nil, fmt.Errorf("could not open Alpaca trade updates stream (max retries exceeded)")
+}
+
+// tradeUpdate is the wire format of a single trade update
+type tradeUpdate struct {
+	EventID     string            `json:"event_id"`
+	Event       alpaca.TradeEvent `json:"event"`
+	ExecutionID string            `json:"execution_id"`
+	Order       alpaca.Order      `json:"order"`
+	Timestamp   time.Time         `json:"timestamp"`
+	Price       decimal.Decimal   `json:"price"`
+	Qty         decimal.Decimal   `json:"qty"`
+	PositionQty decimal.Decimal   `json:"position_qty"`
+}
+
+func decodeTradeUpdate(b []byte) (TradeUpdateEvent, error) {
+	var u tradeUpdate
+	if err := json.Unmarshal(b, &u); err != nil {
+		return nil, err
+	}
+	return u.event(), nil
+}
+
+func (u tradeUpdate) event() TradeUpdateEvent {
+	base := OrderEvent{
+		EventID:   u.EventID,
+		Event:     u.Event,
+		Order:     u.Order,
+		Timestamp: u.Timestamp,
+	}
+	switch u.Event {
+	case alpaca.EventFill, alpaca.EventPartialFill:
+		return FillEvent{
+			OrderEvent:  base,
+			ExecutionID: u.ExecutionID,
+			Price:       u.Price,
+			Qty:         u.Qty,
+			PositionQty: u.PositionQty,
+		}
+	case alpaca.EventCanceled, alpaca.EventExpired, alpaca.EventRejected:
+		return CancelEvent{OrderEvent: base}
+	case alpaca.EventReplaced:
+		e := ReplaceEvent{OrderEvent: base}
+		if u.Order.ReplacedBy != nil {
+			e.ReplacedBy = *u.Order.ReplacedBy
+		}
+		return e
+	default:
+		return base
+	}
+}
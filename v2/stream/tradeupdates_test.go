@@ -0,0 +1,305 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/market-development-strategy/alpaca-trade-api-go/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nhooyr.io/websocket"
+)
+
+const testFill = `{
+	"stream": "trade_updates",
+	"data": {
+		"event": "partial_fill",
+		"execution_id": "exec1",
+		"timestamp": "2021-03-04T15:16:17.000000018Z",
+		"price": "179.08",
+		"qty": "2",
+		"position_qty": "5",
+		"order": {"id": "order1", "symbol": "AAPL", "status": "partially_filled"}
+	}
+}`
+
+const testReplace = `{
+	"stream": "trade_updates",
+	"data": {
+		"event": "replaced",
+		"timestamp": "2021-03-04T15:16:17Z",
+		"order": {"id": "order1", "status": "replaced", "replaced_by": "order2"}
+	}
+}`
+
+const testCancel = `{
+	"stream": "trade_updates",
+	"data": {
+		"event": "canceled",
+		"timestamp": "2021-03-04T15:16:17Z",
+		"order": {"id": "order1", "status": "canceled"}
+	}
+}`
+
+const testPendingNew = `{
+	"stream": "trade_updates",
+	"data": {
+		"event": "pending_new",
+		"timestamp": "2021-03-04T15:16:17Z",
+		"order": {"id": "order1", "status": "pending_new"}
+	}
+}`
+
+func TestHandleTradeUpdates(t *testing.T) {
+	fake := clock.NewFake(testTime.Add(time.Hour))
+	Clock = fake
+	defer func() { Clock = clock.Real }()
+
+	var events []TradeUpdateEvent
+	s := &tradeUpdatesStream{
+		handler: func(event TradeUpdateEvent) {
+			events = append(events, event)
+		},
+	}
+
+	for _, msg := range []string{testFill, testReplace, testCancel, testPendingNew} {
+		require.NoError(t, s.handleMessage([]byte(msg)))
+	}
+	// messages on other streams are ignored
+	require.NoError(t, s.handleMessage([]byte(`{"stream":"listening","data":{"streams":["trade_updates"]}}`)))
+	require.Len(t, events, 4)
+
+	fill, ok := events[0].(FillEvent)
+	require.True(t, ok)
+	assert.True(t, fill.IsPartial())
+	assert.Equal(t, "exec1", fill.ExecutionID)
+	assert.True(t, fill.Price.Equal(decimal.RequireFromString("179.08")))
+	assert.True(t, fill.Qty.Equal(decimal.New(2, 0)))
+	assert.True(t, fill.PositionQty.Equal(decimal.New(5, 0)))
+	assert.Equal(t, "order1", fill.GetOrder().ID)
+	assert.Equal(t, alpaca.OrderPartiallyFilled, fill.Order.Status)
+	assert.True(t, fill.GetTimestamp().Equal(testTime))
+
+	replace, ok := events[1].(ReplaceEvent)
+	require.True(t, ok)
+	assert.Equal(t, "order2", replace.ReplacedBy)
+
+	cancel, ok := events[2].(CancelEvent)
+	require.True(t, ok)
+	assert.Equal(t, alpaca.EventCanceled, cancel.GetEvent())
+
+	other, ok := events[3].(OrderEvent)
+	require.True(t, ok)
+	assert.Equal(t, alpaca.EventPendingNew, other.Event)
+}
+
+func TestTradeUpdatesStreamConnect(t *testing.T) {
+	listened := make(chan []string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close(websocket.StatusNormalClosure, "")
+
+		ctx := context.Background()
+		_, b, err := conn.Read(ctx)
+		require.NoError(t, err)
+		var auth map[string]string
+		require.NoError(t, json.Unmarshal(b, &auth))
+		assert.Equal(t, "auth", auth["action"])
+		assert.Equal(t, "rotated", auth["key"])
+		require.NoError(t, conn.Write(ctx, websocket.MessageText,
+			[]byte(`{"stream":"authorization","data":{"action":"authenticate","status":"authorized"}}`)))
+
+		_, b, err = conn.Read(ctx)
+		require.NoError(t, err)
+		var listen struct {
+			Action string `json:"action"`
+			Data   struct {
+				Streams []string `json:"streams"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(b, &listen))
+		assert.Equal(t, "listen", listen.Action)
+		listened <- listen.Data.Streams
+
+		require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte(testCancel)))
+		conn.Read(ctx)
+	}))
+	defer server.Close()
+
+	TradingStreamURL = server.URL
+	SetCredentialsProvider(common.CredentialsProviderFunc(func(ctx context.Context) (common.APIKey, error) {
+		return common.APIKey{ID: "rotated", Secret: "secret"}, nil
+	}))
+	defer SetCredentialsProvider(nil)
+	s := newTradeUpdatesStream()
+	events := make(chan TradeUpdateEvent, 1)
+	require.NoError(t, s.subscribe(func(event TradeUpdateEvent) {
+		events <- event
+	}))
+	defer s.close(true)
+
+	select {
+	case streams := <-listened:
+		assert.Equal(t, []string{alpaca.TradeUpdates}, streams)
+	case <-time.After(time.Second):
+		require.Fail(t, "no listen message")
+	}
+	select {
+	case event := <-events:
+		_, ok := event.(CancelEvent)
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		require.Fail(t, "no trade event")
+	}
+}
+
+func TestTradeUpdatesReconnect(t *testing.T) {
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&connections, 1)
+		if n == 2 {
+			// the server is briefly unavailable
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := websocket.Accept(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close(websocket.StatusNormalClosure, "")
+
+		ctx := context.Background()
+		_, _, err = conn.Read(ctx)
+		require.NoError(t, err)
+		require.NoError(t, conn.Write(ctx, websocket.MessageText,
+			[]byte(`{"stream":"authorization","data":{"action":"authenticate","status":"authorized"}}`)))
+		_, _, err = conn.Read(ctx)
+		require.NoError(t, err)
+		if n == 1 {
+			conn.Close(websocket.StatusInternalError, "restarting")
+			return
+		}
+		require.NoError(t, conn.Write(ctx, websocket.MessageText, []byte(testCancel)))
+		conn.Read(ctx)
+	}))
+	defer server.Close()
+
+	fake := clock.NewFake(time.Now())
+	Clock = fake
+	defer func() { Clock = clock.Real }()
+	defer func(attempts int) { MaxConnectionAttempts = attempts }(MaxConnectionAttempts)
+	MaxConnectionAttempts = 1
+	TradingStreamURL = server.URL
+	SetCredentialsProvider(common.CredentialsProviderFunc(func(ctx context.Context) (common.APIKey, error) {
+		return common.APIKey{ID: "key", Secret: "secret"}, nil
+	}))
+	defer SetCredentialsProvider(nil)
+	s := newTradeUpdatesStream()
+	events := make(chan TradeUpdateEvent, 1)
+	require.NoError(t, s.subscribe(func(event TradeUpdateEvent) {
+		events <- event
+	}))
+	defer s.close(true)
+
+	// the failed reconnection is retried after a delay instead of panicking
+	fake.BlockUntil(1)
+	fake.Advance(time.Second)
+	select {
+	case event := <-events:
+		_, ok := event.(CancelEvent)
+		assert.True(t, ok)
+	case <-time.After(time.Second):
+		require.Fail(t, "no trade event after reconnecting")
+	}
+	assert.EqualValues(t, 3, atomic.LoadInt32(&connections))
+}
+
+func TestTradeUpdatesReplay(t *testing.T) {
+	defer func(replay func(since, until time.Time, handler func(event alpaca.TradeUpdateEvent)) error) {
+		replayTradeEvents = replay
+	}(replayTradeEvents)
+
+	fake := clock.NewFake(testTime.Add(time.Hour))
+	Clock = fake
+	defer func() { Clock = clock.Real }()
+
+	var events []TradeUpdateEvent
+	s := &tradeUpdatesStream{
+		handler: func(event TradeUpdateEvent) {
+			events = append(events, event)
+		},
+	}
+
+	// nothing to replay before the first event
+	replayTradeEvents = func(since, until time.Time, handler func(event alpaca.TradeUpdateEvent)) error {
+		require.Fail(t, "unexpected replay")
+		return nil
+	}
+	s.replay()
+
+	require.NoError(t, s.handleMessage([]byte(testFill)))
+	// a duplicate live event is dropped
+	require.NoError(t, s.handleMessage([]byte(testFill)))
+	require.Len(t, events, 1)
+
+	missed := testTime.Add(time.Minute)
+	replayTradeEvents = func(since, until time.Time, handler func(event alpaca.TradeUpdateEvent)) error {
+		assert.True(t, since.Equal(testTime))
+		assert.True(t, until.Equal(fake.Now()))
+		// the already delivered fill comes first, as since is inclusive
+		handler(alpaca.TradeUpdateEvent{
+			EventID:     "01",
+			Event:       alpaca.EventPartialFill,
+			ExecutionID: "exec1",
+			Order:       alpaca.Order{ID: "order1"},
+			Timestamp:   testTime,
+		})
+		handler(alpaca.TradeUpdateEvent{
+			EventID:     "02",
+			Event:       alpaca.EventFill,
+			ExecutionID: "exec2",
+			Order:       alpaca.Order{ID: "order1"},
+			Timestamp:   missed,
+			Qty:         decimal.New(3, 0),
+		})
+		return nil
+	}
+	s.replay()
+
+	require.Len(t, events, 2)
+	fill, ok := events[1].(FillEvent)
+	require.True(t, ok)
+	assert.Equal(t, "02", fill.EventID)
+	assert.Equal(t, "exec2", fill.ExecutionID)
+	assert.True(t, fill.Qty.Equal(decimal.New(3, 0)))
+	assert.True(t, s.lastEventTime.Equal(missed))
+}
+
+func TestTradeUpdatesStreamURL(t *testing.T) {
+	defer func(u string) { TradingStreamURL = u }(TradingStreamURL)
+	defer os.Setenv("APCA_API_BASE_URL", os.Getenv("APCA_API_BASE_URL"))
+
+	TradingStreamURL = "https://paper-api.alpaca.markets"
+	os.Setenv("APCA_API_BASE_URL", "")
+	assert.Equal(t, "https://paper-api.alpaca.markets", newTradeUpdatesStream().baseURL)
+
+	// the environment overrides the URL of the stream, not the package one
+	os.Setenv("APCA_API_BASE_URL", "http://localhost:8080")
+	assert.Equal(t, "http://localhost:8080", newTradeUpdatesStream().baseURL)
+	assert.Equal(t, "https://paper-api.alpaca.markets", TradingStreamURL)
+}
+
+func TestAuthMsg(t *testing.T) {
+	assert.Equal(t, map[string]string{"action": "auth", "key": "id", "secret": "secret"},
+		authMsg(common.APIKey{ID: "id", Secret: "secret"}))
+	assert.Equal(t, map[string]string{"action": "auth", "oauth_token": "token"},
+		authMsg(common.APIKey{OAuth: "token"}))
+}
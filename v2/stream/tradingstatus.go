@@ -0,0 +1,236 @@
+package stream
+
+import (
+	"time"
+
+	v2 "github.com/market-development-strategy/alpaca-trade-api-go/v2"
+)
+
+// TradingStatusCode is the status code of a trading status message, from the
+// CTA plan for tapes A and B or from UTDF for tape C
+type TradingStatusCode string
+
+// Trading status codes of tapes A and B (CTA)
+const (
+	StatusCTATradingHalt                TradingStatusCode = "2"
+	StatusCTAResume                     TradingStatusCode = "3"
+	StatusCTAPriceIndication            TradingStatusCode = "5"
+	StatusCTATradingRangeIndication     TradingStatusCode = "6"
+	StatusCTAMarketImbalanceBuy         TradingStatusCode = "7"
+	StatusCTAMarketImbalanceSell        TradingStatusCode = "8"
+	StatusCTAMarketOnCloseImbalanceBuy  TradingStatusCode = "9"
+	StatusCTAMarketOnCloseImbalanceSell TradingStatusCode = "A"
+	StatusCTANoMarketImbalance          TradingStatusCode = "C"
+	StatusCTANoMarketOnCloseImbalance   TradingStatusCode = "D"
+	StatusCTAShortSaleRestriction       TradingStatusCode = "E"
+	StatusCTALimitUpLimitDown           TradingStatusCode = "F"
+)
+
+// Trading status codes of tape C (UTDF)
+const (
+	StatusUTDFTradingHalt            TradingStatusCode = "H"
+	StatusUTDFQuotationResumption    TradingStatusCode = "Q"
+	StatusUTDFTradingResumption      TradingStatusCode = "T"
+	StatusUTDFVolatilityTradingPause TradingStatusCode = "P"
+)
+
+var tradingStatusDescriptions = map[TradingStatusCode]string{
+	StatusCTATradingHalt:                "Trading Halt",
+	StatusCTAResume:                     "Resume",
+	StatusCTAPriceIndication:            "Price Indication",
+	StatusCTATradingRangeIndication:     "Trading Range Indication",
+	StatusCTAMarketImbalanceBuy:         "Market Imbalance Buy",
+	StatusCTAMarketImbalanceSell:        "Market Imbalance Sell",
+	StatusCTAMarketOnCloseImbalanceBuy:  "Market On Close Imbalance Buy",
+	StatusCTAMarketOnCloseImbalanceSell: "Market On Close Imbalance Sell",
+	StatusCTANoMarketImbalance:          "No Market Imbalance",
+	StatusCTANoMarketOnCloseImbalance:   "No Market On Close Imbalance",
+	StatusCTAShortSaleRestriction:       "Short Sale Restriction",
+	StatusCTALimitUpLimitDown:           "Limit Up-Limit Down",
+	StatusUTDFTradingHalt:               "Trading Halt",
+	StatusUTDFQuotationResumption:       "Quotation Resumption",
+	StatusUTDFTradingResumption:         "Trading Resumption",
+	StatusUTDFVolatilityTradingPause:    "Volatility Trading Pause",
+}
+
+// Description returns the meaning of the code, or an empty string if it is
+// unknown
+func (c TradingStatusCode) Description() string {
+	return tradingStatusDescriptions[c]
+}
+
+// IsHalt returns true if trading is stopped, by a halt or a volatility
+// trading pause
+func (c TradingStatusCode) IsHalt() bool {
+	switch c {
+	case StatusCTATradingHalt, StatusUTDFTradingHalt, StatusUTDFVolatilityTradingPause:
+		return true
+	}
+	return false
+}
+
+// IsPause returns true if trading is stopped by a volatility trading pause,
+// usually resumed after a few minutes
+func (c TradingStatusCode) IsPause() bool {
+	return c == StatusUTDFVolatilityTradingPause
+}
+
+// IsResumption returns true if trading resumes. Tape C resumes the quotation
+// (StatusUTDFQuotationResumption) before trading, which is not a resumption.
+func (c TradingStatusCode) IsResumption() bool {
+	return c == StatusCTAResume || c == StatusUTDFTradingResumption
+}
+
+// TradingStatusReason is the reason code of a trading status message
+type TradingStatusReason string
+
+// Trading status reasons of tapes A and B (CTA)
+const (
+	ReasonCTANewsReleased       TradingStatusReason = "D"
+	ReasonCTAOrderImbalance     TradingStatusReason = "I"
+	ReasonCTALimitUpLimitDown   TradingStatusReason = "M"
+	ReasonCTANewsPending        TradingStatusReason = "P"
+	ReasonCTAOperational        TradingStatusReason = "X"
+	ReasonCTASubPennyTrading    TradingStatusReason = "Y"
+	ReasonCTACircuitBreakerLvl1 TradingStatusReason = "1"
+	ReasonCTACircuitBreakerLvl2 TradingStatusReason = "2"
+	ReasonCTACircuitBreakerLvl3 TradingStatusReason = "3"
+)
+
+// Trading status reasons of tape C (UTDF)
+const (
+	ReasonUTDFNewsPending              TradingStatusReason = "T1"
+	ReasonUTDFNewsDissemination        TradingStatusReason = "T2"
+	ReasonUTDFNewsAndResumptionTimes   TradingStatusReason = "T3"
+	ReasonUTDFSingleStockTradingPause  TradingStatusReason = "T5"
+	ReasonUTDFExtraordinaryActivity    TradingStatusReason = "T6"
+	ReasonUTDFQuotationOnlyPeriod      TradingStatusReason = "T7"
+	ReasonUTDFETF                      TradingStatusReason = "T8"
+	ReasonUTDFInformationRequested     TradingStatusReason = "T12"
+	ReasonUTDFNonCompliance            TradingStatusReason = "H4"
+	ReasonUTDFFilingsNotCurrent        TradingStatusReason = "H9"
+	ReasonUTDFSECTradingSuspension     TradingStatusReason = "H10"
+	ReasonUTDFRegulatoryConcern        TradingStatusReason = "H11"
+	ReasonUTDFOperations               TradingStatusReason = "O1"
+	ReasonUTDFIPONotYetTrading         TradingStatusReason = "IPO1"
+	ReasonUTDFCorporateAction          TradingStatusReason = "M1"
+	ReasonUTDFQuotationNotAvailable    TradingStatusReason = "M2"
+	ReasonUTDFVolatilityTradingPause   TradingStatusReason = "LUDP"
+	ReasonUTDFVolatilityPauseStraddle  TradingStatusReason = "LUDS"
+	ReasonUTDFCircuitBreakerLvl1       TradingStatusReason = "MWC1"
+	ReasonUTDFCircuitBreakerLvl2       TradingStatusReason = "MWC2"
+	ReasonUTDFCircuitBreakerLvl3       TradingStatusReason = "MWC3"
+	ReasonUTDFCircuitBreakerCarryOver  TradingStatusReason = "MWC0"
+	ReasonUTDFCircuitBreakerResumption TradingStatusReason = "MWCQ"
+	ReasonUTDFIPOReleasedForQuotation  TradingStatusReason = "IPOQ"
+	ReasonUTDFIPOWindowExtension       TradingStatusReason = "IPOE"
+	ReasonUTDFIssueAvailable           TradingStatusReason = "R"
+	ReasonUTDFNewIssueAvailable        TradingStatusReason = "R1"
+	ReasonUTDFQualificationsResolved   TradingStatusReason = "R4"
+	ReasonUTDFFilingsSatisfied         TradingStatusReason = "R9"
+	ReasonUTDFNewsNotForthcoming       TradingStatusReason = "C3"
+	ReasonUTDFQualificationsHaltEnded  TradingStatusReason = "C4"
+	ReasonUTDFQualificationsConcluded  TradingStatusReason = "C9"
+	ReasonUTDFOtherAuthorityHaltEnded  TradingStatusReason = "C11"
+)
+
+var tradingStatusReasonDescriptions = map[TradingStatusReason]string{
+	ReasonCTANewsReleased:              "News Released",
+	ReasonCTAOrderImbalance:            "Order Imbalance",
+	ReasonCTALimitUpLimitDown:          "Limit Up-Limit Down Trading Pause",
+	ReasonCTANewsPending:               "News Pending",
+	ReasonCTAOperational:               "Operational",
+	ReasonCTASubPennyTrading:           "Sub-Penny Trading",
+	ReasonCTACircuitBreakerLvl1:        "Market-Wide Circuit Breaker Level 1",
+	ReasonCTACircuitBreakerLvl2:        "Market-Wide Circuit Breaker Level 2",
+	ReasonCTACircuitBreakerLvl3:        "Market-Wide Circuit Breaker Level 3",
+	ReasonUTDFNewsPending:              "Halt News Pending",
+	ReasonUTDFNewsDissemination:        "Halt News Dissemination",
+	ReasonUTDFNewsAndResumptionTimes:   "News and Resumption Times",
+	ReasonUTDFSingleStockTradingPause:  "Single Stock Trading Pause In Effect",
+	ReasonUTDFExtraordinaryActivity:    "Regulatory Halt Extraordinary Market Activity",
+	ReasonUTDFQuotationOnlyPeriod:      "Single Stock Trading Pause/Quotation-Only Period",
+	ReasonUTDFETF:                      "Halt ETF",
+	ReasonUTDFInformationRequested:     "Trading Halted; For Information Requested by the Listing Market",
+	ReasonUTDFNonCompliance:            "Halt Non-Compliance",
+	ReasonUTDFFilingsNotCurrent:        "Halt Filings Not Current",
+	ReasonUTDFSECTradingSuspension:     "Halt SEC Trading Suspension",
+	ReasonUTDFRegulatoryConcern:        "Halt Regulatory Concern",
+	ReasonUTDFOperations:               "Operations Halt",
+	ReasonUTDFIPONotYetTrading:         "IPO Issue Not Yet Trading",
+	ReasonUTDFCorporateAction:          "Corporate Action",
+	ReasonUTDFQuotationNotAvailable:    "Quotation Not Available",
+	ReasonUTDFVolatilityTradingPause:   "Volatility Trading Pause",
+	ReasonUTDFVolatilityPauseStraddle:  "Volatility Trading Pause - Straddle Condition",
+	ReasonUTDFCircuitBreakerLvl1:       "Market-Wide Circuit Breaker Halt Level 1",
+	ReasonUTDFCircuitBreakerLvl2:       "Market-Wide Circuit Breaker Halt Level 2",
+	ReasonUTDFCircuitBreakerLvl3:       "Market-Wide Circuit Breaker Halt Level 3",
+	ReasonUTDFCircuitBreakerCarryOver:  "Market-Wide Circuit Breaker Halt Carry Over From Previous Day",
+	ReasonUTDFCircuitBreakerResumption: "Market-Wide Circuit Breaker Resumption",
+	ReasonUTDFIPOReleasedForQuotation:  "IPO Security Released for Quotation",
+	ReasonUTDFIPOWindowExtension:       "IPO Security Positioning Window Extension",
+	ReasonUTDFIssueAvailable:           "Issue Available",
+	ReasonUTDFNewIssueAvailable:        "New Issue Available",
+	ReasonUTDFQualificationsResolved:   "Qualifications Issues Reviewed/Resolved; Quotations/Trading to Resume",
+	ReasonUTDFFilingsSatisfied:         "Filing Requirements Satisfied/Resolved; Quotations/Trading to Resume",
+	ReasonUTDFNewsNotForthcoming:       "Issuer News Not Forthcoming; Quotations/Trading to Resume",
+	ReasonUTDFQualificationsHaltEnded:  "Qualifications Halt Ended; Maintenance Requirements Met; Resume",
+	ReasonUTDFQualificationsConcluded:  "Qualifications Halt Concluded; Filings Met; Quotes/Trades to Resume",
+	ReasonUTDFOtherAuthorityHaltEnded:  "Trade Halt Concluded by Other Regulatory Authority; Quotes/Trades Resume",
+}
+
+// Description returns the meaning of the reason, or an empty string if it is
+// unknown
+func (r TradingStatusReason) Description() string {
+	return tradingStatusReasonDescriptions[r]
+}
+
+// IsCircuitBreaker returns true if the reason is a market-wide circuit
+// breaker halt, which stops the trading of every symbol
+func (r TradingStatusReason) IsCircuitBreaker() bool {
+	switch r {
+	case ReasonCTACircuitBreakerLvl1, ReasonCTACircuitBreakerLvl2, ReasonCTACircuitBreakerLvl3,
+		ReasonUTDFCircuitBreakerLvl1, ReasonUTDFCircuitBreakerLvl2, ReasonUTDFCircuitBreakerLvl3,
+		ReasonUTDFCircuitBreakerCarryOver:
+		return true
+	}
+	return false
+}
+
+// IsLimitUpLimitDown returns true if the reason is a limit up-limit down
+// volatility trading pause
+func (r TradingStatusReason) IsLimitUpLimitDown() bool {
+	switch r {
+	case ReasonCTALimitUpLimitDown, ReasonUTDFVolatilityTradingPause, ReasonUTDFVolatilityPauseStraddle:
+		return true
+	}
+	return false
+}
+
+// TradingStatus is a change of the trading status of a symbol, e.g. a halt
+type TradingStatus struct {
+	Symbol        string
+	StatusCode    TradingStatusCode
+	StatusMessage string
+	ReasonCode    TradingStatusReason
+	ReasonMessage string
+	Timestamp     time.Time
+	Tape          v2.Tape
+}
+
+// IsHalt returns true if trading of the symbol is stopped, see
+// TradingStatusCode.IsHalt
+func (s TradingStatus) IsHalt() bool {
+	return s.StatusCode.IsHalt()
+}
+
+// IsResumption returns true if trading of the symbol resumes
+func (s TradingStatus) IsResumption() bool {
+	return s.StatusCode.IsResumption()
+}
+
+// IsCircuitBreaker returns true if the symbol is halted by a market-wide
+// circuit breaker
+func (s TradingStatus) IsCircuitBreaker() bool {
+	return s.IsHalt() && s.ReasonCode.IsCircuitBreaker()
+}
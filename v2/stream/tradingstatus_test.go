@@ -0,0 +1,32 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTradingStatus(t *testing.T) {
+	halt := TradingStatus{Symbol: "AAPL", StatusCode: "H", ReasonCode: "T12", Tape: "C"}
+	assert.True(t, halt.IsHalt())
+	assert.False(t, halt.IsResumption())
+	assert.False(t, halt.IsCircuitBreaker())
+	assert.Equal(t, "Trading Halt", halt.StatusCode.Description())
+	assert.Contains(t, halt.ReasonCode.Description(), "Information Requested")
+
+	pause := TradingStatus{StatusCode: StatusUTDFVolatilityTradingPause, ReasonCode: ReasonUTDFVolatilityTradingPause}
+	assert.True(t, pause.IsHalt())
+	assert.True(t, pause.StatusCode.IsPause())
+	assert.True(t, pause.ReasonCode.IsLimitUpLimitDown())
+
+	breaker := TradingStatus{StatusCode: StatusCTATradingHalt, ReasonCode: ReasonCTACircuitBreakerLvl1, Tape: "A"}
+	assert.True(t, breaker.IsCircuitBreaker())
+
+	// the quotation resumes before trading
+	assert.False(t, TradingStatusCode("Q").IsResumption())
+	assert.True(t, TradingStatusCode("T").IsResumption())
+	assert.True(t, TradingStatus{StatusCode: StatusCTAResume}.IsResumption())
+
+	assert.Empty(t, TradingStatusCode("?").Description())
+	assert.Empty(t, TradingStatusReason("?").Description())
+}
@@ -0,0 +1,225 @@
+// Package watchlist keeps the subscriptions of the data stream in sync with a
+// watchlist of the account, so that the symbols followed by a service are
+// managed from the Alpaca dashboard, e.g.
+//
+//	syncer := watchlist.New(client, hub.DefaultUpstream, "universe",
+//		watchlist.WithBars(func(bar stream.Bar) { ... }),
+//	)
+//	go syncer.Run(ctx)
+package watchlist
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/clock"
+	"github.com/market-development-strategy/alpaca-trade-api-go/hub"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+)
+
+// Option configures a Syncer
+type Option func(s *Syncer)
+
+// WithTrades subscribes the trades of the symbols of the watchlist to
+// handler.
+func WithTrades(handler func(trade stream.Trade)) Option {
+	return func(s *Syncer) {
+		s.onTrade = handler
+	}
+}
+
+// WithQuotes subscribes the quotes of the symbols of the watchlist to
+// handler.
+func WithQuotes(handler func(quote stream.Quote)) Option {
+	return func(s *Syncer) {
+		s.onQuote = handler
+	}
+}
+
+// WithBars subscribes the bars of the symbols of the watchlist to handler.
+func WithBars(handler func(bar stream.Bar)) Option {
+	return func(s *Syncer) {
+		s.onBar = handler
+	}
+}
+
+// WithInterval sets how often Run checks the watchlist, every 5 minutes by
+// default.
+func WithInterval(interval time.Duration) Option {
+	return func(s *Syncer) {
+		s.interval = interval
+	}
+}
+
+// WithClock sets the clock Run waits on, clock.Real by default.
+func WithClock(c clock.Clock) Option {
+	return func(s *Syncer) {
+		s.clock = c
+	}
+}
+
+// OnChange sets the callback called with the symbols subscribed and
+// unsubscribed by a sync that changed the subscriptions.
+func OnChange(callback func(added, removed []string)) Option {
+	return func(s *Syncer) {
+		s.onChange = callback
+	}
+}
+
+// Syncer subscribes the symbols of a watchlist, and unsubscribes the
+// symbols removed from it.
+type Syncer struct {
+	client   alpaca.TradingClient
+	upstream hub.Upstream
+	name     string
+	interval time.Duration
+	clock    clock.Clock
+	onTrade  func(trade stream.Trade)
+	onQuote  func(quote stream.Quote)
+	onBar    func(bar stream.Bar)
+	onChange func(added, removed []string)
+
+	// mu serializes the syncs
+	mu      sync.Mutex
+	symbols map[string]bool
+}
+
+// New creates a syncer of the watchlist called name, read with client,
+// subscribing to upstream, e.g. hub.DefaultUpstream for the stream package.
+func New(client alpaca.TradingClient, upstream hub.Upstream, name string, opts ...Option) *Syncer {
+	s := &Syncer{
+		client:   client,
+		upstream: upstream,
+		name:     name,
+		interval: 5 * time.Minute,
+		clock:    clock.Real,
+		symbols:  map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Symbols returns the symbols subscribed, sorted.
+func (s *Syncer) Symbols() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sorted(s.symbols)
+}
+
+// Run syncs at once, then at the interval of the syncer until ctx is done.
+// The errors of the syncs are retried at the next one.
+func (s *Syncer) Run(ctx context.Context) error {
+	ticker := s.clock.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		s.Sync()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C():
+		}
+	}
+}
+
+// Sync reads the watchlist and changes the subscriptions to its symbols. A
+// sync that failed leaves the symbols it did not change to the next one.
+func (s *Syncer) Sync() error {
+	watchlist, err := s.client.GetWatchlistByName(s.name)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wanted := map[string]bool{}
+	for _, symbol := range watchlist.Symbols() {
+		wanted[symbol] = true
+	}
+	var added, removed []string
+	for _, symbol := range sorted(wanted) {
+		if !s.symbols[symbol] {
+			added = append(added, symbol)
+		}
+	}
+	for _, symbol := range sorted(s.symbols) {
+		if !wanted[symbol] {
+			removed = append(removed, symbol)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+
+	if len(removed) > 0 {
+		if err := s.unsubscribe(removed); err != nil {
+			return err
+		}
+		for _, symbol := range removed {
+			delete(s.symbols, symbol)
+		}
+	}
+	if len(added) > 0 {
+		if err := s.subscribe(added); err != nil {
+			return err
+		}
+		for _, symbol := range added {
+			s.symbols[symbol] = true
+		}
+	}
+	if s.onChange != nil {
+		s.onChange(added, removed)
+	}
+	return nil
+}
+
+func (s *Syncer) subscribe(symbols []string) error {
+	if s.onTrade != nil {
+		if err := s.upstream.SubscribeTrades(s.onTrade, symbols...); err != nil {
+			return err
+		}
+	}
+	if s.onQuote != nil {
+		if err := s.upstream.SubscribeQuotes(s.onQuote, symbols...); err != nil {
+			return err
+		}
+	}
+	if s.onBar != nil {
+		if err := s.upstream.SubscribeBars(s.onBar, symbols...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Syncer) unsubscribe(symbols []string) error {
+	if s.onTrade != nil {
+		if err := s.upstream.UnsubscribeTrades(symbols...); err != nil {
+			return err
+		}
+	}
+	if s.onQuote != nil {
+		if err := s.upstream.UnsubscribeQuotes(symbols...); err != nil {
+			return err
+		}
+	}
+	if s.onBar != nil {
+		if err := s.upstream.UnsubscribeBars(symbols...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sorted(set map[string]bool) []string {
+	symbols := make([]string, 0, len(set))
+	for symbol := range set {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
@@ -0,0 +1,94 @@
+package watchlist
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca"
+	"github.com/market-development-strategy/alpaca-trade-api-go/alpaca/alpacatest"
+	"github.com/market-development-strategy/alpaca-trade-api-go/v2/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeUpstream struct {
+	calls []string
+	err   error
+}
+
+func (u *fakeUpstream) record(call string, symbols []string) error {
+	if u.err != nil {
+		return u.err
+	}
+	u.calls = append(u.calls, call+" "+strings.Join(symbols, ","))
+	return nil
+}
+
+func (u *fakeUpstream) SubscribeTrades(handler func(trade stream.Trade), symbols ...string) error {
+	return u.record("+trades", symbols)
+}
+
+func (u *fakeUpstream) SubscribeQuotes(handler func(quote stream.Quote), symbols ...string) error {
+	return u.record("+quotes", symbols)
+}
+
+func (u *fakeUpstream) SubscribeBars(handler func(bar stream.Bar), symbols ...string) error {
+	return u.record("+bars", symbols)
+}
+
+func (u *fakeUpstream) UnsubscribeTrades(symbols ...string) error {
+	return u.record("-trades", symbols)
+}
+
+func (u *fakeUpstream) UnsubscribeQuotes(symbols ...string) error {
+	return u.record("-quotes", symbols)
+}
+
+func (u *fakeUpstream) UnsubscribeBars(symbols ...string) error {
+	return u.record("-bars", symbols)
+}
+
+func TestSyncer(t *testing.T) {
+	assets := []alpaca.Asset{{Symbol: "MSFT"}, {Symbol: "AAPL"}}
+	mock := &alpacatest.MockClient{
+		GetWatchlistByNameFunc: func(name string) (*alpaca.Watchlist, error) {
+			assert.Equal(t, "universe", name)
+			return &alpaca.Watchlist{Name: name, Assets: assets}, nil
+		},
+	}
+	upstream := &fakeUpstream{}
+	var changes []string
+	s := New(mock, upstream, "universe",
+		WithTrades(func(trade stream.Trade) {}),
+		WithBars(func(bar stream.Bar) {}),
+		OnChange(func(added, removed []string) {
+			changes = append(changes, "+"+strings.Join(added, ",")+" -"+strings.Join(removed, ","))
+		}),
+	)
+
+	require.NoError(t, s.Sync())
+	assert.Equal(t, []string{"+trades AAPL,MSFT", "+bars AAPL,MSFT"}, upstream.calls)
+	assert.Equal(t, []string{"AAPL", "MSFT"}, s.Symbols())
+
+	// nothing changed
+	require.NoError(t, s.Sync())
+	assert.Len(t, upstream.calls, 2)
+
+	upstream.calls = nil
+	assets = []alpaca.Asset{{Symbol: "AAPL"}, {Symbol: "TSLA"}}
+	require.NoError(t, s.Sync())
+	assert.Equal(t, []string{"-trades MSFT", "-bars MSFT", "+trades TSLA", "+bars TSLA"}, upstream.calls)
+	assert.Equal(t, []string{"+AAPL,MSFT -", "+TSLA -MSFT"}, changes)
+
+	// a failed sync is retried by the next one
+	upstream.calls = nil
+	upstream.err = errors.New("not connected")
+	assets = nil
+	assert.Error(t, s.Sync())
+	assert.Equal(t, []string{"AAPL", "TSLA"}, s.Symbols())
+	upstream.err = nil
+	require.NoError(t, s.Sync())
+	assert.Equal(t, []string{"-trades AAPL,TSLA", "-bars AAPL,TSLA"}, upstream.calls)
+	assert.Empty(t, s.Symbols())
+}